@@ -122,6 +122,9 @@ func ZGrab2Main() {
 		}
 		for i, fl := range flagsReturned {
 			f, _ := fl.(zgrab2.ScanFlags)
+			if err := zgrab2.ValidateTLSFlags(f); err != nil {
+				log.Fatalf("invalid flags for %s: %s", modTypes[i], err)
+			}
 			mod := zgrab2.GetModule(modTypes[i])
 			s := mod.NewScanner()
 			s.Init(f)
@@ -147,9 +150,11 @@ func ZGrab2Main() {
 	wg.Wait()
 	s := Summary{
 		StatusesPerModule: monitor.GetStatuses(),
+		Targets:           monitor.GetTargetCounts(),
 		StartTime:         start.Format(time.RFC3339),
 		EndTime:           end.Format(time.RFC3339),
 		Duration:          end.Sub(start).String(),
+		Elasticsearch:     zgrab2.GetElasticsearchStats(),
 	}
 	enc := json.NewEncoder(zgrab2.GetMetaFile())
 	if err := enc.Encode(&s); err != nil {
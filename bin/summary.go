@@ -5,7 +5,12 @@ import "github.com/zmap/zgrab2"
 // Summary holds the results of a run of a ZGrab2 binary.
 type Summary struct {
 	StatusesPerModule map[string]*zgrab2.State `json:"statuses"`
+	Targets           *zgrab2.TargetCounts     `json:"targets"`
 	StartTime         string                   `json:"start"`
 	EndTime           string                   `json:"end"`
 	Duration          string                   `json:"duration"`
+
+	// Elasticsearch counts the results indexed by --output-elasticsearch,
+	// including any indexing errors.
+	Elasticsearch *zgrab2.ElasticsearchStats `json:"elasticsearch,omitempty"`
 }
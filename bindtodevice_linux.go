@@ -0,0 +1,23 @@
+//go:build linux
+// +build linux
+
+package zgrab2
+
+import (
+	"syscall"
+)
+
+// bindToDeviceControl returns a net.Dialer Control function that binds
+// sockets to the named network interface with SO_BINDTODEVICE, for
+// --interface.
+func bindToDeviceControl(device string) (func(network, address string, c syscall.RawConn) error, error) {
+	return func(network, address string, c syscall.RawConn) error {
+		var err error
+		if controlErr := c.Control(func(fd uintptr) {
+			err = syscall.BindToDevice(int(fd), device)
+		}); controlErr != nil {
+			return controlErr
+		}
+		return err
+	}, nil
+}
@@ -0,0 +1,51 @@
+//go:build linux
+// +build linux
+
+package zgrab2
+
+import (
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBindToDevice(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	defer func(control func(string, string, syscall.RawConn) error) {
+		config.dialControl = control
+	}(config.dialControl)
+
+	if config.dialControl, err = bindToDeviceControl("lo"); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := DialTimeoutConnection("tcp", listener.Addr().String(), time.Second, 0)
+	if err != nil && strings.Contains(err.Error(), "operation not permitted") {
+		t.Skip("SO_BINDTODEVICE requires CAP_NET_RAW")
+	} else if err != nil {
+		t.Fatalf("could not connect through lo: %v", err)
+	}
+	conn.Close()
+
+	if config.dialControl, err = bindToDeviceControl("zgrab2-none0"); err != nil {
+		t.Fatal(err)
+	}
+	if conn, err := DialTimeoutConnection("tcp", listener.Addr().String(), time.Second, 0); err == nil {
+		conn.Close()
+		t.Errorf("expected an error binding to a nonexistent interface")
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package zgrab2
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+// bindToDeviceControl is only supported on Linux, which has
+// SO_BINDTODEVICE.
+func bindToDeviceControl(device string) (func(network, address string, c syscall.RawConn) error, error) {
+	return nil, fmt.Errorf("--interface is not supported on %s", runtime.GOOS)
+}
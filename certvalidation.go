@@ -0,0 +1,175 @@
+package zgrab2
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/zmap/zcrypto/tls"
+	"github.com/zmap/zcrypto/x509"
+)
+
+// systemRootFiles lists the locations of the system's PEM bundle of trusted
+// roots on common platforms; the first one that can be read is used. The
+// SSL_CERT_FILE environment variable overrides them.
+var systemRootFiles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",                // Debian/Ubuntu/Gentoo etc.
+	"/etc/pki/tls/certs/ca-bundle.crt",                  // Fedora/RHEL 6
+	"/etc/ssl/ca-bundle.pem",                            // OpenSUSE
+	"/etc/pki/tls/cacert.pem",                           // OpenELEC
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem", // CentOS/RHEL 7
+	"/etc/ssl/cert.pem",                                 // Alpine Linux, macOS, BSDs
+}
+
+// ErrNoSystemRoots is recorded when --validate-cert is used without
+// --ca-file, and none of the system root bundles could be read.
+var ErrNoSystemRoots = errors.New("no system root certificates found; use --ca-file")
+
+// validationRoots caches the root pools used by --validate-cert, keyed by
+// --ca-file ("" for the system roots).
+var validationRoots = struct {
+	sync.Mutex
+	pools map[string]*x509.CertPool
+}{pools: make(map[string]*x509.CertPool)}
+
+// getValidationRoots returns the pool of trusted roots loaded from caFile,
+// or from the system bundle if caFile is empty. A --ca-file is first loaded
+// when the flags are validated (see TLSFlags.validate), so an unreadable one
+// stops zgrab2 before the scan starts.
+func getValidationRoots(caFile string) (*x509.CertPool, error) {
+	validationRoots.Lock()
+	defer validationRoots.Unlock()
+	if pool, ok := validationRoots.pools[caFile]; ok {
+		return pool, nil
+	}
+	pool := x509.NewCertPool()
+	if caFile != "" {
+		caBytes, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read --ca-file %s: %s", caFile, err)
+		}
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("could not read certificates from --ca-file %s; invalid PEM?", caFile)
+		}
+	} else {
+		files := systemRootFiles
+		if env := os.Getenv("SSL_CERT_FILE"); env != "" {
+			files = []string{env}
+		}
+		found := false
+		for _, file := range files {
+			if caBytes, err := ioutil.ReadFile(file); err == nil && pool.AppendCertsFromPEM(caBytes) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, ErrNoSystemRoots
+		}
+	}
+	validationRoots.pools[caFile] = pool
+	return pool, nil
+}
+
+// CertValidation holds the properties of the server certificate derived
+// with --validate-cert.
+type CertValidation struct {
+	// Valid is true if the certificate chains to a trusted root, is within
+	// its validity period and matches Hostname.
+	Valid bool `json:"valid"`
+
+	// ChainValid is true if the certificate chains to a trusted root, using
+	// the intermediates presented by the server, at the current time.
+	ChainValid bool `json:"chain_valid"`
+
+	// SelfSigned is true if the certificate is signed by its own key.
+	SelfSigned bool `json:"self_signed"`
+
+	// Expired is true if the certificate's NotAfter is in the past.
+	Expired bool `json:"expired"`
+
+	// NotYetValid is true if the certificate's NotBefore is in the future.
+	NotYetValid bool `json:"not_yet_valid"`
+
+	// Hostname is the name the certificate was checked against: the server
+	// name used for the handshake, or the IP address if there was none.
+	Hostname string `json:"hostname,omitempty"`
+
+	// HostnameMismatch is true if the certificate is not valid for
+	// Hostname.
+	HostnameMismatch bool `json:"hostname_mismatch"`
+
+	// DaysUntilExpiry is the number of whole days until NotAfter; it is
+	// negative once the certificate has been expired for a day or more.
+	DaysUntilExpiry int `json:"days_until_expiry"`
+
+	// Error is the reason the chain could not be validated, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// validateCertificate checks the server certificate in the handshake log
+// against the roots of --ca-file (or the system roots) at time now, and
+// against hostname. Returns nil if no certificate was received.
+func validateCertificate(handshake *tls.ServerHandshake, caFile string, hostname string, now time.Time) *CertValidation {
+	if handshake == nil || handshake.ServerCertificates == nil || handshake.ServerCertificates.Certificate.Parsed == nil {
+		return nil
+	}
+	leaf := handshake.ServerCertificates.Certificate.Parsed
+	ret := &CertValidation{
+		SelfSigned:      leaf.SelfSigned,
+		Expired:         now.After(leaf.NotAfter),
+		NotYetValid:     now.Before(leaf.NotBefore),
+		Hostname:        hostname,
+		DaysUntilExpiry: int(leaf.NotAfter.Sub(now) / (24 * time.Hour)),
+	}
+	ret.HostnameMismatch = leaf.VerifyHostname(hostname) != nil
+
+	roots, err := getValidationRoots(caFile)
+	if err != nil {
+		ret.Error = err.Error()
+		return ret
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range handshake.ServerCertificates.Chain {
+		if cert.Parsed != nil {
+			intermediates.AddCert(cert.Parsed)
+		}
+	}
+	current, _, _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	})
+	ret.ChainValid = len(current) > 0
+	if !ret.ChainValid && err != nil {
+		ret.Error = err.Error()
+	}
+	ret.Valid = ret.ChainValid && !ret.Expired && !ret.NotYetValid && !ret.HostnameMismatch
+	return ret
+}
+
+// validateServerCertificate runs validateCertificate on the connection's
+// handshake log, if --validate-cert is set.
+func (z *TLSConnection) validateServerCertificate(handshake *tls.ServerHandshake) *CertValidation {
+	if !z.flags.ValidateCert {
+		return nil
+	}
+	now := time.Now()
+	hostname := ""
+	if z.config != nil {
+		if z.config.Time != nil {
+			now = z.config.Time()
+		}
+		hostname = z.config.ServerName
+	}
+	if hostname == "" {
+		if host, _, err := net.SplitHostPort(z.Conn.RemoteAddr().String()); err == nil {
+			hostname = host
+		}
+	}
+	return validateCertificate(handshake, z.flags.CAFile, hostname, now)
+}
@@ -0,0 +1,148 @@
+package zgrab2
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	stdx509 "crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/zmap/zcrypto/tls"
+	"github.com/zmap/zcrypto/x509"
+)
+
+// testCertificate is a certificate created for a test, along with its key.
+type testCertificate struct {
+	template *stdx509.Certificate
+	key      *ecdsa.PrivateKey
+	raw      []byte
+	parsed   *x509.Certificate
+}
+
+// makeTestCertificate creates a certificate for the given DNS name (or a
+// CA, if name is empty), valid from notBefore to notAfter and signed by
+// parent (or self-signed, if parent is nil).
+func makeTestCertificate(t *testing.T, name string, notBefore, notAfter time.Time, parent *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &stdx509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     stdx509.KeyUsageDigitalSignature | stdx509.KeyUsageCertSign,
+		ExtKeyUsage:  []stdx509.ExtKeyUsage{stdx509.ExtKeyUsageServerAuth},
+	}
+	if name == "" {
+		template.Subject.CommonName = "zgrab2 test CA"
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		template.DNSNames = []string{name}
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.template, parent.key
+	}
+	raw, err := stdx509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCertificate{template: template, key: key, raw: raw, parsed: parsed}
+}
+
+func handshakeWith(leaf *testCertificate, chain ...*testCertificate) *tls.ServerHandshake {
+	certs := &tls.Certificates{Certificate: tls.SimpleCertificate{Raw: leaf.raw, Parsed: leaf.parsed}}
+	for _, cert := range chain {
+		certs.Chain = append(certs.Chain, tls.SimpleCertificate{Raw: cert.raw, Parsed: cert.parsed})
+	}
+	return &tls.ServerHandshake{ServerCertificates: certs}
+}
+
+func TestValidateCertificate(t *testing.T) {
+	now := time.Now()
+	ca := makeTestCertificate(t, "", now.Add(-time.Hour), now.Add(365*24*time.Hour), nil)
+	caFile, err := ioutil.TempFile("", "zgrab2-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(caFile.Name())
+	pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: ca.raw})
+	caFile.Close()
+
+	good := makeTestCertificate(t, "example.com", now.Add(-time.Hour), now.Add(30*24*time.Hour+time.Hour), ca)
+	expired := makeTestCertificate(t, "example.com", now.Add(-48*time.Hour), now.Add(-24*time.Hour-time.Hour), nil)
+	future := makeTestCertificate(t, "example.com", now.Add(24*time.Hour), now.Add(48*time.Hour+time.Hour), ca)
+
+	tests := []struct {
+		name      string
+		handshake *tls.ServerHandshake
+		hostname  string
+		expected  CertValidation
+	}{
+		{"valid", handshakeWith(good, ca), "example.com", CertValidation{Valid: true, ChainValid: true, DaysUntilExpiry: 30}},
+		{"mismatch", handshakeWith(good), "example.org", CertValidation{ChainValid: true, HostnameMismatch: true, DaysUntilExpiry: 30}},
+		{"expired self-signed", handshakeWith(expired), "example.com", CertValidation{SelfSigned: true, Expired: true, DaysUntilExpiry: -1}},
+		{"not yet valid", handshakeWith(future), "example.com", CertValidation{NotYetValid: true, DaysUntilExpiry: 2}},
+	}
+	for _, test := range tests {
+		result := validateCertificate(test.handshake, caFile.Name(), test.hostname, now)
+		if result == nil {
+			t.Fatalf("%s: no result", test.name)
+		}
+		if test.expected.ChainValid != (result.Error == "") {
+			t.Errorf("%s: unexpected error %q", test.name, result.Error)
+		}
+		result.Hostname, result.Error = "", ""
+		if *result != test.expected {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, *result)
+		}
+	}
+
+	if validateCertificate(&tls.ServerHandshake{}, caFile.Name(), "example.com", now) != nil {
+		t.Error("expected no result without a certificate")
+	}
+}
+
+// tlsTestFlags are the flags of a module that uses TLS.
+type tlsTestFlags struct {
+	BaseFlags
+	TLSFlags
+}
+
+func (flags *tlsTestFlags) Validate(args []string) error {
+	return nil
+}
+
+func (flags *tlsTestFlags) Help() string {
+	return ""
+}
+
+func TestValidateTLSFlagsCAFile(t *testing.T) {
+	badFile, err := ioutil.TempFile("", "zgrab2-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(badFile.Name())
+	badFile.WriteString("not a certificate")
+	badFile.Close()
+
+	for _, caFile := range []string{badFile.Name(), badFile.Name() + ".missing"} {
+		flags := &tlsTestFlags{TLSFlags: TLSFlags{ValidateCert: true, CAFile: caFile}}
+		if err := ValidateTLSFlags(flags); err == nil {
+			t.Errorf("expected an error for --ca-file %s", caFile)
+		}
+	}
+}
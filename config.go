@@ -1,12 +1,13 @@
 package zgrab2
 
 import (
+	"io/ioutil"
 	"net"
-	"net/http"
 	"os"
 	"runtime"
+	"syscall"
+	"time"
 
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -14,17 +15,59 @@ import (
 // from the command line
 type Config struct {
 	OutputFileName     string          `short:"o" long:"output-file" default:"-" description:"Output filename, use - for stdout"`
+	OutputKafka        string          `long:"output-kafka" description:"Produce results to Kafka instead of the output file, given as broker1:port,broker2:port/topic"`
+	CSV                bool            `long:"csv" description:"Write results as CSV rows containing only the --csv-fields, instead of JSON"`
+	CSVFields          string          `long:"csv-fields" description:"Comma-separated list of dotted paths into the results to include as CSV columns (e.g. ip,data.http.status)"`
+	OutputSyslog       string          `long:"output-syslog" description:"Send results to the syslog server at host:port instead of the output file"`
+	SyslogProtocol     string          `long:"syslog-protocol" default:"udp" choice:"udp" choice:"tcp" description:"Transport to use for --output-syslog"`
+	SyslogFacility     string          `long:"syslog-facility" default:"local0" description:"Syslog facility keyword (e.g. local0) or code to use for --output-syslog"`
+	SyslogAppName      string          `long:"syslog-app-name" default:"zgrab2" description:"Syslog APP-NAME to use for --output-syslog"`
+	SyslogMaxSize      int             `long:"syslog-max-message-size" default:"2048" description:"Maximum syslog message size in bytes; longer results are truncated"`
+	OutputElastic      string          `long:"output-elasticsearch" description:"Index results into Elasticsearch with the _bulk API instead of the output file, given as http://host:port/index"`
+	ElasticBatchSize   int             `long:"elasticsearch-batch-size" default:"500" description:"Number of results to send in each --output-elasticsearch bulk request"`
+	ElasticInterval    time.Duration   `long:"elasticsearch-flush-interval" default:"5s" description:"Send the results queued for --output-elasticsearch at least this often"`
+	ElasticRetries     int             `long:"elasticsearch-retries" default:"3" description:"Number of times to retry results that --output-elasticsearch fails to index"`
 	InputFileName      string          `short:"f" long:"input-file" default:"-" description:"Input filename, use - for stdin"`
+	InputFormat        string          `long:"input-format" default:"csv" choice:"csv" choice:"json" description:"Format of the input file: CSV (IP, DOMAIN, TAG) or JSON lines (see GetTargetsJSON)"`
 	MetaFileName       string          `short:"m" long:"metadata-file" default:"-" description:"Metadata filename, use - for stderr"`
 	LogFileName        string          `short:"l" long:"log-file" default:"-" description:"Log filename, use - for stderr"`
+	LogJSON            bool            `long:"log-json" description:"Write log records as JSON objects, one per line, instead of text"`
+	LogLevel           string          `long:"log-level" default:"info" choice:"trace" choice:"debug" choice:"info" choice:"warning" choice:"error" choice:"fatal" description:"Log only records of this level or above"`
+	Ports              string          `long:"ports" description:"Comma-separated list of ports and port ranges (e.g. 80,443,8000-8010) to scan each target on, instead of the module's port; each (target, port) is a separate scan with its own result. Targets whose input gives a port are scanned on that port only"`
+	FallbackPorts      string          `long:"fallback-ports" description:"Comma-separated list of ports and port ranges to retry each module on, in order, if the connection to its port is refused; the port that answered is recorded in the module's result"`
 	LocalAddress       string          `long:"source-ip" description:"Local source IP address to use for making connections"`
+	Interface          string          `long:"interface" description:"Bind connections to this network interface (e.g. eth0) with SO_BINDTODEVICE, for policy routing or VRFs; Linux only, and requires CAP_NET_RAW"`
+	TCPKeepAlive       time.Duration   `long:"tcp-keepalive" description:"Send TCP keepalive probes on connections idle for this long (e.g. 60s), instead of Go's default of 15s; keepalives are always enabled (0 = Go's default)"`
+	TCPNagle           bool            `long:"tcp-nagle" description:"Clear TCP_NODELAY on connections, so that small writes are coalesced (Nagle's algorithm); Go sets TCP_NODELAY by default"`
+	CaptureWire        bool            `long:"capture-wire" description:"Record the raw bytes sent and received on each module's connections (opened with ScanTarget.Open), as hex in its wire field"`
+	CaptureWireSize    int             `long:"capture-wire-size" default:"4096" description:"Maximum number of bytes recorded by --capture-wire in each direction, per module"`
+	ReplayFile         string          `long:"replay-file" description:"For offline testing, replay the server bytes recorded in this file on every connection that a module opens with ScanTarget.Open, instead of connecting; the client's bytes are discarded"`
 	Senders            int             `short:"s" long:"senders" default:"1000" description:"Number of send goroutines to use"`
+	ResultMeta         bool            `long:"result-meta" description:"Add a _meta object to each result, recording the output schema version, the zgrab2 version and the modules that produced it"`
+	MaxRecordBytes     int             `long:"max-record-bytes" description:"Shrink results longer than this many bytes when encoded (0 = no limit) by clipping or dropping their largest fields, marked with truncated and truncated_fields"`
 	Debug              bool            `long:"debug" description:"Include debug fields in the output."`
 	Flush              bool            `long:"flush" description:"Flush after each line of output."`
+	SuccessOnly        bool            `long:"success-only" description:"Only output the results of targets for which some module got past connecting (any status but connection-refused, connection-timeout, connection-closed or io-timeout); the others are still counted in the summary"`
+	OrderedOutput      bool            `long:"ordered-output" description:"Write the results in the order of the input targets, holding back the results of later targets while an earlier one is still being scanned"`
+	OrderedWindow      int             `long:"ordered-output-window" default:"10000" description:"Maximum number of targets whose results --ordered-output holds back; beyond it, results are written out of order"`
 	GOMAXPROCS         int             `long:"gomaxprocs" default:"0" description:"Set GOMAXPROCS"`
+	Skip               uint            `long:"skip" description:"Skip the first N records of the input file (not counting empty and comment lines); with --max-targets, splits an input into shards"`
+	EchoInput          bool            `long:"echo-input" description:"Include the input record (CSV line or JSON object) that each target was read from in its result, as raw_input"`
+	MaxTargets         uint            `long:"max-targets" description:"Stop after scanning this many targets (0 = no limit); meant for quick test runs"`
+	SampleRate         uint            `long:"sample-rate" description:"Scan a random sample of about one in every K targets (0 or 1 = all); meant for quick test runs"`
+	Dedup              bool            `long:"dedup" description:"Skip input targets already seen in this run with the same address, port and tag"`
+	DedupBloomSize     uint            `long:"dedup-bloom-size" description:"Track the targets seen by --dedup in a Bloom filter of this many megabytes instead of an exact set; a small fraction of new targets may be skipped"`
 	ConnectionsPerHost int             `long:"connections-per-host" default:"1" description:"Number of times to connect to each host (results in more output)"`
+	MaxPerHost         uint            `long:"max-per-host" description:"Scan at most this many targets with the same IP address (or domain, if no IP is given) at once (0 = unlimited); other workers wait their turn"`
 	ReadLimitPerHost   int             `long:"read-limit-per-host" default:"96" description:"Maximum total kilobytes to read for a single host (default 96kb)"`
-	Prometheus         string          `long:"prometheus" description:"Address to use for Prometheus server (e.g. localhost:8080). If empty, Prometheus is disabled."`
+	CloseGraceful      bool            `long:"close-graceful" description:"Close connections with a FIN, waiting briefly for the server to close its side so that they are never reset; modules with a logout command (e.g. QUIT) send it first"`
+	CloseRST           bool            `long:"close-rst" description:"Close connections with a RST instead of a FIN, for speed"`
+	GeoIP              []string        `long:"geoip" description:"MaxMind database (.mmdb) to look up the country, city and ASN of each target's IP in, recorded in its geoip field; may be given more than once, e.g. for a City and an ASN database"`
+	SignaturesFile     string          `long:"signatures-file" description:"JSON file of {\"name\": ..., \"regex\": ...} rules; the names of the rules matching each module's result are recorded in its signatures list"`
+	MetricsAddr        string          `long:"metrics-addr" description:"Address on which to export Prometheus metrics at /metrics while the scan runs (e.g. localhost:8080). If empty, metrics are not exported."`
+	Prometheus         string          `long:"prometheus" description:"Deprecated alias for --metrics-addr"`
+	Statsd             string          `long:"statsd" description:"Push scan metrics over UDP to the StatsD server at host:port while the scan runs (e.g. localhost:8125)"`
+	StatsdPrefix       string          `long:"statsd-prefix" default:"zgrab2" description:"Prefix for the metric names pushed to --statsd"`
 	Multiple           MultipleCommand `command:"multiple" description:"Multiple module actions"`
 	inputFile          *os.File
 	outputFile         *os.File
@@ -33,6 +76,12 @@ type Config struct {
 	inputTargets       InputTargetsFunc
 	outputResults      OutputResultsFunc
 	localAddr          *net.TCPAddr
+	dialControl        func(network, address string, c syscall.RawConn) error
+	signatures         []*Signature
+	ports              []uint
+	fallbackPorts      []uint
+	elasticsearch      *ElasticsearchOutputSink
+	replayData         []byte
 }
 
 // SetInputFunc sets the target input function to the provided function.
@@ -53,6 +102,16 @@ func init() {
 var config Config
 
 func validateFrameworkConfiguration() {
+	// set up logging before anything else is logged
+	if config.LogJSON {
+		log.SetFormatter(&log.JSONFormatter{})
+	}
+	if level, err := log.ParseLevel(config.LogLevel); err != nil {
+		log.Fatal(err)
+	} else {
+		log.SetLevel(level)
+	}
+
 	// validate files
 	if config.LogFileName == "-" {
 		config.logFile = os.Stderr
@@ -62,8 +121,17 @@ func validateFrameworkConfiguration() {
 			log.Fatal(err)
 		}
 		log.SetOutput(config.logFile)
+		// log.Fatal exits without running deferred calls, so make sure the
+		// log file is flushed to disk first.
+		log.RegisterExitHandler(func() {
+			config.logFile.Sync()
+		})
+	}
+	if config.InputFormat == "json" {
+		SetInputFunc(InputTargetsJSON)
+	} else {
+		SetInputFunc(InputTargetsCSV)
 	}
-	SetInputFunc(InputTargetsCSV)
 
 	if config.LocalAddress != "" {
 		parsed := net.ParseIP(config.LocalAddress)
@@ -73,6 +141,21 @@ func validateFrameworkConfiguration() {
 		config.localAddr = &net.TCPAddr{parsed, 0, ""}
 	}
 
+	if config.Interface != "" {
+		if _, err := net.InterfaceByName(config.Interface); err != nil {
+			log.Fatalf("invalid --interface %s: %v", config.Interface, err)
+		}
+		control, err := bindToDeviceControl(config.Interface)
+		if err != nil {
+			log.Fatal(err)
+		}
+		config.dialControl = control
+	}
+
+	if config.TCPKeepAlive < 0 {
+		log.Fatalf("--tcp-keepalive must not be negative")
+	}
+
 	if config.InputFileName == "-" {
 		config.inputFile = os.Stdin
 	} else {
@@ -82,16 +165,107 @@ func validateFrameworkConfiguration() {
 		}
 	}
 
-	if config.OutputFileName == "-" {
-		config.outputFile = os.Stdout
+	outputs := 0
+	for _, output := range []string{config.OutputKafka, config.OutputSyslog, config.OutputElastic} {
+		if output != "" {
+			outputs++
+		}
+	}
+	if outputs > 1 {
+		log.Fatal("at most one of --output-kafka, --output-syslog and --output-elasticsearch may be given")
+	}
+	var sink OutputSink
+	if config.OutputKafka != "" {
+		brokers, topic, err := ParseKafkaDestination(config.OutputKafka)
+		if err != nil {
+			log.Fatal(err)
+		}
+		sink = NewKafkaOutputSink(brokers, topic)
+	} else if config.OutputElastic != "" {
+		bulkURL, index, err := ParseElasticsearchDestination(config.OutputElastic)
+		if err != nil {
+			log.Fatal(err)
+		}
+		config.elasticsearch = NewElasticsearchOutputSink(bulkURL, index, config.ElasticBatchSize, config.ElasticInterval, config.ElasticRetries)
+		sink = config.elasticsearch
+	} else if config.OutputSyslog != "" {
+		facility, err := ParseSyslogFacility(config.SyslogFacility)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if sink, err = NewSyslogOutputSink(config.SyslogProtocol, config.OutputSyslog, facility, config.SyslogAppName, config.SyslogMaxSize); err != nil {
+			log.Fatalf("could not connect to syslog server %s: %v", config.OutputSyslog, err)
+		}
 	} else {
+		if config.OutputFileName == "-" {
+			config.outputFile = os.Stdout
+		} else {
+			var err error
+			if config.outputFile, err = os.Create(config.OutputFileName); err != nil {
+				log.Fatal(err)
+			}
+		}
+		sink = NewWriterOutputSink(config.outputFile)
+	}
+	if config.CSV {
+		fields := ParseCSVFields(config.CSVFields)
+		if len(fields) == 0 {
+			log.Fatal("--csv requires at least one field in --csv-fields")
+		}
 		var err error
-		if config.outputFile, err = os.Create(config.OutputFileName); err != nil {
+		if sink, err = NewCSVOutputSink(sink, fields); err != nil {
+			log.Fatalf("could not write the CSV header: %v", err)
+		}
+	}
+	SetOutputFunc(OutputResultsSinkFunc(sink))
+
+	if config.Ports != "" {
+		ports, err := ParsePorts(config.Ports)
+		if err != nil {
+			log.Fatalf("invalid --ports: %v", err)
+		}
+		config.ports = ports
+	}
+
+	if config.FallbackPorts != "" {
+		ports, err := ParsePorts(config.FallbackPorts)
+		if err != nil {
+			log.Fatalf("invalid --fallback-ports: %v", err)
+		}
+		config.fallbackPorts = ports
+	}
+
+	if config.ReplayFile != "" {
+		data, err := ioutil.ReadFile(config.ReplayFile)
+		if err != nil {
 			log.Fatal(err)
 		}
+		config.replayData = data
+	}
+
+	if len(config.GeoIP) > 0 {
+		processor, err := OpenGeoIP(config.GeoIP)
+		if err != nil {
+			log.Fatal(err)
+		}
+		RegisterResultProcessor(processor)
+	}
+
+	if config.SignaturesFile != "" {
+		file, err := os.Open(config.SignaturesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		signatures, errs := LoadSignatures(file)
+		file.Close()
+		for _, err := range errs {
+			log.Errorf("invalid signature in %s: %v", config.SignaturesFile, err)
+		}
+		if len(errs) > 0 {
+			log.Fatalf("%d invalid signature(s) in %s", len(errs), config.SignaturesFile)
+		}
+		config.signatures = signatures
 	}
-	outputFunc := OutputResultsWriterFunc(config.outputFile)
-	SetOutputFunc(outputFunc)
 
 	if config.MetaFileName == "-" {
 		config.metaFile = os.Stderr
@@ -102,20 +276,28 @@ func validateFrameworkConfiguration() {
 		}
 	}
 
+	if config.CloseGraceful && config.CloseRST {
+		log.Fatal("at most one of --close-graceful and --close-rst may be given")
+	}
+	if config.CloseGraceful {
+		DefaultCloseMode = CloseModeGraceful
+	} else if config.CloseRST {
+		DefaultCloseMode = CloseModeRST
+	}
+
+	if config.OrderedOutput && config.OrderedWindow < 1 {
+		log.Fatalf("invalid --ordered-output-window (must be positive, given %d)", config.OrderedWindow)
+	}
+
 	// Validate Go Runtime config
 	if config.GOMAXPROCS < 0 {
 		log.Fatalf("invalid GOMAXPROCS (must be positive, given %d)", config.GOMAXPROCS)
 	}
 	runtime.GOMAXPROCS(config.GOMAXPROCS)
 
-	//validate/start prometheus
-	if config.Prometheus != "" {
-		go func() {
-			http.Handle("metrics", promhttp.Handler())
-			if err := http.ListenAndServe(config.Prometheus, nil); err != nil {
-				log.Fatalf("could not run prometheus server: %s", err.Error())
-			}
-		}()
+	// The metrics server itself is started by Process()
+	if config.MetricsAddr == "" {
+		config.MetricsAddr = config.Prometheus
 	}
 
 	//validate senders
@@ -123,6 +305,14 @@ func validateFrameworkConfiguration() {
 		log.Fatalf("need at least one sender, given %d", config.Senders)
 	}
 
+	if config.DedupBloomSize > 0 && !config.Dedup {
+		log.Fatal("--dedup-bloom-size requires --dedup")
+	}
+
+	if config.CaptureWire && config.CaptureWireSize <= 0 {
+		log.Fatalf("--capture-wire-size must be positive, given %d", config.CaptureWireSize)
+	}
+
 	// validate connections per host
 	if config.ConnectionsPerHost <= 0 {
 		log.Fatalf("need at least one connection, given %d", config.ConnectionsPerHost)
@@ -144,6 +334,15 @@ func GetMetaFile() *os.File {
 	return config.metaFile
 }
 
+// GetElasticsearchStats returns the counts of results indexed by
+// --output-elasticsearch, or nil if it is not in use.
+func GetElasticsearchStats() *ElasticsearchStats {
+	if config.elasticsearch == nil {
+		return nil
+	}
+	return config.elasticsearch.Stats()
+}
+
 func includeDebugOutput() bool {
 	return config.Debug
 }
@@ -4,7 +4,11 @@ import (
 	"context"
 	"errors"
 	"io"
+	"io/ioutil"
 	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -29,6 +33,28 @@ const (
 	ReadLimitExceededActionPanic = ReadLimitExceededAction("panic")
 )
 
+// CloseMode describes how a connection is torn down when it is closed.
+type CloseMode string
+
+const (
+	// CloseModeNotSet is a placeholder for the zero value, so that explicitly set values can be
+	// distinguished from the empty default.
+	CloseModeNotSet = CloseMode("")
+
+	// CloseModeNormal closes the socket as usual: the OS sends a FIN, or a RST if data the server sent
+	// was left unread.
+	CloseModeNormal = CloseMode("normal")
+
+	// CloseModeGraceful shuts down the sending side first (sending a FIN), and discards whatever the
+	// server still sends until it closes its side or GracefulCloseTimeout passes, so that the
+	// connection is never reset for unread data.
+	CloseModeGraceful = CloseMode("graceful")
+
+	// CloseModeRST sets a zero linger time, so that the OS resets the connection at once instead of
+	// sending a FIN, and the socket skips TIME_WAIT.
+	CloseModeRST = CloseMode("rst")
+)
+
 var (
 	// DefaultBytesReadLimit is the maximum number of bytes to read per connection when no explicit value is provided.
 	DefaultBytesReadLimit = 256 * 1024 * 1024
@@ -38,12 +64,48 @@ var (
 
 	// DefaultSessionTimeout is the default maximum time a connection may be used when no explicit value is provided.
 	DefaultSessionTimeout = 1 * time.Minute
+
+	// DefaultCloseMode is the close mode used when no explicit mode is set; --close-graceful and
+	// --close-rst change it.
+	DefaultCloseMode = CloseModeNormal
+
+	// GracefulCloseTimeout is the maximum time CloseModeGraceful waits for the server to close its
+	// side of the connection.
+	GracefulCloseTimeout = 2 * time.Second
 )
 
+// gracefulCloseDrainLimit bounds the data CloseModeGraceful discards while waiting for the server
+// to close its side.
+const gracefulCloseDrainLimit = 64 * 1024
+
+// CloseGracefully returns true if connections are closed with CloseModeGraceful (i.e.
+// --close-graceful was given). Modules whose protocol has a logout command (e.g. QUIT) should then
+// send it before closing, even if they would not otherwise.
+func CloseGracefully() bool {
+	return DefaultCloseMode == CloseModeGraceful
+}
+
 // ErrReadLimitExceeded is returned / panic'd from Read if the read limit is exceeded when the
 // ReadLimitExceededAction is error / panic.
 var ErrReadLimitExceeded = errors.New("read limit exceeded")
 
+// ErrServerSpeaksTLS is returned from the first Read on a plaintext connection
+// with TLS detection enabled (see EnableTLSDetection) if the server answered
+// with a TLS record, so that modules record a clear diagnostic instead of the
+// record's binary contents as a banner.
+var ErrServerSpeaksTLS = NewScanError(SCAN_PROTOCOL_ERROR, errors.New("server speaks TLS, use --tls"))
+
+// looksLikeTLSRecord returns true if b starts with the header of a TLS
+// handshake or alert record: content type 0x16 or 0x15, a 0x03 0x00-0x04
+// version and a length no greater than the maximum record size.
+func looksLikeTLSRecord(b []byte) bool {
+	if len(b) < 5 || (b[0] != 0x16 && b[0] != 0x15) || b[1] != 0x03 || b[2] > 0x04 {
+		return false
+	}
+	length := int(b[3])<<8 | int(b[4])
+	return length > 0 && length <= 16384+2048
+}
+
 // TimeoutConnection wraps an existing net.Conn connection, overriding the Read/Write methods to use the configured timeouts
 // TODO: Refactor this into TimeoutConnection, BoundedReader, LoggedReader, etc
 type TimeoutConnection struct {
@@ -56,10 +118,80 @@ type TimeoutConnection struct {
 	BytesWritten            int
 	BytesReadLimit          int
 	ReadLimitExceededAction ReadLimitExceededAction
+	CloseMode               CloseMode
 	Cancel                  context.CancelFunc
 	explicitReadDeadline    bool
 	explicitWriteDeadline   bool
 	explicitDeadline        bool
+	closeOnce               sync.Once
+	closeErr                error
+	// drained is set (atomically) once Close has started draining a
+	// CloseModeGraceful connection.
+	drained int32
+	// detectTLS is set (to 1) by EnableTLSDetection. It, firstReadChecked and
+	// sentTLS are accessed atomically, since Read and Write may be called
+	// concurrently.
+	detectTLS int32
+	// firstReadChecked is set once the first data read has been checked for
+	// a TLS record.
+	firstReadChecked int32
+	// sentTLS is set if the first data written was a TLS record, i.e. the
+	// connection is carrying TLS rather than a plaintext protocol.
+	sentTLS int32
+}
+
+// EnableTLSDetection makes the first Read fail with ErrServerSpeaksTLS if the
+// server answers with a TLS record, unless the connection itself started with
+// one. It is meant for modules speaking text protocols, where such a record
+// can only mean that the port expects TLS; it must be called before the
+// first Read.
+func (c *TimeoutConnection) EnableTLSDetection() {
+	atomic.StoreInt32(&c.detectTLS, 1)
+}
+
+// EnableTLSDetection enables TLS detection (see
+// TimeoutConnection.EnableTLSDetection) on conn, if it is or wraps a
+// *TimeoutConnection; otherwise it does nothing.
+func EnableTLSDetection(conn net.Conn) {
+	if timeoutConn := GetTimeoutConnection(conn); timeoutConn != nil {
+		timeoutConn.EnableTLSDetection()
+	}
+}
+
+// GetTimeoutConnection returns the *TimeoutConnection that conn is, or that
+// it wraps (with an Unwrap() net.Conn method, as with --capture-wire), or nil
+// if there is none. Modules should use it rather than asserting the type of
+// the connections returned by ScanTarget.Open.
+func GetTimeoutConnection(conn net.Conn) *TimeoutConnection {
+	for conn != nil {
+		switch c := conn.(type) {
+		case *TimeoutConnection:
+			return c
+		case interface{ Unwrap() net.Conn }:
+			conn = c.Unwrap()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// ErrCloseWriteNotSupported is returned by CloseWrite if the underlying
+// connection cannot be half-closed.
+var ErrCloseWriteNotSupported = errors.New("connection cannot be half-closed")
+
+// closeWrite shuts down the writing side of conn, if it can be half-closed.
+func closeWrite(conn net.Conn) error {
+	if halfCloser, ok := conn.(interface{ CloseWrite() error }); ok {
+		return halfCloser.CloseWrite()
+	}
+	return ErrCloseWriteNotSupported
+}
+
+// CloseWrite shuts down the writing side of the underlying connection (e.g.
+// sends a TCP FIN), if it can be half-closed.
+func (c *TimeoutConnection) CloseWrite() error {
+	return closeWrite(c.Conn)
 }
 
 // TimeoutConnection.Read calls Read() on the underlying connection, using any configured deadlines
@@ -81,6 +213,19 @@ func (c *TimeoutConnection) Read(b []byte) (n int, err error) {
 	}
 	n, err = c.Conn.Read(b)
 	c.BytesRead += n
+	if n > 0 && atomic.LoadInt32(&c.detectTLS) != 0 && atomic.CompareAndSwapInt32(&c.firstReadChecked, 0, 1) {
+		// A plaintext module that gets a TLS record back (e.g. an alert in
+		// reply to its request) is talking to a TLS port.
+		if atomic.LoadInt32(&c.sentTLS) == 0 && looksLikeTLSRecord(b[:n]) {
+			return 0, ErrServerSpeaksTLS
+		}
+	}
+	if err != nil {
+		// If the context is done, the connection was closed under us.
+		if ctxErr := c.checkContext(); ctxErr != nil {
+			return n, ctxErr
+		}
+	}
 	if err == nil && origSize != len(b) && n == len(b) {
 		// we had to shrink the output buffer AND we used up the whole shrunk size, AND we're not at EOF
 		switch c.ReadLimitExceededAction {
@@ -111,8 +256,16 @@ func (c *TimeoutConnection) Write(b []byte) (n int, err error) {
 			return 0, err
 		}
 	}
+	if c.BytesWritten == 0 && atomic.LoadInt32(&c.detectTLS) != 0 && looksLikeTLSRecord(b) {
+		atomic.StoreInt32(&c.sentTLS, 1)
+	}
 	n, err = c.Conn.Write(b)
 	c.BytesWritten += n
+	if err != nil {
+		if ctxErr := c.checkContext(); ctxErr != nil {
+			return n, ctxErr
+		}
+	}
 	return n, err
 }
 
@@ -171,9 +324,57 @@ func GetTimeoutDialFunc(timeout time.Duration) func(string, string) (net.Conn, e
 	}
 }
 
-// Close the underlying connection.
+// Close the underlying connection, as set by its CloseMode.
 func (c *TimeoutConnection) Close() error {
-	return c.Conn.Close()
+	if c.CloseMode == CloseModeGraceful && atomic.CompareAndSwapInt32(&c.drained, 0, 1) {
+		c.drain()
+	}
+	if c.Cancel != nil {
+		c.Cancel()
+	}
+	return c.closeConn()
+}
+
+// closeConn closes the underlying connection once, whether on Close or
+// because the context is done.
+func (c *TimeoutConnection) closeConn() error {
+	c.closeOnce.Do(func() {
+		if c.CloseMode == CloseModeRST {
+			if tcpConn, ok := c.Conn.(*net.TCPConn); ok {
+				tcpConn.SetLinger(0)
+			}
+		}
+		c.closeErr = c.Conn.Close()
+	})
+	return c.closeErr
+}
+
+// drain sends a FIN, then reads until the server closes its side (or
+// GracefulCloseTimeout passes), so that the underlying connection can be
+// closed without a reset. If the context is done meanwhile, the connection is
+// closed at once, ending the drain. Connections that cannot be half-closed
+// are left to be closed as usual.
+func (c *TimeoutConnection) drain() {
+	halfCloser, ok := c.Conn.(interface{ CloseWrite() error })
+	if !ok || c.checkContext() != nil {
+		return
+	}
+	if err := halfCloser.CloseWrite(); err != nil {
+		return
+	}
+	if c.ctx != nil {
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-c.ctx.Done():
+				c.closeConn()
+			case <-done:
+			}
+		}()
+	}
+	c.Conn.SetReadDeadline(time.Now().Add(GracefulCloseTimeout))
+	io.Copy(ioutil.Discard, io.LimitReader(c.Conn, gracefulCloseDrainLimit))
 }
 
 // Get the timeout for the given field, falling back to the global timeout.
@@ -213,6 +414,9 @@ func (c *TimeoutConnection) SetDefaults() *TimeoutConnection {
 	if c.Timeout == 0 {
 		c.Timeout = DefaultSessionTimeout
 	}
+	if c.CloseMode == CloseModeNotSet {
+		c.CloseMode = DefaultCloseMode
+	}
 	return c
 }
 
@@ -229,30 +433,80 @@ func NewTimeoutConnection(ctx context.Context, conn net.Conn, timeout, readTimeo
 		ctx = context.Background()
 	}
 	ret.ctx, ret.Cancel = context.WithTimeout(ctx, timeout)
+	// If ctx is cancelled, close the connection so that blocked reads and
+	// writes return at once. (The session timeout alone does not interrupt
+	// them.)
+	if ctx.Done() != nil {
+		go func() {
+			<-ret.ctx.Done()
+			if ctx.Err() != nil {
+				ret.closeConn()
+			}
+		}()
+	}
 	return ret
 }
 
 // DialTimeoutConnectionEx dials the target and returns a net.Conn that uses the configured timeouts for Read/Write operations.
 func DialTimeoutConnectionEx(proto string, target string, dialTimeout, sessionTimeout, readTimeout, writeTimeout time.Duration, bytesReadLimit int) (net.Conn, error) {
-	var conn net.Conn
-	var err error
+	return dialTimeoutConnection(context.Background(), proto, target, dialTimeout, sessionTimeout, readTimeout, writeTimeout, bytesReadLimit)
+}
+
+// DialTimeoutConnection dials the target and returns a net.Conn that uses the configured single timeout for all operations.
+func DialTimeoutConnection(proto string, target string, timeout time.Duration, bytesReadLimit int) (net.Conn, error) {
+	return DialTimeoutConnectionEx(proto, target, timeout, timeout, timeout, timeout, bytesReadLimit)
+}
+
+// DialTimeoutConnectionContext is DialTimeoutConnection, except that the dial
+// and all operations on the connection also end when ctx is done.
+func DialTimeoutConnectionContext(ctx context.Context, proto string, target string, timeout time.Duration, bytesReadLimit int) (net.Conn, error) {
+	return dialTimeoutConnection(ctx, proto, target, timeout, timeout, timeout, timeout, bytesReadLimit)
+}
+
+func dialTimeoutConnection(ctx context.Context, proto string, target string, dialTimeout, sessionTimeout, readTimeout, writeTimeout time.Duration, bytesReadLimit int) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: sessionTimeout, Control: config.dialControl}
 	if dialTimeout > 0 {
-		conn, err = net.DialTimeout(proto, target, dialTimeout)
-	} else {
-		conn, err = net.DialTimeout(proto, target, sessionTimeout)
+		dialer.Timeout = dialTimeout
 	}
+	// Use the --source-ip, if set; it only applies to TCP.
+	if config.localAddr != nil && strings.HasPrefix(proto, "tcp") {
+		dialer.LocalAddr = config.localAddr
+	}
+	conn, err := dialer.DialContext(ctx, proto, target)
 	if err != nil {
 		if conn != nil {
 			conn.Close()
 		}
 		return nil, err
 	}
-	return NewTimeoutConnection(context.Background(), conn, sessionTimeout, readTimeout, writeTimeout, bytesReadLimit), nil
+	if err := setTCPOptions(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return NewTimeoutConnection(ctx, conn, sessionTimeout, readTimeout, writeTimeout, bytesReadLimit), nil
 }
 
-// DialTimeoutConnection dials the target and returns a net.Conn that uses the configured single timeout for all operations.
-func DialTimeoutConnection(proto string, target string, timeout time.Duration, bytesReadLimit int) (net.Conn, error) {
-	return DialTimeoutConnectionEx(proto, target, timeout, timeout, timeout, timeout, bytesReadLimit)
+// setTCPOptions applies --tcp-keepalive and --tcp-nagle to conn, if it is a
+// TCP connection; otherwise, and by default, conn is left as dialed.
+func setTCPOptions(conn net.Conn) error {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	if config.TCPKeepAlive > 0 {
+		if err := tcpConn.SetKeepAlive(true); err != nil {
+			return err
+		}
+		if err := tcpConn.SetKeepAlivePeriod(config.TCPKeepAlive); err != nil {
+			return err
+		}
+	}
+	if config.TCPNagle {
+		if err := tcpConn.SetNoDelay(false); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Dialer provides Dial and DialContext methods to get connections with the given timeout.
@@ -292,8 +546,13 @@ func (d *Dialer) getTimeout(field time.Duration) time.Duration {
 
 // DialContext wraps the connection returned by net.Dialer.DialContext() with a TimeoutConnection.
 func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	// The session timeout bounds the dial too; NewTimeoutConnection applies
+	// it to the connection itself.
+	sessionContext := ctx
 	if d.Timeout != 0 {
-		ctx, _ = context.WithTimeout(ctx, d.Timeout)
+		var cancelSession context.CancelFunc
+		sessionContext, cancelSession = context.WithTimeout(ctx, d.Timeout)
+		defer cancelSession()
 	}
 	// ensure that our aux dialer is up-to-date; copied from http/transport.go
 	d.Dialer.Timeout = d.getTimeout(d.ConnectTimeout)
@@ -301,13 +560,21 @@ func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.
 
 	// Copy over the source IP if set, or nil
 	d.Dialer.LocalAddr = config.localAddr
+	// Bind to the --interface, if set
+	if config.dialControl != nil {
+		d.Dialer.Control = config.dialControl
+	}
 
-	dialContext, cancelDial := context.WithTimeout(ctx, d.Dialer.Timeout)
+	dialContext, cancelDial := context.WithTimeout(sessionContext, d.Dialer.Timeout)
 	defer cancelDial()
 	conn, err := d.Dialer.DialContext(dialContext, network, address)
 	if err != nil {
 		return nil, err
 	}
+	if err := setTCPOptions(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
 	ret := NewTimeoutConnection(ctx, conn, d.Timeout, d.ReadTimeout, d.WriteTimeout, d.BytesReadLimit)
 	ret.BytesReadLimit = d.BytesReadLimit
 	ret.ReadLimitExceededAction = d.ReadLimitExceededAction
@@ -0,0 +1,90 @@
+package zgrab2
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCloseModes(t *testing.T) {
+	tests := []struct {
+		mode  CloseMode
+		reset bool
+	}{
+		// Closing with data left unread resets the connection...
+		{CloseModeNormal, true},
+		// ...unless the client drains it first.
+		{CloseModeGraceful, false},
+		{CloseModeRST, true},
+	}
+	for _, test := range tests {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The server sends data the client never reads, then reads until
+		// the client closes the connection.
+		serverErr := make(chan error, 1)
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				serverErr <- err
+				return
+			}
+			defer conn.Close()
+			conn.Write(bytes.Repeat([]byte("x"), 1024))
+			conn.SetReadDeadline(time.Now().Add(medium))
+			_, err = ioutil.ReadAll(conn)
+			serverErr <- err
+		}()
+		conn, err := DialTimeoutConnection("tcp", listener.Addr().String(), medium, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.(*TimeoutConnection).CloseMode = test.mode
+		// Give the data time to arrive.
+		time.Sleep(short)
+		if err := conn.Close(); err != nil {
+			t.Errorf("%s: close failed: %v", test.mode, err)
+		}
+		err = <-serverErr
+		listener.Close()
+		if reset := err != nil && strings.Contains(err.Error(), "reset"); reset != test.reset {
+			t.Errorf("%s: expected reset=%v, server got %v", test.mode, test.reset, err)
+		}
+	}
+}
+
+func TestGracefulCloseCancelled(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	// The server never closes its side, so the drain only ends with
+	// GracefulCloseTimeout, or when the context is cancelled.
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		time.Sleep(GracefulCloseTimeout + time.Second)
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := DialTimeoutConnectionContext(ctx, "tcp", listener.Addr().String(), GracefulCloseTimeout*2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.(*TimeoutConnection).CloseMode = CloseModeGraceful
+	time.AfterFunc(short, cancel)
+	start := time.Now()
+	conn.Close()
+	if elapsed := time.Since(start); elapsed >= GracefulCloseTimeout {
+		t.Errorf("expected the cancellation to end the drain, took %s", elapsed)
+	}
+}
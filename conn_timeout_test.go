@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"strings"
 	"testing"
@@ -420,3 +421,38 @@ func TestTimeoutConnectionTimeouts(t *testing.T) {
 		cfg.run(t)
 	}
 }
+
+// TestTimeoutConnectionCancel checks that cancelling the context unblocks a
+// read that would otherwise wait for the (long) timeout.
+func TestTimeoutConnectionCancel(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Never write, so the client's read blocks.
+		io.Copy(ioutil.Discard, conn)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := DialTimeoutConnectionContext(ctx, "tcp", listener.Addr().String(), time.Minute, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = conn.Read(make([]byte, 1))
+	if err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("read took %v after cancellation", elapsed)
+	}
+}
@@ -0,0 +1,104 @@
+package zgrab2
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// tlsAlert is a TLS 1.2 fatal protocol_version alert record.
+var tlsAlert = []byte{0x15, 0x03, 0x03, 0x00, 0x02, 0x02, 0x46}
+
+// runReplyServer starts a local server that answers the first read on each
+// connection with reply, and returns its address.
+func runReplyServer(t *testing.T, reply []byte) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		defer listener.Close()
+		for {
+			sock, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(sock net.Conn) {
+				defer sock.Close()
+				buf := make([]byte, 1024)
+				if _, err := sock.Read(buf); err != nil {
+					return
+				}
+				sock.Write(reply)
+				time.Sleep(100 * time.Millisecond)
+			}(sock)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func sendAndRead(t *testing.T, address string, request []byte) ([]byte, error) {
+	conn, err := DialTimeoutConnectionContext(context.Background(), "tcp", address, time.Second, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	EnableTLSDetection(conn)
+	if _, err := conn.Write(request); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	return buf[:n], err
+}
+
+func TestServerSpeaksTLS(t *testing.T) {
+	address := runReplyServer(t, tlsAlert)
+	data, err := sendAndRead(t, address, []byte("GET / HTTP/1.0\r\n\r\n"))
+	if err != ErrServerSpeaksTLS || len(data) != 0 {
+		t.Errorf("expected ErrServerSpeaksTLS and no data, got %v, %x", err, data)
+	}
+	if status := TryGetScanStatus(err); status != SCAN_PROTOCOL_ERROR {
+		t.Errorf("expected status %s, got %s", SCAN_PROTOCOL_ERROR, status)
+	}
+}
+
+func TestServerSpeaksTLSAfterClientHello(t *testing.T) {
+	// A connection that sent a TLS record is carrying TLS, so the reply is
+	// passed through.
+	address := runReplyServer(t, tlsAlert)
+	clientHello := []byte{0x16, 0x03, 0x01, 0x00, 0x04, 0x01, 0x00, 0x00, 0x00}
+	data, err := sendAndRead(t, address, clientHello)
+	if err != nil || !bytes.Equal(data, tlsAlert) {
+		t.Errorf("expected the alert, got %v, %x", err, data)
+	}
+}
+
+func TestServerSpeaksTLSNotDetected(t *testing.T) {
+	// Without EnableTLSDetection, the reply is passed through.
+	address := runReplyServer(t, tlsAlert)
+	conn, err := DialTimeoutConnectionContext(context.Background(), "tcp", address, time.Second, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("GET / HTTP/1.0\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil || !bytes.Equal(buf[:n], tlsAlert) {
+		t.Errorf("expected the alert, got %v, %x", err, buf[:n])
+	}
+}
+
+func TestServerSpeaksPlaintext(t *testing.T) {
+	banner := []byte("220 mail.example.com ESMTP\r\n")
+	address := runReplyServer(t, banner)
+	data, err := sendAndRead(t, address, []byte("EHLO example.com\r\n"))
+	if err != nil || !bytes.Equal(data, banner) {
+		t.Errorf("expected the banner, got %v, %q", err, data)
+	}
+}
@@ -0,0 +1,86 @@
+package zgrab2
+
+import (
+	"hash/fnv"
+	"strconv"
+)
+
+// targetSet records the targets seen so far in a run, for --dedup.
+type targetSet interface {
+	// add adds key to the set, and returns false if it was already there.
+	add(key string) bool
+}
+
+// exactTargetSet is a targetSet that never mistakes a new target for a
+// duplicate, at the cost of memory proportional to the number of targets.
+type exactTargetSet map[string]struct{}
+
+func (s exactTargetSet) add(key string) bool {
+	if _, ok := s[key]; ok {
+		return false
+	}
+	s[key] = struct{}{}
+	return true
+}
+
+// bloomHashes is the number of bits set per key in a bloomTargetSet; with
+// ten or more bits per target, fewer than 1% of new targets are mistaken for
+// duplicates.
+const bloomHashes = 7
+
+// bloomTargetSet is a targetSet of fixed size: a Bloom filter, which
+// occasionally mistakes a new target for a duplicate, but never the reverse.
+type bloomTargetSet struct {
+	bits []uint64
+}
+
+// newBloomTargetSet returns a Bloom filter of the given size in megabytes.
+func newBloomTargetSet(megabytes uint) *bloomTargetSet {
+	return &bloomTargetSet{bits: make([]uint64, megabytes<<20/8)}
+}
+
+func (s *bloomTargetSet) add(key string) bool {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h1 := h.Sum64()
+	// Derive the other hashes from h1 and h2 (Kirsch-Mitzenmacher).
+	h.Write([]byte{0})
+	h2 := h.Sum64() | 1
+	size := uint64(len(s.bits)) * 64
+	added := false
+	for i := uint64(0); i < bloomHashes; i++ {
+		bit := (h1 + i*h2) % size
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if s.bits[word]&mask == 0 {
+			s.bits[word] |= mask
+			added = true
+		}
+	}
+	return added
+}
+
+// dedupKey identifies the scans that would be made of target: its address,
+// its port override, if any, and its trigger tag, which selects the modules.
+func dedupKey(target *ScanTarget) string {
+	key := target.Host()
+	if target.IP != nil && target.Domain != "" {
+		key += "/" + target.Domain
+	}
+	key += "|"
+	if target.Port != nil {
+		key += strconv.FormatUint(uint64(*target.Port), 10)
+	}
+	return key + "|" + target.Tag
+}
+
+// newTargetSet returns the targetSet for the --dedup flags, or nil if
+// deduplication is off.
+func newTargetSet() targetSet {
+	if !config.Dedup {
+		return nil
+	}
+	if config.DedupBloomSize > 0 {
+		return newBloomTargetSet(config.DedupBloomSize)
+	}
+	return make(exactTargetSet)
+}
@@ -0,0 +1,72 @@
+package zgrab2
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+)
+
+func TestTargetSets(t *testing.T) {
+	for name, set := range map[string]targetSet{
+		"exact": make(exactTargetSet),
+		"bloom": newBloomTargetSet(1),
+	} {
+		for i := 0; i < 1000; i++ {
+			if !set.add(fmt.Sprintf("192.0.2.%d||", i)) {
+				t.Errorf("%s: new key %d reported as a duplicate", name, i)
+			}
+		}
+		for i := 0; i < 1000; i++ {
+			if set.add(fmt.Sprintf("192.0.2.%d||", i)) {
+				t.Errorf("%s: duplicate key %d reported as new", name, i)
+			}
+		}
+	}
+}
+
+func TestDedupKey(t *testing.T) {
+	port := uint(8080)
+	ip := net.ParseIP("192.0.2.1")
+	keys := []string{
+		dedupKey(&ScanTarget{IP: ip}),
+		dedupKey(&ScanTarget{IP: ip, Domain: "example.com"}),
+		dedupKey(&ScanTarget{IP: ip, Port: &port}),
+		dedupKey(&ScanTarget{IP: ip, Tag: "web"}),
+		dedupKey(&ScanTarget{Domain: "example.com"}),
+	}
+	for i := range keys {
+		for j := i + 1; j < len(keys); j++ {
+			if keys[i] == keys[j] {
+				t.Errorf("targets %d and %d have the same key %q", i, j, keys[i])
+			}
+		}
+	}
+}
+
+func TestFeedTargetsDedup(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config.Dedup = true
+	config.inputTargets = func(ch chan<- ScanTarget) error {
+		for i := 0; i < 30; i++ {
+			ch <- ScanTarget{IP: net.IPv4(192, 0, 2, byte(i%10))}
+		}
+		return nil
+	}
+	var wg sync.WaitGroup
+	mon := MakeMonitor(1, &wg)
+	defer wg.Wait()
+	defer mon.Stop()
+	processQueue := make(chan ScanTarget, 30)
+	feedTargets(context.Background(), processQueue, mon)
+	close(processQueue)
+	fed := 0
+	for range processQueue {
+		fed++
+	}
+	if counts := mon.GetTargetCounts(); fed != 10 || counts.Scanned != 10 || counts.Duplicates != 20 {
+		t.Errorf("fed %d, counts %+v", fed, counts)
+	}
+}
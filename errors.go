@@ -12,5 +12,9 @@ var ErrInvalidArguments = errors.New("invalid arguments")
 // ErrInvalidResponse is returned when the server returns a syntactically-invalid response.
 var ErrInvalidResponse = errors.New("invalid response")
 
+// ErrEmptyResponse is returned by ReadUntilClose when the server closes the
+// connection without sending a response.
+var ErrEmptyResponse = errors.New("empty response")
+
 // ErrUnexpectedResponse is returned when the server returns a syntactically-valid but unexpected response.
 var ErrUnexpectedResponse = errors.New("unexpected response")
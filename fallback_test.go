@@ -0,0 +1,64 @@
+package zgrab2
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// closedPort returns a local port on which connections are refused.
+func closedPort(t *testing.T) uint {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	listener.Close()
+	return port
+}
+
+func TestRunScannerFallbackPorts(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	open := uint(listener.Addr().(*net.TCPAddr).Port)
+	refused := closedPort(t)
+
+	var wg sync.WaitGroup
+	mon := MakeMonitor(1, &wg)
+	defer wg.Wait()
+	defer mon.Stop()
+	defer func(ports []uint) { config.fallbackPorts = ports }(config.fallbackPorts)
+
+	tests := []struct {
+		name          string
+		fallbackPorts []uint
+		status        ScanStatus
+		port          uint
+	}{
+		{"no fallback", nil, SCAN_CONNECTION_REFUSED, 0},
+		{"fallback", []uint{closedPort(t), open}, SCAN_SUCCESS, open},
+		{"all refused", []uint{closedPort(t)}, SCAN_CONNECTION_REFUSED, 0},
+	}
+	for _, test := range tests {
+		config.fallbackPorts = test.fallbackPorts
+		scanner := &timingTestScanner{flags: &BaseFlags{Port: refused, Timeout: 5 * time.Second}}
+		_, resp := RunScanner(context.Background(), scanner, mon, ScanTarget{IP: net.ParseIP("127.0.0.1")})
+		if resp.Status != test.status || resp.Port != test.port {
+			t.Errorf("%s: expected status %s on port %d, got %s on port %d (%v)", test.name, test.status, test.port, resp.Status, resp.Port, resp.Error)
+		}
+	}
+}
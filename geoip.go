@@ -0,0 +1,132 @@
+package zgrab2
+
+import (
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoIP holds the location and network of a target's IP address, looked up
+// in the --geoip databases.
+type GeoIP struct {
+	// Country is the ISO 3166-1 code of the country, e.g. "US".
+	Country string `json:"country,omitempty"`
+
+	// CountryName is the English name of the country.
+	CountryName string `json:"country_name,omitempty"`
+
+	// City is the English name of the city.
+	City string `json:"city,omitempty"`
+
+	// ASN is the number of the autonomous system announcing the address.
+	ASN uint `json:"asn,omitempty"`
+
+	// Organization is the name of the organization owning the autonomous
+	// system.
+	Organization string `json:"organization,omitempty"`
+}
+
+// geoIPRecord is the part of a MaxMind City, Country or ASN database record
+// that is recorded in GeoIP.
+type geoIPRecord struct {
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	ASN          uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// geoIPDatabase looks up records in a MaxMind database; it is implemented
+// by *maxminddb.Reader, which is safe for concurrent use.
+type geoIPDatabase interface {
+	Lookup(ip net.IP, result interface{}) error
+}
+
+// reservedNetworks are the private and reserved networks (including loopback
+// and link-local), which have no location; see RFC 6890.
+var reservedNetworks = parseNetworks(
+	"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8",
+	"169.254.0.0/16", "172.16.0.0/12", "192.0.0.0/24", "192.0.2.0/24",
+	"192.168.0.0/16", "198.18.0.0/15", "198.51.100.0/24", "203.0.113.0/24",
+	"224.0.0.0/4", "240.0.0.0/4",
+	"::/128", "::1/128", "100::/64", "2001:db8::/32", "fc00::/7",
+	"fe80::/10", "ff00::/8",
+)
+
+// parseNetworks parses CIDR blocks, panicking if one is invalid.
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	ret := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		ret[i] = network
+	}
+	return ret
+}
+
+// IsReservedIP returns true if ip is in one of the private or reserved
+// networks, e.g. loopback, RFC 1918 or link-local addresses, which are not
+// reachable on the public Internet.
+func IsReservedIP(ip net.IP) bool {
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenGeoIP opens the MaxMind databases at paths (e.g. a City and an ASN
+// database), and returns a ResultProcessor setting the GeoIP of each Grab
+// from them. The databases are opened once, and shared by all workers.
+func OpenGeoIP(paths []string) (ResultProcessor, error) {
+	databases := make([]geoIPDatabase, len(paths))
+	for i, path := range paths {
+		reader, err := maxminddb.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		databases[i] = reader
+	}
+	return newGeoIPProcessor(databases), nil
+}
+
+// newGeoIPProcessor returns a ResultProcessor setting the GeoIP of each Grab
+// from databases. Targets without an IP address, or with a private or
+// reserved one, are left without a GeoIP.
+func newGeoIPProcessor(databases []geoIPDatabase) ResultProcessor {
+	return func(grab *Grab) error {
+		ip := net.ParseIP(grab.IP)
+		if ip == nil || IsReservedIP(ip) {
+			return nil
+		}
+		geoIP := new(GeoIP)
+		for _, database := range databases {
+			var record geoIPRecord
+			if err := database.Lookup(ip, &record); err != nil {
+				return err
+			}
+			if record.Country.ISOCode != "" {
+				geoIP.Country = record.Country.ISOCode
+				geoIP.CountryName = record.Country.Names["en"]
+			}
+			if name := record.City.Names["en"]; name != "" {
+				geoIP.City = name
+			}
+			if record.ASN != 0 {
+				geoIP.ASN = record.ASN
+				geoIP.Organization = record.Organization
+			}
+		}
+		if *geoIP != (GeoIP{}) {
+			grab.GeoIP = geoIP
+		}
+		return nil
+	}
+}
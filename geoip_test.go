@@ -0,0 +1,62 @@
+package zgrab2
+
+import (
+	"net"
+	"testing"
+)
+
+// fakeGeoIPDatabase fills in the record for every address with the given
+// country, city and ASN, counting the lookups.
+type fakeGeoIPDatabase struct {
+	country, city string
+	asn           uint
+	organization  string
+	lookups       int
+}
+
+func (database *fakeGeoIPDatabase) Lookup(ip net.IP, result interface{}) error {
+	database.lookups++
+	record := result.(*geoIPRecord)
+	record.Country.ISOCode = database.country
+	if database.country != "" {
+		record.Country.Names = map[string]string{"en": "Country " + database.country}
+	}
+	if database.city != "" {
+		record.City.Names = map[string]string{"en": database.city}
+	}
+	record.ASN = database.asn
+	record.Organization = database.organization
+	return nil
+}
+
+func TestGeoIPProcessor(t *testing.T) {
+	city := &fakeGeoIPDatabase{country: "US", city: "Mountain View"}
+	asn := &fakeGeoIPDatabase{asn: 15169, organization: "GOOGLE"}
+	processor := newGeoIPProcessor([]geoIPDatabase{city, asn})
+
+	grab := &Grab{IP: "8.8.8.8"}
+	if err := processor(grab); err != nil {
+		t.Fatal(err)
+	}
+	expected := GeoIP{Country: "US", CountryName: "Country US", City: "Mountain View", ASN: 15169, Organization: "GOOGLE"}
+	if grab.GeoIP == nil || *grab.GeoIP != expected {
+		t.Errorf("expected %+v, got %+v", expected, grab.GeoIP)
+	}
+
+	for _, ip := range []string{"", "10.1.2.3", "192.168.0.1", "127.0.0.1", "100.64.0.1", "::1", "fd00::1", "fe80::1"} {
+		grab := &Grab{IP: ip}
+		if err := processor(grab); err != nil || grab.GeoIP != nil {
+			t.Errorf("expected no GeoIP for %q, got %+v: %v", ip, grab.GeoIP, err)
+		}
+	}
+	if city.lookups != 1 || asn.lookups != 1 {
+		t.Errorf("expected private and reserved addresses not to be looked up, got %d and %d lookups", city.lookups, asn.lookups)
+	}
+
+	// Addresses missing from the databases are left without a GeoIP.
+	empty := newGeoIPProcessor([]geoIPDatabase{&fakeGeoIPDatabase{}})
+	grab = &Grab{IP: "2001:4860:4860::8888"}
+	if err := empty(grab); err != nil || grab.GeoIP != nil {
+		t.Errorf("expected no GeoIP, got %+v: %v", grab.GeoIP, err)
+	}
+}
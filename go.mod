@@ -5,8 +5,10 @@ go 1.12
 require (
 	github.com/RumbleDiscovery/jarm-go v0.0.6 // indirect
 	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/oschwald/maxminddb-golang v1.8.0
 	github.com/prometheus/client_golang v1.10.0
 	github.com/prometheus/common v0.20.0 // indirect
+	github.com/segmentio/kafka-go v0.4.16
 	github.com/sirupsen/logrus v1.8.1
 	github.com/zmap/rc2 v0.0.0-20190804163417-abaa70531248 // indirect
 	github.com/zmap/zcrypto v0.0.0-20210331113710-23c79953ef34
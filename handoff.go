@@ -0,0 +1,78 @@
+package zgrab2
+
+import (
+	"net"
+	"sync"
+)
+
+// handoffPool holds the connections handed off between the scanners run
+// against a single target, keyed by port.
+//
+// Ownership rules:
+//   - A scanner owns the connections it opens, and must close them before
+//     Scan returns, unless it passes one to ScanTarget.HandOff. After that
+//     call, the scanner must neither use nor close the connection.
+//   - While in the pool, a connection is owned by the framework. At most one
+//     connection is held per port; handing off a second one closes the first.
+//   - A later scanner for the same target that calls
+//     ScanTarget.TakeConnection owns the returned connection, exactly as if
+//     it had opened it itself (and may in turn hand it off again).
+//   - Once every scanner has run against the target, the framework closes
+//     any connections that were handed off but never taken.
+//
+// A handed-off connection is passed on as-is: it keeps its deadlines and
+// read limits, any TLS (or other) layer the earlier scanner set up, and
+// any unread data. Scanners that take connections must therefore agree
+// with the scanner that handed them off on the state they are in.
+type handoffPool struct {
+	mutex sync.Mutex
+	conns map[uint]net.Conn
+}
+
+// newHandoffPool returns an empty handoffPool.
+func newHandoffPool() *handoffPool {
+	return &handoffPool{conns: make(map[uint]net.Conn)}
+}
+
+// closeAll closes every connection in the pool.
+func (pool *handoffPool) closeAll() {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	for port, conn := range pool.conns {
+		conn.Close()
+		delete(pool.conns, port)
+	}
+}
+
+// HandOff passes ownership of conn, an open connection to the target on
+// the given port, to the framework, so that a later scanner run against the
+// same target can continue using it via TakeConnection -- e.g. a STARTTLS
+// probe handing its upgraded connection to an authenticated probe. See
+// handoffPool for the ownership rules. If the target is not being scanned
+// by the framework, there is no later scanner, and conn is closed.
+func (target *ScanTarget) HandOff(port uint, conn net.Conn) {
+	if target.handoff == nil {
+		conn.Close()
+		return
+	}
+	target.handoff.mutex.Lock()
+	defer target.handoff.mutex.Unlock()
+	if old, ok := target.handoff.conns[port]; ok && old != conn {
+		old.Close()
+	}
+	target.handoff.conns[port] = conn
+}
+
+// TakeConnection returns the connection to the given port that an earlier
+// scanner handed off, transferring its ownership to the caller, or nil if
+// there is none.
+func (target *ScanTarget) TakeConnection(port uint) net.Conn {
+	if target.handoff == nil {
+		return nil
+	}
+	target.handoff.mutex.Lock()
+	defer target.handoff.mutex.Unlock()
+	conn := target.handoff.conns[port]
+	delete(target.handoff.conns, port)
+	return conn
+}
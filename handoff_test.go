@@ -0,0 +1,60 @@
+package zgrab2
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// isClosed returns true if conn has been closed locally.
+func isClosed(conn net.Conn) bool {
+	return conn.SetDeadline(time.Time{}) == io.ErrClosedPipe
+}
+
+func TestHandOff(t *testing.T) {
+	target := ScanTarget{IP: net.ParseIP("192.0.2.1"), handoff: newHandoffPool()}
+	first, _ := net.Pipe()
+	second, _ := net.Pipe()
+	third, _ := net.Pipe()
+
+	// Scan receives the target by value; the pool is shared between copies.
+	func(target ScanTarget) {
+		target.HandOff(25, first)
+		target.HandOff(25, second)
+		target.HandOff(587, third)
+	}(target)
+	if !isClosed(first) {
+		t.Error("replaced connection was not closed")
+	}
+	if conn := target.TakeConnection(25); conn != second {
+		t.Errorf("expected the second connection, got %v", conn)
+	}
+	if conn := target.TakeConnection(25); conn != nil {
+		t.Errorf("connection was taken twice")
+	}
+	if target.TakeConnection(110) != nil {
+		t.Errorf("got a connection for a port that was not handed off")
+	}
+
+	target.handoff.closeAll()
+	if !isClosed(third) {
+		t.Error("untaken connection was not closed")
+	}
+	if isClosed(second) {
+		t.Error("taken connection was closed")
+	}
+	second.Close()
+}
+
+func TestHandOffWithoutPool(t *testing.T) {
+	target := ScanTarget{IP: net.ParseIP("192.0.2.1")}
+	conn, _ := net.Pipe()
+	target.HandOff(25, conn)
+	if !isClosed(conn) {
+		t.Error("connection handed off outside the framework was not closed")
+	}
+	if target.TakeConnection(25) != nil {
+		t.Error("got a connection without a pool")
+	}
+}
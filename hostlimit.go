@@ -0,0 +1,63 @@
+package zgrab2
+
+import "sync"
+
+// hostLimiter is a keyed semaphore that caps the number of targets with the
+// same destination being scanned at once, for --max-per-host.
+type hostLimiter struct {
+	limit int
+
+	mu    sync.Mutex
+	hosts map[string]*hostSlots
+}
+
+// hostSlots tracks the scans of one destination. It is dropped once no
+// scans of the destination are running or waiting.
+type hostSlots struct {
+	active  int
+	waiting int
+	free    *sync.Cond
+}
+
+// newHostLimiter returns the hostLimiter for --max-per-host, or nil if the
+// number of scans per host is unlimited.
+func newHostLimiter() *hostLimiter {
+	if config.MaxPerHost == 0 {
+		return nil
+	}
+	return &hostLimiter{limit: int(config.MaxPerHost), hosts: make(map[string]*hostSlots)}
+}
+
+// acquire blocks until fewer than limit scans of host are running, and
+// starts one. It returns true if it had to wait.
+func (limiter *hostLimiter) acquire(host string) bool {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	slots := limiter.hosts[host]
+	if slots == nil {
+		slots = &hostSlots{free: sync.NewCond(&limiter.mu)}
+		limiter.hosts[host] = slots
+	}
+	throttled := false
+	for slots.active >= limiter.limit {
+		throttled = true
+		slots.waiting++
+		slots.free.Wait()
+		slots.waiting--
+	}
+	slots.active++
+	return throttled
+}
+
+// release ends a scan of host started by acquire.
+func (limiter *hostLimiter) release(host string) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	slots := limiter.hosts[host]
+	slots.active--
+	if slots.waiting > 0 {
+		slots.free.Signal()
+	} else if slots.active == 0 {
+		delete(limiter.hosts, host)
+	}
+}
@@ -0,0 +1,78 @@
+package zgrab2
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHostLimiter(t *testing.T) {
+	limiter := &hostLimiter{limit: 2, hosts: make(map[string]*hostSlots)}
+	var running, maxRunning, throttled int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if limiter.acquire("192.0.2.1") {
+				atomic.AddInt32(&throttled, 1)
+			}
+			n := atomic.AddInt32(&running, 1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			limiter.release("192.0.2.1")
+		}()
+	}
+	wg.Wait()
+	if maxRunning != 2 {
+		t.Errorf("expected at most 2 scans of the host at once, got %d", maxRunning)
+	}
+	if throttled == 0 {
+		t.Error("expected some scans to be throttled")
+	}
+	if len(limiter.hosts) != 0 {
+		t.Errorf("expected no hosts to be tracked once the scans are done, got %d", len(limiter.hosts))
+	}
+}
+
+func TestHostLimiterOtherHosts(t *testing.T) {
+	limiter := &hostLimiter{limit: 1, hosts: make(map[string]*hostSlots)}
+	if limiter.acquire("192.0.2.1") {
+		t.Error("first scan of a host throttled")
+	}
+	// A scan of a different host starts at once.
+	done := make(chan bool)
+	go func() {
+		done <- limiter.acquire("192.0.2.2")
+	}()
+	select {
+	case throttled := <-done:
+		if throttled {
+			t.Error("scan of a different host throttled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("scan of a different host blocked")
+	}
+	limiter.release("192.0.2.2")
+
+	go func() {
+		done <- limiter.acquire("192.0.2.1")
+	}()
+	select {
+	case <-done:
+		t.Fatal("second scan of a host started while the first was running")
+	case <-time.After(10 * time.Millisecond):
+	}
+	limiter.release("192.0.2.1")
+	if throttled := <-done; !throttled {
+		t.Error("expected the second scan of the host to be throttled")
+	}
+	limiter.release("192.0.2.1")
+}
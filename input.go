@@ -1,7 +1,10 @@
 package zgrab2
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
@@ -80,22 +83,59 @@ func duplicateIP(ip net.IP) net.IP {
 	return dup
 }
 
+// SkipInputRecords returns a reader of source that starts after its first n
+// records: its first n lines, not counting empty lines or comment lines
+// (beginning with #). Each record of the CSV and JSON input formats is a
+// single line, so --skip and --max-targets can split an input with one
+// target per line into shards.
+func SkipInputRecords(source io.Reader, n uint) (io.Reader, error) {
+	reader := bufio.NewReader(source)
+	for n > 0 {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 && trimmed[0] != '#' {
+				n--
+			}
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return reader, nil
+}
+
 // InputTargetsCSV is an InputTargetsFunc that calls GetTargetsCSV with
-// the CSV file provided on the command line.
+// the CSV file provided on the command line, after any records skipped with
+// --skip.
 func InputTargetsCSV(ch chan<- ScanTarget) error {
-	return GetTargetsCSV(config.inputFile, ch)
+	source, err := SkipInputRecords(config.inputFile, config.Skip)
+	if err != nil {
+		return err
+	}
+	return GetTargetsCSV(source, ch)
 }
 
 // GetTargetsCSV reads targets from a CSV source, generates ScanTargets,
 // and delivers them to the provided channel.
+//
+// Records that cannot be parsed are logged and skipped. If --echo-input is
+// set, the record's text (which may span several lines, if a quoted field
+// contains a newline) is kept in the targets' RawInput.
 func GetTargetsCSV(source io.Reader, ch chan<- ScanTarget) error {
-	csvreader := csv.NewReader(source)
+	input := &csvInput{reader: bufio.NewReader(source), record: config.EchoInput}
+	csvreader := csv.NewReader(input)
 	csvreader.Comment = '#'
 	csvreader.FieldsPerRecord = -1 // variable
 	for {
 		fields, err := csvreader.Read()
+		raw := input.consumed()
 		if err == io.EOF {
-			break
+			return nil
+		} else if perr, ok := err.(*csv.ParseError); ok {
+			log.Errorf("parse error, skipping: %v", perr)
+			continue
 		} else if err != nil {
 			return err
 		}
@@ -107,21 +147,143 @@ func GetTargetsCSV(source io.Reader, ch chan<- ScanTarget) error {
 			log.Errorf("parse error, skipping: %v", err)
 			continue
 		}
-		var ip net.IP
-		if ipnet != nil {
-			if ipnet.Mask != nil {
-				// expand CIDR block into one target for each IP
-				for ip = ipnet.IP.Mask(ipnet.Mask); ipnet.Contains(ip); incrementIP(ip) {
-					ch <- ScanTarget{IP: duplicateIP(ip), Domain: domain, Tag: tag}
-				}
-				continue
+		target := ScanTarget{Domain: domain, Tag: tag}
+		if config.EchoInput {
+			target.RawInput = raw
+		}
+		sendTargets(ch, ipnet, target)
+	}
+}
+
+// csvInput is the source of a csv.Reader that gives it at most one line per
+// Read, so that the reader never buffers input past the end of the record it
+// is reading. The input read since the last record can then be taken from
+// consumed, if record is set.
+type csvInput struct {
+	reader  *bufio.Reader
+	record  bool
+	pending []byte
+	err     error
+	read    bytes.Buffer
+}
+
+// Read reads the rest of the current line.
+func (input *csvInput) Read(p []byte) (int, error) {
+	if len(input.pending) == 0 {
+		if input.err != nil {
+			return 0, input.err
+		}
+		line, err := input.reader.ReadSlice('\n')
+		if err != nil && err != bufio.ErrBufferFull {
+			input.err = err
+		}
+		if len(line) == 0 {
+			return 0, input.err
+		}
+		input.pending = line
+	}
+	n := copy(p, input.pending)
+	if input.record {
+		input.read.Write(input.pending[:n])
+	}
+	input.pending = input.pending[n:]
+	return n, nil
+}
+
+// consumed returns the text of the last record read, without the empty
+// lines and comments before it or its line ending, and starts the next one.
+func (input *csvInput) consumed() string {
+	if !input.record {
+		return ""
+	}
+	raw := input.read.String()
+	input.read.Reset()
+	for raw != "" && (raw[0] == '#' || raw[0] == '\n' || strings.HasPrefix(raw, "\r\n")) {
+		end := strings.IndexByte(raw, '\n')
+		if end < 0 {
+			return ""
+		}
+		raw = raw[end+1:]
+	}
+	return strings.TrimRight(raw, "\r\n")
+}
+
+// sendTargets delivers target to ch with its IP set from ipnet, expanding a
+// CIDR block into one target for each address in it.
+func sendTargets(ch chan<- ScanTarget, ipnet *net.IPNet, target ScanTarget) {
+	if ipnet != nil {
+		if ipnet.Mask != nil {
+			// expand CIDR block into one target for each IP
+			for ip := ipnet.IP.Mask(ipnet.Mask); ipnet.Contains(ip); incrementIP(ip) {
+				target.IP = duplicateIP(ip)
+				ch <- target
+			}
+			return
+		}
+		target.IP = ipnet.IP
+	}
+	ch <- target
+}
+
+// jsonTarget is a single record of a JSON-format input file.
+type jsonTarget struct {
+	IP     string            `json:"ip"`
+	Domain string            `json:"domain"`
+	Tag    string            `json:"tag"`
+	Port   *uint             `json:"port"`
+	Tags   map[string]string `json:"tags"`
+}
+
+// InputTargetsJSON is an InputTargetsFunc that calls GetTargetsJSON with
+// the JSON file provided on the command line, after any records skipped with
+// --skip.
+func InputTargetsJSON(ch chan<- ScanTarget) error {
+	source, err := SkipInputRecords(config.inputFile, config.Skip)
+	if err != nil {
+		return err
+	}
+	return GetTargetsJSON(source, ch)
+}
+
+// GetTargetsJSON reads targets from a source of JSON objects, one per line,
+// generates ScanTargets, and delivers them to the provided channel.
+//
+// Each object has the fields:
+//   {"ip": ..., "domain": ..., "tag": ..., "port": ..., "tags": {...}}
+//
+// The ip, domain and tag fields are interpreted as the corresponding CSV
+// fields (see ParseCSVTarget), and port overrides the scanner's port. The
+// tags object holds arbitrary string labels that are copied into the
+// target's output record.
+//
+// Empty lines are ignored, and lines that are not valid records are logged
+// and skipped. If --echo-input is set, the object is kept in the targets'
+// RawInput.
+func GetTargetsJSON(source io.Reader, ch chan<- ScanTarget) error {
+	reader := bufio.NewReader(source)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if raw := strings.TrimSpace(line); raw != "" {
+			var record jsonTarget
+			if perr := json.Unmarshal([]byte(raw), &record); perr != nil {
+				log.Errorf("parse error, skipping: %v", perr)
+			} else if ipnet, domain, tag, perr := ParseCSVTarget([]string{record.IP, record.Domain, record.Tag}); perr != nil {
+				log.Errorf("parse error, skipping: %v", perr)
 			} else {
-				ip = ipnet.IP
+				target := ScanTarget{Domain: domain, Tag: tag, Port: record.Port, Tags: record.Tags}
+				if config.EchoInput {
+					target.RawInput = raw
+				}
+				sendTargets(ch, ipnet, target)
 			}
 		}
-		ch <- ScanTarget{IP: ip, Domain: domain, Tag: tag}
+		if err == io.EOF {
+			return nil
+		}
 	}
-	return nil
 }
 
 // InputTargetsFunc is a function type for target input functions.
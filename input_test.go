@@ -1,7 +1,9 @@
 package zgrab2
 
 import (
+	"io"
 	"net"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -150,6 +152,9 @@ func TestGetTargetsCSV(t *testing.T) {
 10.0.0.1
 ,example.com
 example.com
+10.0.0.6,exa"mple.com
+10.0.0.7,,"multi
+line"
 2.2.2.2/30,, tag`
 
 	expected := []ScanTarget{
@@ -158,6 +163,7 @@ example.com
 		ScanTarget{IP: net.ParseIP("10.0.0.1")},
 		ScanTarget{Domain: "example.com"},
 		ScanTarget{Domain: "example.com"},
+		ScanTarget{IP: net.ParseIP("10.0.0.7"), Tag: "multi\nline"},
 		ScanTarget{IP: net.ParseIP("2.2.2.0"), Tag: "tag"},
 		ScanTarget{IP: net.ParseIP("2.2.2.1"), Tag: "tag"},
 		ScanTarget{IP: net.ParseIP("2.2.2.2"), Tag: "tag"},
@@ -189,3 +195,142 @@ example.com
 		}
 	}
 }
+
+func TestGetTargetsJSON(t *testing.T) {
+	input := `{"ip": "10.0.0.1", "domain": "example.com", "tag": "tag", "tags": {"customer": "acme"}}
+{"domain": "example.com", "port": 8443}
+
+{"ip": "2.2.2.2/31", "tags": {"segment": "dmz"}}
+{"domain": ""}
+{"ip": "10.0.0.3"
+["not", "an", "object"]
+{"ip": "10.0.0.2"}`
+
+	port := uint(8443)
+	expected := []ScanTarget{
+		ScanTarget{IP: net.ParseIP("10.0.0.1"), Domain: "example.com", Tag: "tag", Tags: map[string]string{"customer": "acme"}},
+		ScanTarget{Domain: "example.com", Port: &port},
+		ScanTarget{IP: net.ParseIP("2.2.2.2"), Tags: map[string]string{"segment": "dmz"}},
+		ScanTarget{IP: net.ParseIP("2.2.2.3"), Tags: map[string]string{"segment": "dmz"}},
+		ScanTarget{IP: net.ParseIP("10.0.0.2")},
+	}
+
+	ch := make(chan ScanTarget, 0)
+	go func() {
+		err := GetTargetsJSON(strings.NewReader(input), ch)
+		if err != nil {
+			t.Errorf("GetTargetsJSON error: %v", err)
+		}
+		close(ch)
+	}()
+	res := []ScanTarget{}
+	for r := range ch {
+		res = append(res, r)
+	}
+
+	if len(res) != len(expected) {
+		t.Errorf("wrong number of results (got %d; expected %d)", len(res), len(expected))
+		return
+	}
+	for i := range expected {
+		if res[i].IP.String() != expected[i].IP.String() ||
+			res[i].Domain != expected[i].Domain ||
+			res[i].Tag != expected[i].Tag ||
+			!reflect.DeepEqual(res[i].Port, expected[i].Port) ||
+			!reflect.DeepEqual(res[i].Tags, expected[i].Tags) {
+			t.Errorf("wrong data in ScanTarget %d (got %v; expected %v)", i, res[i], expected[i])
+		}
+		if grab := BuildGrabFromInputResponse(&res[i], nil); !reflect.DeepEqual(grab.Tags, expected[i].Tags) {
+			t.Errorf("tags not copied into Grab %d (got %v; expected %v)", i, grab.Tags, expected[i].Tags)
+		}
+	}
+}
+
+// skipAndGetTargets reads the targets from input after skipping n records.
+func skipAndGetTargets(t *testing.T, input string, n uint, getTargets func(io.Reader, chan<- ScanTarget) error) []string {
+	source, err := SkipInputRecords(strings.NewReader(input), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan ScanTarget)
+	go func() {
+		if err := getTargets(source, ch); err != nil {
+			t.Errorf("error reading targets: %v", err)
+		}
+		close(ch)
+	}()
+	var res []string
+	for r := range ch {
+		res = append(res, r.Host())
+	}
+	return res
+}
+
+func TestSkipInputRecords(t *testing.T) {
+	csvInput := `# Comment
+10.0.0.1
+
+10.0.0.2,example.com
+# Another comment
+10.0.0.3
+10.0.0.4`
+	jsonInput := `{"ip": "10.0.0.1"}
+
+{"ip": "10.0.0.2", "domain": "example.com"}
+{"ip": "10.0.0.3"}
+{"ip": "10.0.0.4"}
+`
+	tests := []struct {
+		n        uint
+		expected []string
+	}{
+		{0, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"}},
+		{1, []string{"10.0.0.2", "10.0.0.3", "10.0.0.4"}},
+		{3, []string{"10.0.0.4"}},
+		{4, nil},
+		{10, nil},
+	}
+	for _, test := range tests {
+		if res := skipAndGetTargets(t, csvInput, test.n, GetTargetsCSV); !reflect.DeepEqual(res, test.expected) {
+			t.Errorf("CSV, skip %d: got %v; expected %v", test.n, res, test.expected)
+		}
+		if res := skipAndGetTargets(t, jsonInput, test.n, GetTargetsJSON); !reflect.DeepEqual(res, test.expected) {
+			t.Errorf("JSON, skip %d: got %v; expected %v", test.n, res, test.expected)
+		}
+	}
+}
+
+func TestGetTargetsEchoInput(t *testing.T) {
+	saved := config.EchoInput
+	defer func() { config.EchoInput = saved }()
+	config.EchoInput = true
+
+	csvInput := "# Comment\r\n10.0.0.1, example.com ,tag\r\n\r\n10.0.0.2,,\"a\r\n# b\"\r\n2.2.2.2/31\n"
+	jsonInput := `{"ip": "10.0.0.1", "tags": {"customer": "acme"}}
+
+{"ip": "2.2.2.2/31"}`
+	tests := []struct {
+		input      string
+		getTargets func(io.Reader, chan<- ScanTarget) error
+		expected   []string
+	}{
+		{csvInput, GetTargetsCSV, []string{"10.0.0.1, example.com ,tag", "10.0.0.2,,\"a\r\n# b\"", "2.2.2.2/31", "2.2.2.2/31"}},
+		{jsonInput, GetTargetsJSON, []string{`{"ip": "10.0.0.1", "tags": {"customer": "acme"}}`, `{"ip": "2.2.2.2/31"}`, `{"ip": "2.2.2.2/31"}`}},
+	}
+	for _, test := range tests {
+		ch := make(chan ScanTarget)
+		go func() {
+			if err := test.getTargets(strings.NewReader(test.input), ch); err != nil {
+				t.Errorf("error reading targets: %v", err)
+			}
+			close(ch)
+		}()
+		var res []string
+		for r := range ch {
+			res = append(res, BuildGrabFromInputResponse(&r, nil).Input)
+		}
+		if !reflect.DeepEqual(res, test.expected) {
+			t.Errorf("got raw inputs %q; expected %q", res, test.expected)
+		}
+	}
+}
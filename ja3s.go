@@ -0,0 +1,142 @@
+package zgrab2
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// maxServerHelloRecording bounds the number of bytes recorded while looking
+// for the ServerHello (a full-size record, plus some slack).
+const maxServerHelloRecording = 16384 + 5 + 1024
+
+// errNoServerHello is returned by parseServerHello when the recorded bytes do
+// not begin with a complete, well-formed ServerHello.
+var errNoServerHello = errors.New("no complete ServerHello was received")
+
+// JA3S is the JA3S fingerprint of a ServerHello: the decimal version,
+// selected cipher suite and extension types, in the order they were sent,
+// joined as "version,cipher,ext1-ext2-...", and the MD5 hash of that string.
+type JA3S struct {
+	// String is the JA3S string, e.g. "771,49199,65281-0-11-35-23".
+	String string `json:"string"`
+
+	// Hash is the hex-encoded MD5 hash of String.
+	Hash string `json:"hash"`
+}
+
+// serverHelloRecorder wraps the connection a TLS client reads from,
+// recording the first bytes the server sends so that the raw ServerHello
+// can be recovered once the handshake is done.
+type serverHelloRecorder struct {
+	net.Conn
+	recorded  []byte
+	recording bool
+}
+
+func newServerHelloRecorder(conn net.Conn) *serverHelloRecorder {
+	return &serverHelloRecorder{Conn: conn, recording: true}
+}
+
+// Read reads from the underlying connection, recording what was read until
+// stop is called or maxServerHelloRecording bytes have been recorded.
+func (r *serverHelloRecorder) Read(b []byte) (int, error) {
+	n, err := r.Conn.Read(b)
+	if r.recording && n > 0 {
+		room := maxServerHelloRecording - len(r.recorded)
+		if room > n {
+			room = n
+		}
+		r.recorded = append(r.recorded, b[:room]...)
+	}
+	return n, err
+}
+
+// stop stops recording, and returns the JA3S fingerprint of the recorded
+// ServerHello, or nil if none was recorded.
+func (r *serverHelloRecorder) stop() *JA3S {
+	recorded := r.recorded
+	r.recording = false
+	r.recorded = nil
+	version, cipher, extensions, err := parseServerHello(recorded)
+	if err != nil {
+		return nil
+	}
+	return newJA3S(version, cipher, extensions)
+}
+
+// isGREASE returns true if value is a GREASE value (RFC 8701), which JA3
+// ignores.
+func isGREASE(value uint16) bool {
+	return value&0x0f0f == 0x0a0a && value>>8 == value&0xff
+}
+
+// newJA3S returns the JA3S fingerprint of a ServerHello with the given
+// fields.
+func newJA3S(version, cipher uint16, extensions []uint16) *JA3S {
+	var types []string
+	for _, extension := range extensions {
+		if !isGREASE(extension) {
+			types = append(types, strconv.Itoa(int(extension)))
+		}
+	}
+	str := strconv.Itoa(int(version)) + "," + strconv.Itoa(int(cipher)) + "," + strings.Join(types, "-")
+	hash := md5.Sum([]byte(str))
+	return &JA3S{String: str, Hash: hex.EncodeToString(hash[:])}
+}
+
+// parseServerHello extracts the version, cipher suite and extension types
+// from the ServerHello at the start of the raw TLS records in data.
+func parseServerHello(data []byte) (version uint16, cipher uint16, extensions []uint16, err error) {
+	// Reassemble the handshake messages from the leading handshake records.
+	var handshake []byte
+	for len(data) >= 5 && data[0] == 22 {
+		length := int(binary.BigEndian.Uint16(data[3:5]))
+		if len(data) < 5+length {
+			break
+		}
+		handshake = append(handshake, data[5:5+length]...)
+		data = data[5+length:]
+	}
+	if len(handshake) < 4 || handshake[0] != 2 {
+		return 0, 0, nil, errNoServerHello
+	}
+	length := int(handshake[1])<<16 | int(handshake[2])<<8 | int(handshake[3])
+	if len(handshake) < 4+length {
+		return 0, 0, nil, errNoServerHello
+	}
+	hello := handshake[4 : 4+length]
+
+	// version(2) random(32) session_id_length(1)
+	if len(hello) < 35 {
+		return 0, 0, nil, errNoServerHello
+	}
+	version = binary.BigEndian.Uint16(hello[0:2])
+	offset := 35 + int(hello[34])
+	// cipher_suite(2) compression_method(1)
+	if len(hello) < offset+3 {
+		return 0, 0, nil, errNoServerHello
+	}
+	cipher = binary.BigEndian.Uint16(hello[offset : offset+2])
+	offset += 3
+	if len(hello) < offset+2 {
+		// No extensions
+		return version, cipher, nil, nil
+	}
+	end := offset + 2 + int(binary.BigEndian.Uint16(hello[offset:offset+2]))
+	if len(hello) < end {
+		return 0, 0, nil, errNoServerHello
+	}
+	for offset += 2; offset+4 <= end; {
+		extensions = append(extensions, binary.BigEndian.Uint16(hello[offset:offset+2]))
+		offset += 4 + int(binary.BigEndian.Uint16(hello[offset+2:offset+4]))
+	}
+	if offset != end {
+		return 0, 0, nil, errNoServerHello
+	}
+	return version, cipher, extensions, nil
+}
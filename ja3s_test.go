@@ -0,0 +1,95 @@
+package zgrab2
+
+import (
+	stdtls "crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testServerHello is a TLS 1.2 ServerHello record selecting
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 with the renegotiation_info,
+// server_name, ec_point_formats, session_ticket and extended_master_secret
+// extensions.
+var testServerHello = []byte{
+	22, 0x03, 0x03, 0x00, 0x43, // record header
+	2, 0x00, 0x00, 0x3f, // handshake header
+	0x03, 0x03, // version
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // random
+	0,          // session ID
+	0xc0, 0x2f, // cipher suite
+	0,          // compression method
+	0x00, 0x17, // extensions length
+	0xff, 0x01, 0x00, 0x01, 0x00,
+	0x00, 0x00, 0x00, 0x00,
+	0x00, 0x0b, 0x00, 0x02, 0x01, 0x00,
+	0x00, 0x23, 0x00, 0x00,
+	0x00, 0x17, 0x00, 0x00,
+}
+
+func TestParseServerHello(t *testing.T) {
+	version, cipher, extensions, err := parseServerHello(testServerHello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ja3s := newJA3S(version, cipher, extensions)
+	if ja3s.String != "771,49199,65281-0-11-35-23" {
+		t.Errorf("unexpected JA3S string %q", ja3s.String)
+	}
+	if ja3s.Hash != "098e26e2609212ac1bfac552fbe04127" {
+		t.Errorf("unexpected JA3S hash %q", ja3s.Hash)
+	}
+
+	// The ServerHello split across two records
+	split := append([]byte{22, 0x03, 0x03, 0x00, 0x10}, testServerHello[5:21]...)
+	split = append(split, 22, 0x03, 0x03, 0x00, 0x33)
+	split = append(split, testServerHello[21:]...)
+	if _, _, splitExtensions, err := parseServerHello(split); err != nil || len(splitExtensions) != 5 {
+		t.Errorf("failed to parse split ServerHello: %v, %v", splitExtensions, err)
+	}
+
+	for _, truncated := range [][]byte{testServerHello[:30], testServerHello[:len(testServerHello)-1], {21, 3, 3, 0, 2, 2, 40}} {
+		if _, _, _, err := parseServerHello(truncated); err != errNoServerHello {
+			t.Errorf("expected %v to be rejected, got %v", truncated, err)
+		}
+	}
+}
+
+func TestHandshakeJA3S(t *testing.T) {
+	// Borrow the test certificate of an httptest TLS server.
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	config := server.TLS.Clone()
+	config.MaxVersion = stdtls.VersionTLS12
+	config.CipherSuites = []uint16{stdtls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}
+	server.Close()
+
+	client, serverConn := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer serverConn.Close()
+		serverConn.SetDeadline(time.Now().Add(5 * time.Second))
+		stdtls.Server(serverConn, config).Handshake()
+	}()
+
+	flags := &TLSFlags{}
+	conn, err := flags.GetTLSConnection(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+	ja3s := conn.GetLog().JA3S
+	if ja3s == nil {
+		t.Fatal("no JA3S fingerprint")
+	}
+	expected := "771," + strconv.Itoa(int(stdtls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)) + ","
+	if !strings.HasPrefix(ja3s.String, expected) || len(ja3s.Hash) != 32 {
+		t.Errorf("unexpected JA3S %+v", ja3s)
+	}
+}
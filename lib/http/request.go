@@ -774,6 +774,21 @@ func NewRequest(method, urlStr string, body io.Reader) (*Request, error) {
 	return NewRequestWithHost(method, urlStr, "", body)
 }
 
+// NewRequestWithContext is NewRequest, with the request's context set to
+// ctx, which controls the request's cancelation. The provided ctx must be
+// non-nil.
+func NewRequestWithContext(ctx context.Context, method, urlStr string, body io.Reader) (*Request, error) {
+	if ctx == nil {
+		return nil, errors.New("net/http: nil Context")
+	}
+	req, err := NewRequest(method, urlStr, body)
+	if err != nil {
+		return nil, err
+	}
+	req.ctx = ctx
+	return req, nil
+}
+
 // NewRequest returns a new Request given a method, URL, and optional body.
 //
 // If the provided body is also an io.Closer, the returned
@@ -65,6 +65,12 @@ type Response struct {
 	BodyHash string `json:"body_hash,omitempty"`
 	// Number of bytes read from the server and encoded into BodyText
 	BodyTextLength int64 `json:"body_length,omitempty"`
+	// BodyCompression is the Content-Encoding ("gzip" or "deflate") that
+	// BodyText was decompressed from, if any.
+	BodyCompression string `json:"body_compression,omitempty"`
+	// BodyCompressed holds the raw compressed bytes read from the server,
+	// if the scanner was configured to keep them.
+	BodyCompressed []byte `json:"body_compressed,omitempty"`
 
 	// ContentLength records the length of the associated content. The
 	// value -1 indicates that the length is unknown. Unless Request.Method
@@ -151,18 +157,76 @@ func (r *Response) Location() (*url.URL, error) {
 	return url.Parse(lv)
 }
 
+// protocolHTTP09 is the Protocol of a response without a status line or
+// headers, i.e. the bare body sent by HTTP/0.9 servers.
+var protocolHTTP09 = Protocol{Name: "HTTP/0.9", Major: 0, Minor: 9}
+
+// InvalidResponseError is returned by ReadResponse when the response is not
+// valid HTTP: its status line or headers are malformed, or it does not start
+// with a status line and is not text, so it cannot be an HTTP/0.9 response.
+type InvalidResponseError struct {
+	Err error
+
+	// Raw is the start of the response, as much of it as had been received
+	// when it was parsed.
+	Raw []byte
+}
+
+func (e *InvalidResponseError) Error() string { return e.Err.Error() }
+
+// looksLikeText returns true if b has no control characters other than
+// whitespace.
+func looksLikeText(b []byte) bool {
+	for _, c := range b {
+		if (c < 0x20 && c != '\t' && c != '\n' && c != '\r' && c != '\f') || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
 // ReadResponse reads and returns an HTTP response from r.
 // The req parameter optionally specifies the Request that corresponds
 // to this Response. If nil, a GET request is assumed.
 // Clients must call resp.Body.Close when finished reading resp.Body.
 // After that call, clients can inspect resp.Trailer to find key/value
 // pairs included in the response trailer.
+//
+// A response that is not valid HTTP, including one that does not start with
+// an HTTP version, yields an *InvalidResponseError.
 func ReadResponse(r *bufio.Reader, req *Request) (*Response, error) {
+	return readResponse(r, req, false)
+}
+
+// readResponse is ReadResponse, except that if allowHTTP09 is true, a text
+// response that does not start with an HTTP version is read as an HTTP/0.9
+// response: its Protocol is HTTP/0.9, it has no status or headers, and its
+// Body is everything up to the end of the connection.
+func readResponse(r *bufio.Reader, req *Request, allowHTTP09 bool) (*Response, error) {
 	tp := textproto.NewReader(r)
 	resp := &Response{
 		Request: req,
 	}
 
+	const versionPrefix = "HTTP/"
+	start, _ := r.Peek(len(versionPrefix))
+	buffered, _ := r.Peek(r.Buffered())
+	raw := append([]byte(nil), buffered...)
+	invalid := func(err error) error {
+		return &InvalidResponseError{Err: err, Raw: raw}
+	}
+	if len(start) > 0 && !strings.EqualFold(string(start), versionPrefix[:len(start)]) {
+		if !allowHTTP09 || !looksLikeText(raw) {
+			return resp, invalid(&badStringError{"malformed HTTP response", string(start)})
+		}
+		resp.Protocol = protocolHTTP09
+		resp.Header = make(Header)
+		if err := readTransfer(resp, r); err != nil {
+			return resp, err
+		}
+		return resp, nil
+	}
+
 	// Parse the first line of the response.
 	line, err := tp.ReadLine()
 	if err != nil {
@@ -173,25 +237,25 @@ func ReadResponse(r *bufio.Reader, req *Request) (*Response, error) {
 	}
 	f := strings.SplitN(line, " ", 3)
 	if len(f) < 2 {
-		return resp, &badStringError{"malformed HTTP response", line}
+		return resp, invalid(&badStringError{"malformed HTTP response", line})
 	}
 	reasonPhrase := ""
 	if len(f) > 2 {
 		reasonPhrase = f[2]
 	}
 	if len(f[1]) != 3 {
-		return resp, &badStringError{"malformed HTTP status code", f[1]}
+		return resp, invalid(&badStringError{"malformed HTTP status code", f[1]})
 	}
 	resp.StatusCode, err = strconv.Atoi(f[1])
 	if err != nil || resp.StatusCode < 0 {
-		return resp, &badStringError{"malformed HTTP status code", f[1]}
+		return resp, invalid(&badStringError{"malformed HTTP status code", f[1]})
 	}
 	resp.Status = f[1] + " " + reasonPhrase
 	resp.Protocol = *(new(Protocol))
 	resp.Protocol.Name = f[0]
 	var ok bool
 	if resp.Protocol.Major, resp.Protocol.Minor, ok = ParseHTTPVersion(resp.Protocol.Name); !ok {
-		return resp, &badStringError{"malformed HTTP version", resp.Protocol.Name}
+		return resp, invalid(&badStringError{"malformed HTTP version", resp.Protocol.Name})
 	}
 
 	// Parse the response headers.
@@ -200,6 +264,9 @@ func ReadResponse(r *bufio.Reader, req *Request) (*Response, error) {
 		if err == io.EOF {
 			err = io.ErrUnexpectedEOF
 		}
+		if _, ok := err.(textproto.ProtocolError); ok {
+			err = invalid(err)
+		}
 		return resp, err
 	}
 	resp.Header = Header(mimeHeader)
@@ -974,3 +974,36 @@ func TestNeedsSniff(t *testing.T) {
 		t.Errorf("needsSniff empty Content-Type = %t; want %t", got, want)
 	}
 }
+
+func TestReadResponseWithoutStatusLine(t *testing.T) {
+	body := "<html><body>Printer status: ready</body></html>\r\n"
+	_, err := ReadResponse(bufio.NewReader(strings.NewReader(body)), nil)
+	if invalid, ok := err.(*InvalidResponseError); !ok || string(invalid.Raw) != body {
+		t.Errorf("expected an InvalidResponseError without allowHTTP09, got %#v", err)
+	}
+
+	resp, err := readResponse(bufio.NewReader(strings.NewReader(body)), nil, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Protocol != protocolHTTP09 || resp.StatusCode != 0 || len(resp.Header) != 0 || !resp.Close {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	got, err := ioutil.ReadAll(resp.Body)
+	if err != nil || string(got) != body {
+		t.Errorf("Body = %q, %v; want %q", got, err, body)
+	}
+
+	binary := "\x00\x01\x02\x03garbage"
+	_, err = readResponse(bufio.NewReader(strings.NewReader(binary)), nil, true)
+	invalid, ok := err.(*InvalidResponseError)
+	if !ok || string(invalid.Raw) != binary {
+		t.Errorf("expected an InvalidResponseError with the raw response, got %#v", err)
+	}
+
+	malformed := "HTTP/1.1 abc OK\r\n\r\n"
+	_, err = ReadResponse(bufio.NewReader(strings.NewReader(malformed)), nil)
+	if invalid, ok := err.(*InvalidResponseError); !ok || string(invalid.Raw) != malformed {
+		t.Errorf("expected an InvalidResponseError with the raw response, got %#v", err)
+	}
+}
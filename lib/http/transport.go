@@ -129,6 +129,11 @@ type Transport struct {
 	// between different HTTP requests.
 	DisableKeepAlives bool
 
+	// AllowHTTP09, if true, reads a text response that does not start
+	// with a status line as an HTTP/0.9 response instead of failing
+	// with an *InvalidResponseError.
+	AllowHTTP09 bool
+
 	// DisableCompression, if true, prevents the Transport from
 	// requesting compression with an "Accept-Encoding: gzip"
 	// request header when the Request contains no existing
@@ -1655,7 +1660,7 @@ func (pc *persistConn) readResponse(rc requestAndChan, trace *httptrace.ClientTr
 			trace.GotFirstResponseByte()
 		}
 	}
-	resp, err = ReadResponse(pc.br, rc.req)
+	resp, err = readResponse(pc.br, rc.req, pc.t.AllowHTTP09)
 	if err != nil {
 		return
 	}
@@ -1671,7 +1676,7 @@ func (pc *persistConn) readResponse(rc requestAndChan, trace *httptrace.ClientTr
 	}
 	if resp.StatusCode == 100 {
 		pc.readLimit = pc.maxHeaderResponseSize() // reset the limit
-		resp, err = ReadResponse(pc.br, rc.req)
+		resp, err = readResponse(pc.br, rc.req, pc.t.AllowHTTP09)
 		if err != nil {
 			return
 		}
@@ -0,0 +1,186 @@
+// Package httpapi provides the HTTP client shared by the modules that probe
+// HTTP APIs (e.g. docker): it connects to a single target, over TLS if
+// requested, records the TLS log and the connection timing, and reads a
+// bounded amount of each response body.
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/http"
+)
+
+// Config holds the options of a Client.
+type Config struct {
+	// BaseFlags are the module's flags, giving the timeout and byte limits.
+	BaseFlags *zgrab2.BaseFlags
+
+	// TLSFlags are the module's TLS flags, used if UseTLS is set.
+	TLSFlags *zgrab2.TLSFlags
+
+	// UseTLS indicates that the client should connect over TLS.
+	UseTLS bool
+
+	// UserAgent is sent in the User-Agent header of each request.
+	UserAgent string
+
+	// MaxSize bounds the size of each response body that is read, in
+	// kilobytes.
+	MaxSize int
+
+	// TLSLog, if not nil, is set to the log of each TLS handshake that is
+	// attempted, so that it is recorded in the module's results.
+	TLSLog **zgrab2.TLSLog
+}
+
+// Client sends requests to an HTTP API on a single target. Redirects are
+// not followed, and cookies are neither sent nor stored.
+type Client struct {
+	config      *Config
+	target      *zgrab2.ScanTarget
+	client      *http.Client
+	baseURL     string
+	connections []net.Conn
+
+	// TLSLog is the log of the most recent TLS handshake, if UseTLS is set
+	// and a handshake has been attempted.
+	TLSLog *zgrab2.TLSLog
+}
+
+// NewClient returns a Client for the API on the given port of the target.
+// Its connections end with the target's context; Close must be called when
+// the scan is done.
+func NewClient(target *zgrab2.ScanTarget, port uint, config *Config) *Client {
+	ret := &Client{
+		config: config,
+		target: target,
+		client: http.MakeNewClient(),
+	}
+	transport := &http.Transport{
+		Proxy:              nil,
+		DisableKeepAlives:  false,
+		DisableCompression: false,
+	}
+	transport.DialTLS = ret.dialTLS
+	transport.DialContext = ret.dialContext
+	ret.client.Transport = transport
+	ret.client.UserAgent = config.UserAgent
+	ret.client.Jar = nil
+	ret.client.CheckRedirect = func(*http.Request, *http.Response, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	host := target.Domain
+	if host == "" {
+		host = target.IP.String()
+	}
+	scheme := "http://"
+	if config.UseTLS {
+		scheme = "https://"
+	}
+	ret.baseURL = scheme + net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10))
+	return ret
+}
+
+// dialContext opens a connection to addr, recording the connect time and
+// the remote address on the target.
+func (c *Client) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	start := time.Now()
+	conn, err := zgrab2.DialTimeoutConnectionContext(ctx, network, addr, c.config.BaseFlags.Timeout, c.config.BaseFlags.BytesReadLimit)
+	if err != nil {
+		return nil, err
+	}
+	c.target.RecordConnect(start)
+	c.target.RecordRemoteAddr(conn.RemoteAddr())
+	c.connections = append(c.connections, conn)
+	return conn, nil
+}
+
+// dialTLS opens a connection to addr and performs the TLS handshake,
+// recording the TLS log and the handshake time.
+func (c *Client) dialTLS(network, addr string) (net.Conn, error) {
+	outer, err := c.dialContext(c.target.Context(), network, addr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConn, err := c.config.TLSFlags.GetTLSConnection(outer)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	err = tlsConn.Handshake()
+	c.TLSLog = tlsConn.GetLog()
+	if c.config.TLSLog != nil {
+		*c.config.TLSLog = c.TLSLog
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.target.RecordHandshake(start)
+	return tlsConn, nil
+}
+
+// Close closes any connections that have been opened by the client.
+func (c *Client) Close() {
+	for _, conn := range c.connections {
+		conn.Close()
+	}
+	c.connections = nil
+}
+
+// Do sends a request for endpoint, accepting the given media type and with
+// a JSON body if body is not empty, and reads up to MaxSize KB of the
+// response body into its BodyText. It also returns the number of bytes
+// read.
+func (c *Client) Do(method string, endpoint string, accept string, body string) (*http.Response, int64, error) {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	request, err := http.NewRequestWithContext(c.target.Context(), method, c.baseURL+endpoint, reader)
+	if err != nil {
+		return nil, 0, err
+	}
+	request.Header.Set("Accept", accept)
+	if body != "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(request)
+	if urlError, ok := err.(*url.Error); ok {
+		err = urlError.Err
+	}
+	if err != nil {
+		return resp, 0, err
+	}
+	b := new(bytes.Buffer)
+	maxReadLen := int64(c.config.MaxSize) * 1024
+	readLen := maxReadLen
+	if resp.ContentLength >= 0 && resp.ContentLength < maxReadLen {
+		readLen = resp.ContentLength
+	}
+	n, _ := io.CopyN(b, resp.Body, readLen)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(b)
+	resp.BodyText = b.String()
+	if len(resp.BodyText) > 0 {
+		m := sha256.Sum256(b.Bytes())
+		resp.BodySHA256 = m[:]
+	}
+	return resp, n, nil
+}
+
+// Get sends a GET request for endpoint, accepting JSON, and reads up to
+// MaxSize KB of the response body into its BodyText.
+func (c *Client) Get(endpoint string) (*http.Response, error) {
+	resp, _, err := c.Do("GET", endpoint, "application/json", "")
+	return resp, err
+}
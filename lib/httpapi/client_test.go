@@ -0,0 +1,79 @@
+package httpapi
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+func TestClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/big":
+			w.Write([]byte(strings.Repeat("x", 2000)))
+		case "/echo":
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Write([]byte(r.Method + " " + r.Header.Get("Accept") + " " + r.Header.Get("Content-Type") + " " + string(body)))
+		case "/redirect":
+			http.Redirect(w, r, "/echo", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	addr := server.Listener.Addr().(*net.TCPAddr)
+	baseFlags := &zgrab2.BaseFlags{Timeout: 5 * time.Second}
+	client := NewClient(&zgrab2.ScanTarget{IP: addr.IP}, uint(addr.Port), &Config{
+		BaseFlags: baseFlags,
+		UserAgent: "zgrab",
+		MaxSize:   1,
+	})
+	defer client.Close()
+
+	resp, n, err := client.Do("GET", "/big", "*/*", "")
+	if err != nil || n != 1024 || len(resp.BodyText) != 1024 || resp.BodySHA256 == nil {
+		t.Errorf("expected the body to be truncated to 1024 bytes, got %d (%v)", n, err)
+	}
+	resp, _, err = client.Do("POST", "/echo", "application/json", `{"a":1}`)
+	if expected := `POST application/json application/json {"a":1}`; err != nil || resp.BodyText != expected {
+		t.Errorf("expected %q, got %q (%v)", expected, resp.BodyText, err)
+	}
+	resp, err = client.Get("/redirect")
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Errorf("expected the redirect not to be followed, got %v (%v)", resp, err)
+	}
+	if client.TLSLog != nil {
+		t.Errorf("unexpected TLS log without TLS")
+	}
+}
+
+func TestClientTLSLog(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	addr := server.Listener.Addr().(*net.TCPAddr)
+	var tlsLog *zgrab2.TLSLog
+	client := NewClient(&zgrab2.ScanTarget{IP: addr.IP}, uint(addr.Port), &Config{
+		BaseFlags: &zgrab2.BaseFlags{Timeout: 5 * time.Second},
+		TLSFlags:  &zgrab2.TLSFlags{},
+		UseTLS:    true,
+		UserAgent: "zgrab",
+		MaxSize:   1,
+		TLSLog:    &tlsLog,
+	})
+	defer client.Close()
+
+	if resp, err := client.Get("/"); err != nil || resp.BodyText != "{}" {
+		t.Fatalf("unexpected response %v (%v)", resp, err)
+	}
+	if tlsLog == nil || tlsLog != client.TLSLog || tlsLog.HandshakeLog == nil {
+		t.Errorf("expected the TLS log to be recorded in the config's TLSLog, got %v", tlsLog)
+	}
+}
@@ -0,0 +1,27 @@
+// Package httpapitest provides a test fixture for the modules that probe
+// HTTP APIs with the httpapi client.
+package httpapitest
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zmap/zgrab2"
+)
+
+// ServeTarget starts a local HTTP server using handler, and calls scan with
+// a target for the server's address and port. The server is closed once scan
+// returns.
+func ServeTarget(t *testing.T, handler http.Handler, scan func(target *zgrab2.ScanTarget)) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	addr, ok := server.Listener.Addr().(*net.TCPAddr)
+	if !ok {
+		t.Fatalf("unexpected test server address %v", server.Listener.Addr())
+	}
+	port := uint(addr.Port)
+	scan(&zgrab2.ScanTarget{IP: addr.IP, Port: &port})
+}
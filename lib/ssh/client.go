@@ -5,6 +5,9 @@
 package ssh
 
 import (
+	"bytes"
+	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net"
@@ -84,13 +87,28 @@ func NewClientConn(c net.Conn, addr string, config *ClientConfig) (Conn, <-chan
 // clientHandshake performs the client side key exchange. See RFC 4253 Section
 // 7.
 func (c *connection) clientHandshake(dialAddress string, config *ClientConfig) error {
-	if config.ClientVersion != "" {
+	if len(config.RawClientBanner) > 0 {
+		// The version that goes into the exchange hash is the raw banner
+		// without its line ending, if it has one.
+		c.clientVersion = bytes.TrimRight(config.RawClientBanner, "\r\n")
+	} else if config.ClientVersion != "" {
 		c.clientVersion = []byte(config.ClientVersion)
 	} else {
 		c.clientVersion = []byte(packageVersion)
 	}
 	var err error
-	c.serverVersion, err = exchangeVersions(c.sshConn.conn, c.clientVersion)
+	if len(config.RawClientBanner) > 0 {
+		if config.ConnLog != nil {
+			config.ConnLog.RawClientBanner = hex.EncodeToString(config.RawClientBanner)
+		}
+		c.serverVersion, err = exchangeRawVersion(c.sshConn.conn, config.RawClientBanner)
+		if err != nil && config.ConnLog != nil && len(c.serverVersion) > 0 {
+			// Record what the server sent before it ended the connection.
+			config.ConnLog.ServerID = &EndpointId{Raw: string(c.serverVersion)}
+		}
+	} else {
+		c.serverVersion, err = exchangeVersions(c.sshConn.conn, c.clientVersion)
+	}
 	if err != nil {
 		return err
 	}
@@ -219,10 +237,28 @@ func (c *Client) handleChannelOpens(in <-chan NewChannel) {
 // to incoming channels and requests, use net.Dial with NewClientConn
 // instead.
 func Dial(network, addr string, config *ClientConfig) (*Client, error) {
-	conn, err := net.DialTimeout(network, addr, config.Timeout)
+	return DialContext(context.Background(), network, addr, config)
+}
+
+// DialContext is Dial, except that the dial and the handshake are abandoned
+// (and the connection closed) when ctx is done.
+func DialContext(ctx context.Context, network, addr string, config *ClientConfig) (*Client, error) {
+	dialer := net.Dialer{Timeout: config.Timeout}
+	conn, err := dialer.DialContext(ctx, network, addr)
 	if err != nil {
 		return nil, err
 	}
+	if ctx.Done() != nil {
+		handshakeDone := make(chan struct{})
+		defer close(handshakeDone)
+		go func() {
+			select {
+			case <-ctx.Done():
+				conn.Close()
+			case <-handshakeDone:
+			}
+		}()
+	}
 
 	if config.Timeout != 0 {
 		conn.SetDeadline(time.Now().Add(config.Timeout))
@@ -268,6 +304,11 @@ type ClientConfig struct {
 	// be used for the connection. If empty, a reasonable default is used.
 	ClientVersion string
 
+	// RawClientBanner, if set, is sent exactly as given (with no line
+	// ending added, and no checks) in place of the identification line
+	// built from ClientVersion, to test how servers handle malformed ones.
+	RawClientBanner []byte
+
 	// HostKeyAlgorithms lists the key types that the client will
 	// accept from the server as host key, in order of
 	// preference. If empty, a reasonable default is used. Any
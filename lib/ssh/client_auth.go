@@ -13,7 +13,10 @@ import (
 
 // clientAuthenticate authenticates with the remote server. See RFC 4252.
 func (c *connection) clientAuthenticate(config *ClientConfig) error {
-	if c.transport.config.ConnLog != nil && !config.DontAuthenticate {
+	if config.DontAuthenticate {
+		return c.collectAuthMethods(config)
+	}
+	if c.transport.config.ConnLog != nil {
 		// Use ConnLog existence to indicate that this is a run and not testing
 		return nil
 	}
@@ -45,13 +48,6 @@ func (c *connection) clientAuthenticate(config *ClientConfig) error {
 			return nil
 		}
 
-		if c.transport.config.ConnLog != nil {
-			c.transport.config.ConnLog.UserAuth = methods
-		}
-		if config.DontAuthenticate {
-			return nil
-		}
-
 		tried[auth.method()] = true
 		if methods == nil {
 			methods = lastMethods
@@ -77,6 +73,79 @@ func (c *connection) clientAuthenticate(config *ClientConfig) error {
 	return fmt.Errorf("ssh: unable to authenticate, attempted methods %v, no supported methods remain", keys(tried))
 }
 
+// collectAuthMethods sends a "none" authentication request, which asks the
+// server which methods it allows without attempting any, and records the
+// answer in the ConnLog's AuthMethods (and the deprecated UserAuth), if
+// there is a ConnLog. If the exchange fails (e.g. the server closes the
+// connection on the none request), whatever was learned before is kept, and
+// the error is recorded there rather than returned, as the handshake itself
+// succeeded.
+func (c *connection) collectAuthMethods(config *ClientConfig) error {
+	authLog := new(AuthMethodsLog)
+	if c.transport.config.ConnLog != nil {
+		c.transport.config.ConnLog.AuthMethods = authLog
+	}
+	if err := c.requestAuthMethods(config, authLog); err != nil {
+		authLog.Error = err.Error()
+	}
+	if c.transport.config.ConnLog != nil {
+		c.transport.config.ConnLog.UserAuth = authLog.Methods
+	}
+	return nil
+}
+
+// requestAuthMethods does the work of collectAuthMethods.
+func (c *connection) requestAuthMethods(config *ClientConfig, authLog *AuthMethodsLog) error {
+	if err := c.transport.writePacket(Marshal(&serviceRequestMsg{serviceUserAuth})); err != nil {
+		return err
+	}
+	packet, err := c.transport.readPacket()
+	if err != nil {
+		return err
+	}
+	var serviceAccept serviceAcceptMsg
+	if err := Unmarshal(packet, &serviceAccept); err != nil {
+		return err
+	}
+	authLog.ServiceAccepted = true
+
+	if err := c.transport.writePacket(Marshal(&userAuthRequestMsg{
+		User:    config.User,
+		Service: serviceSSH,
+		Method:  "none",
+	})); err != nil {
+		return err
+	}
+	for {
+		packet, err := c.transport.readPacket()
+		if err != nil {
+			return err
+		}
+		switch packet[0] {
+		case msgUserAuthBanner:
+			if err := handleBannerResponse(c.transport, packet); err != nil {
+				return err
+			}
+		case msgUserAuthFailure:
+			var msg userAuthFailureMsg
+			if err := Unmarshal(packet, &msg); err != nil {
+				return err
+			}
+			authLog.Methods = msg.Methods
+			if authLog.Methods == nil {
+				authLog.Methods = []string{}
+			}
+			authLog.PartialSuccess = msg.PartialSuccess
+			return nil
+		case msgUserAuthSuccess:
+			authLog.NoneAccepted = true
+			return nil
+		default:
+			return unexpectedMessageError(msgUserAuthFailure, packet[0])
+		}
+	}
+}
+
 func keys(m map[string]bool) []string {
 	s := make([]string, 0, len(m))
 
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -470,3 +471,23 @@ func TestClientAuthNone(t *testing.T) {
 		t.Fatalf("server: got %q, want %q", serverConn.User(), user)
 	}
 }
+
+func TestClientAuthCollectMethods(t *testing.T) {
+	connLog := new(HandshakeLog)
+	config := &ClientConfig{
+		User:             "testuser",
+		DontAuthenticate: true,
+	}
+	config.ConnLog = connLog
+	if err := tryAuth(t, config); err != nil {
+		t.Fatalf("unable to dial remote side: %s", err)
+	}
+	authLog := connLog.AuthMethods
+	if authLog == nil || !authLog.ServiceAccepted || authLog.NoneAccepted || authLog.Error != "" {
+		t.Fatalf("unexpected auth methods log %+v", authLog)
+	}
+	expected := []string{"password", "publickey", "keyboard-interactive"}
+	if !reflect.DeepEqual(authLog.Methods, expected) || !reflect.DeepEqual(connLog.UserAuth, expected) {
+		t.Errorf("expected methods %v, got %v (userauth %v)", expected, authLog.Methods, connLog.UserAuth)
+	}
+}
@@ -341,6 +341,16 @@ func (t *handshakeTransport) enterKeyExchangeLocked(otherInitPacket []byte) erro
 	if debugHandshake {
 		log.Printf("%s entered key exchange", t.id())
 	}
+	// Record the other side's KEXINIT before answering it, so that it is
+	// logged even if the key exchange fails.
+	otherInit := &KexInitMsg{}
+	if err := Unmarshal(otherInitPacket, otherInit); err != nil {
+		return err
+	}
+	if t.config.ConnLog != nil && t.config.ConnLog.ServerKex == nil {
+		t.config.ConnLog.ServerKex = otherInit
+	}
+
 	myInit, myInitPacket, err := t.sendKexInitLocked(subsequentKeyExchange)
 	if err != nil {
 		return err
@@ -352,14 +362,6 @@ func (t *handshakeTransport) enterKeyExchangeLocked(otherInitPacket []byte) erro
 		}
 	}
 
-	otherInit := &KexInitMsg{}
-	if err := Unmarshal(otherInitPacket, otherInit); err != nil {
-		return err
-	}
-	if t.config.ConnLog != nil {
-		t.config.ConnLog.ServerKex = otherInit
-	}
-
 	magics := handshakeMagics{
 		clientVersion: t.clientVersion,
 		serverVersion: t.serverVersion,
@@ -376,6 +378,10 @@ func (t *handshakeTransport) enterKeyExchangeLocked(otherInitPacket []byte) erro
 		magics.clientKexInit = myInitPacket
 		magics.serverKexInit = otherInitPacket
 	}
+	if t.config.ConnLog != nil && t.config.ConnLog.HASSHServer == nil {
+		t.config.ConnLog.HASSH = ClientHASSH(clientInit)
+		t.config.ConnLog.HASSHServer = ServerHASSH(serverInit)
+	}
 
 	algs, err := findAgreedAlgorithms(clientInit, serverInit)
 	if err != nil {
@@ -0,0 +1,42 @@
+package ssh
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"strings"
+)
+
+// HASSH is a HASSH fingerprint of a KEXINIT message: the key exchange,
+// cipher, MAC and compression algorithm lists for one direction, each
+// comma-separated in the order they were sent, joined with ";", and the MD5
+// hash of that string. The HASSH of a client uses its client-to-server
+// lists; the HASSHServer of a server uses its server-to-client lists.
+type HASSH struct {
+	// String is the HASSH algorithms string, e.g.
+	// "curve25519-sha256,diffie-hellman-group14-sha1;aes128-ctr;hmac-sha2-256;none".
+	String string `json:"string"`
+
+	// Hash is the hex-encoded MD5 hash of String.
+	Hash string `json:"hash"`
+}
+
+func newHASSH(kex, ciphers, macs, compression []string) *HASSH {
+	s := strings.Join([]string{
+		strings.Join(kex, ","),
+		strings.Join(ciphers, ","),
+		strings.Join(macs, ","),
+		strings.Join(compression, ","),
+	}, ";")
+	hash := md5.Sum([]byte(s))
+	return &HASSH{String: s, Hash: hex.EncodeToString(hash[:])}
+}
+
+// ClientHASSH returns the HASSH fingerprint of a client's KEXINIT.
+func ClientHASSH(kex *KexInitMsg) *HASSH {
+	return newHASSH(kex.KexAlgos, kex.CiphersClientServer, kex.MACsClientServer, kex.CompressionClientServer)
+}
+
+// ServerHASSH returns the HASSHServer fingerprint of a server's KEXINIT.
+func ServerHASSH(kex *KexInitMsg) *HASSH {
+	return newHASSH(kex.KexAlgos, kex.CiphersServerClient, kex.MACsServerClient, kex.CompressionServerClient)
+}
@@ -0,0 +1,35 @@
+package ssh
+
+import "testing"
+
+func TestHASSH(t *testing.T) {
+	kex := &KexInitMsg{
+		KexAlgos:                []string{"curve25519-sha256", "diffie-hellman-group14-sha1"},
+		ServerHostKeyAlgos:      []string{"ssh-ed25519", "ssh-rsa"},
+		CiphersClientServer:     []string{"aes128-ctr", "aes256-ctr"},
+		CiphersServerClient:     []string{"aes256-ctr"},
+		MACsClientServer:        []string{"hmac-sha2-256", "hmac-sha1"},
+		MACsServerClient:        []string{"hmac-sha1"},
+		CompressionClientServer: []string{"none", "zlib@openssh.com"},
+		CompressionServerClient: []string{"none"},
+	}
+	tests := []struct {
+		name     string
+		hassh    *HASSH
+		expected HASSH
+	}{
+		{"client", ClientHASSH(kex), HASSH{
+			String: "curve25519-sha256,diffie-hellman-group14-sha1;aes128-ctr,aes256-ctr;hmac-sha2-256,hmac-sha1;none,zlib@openssh.com",
+			Hash:   "204338317416cc633fdc19e43cfaee8f",
+		}},
+		{"server", ServerHASSH(kex), HASSH{
+			String: "curve25519-sha256,diffie-hellman-group14-sha1;aes256-ctr;hmac-sha1;none",
+			Hash:   "fb63e6327e76feeab307abca351fe3f9",
+		}},
+	}
+	for _, test := range tests {
+		if *test.hassh != test.expected {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, *test.hassh)
+		}
+	}
+}
@@ -163,6 +163,9 @@ type kexAlgorithm interface {
 type dhGroup struct {
 	g, p, pMinus1 *big.Int
 	JsonLog       dhGroupJsonLog
+
+	// name is the group's name in the DHGroupLog.
+	name string
 }
 type dhGroupJsonLog struct {
 	Parameters      *ztoolsKeys.DHParams  `json:"dh_params,omitempty"`
@@ -184,11 +187,13 @@ func (group *dhGroup) GetNew(keyType string) kexAlgorithm {
 	case kexAlgoDH1SHA1:
 		ret.p, _ = new(big.Int).SetString("FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7EDEE386BFB5A899FA5AE9F24117C4B1FE649286651ECE65381FFFFFFFFFFFFFFFF", 16)
 		ret.pMinus1 = new(big.Int).Sub(ret.p, bigOne)
+		ret.name = "group1"
 		break
 
 	case kexAlgoDH14SHA1:
 		ret.p, _ = new(big.Int).SetString("FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7EDEE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3BE39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF6955817183995497CEA956AE515D2261898FA051015728E5A8AACAA68FFFFFFFFFFFFFFFF", 16)
 		ret.pMinus1 = new(big.Int).Sub(ret.p, bigOne)
+		ret.name = "group14"
 		break
 
 	default:
@@ -208,6 +213,9 @@ func (group *dhGroup) diffieHellman(theirPublic, myPrivate *big.Int) (*big.Int,
 func (group *dhGroup) Client(c packetConn, randSource io.Reader, magics *handshakeMagics, config *Config) (*kexResult, error) {
 	group.JsonLog.Parameters = new(ztoolsKeys.DHParams)
 	hashFunc := crypto.SHA1
+	if config.ConnLog != nil {
+		config.ConnLog.DHGroup = &DHGroupLog{Name: group.name, PrimeBits: group.p.BitLen()}
+	}
 
 	var x *big.Int
 	for {
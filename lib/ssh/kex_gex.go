@@ -109,6 +109,15 @@ func (gex *dhGEXSHA) Client(c packetConn, randSource io.Reader, magics *handshak
 		gex.JsonLog.Parameters.Prime = kexDHGexGroup.P
 		gex.JsonLog.Parameters.Generator = kexDHGexGroup.G
 	}
+	if config.ConnLog != nil {
+		config.ConnLog.DHGroup = &DHGroupLog{
+			GroupExchange: true,
+			PrimeBits:     kexDHGexGroup.P.BitLen(),
+			MinBits:       kexDHGexRequest.MinBits,
+			PreferredBits: kexDHGexRequest.PreferedBits,
+			MaxBits:       kexDHGexRequest.MaxBits,
+		}
+	}
 
 	// reject if p's bit length < pkgConfig.GexMinBits or > pkgConfig.GexMaxBits
 	if kexDHGexGroup.P.BitLen() < int(config.GexMinBits) || kexDHGexGroup.P.BitLen() > int(config.GexMaxBits) {
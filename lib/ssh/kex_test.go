@@ -18,6 +18,12 @@ func TestKexes(t *testing.T) {
 		err    error
 	}
 
+	// The GEX bounds are the ssh module's defaults.
+	config := &Config{
+		GexMinBits:       1024,
+		GexPreferredBits: 2048,
+		GexMaxBits:       8192,
+	}
 	for name, kex := range kexAlgoMap {
 		a, b := memPipe()
 
@@ -25,12 +31,12 @@ func TestKexes(t *testing.T) {
 		c := make(chan kexResultErr, 1)
 		var magics handshakeMagics
 		go func() {
-			r, e := kex.Client(a, rand.Reader, &magics)
+			r, e := kex.Client(a, rand.Reader, &magics, config)
 			a.Close()
 			c <- kexResultErr{r, e}
 		}()
 		go func() {
-			r, e := kex.Server(b, rand.Reader, &magics, testSigners["ecdsa"])
+			r, e := kex.Server(b, rand.Reader, &magics, testSigners["ecdsa"], config)
 			b.Close()
 			s <- kexResultErr{r, e}
 		}()
@@ -48,3 +54,45 @@ func TestKexes(t *testing.T) {
 		}
 	}
 }
+
+func TestDHGroupLog(t *testing.T) {
+	// The server side of the group exchange always sends a 1536-bit prime.
+	for _, test := range []struct {
+		minBits uint
+		fails   bool
+	}{
+		{1024, false},
+		{2048, true},
+	} {
+		a, b := memPipe()
+		config := &Config{
+			ConnLog:          new(HandshakeLog),
+			GexMinBits:       test.minBits,
+			GexPreferredBits: 2048,
+			GexMaxBits:       8192,
+		}
+		var magics handshakeMagics
+		go func() {
+			kexAlgoMap[kexAlgoDHGEXSHA256].GetNew(kexAlgoDHGEXSHA256).Server(b, rand.Reader, &magics, testSigners["ecdsa"], config)
+			b.Close()
+		}()
+		_, err := kexAlgoMap[kexAlgoDHGEXSHA256].GetNew(kexAlgoDHGEXSHA256).Client(a, rand.Reader, &magics, config)
+		a.Close()
+		if (err != nil) != test.fails {
+			t.Errorf("min %d bits: unexpected error %v", test.minBits, err)
+		}
+		expected := &DHGroupLog{GroupExchange: true, PrimeBits: 1536, MinBits: uint32(test.minBits), PreferredBits: 2048, MaxBits: 8192}
+		if !reflect.DeepEqual(config.ConnLog.DHGroup, expected) {
+			t.Errorf("min %d bits: expected %+v, got %+v", test.minBits, expected, config.ConnLog.DHGroup)
+		}
+	}
+
+	config := &Config{ConnLog: new(HandshakeLog)}
+	a, b := memPipe()
+	a.Close()
+	b.Close()
+	kexAlgoMap[kexAlgoDH1SHA1].GetNew(kexAlgoDH1SHA1).Client(a, rand.Reader, new(handshakeMagics), config)
+	if expected := (&DHGroupLog{Name: "group1", PrimeBits: 1024}); !reflect.DeepEqual(config.ConnLog.DHGroup, expected) {
+		t.Errorf("expected %+v, got %+v", expected, config.ConnLog.DHGroup)
+	}
+}
@@ -6,7 +6,6 @@ package ssh
 
 import (
 	"bytes"
-	"crypto/dsa"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -17,6 +16,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/zmap/zcrypto/dsa"
 	"github.com/zmap/zgrab2/lib/ssh/testdata"
 	"golang.org/x/crypto/ed25519"
 )
@@ -22,10 +22,77 @@ type HandshakeLog struct {
 	ClientID           *EndpointId  `json:"client_id,omitempty"`
 	ServerKex          *KexInitMsg  `json:"server_key_exchange,omitempty"`
 	ClientKex          *KexInitMsg  `json:"client_key_exchange,omitempty"`
+	HASSH              *HASSH       `json:"hassh,omitempty"`
+	HASSHServer        *HASSH       `json:"hassh_server,omitempty"`
 	AlgorithmSelection *Algorithms  `json:"algorithm_selection,omitempty"`
 	DHKeyExchange      kexAlgorithm `json:"key_exchange,omitempty"`
-	UserAuth           []string     `json:"userauth,omitempty"`
+	DHGroup            *DHGroupLog  `json:"dh_group,omitempty"`
 	Crypto             *kexResult   `json:"crypto,omitempty"`
+
+	// AuthMethods is the server's answer to the none authentication
+	// request, if DontAuthenticate is set.
+	AuthMethods *AuthMethodsLog `json:"auth_methods,omitempty"`
+
+	// UserAuth holds the same methods as AuthMethods.Methods.
+	//
+	// Deprecated: use AuthMethods, which also records whether the request
+	// succeeded. UserAuth is kept for the consumers of earlier output.
+	UserAuth []string `json:"userauth,omitempty"`
+
+	// RawClientBanner is the hex of the bytes sent in place of the client's
+	// identification line, if the ClientConfig's RawClientBanner is set.
+	RawClientBanner string `json:"raw_client_banner,omitempty"`
+}
+
+// AuthMethodsLog is the outcome of the "none" authentication request sent
+// when DontAuthenticate is set (--userauth), which asks the server which
+// authentication methods it allows without attempting any of them.
+type AuthMethodsLog struct {
+	// ServiceAccepted is true if the server accepted the ssh-userauth
+	// service request that precedes the none request.
+	ServiceAccepted bool `json:"service_accepted"`
+
+	// Methods lists the methods the server allows, from its
+	// SSH_MSG_USERAUTH_FAILURE response, in the order sent, e.g.
+	// ["publickey", "password", "keyboard-interactive"]. It is null if no
+	// such response was received.
+	Methods []string `json:"methods"`
+
+	// PartialSuccess is the partial success flag of the failure response.
+	PartialSuccess bool `json:"partial_success,omitempty"`
+
+	// NoneAccepted is true if the server accepted the none request, i.e.
+	// it allows logging in without authenticating.
+	NoneAccepted bool `json:"none_accepted,omitempty"`
+
+	// Error describes how the exchange failed, e.g. if the server closed
+	// the connection on the none request.
+	Error string `json:"error,omitempty"`
+}
+
+// DHGroupLog describes the finite field Diffie-Hellman group of a
+// diffie-hellman-group* or diffie-hellman-group-exchange-* key exchange, so
+// that weak (e.g. 1024-bit) groups can be spotted without decoding the prime.
+type DHGroupLog struct {
+	// Name is the name of the fixed group (group1 or group14), or empty if
+	// the server chose the group in a group exchange.
+	Name string `json:"name,omitempty"`
+
+	// GroupExchange is true if the server chose the group in a group
+	// exchange (RFC 4419).
+	GroupExchange bool `json:"group_exchange"`
+
+	// PrimeBits is the size of the group's prime modulus, in bits. For a
+	// group exchange it is recorded even if the prime is outside the
+	// requested range and the handshake was aborted.
+	PrimeBits int `json:"prime_bits"`
+
+	// MinBits, PreferredBits and MaxBits are the prime sizes requested in
+	// a group exchange (--gex-min-bits, --gex-preferred-bits and
+	// --gex-max-bits).
+	MinBits       uint32 `json:"min_bits,omitempty"`
+	PreferredBits uint32 `json:"preferred_bits,omitempty"`
+	MaxBits       uint32 `json:"max_bits,omitempty"`
 }
 
 type EndpointId struct {
@@ -70,35 +70,48 @@ type KexInitMsg struct {
 	Reserved                uint32
 }
 
+// JsonKexInitMsg is the JSON encoding of a KexInitMsg. The algorithm lists
+// keep the order in which they were sent (the sender's preference, which
+// fingerprints such as HASSH depend on), and are always present, as empty
+// arrays if the sender sent empty lists.
 type JsonKexInitMsg struct {
 	Cookie                  []byte   `json:"cookie,omitempty"`
-	KexAlgos                []string `json:"kex_algorithms,omitempty"`
-	ServerHostKeyAlgos      []string `json:"host_key_algorithms,omitempty"`
-	CiphersClientServer     []string `json:"client_to_server_ciphers,omitempty"`
-	CiphersServerClient     []string `json:"server_to_client_ciphers,omitempty"`
-	MACsClientServer        []string `json:"client_to_server_macs,omitempty"`
-	MACsServerClient        []string `json:"server_to_client_macs,omitempty"`
-	CompressionClientServer []string `json:"client_to_server_compression,omitempty"`
-	CompressionServerClient []string `json:"server_to_client_compression,omitempty"`
-	LanguagesClientServer   []string `json:"client_to_server_languages,omitempty"`
-	LanguagesServerClient   []string `json:"server_to_client_languages,omitempty"`
+	KexAlgos                []string `json:"kex_algorithms"`
+	ServerHostKeyAlgos      []string `json:"host_key_algorithms"`
+	CiphersClientServer     []string `json:"client_to_server_ciphers"`
+	CiphersServerClient     []string `json:"server_to_client_ciphers"`
+	MACsClientServer        []string `json:"client_to_server_macs"`
+	MACsServerClient        []string `json:"server_to_client_macs"`
+	CompressionClientServer []string `json:"client_to_server_compression"`
+	CompressionServerClient []string `json:"server_to_client_compression"`
+	LanguagesClientServer   []string `json:"client_to_server_languages"`
+	LanguagesServerClient   []string `json:"server_to_client_languages"`
 	FirstKexFollows         bool     `json:"first_kex_follows"`
 	Reserved                uint32   `json:"reserved"`
 }
 
+// jsonNameList returns list, or an empty list if it is nil, so that it is
+// encoded as [] rather than null.
+func jsonNameList(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
 func (kex *KexInitMsg) MarshalJSON() ([]byte, error) {
 	temp := JsonKexInitMsg{
 		Cookie:                  kex.Cookie[:],
-		KexAlgos:                kex.KexAlgos,
-		ServerHostKeyAlgos:      kex.ServerHostKeyAlgos,
-		CiphersClientServer:     kex.CiphersClientServer,
-		CiphersServerClient:     kex.CiphersServerClient,
-		MACsClientServer:        kex.MACsClientServer,
-		MACsServerClient:        kex.MACsServerClient,
-		CompressionClientServer: kex.CompressionClientServer,
-		CompressionServerClient: kex.CompressionServerClient,
-		LanguagesClientServer:   kex.LanguagesClientServer,
-		LanguagesServerClient:   kex.LanguagesServerClient,
+		KexAlgos:                jsonNameList(kex.KexAlgos),
+		ServerHostKeyAlgos:      jsonNameList(kex.ServerHostKeyAlgos),
+		CiphersClientServer:     jsonNameList(kex.CiphersClientServer),
+		CiphersServerClient:     jsonNameList(kex.CiphersServerClient),
+		MACsClientServer:        jsonNameList(kex.MACsClientServer),
+		MACsServerClient:        jsonNameList(kex.MACsServerClient),
+		CompressionClientServer: jsonNameList(kex.CompressionClientServer),
+		CompressionServerClient: jsonNameList(kex.CompressionServerClient),
+		LanguagesClientServer:   jsonNameList(kex.LanguagesClientServer),
+		LanguagesServerClient:   jsonNameList(kex.LanguagesServerClient),
 		FirstKexFollows:         kex.FirstKexFollows,
 		Reserved:                kex.Reserved,
 	}
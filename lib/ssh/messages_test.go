@@ -6,6 +6,7 @@ package ssh
 
 import (
 	"bytes"
+	"encoding/json"
 	"math/big"
 	"math/rand"
 	"reflect"
@@ -286,3 +287,38 @@ func BenchmarkUnmarshalKexDHInitMsg(b *testing.B) {
 		Unmarshal(_kexDHInit, m)
 	}
 }
+
+func TestKexInitMsgJSON(t *testing.T) {
+	kex := &KexInitMsg{
+		KexAlgos:                []string{"curve25519-sha256", "diffie-hellman-group14-sha1"},
+		ServerHostKeyAlgos:      []string{"ssh-ed25519", "rsa-sha2-512", "ssh-rsa"},
+		CiphersClientServer:     []string{"chacha20-poly1305@openssh.com", "aes128-ctr"},
+		CiphersServerClient:     []string{"chacha20-poly1305@openssh.com", "aes128-ctr"},
+		MACsClientServer:        []string{"hmac-sha2-256", "hmac-sha1"},
+		MACsServerClient:        []string{"hmac-sha2-256", "hmac-sha1"},
+		CompressionClientServer: []string{"none", "zlib@openssh.com"},
+		CompressionServerClient: []string{"none", "zlib@openssh.com"},
+		FirstKexFollows:         true,
+	}
+	var decoded KexInitMsg
+	if err := Unmarshal(Marshal(kex), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := json.Marshal(&decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(encoded, &result); err != nil {
+		t.Fatal(err)
+	}
+	if hostKeys := result["host_key_algorithms"].([]interface{}); len(hostKeys) != 3 || hostKeys[0] != "ssh-ed25519" || hostKeys[2] != "ssh-rsa" {
+		t.Errorf("host key algorithms not in order: %v", hostKeys)
+	}
+	if languages, ok := result["client_to_server_languages"].([]interface{}); !ok || len(languages) != 0 {
+		t.Errorf("expected an empty list of languages, got %v", result["client_to_server_languages"])
+	}
+	if result["first_kex_follows"] != true {
+		t.Errorf("expected first_kex_follows, got %v", result["first_kex_follows"])
+	}
+}
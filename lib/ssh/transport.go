@@ -289,12 +289,24 @@ func exchangeVersions(rw io.ReadWriter, versionLine []byte) (them []byte, err er
 	return them, err
 }
 
+// exchangeRawVersion sends raw exactly as given (with no line ending added)
+// in place of a version line, and returns the other side's version line. If
+// the other side ends the connection before sending a whole line, whatever
+// it sent is returned along with the error.
+func exchangeRawVersion(rw io.ReadWriter, raw []byte) ([]byte, error) {
+	if _, err := rw.Write(raw); err != nil {
+		return nil, err
+	}
+	return readVersion(rw)
+}
+
 // maxVersionStringBytes is the maximum number of bytes that we'll
 // accept as a version string. RFC 4253 section 4.2 limits this at 255
 // chars
 const maxVersionStringBytes = 255
 
-// Read version string as specified by RFC 4253, section 4.2.
+// Read version string as specified by RFC 4253, section 4.2. If reading
+// fails, the part of the line read so far is returned with the error.
 func readVersion(r io.Reader) ([]byte, error) {
 	versionString := make([]byte, 0, 64)
 	var ok bool
@@ -303,7 +315,7 @@ func readVersion(r io.Reader) ([]byte, error) {
 	for len(versionString) < maxVersionStringBytes {
 		_, err := io.ReadFull(r, buf[:])
 		if err != nil {
-			return nil, err
+			return versionString, err
 		}
 		// The RFC says that the version should be terminated with \r\n
 		// but several SSH servers actually only send a \n.
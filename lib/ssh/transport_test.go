@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"crypto/rand"
 	"encoding/binary"
+	"io"
 	"strings"
 	"testing"
 )
@@ -70,6 +71,42 @@ func TestExchangeVersions(t *testing.T) {
 	}
 }
 
+// splitReadWriter reads from r and writes to w.
+type splitReadWriter struct {
+	r io.Reader
+	w bytes.Buffer
+}
+
+func (rw *splitReadWriter) Read(p []byte) (int, error) {
+	return rw.r.Read(p)
+}
+
+func (rw *splitReadWriter) Write(p []byte) (int, error) {
+	return rw.w.Write(p)
+}
+
+func TestExchangeRawVersion(t *testing.T) {
+	raw := []byte("SSH-9.9-\x00\xff" + strings.Repeat("A", 300))
+	rw := &splitReadWriter{r: bytes.NewBufferString("SSH-2.0-bla\r\n")}
+	them, err := exchangeRawVersion(rw, raw)
+	if err != nil {
+		t.Errorf("exchangeRawVersion: %v", err)
+	}
+	if want := "SSH-2.0-bla"; string(them) != want {
+		t.Errorf("got %q want %q for their version", them, want)
+	}
+	if !bytes.Equal(rw.w.Bytes(), raw) {
+		t.Errorf("expected the raw banner to be sent as-is, got %q", rw.w.Bytes())
+	}
+
+	// If the server hangs up mid-line, the partial line is returned.
+	rw = &splitReadWriter{r: bytes.NewBufferString("Protocol mismatch.")}
+	them, err = exchangeRawVersion(rw, raw)
+	if err != io.EOF || string(them) != "Protocol mismatch." {
+		t.Errorf("expected the partial line and EOF, got %q, %v", them, err)
+	}
+}
+
 type closerBuffer struct {
 	bytes.Buffer
 }
@@ -0,0 +1,45 @@
+package zgrab2
+
+import "sort"
+
+// Version is the zgrab2 version recorded in each result's _meta with
+// --result-meta. Release builds set it with
+// -ldflags "-X github.com/zmap/zgrab2.Version=...".
+var Version = "dev"
+
+// ResultSchemaVersion is the version of the output format. It is incremented
+// whenever a change to the framework's fields or a module's result could
+// break consumers of the output.
+const ResultSchemaVersion = 1
+
+// ResultMeta describes how a result was produced, so that consumers of
+// long-lived outputs can handle format changes.
+type ResultMeta struct {
+	// SchemaVersion is the ResultSchemaVersion of the zgrab2 that produced
+	// the result.
+	SchemaVersion int `json:"schema_version"`
+
+	// ZGrab2Version is the Version of the zgrab2 that produced the result.
+	ZGrab2Version string `json:"zgrab2_version"`
+
+	// Modules lists the distinct modules (protocols) that produced the
+	// result's data, sorted by name, e.g. ["http", "ssh"].
+	Modules []string `json:"modules,omitempty"`
+}
+
+// newResultMeta returns the ResultMeta of a result with the given responses.
+func newResultMeta(responses map[string]ScanResponse) *ResultMeta {
+	meta := &ResultMeta{
+		SchemaVersion: ResultSchemaVersion,
+		ZGrab2Version: Version,
+	}
+	seen := make(map[string]bool)
+	for _, response := range responses {
+		if response.Protocol != "" && !seen[response.Protocol] {
+			seen[response.Protocol] = true
+			meta.Modules = append(meta.Modules, response.Protocol)
+		}
+	}
+	sort.Strings(meta.Modules)
+	return meta
+}
@@ -0,0 +1,29 @@
+package zgrab2
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResultMeta(t *testing.T) {
+	responses := map[string]ScanResponse{
+		"http80":   {Status: SCAN_SUCCESS, Protocol: "http"},
+		"http8080": {Status: SCAN_SUCCESS, Protocol: "http"},
+		"ssh":      {Status: SCAN_SUCCESS, Protocol: "ssh"},
+	}
+	meta := newResultMeta(responses)
+	expected := &ResultMeta{SchemaVersion: ResultSchemaVersion, ZGrab2Version: Version, Modules: []string{"http", "ssh"}}
+	if !reflect.DeepEqual(meta, expected) {
+		t.Errorf("expected %+v, got %+v", expected, meta)
+	}
+
+	encoded, err := json.Marshal(&Grab{Meta: meta, IP: "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(encoded), `{"_meta":{"schema_version":1,"zgrab2_version":"dev","modules":["http","ssh"]},`) {
+		t.Errorf("unexpected encoding %s", encoded)
+	}
+}
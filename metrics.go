@@ -0,0 +1,74 @@
+package zgrab2
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+// Prometheus metrics updated by RunScanner and the Monitor, and exported on
+// --metrics-addr.
+var (
+	scansAttempted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "zgrab2",
+		Name:      "scans_attempted_total",
+		Help:      "Number of scans started, per module.",
+	}, []string{"module"})
+
+	scansSucceeded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "zgrab2",
+		Name:      "scans_succeeded_total",
+		Help:      "Number of scans that completed without error, per module.",
+	}, []string{"module"})
+
+	scansFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "zgrab2",
+		Name:      "scans_failed_total",
+		Help:      "Number of scans that returned an error, per module.",
+	}, []string{"module"})
+
+	scansInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "zgrab2",
+		Name:      "scans_in_flight",
+		Help:      "Number of scans currently running, per module.",
+	}, []string{"module"})
+
+	scanDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "zgrab2",
+		Name:      "scan_duration_seconds",
+		Help:      "Time taken by each scan, per module.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{"module"})
+)
+
+func init() {
+	prometheus.MustRegister(scansAttempted, scansSucceeded, scansFailed, scansInFlight, scanDuration)
+}
+
+// startMetricsServer starts an HTTP server that exports the Prometheus metrics
+// at /metrics on the given address.
+func startMetricsServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("could not run metrics server: %s", err.Error())
+		}
+	}()
+	return server
+}
+
+// stopMetricsServer gracefully shuts down a server started with
+// startMetricsServer, allowing in-progress scrapes a few seconds to finish.
+func stopMetricsServer(server *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Warnf("error shutting down metrics server: %s", err.Error())
+	}
+}
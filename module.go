@@ -1,6 +1,9 @@
 package zgrab2
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // Scanner is an interface that represents all functions necessary to run a scan
 type Scanner interface {
@@ -20,8 +23,10 @@ type Scanner interface {
 	// Protocol returns the protocol identifier for the scan.
 	Protocol() string
 
-	// Scan connects to a host. The result should be JSON-serializable
-	Scan(t ScanTarget) (ScanStatus, interface{}, error)
+	// Scan connects to a host. The result should be JSON-serializable.
+	// The connections opened with t are closed when ctx is done; a scan that
+	// makes several connections should also stop when ctx is done.
+	Scan(ctx context.Context, t ScanTarget) (ScanStatus, interface{}, error)
 }
 
 // ScanResponse is the result of a scan on a single host
@@ -36,6 +41,24 @@ type ScanResponse struct {
 	Result    interface{} `json:"result,omitempty"`
 	Timestamp string      `json:"timestamp,omitempty"`
 	Error     *string     `json:"error,omitempty"`
+
+	// Timing is the time taken to connect, handshake and scan.
+	Timing *Timing `json:"timing,omitempty"`
+
+	// Wire is the raw traffic of the scan's connections, if --capture-wire
+	// is set.
+	Wire *WireCapture `json:"wire,omitempty"`
+
+	// ConnectedAddr is the remote address (IP:port) of the scan's first
+	// connection, which may differ from the input (e.g. for a domain with
+	// several addresses), if it opened one with ScanTarget.Open or
+	// ScanTarget.OpenUDP (or recorded one with ScanTarget.RecordRemoteAddr).
+	ConnectedAddr string `json:"connected_addr,omitempty"`
+
+	// Port is the port from --fallback-ports that the scan was retried on
+	// and answered, after the connection to the module's port was refused.
+	// It is absent if the scan ran on the module's port.
+	Port uint `json:"port,omitempty"`
 }
 
 // ScanModule is an interface which represents a module that the framework can
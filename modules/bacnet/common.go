@@ -21,6 +21,18 @@ const (
 	NPDU_FLAG_EXPECTING_RESPONSE byte = 0x04
 )
 
+// APDU PDU type constants (the high nibble of APDU.TypeAndFlags)
+const (
+	APDU_TYPE_CONFIRMED_REQUEST byte = 0x00
+	APDU_TYPE_COMPLEX_ACK       byte = 0x03
+)
+
+// APDU header flag constants (the low nibble of APDU.TypeAndFlags)
+const (
+	APDU_FLAG_SEGMENTED    byte = 0x08
+	APDU_FLAG_MORE_FOLLOWS byte = 0x04
+)
+
 // APDU Server Choice constants
 const (
 	SERVER_CHOICE_READ_PROPERTY byte = 0x0c
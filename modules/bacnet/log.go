@@ -13,6 +13,10 @@ type Log struct {
 	ModelName                   string `json:"model_name,omitempty"`
 	Description                 string `json:"description,omitempty"`
 	Location                    string `json:"location,omitempty"`
+
+	// Segmented is set if any response was a segmented ComplexACK. Only the
+	// first segment of such a response is decoded.
+	Segmented bool `json:"segmented,omitempty"`
 }
 
 func (log *Log) sendReadProperty(c net.Conn, oid ObjectID, pid PropertyID) ([]byte, error, bool) {
@@ -24,12 +28,16 @@ func (log *Log) sendReadProperty(c net.Conn, oid ObjectID, pid PropertyID) ([]by
 	if err := SendVLC(c, b); err != nil {
 		return nil, err, false
 	}
+	var apdu *APDU
 	var body []byte
 	var isBACNet bool
-	_, _, _, body, err, isBACNet = ReadVLC(c)
+	_, _, apdu, body, err, isBACNet = ReadVLC(c)
 	if err != nil {
 		return nil, err, isBACNet
 	}
+	if apdu.IsSegmented() {
+		log.Segmented = true
+	}
 	r := new(ReadProperty)
 	if body, err = r.Unmarshal(body); err != nil {
 		return nil, err, isBACNet
@@ -22,7 +22,11 @@ type APDU struct {
 	TypeAndFlags byte              `json:"type_and_flags"`
 	SegmentSizes SegmentParameters `json:"segment_sizes"`
 	InvokeID     byte              `json:"invoke_id"`
-	ServerChoice byte              `json:"server_choice"`
+	// SequenceNumber and ProposedWindowSize are only present in segmented
+	// ComplexACK PDUs.
+	SequenceNumber     byte `json:"sequence_number,omitempty"`
+	ProposedWindowSize byte `json:"proposed_window_size,omitempty"`
+	ServerChoice       byte `json:"server_choice"`
 }
 
 type Frame struct {
@@ -32,6 +36,23 @@ type Frame struct {
 	Payload interface{} `json:"payload,omitempty"`
 }
 
+// PDUType returns the APDU's PDU type (e.g. APDU_TYPE_COMPLEX_ACK).
+func (apdu *APDU) PDUType() byte {
+	return apdu.TypeAndFlags >> 4
+}
+
+// IsSegmented returns true if the APDU is a ComplexACK that carries only one
+// segment of a segmented message.
+func (apdu *APDU) IsSegmented() bool {
+	return apdu.PDUType() == APDU_TYPE_COMPLEX_ACK && apdu.TypeAndFlags&APDU_FLAG_SEGMENTED != 0
+}
+
+// MoreFollows returns true if the APDU is a segment that is followed by more
+// segments.
+func (apdu *APDU) MoreFollows() bool {
+	return apdu.IsSegmented() && apdu.TypeAndFlags&APDU_FLAG_MORE_FOLLOWS != 0
+}
+
 const vlcLength = 4
 
 // Marshal encodes a VLC header to binary
@@ -87,6 +108,10 @@ func (apdu *APDU) Marshal() ([]byte, error) {
 		buf.WriteByte(apdu.SegmentSizes.raw)
 	}
 	buf.WriteByte(apdu.InvokeID)
+	if apdu.IsSegmented() {
+		buf.WriteByte(apdu.SequenceNumber)
+		buf.WriteByte(apdu.ProposedWindowSize)
+	}
 	buf.WriteByte(apdu.ServerChoice)
 	return buf.Bytes(), nil
 }
@@ -105,6 +130,14 @@ func (apdu *APDU) Unmarshal(b []byte) (out []byte, err error) {
 	if apdu.InvokeID, err = buf.ReadByte(); err != nil {
 		return b, errBACNetPacketTooShort
 	}
+	if apdu.IsSegmented() {
+		if apdu.SequenceNumber, err = buf.ReadByte(); err != nil {
+			return b, errBACNetPacketTooShort
+		}
+		if apdu.ProposedWindowSize, err = buf.ReadByte(); err != nil {
+			return b, errBACNetPacketTooShort
+		}
+	}
 	if apdu.ServerChoice, err = buf.ReadByte(); err != nil {
 		return b, errBACNetPacketTooShort
 	}
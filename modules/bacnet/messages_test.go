@@ -86,3 +86,23 @@ func (s *APDUSuite) TestMarshalUnmarshalAPDU(c *C) {
 	c.Check(dec, DeepEquals, &apdu)
 	c.Check(len(b), Equals, 0)
 }
+
+func (s *APDUSuite) TestMarshalUnmarshalSegmentedAPDU(c *C) {
+	apdu := APDU{
+		TypeAndFlags:       APDU_TYPE_COMPLEX_ACK<<4 | APDU_FLAG_SEGMENTED | APDU_FLAG_MORE_FOLLOWS,
+		InvokeID:           1,
+		SequenceNumber:     0,
+		ProposedWindowSize: 4,
+		ServerChoice:       SERVER_CHOICE_READ_PROPERTY,
+	}
+	b, err := apdu.Marshal()
+	c.Assert(err, IsNil)
+	c.Check(len(b), Equals, 5)
+	dec := new(APDU)
+	b, err = dec.Unmarshal(b)
+	c.Assert(err, IsNil)
+	c.Check(dec, DeepEquals, &apdu)
+	c.Check(dec.IsSegmented(), Equals, true)
+	c.Check(dec.MoreFollows(), Equals, true)
+	c.Check(len(b), Equals, 0)
+}
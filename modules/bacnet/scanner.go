@@ -5,6 +5,8 @@
 package bacnet
 
 import (
+	"context"
+
 	log "github.com/sirupsen/logrus"
 	"github.com/zmap/zgrab2"
 )
@@ -106,8 +108,10 @@ func (scanner *Scanner) Protocol() string {
 // 7. Model  name
 // 8. Description
 // 9. Location
+// If any response is a segmented ComplexACK, Segmented is set in the result and
+// only the first segment is decoded.
 // The result is a bacnet.Log, and contains any of the above.
-func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
 	conn, err := target.OpenUDP(&scanner.config.BaseFlags, &scanner.config.UDPFlags)
 	if err != nil {
 		return zgrab2.TryGetScanStatus(err), nil, err
@@ -1,9 +1,33 @@
 // Package banner provides simple banner grab and matching implementation of the zgrab2.Module.
 // It sends a customizble probe (default to "\n") and filters the results based on custom regexp (--pattern)
+//
+// The probe may instead be given as raw bytes with --probe-hex or sent
+// verbatim with --probe-string, or not sent at all with --no-probe, in which
+// case the scanner just reads whatever the server sends first. Up to
+// --read-bytes bytes are read, waiting at most --read-timeout, and recorded
+// as raw bytes, hex and a printable-ASCII rendering.
+//
+// With --probes-file, the scanner instead tries an ordered list of probes,
+// like nmap's service probes: each is sent on a fresh connection, and the
+// first whose response is non-empty and matches its pattern is recorded as
+// matched_probe (with --all-probes, every probe is sent). The file holds a
+// JSON array of probe definitions:
+//
+//	[{"name": "http", "string": "GET / HTTP/1.0\r\n\r\n", "pattern": "^HTTP/"},
+//	 {"name": "null", "hex": ""}]
+//
+// Each probe's payload is given by "hex" or "string" (neither means the
+// probe sends nothing), and its "pattern" defaults to --pattern. The
+// per-probe results are recorded under probes.
+//
+// With --connect-only, no probe is sent and nothing is read: the scanner
+// only opens the TCP connection, records whether it was accepted and how
+// long that took under connect, and closes it.
 
 package banner
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -12,7 +36,10 @@ import (
 	"net"
 	"regexp"
 	"strconv"
+	"syscall"
 	"encoding/hex"
+	"encoding/json"
+	"time"
 
 	"github.com/zmap/zgrab2"
 )
@@ -26,6 +53,20 @@ type Flags struct {
 	UseTLS    bool   `long:"tls" description:"Sends probe with TLS connection. Loads TLS module command options. "`
 	MaxTries  int    `long:"max-tries" default:"1" description:"Number of tries for timeouts and connection errors before giving up. Includes making TLS connection if enabled."`
 	Hex       bool   `long:"hex" description:"Store banner value in hex. "`
+
+	// ProbeHex and ProbeString are alternatives to --probe and --probe-file.
+	ProbeHex    string `long:"probe-hex" description:"Probe to send to the server, as hex (e.g. 0d0a). Mutually exclusive with --probe, --probe-file and --probe-string"`
+	ProbeString string `long:"probe-string" description:"Probe to send to the server verbatim, without unescaping. Mutually exclusive with --probe, --probe-file and --probe-hex"`
+	NoProbe     bool   `long:"no-probe" description:"Don't send a probe; just read whatever the server sends"`
+
+	ReadBytes   int           `long:"read-bytes" default:"524288" description:"Maximum number of bytes to read from the server"`
+	ReadTimeout time.Duration `long:"read-timeout" default:"0" description:"Maximum time to wait for the server's response (0 uses the connection timeout)"`
+
+	// ProbesFile and AllProbes configure multiple probes per target.
+	ProbesFile string `long:"probes-file" description:"Read an ordered list of JSON probe definitions from file, trying each on a fresh connection. Mutually exclusive with the other probe flags"`
+	AllProbes  bool   `long:"all-probes" description:"With --probes-file, send every probe rather than stopping at the first match"`
+
+	ConnectOnly bool `long:"connect-only" description:"Only open the TCP connection and record whether it was accepted, refused or timed out, then close it"`
 	zgrab2.TLSFlags
 }
 
@@ -38,12 +79,85 @@ type Scanner struct {
 	config *Flags
 	regex  *regexp.Regexp
 	probe  []byte
+	probes []*probeDefinition
+}
+
+// probeDefinition is a single entry of a --probes-file.
+type probeDefinition struct {
+	Name    string `json:"name"`
+	Hex     string `json:"hex"`
+	String  string `json:"string"`
+	Pattern string `json:"pattern"`
+
+	payload []byte
+	regex   *regexp.Regexp
+}
+
+// ProbeResult holds the response to a single probe from a --probes-file.
+type ProbeResult struct {
+	Name      string `json:"name"`
+	Length    int    `json:"length"`
+	Raw       []byte `json:"raw,omitempty"`
+	Hex       string `json:"hex,omitempty"`
+	Printable string `json:"printable,omitempty"`
+
+	// Matched is true if the response was non-empty and matched the probe's
+	// pattern.
+	Matched bool `json:"matched"`
+
+	// Error is the connection or read error, if any.
+	Error string `json:"error,omitempty"`
 }
 
 // ScanResults instances are returned by the module's Scan function.
 type Results struct {
 	Banner string `json:"banner,omitempty"`
 	Length int    `json:"length,omitempty"`
+
+	// Raw is the exact response from the server.
+	Raw []byte `json:"raw,omitempty"`
+
+	// Hex is the response encoded as hex.
+	Hex string `json:"hex,omitempty"`
+
+	// Printable is the response with every byte that is not printable ASCII
+	// replaced by '.'.
+	Printable string `json:"printable,omitempty"`
+
+	// MatchedProbe is the name of the first probe from --probes-file whose
+	// response matched; the fields above then hold its response.
+	MatchedProbe string `json:"matched_probe,omitempty"`
+
+	// Probes holds the results of each probe sent from --probes-file.
+	Probes []ProbeResult `json:"probes,omitempty"`
+
+	// Connect holds the outcome of the connection, with --connect-only.
+	Connect *ConnectResult `json:"connect,omitempty"`
+}
+
+// The outcomes of a --connect-only connection attempt.
+const (
+	ConnectSuccess     = "success"
+	ConnectRefused     = "refused"
+	ConnectTimeout     = "timeout"
+	ConnectUnreachable = "unreachable"
+	ConnectReset       = "reset"
+	ConnectError       = "error"
+)
+
+// ConnectResult is the outcome of a --connect-only connection attempt.
+type ConnectResult struct {
+	// Outcome is one of ConnectSuccess, ConnectRefused (the port is closed),
+	// ConnectTimeout (the port is probably filtered), ConnectUnreachable (an
+	// ICMP unreachable was received), ConnectReset or ConnectError.
+	Outcome string `json:"outcome"`
+
+	// LatencyMicroseconds is the time from starting to connect until the
+	// connection was established or failed.
+	LatencyMicroseconds int64 `json:"latency_us"`
+
+	// Error is the connection error, if any.
+	Error string `json:"error,omitempty"`
 }
 
 // RegisterModule is called by modules/banner.go to register the scanner.
@@ -91,6 +205,24 @@ func (f *Flags) Validate(args []string) error {
 		log.Fatal("Cannot set both --probe and --probe-file")
 		return zgrab2.ErrInvalidArguments
 	}
+	probes := 0
+	for _, set := range []bool{f.Probe != "\\n" || f.ProbeFile != "", f.ProbeHex != "", f.ProbeString != "", f.NoProbe, f.ProbesFile != ""} {
+		if set {
+			probes++
+		}
+	}
+	if probes > 1 {
+		log.Fatal("At most one of --probe / --probe-file, --probe-hex, --probe-string, --no-probe and --probes-file may be set")
+		return zgrab2.ErrInvalidArguments
+	}
+	if f.ReadBytes <= 0 {
+		log.Fatal("--read-bytes must be positive")
+		return zgrab2.ErrInvalidArguments
+	}
+	if f.ConnectOnly && (f.UseTLS || f.ProbesFile != "") {
+		log.Fatal("--connect-only cannot be combined with --tls or --probes-file")
+		return zgrab2.ErrInvalidArguments
+	}
 	return nil
 }
 
@@ -110,7 +242,22 @@ func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
 	f, _ := flags.(*Flags)
 	scanner.config = f
 	scanner.regex = regexp.MustCompile(scanner.config.Pattern)
-	if len(f.ProbeFile) != 0 {
+	if len(f.ProbesFile) != 0 {
+		if scanner.probes, err = loadProbes(f.ProbesFile, scanner.regex); err != nil {
+			log.Fatalf("Failed to load probes file: %v", err)
+			return zgrab2.ErrInvalidArguments
+		}
+	} else if f.NoProbe {
+		scanner.probe = nil
+	} else if len(f.ProbeHex) != 0 {
+		scanner.probe, err = hex.DecodeString(f.ProbeHex)
+		if err != nil {
+			log.Fatalf("Invalid --probe-hex: %v", err)
+			return zgrab2.ErrInvalidArguments
+		}
+	} else if len(f.ProbeString) != 0 {
+		scanner.probe = []byte(f.ProbeString)
+	} else if len(f.ProbeFile) != 0 {
 		scanner.probe, err = ioutil.ReadFile(f.ProbeFile)
 		if err != nil {
 			log.Fatal("Failed to open probe file")
@@ -129,7 +276,67 @@ func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
 
 var NoMatchError = errors.New("pattern did not match")
 
-func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+// loadProbes reads the JSON probe definitions from file, compiling their
+// payloads and patterns; probes without a pattern use defaultRegex.
+func loadProbes(file string, defaultRegex *regexp.Regexp) ([]*probeDefinition, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var probes []*probeDefinition
+	if err := json.Unmarshal(data, &probes); err != nil {
+		return nil, err
+	}
+	if len(probes) == 0 {
+		return nil, errors.New("no probes defined")
+	}
+	for i, probe := range probes {
+		if probe.Name == "" {
+			probe.Name = strconv.Itoa(i)
+		}
+		if probe.Hex != "" && probe.String != "" {
+			return nil, fmt.Errorf("probe %s: at most one of hex and string may be set", probe.Name)
+		}
+		if probe.payload, err = hex.DecodeString(probe.Hex); err != nil {
+			return nil, fmt.Errorf("probe %s: invalid hex: %v", probe.Name, err)
+		}
+		if probe.String != "" {
+			probe.payload = []byte(probe.String)
+		}
+		probe.regex = defaultRegex
+		if probe.Pattern != "" {
+			if probe.regex, err = regexp.Compile(probe.Pattern); err != nil {
+				return nil, fmt.Errorf("probe %s: invalid pattern: %v", probe.Name, err)
+			}
+		}
+	}
+	return probes, nil
+}
+
+const (
+	// readBufferSize and readPollTimeout are the buffer size and per-read
+	// timeout used by zgrab2.ReadAvailable.
+	readBufferSize  = 8209
+	readPollTimeout = 10 * time.Millisecond
+)
+
+// printable returns b with every byte that is not printable ASCII replaced
+// by '.', as in the right-hand column of a hex dump.
+func printable(b []byte) string {
+	ret := make([]byte, len(b))
+	for i, c := range b {
+		if c >= 0x20 && c < 0x7f {
+			ret[i] = c
+		} else {
+			ret[i] = '.'
+		}
+	}
+	return string(ret)
+}
+
+// grab connects to the target (retrying up to --max-tries times), sends the
+// probe (if any) and returns the response.
+func (scanner *Scanner) grab(target *zgrab2.ScanTarget, probe []byte) ([]byte, error) {
 	try := 0
 	var (
 		conn    net.Conn
@@ -157,7 +364,7 @@ func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, inter
 		break
 	}
 	if err != nil {
-		return zgrab2.TryGetScanStatus(err), nil, err
+		return nil, err
 	}
 	defer conn.Close()
 
@@ -165,8 +372,13 @@ func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, inter
 	try = 0
 	for try < scanner.config.MaxTries {
 		try++
-		_, err = conn.Write(scanner.probe)
-		ret, readerr = zgrab2.ReadAvailable(conn)
+		if len(probe) > 0 {
+			_, err = conn.Write(probe)
+		}
+		if scanner.config.ReadTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(scanner.config.ReadTimeout))
+		}
+		ret, readerr = zgrab2.ReadAvailableWithOptions(conn, readBufferSize, readPollTimeout, scanner.config.ReadTimeout, scanner.config.ReadBytes)
 		if err != nil {
 			continue
 		}
@@ -176,17 +388,125 @@ func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, inter
 		break
 	}
 	if err != nil {
-		return zgrab2.TryGetScanStatus(err), nil, err
+		return nil, err
 	}
 	if readerr != io.EOF && readerr != nil {
-		return zgrab2.TryGetScanStatus(readerr), nil, readerr
+		return nil, readerr
 	}
-	var results Results
+	return ret, nil
+}
+
+// setResponse records ret as the response in results.
+func (scanner *Scanner) setResponse(results *Results, ret []byte) {
 	if scanner.config.Hex {
-		results = Results{Banner: hex.EncodeToString(ret), Length: len(ret)}
+		results.Banner = hex.EncodeToString(ret)
 	} else {
-		results = Results{Banner: string(ret), Length: len(ret)}
+		results.Banner = string(ret)
+	}
+	results.Length = len(ret)
+	results.Raw = ret
+	results.Hex = hex.EncodeToString(ret)
+	results.Printable = printable(ret)
+}
+
+// scanProbes sends each of the --probes-file probes on a fresh connection,
+// stopping at the first match unless --all-probes is set.
+func (scanner *Scanner) scanProbes(target *zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	var results Results
+	var lastErr error
+	for _, probe := range scanner.probes {
+		ret, err := scanner.grab(target, probe.payload)
+		result := ProbeResult{
+			Name:      probe.Name,
+			Length:    len(ret),
+			Raw:       ret,
+			Hex:       hex.EncodeToString(ret),
+			Printable: printable(ret),
+			Matched:   err == nil && len(ret) > 0 && probe.regex.Match(ret),
+		}
+		if err != nil {
+			result.Error = err.Error()
+			lastErr = err
+		}
+		results.Probes = append(results.Probes, result)
+		if result.Matched && results.MatchedProbe == "" {
+			results.MatchedProbe = probe.Name
+			scanner.setResponse(&results, ret)
+			if !scanner.config.AllProbes {
+				break
+			}
+		}
 	}
+	if results.MatchedProbe != "" {
+		return zgrab2.SCAN_SUCCESS, &results, nil
+	}
+	for _, result := range results.Probes {
+		if result.Error == "" {
+			// At least one probe got a response that did not match.
+			return zgrab2.SCAN_PROTOCOL_ERROR, &results, NoMatchError
+		}
+	}
+	return zgrab2.TryGetScanStatus(lastErr), &results, lastErr
+}
+
+// connectOutcome classifies a connection error as one of the Connect*
+// outcomes, as far as the OS error allows.
+func connectOutcome(err error) string {
+	if err == nil {
+		return ConnectSuccess
+	}
+	opErr, ok := err.(*net.OpError)
+	if !ok {
+		return ConnectError
+	}
+	if errno, ok := zgrab2.GetErrno(opErr); ok {
+		switch errno {
+		case syscall.ECONNREFUSED:
+			return ConnectRefused
+		case syscall.EHOSTUNREACH, syscall.ENETUNREACH:
+			return ConnectUnreachable
+		case syscall.ECONNRESET:
+			return ConnectReset
+		case syscall.ETIMEDOUT:
+			return ConnectTimeout
+		}
+	}
+	if opErr.Timeout() {
+		return ConnectTimeout
+	}
+	return ConnectError
+}
+
+// connect opens a TCP connection to the target and closes it, recording the
+// outcome and latency.
+func (scanner *Scanner) connect(target *zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	start := time.Now()
+	conn, err := target.Open(&scanner.config.BaseFlags)
+	result := &ConnectResult{
+		Outcome:             connectOutcome(err),
+		LatencyMicroseconds: int64(time.Since(start) / time.Microsecond),
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return zgrab2.TryGetScanStatus(err), &Results{Connect: result}, err
+	}
+	conn.Close()
+	return zgrab2.SCAN_SUCCESS, &Results{Connect: result}, nil
+}
+
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	if scanner.config.ConnectOnly {
+		return scanner.connect(&target)
+	}
+	if len(scanner.probes) > 0 {
+		return scanner.scanProbes(&target)
+	}
+	ret, err := scanner.grab(&target, scanner.probe)
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	var results Results
+	scanner.setResponse(&results, ret)
 	if scanner.regex.Match(ret) {
 		return zgrab2.SCAN_SUCCESS, &results, nil
 	}
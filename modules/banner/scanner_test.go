@@ -0,0 +1,163 @@
+package banner
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+func TestPrintable(t *testing.T) {
+	if s := printable([]byte("SSH-2.0\r\n\x00\xff~")); s != "SSH-2.0....~" {
+		t.Errorf("unexpected printable rendering: %q", s)
+	}
+}
+
+// scanLocal runs the scanner with the given flags against a local server
+// that records what it receives and then sends response.
+func scanLocal(t *testing.T, flags *Flags, response []byte) (*Results, []byte) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 64)
+		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, _ := conn.Read(buf)
+		received <- buf[:n]
+		conn.Write(response)
+	}()
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	flags.Timeout = 2 * time.Second
+	flags.MaxTries = 1
+	flags.Probe = "\\n"
+	if flags.ReadBytes == 0 {
+		flags.ReadBytes = 1024
+	}
+	scanner := new(Scanner)
+	if err := scanner.Init(flags); err != nil {
+		t.Fatal(err)
+	}
+	status, result, err := scanner.Scan(context.Background(), zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port})
+	if status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	return result.(*Results), <-received
+}
+
+func TestScanNoProbe(t *testing.T) {
+	results, received := scanLocal(t, &Flags{NoProbe: true, ReadTimeout: time.Second}, []byte("220 hello\r\n\x01"))
+	if len(received) != 0 {
+		t.Errorf("expected no probe, server received %q", received)
+	}
+	if !bytes.Equal(results.Raw, []byte("220 hello\r\n\x01")) || results.Hex != "3232302068656c6c6f0d0a01" || results.Printable != "220 hello..." {
+		t.Errorf("unexpected results: %+v", results)
+	}
+}
+
+func TestScanProbeHexReadBytes(t *testing.T) {
+	results, received := scanLocal(t, &Flags{ProbeHex: "0001ff", ReadBytes: 4}, []byte("abcdefgh"))
+	if !bytes.Equal(received, []byte{0x00, 0x01, 0xff}) {
+		t.Errorf("unexpected probe %x", received)
+	}
+	if string(results.Raw) != "abcd" || results.Length != 4 {
+		t.Errorf("expected 4 bytes, got %+v", results)
+	}
+}
+
+func TestScanProbesFile(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	// The server is silent until it sees its greeting.
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			buf := make([]byte, 64)
+			conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+			n, _ := conn.Read(buf)
+			if string(buf[:n]) == "HELLO\n" {
+				conn.Write([]byte("WELCOME v1.2\n"))
+			}
+			conn.Close()
+		}
+	}()
+
+	probesFile, err := ioutil.TempFile("", "probes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(probesFile.Name())
+	probesFile.WriteString(`[
+		{"name": "null"},
+		{"name": "http", "string": "GET / HTTP/1.0\r\n\r\n", "pattern": "^HTTP/"},
+		{"name": "hello", "hex": "48454c4c4f0a", "pattern": "^WELCOME"},
+		{"name": "never", "string": "x"}
+	]`)
+	probesFile.Close()
+
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	flags := &Flags{ProbesFile: probesFile.Name(), ReadBytes: 1024, ReadTimeout: 200 * time.Millisecond, MaxTries: 1, Probe: "\\n"}
+	flags.Timeout = 2 * time.Second
+	scanner := new(Scanner)
+	if err := scanner.Init(flags); err != nil {
+		t.Fatal(err)
+	}
+	status, result, err := scanner.Scan(context.Background(), zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port})
+	if status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	results := result.(*Results)
+	if results.MatchedProbe != "hello" || results.Banner != "WELCOME v1.2\n" {
+		t.Errorf("expected the hello probe to match, got %+v", results)
+	}
+	if len(results.Probes) != 3 || results.Probes[0].Matched || results.Probes[1].Matched || !results.Probes[2].Matched {
+		t.Errorf("unexpected per-probe results: %+v", results.Probes)
+	}
+}
+
+func TestScanConnectOnly(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	flags := &Flags{ConnectOnly: true, ReadBytes: 1024}
+	flags.Timeout = 2 * time.Second
+	flags.MaxTries = 1
+	flags.Probe = "\\n"
+	scanner := new(Scanner)
+	if err := scanner.Init(flags); err != nil {
+		t.Fatal(err)
+	}
+	target := zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port}
+
+	status, result, err := scanner.Scan(context.Background(), target)
+	if connect := result.(*Results).Connect; status != zgrab2.SCAN_SUCCESS || connect.Outcome != ConnectSuccess || connect.Error != "" {
+		t.Errorf("open port: unexpected status %s, result %+v (%v)", status, connect, err)
+	}
+
+	// Nothing listens on the port once the listener is closed.
+	listener.Close()
+	status, result, err = scanner.Scan(context.Background(), target)
+	if connect := result.(*Results).Connect; status != zgrab2.SCAN_CONNECTION_REFUSED || connect.Outcome != ConnectRefused || err == nil {
+		t.Errorf("closed port: unexpected status %s, result %+v (%v)", status, connect, err)
+	}
+}
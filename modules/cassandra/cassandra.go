@@ -0,0 +1,268 @@
+package cassandra
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+)
+
+// Opcodes from the native protocol specification (section 2.4).
+const (
+	opError        = 0x00
+	opStartup      = 0x01
+	opReady        = 0x02
+	opAuthenticate = 0x03
+	opOptions      = 0x05
+	opSupported    = 0x06
+)
+
+// errProtocol is the error code the server sends when it does not support
+// the requested protocol version.
+const errProtocol = 0x000A
+
+// maxFrameLength bounds the size of the frame bodies that are read.
+const maxFrameLength = 64 * 1024
+
+var (
+	// ErrInvalidFrame is returned when the server's response is not a
+	// native protocol response frame.
+	ErrInvalidFrame = errors.New("invalid native protocol frame")
+
+	// ErrFrameTooLarge is returned when a frame's body exceeds
+	// maxFrameLength.
+	ErrFrameTooLarge = errors.New("frame body too large")
+)
+
+// supportedVersionRegex matches the versions listed in a protocol error,
+// e.g. "Invalid or unsupported protocol version (5); supported versions
+// are (3/v3, 4/v4, 5/v5-beta)", or the greatest version named in one, e.g.
+// "...; the lowest supported version is 3 and the greatest is 4".
+var supportedVersionRegex = regexp.MustCompile(`(\d+)/v\d+|greatest is (\d+)`)
+
+// Frame is a native protocol frame.
+type Frame struct {
+	// Version is the protocol version, without the response bit.
+	Version byte
+
+	// Response is true if the frame was sent by the server.
+	Response bool
+
+	Flags  byte
+	Stream int16
+	Opcode byte
+	Body   []byte
+}
+
+// Error is the body of an ERROR frame.
+type Error struct {
+	Code    int32  `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("error 0x%04x: %s", err.Code, err.Message)
+}
+
+// headerLength returns the length of the frame header for the given
+// protocol version: versions 1 and 2 use a one-byte stream ID.
+func headerLength(version byte) int {
+	if version < 3 {
+		return 8
+	}
+	return 9
+}
+
+// Marshal returns the wire encoding of the frame.
+func (frame *Frame) Marshal() []byte {
+	versionByte := frame.Version
+	if frame.Response {
+		versionByte |= 0x80
+	}
+	ret := []byte{versionByte, frame.Flags}
+	if frame.Version < 3 {
+		ret = append(ret, byte(frame.Stream))
+	} else {
+		ret = append(ret, byte(frame.Stream>>8), byte(frame.Stream))
+	}
+	ret = append(ret, frame.Opcode, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(ret[len(ret)-4:], uint32(len(frame.Body)))
+	return append(ret, frame.Body...)
+}
+
+// Connection wraps a connection to a native protocol server.
+type Connection struct {
+	conn net.Conn
+}
+
+// NewConnection returns a Connection wrapping conn.
+func NewConnection(conn net.Conn) *Connection {
+	return &Connection{conn: conn}
+}
+
+// WriteFrame sends a request frame with the given version, opcode and body
+// on stream 0.
+func (c *Connection) WriteFrame(version byte, opcode byte, body []byte) error {
+	frame := Frame{Version: version, Opcode: opcode, Body: body}
+	_, err := c.conn.Write(frame.Marshal())
+	return err
+}
+
+// ReadFrame reads a response frame. The header length is taken from the
+// version of the response, which may differ from that of the request when
+// the server rejects the requested version.
+func (c *Connection) ReadFrame() (*Frame, error) {
+	var first [1]byte
+	if _, err := io.ReadFull(c.conn, first[:]); err != nil {
+		return nil, err
+	}
+	frame := &Frame{Version: first[0] & 0x7f, Response: first[0]&0x80 != 0}
+	if !frame.Response || frame.Version == 0 {
+		return nil, ErrInvalidFrame
+	}
+	header := make([]byte, headerLength(frame.Version)-1)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return nil, err
+	}
+	frame.Flags = header[0]
+	if frame.Version < 3 {
+		frame.Stream = int16(int8(header[1]))
+		header = header[2:]
+	} else {
+		frame.Stream = int16(binary.BigEndian.Uint16(header[1:3]))
+		header = header[3:]
+	}
+	frame.Opcode = header[0]
+	length := binary.BigEndian.Uint32(header[1:5])
+	if length > maxFrameLength {
+		return frame, ErrFrameTooLarge
+	}
+	frame.Body = make([]byte, length)
+	if _, err := io.ReadFull(c.conn, frame.Body); err != nil {
+		return frame, err
+	}
+	return frame, nil
+}
+
+// reader decodes the notation types of the protocol specification
+// (section 3) from a frame body.
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.buf) < n {
+		r.err = ErrInvalidFrame
+		return nil
+	}
+	ret := r.buf[:n]
+	r.buf = r.buf[n:]
+	return ret
+}
+
+func (r *reader) readInt() int32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
+
+func (r *reader) readShort() uint16 {
+	b := r.next(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *reader) readString() string {
+	return string(r.next(int(r.readShort())))
+}
+
+func (r *reader) readStringList() []string {
+	n := int(r.readShort())
+	var ret []string
+	for i := 0; i < n && r.err == nil; i++ {
+		ret = append(ret, r.readString())
+	}
+	return ret
+}
+
+func (r *reader) readStringMultimap() map[string][]string {
+	n := int(r.readShort())
+	ret := make(map[string][]string, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		key := r.readString()
+		ret[key] = r.readStringList()
+	}
+	return ret
+}
+
+// encodeStringMap returns the [string map] encoding of m.
+func encodeStringMap(m map[string]string) []byte {
+	ret := make([]byte, 2)
+	binary.BigEndian.PutUint16(ret, uint16(len(m)))
+	for k, v := range m {
+		ret = appendString(ret, k)
+		ret = appendString(ret, v)
+	}
+	return ret
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
+
+// parseSupported decodes the [string multimap] body of a SUPPORTED frame.
+func parseSupported(body []byte) (map[string][]string, error) {
+	r := &reader{buf: body}
+	ret := r.readStringMultimap()
+	return ret, r.err
+}
+
+// parseError decodes the body of an ERROR frame.
+func parseError(body []byte) (*Error, error) {
+	r := &reader{buf: body}
+	ret := &Error{Code: r.readInt()}
+	ret.Message = r.readString()
+	return ret, r.err
+}
+
+// parseAuthenticate decodes the body of an AUTHENTICATE frame, the class
+// name of the server's authenticator.
+func parseAuthenticate(body []byte) (string, error) {
+	r := &reader{buf: body}
+	ret := r.readString()
+	return ret, r.err
+}
+
+// nextVersion returns the protocol version to retry with after the server
+// rejected version with a protocol error. The highest version below the
+// rejected one that is named in the error message (or, failing that, the
+// version of the error frame) is preferred; otherwise it steps down by one.
+// It returns 0 if there is no lower version to try.
+func nextVersion(version byte, frame *Frame, err *Error) byte {
+	var best byte
+	for _, match := range supportedVersionRegex.FindAllStringSubmatch(err.Message, -1) {
+		v, _ := strconv.Atoi(match[1] + match[2])
+		if v > 0 && v < int(version) && byte(v) > best {
+			best = byte(v)
+		}
+	}
+	if best == 0 && frame.Version < version {
+		best = frame.Version
+	}
+	if best == 0 {
+		best = version - 1
+	}
+	return best
+}
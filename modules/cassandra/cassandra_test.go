@@ -0,0 +1,183 @@
+package cassandra
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+// encodeSupported returns the [string multimap] encoding of m.
+func encodeSupported(m map[string][]string) []byte {
+	ret := make([]byte, 2)
+	binary.BigEndian.PutUint16(ret, uint16(len(m)))
+	for k, values := range m {
+		ret = appendString(ret, k)
+		ret = append(ret, byte(len(values)>>8), byte(len(values)))
+		for _, v := range values {
+			ret = appendString(ret, v)
+		}
+	}
+	return ret
+}
+
+func encodeError(code int32, message string) []byte {
+	ret := make([]byte, 4)
+	binary.BigEndian.PutUint32(ret, uint32(code))
+	return appendString(ret, message)
+}
+
+// serveNode accepts connections on listener, answering as a node that only
+// supports protocol versions up to maxVersion and requires authentication.
+// Rejected versions are answered in a version 2 frame, as Cassandra 2.x
+// does.
+func serveNode(t *testing.T, listener net.Listener, maxVersion byte, supported map[string][]string) {
+	for {
+		c, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(c net.Conn) {
+			defer c.Close()
+			conn := NewConnection(c)
+			for {
+				var header [9]byte
+				if _, err := c.Read(header[:1]); err != nil {
+					return
+				}
+				version := header[0]
+				n := headerLength(version) - 1
+				if _, err := c.Read(header[1 : n+1]); err != nil {
+					return
+				}
+				opcode := header[n-4]
+				if length := binary.BigEndian.Uint32(header[n-3 : n+1]); length > 0 {
+					c.Read(make([]byte, length))
+				}
+				reply := &Frame{Version: version, Response: true}
+				switch {
+				case version > maxVersion:
+					reply.Version = 2
+					reply.Opcode = opError
+					reply.Body = encodeError(errProtocol, "Invalid or unsupported protocol version (4); the lowest supported version is 1 and the greatest is 3")
+				case opcode == opOptions:
+					reply.Opcode = opSupported
+					reply.Body = encodeSupported(supported)
+				case opcode == opStartup:
+					reply.Opcode = opAuthenticate
+					reply.Body = appendString(nil, "org.apache.cassandra.auth.PasswordAuthenticator")
+				default:
+					t.Errorf("unexpected opcode %d", opcode)
+					return
+				}
+				if _, err := conn.conn.Write(reply.Marshal()); err != nil {
+					return
+				}
+			}
+		}(c)
+	}
+}
+
+func scanTestNode(t *testing.T, maxVersion byte, supported map[string][]string) (zgrab2.ScanStatus, *ScanResults, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveNode(t, listener, maxVersion, supported)
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	scanner := &Scanner{config: &Flags{ProtocolVersion: 4}}
+	scanner.config.Timeout = 5 * time.Second
+	status, result, err := scanner.Scan(context.Background(), zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port})
+	results, _ := result.(*ScanResults)
+	return status, results, err
+}
+
+func TestScanAuthRequired(t *testing.T) {
+	supported := map[string][]string{
+		"CQL_VERSION": {"3.4.4"},
+		"COMPRESSION": {"snappy", "lz4"},
+	}
+	status, result, err := scanTestNode(t, 4, supported)
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	if result.ProtocolVersion != 4 || len(result.ProtocolErrors) != 0 {
+		t.Errorf("expected version 4 to be accepted, got %d (%v)", result.ProtocolVersion, result.ProtocolErrors)
+	}
+	if !reflect.DeepEqual(result.Supported, supported) || !reflect.DeepEqual(result.Compression, []string{"snappy", "lz4"}) || result.Product != "cassandra" {
+		t.Errorf("unexpected SUPPORTED: %v", result.Supported)
+	}
+	if !result.AuthRequired || result.StartupResponse != "authenticate" || result.Authenticator != "org.apache.cassandra.auth.PasswordAuthenticator" {
+		t.Errorf("expected authentication to be required, got %+v", result)
+	}
+}
+
+func TestScanScyllaDB(t *testing.T) {
+	supported := map[string][]string{
+		"CQL_VERSION":      {"3.3.1"},
+		"SCYLLA_SHARD":     {"0"},
+		"SCYLLA_NR_SHARDS": {"4"},
+	}
+	status, result, err := scanTestNode(t, 4, supported)
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	if result.Product != "scylladb" || !reflect.DeepEqual(result.Supported, supported) {
+		t.Errorf("expected a ScyllaDB node, got %q (%v)", result.Product, result.Supported)
+	}
+}
+
+func TestDetectProduct(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   ScanResults
+		expected string
+	}{
+		{"cassandra", ScanResults{Supported: map[string][]string{"CQL_VERSION": {"3.4.5"}}}, "cassandra"},
+		{"scylla options", ScanResults{Supported: map[string][]string{"SCYLLA_PARTITIONER": {"org.apache.cassandra.dht.Murmur3Partitioner"}}}, "scylladb"},
+		{"scylla authenticator", ScanResults{StartupResponse: "authenticate", Authenticator: "com.scylladb.auth.TransitionalAuthenticator"}, "scylladb"},
+		{"scylla error", ScanResults{StartupResponse: "error", StartupError: &Error{Message: "ScyllaDB does not support this"}}, "scylladb"},
+		{"nothing", ScanResults{}, ""},
+	}
+	for _, test := range tests {
+		if got := detectProduct(&test.result); got != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestScanDowngrade(t *testing.T) {
+	status, result, err := scanTestNode(t, 3, map[string][]string{"CQL_VERSION": {"3.3.1"}})
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	if result.ProtocolVersion != 3 || len(result.ProtocolErrors) != 1 {
+		t.Errorf("expected a downgrade to version 3, got %d (%v)", result.ProtocolVersion, result.ProtocolErrors)
+	}
+	if !reflect.DeepEqual(result.CQLVersions, []string{"3.3.1"}) {
+		t.Errorf("unexpected CQL versions: %v", result.CQLVersions)
+	}
+}
+
+func TestNextVersion(t *testing.T) {
+	tests := []struct {
+		version  byte
+		frame    byte
+		message  string
+		expected byte
+	}{
+		{5, 5, "Invalid or unsupported protocol version (5); supported versions are (3/v3, 4/v4, 5/v5-beta)", 4},
+		{4, 2, "Invalid or unsupported protocol version: 4", 2},
+		{4, 4, "Invalid or unsupported protocol version: 4", 3},
+	}
+	for _, test := range tests {
+		if got := nextVersion(test.version, &Frame{Version: test.frame}, &Error{Message: test.message}); got != test.expected {
+			t.Errorf("%d %q: expected %d, got %d", test.version, test.message, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+// Package cassandra provides a zgrab2 module that scans for Cassandra and
+// ScyllaDB nodes using the CQL native protocol.
+// Default Port: 9042 (TCP)
+//
+// The scanner sends an OPTIONS frame and records the SUPPORTED response: the
+// CQL versions, compression algorithms and (on newer nodes) protocol
+// versions the node accepts. It then sends a STARTUP frame and records
+// whether the node is ready for queries (READY) or requires authentication
+// (AUTHENTICATE), along with its authenticator class. ScyllaDB nodes are
+// told apart from Cassandra by the SCYLLA_ options in their SUPPORTED
+// response.
+//
+// The first frame uses --protocol-version (default 4). If the node rejects
+// it with a protocol error, the scanner reconnects with the highest lower
+// version named in the error and tries again.
+package cassandra
+
+import (
+	"context"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmap/zgrab2"
+)
+
+// ScanResults instances are returned by the module's Scan function.
+type ScanResults struct {
+	// ProtocolVersion is the native protocol version the node accepted.
+	ProtocolVersion int `json:"protocol_version,omitempty"`
+
+	// ProtocolErrors holds the messages of the protocol errors returned
+	// for rejected protocol versions during negotiation.
+	ProtocolErrors []string `json:"protocol_errors,omitempty"`
+
+	// Supported is the full SUPPORTED response to the OPTIONS frame.
+	Supported map[string][]string `json:"supported,omitempty"`
+
+	// CQLVersions is the SUPPORTED response's CQL_VERSION list.
+	CQLVersions []string `json:"cql_versions,omitempty"`
+
+	// Compression is the SUPPORTED response's COMPRESSION list.
+	Compression []string `json:"compression,omitempty"`
+
+	// ProtocolVersions is the SUPPORTED response's PROTOCOL_VERSIONS list,
+	// sent by Cassandra 4.0 and later, e.g. "4/v4".
+	ProtocolVersions []string `json:"protocol_versions,omitempty"`
+
+	// StartupResponse is the type of the response to the STARTUP frame:
+	// "ready", "authenticate" or "error".
+	StartupResponse string `json:"startup_response,omitempty"`
+
+	// AuthRequired is true if the node answered STARTUP with AUTHENTICATE.
+	AuthRequired bool `json:"auth_required"`
+
+	// Authenticator is the class name sent in the AUTHENTICATE frame, e.g.
+	// "org.apache.cassandra.auth.PasswordAuthenticator".
+	Authenticator string `json:"authenticator,omitempty"`
+
+	// StartupError is the ERROR returned in response to STARTUP, if any.
+	StartupError *Error `json:"startup_error,omitempty"`
+
+	// Product is the node's product, "scylladb" or "cassandra", told apart
+	// by the SCYLLA_ options in the SUPPORTED response, the authenticator
+	// class or the STARTUP error. It is omitted if the node sent neither.
+	Product string `json:"product,omitempty"`
+}
+
+// Flags holds the command-line configuration for the cassandra scan module.
+// Populated by the framework.
+type Flags struct {
+	zgrab2.BaseFlags
+
+	// ProtocolVersion is the native protocol version to try first.
+	ProtocolVersion uint8 `long:"protocol-version" default:"4" description:"Native protocol version to try first (1-5); lower versions are tried if the node rejects it"`
+
+	// CQLVersion is the CQL_VERSION sent in the STARTUP frame.
+	CQLVersion string `long:"cql-version" description:"CQL_VERSION to send in STARTUP (default: the first version the node lists, or 3.0.0)"`
+
+	// Verbose indicates that there should be more verbose logging.
+	Verbose bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
+}
+
+// Module implements the zgrab2.Module interface.
+type Module struct {
+}
+
+// Scanner implements the zgrab2.Scanner interface.
+type Scanner struct {
+	config *Flags
+}
+
+// RegisterModule registers the zgrab2 module.
+func RegisterModule() {
+	var module Module
+	_, err := zgrab2.AddCommand("cassandra", "cassandra", module.Description(), 9042, &module)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewFlags returns a default Flags object.
+func (module *Module) NewFlags() interface{} {
+	return new(Flags)
+}
+
+// NewScanner returns a new Scanner instance.
+func (module *Module) NewScanner() zgrab2.Scanner {
+	return new(Scanner)
+}
+
+// Description returns an overview of this module.
+func (module *Module) Description() string {
+	return "Probe for Cassandra/ScyllaDB nodes with CQL native protocol OPTIONS and STARTUP frames"
+}
+
+// Validate checks that the flags are valid.
+// On success, returns nil.
+// On failure, returns an error instance describing the error.
+func (flags *Flags) Validate(args []string) error {
+	if flags.ProtocolVersion < 1 || flags.ProtocolVersion > 5 {
+		log.Error("--protocol-version must be between 1 and 5")
+		return zgrab2.ErrInvalidArguments
+	}
+	return nil
+}
+
+// Help returns the module's help string.
+func (flags *Flags) Help() string {
+	return ""
+}
+
+// Init initializes the Scanner.
+func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
+	f, _ := flags.(*Flags)
+	scanner.config = f
+	return nil
+}
+
+// InitPerSender initializes the scanner for a given sender.
+func (scanner *Scanner) InitPerSender(senderID int) error {
+	return nil
+}
+
+// GetName returns the Scanner name defined in the Flags.
+func (scanner *Scanner) GetName() string {
+	return scanner.config.Name
+}
+
+// GetTrigger returns the Trigger defined in the Flags.
+func (scanner *Scanner) GetTrigger() string {
+	return scanner.config.Trigger
+}
+
+// Protocol returns the protocol identifier of the scan.
+func (scanner *Scanner) Protocol() string {
+	return "cassandra"
+}
+
+// scyllaOptionPrefix is the prefix of the options that ScyllaDB adds to its
+// SUPPORTED response, e.g. SCYLLA_SHARD and SCYLLA_NR_SHARDS.
+const scyllaOptionPrefix = "SCYLLA_"
+
+// detectProduct returns the Product of the node that sent the responses in
+// result.
+func detectProduct(result *ScanResults) string {
+	for option := range result.Supported {
+		if strings.HasPrefix(option, scyllaOptionPrefix) {
+			return "scylladb"
+		}
+	}
+	if strings.HasPrefix(result.Authenticator, "com.scylladb.") {
+		return "scylladb"
+	}
+	if result.StartupError != nil && strings.Contains(strings.ToLower(result.StartupError.Message), "scylla") {
+		return "scylladb"
+	}
+	if result.Supported != nil || result.StartupResponse != "" {
+		return "cassandra"
+	}
+	return ""
+}
+
+// startupOptions returns the [string map] body of the STARTUP frame.
+func (scanner *Scanner) startupOptions(result *ScanResults) map[string]string {
+	cqlVersion := scanner.config.CQLVersion
+	if cqlVersion == "" {
+		cqlVersion = "3.0.0"
+		if len(result.CQLVersions) > 0 {
+			cqlVersion = result.CQLVersions[0]
+		}
+	}
+	return map[string]string{"CQL_VERSION": cqlVersion}
+}
+
+// probe sends OPTIONS and STARTUP frames with the given protocol version
+// over conn. If the node rejects the version, it returns the protocol error
+// and the frame that carried it.
+func (scanner *Scanner) probe(conn *Connection, version byte, result *ScanResults) (*Frame, *Error, error) {
+	if err := conn.WriteFrame(version, opOptions, nil); err != nil {
+		return nil, nil, err
+	}
+	frame, err := conn.ReadFrame()
+	if err != nil {
+		return nil, nil, err
+	}
+	switch frame.Opcode {
+	case opError:
+		cqlErr, err := parseError(frame.Body)
+		if err != nil {
+			return nil, nil, err
+		}
+		if cqlErr.Code == errProtocol {
+			return frame, cqlErr, nil
+		}
+		return nil, nil, cqlErr
+	case opSupported:
+		if result.Supported, err = parseSupported(frame.Body); err != nil {
+			return nil, nil, err
+		}
+		result.CQLVersions = result.Supported["CQL_VERSION"]
+		result.Compression = result.Supported["COMPRESSION"]
+		result.ProtocolVersions = result.Supported["PROTOCOL_VERSIONS"]
+	default:
+		return nil, nil, ErrInvalidFrame
+	}
+
+	if err := conn.WriteFrame(version, opStartup, encodeStringMap(scanner.startupOptions(result))); err != nil {
+		return nil, nil, err
+	}
+	if frame, err = conn.ReadFrame(); err != nil {
+		return nil, nil, err
+	}
+	switch frame.Opcode {
+	case opReady:
+		result.StartupResponse = "ready"
+	case opAuthenticate:
+		result.StartupResponse = "authenticate"
+		result.AuthRequired = true
+		if result.Authenticator, err = parseAuthenticate(frame.Body); err != nil {
+			return nil, nil, err
+		}
+	case opError:
+		result.StartupResponse = "error"
+		if result.StartupError, err = parseError(frame.Body); err != nil {
+			return nil, nil, err
+		}
+		if result.StartupError.Code == errProtocol {
+			return frame, result.StartupError, nil
+		}
+	default:
+		return nil, nil, ErrInvalidFrame
+	}
+	return nil, nil, nil
+}
+
+// Scan performs the Cassandra scan.
+//  1. Open a TCP connection to the target port (default 9042).
+//  2. Send OPTIONS and record the SUPPORTED response.
+//  3. Send STARTUP and record whether the node is READY or requires
+//     authentication.
+//  4. If either frame is rejected with a protocol error, record the error
+//     and repeat from 1 with a lower protocol version.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	version := byte(scanner.config.ProtocolVersion)
+	var result *ScanResults
+	var rejected []string
+	for version > 0 {
+		c, err := target.Open(&scanner.config.BaseFlags)
+		if err != nil {
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+		result = &ScanResults{ProtocolVersion: int(version), ProtocolErrors: rejected}
+		frame, protocolErr, err := scanner.probe(NewConnection(c), version, result)
+		c.Close()
+		result.Product = detectProduct(result)
+		if err != nil {
+			if _, ok := err.(*Error); ok {
+				return zgrab2.SCAN_APPLICATION_ERROR, result, err
+			}
+			if err == ErrInvalidFrame || err == ErrFrameTooLarge {
+				return zgrab2.SCAN_PROTOCOL_ERROR, result, err
+			}
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+		if protocolErr == nil {
+			return zgrab2.SCAN_SUCCESS, result, nil
+		}
+		log.Debugf("cassandra: version %d rejected: %s", version, protocolErr.Message)
+		rejected = append(rejected, protocolErr.Message)
+		version = nextVersion(version, frame, protocolErr)
+	}
+	result.ProtocolVersion = 0
+	result.ProtocolErrors = rejected
+	return zgrab2.SCAN_PROTOCOL_ERROR, result, &Error{Code: errProtocol, Message: "no supported protocol version"}
+}
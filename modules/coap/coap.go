@@ -0,0 +1,446 @@
+// CoAP client for the coap module.
+// https://tools.ietf.org/html/rfc7252 (CoAP),
+// https://tools.ietf.org/html/rfc7959 (block-wise transfers) and
+// https://tools.ietf.org/html/rfc6690 (CoRE Link Format).
+
+package coap
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxDatagramSize is the size of the buffer used to read each response.
+const maxDatagramSize = 0x10000
+
+// Message types.
+const (
+	typeConfirmable     = 0
+	typeNonConfirmable  = 1
+	typeAcknowledgement = 2
+	typeReset           = 3
+)
+
+// typeNames maps message types to their names.
+var typeNames = []string{"CON", "NON", "ACK", "RST"}
+
+// Option numbers.
+const (
+	optionURIPath       = 11
+	optionContentFormat = 12
+	optionBlock2        = 23
+)
+
+// codeGet is the request code of GET (0.01); codeEmpty (0.00) is used by
+// empty ACKs and RSTs.
+const (
+	codeEmpty = 0x00
+	codeGet   = 0x01
+)
+
+// codeNames maps the response codes to their names.
+var codeNames = map[byte]string{
+	0x41: "Created",
+	0x42: "Deleted",
+	0x43: "Valid",
+	0x44: "Changed",
+	0x45: "Content",
+	0x5f: "Continue",
+	0x80: "Bad Request",
+	0x81: "Unauthorized",
+	0x82: "Bad Option",
+	0x83: "Forbidden",
+	0x84: "Not Found",
+	0x85: "Method Not Allowed",
+	0x86: "Not Acceptable",
+	0x88: "Request Entity Incomplete",
+	0x8c: "Precondition Failed",
+	0x8d: "Request Entity Too Large",
+	0x8f: "Unsupported Content-Format",
+	0xa0: "Internal Server Error",
+	0xa1: "Not Implemented",
+	0xa2: "Bad Gateway",
+	0xa3: "Service Unavailable",
+	0xa4: "Gateway Timeout",
+	0xa5: "Proxying Not Supported",
+}
+
+var (
+	// ErrInvalidMessage is returned when a datagram is not a valid CoAP
+	// message.
+	ErrInvalidMessage = errors.New("invalid CoAP message")
+
+	// ErrReset is returned when the server rejects the request with a
+	// Reset message.
+	ErrReset = errors.New("request was reset by the server")
+
+	// ErrTooManyMessages is returned when the server sends too many
+	// unrelated messages without responding to the request.
+	ErrTooManyMessages = errors.New("too many unmatched messages")
+)
+
+// maxUnmatchedMessages bounds the number of messages that are skipped while
+// waiting for the response to a request.
+const maxUnmatchedMessages = 8
+
+// Option is a CoAP option.
+type Option struct {
+	Number int
+	Value  []byte
+}
+
+// Message is a CoAP message.
+type Message struct {
+	Type      byte
+	Code      byte
+	MessageID uint16
+	Token     []byte
+	Options   []Option
+	Payload   []byte
+}
+
+// CodeString returns the code in c.dd form, e.g. "2.05".
+func (m *Message) CodeString() string {
+	return fmt.Sprintf("%d.%02d", m.Code>>5, m.Code&0x1f)
+}
+
+// Option returns the value of the first option with the given number.
+func (m *Message) Option(number int) ([]byte, bool) {
+	for _, option := range m.Options {
+		if option.Number == number {
+			return option.Value, true
+		}
+	}
+	return nil, false
+}
+
+// encodeOptionNibble returns the 4-bit encoding of an option delta or
+// length, and its extended bytes.
+func encodeOptionNibble(v int) (byte, []byte) {
+	switch {
+	case v < 13:
+		return byte(v), nil
+	case v < 269:
+		return 13, []byte{byte(v - 13)}
+	default:
+		return 14, []byte{byte((v - 269) >> 8), byte(v - 269)}
+	}
+}
+
+// Marshal returns the wire encoding of the message. The options are sorted
+// by number, as the encoding requires.
+func (m *Message) Marshal() []byte {
+	ret := []byte{0x40 | m.Type<<4 | byte(len(m.Token)), m.Code, byte(m.MessageID >> 8), byte(m.MessageID)}
+	ret = append(ret, m.Token...)
+	options := make([]Option, len(m.Options))
+	copy(options, m.Options)
+	sort.SliceStable(options, func(i, j int) bool { return options[i].Number < options[j].Number })
+	last := 0
+	for _, option := range options {
+		delta, deltaExt := encodeOptionNibble(option.Number - last)
+		length, lengthExt := encodeOptionNibble(len(option.Value))
+		ret = append(ret, delta<<4|length)
+		ret = append(ret, deltaExt...)
+		ret = append(ret, lengthExt...)
+		ret = append(ret, option.Value...)
+		last = option.Number
+	}
+	if len(m.Payload) > 0 {
+		ret = append(ret, 0xff)
+		ret = append(ret, m.Payload...)
+	}
+	return ret
+}
+
+// decodeOptionNibble decodes an option delta or length nibble, consuming any
+// extended bytes from buf.
+func decodeOptionNibble(v byte, buf []byte) (int, []byte, error) {
+	switch v {
+	case 13:
+		if len(buf) < 1 {
+			return 0, nil, ErrInvalidMessage
+		}
+		return int(buf[0]) + 13, buf[1:], nil
+	case 14:
+		if len(buf) < 2 {
+			return 0, nil, ErrInvalidMessage
+		}
+		return int(binary.BigEndian.Uint16(buf)) + 269, buf[2:], nil
+	case 15:
+		return 0, nil, ErrInvalidMessage
+	}
+	return int(v), buf, nil
+}
+
+// ParseMessage decodes a CoAP message.
+func ParseMessage(buf []byte) (*Message, error) {
+	if len(buf) < 4 || buf[0]>>6 != 1 {
+		return nil, ErrInvalidMessage
+	}
+	tokenLength := int(buf[0] & 0x0f)
+	if tokenLength > 8 || len(buf) < 4+tokenLength {
+		return nil, ErrInvalidMessage
+	}
+	m := &Message{
+		Type:      (buf[0] >> 4) & 0x03,
+		Code:      buf[1],
+		MessageID: binary.BigEndian.Uint16(buf[2:4]),
+		Token:     buf[4 : 4+tokenLength],
+	}
+	buf = buf[4+tokenLength:]
+	number := 0
+	for len(buf) > 0 {
+		if buf[0] == 0xff {
+			if len(buf) == 1 {
+				return nil, ErrInvalidMessage
+			}
+			m.Payload = buf[1:]
+			break
+		}
+		var delta, length int
+		var err error
+		head := buf[0]
+		if delta, buf, err = decodeOptionNibble(head>>4, buf[1:]); err != nil {
+			return nil, err
+		}
+		if length, buf, err = decodeOptionNibble(head&0x0f, buf); err != nil {
+			return nil, err
+		}
+		if len(buf) < length {
+			return nil, ErrInvalidMessage
+		}
+		number += delta
+		m.Options = append(m.Options, Option{Number: number, Value: buf[:length]})
+		buf = buf[length:]
+	}
+	return m, nil
+}
+
+// decodeUint decodes a variable-length unsigned integer option value.
+func decodeUint(b []byte) uint32 {
+	var ret uint32
+	for _, c := range b {
+		ret = ret<<8 | uint32(c)
+	}
+	return ret
+}
+
+// encodeUint encodes an unsigned integer option value in as few bytes as
+// possible.
+func encodeUint(v uint32) []byte {
+	var ret []byte
+	for ; v > 0; v >>= 8 {
+		ret = append([]byte{byte(v)}, ret...)
+	}
+	return ret
+}
+
+// randomBytes returns n random bytes.
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
+
+// Client sends requests over a CoAP connection.
+type Client struct {
+	conn      net.Conn
+	messageID uint16
+}
+
+// NewClient returns a Client over the given connection, starting with a
+// random message ID.
+func NewClient(conn net.Conn) *Client {
+	return &Client{conn: conn, messageID: binary.BigEndian.Uint16(randomBytes(2))}
+}
+
+// readMessage reads and decodes the next datagram.
+func (c *Client) readMessage() (*Message, error) {
+	buf := make([]byte, maxDatagramSize)
+	n, err := c.conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMessage(buf[:n])
+}
+
+// Do sends a confirmable request and returns the matching response. A
+// piggybacked response must be an ACK with the request's message ID and
+// token. If the server sends an empty ACK instead (or the ACK is lost), the
+// separate response is matched by its token, and acknowledged if it is
+// confirmable. Unrelated messages (e.g. duplicates) are skipped.
+func (c *Client) Do(request *Message) (*Message, error) {
+	c.messageID++
+	request.Type = typeConfirmable
+	request.MessageID = c.messageID
+	request.Token = randomBytes(4)
+	if _, err := c.conn.Write(request.Marshal()); err != nil {
+		return nil, err
+	}
+	for i := 0; i < maxUnmatchedMessages; i++ {
+		response, err := c.readMessage()
+		if err == ErrInvalidMessage {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if response.MessageID == request.MessageID && (response.Type == typeAcknowledgement || response.Type == typeReset) {
+			if response.Type == typeReset {
+				return response, ErrReset
+			}
+			if response.Code == codeEmpty {
+				continue
+			}
+		} else if response.Type == typeAcknowledgement || response.Type == typeReset {
+			continue
+		}
+		if string(response.Token) != string(request.Token) {
+			continue
+		}
+		if response.Type == typeConfirmable {
+			ack := &Message{Type: typeAcknowledgement, Code: codeEmpty, MessageID: response.MessageID}
+			if _, err := c.conn.Write(ack.Marshal()); err != nil {
+				return response, err
+			}
+		}
+		return response, nil
+	}
+	return nil, ErrTooManyMessages
+}
+
+// Get sends a GET request for path, following up to maxBlocks Block2
+// blocks. It returns the first response, with its payload replaced by the
+// concatenation of the blocks' payloads.
+func (c *Client) Get(path string, maxBlocks int) (*Message, error) {
+	var ret *Message
+	var payload []byte
+	block := uint32(0)
+	for {
+		request := &Message{Code: codeGet}
+		for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+			request.Options = append(request.Options, Option{Number: optionURIPath, Value: []byte(segment)})
+		}
+		if block > 0 {
+			request.Options = append(request.Options, Option{Number: optionBlock2, Value: encodeUint(block)})
+		}
+		response, err := c.Do(request)
+		if ret == nil {
+			ret = response
+		}
+		if response != nil {
+			payload = append(payload, response.Payload...)
+			ret.Payload = payload
+		}
+		if err != nil {
+			return ret, err
+		}
+		value, ok := response.Option(optionBlock2)
+		if !ok || response.Code != ret.Code {
+			return ret, nil
+		}
+		// NUM (20 bits) | M (1 bit) | SZX (3 bits)
+		block2 := decodeUint(value)
+		num := block2 >> 4
+		if block2&0x08 == 0 || int(num)+1 >= maxBlocks {
+			return ret, nil
+		}
+		block = (num+1)<<4 | block2&0x07
+	}
+}
+
+// Link is a link in a CoRE Link Format document.
+type Link struct {
+	// Path is the link's target, e.g. "/sensors/temp".
+	Path string `json:"path"`
+
+	// ResourceTypes lists the link's resource types (rt), e.g.
+	// "temperature-c".
+	ResourceTypes []string `json:"resource_types,omitempty"`
+
+	// Interfaces lists the link's interface descriptions (if), e.g.
+	// "sensor".
+	Interfaces []string `json:"interfaces,omitempty"`
+
+	// ContentFormat is the link's content format (ct), if present.
+	ContentFormat string `json:"content_format,omitempty"`
+
+	// Title is the link's title, if present.
+	Title string `json:"title,omitempty"`
+
+	// Attributes holds all of the link's attributes, including the above.
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// splitOutsideQuotes splits s on sep, ignoring separators within quotes or
+// angle brackets.
+func splitOutsideQuotes(s string, sep byte) []string {
+	var ret []string
+	quoted, bracketed := false, false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"' && !bracketed:
+			quoted = !quoted
+		case s[i] == '<' && !quoted:
+			bracketed = true
+		case s[i] == '>' && !quoted:
+			bracketed = false
+		case s[i] == sep && !quoted && !bracketed:
+			ret = append(ret, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(ret, s[start:])
+}
+
+// ParseLinkFormat parses a CoRE Link Format document. Malformed links are
+// skipped.
+func ParseLinkFormat(s string) []*Link {
+	var ret []*Link
+	for _, value := range splitOutsideQuotes(s, ',') {
+		params := splitOutsideQuotes(strings.TrimSpace(value), ';')
+		target := strings.TrimSpace(params[0])
+		if len(target) < 2 || target[0] != '<' || target[len(target)-1] != '>' {
+			continue
+		}
+		link := &Link{Path: target[1 : len(target)-1]}
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if param == "" {
+				continue
+			}
+			name, value := param, ""
+			if i := strings.IndexByte(param, '='); i >= 0 {
+				name, value = param[:i], param[i+1:]
+				if unquoted, err := strconv.Unquote(value); err == nil {
+					value = unquoted
+				} else {
+					value = strings.Trim(value, `"`)
+				}
+			}
+			if link.Attributes == nil {
+				link.Attributes = make(map[string]string)
+			}
+			link.Attributes[name] = value
+			switch name {
+			case "rt":
+				link.ResourceTypes = strings.Fields(value)
+			case "if":
+				link.Interfaces = strings.Fields(value)
+			case "ct":
+				link.ContentFormat = value
+			case "title":
+				link.Title = value
+			}
+		}
+		ret = append(ret, link)
+	}
+	return ret
+}
@@ -0,0 +1,135 @@
+package coap
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+const testLinkFormat = `</sensors/temp>;rt="temperature-c";if="sensor";ct=0,` +
+	`</sensors/light>;rt="light-lux core.s";if="sensor";title="Light, ambient",` +
+	`</.well-known/core>;ct=40`
+
+func TestMessageRoundTrip(t *testing.T) {
+	message := &Message{
+		Type:      typeConfirmable,
+		Code:      codeGet,
+		MessageID: 0x1234,
+		Token:     []byte{1, 2, 3, 4},
+		Options: []Option{
+			{Number: optionBlock2, Value: []byte{0x16}},
+			{Number: optionURIPath, Value: []byte(".well-known")},
+			{Number: optionURIPath, Value: []byte("core")},
+			{Number: 2048, Value: []byte("a long option number")},
+		},
+		Payload: []byte("payload"),
+	}
+	parsed, err := ParseMessage(message.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.MessageID != 0x1234 || parsed.Type != typeConfirmable || parsed.CodeString() != "0.01" || string(parsed.Token) != "\x01\x02\x03\x04" {
+		t.Errorf("unexpected header: %+v", parsed)
+	}
+	var numbers []int
+	for _, option := range parsed.Options {
+		numbers = append(numbers, option.Number)
+	}
+	if !reflect.DeepEqual(numbers, []int{optionURIPath, optionURIPath, optionBlock2, 2048}) {
+		t.Errorf("unexpected options: %v", numbers)
+	}
+	if string(parsed.Payload) != "payload" {
+		t.Errorf("unexpected payload %q", parsed.Payload)
+	}
+	if _, err := ParseMessage([]byte{0x80, 0x01, 0, 0}); err != ErrInvalidMessage {
+		t.Errorf("expected invalid version to be rejected, got %v", err)
+	}
+}
+
+func TestParseLinkFormat(t *testing.T) {
+	links := ParseLinkFormat(testLinkFormat)
+	if len(links) != 3 {
+		t.Fatalf("expected 3 links, got %d", len(links))
+	}
+	if links[0].Path != "/sensors/temp" || !reflect.DeepEqual(links[0].ResourceTypes, []string{"temperature-c"}) || links[0].ContentFormat != "0" {
+		t.Errorf("unexpected link: %+v", links[0])
+	}
+	if !reflect.DeepEqual(links[1].ResourceTypes, []string{"light-lux", "core.s"}) || links[1].Title != "Light, ambient" {
+		t.Errorf("unexpected link: %+v", links[1])
+	}
+	if links[2].Path != "/.well-known/core" || links[2].Attributes["ct"] != "40" {
+		t.Errorf("unexpected link: %+v", links[2])
+	}
+}
+
+// serve answers GET requests on conn with the given blocks of payload,
+// sending an empty ACK and a separate confirmable response for the first.
+func serve(t *testing.T, conn net.Conn, blocks []string) {
+	defer conn.Close()
+	buf := make([]byte, maxDatagramSize)
+	for num := 0; num < len(blocks); num++ {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		request, err := ParseMessage(buf[:n])
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if block, ok := request.Option(optionBlock2); num > 0 && (!ok || decodeUint(block)>>4 != uint32(num)) {
+			t.Errorf("expected a request for block %d, got %v", num, block)
+		}
+		more := byte(0)
+		if num < len(blocks)-1 {
+			more = 0x08
+		}
+		response := &Message{
+			Type:      typeAcknowledgement,
+			Code:      0x45,
+			MessageID: request.MessageID,
+			Token:     request.Token,
+			Options: []Option{
+				{Number: optionContentFormat, Value: []byte{contentFormatLinkFormat}},
+				{Number: optionBlock2, Value: []byte{byte(num<<4) | more | 0x02}},
+			},
+			Payload: []byte(blocks[num]),
+		}
+		if num == 0 {
+			// Empty ACK, then a separate response, which must be ACKed.
+			conn.Write((&Message{Type: typeAcknowledgement, MessageID: request.MessageID}).Marshal())
+			response.Type = typeConfirmable
+			response.MessageID = 0x7777
+		}
+		conn.Write(response.Marshal())
+		if num == 0 {
+			if n, err = conn.Read(buf); err != nil {
+				return
+			}
+			if ack, err := ParseMessage(buf[:n]); err != nil || ack.Type != typeAcknowledgement || ack.MessageID != 0x7777 {
+				t.Errorf("expected an ACK of the separate response, got %+v", ack)
+			}
+		}
+	}
+}
+
+func TestGetBlockwiseSeparate(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	blocks := []string{testLinkFormat[:64], testLinkFormat[64:128], testLinkFormat[128:]}
+	go serve(t, server, blocks)
+	response, err := NewClient(client).Get("/.well-known/core", 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := newResults(response)
+	if result.LinkFormat != testLinkFormat {
+		t.Errorf("expected the blocks to be concatenated, got %q", result.LinkFormat)
+	}
+	if result.Code != "2.05" || result.CodeName != "Content" || result.MessageType != "CON" || *result.ContentFormat != contentFormatLinkFormat {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if len(result.Resources) != 3 || !reflect.DeepEqual(result.ResourceTypes, []string{"core.s", "light-lux", "temperature-c"}) {
+		t.Errorf("unexpected resources: %v", result.ResourceTypes)
+	}
+}
@@ -0,0 +1,206 @@
+// Package coap provides a zgrab2 module that scans for CoAP servers, such
+// as IoT devices.
+// Default Port: 5683 (UDP)
+//
+// The scanner sends a confirmable GET request for /.well-known/core (or
+// --path) and records the response code along with the CoRE Link Format
+// document listing the server's resources, both raw and parsed into the
+// resources' paths and attributes. Block-wise (Block2) responses are
+// followed for up to --max-blocks blocks.
+package coap
+
+import (
+	"context"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmap/zgrab2"
+)
+
+// contentFormatLinkFormat is the Content-Format of application/link-format.
+const contentFormatLinkFormat = 40
+
+// ScanResults instances are returned by the module's Scan function.
+type ScanResults struct {
+	// MessageType is the type of the response message: "ACK" for a
+	// piggybacked response, "CON" or "NON" for a separate response, or
+	// "RST".
+	MessageType string `json:"message_type,omitempty"`
+
+	// Code is the response code in c.dd form, e.g. "2.05".
+	Code string `json:"code,omitempty"`
+
+	// CodeName is the name of the response code, e.g. "Content".
+	CodeName string `json:"code_name,omitempty"`
+
+	// ContentFormat is the response's Content-Format option, if present,
+	// e.g. 40 (application/link-format).
+	ContentFormat *uint32 `json:"content_format,omitempty"`
+
+	// LinkFormat is the raw response payload.
+	LinkFormat string `json:"link_format,omitempty"`
+
+	// Resources lists the links parsed from the payload, if it is a CoRE
+	// Link Format document.
+	Resources []*Link `json:"resources,omitempty"`
+
+	// ResourceTypes lists the distinct resource types (rt) of the
+	// resources.
+	ResourceTypes []string `json:"resource_types,omitempty"`
+}
+
+// Flags holds the command-line configuration for the CoAP scan module.
+// Populated by the framework.
+type Flags struct {
+	zgrab2.BaseFlags
+	zgrab2.UDPFlags
+
+	// Path is the resource requested with GET.
+	Path string `long:"path" default:"/.well-known/core" description:"Resource to GET"`
+
+	// MaxBlocks bounds the number of blocks of a block-wise response that
+	// are read.
+	MaxBlocks int `long:"max-blocks" default:"16" description:"Maximum number of blocks of a block-wise (Block2) response to read"`
+
+	// Verbose indicates that there should be more verbose logging.
+	Verbose bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
+}
+
+// Module implements the zgrab2.Module interface.
+type Module struct {
+}
+
+// Scanner implements the zgrab2.Scanner interface.
+type Scanner struct {
+	config *Flags
+}
+
+// RegisterModule registers the zgrab2 module.
+func RegisterModule() {
+	var module Module
+	_, err := zgrab2.AddCommand("coap", "coap", module.Description(), 5683, &module)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewFlags returns a default Flags object.
+func (module *Module) NewFlags() interface{} {
+	return new(Flags)
+}
+
+// NewScanner returns a new Scanner instance.
+func (module *Module) NewScanner() zgrab2.Scanner {
+	return new(Scanner)
+}
+
+// Description returns an overview of this module.
+func (module *Module) Description() string {
+	return "Probe for CoAP servers and list their resources from /.well-known/core"
+}
+
+// Validate checks that the flags are valid.
+// On success, returns nil.
+// On failure, returns an error instance describing the error.
+func (flags *Flags) Validate(args []string) error {
+	if flags.MaxBlocks < 1 {
+		log.Error("--max-blocks must be at least 1")
+		return zgrab2.ErrInvalidArguments
+	}
+	return nil
+}
+
+// Help returns the module's help string.
+func (flags *Flags) Help() string {
+	return ""
+}
+
+// Init initializes the Scanner.
+func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
+	f, _ := flags.(*Flags)
+	scanner.config = f
+	return nil
+}
+
+// InitPerSender initializes the scanner for a given sender.
+func (scanner *Scanner) InitPerSender(senderID int) error {
+	return nil
+}
+
+// GetName returns the Scanner name defined in the Flags.
+func (scanner *Scanner) GetName() string {
+	return scanner.config.Name
+}
+
+// GetTrigger returns the Trigger defined in the Flags.
+func (scanner *Scanner) GetTrigger() string {
+	return scanner.config.Trigger
+}
+
+// Protocol returns the protocol identifier of the scan.
+func (scanner *Scanner) Protocol() string {
+	return "coap"
+}
+
+// newResults returns the ScanResults for a response.
+func newResults(response *Message) *ScanResults {
+	result := &ScanResults{
+		MessageType: typeNames[response.Type],
+		Code:        response.CodeString(),
+		CodeName:    codeNames[response.Code],
+	}
+	if value, ok := response.Option(optionContentFormat); ok {
+		contentFormat := decodeUint(value)
+		result.ContentFormat = &contentFormat
+	}
+	if len(response.Payload) == 0 {
+		return result
+	}
+	result.LinkFormat = string(response.Payload)
+	if result.ContentFormat == nil || *result.ContentFormat == contentFormatLinkFormat {
+		result.Resources = ParseLinkFormat(result.LinkFormat)
+	}
+	seen := make(map[string]bool)
+	for _, resource := range result.Resources {
+		for _, rt := range resource.ResourceTypes {
+			if !seen[rt] {
+				seen[rt] = true
+				result.ResourceTypes = append(result.ResourceTypes, rt)
+			}
+		}
+	}
+	sort.Strings(result.ResourceTypes)
+	return result
+}
+
+// Scan performs the CoAP scan.
+//  1. Open a UDP connection to the target port (default 5683).
+//  2. Send a confirmable GET for --path, and wait for the response with the
+//     matching message ID / token, following any Block2 blocks.
+//  3. If the request is reset, record it and fail with an application error;
+//     if no matching response is received, fail with a protocol error.
+//  4. Record the response code and payload, parsing it as CoRE Link
+//     Format unless its Content-Format says otherwise.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	conn, err := target.OpenUDP(&scanner.config.BaseFlags, &scanner.config.UDPFlags)
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	defer conn.Close()
+
+	response, err := NewClient(conn).Get(scanner.config.Path, scanner.config.MaxBlocks)
+	if response == nil {
+		if err == ErrTooManyMessages {
+			return zgrab2.SCAN_PROTOCOL_ERROR, nil, err
+		}
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	result := newResults(response)
+	if err == ErrReset {
+		return zgrab2.SCAN_APPLICATION_ERROR, result, err
+	}
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+	return zgrab2.SCAN_SUCCESS, result, nil
+}
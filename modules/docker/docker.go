@@ -0,0 +1,130 @@
+package docker
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/zmap/zgrab2/lib/http"
+)
+
+// VersionInfo holds the fields of interest from the Engine API's /version
+// endpoint.
+type VersionInfo struct {
+	Version       string `json:"version,omitempty"`
+	APIVersion    string `json:"api_version,omitempty"`
+	MinAPIVersion string `json:"min_api_version,omitempty"`
+	GitCommit     string `json:"git_commit,omitempty"`
+	GoVersion     string `json:"go_version,omitempty"`
+	OS            string `json:"os,omitempty"`
+	Arch          string `json:"arch,omitempty"`
+	KernelVersion string `json:"kernel_version,omitempty"`
+	BuildTime     string `json:"build_time,omitempty"`
+}
+
+// rawVersion is used to decode the /version response, which uses the
+// Engine API's field names.
+type rawVersion struct {
+	Version       string
+	APIVersion    string `json:"ApiVersion"`
+	MinAPIVersion string
+	GitCommit     string
+	GoVersion     string
+	Os            string
+	Arch          string
+	KernelVersion string
+	BuildTime     string
+}
+
+// Info holds the fields of interest from the Engine API's /info endpoint.
+// Their presence in an unauthenticated response means the daemon is fully
+// exposed.
+type Info struct {
+	ID                string `json:"id,omitempty"`
+	Name              string `json:"name,omitempty"`
+	ServerVersion     string `json:"server_version,omitempty"`
+	OperatingSystem   string `json:"operating_system,omitempty"`
+	OSType            string `json:"os_type,omitempty"`
+	Architecture      string `json:"architecture,omitempty"`
+	KernelVersion     string `json:"kernel_version,omitempty"`
+	Containers        int    `json:"containers"`
+	ContainersRunning int    `json:"containers_running"`
+	Images            int    `json:"images"`
+	NCPU              int    `json:"ncpu,omitempty"`
+	MemTotal          int64  `json:"mem_total,omitempty"`
+	DockerRootDir     string `json:"docker_root_dir,omitempty"`
+}
+
+// rawInfo is used to decode the /info response, which uses the Engine API's
+// field names.
+type rawInfo struct {
+	ID                string
+	Name              string
+	ServerVersion     string
+	OperatingSystem   string
+	OSType            string
+	Architecture      string
+	KernelVersion     string
+	Containers        *int
+	ContainersRunning int
+	Images            *int
+	NCPU              int
+	MemTotal          int64
+	DockerRootDir     string
+}
+
+// parseVersion parses the body of a /version response. It returns nil if
+// the body is not a Docker version object.
+func parseVersion(body string) *VersionInfo {
+	var raw rawVersion
+	if err := json.Unmarshal([]byte(body), &raw); err != nil || raw.APIVersion == "" {
+		return nil
+	}
+	return &VersionInfo{
+		Version:       raw.Version,
+		APIVersion:    raw.APIVersion,
+		MinAPIVersion: raw.MinAPIVersion,
+		GitCommit:     raw.GitCommit,
+		GoVersion:     raw.GoVersion,
+		OS:            raw.Os,
+		Arch:          raw.Arch,
+		KernelVersion: raw.KernelVersion,
+		BuildTime:     raw.BuildTime,
+	}
+}
+
+// parseInfo parses the body of an /info response. It returns nil unless the
+// body carries the container and image counts.
+func parseInfo(body string) *Info {
+	var raw rawInfo
+	if err := json.Unmarshal([]byte(body), &raw); err != nil || raw.Containers == nil || raw.Images == nil {
+		return nil
+	}
+	return &Info{
+		ID:                raw.ID,
+		Name:              raw.Name,
+		ServerVersion:     raw.ServerVersion,
+		OperatingSystem:   raw.OperatingSystem,
+		OSType:            raw.OSType,
+		Architecture:      raw.Architecture,
+		KernelVersion:     raw.KernelVersion,
+		Containers:        *raw.Containers,
+		ContainersRunning: raw.ContainersRunning,
+		Images:            *raw.Images,
+		NCPU:              raw.NCPU,
+		MemTotal:          raw.MemTotal,
+		DockerRootDir:     raw.DockerRootDir,
+	}
+}
+
+// isDocker returns true if the response carries a Docker Server header or
+// Docker's API version header.
+func isDocker(resp *http.Response) bool {
+	return strings.HasPrefix(resp.Header.Get("Server"), "Docker/") || resp.Header.Get("Api-Version") != ""
+}
+
+// isAuthRequired returns true if the response's status indicates that the
+// request was refused for lack of credentials, e.g. by an authorization
+// plugin or a reverse proxy.
+func isAuthRequired(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden
+}
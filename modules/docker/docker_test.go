@@ -0,0 +1,98 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/httpapi/httpapitest"
+)
+
+const testVersion = `{"Platform":{"Name":"Docker Engine - Community"},"Version":"20.10.7","ApiVersion":"1.41","MinAPIVersion":"1.12","GitCommit":"b0f5bc3","GoVersion":"go1.13.15","Os":"linux","Arch":"amd64","KernelVersion":"5.4.0-77-generic","BuildTime":"2021-06-02T11:54:50.000000000+00:00"}`
+
+const testInfo = `{"ID":"ABCD:EFGH","Containers":3,"ContainersRunning":1,"ContainersPaused":0,"ContainersStopped":2,"Images":7,"Name":"docker-host","ServerVersion":"20.10.7","OperatingSystem":"Ubuntu 20.04.2 LTS","OSType":"linux","Architecture":"x86_64","KernelVersion":"5.4.0-77-generic","NCPU":4,"MemTotal":8348520448,"DockerRootDir":"/var/lib/docker"}`
+
+func TestParseVersion(t *testing.T) {
+	version := parseVersion(testVersion)
+	if version == nil {
+		t.Fatal("failed to parse version")
+	}
+	if version.Version != "20.10.7" || version.APIVersion != "1.41" || version.OS != "linux" || version.Arch != "amd64" {
+		t.Errorf("unexpected version: %+v", version)
+	}
+	if parseVersion(`{"message":"page not found"}`) != nil || parseVersion("<html></html>") != nil {
+		t.Error("parsed a non-Docker version response")
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	info := parseInfo(testInfo)
+	if info == nil {
+		t.Fatal("failed to parse info")
+	}
+	if info.Containers != 3 || info.ContainersRunning != 1 || info.Images != 7 || info.Name != "docker-host" {
+		t.Errorf("unexpected info: %+v", info)
+	}
+	if parseInfo(`{"message":"authorization denied by plugin"}`) != nil {
+		t.Error("parsed info without container / image counts")
+	}
+}
+
+// scanTestServer runs the scanner against a local server using handler.
+func scanTestServer(t *testing.T, handler http.HandlerFunc) (status zgrab2.ScanStatus, results *ScanResults, err error) {
+	scanner := &Scanner{config: &Flags{MaxSize: 256, UserAgent: "zgrab2"}}
+	scanner.config.Timeout = 5 * time.Second
+	httpapitest.ServeTarget(t, handler, func(target *zgrab2.ScanTarget) {
+		var result interface{}
+		status, result, err = scanner.Scan(context.Background(), *target)
+		results, _ = result.(*ScanResults)
+	})
+	return status, results, err
+}
+
+func TestScanExposed(t *testing.T) {
+	status, result, err := scanTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "Docker/20.10.7 (linux)")
+		w.Header().Set("Api-Version", "1.41")
+		switch r.URL.Path {
+		case "/version":
+			w.Write([]byte(testVersion))
+		case "/info":
+			w.Write([]byte(testInfo))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	if !result.UnauthenticatedAccess || result.AuthRequired || result.Info == nil || result.Version == nil {
+		t.Errorf("expected an exposed daemon, got %+v", result)
+	}
+}
+
+func TestScanAuthRequired(t *testing.T) {
+	status, result, err := scanTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "Docker/20.10.7 (linux)")
+		w.Header().Set("Api-Version", "1.41")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message":"authorization denied by plugin authz"}`))
+	})
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	if result.UnauthenticatedAccess || !result.AuthRequired {
+		t.Errorf("expected auth_required, got %+v", result)
+	}
+}
+
+func TestScanNotDocker(t *testing.T) {
+	status, _, err := scanTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>hello</html>"))
+	})
+	if status != zgrab2.SCAN_PROTOCOL_ERROR || err != ErrNotDocker {
+		t.Errorf("expected protocol error, got %s: %v", status, err)
+	}
+}
@@ -0,0 +1,245 @@
+// Package docker provides a zgrab2 module that probes for exposed Docker
+// Engine APIs.
+// Default Port: 2375 (TCP)
+//
+// The scanner sends GET /version and GET /info and records the engine and API
+// versions, the OS, and whether authentication is required.
+//
+// An Engine API that answers /info with container and image counts without
+// credentials grants full control of the host; such targets are flagged with
+// unauthenticated_access.
+//
+// The --use-tls flag tells the scanner to connect over TLS, using the standard
+// TLS flags; it is implied when scanning port 2376, the standard port for the
+// TLS-protected API. If the daemon rejects the handshake for lack of a client
+// certificate, auth_required is set.
+package docker
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/http"
+	"github.com/zmap/zgrab2/lib/httpapi"
+)
+
+// dockerTLSPort is the standard port for the TLS-protected Engine API.
+const dockerTLSPort = 2376
+
+// ErrNotDocker is returned when the server's /version response is neither
+// a Docker version object nor carries Docker's headers.
+var ErrNotDocker = errors.New("server is not a Docker Engine API")
+
+// ScanResults instances are returned by the module's Scan function.
+type ScanResults struct {
+	// VersionResponse is the HTTP response to GET /version.
+	VersionResponse *http.Response `json:"version_response,omitempty" zgrab:"debug"`
+
+	// InfoResponse is the HTTP response to GET /info, if it was sent.
+	InfoResponse *http.Response `json:"info_response,omitempty" zgrab:"debug"`
+
+	// Version holds the parsed /version response.
+	Version *VersionInfo `json:"version,omitempty"`
+
+	// Info holds the parsed /info response.
+	Info *Info `json:"info,omitempty"`
+
+	// AuthRequired is true if either request was refused for lack of
+	// credentials (HTTP 401 / 403), or the TLS handshake was rejected for
+	// lack of a client certificate.
+	AuthRequired bool `json:"auth_required"`
+
+	// UnauthenticatedAccess is true if /info returned the daemon's
+	// container and image counts without any credentials, i.e. the Docker
+	// socket is exposed.
+	UnauthenticatedAccess bool `json:"unauthenticated_access"`
+
+	// TLSLog is the standard TLS log, if TLS was used.
+	TLSLog *zgrab2.TLSLog `json:"tls,omitempty"`
+}
+
+// Flags holds the command-line configuration for the docker scan module.
+// Populated by the framework.
+type Flags struct {
+	zgrab2.BaseFlags
+	zgrab2.TLSFlags
+
+	// UseTLS indicates that the client should connect over TLS.
+	UseTLS bool `long:"use-tls" description:"Connect over TLS (implied on port 2376)"`
+
+	// MaxSize bounds the size of each response body that is read.
+	MaxSize int `long:"max-size" default:"256" description:"Max kilobytes to read in response to each request"`
+
+	// UserAgent is sent in the User-Agent header of each request.
+	UserAgent string `long:"user-agent" default:"Mozilla/5.0 zgrab/0.x" description:"Set a custom user agent"`
+
+	// Verbose indicates that there should be more verbose logging.
+	Verbose bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
+}
+
+// Module implements the zgrab2.Module interface.
+type Module struct {
+}
+
+// Scanner implements the zgrab2.Scanner interface.
+type Scanner struct {
+	config *Flags
+}
+
+// scan holds the state of a single scan.
+type scan struct {
+	scanner *Scanner
+	client  *httpapi.Client
+	results ScanResults
+}
+
+// RegisterModule registers the zgrab2 module.
+func RegisterModule() {
+	var module Module
+	_, err := zgrab2.AddCommand("docker", "docker", module.Description(), 2375, &module)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewFlags returns a default Flags object.
+func (module *Module) NewFlags() interface{} {
+	return new(Flags)
+}
+
+// NewScanner returns a new Scanner instance.
+func (module *Module) NewScanner() zgrab2.Scanner {
+	return new(Scanner)
+}
+
+// Description returns an overview of this module.
+func (module *Module) Description() string {
+	return "Probe for exposed Docker Engine APIs with GET /version and /info"
+}
+
+// Validate checks that the flags are valid.
+// On success, returns nil.
+// On failure, returns an error instance describing the error.
+func (flags *Flags) Validate(args []string) error {
+	return nil
+}
+
+// Help returns the module's help string.
+func (flags *Flags) Help() string {
+	return ""
+}
+
+// Init initializes the Scanner.
+func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
+	f, _ := flags.(*Flags)
+	scanner.config = f
+	return nil
+}
+
+// InitPerSender initializes the scanner for a given sender.
+func (scanner *Scanner) InitPerSender(senderID int) error {
+	return nil
+}
+
+// GetName returns the Scanner name defined in the Flags.
+func (scanner *Scanner) GetName() string {
+	return scanner.config.Name
+}
+
+// GetTrigger returns the Trigger defined in the Flags.
+func (scanner *Scanner) GetTrigger() string {
+	return scanner.config.Trigger
+}
+
+// Protocol returns the protocol identifier of the scan.
+func (scanner *Scanner) Protocol() string {
+	return "docker"
+}
+
+// newDockerScan returns a scan of the target, over TLS if useTLS is set.
+func (scanner *Scanner) newDockerScan(target *zgrab2.ScanTarget, port uint, useTLS bool) *scan {
+	ret := &scan{scanner: scanner}
+	ret.client = httpapi.NewClient(target, port, &httpapi.Config{
+		BaseFlags: &scanner.config.BaseFlags,
+		TLSFlags:  &scanner.config.TLSFlags,
+		UseTLS:    useTLS,
+		UserAgent: scanner.config.UserAgent,
+		MaxSize:   scanner.config.MaxSize,
+		TLSLog:    &ret.results.TLSLog,
+	})
+	return ret
+}
+
+// Cleanup closes any connections that have been opened during the scan.
+func (scan *scan) Cleanup() {
+	scan.client.Close()
+}
+
+// isClientCertRequired returns true if err is the server rejecting the TLS
+// handshake for lack of a (valid) client certificate, as dockerd does when
+// started with --tlsverify.
+func isClientCertRequired(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "remote error") && strings.Contains(msg, "certificate")
+}
+
+// Scan performs the Docker Engine API scan.
+//  1. Connect over TLS if --use-tls is set or the port is 2376; otherwise
+//     over plain HTTP.
+//  2. Send GET /version. If the handshake is rejected for lack of a client
+//     certificate, record auth_required and stop. If the response is
+//     neither a Docker version object nor carries Docker's headers, fail
+//     with a protocol error.
+//  3. Send GET /info. If it returns the container and image counts, record
+//     them and flag unauthenticated_access; if it returns 401 / 403, record
+//     auth_required.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	port := scanner.config.Port
+	if target.Port != nil {
+		port = *target.Port
+	}
+	useTLS := scanner.config.UseTLS || port == dockerTLSPort
+	scan := scanner.newDockerScan(&target, port, useTLS)
+	defer scan.Cleanup()
+	result := &scan.results
+
+	resp, err := scan.client.Get("/version")
+	if err != nil {
+		if useTLS && result.TLSLog != nil && isClientCertRequired(err) {
+			result.AuthRequired = true
+			return zgrab2.SCAN_APPLICATION_ERROR, result, err
+		}
+		if result.TLSLog != nil {
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	result.VersionResponse = resp
+	if resp.StatusCode == http.StatusOK {
+		result.Version = parseVersion(resp.BodyText)
+	}
+	if result.Version == nil && !isDocker(resp) {
+		return zgrab2.SCAN_PROTOCOL_ERROR, result, ErrNotDocker
+	}
+	if isAuthRequired(resp) {
+		result.AuthRequired = true
+	}
+
+	resp, err = scan.client.Get("/info")
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+	result.InfoResponse = resp
+	if resp.StatusCode == http.StatusOK {
+		if result.Info = parseInfo(resp.BodyText); result.Info != nil {
+			result.UnauthenticatedAccess = true
+		}
+	}
+	if isAuthRequired(resp) {
+		result.AuthRequired = true
+	}
+	return zgrab2.SCAN_SUCCESS, result, nil
+}
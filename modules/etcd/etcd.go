@@ -0,0 +1,155 @@
+package etcd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// v3Prefixes are the gRPC-gateway prefixes, newest first: etcd 3.4+ serves
+// /v3, 3.3 serves /v3beta and 3.2 serves /v3alpha.
+var v3Prefixes = []string{"/v3", "/v3beta", "/v3alpha"}
+
+// v3AuthErrors are substrings of the gRPC-gateway error messages returned
+// when a request is refused because auth is enabled.
+var v3AuthErrors = []string{
+	"user name is empty",
+	"invalid auth token",
+	"permission denied",
+	"authentication failed",
+}
+
+// rawVersion is used to decode the /version response.
+type rawVersion struct {
+	Server  string `json:"etcdserver"`
+	Cluster string `json:"etcdcluster"`
+}
+
+// parseVersion parses the body of a /version response. It returns nil if the
+// body is not an etcd version object.
+func parseVersion(body string) *rawVersion {
+	var version rawVersion
+	if err := json.Unmarshal([]byte(body), &version); err != nil || version.Server == "" {
+		return nil
+	}
+	return &version
+}
+
+// APIResult holds the outcome of listing keys through one API version.
+type APIResult struct {
+	// Endpoint is the path that answered, e.g. "/v2/keys/" or
+	// "/v3/kv/range".
+	Endpoint string `json:"endpoint"`
+
+	// StatusCode is the HTTP status of the response.
+	StatusCode int `json:"status_code"`
+
+	// AuthRequired is true if the request was refused because auth is
+	// enabled.
+	AuthRequired bool `json:"auth_required"`
+
+	// Readable is true if keys were listed without credentials.
+	Readable bool `json:"readable"`
+
+	// KeyCount is the number of keys reported: for v3, the total count; for
+	// v2, the number of top-level nodes.
+	KeyCount int64 `json:"key_count,omitempty"`
+
+	// Keys holds the names (not values) of up to --max-keys keys.
+	Keys []string `json:"keys,omitempty"`
+
+	// Error is the error message returned by the server, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// rawV2Response is used to decode /v2/keys/ responses.
+type rawV2Response struct {
+	Action string `json:"action"`
+	Node   *struct {
+		Nodes []struct {
+			Key string `json:"key"`
+		} `json:"nodes"`
+	} `json:"node"`
+	ErrorCode *int   `json:"errorCode"`
+	Message   string `json:"message"`
+}
+
+// parseV2Keys parses the body of a /v2/keys/ response into result. It
+// returns false if the body is not a v2 keys response.
+func parseV2Keys(body string, maxKeys int, result *APIResult) bool {
+	var raw rawV2Response
+	if err := json.Unmarshal([]byte(body), &raw); err != nil {
+		return false
+	}
+	if raw.ErrorCode != nil {
+		result.Error = raw.Message
+		// 110: EcodeUnauthorized
+		result.AuthRequired = *raw.ErrorCode == 110
+		return true
+	}
+	if raw.Action != "get" || raw.Node == nil {
+		return false
+	}
+	result.Readable = true
+	result.KeyCount = int64(len(raw.Node.Nodes))
+	for i, node := range raw.Node.Nodes {
+		if i >= maxKeys {
+			break
+		}
+		result.Keys = append(result.Keys, node.Key)
+	}
+	return true
+}
+
+// v3RangeRequest is the body of a /v3/kv/range request listing all keys
+// (from "\x00" with range_end "\x00"), without their values.
+func v3RangeRequest(maxKeys int) string {
+	zero := base64.StdEncoding.EncodeToString([]byte{0})
+	return `{"key":"` + zero + `","range_end":"` + zero + `","keys_only":true,"limit":` + strconv.Itoa(maxKeys) + `}`
+}
+
+// rawV3Response is used to decode /v3/kv/range responses.
+type rawV3Response struct {
+	Header *struct {
+		ClusterID string `json:"cluster_id"`
+	} `json:"header"`
+	Kvs []struct {
+		Key []byte `json:"key"`
+	} `json:"kvs"`
+	// Count is encoded as a string, as it is an int64.
+	Count string `json:"count"`
+	// Older gateways use "error", newer ones "message".
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// parseV3Range parses the body of a /v3/kv/range response into result. It
+// returns false if the body is not a v3 range response.
+func parseV3Range(body string, maxKeys int, result *APIResult) bool {
+	var raw rawV3Response
+	if err := json.Unmarshal([]byte(body), &raw); err != nil {
+		return false
+	}
+	if msg := raw.Error + raw.Message; msg != "" && raw.Header == nil {
+		result.Error = msg
+		for _, authError := range v3AuthErrors {
+			if strings.Contains(msg, authError) {
+				result.AuthRequired = true
+			}
+		}
+		return true
+	}
+	if raw.Header == nil {
+		return false
+	}
+	result.Readable = true
+	result.KeyCount, _ = strconv.ParseInt(raw.Count, 10, 64)
+	for i, kv := range raw.Kvs {
+		if i >= maxKeys {
+			break
+		}
+		result.Keys = append(result.Keys, string(kv.Key))
+	}
+	return true
+}
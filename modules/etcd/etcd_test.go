@@ -0,0 +1,110 @@
+package etcd
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/httpapi/httpapitest"
+)
+
+// scanTestServer runs the scanner, listing up to 10 keys, against a local
+// server using handler.
+func scanTestServer(t *testing.T, handler http.HandlerFunc) (status zgrab2.ScanStatus, results *ScanResults, err error) {
+	scanner := &Scanner{config: &Flags{MaxKeys: 10, MaxSize: 256, UserAgent: "zgrab2"}}
+	scanner.config.Timeout = 5 * time.Second
+	httpapitest.ServeTarget(t, handler, func(target *zgrab2.ScanTarget) {
+		var result interface{}
+		status, result, err = scanner.Scan(context.Background(), *target)
+		results, _ = result.(*ScanResults)
+	})
+	return status, results, err
+}
+
+func TestScanV3Readable(t *testing.T) {
+	status, result, err := scanTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/version":
+			w.Write([]byte(`{"etcdserver":"3.4.13","etcdcluster":"3.4.0"}`))
+		case "/v3/kv/range":
+			body, _ := ioutil.ReadAll(r.Body)
+			if !strings.Contains(string(body), `"keys_only":true`) {
+				t.Errorf("range request is not keys-only: %s", body)
+			}
+			// "/registry/secrets/default/token", "/registry/pods/default/web"
+			w.Write([]byte(`{"header":{"cluster_id":"14841639068965178418","revision":"42"},` +
+				`"kvs":[{"key":"L3JlZ2lzdHJ5L3NlY3JldHMvZGVmYXVsdC90b2tlbg=="},{"key":"L3JlZ2lzdHJ5L3BvZHMvZGVmYXVsdC93ZWI="}],"count":"2"}`))
+		default:
+			// v2 is disabled by default in etcd 3.4.
+			http.NotFound(w, r)
+		}
+	})
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	if !result.ReadableWithoutAuth || result.AuthEnabled || result.ServerVersion != "3.4.13" || result.ClusterVersion != "3.4.0" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if !reflect.DeepEqual(result.APIVersions, []string{"v3"}) || result.V2 != nil {
+		t.Errorf("expected only v3 to answer, got %v", result.APIVersions)
+	}
+	if result.V3.KeyCount != 2 || !reflect.DeepEqual(result.V3.Keys, []string{"/registry/secrets/default/token", "/registry/pods/default/web"}) {
+		t.Errorf("unexpected v3 result: %+v", result.V3)
+	}
+}
+
+func TestScanAuthEnabled(t *testing.T) {
+	status, result, err := scanTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/version":
+			w.Write([]byte(`{"etcdserver":"3.3.25","etcdcluster":"3.3.0"}`))
+		case "/v3beta/kv/range":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"etcdserver: user name is empty","code":3}`))
+		case "/v2/keys/":
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"errorCode":110,"message":"The request requires user authentication","cause":"Insufficient credentials","index":0}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	if result.ReadableWithoutAuth || !result.AuthEnabled {
+		t.Errorf("expected auth enabled, got %+v", result)
+	}
+	if !reflect.DeepEqual(result.APIVersions, []string{"v3", "v2"}) || result.V3.Endpoint != "/v3beta/kv/range" {
+		t.Errorf("unexpected APIs: %v, %+v", result.APIVersions, result.V3)
+	}
+}
+
+func TestScanV2Readable(t *testing.T) {
+	_, result, _ := scanTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/version":
+			w.Write([]byte(`{"etcdserver":"2.3.8","etcdcluster":"2.3.0"}`))
+		case "/v2/keys/":
+			w.Write([]byte(`{"action":"get","node":{"dir":true,"nodes":[{"key":"/coreos.com","dir":true}]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	if !result.ReadableWithoutAuth || result.V2 == nil || result.V2.KeyCount != 1 || result.V3 != nil {
+		t.Errorf("expected v2 to be readable, got %+v", result)
+	}
+}
+
+func TestScanNotEtcd(t *testing.T) {
+	status, _, err := scanTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"version":"1.0"}`))
+	})
+	if status != zgrab2.SCAN_PROTOCOL_ERROR || err != ErrNotEtcd {
+		t.Errorf("expected protocol error, got %s: %v", status, err)
+	}
+}
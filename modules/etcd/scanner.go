@@ -0,0 +1,283 @@
+// Package etcd provides a zgrab2 module that probes for exposed etcd
+// servers.
+// Default Port: 2379 (TCP)
+//
+// The scanner sends GET /version, then tries to list keys (names only)
+// through both the v3 gRPC gateway (POST /v3/kv/range, falling back to the
+// /v3beta and /v3alpha prefixes of older releases) and the v2 API (GET
+// /v2/keys/). It records the server and cluster versions, whether auth is
+// enabled, and which API versions answered.
+//
+// An etcd that lists keys without credentials typically exposes everything
+// stored in it, e.g. all of a Kubernetes cluster's secrets; such targets
+// are flagged with readable_without_auth.
+//
+// The --use-tls flag tells the scanner to connect over TLS, using the
+// standard TLS flags.
+package etcd
+
+import (
+	"context"
+	"errors"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/http"
+	"github.com/zmap/zgrab2/lib/httpapi"
+)
+
+// ErrNotEtcd is returned when the server's /version response is not an etcd
+// version object.
+var ErrNotEtcd = errors.New("server is not etcd")
+
+// ScanResults instances are returned by the module's Scan function.
+type ScanResults struct {
+	// VersionResponse is the HTTP response to GET /version.
+	VersionResponse *http.Response `json:"version_response,omitempty" zgrab:"debug"`
+
+	// ServerVersion is the etcd server version, e.g. "3.4.13".
+	ServerVersion string `json:"server_version,omitempty"`
+
+	// ClusterVersion is the etcd cluster version, e.g. "3.4.0".
+	ClusterVersion string `json:"cluster_version,omitempty"`
+
+	// V3 is the outcome of listing keys through the v3 gRPC gateway, if it
+	// answered.
+	V3 *APIResult `json:"v3,omitempty"`
+
+	// V2 is the outcome of listing keys through the v2 API, if it answered.
+	V2 *APIResult `json:"v2,omitempty"`
+
+	// APIVersions lists the API versions that answered, "v3" and / or "v2".
+	APIVersions []string `json:"api_versions,omitempty"`
+
+	// AuthEnabled is true if either API refused the request because auth
+	// is enabled.
+	AuthEnabled bool `json:"auth_enabled"`
+
+	// ReadableWithoutAuth is true if either API listed keys without
+	// credentials.
+	ReadableWithoutAuth bool `json:"readable_without_auth"`
+
+	// TLSLog is the standard TLS log, if --use-tls is enabled.
+	TLSLog *zgrab2.TLSLog `json:"tls,omitempty"`
+}
+
+// Flags holds the command-line configuration for the etcd scan module.
+// Populated by the framework.
+type Flags struct {
+	zgrab2.BaseFlags
+	zgrab2.TLSFlags
+
+	// UseTLS indicates that the client should connect over TLS.
+	UseTLS bool `long:"use-tls" description:"Connect over TLS"`
+
+	// MaxKeys bounds the number of key names recorded per API.
+	MaxKeys int `long:"max-keys" default:"10" description:"Maximum number of key names to record per API version"`
+
+	// MaxSize bounds the size of each response body that is read.
+	MaxSize int `long:"max-size" default:"256" description:"Max kilobytes to read in response to each request"`
+
+	// UserAgent is sent in the User-Agent header of each request.
+	UserAgent string `long:"user-agent" default:"Mozilla/5.0 zgrab/0.x" description:"Set a custom user agent"`
+
+	// Verbose indicates that there should be more verbose logging.
+	Verbose bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
+}
+
+// Module implements the zgrab2.Module interface.
+type Module struct {
+}
+
+// Scanner implements the zgrab2.Scanner interface.
+type Scanner struct {
+	config *Flags
+}
+
+// scan holds the state of a single scan.
+type scan struct {
+	scanner *Scanner
+	client  *httpapi.Client
+	results ScanResults
+}
+
+// RegisterModule registers the zgrab2 module.
+func RegisterModule() {
+	var module Module
+	_, err := zgrab2.AddCommand("etcd", "etcd", module.Description(), 2379, &module)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewFlags returns a default Flags object.
+func (module *Module) NewFlags() interface{} {
+	return new(Flags)
+}
+
+// NewScanner returns a new Scanner instance.
+func (module *Module) NewScanner() zgrab2.Scanner {
+	return new(Scanner)
+}
+
+// Description returns an overview of this module.
+func (module *Module) Description() string {
+	return "Probe for etcd servers whose keys can be listed without authentication"
+}
+
+// Validate checks that the flags are valid.
+// On success, returns nil.
+// On failure, returns an error instance describing the error.
+func (flags *Flags) Validate(args []string) error {
+	if flags.MaxKeys <= 0 {
+		log.Error("--max-keys must be positive")
+		return zgrab2.ErrInvalidArguments
+	}
+	return nil
+}
+
+// Help returns the module's help string.
+func (flags *Flags) Help() string {
+	return ""
+}
+
+// Init initializes the Scanner.
+func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
+	f, _ := flags.(*Flags)
+	scanner.config = f
+	return nil
+}
+
+// InitPerSender initializes the scanner for a given sender.
+func (scanner *Scanner) InitPerSender(senderID int) error {
+	return nil
+}
+
+// GetName returns the Scanner name defined in the Flags.
+func (scanner *Scanner) GetName() string {
+	return scanner.config.Name
+}
+
+// GetTrigger returns the Trigger defined in the Flags.
+func (scanner *Scanner) GetTrigger() string {
+	return scanner.config.Trigger
+}
+
+// Protocol returns the protocol identifier of the scan.
+func (scanner *Scanner) Protocol() string {
+	return "etcd"
+}
+
+// newEtcdScan returns a scan of the target.
+func (scanner *Scanner) newEtcdScan(target *zgrab2.ScanTarget) *scan {
+	port := scanner.config.Port
+	if target.Port != nil {
+		port = *target.Port
+	}
+	ret := &scan{scanner: scanner}
+	ret.client = httpapi.NewClient(target, port, &httpapi.Config{
+		BaseFlags: &scanner.config.BaseFlags,
+		TLSFlags:  &scanner.config.TLSFlags,
+		UseTLS:    scanner.config.UseTLS,
+		UserAgent: scanner.config.UserAgent,
+		MaxSize:   scanner.config.MaxSize,
+		TLSLog:    &ret.results.TLSLog,
+	})
+	return ret
+}
+
+// Cleanup closes any connections that have been opened during the scan.
+func (scan *scan) Cleanup() {
+	scan.client.Close()
+}
+
+// do sends a request for endpoint, with a JSON body if body is not empty,
+// and reads up to --max-size KB of the response body into its BodyText.
+func (scan *scan) do(method string, endpoint string, body string) (*http.Response, error) {
+	resp, _, err := scan.client.Do(method, endpoint, "application/json", body)
+	return resp, err
+}
+
+// listV3 tries to list keys through the v3 gRPC gateway, trying each of
+// v3Prefixes in turn. It returns nil if none of them answered.
+func (scan *scan) listV3() (*APIResult, error) {
+	maxKeys := scan.scanner.config.MaxKeys
+	for _, prefix := range v3Prefixes {
+		endpoint := prefix + "/kv/range"
+		resp, err := scan.do("POST", endpoint, v3RangeRequest(maxKeys))
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			continue
+		}
+		result := &APIResult{Endpoint: endpoint, StatusCode: resp.StatusCode}
+		if parseV3Range(resp.BodyText, maxKeys, result) {
+			return result, nil
+		}
+	}
+	return nil, nil
+}
+
+// listV2 tries to list keys through the v2 API. It returns nil if it did not
+// answer (e.g. etcd 3.4+, where v2 is disabled by default).
+func (scan *scan) listV2() (*APIResult, error) {
+	const endpoint = "/v2/keys/"
+	resp, err := scan.do("GET", endpoint, "")
+	if err != nil {
+		return nil, err
+	}
+	result := &APIResult{Endpoint: endpoint, StatusCode: resp.StatusCode}
+	if resp.StatusCode == http.StatusNotFound || !parseV2Keys(resp.BodyText, scan.scanner.config.MaxKeys, result) {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// Scan performs the etcd scan.
+//  1. Send GET /version. If the response is not an etcd version object,
+//     fail with a protocol error.
+//  2. POST a keys-only range request for all keys to /v3/kv/range (or the
+//     /v3beta, /v3alpha equivalents).
+//  3. Send GET /v2/keys/.
+//  4. Record which APIs answered, whether either refused the request
+//     because auth is enabled, and whether either listed keys.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	scan := scanner.newEtcdScan(&target)
+	defer scan.Cleanup()
+	result := &scan.results
+
+	resp, err := scan.do("GET", "/version", "")
+	if err != nil {
+		if result.TLSLog != nil {
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	result.VersionResponse = resp
+	version := parseVersion(resp.BodyText)
+	if resp.StatusCode != http.StatusOK || version == nil {
+		return zgrab2.SCAN_PROTOCOL_ERROR, result, ErrNotEtcd
+	}
+	result.ServerVersion = version.Server
+	result.ClusterVersion = version.Cluster
+
+	if result.V3, err = scan.listV3(); err != nil {
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+	if result.V2, err = scan.listV2(); err != nil {
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+	for _, api := range []struct {
+		name   string
+		result *APIResult
+	}{{"v3", result.V3}, {"v2", result.V2}} {
+		if api.result == nil {
+			continue
+		}
+		result.APIVersions = append(result.APIVersions, api.name)
+		result.AuthEnabled = result.AuthEnabled || api.result.AuthRequired
+		result.ReadableWithoutAuth = result.ReadableWithoutAuth || api.result.Readable
+	}
+	return zgrab2.SCAN_SUCCESS, result, nil
+}
@@ -0,0 +1,160 @@
+// Package finger provides a zgrab2 module that queries finger servers.
+// Default Port: 79 (TCP)
+//
+// The scanner sends a single query line, as described in RFC 1288: the
+// --user flag, or else an empty line, which asks the server to list the users
+// logged in. It records the response text, which on legacy or misconfigured
+// hosts leaks user names, login times and the like.
+package finger
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmap/zgrab2"
+)
+
+// ErrEmptyResponse is returned when the server closes the connection without
+// sending a response.
+var ErrEmptyResponse = zgrab2.ErrEmptyResponse
+
+// ScanResults instances are returned by the module's Scan function.
+type ScanResults struct {
+	// Query is the query that was sent: the user name, or "" to list the
+	// users.
+	Query string `json:"query"`
+
+	// Response is the full response text.
+	Response string `json:"response"`
+
+	// Truncated is true if the response was longer than --max-size, so only
+	// its start is in Response.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Flags holds the command-line configuration for the finger scan module.
+// Populated by the framework.
+type Flags struct {
+	zgrab2.BaseFlags
+
+	// User is the user name to query; if it is empty, the server is asked
+	// to list its users.
+	User string `long:"user" description:"User name to query; by default, send an empty query to list the users logged in"`
+
+	// MaxSize bounds the size of the response that is read.
+	MaxSize int `long:"max-size" default:"64" description:"Max kilobytes to read in response to the query"`
+
+	// Verbose indicates that there should be more verbose logging.
+	Verbose bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
+}
+
+// Module implements the zgrab2.Module interface.
+type Module struct {
+}
+
+// Scanner implements the zgrab2.Scanner interface.
+type Scanner struct {
+	config *Flags
+}
+
+// RegisterModule registers the zgrab2 module.
+func RegisterModule() {
+	var module Module
+	_, err := zgrab2.AddCommand("finger", "finger", module.Description(), 79, &module)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewFlags returns a default Flags object.
+func (module *Module) NewFlags() interface{} {
+	return new(Flags)
+}
+
+// NewScanner returns a new Scanner instance.
+func (module *Module) NewScanner() zgrab2.Scanner {
+	return new(Scanner)
+}
+
+// Description returns an overview of this module.
+func (module *Module) Description() string {
+	return "Send a finger query for a user, or for the list of users, and record the response"
+}
+
+// Validate checks that the flags are valid.
+// On success, returns nil.
+// On failure, returns an error instance describing the error.
+func (flags *Flags) Validate(args []string) error {
+	if flags.MaxSize <= 0 {
+		log.Errorf("--max-size must be positive, given %d", flags.MaxSize)
+		return zgrab2.ErrInvalidArguments
+	}
+	return nil
+}
+
+// Help returns the module's help string.
+func (flags *Flags) Help() string {
+	return ""
+}
+
+// Init initializes the Scanner.
+func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
+	f, _ := flags.(*Flags)
+	scanner.config = f
+	return nil
+}
+
+// InitPerSender initializes the scanner for a given sender.
+func (scanner *Scanner) InitPerSender(senderID int) error {
+	return nil
+}
+
+// GetName returns the Scanner name defined in the Flags.
+func (scanner *Scanner) GetName() string {
+	return scanner.config.Name
+}
+
+// GetTrigger returns the Trigger defined in the Flags.
+func (scanner *Scanner) GetTrigger() string {
+	return scanner.config.Trigger
+}
+
+// Protocol returns the protocol identifier of the scan.
+func (scanner *Scanner) Protocol() string {
+	return "finger"
+}
+
+// Scan performs the finger scan.
+//  1. Open a TCP connection to the target port (default 79).
+//  2. Send the --user query, or an empty line to list the users.
+//  3. Read the response until the server closes the connection, up to
+//     --max-size KB. If the server sends nothing, fail with a protocol
+//     error; if the read times out after part of the response, record it
+//     and fail with the timeout.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	conn, err := target.Open(&scanner.config.BaseFlags)
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	zgrab2.EnableTLSDetection(conn)
+	defer conn.Close()
+	result := &ScanResults{Query: scanner.config.User}
+	if _, err := conn.Write([]byte(result.Query + "\r\n")); err != nil {
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+
+	maxSize := scanner.config.MaxSize * 1024
+	data, truncated, err := zgrab2.ReadUntilClose(conn, maxSize)
+	if data == nil {
+		if err == ErrEmptyResponse {
+			return zgrab2.SCAN_PROTOCOL_ERROR, nil, err
+		}
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	result.Response = string(data)
+	result.Truncated = truncated
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+	return zgrab2.SCAN_SUCCESS, result, nil
+}
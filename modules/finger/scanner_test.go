@@ -0,0 +1,69 @@
+package finger
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+// serveFinger accepts connections on listener, listing the users for an
+// empty query, describing root, and sending nothing for other users.
+func serveFinger(t *testing.T, listener net.Listener) {
+	for {
+		c, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(c net.Conn) {
+			defer c.Close()
+			line, _ := bufio.NewReader(c).ReadString('\n')
+			switch line {
+			case "\r\n":
+				c.Write([]byte("Login     Name       Tty      Idle  Login Time\r\nroot      root       pts/0          Jan  1 00:00\r\n"))
+			case "root\r\n":
+				c.Write([]byte("Login: root           \t\t\tName: root\r\nDirectory: /root                    \tShell: /bin/bash\r\n"))
+			}
+		}(c)
+	}
+}
+
+func TestScan(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveFinger(t, listener)
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	target := zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port}
+
+	tests := []struct {
+		user     string
+		status   zgrab2.ScanStatus
+		response string
+	}{
+		{"", zgrab2.SCAN_SUCCESS, "Login     Name       Tty      Idle  Login Time\r\nroot      root       pts/0          Jan  1 00:00\r\n"},
+		{"root", zgrab2.SCAN_SUCCESS, "Login: root           \t\t\tName: root\r\nDirectory: /root                    \tShell: /bin/bash\r\n"},
+		{"nobody", zgrab2.SCAN_PROTOCOL_ERROR, ""},
+	}
+	for _, test := range tests {
+		scanner := &Scanner{config: &Flags{User: test.user, MaxSize: 64}}
+		scanner.config.Timeout = 5 * time.Second
+		status, res, err := scanner.Scan(context.Background(), target)
+		if status != test.status {
+			t.Errorf("%q: expected status %s, got %s (%v)", test.user, test.status, status, err)
+			continue
+		}
+		if test.status != zgrab2.SCAN_SUCCESS {
+			continue
+		}
+		result := res.(*ScanResults)
+		if result.Query != test.user || result.Response != test.response || result.Truncated {
+			t.Errorf("%q: unexpected result %+v", test.user, result)
+		}
+	}
+}
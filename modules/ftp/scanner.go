@@ -11,6 +11,7 @@
 package ftp
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"regexp"
@@ -246,12 +247,13 @@ func (ftp *Connection) GetFTPSCertificates() error {
 // * Perform ths TLS handshake / any configured TLS scans, populating
 //   results.TLSLog.
 // * Return SCAN_SUCCESS, &results, nil
-func (s *Scanner) Scan(t zgrab2.ScanTarget) (status zgrab2.ScanStatus, result interface{}, thrown error) {
+func (s *Scanner) Scan(ctx context.Context, t zgrab2.ScanTarget) (status zgrab2.ScanStatus, result interface{}, thrown error) {
 	var err error
 	conn, err := t.Open(&s.config.BaseFlags)
 	if err != nil {
 		return zgrab2.TryGetScanStatus(err), nil, err
 	}
+	zgrab2.EnableTLSDetection(conn)
 	cn := conn
 	defer func() {
 		cn.Close()
@@ -0,0 +1,127 @@
+package http
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/zmap/zgrab2/lib/http"
+)
+
+// redactedCredentials replaces the credentials in the Authorization header
+// of the requests recorded in the output.
+const redactedCredentials = "<redacted>"
+
+// AuthResults records the response to the request repeated with the
+// --auth-basic or --auth-bearer credentials.
+type AuthResults struct {
+	// Scheme is the authentication scheme used: "basic" or "bearer".
+	Scheme string `json:"scheme,omitempty"`
+
+	// Challenge is the WWW-Authenticate header of the unauthenticated
+	// response.
+	Challenge string `json:"challenge,omitempty"`
+
+	// UnauthenticatedStatusCode is the status code of the unauthenticated
+	// response.
+	UnauthenticatedStatusCode int `json:"unauthenticated_status_code,omitempty"`
+
+	// StatusCode is the status code of the authenticated response.
+	StatusCode int `json:"status_code,omitempty"`
+
+	// StatusChanged is true if the authenticated response's status code
+	// differs from the unauthenticated one.
+	StatusChanged bool `json:"status_changed"`
+
+	// Response is the authenticated response. The credentials in its
+	// request's Authorization header are redacted.
+	Response *http.Response `json:"response,omitempty"`
+
+	// Error is set if the authenticated request failed.
+	Error string `json:"error,omitempty"`
+}
+
+// setAuthorization sets the Authorization header for the configured
+// credentials, returning the scheme used.
+func (scanner *Scanner) setAuthorization(request *http.Request) string {
+	if scanner.config.AuthBearer != "" {
+		request.Header.Set("Authorization", "Bearer "+scanner.config.AuthBearer)
+		return "bearer"
+	}
+	user, pass := splitBasicAuth(scanner.config.AuthBasic)
+	request.SetBasicAuth(user, pass)
+	return "basic"
+}
+
+// splitBasicAuth splits a user:pass pair at the first colon.
+func splitBasicAuth(auth string) (string, string) {
+	parts := strings.SplitN(auth, ":", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// redactAuthorization replaces the credentials in the Authorization header
+// of each request that led to resp, keeping only the scheme.
+func redactAuthorization(resp *http.Response) {
+	for ; resp != nil && resp.Request != nil; resp = resp.Request.Response {
+		header := resp.Request.Header
+		if auth := header.Get("Authorization"); auth != "" {
+			scheme := strings.SplitN(auth, " ", 2)[0]
+			header.Set("Authorization", scheme+" "+redactedCredentials)
+		}
+	}
+}
+
+// GrabAuth repeats the initial request with the configured credentials and
+// records the result in scan.results.Auth. With --auth-on-challenge, this is
+// only done if the initial response was 401 Unauthorized. Redirects are
+// recorded rather than followed. Failures are recorded in the result rather
+// than returned, since they do not affect the initial request.
+func (scan *scan) GrabAuth() {
+	unauthenticated := scan.results.Response
+	if unauthenticated == nil {
+		return
+	}
+	if scan.scanner.config.AuthOnChallenge && unauthenticated.StatusCode != 401 {
+		return
+	}
+	result := &AuthResults{
+		Challenge:                 unauthenticated.Header.Get("WWW-Authenticate"),
+		UnauthenticatedStatusCode: unauthenticated.StatusCode,
+	}
+	scan.results.Auth = result
+	request, err := http.NewRequest(scan.scanner.config.Method, scan.url, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	request.Header.Set("Accept", "*/*")
+	scan.setHeaders(request)
+	if request.Header.Get("Accept-Encoding") == "" && request.Method != "HEAD" {
+		request.Header.Set("Accept-Encoding", "gzip, deflate")
+	}
+	result.Scheme = scan.scanner.setAuthorization(request)
+
+	scan.client.CheckRedirect = func(*http.Request, *http.Response, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := scan.client.Do(request)
+	if resp != nil && resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	redactAuthorization(resp)
+	if err != nil {
+		if urlError, ok := err.(*url.Error); ok {
+			err = urlError.Err
+		}
+		result.Error = err.Error()
+	}
+	if resp == nil {
+		return
+	}
+	scan.readBody(resp)
+	result.Response = resp
+	result.StatusCode = resp.StatusCode
+	result.StatusChanged = resp.StatusCode != result.UnauthenticatedStatusCode
+}
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	stdhttp "net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/zmap/zgrab2"
+)
+
+// authHandler requires Basic credentials admin:secret for /private, and
+// accepts anything elsewhere.
+func authHandler(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	if r.URL.Path != "/private" {
+		w.Write([]byte("public"))
+		return
+	}
+	if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="zgrab"`)
+		w.WriteHeader(401)
+		return
+	}
+	w.Write([]byte("private"))
+}
+
+func TestAuth(t *testing.T) {
+	server := httptest.NewServer(stdhttp.HandlerFunc(authHandler))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	_, portString, _ := net.SplitHostPort(serverURL.Host)
+	port, _ := net.LookupPort("tcp", portString)
+
+	tests := []struct {
+		name            string
+		endpoint        string
+		basic           string
+		bearer          string
+		onChallenge     bool
+		expectAuth      bool
+		expectStatus    int
+		expectChanged   bool
+		expectAuthValue string
+	}{
+		{"basic", "/private", "admin:secret", "", false, true, 200, true, "Basic <redacted>"},
+		{"bad password", "/private", "admin:wrong", "", true, true, 401, false, "Basic <redacted>"},
+		{"bearer", "/", "", "token", false, true, 200, false, "Bearer <redacted>"},
+		{"no challenge", "/", "", "token", true, false, 0, false, ""},
+	}
+	for _, test := range tests {
+		var module Module
+		flags := module.NewFlags().(*Flags)
+		flags.Endpoint = test.endpoint
+		flags.Method = "GET"
+		flags.UserAgent = "Mozilla/5.0 zgrab/0.x"
+		flags.MaxSize = 256
+		flags.Timeout = 5 * time.Second
+		flags.Port = uint(port)
+		flags.AuthBasic = test.basic
+		flags.AuthBearer = test.bearer
+		flags.AuthOnChallenge = test.onChallenge
+		if err := flags.Validate(nil); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		scanner := module.NewScanner().(*Scanner)
+		scanner.Init(flags)
+
+		status, ret, err := scanner.Scan(context.Background(), zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1")})
+		if status != zgrab2.SCAN_SUCCESS {
+			t.Fatalf("%s: unexpected status %s (%v)", test.name, status, err)
+		}
+		results := ret.(*Results)
+		if results.Response.Request.Header.Get("Authorization") != "" {
+			t.Errorf("%s: initial request was authenticated", test.name)
+		}
+		if !test.expectAuth {
+			if results.Auth != nil {
+				t.Errorf("%s: unexpected auth result %+v", test.name, results.Auth)
+			}
+			continue
+		}
+		auth := results.Auth
+		if auth == nil || auth.Response == nil {
+			t.Fatalf("%s: no auth response: %+v", test.name, auth)
+		}
+		if auth.StatusCode != test.expectStatus || auth.StatusChanged != test.expectChanged {
+			t.Errorf("%s: unexpected auth result %+v", test.name, auth)
+		}
+		if value := auth.Response.Request.Header.Get("Authorization"); value != test.expectAuthValue {
+			t.Errorf("%s: expected Authorization %q, got %q", test.name, test.expectAuthValue, value)
+		}
+		if test.endpoint == "/private" && auth.Challenge != `Basic realm="zgrab"` {
+			t.Errorf("%s: unexpected challenge %q", test.name, auth.Challenge)
+		}
+	}
+}
+
+func TestValidateAuth(t *testing.T) {
+	invalid := []*Flags{
+		{AuthBasic: "admin:secret", AuthBearer: "token"},
+		{AuthBasic: "admin"},
+		{AuthOnChallenge: true},
+	}
+	for _, flags := range invalid {
+		if err := flags.Validate(nil); err != zgrab2.ErrInvalidArguments {
+			t.Errorf("expected %+v to be rejected", flags)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+// runRawServer starts a server that reads each request's headers and answers
+// with response, then closes the connection. It returns the server's port.
+func runRawServer(t *testing.T, response string) (uint, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil || line == "\r\n" {
+						break
+					}
+				}
+				conn.Write([]byte(response))
+			}(conn)
+		}
+	}()
+	return uint(listener.Addr().(*net.TCPAddr).Port), func() { listener.Close() }
+}
+
+func TestLegacyResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		allow    bool
+		status   zgrab2.ScanStatus
+		protocol string
+		body     string
+		invalid  string
+	}{
+		{"http/1.1", "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhello", false, zgrab2.SCAN_SUCCESS, "HTTP/1.1", "hello", ""},
+		{"http/0.9", "<html>camera</html>\n", true, zgrab2.SCAN_PROTOCOL_ERROR, "HTTP/0.9", "<html>camera</html>\n", ""},
+		{"http/0.9 not allowed", "<html>camera</html>\n", false, zgrab2.SCAN_PROTOCOL_ERROR, "", "", "<html>camera</html>\n"},
+		{"banner", "220 mail.example.com ESMTP\r\n", false, zgrab2.SCAN_PROTOCOL_ERROR, "", "", "220 mail.example.com ESMTP\r\n"},
+		{"binary", "\x00\x00\x00\x0c\xff\xfe", true, zgrab2.SCAN_PROTOCOL_ERROR, "", "", "\x00\x00\x00\x0c\xff\xfe"},
+		{"bad status", "HTTP/1.0 OK\r\n\r\n", false, zgrab2.SCAN_PROTOCOL_ERROR, "", "", "HTTP/1.0 OK\r\n\r\n"},
+	}
+	for _, test := range tests {
+		port, stop := runRawServer(t, test.response)
+
+		var module Module
+		flags := module.NewFlags().(*Flags)
+		flags.Endpoint = "/"
+		flags.Method = "GET"
+		flags.UserAgent = "Mozilla/5.0 zgrab/0.x"
+		flags.MaxSize = 256
+		flags.Timeout = 5 * time.Second
+		flags.Port = port
+		flags.AllowHTTP09 = test.allow
+		scanner := module.NewScanner().(*Scanner)
+		scanner.Init(flags)
+
+		status, ret, err := scanner.Scan(context.Background(), zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1")})
+		stop()
+		if status != test.status {
+			t.Errorf("%s: expected status %s, got %s (%v)", test.name, test.status, status, err)
+			continue
+		}
+		results := ret.(*Results)
+		if string(results.InvalidResponse) != test.invalid {
+			t.Errorf("%s: expected invalid response %q, got %q", test.name, test.invalid, results.InvalidResponse)
+		}
+		if results.HTTP09 != (test.protocol == "HTTP/0.9") {
+			t.Errorf("%s: unexpected http09 %t", test.name, results.HTTP09)
+		}
+		if test.protocol == "" {
+			continue
+		}
+		if results.Response.Protocol.Name != test.protocol || results.Response.BodyText != test.body {
+			t.Errorf("%s: unexpected response %+v", test.name, results.Response)
+		}
+	}
+}
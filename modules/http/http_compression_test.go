@@ -0,0 +1,72 @@
+package http
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/zmap/zgrab2/lib/http"
+)
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	buf := new(bytes.Buffer)
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(buf)
+	case "zlib":
+		w = zlib.NewWriter(buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(buf, flate.DefaultCompression)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("compress(%s) write failed: %v", encoding, err)
+	}
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDecompressBody(t *testing.T) {
+	body := []byte(strings.Repeat("<html>zgrab2</html>", 100))
+	// Decompressed bodies are capped at --max-decompressed-size=1 (kilobytes)
+	capped := body[:1024]
+	tests := []struct {
+		name            string
+		contentEncoding string
+		input           []byte
+		expected        []byte
+		compression     string
+	}{
+		{"gzip", "gzip", compress(t, "gzip", body), capped, "gzip"},
+		{"x-gzip", "x-gzip", compress(t, "gzip", body), capped, "gzip"},
+		{"zlib deflate", "deflate", compress(t, "zlib", body), capped, "deflate"},
+		{"raw deflate", "Deflate", compress(t, "raw-deflate", body), capped, "deflate"},
+		{"identity", "", body, body, ""},
+		{"truncated gzip", "gzip", compress(t, "gzip", body)[:40], nil, "gzip"},
+		{"bad gzip", "gzip", []byte("not gzip"), []byte("not gzip"), ""},
+	}
+	for _, test := range tests {
+		scan := &scan{scanner: &Scanner{config: &Flags{MaxDecompressedSize: 1, KeepCompressedBody: true}}}
+		resp := &http.Response{Header: http.Header{}}
+		if test.contentEncoding != "" {
+			resp.Header.Set("Content-Encoding", test.contentEncoding)
+		}
+		out := scan.decompressBody(resp, bytes.NewBuffer(test.input))
+		if resp.BodyCompression != test.compression {
+			t.Errorf("%s: expected compression %q, got %q", test.name, test.compression, resp.BodyCompression)
+		}
+		if test.expected != nil && !bytes.Equal(out.Bytes(), test.expected) {
+			t.Errorf("%s: unexpected body (%d bytes)", test.name, out.Len())
+		}
+		if test.compression != "" && !bytes.Equal(resp.BodyCompressed, test.input) {
+			t.Errorf("%s: compressed body not kept", test.name)
+		}
+		if test.compression != "" && out.Len() > 1024 {
+			t.Errorf("%s: decompressed body exceeds limit: %d bytes", test.name, out.Len())
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"context"
 	"crypto/rsa"
 	"encoding/hex"
 	"fmt"
@@ -306,7 +307,7 @@ func (cfg *readLimitTestConfig) runTest(t *testing.T, testName string) {
 	target := zgrab2.ScanTarget{
 		IP: net.ParseIP("127.0.0.1"),
 	}
-	status, ret, err := scanner.Scan(target)
+	status, ret, err := scanner.Scan(context.Background(), target)
 
 	if status != cfg.expectedStatus {
 		t.Errorf("Wrong status: expected %s, got %s", cfg.expectedStatus, status)
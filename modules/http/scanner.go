@@ -8,6 +8,9 @@ package http
 
 import (
 	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
 	"crypto/sha1"
 	"crypto/sha256"
@@ -47,7 +50,8 @@ type Flags struct {
 	zgrab2.BaseFlags
 	zgrab2.TLSFlags
 	Method          string `long:"method" default:"GET" description:"Set HTTP request method type"`
-	Endpoint        string `long:"endpoint" default:"/" description:"Send an HTTP request to an endpoint"`
+	Endpoint        string `long:"endpoint" default:"/" description:"Send an HTTP request to an endpoint; may use per-target template variables, e.g. /{{.Tag}}"`
+	HostHeader      string `long:"host-header" description:"Send this Host header instead of the target's domain or IP; may use per-target template variables, e.g. {{.Domain}}"`
 	FailHTTPToHTTPS bool   `long:"fail-http-to-https" description:"Trigger retry-https logic on known HTTP/400 protocol mismatch responses"`
 	UserAgent       string `long:"user-agent" default:"Mozilla/5.0 zgrab/0.x" description:"Set a custom user agent"`
 	RetryHTTPS      bool   `long:"retry-https" description:"If the initial request fails, reconnect and try with HTTPS."`
@@ -67,7 +71,7 @@ type Flags struct {
 
 	// Set arbitrary HTTP headers
 	CustomHeadersNames     string `long:"custom-headers-names" description:"CSV of custom HTTP headers to send to server"`
-	CustomHeadersValues    string `long:"custom-headers-values" description:"CSV of custom HTTP header values to send to server. Should match order of custom-headers-names."`
+	CustomHeadersValues    string `long:"custom-headers-values" description:"CSV of custom HTTP header values to send to server. Should match order of custom-headers-names. The values may use per-target template variables, e.g. {{.IP}}"`
 	CustomHeadersDelimiter string `long:"custom-headers-delimiter" description:"Delimiter for customer header name/value CSVs"`
 
 	OverrideSH bool `long:"override-sig-hash" description:"Override the default SignatureAndHashes TLS option with more expansive default"`
@@ -78,6 +82,35 @@ type Flags struct {
 
 	// WithBodyLength enables adding the body_size field to the Response
 	WithBodyLength bool `long:"with-body-size" description:"Enable the body_size attribute, for how many bytes actually read"`
+
+	// MaxDecompressedSize caps the size of a decompressed gzip / deflate body,
+	// to guard against decompression bombs.
+	MaxDecompressedSize int `long:"max-decompressed-size" default:"1024" description:"Max kilobytes of decompressed body to keep from a gzip or deflate encoded response"`
+
+	// KeepCompressedBody causes the raw compressed bytes of a gzip / deflate
+	// encoded body to be included in the Response alongside the decompressed
+	// body.
+	KeepCompressedBody bool `long:"keep-compressed-body" description:"Include the raw compressed body of gzip or deflate encoded responses"`
+
+	// WebSocket causes a WebSocket upgrade request to be sent to
+	// WebSocketPath after the initial request.
+	WebSocket          bool   `long:"websocket" description:"After the initial request, attempt a WebSocket upgrade"`
+	WebSocketPath      string `long:"websocket-path" default:"/" description:"Endpoint to send the WebSocket upgrade request to"`
+	WebSocketProtocols string `long:"websocket-protocols" description:"Comma-separated list of subprotocols to offer in Sec-WebSocket-Protocol"`
+
+	// AuthBasic and AuthBearer are credentials used to repeat the initial
+	// request with an Authorization header. Only one may be set.
+	AuthBasic  string `long:"auth-basic" description:"After the initial request, repeat it with HTTP Basic authentication using these user:pass credentials"`
+	AuthBearer string `long:"auth-bearer" description:"After the initial request, repeat it with this Bearer token"`
+
+	// AuthOnChallenge causes the authenticated request to be sent only if
+	// the initial response is 401 Unauthorized.
+	AuthOnChallenge bool `long:"auth-on-challenge" description:"Only send the authenticated request if the initial response is 401 Unauthorized"`
+
+	// AllowHTTP09 reads a text response without a status line as an
+	// HTTP/0.9 response. Such a response is recorded, but is still a
+	// protocol error, since any text banner would otherwise match.
+	AllowHTTP09 bool `long:"allow-http09" description:"Read a text response without a status line as an HTTP/0.9 response (recorded with http09 set, and a protocol-error status)"`
 }
 
 // A Results object is returned by the HTTP module's Scanner.Scan()
@@ -89,6 +122,23 @@ type Results struct {
 	// RedirectResponseChain is non-empty is the scanner follows a redirect.
 	// It contains all redirect response prior to the final response.
 	RedirectResponseChain []*http.Response `json:"redirect_response_chain,omitempty"`
+
+	// WebSocket is the result of the WebSocket upgrade request, if
+	// --websocket was set.
+	WebSocket *WebSocketResults `json:"websocket,omitempty"`
+
+	// Auth is the result of repeating the initial request with the
+	// --auth-basic or --auth-bearer credentials, if set.
+	Auth *AuthResults `json:"auth,omitempty"`
+
+	// InvalidResponse holds the start of the raw response if it was not
+	// valid HTTP. (With --allow-http09, a text response without a status
+	// line is instead read as an HTTP/0.9 Response.)
+	InvalidResponse []byte `json:"invalid_response,omitempty"`
+
+	// HTTP09 is true if the Response had no status line and was read as an
+	// HTTP/0.9 response (only with --allow-http09).
+	HTTP09 bool `json:"http09,omitempty"`
 }
 
 // Module is an implementation of the zgrab2.Module interface.
@@ -99,6 +149,7 @@ type Module struct {
 type Scanner struct {
 	config        *Flags
 	customHeaders map[string]string
+	templates     *requestTemplates
 	decodedHashFn func([]byte) string
 }
 
@@ -113,6 +164,11 @@ type scan struct {
 	results        Results
 	url            string
 	globalDeadline time.Time
+
+	// host and headers are the Host header (if overridden) and the custom
+	// headers for the target, from the templates.
+	host    string
+	headers map[string]string
 }
 
 // NewFlags returns an empty Flags object.
@@ -132,6 +188,18 @@ func (module *Module) Description() string {
 
 // Validate performs any needed validation on the arguments
 func (flags *Flags) Validate(args []string) error {
+	if flags.AuthBasic != "" && flags.AuthBearer != "" {
+		log.Error("--auth-basic and --auth-bearer are mutually exclusive")
+		return zgrab2.ErrInvalidArguments
+	}
+	if flags.AuthBasic != "" && !strings.Contains(flags.AuthBasic, ":") {
+		log.Error("--auth-basic must be of the form user:pass")
+		return zgrab2.ErrInvalidArguments
+	}
+	if flags.AuthOnChallenge && flags.AuthBasic == "" && flags.AuthBearer == "" {
+		log.Error("--auth-on-challenge requires --auth-basic or --auth-bearer")
+		return zgrab2.ErrInvalidArguments
+	}
 	return nil
 }
 
@@ -194,7 +262,7 @@ func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
 			hName := strings.ToLower(headerNames[i])
 			switch {
 			case hName == "host":
-				log.Panicf("Attempt to set immutable header 'Host', specify this in targets file")
+				log.Panicf("Attempt to set immutable header 'Host', use --host-header instead")
 			case hName == "user-agent":
 				log.Panicf("Attempt to set special header 'User-Agent', use --user-agent instead")
 			case hName == "content-length":
@@ -209,6 +277,12 @@ func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
 		}
 	}
 
+	templates, err := newRequestTemplates(fl.Endpoint, fl.HostHeader, scanner.customHeaders)
+	if err != nil {
+		return err
+	}
+	scanner.templates = templates
+
 	if fl.ComputeDecodedBodyHashAlgorithm == "sha1" {
 		scanner.decodedHashFn = func(body []byte) string {
 			rawHash := sha1.Sum(body)
@@ -295,12 +369,15 @@ func (scan *scan) dialContext(ctx context.Context, network string, addr string)
 		}
 	}
 
-	timeoutContext, _ := context.WithTimeout(context.Background(), scan.scanner.config.Timeout)
+	timeoutContext, _ := context.WithTimeout(scan.target.Context(), scan.scanner.config.Timeout)
 
+	start := time.Now()
 	conn, err := dialer.DialContext(scan.withDeadlineContext(timeoutContext), network, addr)
 	if err != nil {
 		return nil, err
 	}
+	scan.target.RecordConnect(start)
+	scan.target.RecordRemoteAddr(conn.RemoteAddr())
 	scan.connections = append(scan.connections, conn)
 	return conn, nil
 }
@@ -350,7 +427,11 @@ func (scan *scan) getTLSDialer(t *zgrab2.ScanTarget) func(network, addr string)
 		tlsConn := scan.scanner.config.TLSFlags.GetWrappedConnection(outer, cfg)
 
 		// lib/http/transport.go fills in the TLSLog in the http.Request instance(s)
+		start := time.Now()
 		err = tlsConn.Handshake()
+		if err == nil {
+			scan.target.RecordHandshake(start)
+		}
 		return tlsConn, err
 	}
 }
@@ -384,26 +465,7 @@ func (scan *scan) getCheckRedirect() func(*http.Request, *http.Response, []*http
 			return ErrRedirLocalhost
 		}
 		scan.results.RedirectResponseChain = append(scan.results.RedirectResponseChain, res)
-		b := new(bytes.Buffer)
-		maxReadLen := int64(scan.scanner.config.MaxSize) * 1024
-		readLen := maxReadLen
-		if res.ContentLength >= 0 && res.ContentLength < maxReadLen {
-			readLen = res.ContentLength
-		}
-		bytesRead, _ := io.CopyN(b, res.Body, readLen)
-		if scan.scanner.config.WithBodyLength {
-			res.BodyTextLength = bytesRead
-		}
-		res.BodyText = b.String()
-		if len(res.BodyText) > 0 {
-			if scan.scanner.decodedHashFn != nil {
-				res.BodyHash = scan.scanner.decodedHashFn([]byte(res.BodyText))
-			} else {
-				m := sha256.New()
-				m.Write(b.Bytes())
-				res.BodySHA256 = m.Sum(nil)
-			}
-		}
+		scan.readBody(res)
 
 		if len(via) > scan.scanner.config.MaxRedirects {
 			return ErrTooManyRedirects
@@ -413,6 +475,72 @@ func (scan *scan) getCheckRedirect() func(*http.Request, *http.Response, []*http
 	}
 }
 
+// readBody reads up to --max-size kilobytes of the body of res (e.g. a
+// response in the redirect chain) into its BodyText, and hashes it.
+func (scan *scan) readBody(res *http.Response) {
+	b := new(bytes.Buffer)
+	maxReadLen := int64(scan.scanner.config.MaxSize) * 1024
+	readLen := maxReadLen
+	if res.ContentLength >= 0 && res.ContentLength < maxReadLen {
+		readLen = res.ContentLength
+	}
+	bytesRead, _ := io.CopyN(b, res.Body, readLen)
+	if scan.scanner.config.WithBodyLength {
+		res.BodyTextLength = bytesRead
+	}
+	b = scan.decompressBody(res, b)
+	res.BodyText = b.String()
+	if len(res.BodyText) > 0 {
+		if scan.scanner.decodedHashFn != nil {
+			res.BodyHash = scan.scanner.decodedHashFn([]byte(res.BodyText))
+		} else {
+			m := sha256.New()
+			m.Write(b.Bytes())
+			res.BodySHA256 = m.Sum(nil)
+		}
+	}
+}
+
+// decompressBody inflates a gzip or deflate encoded body that was read from
+// resp, keeping at most --max-decompressed-size kilobytes of the output. On
+// success it sets resp.BodyCompression (and resp.BodyCompressed, if
+// --keep-compressed-body is set) and returns the decompressed body; otherwise
+// it returns body unchanged. A truncated compressed body still yields
+// whatever could be inflated from it.
+func (scan *scan) decompressBody(resp *http.Response, body *bytes.Buffer) *bytes.Buffer {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	var reader io.Reader
+	var err error
+	switch encoding {
+	case "gzip", "x-gzip":
+		encoding = "gzip"
+		reader, err = gzip.NewReader(bytes.NewReader(body.Bytes()))
+	case "deflate":
+		// "deflate" is meant to be zlib-wrapped, but some servers send a raw
+		// DEFLATE stream.
+		reader, err = zlib.NewReader(bytes.NewReader(body.Bytes()))
+		if err != nil {
+			reader, err = flate.NewReader(bytes.NewReader(body.Bytes())), nil
+		}
+	default:
+		return body
+	}
+	if err != nil {
+		return body
+	}
+	maxLen := int64(scan.scanner.config.MaxDecompressedSize) * 1024
+	decompressed := new(bytes.Buffer)
+	io.CopyN(decompressed, reader, maxLen)
+	if decompressed.Len() == 0 && body.Len() > 0 {
+		return body
+	}
+	resp.BodyCompression = encoding
+	if scan.scanner.config.KeepCompressedBody {
+		resp.BodyCompressed = body.Bytes()
+	}
+	return decompressed
+}
+
 // Maps URL protocol to the default port for that protocol
 var protoToPort = map[string]uint16{
 	"http":  80,
@@ -433,15 +561,17 @@ func getHTTPURL(https bool, host string, port uint16, endpoint string) string {
 	return proto + "://" + net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10)) + endpoint
 }
 
-// NewHTTPScan gets a new Scan instance for the given target
-func (scanner *Scanner) newHTTPScan(t *zgrab2.ScanTarget, useHTTPS bool) *scan {
+// NewHTTPScan gets a new Scan instance for the given target, or an error if
+// the request templates cannot be executed for it.
+func (scanner *Scanner) newHTTPScan(t *zgrab2.ScanTarget, useHTTPS bool) (*scan, error) {
 	ret := scan{
 		scanner: scanner,
 		target:  t,
 		transport: &http.Transport{
 			Proxy:               nil, // TODO: implement proxying
 			DisableKeepAlives:   false,
-			DisableCompression:  false,
+			DisableCompression:  true, // see decompressBody
+			AllowHTTP09:         scanner.config.AllowHTTP09,
 			MaxIdleConnsPerHost: scanner.config.MaxRedirects,
 		},
 		client:         http.MakeNewClient(),
@@ -465,9 +595,26 @@ func (scanner *Scanner) newHTTPScan(t *zgrab2.ScanTarget, useHTTPS bool) *scan {
 	} else {
 		port = uint16(scanner.config.BaseFlags.Port)
 	}
-	ret.url = getHTTPURL(useHTTPS, host, port, scanner.config.Endpoint)
+	values, err := scanner.templates.execute(newTemplateVars(t, port))
+	if err != nil {
+		return nil, err
+	}
+	ret.url = getHTTPURL(useHTTPS, host, port, values.endpoint)
+	ret.host = values.host
+	ret.headers = values.headers
+
+	return &ret, nil
+}
 
-	return &ret
+// setHeaders sets the Host header, if overridden, and the custom headers of
+// request.
+func (scan *scan) setHeaders(request *http.Request) {
+	if scan.host != "" {
+		request.Host = scan.host
+	}
+	for k, v := range scan.headers {
+		request.Header.Set(k, v)
+	}
 }
 
 // Grab performs the HTTP scan -- implementation taken from zgrab/zlib/grabber.go
@@ -480,15 +627,12 @@ func (scan *scan) Grab() *zgrab2.ScanError {
 
 	// By default, the following headers are *always* set:
 	// Host, User-Agent, Accept, Accept-Encoding
-	if scan.scanner.customHeaders != nil {
-		request.Header.Set("Accept", "*/*")
-		for k, v := range scan.scanner.customHeaders {
-			request.Header.Set(k, v)
-		}
-	} else {
-		// If user did not specify custom headers, legacy behavior has always been
-		// to set the Accept header
-		request.Header.Set("Accept", "*/*")
+	request.Header.Set("Accept", "*/*")
+	scan.setHeaders(request)
+	// The transport's own gzip handling is disabled so that the compression
+	// can be recorded; see decompressBody.
+	if request.Header.Get("Accept-Encoding") == "" && request.Method != "HEAD" {
+		request.Header.Set("Accept-Encoding", "gzip, deflate")
 	}
 
 	resp, err := scan.client.Do(request)
@@ -500,6 +644,10 @@ func (scan *scan) Grab() *zgrab2.ScanError {
 		if urlError, ok := err.(*url.Error); ok {
 			err = urlError.Err
 		}
+		if invalid, ok := err.(*http.InvalidResponseError); ok {
+			scan.results.InvalidResponse = invalid.Raw
+			return zgrab2.NewScanError(zgrab2.SCAN_PROTOCOL_ERROR, err)
+		}
 	}
 	if err != nil {
 		switch err {
@@ -522,6 +670,9 @@ func (scan *scan) Grab() *zgrab2.ScanError {
 		readLen = resp.ContentLength
 	}
 	io.CopyN(buf, resp.Body, readLen)
+	if buf = scan.decompressBody(resp, buf); resp.BodyCompression != "" {
+		readLen = int64(buf.Len())
+	}
 	encoder, encoding, certain := charset.DetermineEncoding(buf.Bytes(), resp.Header.Get("content-type"))
 
 	bodyText := ""
@@ -586,29 +737,53 @@ func (scan *scan) Grab() *zgrab2.ScanError {
 		}
 	}
 
+	if resp.Protocol.Major == 0 && resp.Protocol.Minor == 9 {
+		scan.results.HTTP09 = true
+		return zgrab2.NewScanError(zgrab2.SCAN_PROTOCOL_ERROR, errors.New("HTTP/0.9 response without a status line"))
+	}
+
 	return nil
 }
 
+// grabFollowUps sends the configured follow-up requests after a successful
+// initial request.
+func (scan *scan) grabFollowUps() {
+	if scan.scanner.config.AuthBasic != "" || scan.scanner.config.AuthBearer != "" {
+		scan.GrabAuth()
+	}
+	if scan.scanner.config.WebSocket {
+		scan.GrabWebSocket()
+	}
+}
+
 // Scan implements the zgrab2.Scanner interface and performs the full scan of
 // the target. If the scanner is configured to follow redirects, this may entail
 // multiple TCP connections to hosts other than target.
-func (scanner *Scanner) Scan(t zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
-	scan := scanner.newHTTPScan(&t, scanner.config.UseHTTPS)
+func (scanner *Scanner) Scan(ctx context.Context, t zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	scan, templateErr := scanner.newHTTPScan(&t, scanner.config.UseHTTPS)
+	if templateErr != nil {
+		return zgrab2.SCAN_UNKNOWN_ERROR, nil, templateErr
+	}
 	defer scan.Cleanup()
 	err := scan.Grab()
 	if err != nil {
 		if scanner.config.RetryHTTPS && !scanner.config.UseHTTPS {
 			scan.Cleanup()
-			retry := scanner.newHTTPScan(&t, true)
+			retry, templateErr := scanner.newHTTPScan(&t, true)
+			if templateErr != nil {
+				return zgrab2.SCAN_UNKNOWN_ERROR, nil, templateErr
+			}
 			defer retry.Cleanup()
 			retryError := retry.Grab()
 			if retryError != nil {
 				return retryError.Unpack(&retry.results)
 			}
+			retry.grabFollowUps()
 			return zgrab2.SCAN_SUCCESS, &retry.results, nil
 		}
 		return err.Unpack(&scan.results)
 	}
+	scan.grabFollowUps()
 	return zgrab2.SCAN_SUCCESS, &scan.results, nil
 }
 
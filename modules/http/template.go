@@ -0,0 +1,130 @@
+package http
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/zmap/zgrab2"
+)
+
+// TemplateVars are the per-target variables that the --endpoint,
+// --host-header and --custom-headers-values templates can use, in Go's
+// text/template syntax (e.g. --endpoint "/{{.Tag}}"):
+//
+//	{{.IP}}        the target's IP address, if any
+//	{{.Domain}}    the target's domain name, if any
+//	{{.Host}}      the domain name, or the IP address if there is none
+//	{{.Port}}      the port being scanned
+//	{{.Tag}}       the target's tag, from the input
+//	{{.Tags.name}} the input tag called name (empty if the target has none)
+type TemplateVars struct {
+	IP     string
+	Domain string
+	Host   string
+	Port   uint16
+	Tag    string
+	Tags   map[string]string
+}
+
+// newTemplateVars returns the TemplateVars of target, scanned on port.
+func newTemplateVars(target *zgrab2.ScanTarget, port uint16) *TemplateVars {
+	vars := &TemplateVars{
+		Domain: target.Domain,
+		Host:   target.Domain,
+		Port:   port,
+		Tag:    target.Tag,
+		Tags:   target.Tags,
+	}
+	if target.IP != nil {
+		vars.IP = target.IP.String()
+		if vars.Host == "" {
+			vars.Host = vars.IP
+		}
+	}
+	return vars
+}
+
+// requestTemplates holds the parsed templates of the request.
+type requestTemplates struct {
+	endpoint *template.Template
+
+	// host is nil unless --host-header is set.
+	host *template.Template
+
+	// headers maps the (lower-case) custom header names to the templates of
+	// their values.
+	headers map[string]*template.Template
+}
+
+// parseTemplate parses text as a template, and checks that it can be
+// executed, so that invalid templates are rejected at startup rather than
+// for each target.
+func parseTemplate(name string, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := executeTemplate(tmpl, &TemplateVars{Tags: map[string]string{}}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// newRequestTemplates parses the templates of the endpoint, the Host header
+// (if host is not empty) and the custom headers' values.
+func newRequestTemplates(endpoint string, host string, headers map[string]string) (*requestTemplates, error) {
+	var err error
+	ret := &requestTemplates{headers: make(map[string]*template.Template, len(headers))}
+	if ret.endpoint, err = parseTemplate("endpoint", endpoint); err != nil {
+		return nil, fmt.Errorf("invalid --endpoint template: %v", err)
+	}
+	if host != "" {
+		if ret.host, err = parseTemplate("host-header", host); err != nil {
+			return nil, fmt.Errorf("invalid --host-header template: %v", err)
+		}
+	}
+	for name, value := range headers {
+		if ret.headers[name], err = parseTemplate(name, value); err != nil {
+			return nil, fmt.Errorf("invalid template for custom header %s: %v", name, err)
+		}
+	}
+	return ret, nil
+}
+
+// executeTemplate returns the text of tmpl for vars.
+func executeTemplate(tmpl *template.Template, vars *TemplateVars) (string, error) {
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// requestValues holds the request's endpoint, Host header and custom
+// headers for a target.
+type requestValues struct {
+	endpoint string
+	host     string
+	headers  map[string]string
+}
+
+// execute returns the values of the templates for vars.
+func (templates *requestTemplates) execute(vars *TemplateVars) (*requestValues, error) {
+	var err error
+	ret := &requestValues{headers: make(map[string]string, len(templates.headers))}
+	if ret.endpoint, err = executeTemplate(templates.endpoint, vars); err != nil {
+		return nil, err
+	}
+	if templates.host != nil {
+		if ret.host, err = executeTemplate(templates.host, vars); err != nil {
+			return nil, err
+		}
+	}
+	for name, tmpl := range templates.headers {
+		if ret.headers[name], err = executeTemplate(tmpl, vars); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
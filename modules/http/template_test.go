@@ -0,0 +1,80 @@
+package http
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	stdhttp "net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/zmap/zgrab2"
+)
+
+func TestRequestTemplates(t *testing.T) {
+	server := httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		w.Write([]byte(r.Host + " " + r.URL.Path + " " + r.Header.Get("X-Customer")))
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	_, portString, _ := net.SplitHostPort(serverURL.Host)
+	port, _ := net.LookupPort("tcp", portString)
+
+	var module Module
+	flags := module.NewFlags().(*Flags)
+	flags.Endpoint = "/{{.Tag}}"
+	flags.HostHeader = "{{.Domain}}"
+	flags.CustomHeadersNames = "X-Customer"
+	flags.CustomHeadersValues = "{{.Tags.customer}}@{{.IP}}"
+	flags.Method = "GET"
+	flags.UserAgent = "Mozilla/5.0 zgrab/0.x"
+	flags.MaxSize = 256
+	flags.Timeout = 5 * time.Second
+	flags.Port = uint(port)
+	scanner := module.NewScanner().(*Scanner)
+	if err := scanner.Init(flags); err != nil {
+		t.Fatal(err)
+	}
+
+	targets := []struct {
+		target   zgrab2.ScanTarget
+		expected string
+	}{
+		{zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Domain: "example.com", Tag: "admin", Tags: map[string]string{"customer": "acme"}}, "example.com /admin acme@127.0.0.1"},
+		// Missing tags are empty.
+		{zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Domain: "example.org"}, "example.org / @127.0.0.1"},
+	}
+	for _, test := range targets {
+		status, ret, err := scanner.Scan(context.Background(), test.target)
+		if status != zgrab2.SCAN_SUCCESS {
+			t.Fatalf("%s: unexpected status %s (%v)", test.target.Domain, status, err)
+		}
+		if body := ret.(*Results).Response.BodyText; body != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, body)
+		}
+	}
+}
+
+func TestInvalidRequestTemplates(t *testing.T) {
+	for _, endpoint := range []string{"/{{.Tag", "/{{.Path}}", "/{{.Tag.Name}}"} {
+		if _, err := newRequestTemplates(endpoint, "", nil); err == nil {
+			t.Errorf("expected an error for %s", endpoint)
+		}
+	}
+	if _, err := newRequestTemplates("/", "", map[string]string{"x-customer": "{{.Customer}}"}); err == nil {
+		t.Errorf("expected an error for an invalid header template")
+	}
+	templates, err := newRequestTemplates("/{{.Host}}:{{.Port}}", "{{.Host}}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := templates.execute(newTemplateVars(&zgrab2.ScanTarget{IP: net.ParseIP("192.0.2.1")}, 8080))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values.endpoint != "/192.0.2.1:8080" || values.host != "192.0.2.1" {
+		t.Errorf("unexpected values %+v", values)
+	}
+}
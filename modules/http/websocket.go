@@ -0,0 +1,151 @@
+package http
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/url"
+	"strings"
+
+	"github.com/zmap/zgrab2/lib/http"
+)
+
+// webSocketGUID is appended to the Sec-WebSocket-Key to compute the
+// Sec-WebSocket-Accept value (RFC 6455, section 1.3).
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocketResults records the response to a WebSocket upgrade request.
+type WebSocketResults struct {
+	// URL is the URL the upgrade request was sent to.
+	URL string `json:"url,omitempty"`
+
+	// StatusCode is the status code of the response.
+	StatusCode int `json:"status_code,omitempty"`
+
+	// Upgraded is true if the server switched protocols (101) to
+	// "websocket" with a valid Sec-WebSocket-Accept.
+	Upgraded bool `json:"upgraded"`
+
+	// Accept is the Sec-WebSocket-Accept header returned by the server.
+	Accept string `json:"accept,omitempty"`
+
+	// AcceptValid is true if Accept matches the value computed from the
+	// Sec-WebSocket-Key we sent.
+	AcceptValid bool `json:"accept_valid"`
+
+	// Subprotocol is the Sec-WebSocket-Protocol selected by the server.
+	Subprotocol string `json:"subprotocol,omitempty"`
+
+	// Extensions lists the Sec-WebSocket-Extensions accepted by the server.
+	Extensions []string `json:"extensions,omitempty"`
+
+	// Response is the full response to the upgrade request.
+	Response *http.Response `json:"response,omitempty" zgrab:"debug"`
+
+	// Error is set if the upgrade request failed.
+	Error string `json:"error,omitempty"`
+}
+
+// newWebSocketKey returns a random base64-encoded 16-byte nonce for use as
+// the Sec-WebSocket-Key.
+func newWebSocketKey() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(nonce), nil
+}
+
+// computeWebSocketAccept returns the Sec-WebSocket-Accept value a server
+// must return for the given Sec-WebSocket-Key.
+func computeWebSocketAccept(key string) string {
+	hash := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// splitHeaderList splits the comma-separated values of all instances of a
+// header into a list, dropping empty elements.
+func splitHeaderList(values []string) []string {
+	var ret []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			if element = strings.TrimSpace(element); element != "" {
+				ret = append(ret, element)
+			}
+		}
+	}
+	return ret
+}
+
+// getWebSocketURL returns the URL of the --websocket-path endpoint on the
+// same scheme, host and port as the initial request.
+func (scan *scan) getWebSocketURL() (string, error) {
+	base, err := url.Parse(scan.url)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(scan.scanner.config.WebSocketPath)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// GrabWebSocket sends a WebSocket upgrade request to --websocket-path and
+// records the result in scan.results.WebSocket. Failures are recorded in the
+// result rather than returned, since they do not affect the initial request.
+func (scan *scan) GrabWebSocket() {
+	result := &WebSocketResults{}
+	scan.results.WebSocket = result
+	wsURL, err := scan.getWebSocketURL()
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	result.URL = wsURL
+	key, err := newWebSocketKey()
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	request, err := http.NewRequest("GET", wsURL, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	scan.setHeaders(request)
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Sec-WebSocket-Version", "13")
+	request.Header.Set("Sec-WebSocket-Key", key)
+	if scan.scanner.config.WebSocketProtocols != "" {
+		request.Header.Set("Sec-WebSocket-Protocol", scan.scanner.config.WebSocketProtocols)
+	}
+
+	// A redirect is recorded rather than followed (and kept out of the
+	// initial request's RedirectResponseChain).
+	scan.client.CheckRedirect = func(*http.Request, *http.Response, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := scan.client.Do(request)
+	if resp != nil && resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		if urlError, ok := err.(*url.Error); ok {
+			err = urlError.Err
+		}
+		result.Error = err.Error()
+	}
+	if resp == nil {
+		return
+	}
+	result.Response = resp
+	result.StatusCode = resp.StatusCode
+	result.Accept = resp.Header.Get("Sec-WebSocket-Accept")
+	result.AcceptValid = result.Accept == computeWebSocketAccept(key)
+	result.Subprotocol = resp.Header.Get("Sec-WebSocket-Protocol")
+	result.Extensions = splitHeaderList(resp.Header[http.CanonicalHeaderKey("Sec-WebSocket-Extensions")])
+	result.Upgraded = resp.StatusCode == 101 && result.AcceptValid &&
+		strings.EqualFold(resp.Header.Get("Upgrade"), "websocket")
+}
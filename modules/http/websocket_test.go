@@ -0,0 +1,108 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	stdhttp "net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/zmap/zgrab2"
+)
+
+func TestComputeWebSocketAccept(t *testing.T) {
+	// Example from RFC 6455, section 1.3.
+	if accept := computeWebSocketAccept("dGhlIHNhbXBsZSBub25jZQ=="); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("unexpected Sec-WebSocket-Accept %q", accept)
+	}
+}
+
+// webSocketHandler upgrades requests to /ws, answering with the given
+// Sec-WebSocket-Accept (or the correct one, if accept is empty).
+func webSocketHandler(t *testing.T, accept string) stdhttp.Handler {
+	return stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		if r.URL.Path != "/ws" || r.Header.Get("Upgrade") != "websocket" {
+			w.Write([]byte("hello"))
+			return
+		}
+		if r.Header.Get("Sec-WebSocket-Protocol") != "mqtt, stomp" {
+			t.Errorf("unexpected Sec-WebSocket-Protocol %q", r.Header.Get("Sec-WebSocket-Protocol"))
+		}
+		if accept == "" {
+			accept = computeWebSocketAccept(r.Header.Get("Sec-WebSocket-Key"))
+		}
+		conn, rw, err := w.(stdhttp.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		writeUpgrade(rw, accept)
+	})
+}
+
+func writeUpgrade(rw *bufio.ReadWriter, accept string) {
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept + "\r\n" +
+		"Sec-WebSocket-Protocol: mqtt\r\n" +
+		"Sec-WebSocket-Extensions: permessage-deflate; client_max_window_bits\r\n" +
+		"Sec-WebSocket-Extensions: x-custom\r\n\r\n")
+	rw.Flush()
+}
+
+func TestWebSocketUpgrade(t *testing.T) {
+	tests := []struct {
+		name     string
+		accept   string
+		upgraded bool
+	}{
+		{"valid", "", true},
+		{"invalid accept", "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", false},
+	}
+	for _, test := range tests {
+		server := httptest.NewServer(webSocketHandler(t, test.accept))
+		serverURL, _ := url.Parse(server.URL)
+		_, portString, _ := net.SplitHostPort(serverURL.Host)
+		port, _ := net.LookupPort("tcp", portString)
+
+		var module Module
+		flags := module.NewFlags().(*Flags)
+		flags.Endpoint = "/"
+		flags.Method = "GET"
+		flags.UserAgent = "Mozilla/5.0 zgrab/0.x"
+		flags.MaxSize = 256
+		flags.Timeout = 5 * time.Second
+		flags.Port = uint(port)
+		flags.WebSocket = true
+		flags.WebSocketPath = "/ws"
+		flags.WebSocketProtocols = "mqtt, stomp"
+		scanner := module.NewScanner().(*Scanner)
+		scanner.Init(flags)
+
+		status, ret, err := scanner.Scan(context.Background(), zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1")})
+		server.Close()
+		if status != zgrab2.SCAN_SUCCESS {
+			t.Fatalf("%s: unexpected status %s (%v)", test.name, status, err)
+		}
+		result := ret.(*Results).WebSocket
+		if result == nil {
+			t.Fatalf("%s: no websocket result", test.name)
+		}
+		if result.Error != "" || result.StatusCode != 101 || result.Subprotocol != "mqtt" {
+			t.Errorf("%s: unexpected result %+v", test.name, result)
+		}
+		if result.Upgraded != test.upgraded || result.AcceptValid != test.upgraded {
+			t.Errorf("%s: expected upgraded=%v, got %+v", test.name, test.upgraded, result)
+		}
+		if !reflect.DeepEqual(result.Extensions, []string{"permessage-deflate; client_max_window_bits", "x-custom"}) {
+			t.Errorf("%s: unexpected extensions %v", test.name, result.Extensions)
+		}
+	}
+}
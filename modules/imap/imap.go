@@ -4,6 +4,7 @@ import (
 	"net"
 	"regexp"
 	"io"
+	"strings"
 
 	"github.com/zmap/zgrab2"
 )
@@ -36,3 +37,30 @@ func (conn *Connection) SendCommand(cmd string) (string, error) {
 	}
 	return conn.ReadResponse()
 }
+
+// SendTaggedCommand sends a command with the given tag, then reads the
+// server's response up to and including the tagged status line, so that
+// untagged responses (e.g. "* CAPABILITY ...") are not left unread.
+func (conn *Connection) SendTaggedCommand(tag string, cmd string) (string, error) {
+	ret, err := conn.SendCommand(tag + " " + cmd)
+	if err != nil {
+		return "", err
+	}
+	for !hasTaggedLine(ret, tag) {
+		more, err := conn.ReadResponse()
+		if err != nil {
+			return ret, err
+		}
+		if more == "" {
+			// Timed out or closed; return what there is.
+			break
+		}
+		ret += more
+	}
+	return ret, nil
+}
+
+// hasTaggedLine checks whether response includes the status line for tag.
+func hasTaggedLine(response string, tag string) bool {
+	return strings.HasPrefix(response, tag+" ") || strings.Contains(response, "\n"+tag+" ")
+}
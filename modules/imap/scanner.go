@@ -12,6 +12,9 @@
 // --imaps does not change the default port number from 143, so
 // it should usually be coupled with e.g. --port 993.
 //
+// The --send-capability flag tells the scanner to send a CAPABILITY
+// command (after STARTTLS, if it is sent), and read the response.
+//
 // The --send-close flag tells the scanner to send a CLOSE command
 // before disconnecting.
 //
@@ -23,6 +26,7 @@
 package imap
 
 import (
+	"context"
 	"fmt"
 	"errors"
 
@@ -40,6 +44,13 @@ type ScanResults struct {
 	// StartTLS is the server's response to the STARTTLS command, if it is sent.
 	StartTLS string `json:"starttls,omitempty"`
 
+	// Capability is the server's response to the CAPABILITY command, if it is sent.
+	Capability string `json:"capability,omitempty"`
+
+	// AuthMechanisms are the SASL mechanisms advertised in the server's
+	// capabilities, in the banner or the response to the CAPABILITY command.
+	AuthMechanisms []string `json:"auth_mechanisms,omitempty"`
+
 	// CLOSE is the server's response to the CLOSE command, if it is sent.
 	CLOSE string `json:"close,omitempty"`
 
@@ -53,6 +64,9 @@ type Flags struct {
 	zgrab2.BaseFlags
 	zgrab2.TLSFlags
 
+	// SendCAPABILITY indicates that the CAPABILITY command should be sent.
+	SendCAPABILITY bool `long:"send-capability" description:"Send the CAPABILITY command"`
+
 	// SendCLOSE indicates that the CLOSE command should be sent.
 	SendCLOSE bool `long:"send-close" description:"Send the CLOSE command before closing."`
 
@@ -149,6 +163,20 @@ func getIMAPError(response string) error {
 	return fmt.Errorf("error: %s", response)
 }
 
+// getAuthMechanisms returns the SASL mechanisms listed as AUTH=<mechanism>
+// capabilities in an IMAP response, either a CAPABILITY response or a
+// [CAPABILITY ...] response code, as in a banner.
+func getAuthMechanisms(response string) []string {
+	var names []string
+	for _, field := range strings.Fields(response) {
+		field = strings.TrimRight(field, "]")
+		if len(field) > len("AUTH=") && strings.EqualFold(field[:len("AUTH=")], "AUTH=") {
+			names = append(names, field[len("AUTH="):])
+		}
+	}
+	return zgrab2.SASLMechanisms(names)
+}
+
 // Check the contents of the IMAP banner and return a relevant ScanStatus
 func VerifyIMAPContents(banner string) zgrab2.ScanStatus {
 	lowerBanner := strings.ToLower(banner)
@@ -179,13 +207,15 @@ func VerifyIMAPContents(banner string) zgrab2.ScanStatus {
 // 3. Read the banner.
 // 6. If --starttls is sent, send a001 STARTTLS, read the result, negotiate a
 //    TLS connection using the command-line flags.
-// 7. If --send-close is sent, send a001 CLOSE and read the result.
-// 8. Close the connection.
-func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+// 7. If --send-capability is sent, send a001 CAPABILITY and read the result.
+// 8. If --send-close is sent, send a001 CLOSE and read the result.
+// 9. Close the connection.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
 	c, err := target.Open(&scanner.config.BaseFlags)
 	if err != nil {
 		return zgrab2.TryGetScanStatus(err), nil, err
 	}
+	zgrab2.EnableTLSDetection(c)
 	defer c.Close()
 	result := &ScanResults{}
 	if scanner.config.IMAPSecure {
@@ -211,6 +241,7 @@ func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, inter
 		return sr, nil, errors.New("Invalid response for IMAP")
 	}
 	result.Banner = banner
+	result.AuthMechanisms = getAuthMechanisms(banner)
 	if scanner.config.StartTLS {
 		ret, err := conn.SendCommand("a001 STARTTLS")
 		if err != nil {
@@ -230,6 +261,17 @@ func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, inter
 		}
 		conn.Conn = tlsConn
 	}
+	if scanner.config.SendCAPABILITY {
+		ret, err := conn.SendTaggedCommand("a001", "CAPABILITY")
+		if err != nil {
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+		result.Capability = ret
+		// The capabilities may change after STARTTLS, so prefer these.
+		if mechanisms := getAuthMechanisms(ret); mechanisms != nil {
+			result.AuthMechanisms = mechanisms
+		}
+	}
 	if scanner.config.SendCLOSE {
 		ret, err := conn.SendCommand("a001 CLOSE")
 		if err != nil {
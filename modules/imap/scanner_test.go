@@ -0,0 +1,46 @@
+package imap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetAuthMechanisms(t *testing.T) {
+	testTable := map[string]struct {
+		Response string
+		Expected []string
+	}{
+		"dovecot banner": {
+			Response: "* OK [CAPABILITY IMAP4rev1 SASL-IR LOGIN-REFERRALS ID ENABLE IDLE LITERAL+ STARTTLS AUTH=PLAIN AUTH=LOGIN] Dovecot ready.\r\n",
+			Expected: []string{"PLAIN", "LOGIN"},
+		},
+		"capability response": {
+			Response: "* CAPABILITY IMAP4rev1 UNSELECT IDLE NAMESPACE QUOTA ID XLIST CHILDREN X-GM-EXT-1 AUTH=XOAUTH2 AUTH=PLAIN AUTH=PLAIN-CLIENTTOKEN AUTH=OAUTHBEARER\r\na001 OK Thats all she wrote!\r\n",
+			Expected: []string{"XOAUTH2", "PLAIN", "PLAIN-CLIENTTOKEN", "OAUTHBEARER"},
+		},
+		"last capability": {
+			Response: "* OK [CAPABILITY IMAP4rev1 auth=cram-md5] ready\r\n",
+			Expected: []string{"CRAM-MD5"},
+		},
+		"duplicates and invalid names": {
+			Response: "* CAPABILITY IMAP4rev1 AUTH=PLAIN AUTH= AUTH=plain AUTH=BAD/NAME\r\n",
+			Expected: []string{"PLAIN"},
+		},
+		"logins disabled": {
+			Response: "* OK [CAPABILITY IMAP4rev1 STARTTLS LOGINDISABLED] ready\r\n",
+			Expected: nil,
+		},
+		"no capabilities": {
+			Response: "* OK IMAP4 server ready\r\n",
+			Expected: nil,
+		},
+	}
+
+	for name, test := range testTable {
+		t.Run(name, func(t *testing.T) {
+			if got := getAuthMechanisms(test.Response); !reflect.DeepEqual(got, test.Expected) {
+				t.Errorf("received unexpected mechanisms: %v, wanted: %v", got, test.Expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package influxdb
+
+import (
+	"encoding/json"
+
+	"github.com/zmap/zgrab2/lib/http"
+)
+
+// Health holds the parsed /health response.
+type Health struct {
+	Name    string `json:"name,omitempty"`
+	Message string `json:"message,omitempty"`
+	Status  string `json:"status,omitempty"`
+	Version string `json:"version,omitempty"`
+	Commit  string `json:"commit,omitempty"`
+}
+
+// parseHealth parses the body of a /health response. It returns nil if the
+// body is not an InfluxDB health object.
+func parseHealth(body string) *Health {
+	var health Health
+	if err := json.Unmarshal([]byte(body), &health); err != nil || health.Name != "influxdb" {
+		return nil
+	}
+	return &health
+}
+
+// rawQueryResponse is used to decode /query responses.
+type rawQueryResponse struct {
+	Results []struct {
+		Series []struct {
+			Name   string          `json:"name"`
+			Values [][]interface{} `json:"values"`
+		} `json:"series"`
+		Error string `json:"error"`
+	} `json:"results"`
+	// 1.x reports errors in "error"; 2.x in "code" and "message".
+	Error   string `json:"error"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// parseDatabases parses the body of a SHOW DATABASES response. It returns
+// false if the body is not a successful query result.
+func parseDatabases(body string) ([]string, bool) {
+	var raw rawQueryResponse
+	if err := json.Unmarshal([]byte(body), &raw); err != nil || raw.Results == nil {
+		return nil, false
+	}
+	databases := []string{}
+	for _, result := range raw.Results {
+		if result.Error != "" {
+			return nil, false
+		}
+		for _, series := range result.Series {
+			for _, row := range series.Values {
+				if len(row) > 0 {
+					if name, ok := row[0].(string); ok {
+						databases = append(databases, name)
+					}
+				}
+			}
+		}
+	}
+	return databases, true
+}
+
+// isAuthRequired returns true if the response refused the request for lack
+// of credentials.
+func isAuthRequired(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden
+}
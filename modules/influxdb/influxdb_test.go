@@ -0,0 +1,81 @@
+package influxdb
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/httpapi/httpapitest"
+)
+
+// scanTestServer runs the scanner against a local server using handler.
+func scanTestServer(t *testing.T, handler http.HandlerFunc) (status zgrab2.ScanStatus, results *ScanResults, err error) {
+	scanner := &Scanner{config: &Flags{MaxSize: 256, UserAgent: "zgrab2"}}
+	scanner.config.Timeout = 5 * time.Second
+	httpapitest.ServeTarget(t, handler, func(target *zgrab2.ScanTarget) {
+		var result interface{}
+		status, result, err = scanner.Scan(context.Background(), *target)
+		results, _ = result.(*ScanResults)
+	})
+	return status, results, err
+}
+
+func TestScanOpen(t *testing.T) {
+	status, result, err := scanTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Influxdb-Version", "1.8.10")
+		w.Header().Set("X-Influxdb-Build", "OSS")
+		switch r.URL.Path {
+		case "/ping":
+			w.WriteHeader(http.StatusNoContent)
+		case "/health":
+			w.Write([]byte(`{"name":"influxdb","message":"ready for queries and writes","status":"pass","checks":[],"version":"1.8.10"}`))
+		case "/query":
+			if q := r.URL.Query().Get("q"); q != "SHOW DATABASES" {
+				t.Errorf("unexpected query %q", q)
+			}
+			w.Write([]byte(`{"results":[{"statement_id":0,"series":[{"name":"databases","columns":["name"],"values":[["_internal"],["telegraf"]]}]}]}`))
+		}
+	})
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	if result.Version != "1.8.10" || result.Build != "OSS" || result.Health == nil || result.Health.Status != "pass" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if !result.UnauthenticatedAccess || result.AuthRequired || !reflect.DeepEqual(result.Databases, []string{"_internal", "telegraf"}) {
+		t.Errorf("expected unauthenticated access, got %+v", result)
+	}
+}
+
+func TestScanAuthRequired(t *testing.T) {
+	status, result, err := scanTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ping":
+			w.Header().Set("X-Influxdb-Version", "v2.7.1")
+			w.WriteHeader(http.StatusNoContent)
+		case "/health":
+			w.Write([]byte(`{"name":"influxdb","message":"ready for queries and writes","status":"pass","checks":[],"version":"v2.7.1","commit":"407fa622e9"}`))
+		case "/query":
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code":"unauthorized","message":"unauthorized access"}`))
+		}
+	})
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	if !result.AuthRequired || result.UnauthenticatedAccess || result.Databases != nil || result.Health.Commit != "407fa622e9" {
+		t.Errorf("expected auth required, got %+v", result)
+	}
+}
+
+func TestScanNotInfluxDB(t *testing.T) {
+	status, _, err := scanTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html></html>`))
+	})
+	if status != zgrab2.SCAN_PROTOCOL_ERROR || err != ErrNotInfluxDB {
+		t.Errorf("expected protocol error, got %s: %v", status, err)
+	}
+}
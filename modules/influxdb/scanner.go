@@ -0,0 +1,237 @@
+// Package influxdb provides a zgrab2 module that probes for InfluxDB
+// servers.
+// Default Port: 8086 (TCP)
+//
+// The scanner sends GET /ping, recording the X-Influxdb-Version and
+// X-Influxdb-Build headers, and GET /health. It then sends
+// GET /query?q=SHOW DATABASES and records whether the query succeeded
+// without credentials, along with the databases it listed.
+//
+// The --use-tls flag tells the scanner to connect over TLS, using the
+// standard TLS flags.
+package influxdb
+
+import (
+	"context"
+	"errors"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/http"
+	"github.com/zmap/zgrab2/lib/httpapi"
+)
+
+// ErrNotInfluxDB is returned when neither /ping nor /health look like they
+// came from InfluxDB.
+var ErrNotInfluxDB = errors.New("server is not InfluxDB")
+
+// showDatabases is the query used to check for unauthenticated access.
+const showDatabases = "/query?q=SHOW%20DATABASES"
+
+// ScanResults instances are returned by the module's Scan function.
+type ScanResults struct {
+	// PingResponse is the HTTP response to GET /ping.
+	PingResponse *http.Response `json:"ping_response,omitempty" zgrab:"debug"`
+
+	// HealthResponse is the HTTP response to GET /health.
+	HealthResponse *http.Response `json:"health_response,omitempty" zgrab:"debug"`
+
+	// QueryResponse is the HTTP response to SHOW DATABASES.
+	QueryResponse *http.Response `json:"query_response,omitempty" zgrab:"debug"`
+
+	// Version is the /ping response's X-Influxdb-Version header, or the
+	// /health response's version.
+	Version string `json:"version,omitempty"`
+
+	// Build is the /ping response's X-Influxdb-Build header, e.g. "OSS"
+	// or "ENT".
+	Build string `json:"build,omitempty"`
+
+	// Health holds the parsed /health response.
+	Health *Health `json:"health,omitempty"`
+
+	// AuthRequired is true if SHOW DATABASES was refused for lack of
+	// credentials.
+	AuthRequired bool `json:"auth_required"`
+
+	// UnauthenticatedAccess is true if SHOW DATABASES succeeded without
+	// credentials.
+	UnauthenticatedAccess bool `json:"unauthenticated_access"`
+
+	// Databases lists the databases returned by SHOW DATABASES.
+	Databases []string `json:"databases,omitempty"`
+
+	// TLSLog is the standard TLS log, if TLS was used.
+	TLSLog *zgrab2.TLSLog `json:"tls,omitempty"`
+}
+
+// Flags holds the command-line configuration for the influxdb scan module.
+// Populated by the framework.
+type Flags struct {
+	zgrab2.BaseFlags
+	zgrab2.TLSFlags
+
+	// UseTLS indicates that the client should connect over TLS.
+	UseTLS bool `long:"use-tls" description:"Connect over TLS"`
+
+	// MaxSize bounds the size of each response body that is read.
+	MaxSize int `long:"max-size" default:"256" description:"Max kilobytes to read in response to each request"`
+
+	// UserAgent is sent in the User-Agent header of each request.
+	UserAgent string `long:"user-agent" default:"Mozilla/5.0 zgrab/0.x" description:"Set a custom user agent"`
+
+	// Verbose indicates that there should be more verbose logging.
+	Verbose bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
+}
+
+// Module implements the zgrab2.Module interface.
+type Module struct {
+}
+
+// Scanner implements the zgrab2.Scanner interface.
+type Scanner struct {
+	config *Flags
+}
+
+// scan holds the state of a single scan.
+type scan struct {
+	scanner *Scanner
+	client  *httpapi.Client
+	results ScanResults
+}
+
+// RegisterModule registers the zgrab2 module.
+func RegisterModule() {
+	var module Module
+	_, err := zgrab2.AddCommand("influxdb", "influxdb", module.Description(), 8086, &module)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewFlags returns a default Flags object.
+func (module *Module) NewFlags() interface{} {
+	return new(Flags)
+}
+
+// NewScanner returns a new Scanner instance.
+func (module *Module) NewScanner() zgrab2.Scanner {
+	return new(Scanner)
+}
+
+// Description returns an overview of this module.
+func (module *Module) Description() string {
+	return "Probe for InfluxDB servers with /ping and /health, and check for unauthenticated queries"
+}
+
+// Validate checks that the flags are valid.
+// On success, returns nil.
+// On failure, returns an error instance describing the error.
+func (flags *Flags) Validate(args []string) error {
+	return nil
+}
+
+// Help returns the module's help string.
+func (flags *Flags) Help() string {
+	return ""
+}
+
+// Init initializes the Scanner.
+func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
+	f, _ := flags.(*Flags)
+	scanner.config = f
+	return nil
+}
+
+// InitPerSender initializes the scanner for a given sender.
+func (scanner *Scanner) InitPerSender(senderID int) error {
+	return nil
+}
+
+// GetName returns the Scanner name defined in the Flags.
+func (scanner *Scanner) GetName() string {
+	return scanner.config.Name
+}
+
+// GetTrigger returns the Trigger defined in the Flags.
+func (scanner *Scanner) GetTrigger() string {
+	return scanner.config.Trigger
+}
+
+// Protocol returns the protocol identifier of the scan.
+func (scanner *Scanner) Protocol() string {
+	return "influxdb"
+}
+
+// newInfluxDBScan returns a scan of the target on the given port.
+func (scanner *Scanner) newInfluxDBScan(target *zgrab2.ScanTarget, port uint) *scan {
+	ret := &scan{scanner: scanner}
+	ret.client = httpapi.NewClient(target, port, &httpapi.Config{
+		BaseFlags: &scanner.config.BaseFlags,
+		TLSFlags:  &scanner.config.TLSFlags,
+		UseTLS:    scanner.config.UseTLS,
+		UserAgent: scanner.config.UserAgent,
+		MaxSize:   scanner.config.MaxSize,
+		TLSLog:    &ret.results.TLSLog,
+	})
+	return ret
+}
+
+// Cleanup closes any connections that have been opened during the scan.
+func (scan *scan) Cleanup() {
+	scan.client.Close()
+}
+
+// Scan performs the InfluxDB scan.
+//  1. Connect over TLS if --use-tls is set; otherwise over plain HTTP.
+//  2. Send GET /ping and record the X-Influxdb-Version and X-Influxdb-Build
+//     headers.
+//  3. Send GET /health and record the parsed response. If neither response
+//     looks like it came from InfluxDB, fail with a protocol error.
+//  4. Send SHOW DATABASES. If it succeeds, record the databases and flag
+//     unauthenticated_access; if it returns 401 / 403, record
+//     auth_required.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	port := scanner.config.Port
+	if target.Port != nil {
+		port = *target.Port
+	}
+	scan := scanner.newInfluxDBScan(&target, port)
+	defer scan.Cleanup()
+	result := &scan.results
+
+	resp, err := scan.client.Get("/ping")
+	if err != nil {
+		if result.TLSLog != nil {
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	result.PingResponse = resp
+	result.Version = resp.Header.Get("X-Influxdb-Version")
+	result.Build = resp.Header.Get("X-Influxdb-Build")
+
+	resp, err = scan.client.Get("/health")
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+	result.HealthResponse = resp
+	result.Health = parseHealth(resp.BodyText)
+	if result.Health == nil && result.Version == "" {
+		return zgrab2.SCAN_PROTOCOL_ERROR, result, ErrNotInfluxDB
+	}
+	if result.Version == "" {
+		result.Version = result.Health.Version
+	}
+
+	resp, err = scan.client.Get(showDatabases)
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+	result.QueryResponse = resp
+	if resp.StatusCode == http.StatusOK {
+		result.Databases, result.UnauthenticatedAccess = parseDatabases(resp.BodyText)
+	}
+	result.AuthRequired = isAuthRequired(resp)
+	return zgrab2.SCAN_SUCCESS, result, nil
+}
@@ -0,0 +1,221 @@
+package ipmi
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+// RMCP header constants
+const (
+	RMCP_VERSION_1           byte = 0x06
+	RMCP_SEQUENCE_NO_ACK     byte = 0xff
+	RMCP_CLASS_IPMI          byte = 0x07
+	RMCP_HEADER_LENGTH            = 4
+	MAX_IPMI_DATAGRAM_LENGTH      = 1024
+)
+
+// IPMI session header authentication types
+const (
+	AUTH_TYPE_NONE     byte = 0x00
+	AUTH_TYPE_MD2      byte = 0x01
+	AUTH_TYPE_MD5      byte = 0x02
+	AUTH_TYPE_PASSWORD byte = 0x04
+	AUTH_TYPE_OEM      byte = 0x05
+	AUTH_TYPE_RMCPPLUS byte = 0x06
+)
+
+// IPMI message constants
+const (
+	BMC_SLAVE_ADDRESS     byte = 0x20
+	REMOTE_CONSOLE_SWID   byte = 0x81
+	NETFN_APP_REQUEST     byte = 0x06
+	NETFN_APP_RESPONSE    byte = 0x07
+	PRIVILEGE_ADMIN       byte = 0x04
+	CURRENT_CHANNEL       byte = 0x0e
+	CHANNEL_REQUEST_IPMI2 byte = 0x80
+
+	CMD_GET_CHANNEL_AUTH_CAPABILITIES byte = 0x38
+
+	COMPLETION_CODE_OK              byte = 0x00
+	COMPLETION_CODE_INVALID_REQUEST byte = 0xcc
+)
+
+// RMCP+ payload types
+const (
+	PAYLOAD_TYPE_OPEN_SESSION_REQUEST  byte = 0x10
+	PAYLOAD_TYPE_OPEN_SESSION_RESPONSE byte = 0x11
+)
+
+// Get Channel Authentication Capabilities response bits. Byte 1 is the
+// supported authentication types, byte 2 the authentication status and byte 3
+// the extended capabilities.
+const (
+	CAPS_AUTH_TYPE_NONE     byte = 0x01
+	CAPS_AUTH_TYPE_MD2      byte = 0x02
+	CAPS_AUTH_TYPE_MD5      byte = 0x04
+	CAPS_AUTH_TYPE_PASSWORD byte = 0x10
+	CAPS_AUTH_TYPE_OEM      byte = 0x20
+	CAPS_IPMI2_EXTENDED     byte = 0x80
+
+	CAPS_ANONYMOUS_LOGIN_ENABLED    byte = 0x01
+	CAPS_NULL_USERNAMES_ENABLED     byte = 0x02
+	CAPS_NON_NULL_USERNAMES_ENABLED byte = 0x04
+	CAPS_KG_ENABLED                 byte = 0x08
+	CAPS_PER_MESSAGE_AUTH_DISABLED  byte = 0x10
+	CAPS_USER_LEVEL_AUTH_DISABLED   byte = 0x20
+
+	CAPS_SUPPORTS_IPMI15 byte = 0x01
+	CAPS_SUPPORTS_IPMI20 byte = 0x02
+)
+
+const authCapabilitiesDataLength = 8
+
+var (
+	errIPMIPacketTooShort = errors.New("IPMI packet too short")
+	errNotIPMI            = errors.New("not an IPMI packet")
+	errUnexpectedResponse = errors.New("unexpected IPMI response")
+)
+
+// ipmiChecksum returns the two's complement checksum of b, such that the sum
+// of b and the checksum is zero (mod 256).
+func ipmiChecksum(b []byte) byte {
+	var sum byte
+	for _, v := range b {
+		sum += v
+	}
+	return -sum
+}
+
+// rmcpHeader returns the RMCP header for an IPMI-class message.
+func rmcpHeader() []byte {
+	return []byte{RMCP_VERSION_1, 0x00, RMCP_SEQUENCE_NO_ACK, RMCP_CLASS_IPMI}
+}
+
+// getChannelAuthCapabilitiesRequest returns an IPMI 1.5 session-less Get
+// Channel Authentication Capabilities request for the current channel at
+// the administrator privilege level. If ipmi2 is set, the request asks for
+// the IPMI 2.0 extended capabilities.
+func getChannelAuthCapabilitiesRequest(ipmi2 bool) []byte {
+	channel := CURRENT_CHANNEL
+	if ipmi2 {
+		channel |= CHANNEL_REQUEST_IPMI2
+	}
+	header := []byte{BMC_SLAVE_ADDRESS, NETFN_APP_REQUEST << 2}
+	body := []byte{REMOTE_CONSOLE_SWID, 0x00, CMD_GET_CHANNEL_AUTH_CAPABILITIES, channel, PRIVILEGE_ADMIN}
+	msg := append(header, ipmiChecksum(header))
+	msg = append(msg, body...)
+	msg = append(msg, ipmiChecksum(body))
+
+	ret := rmcpHeader()
+	// Session header: auth type, session sequence number, session ID
+	ret = append(ret, AUTH_TYPE_NONE, 0, 0, 0, 0, 0, 0, 0, 0)
+	ret = append(ret, byte(len(msg)))
+	return append(ret, msg...)
+}
+
+// openSessionRequest returns an RMCP+ Open Session Request proposing cipher
+// suite 0: no authentication, no integrity and no confidentiality.
+func openSessionRequest() []byte {
+	payload := []byte{
+		// Message tag, requested maximum privilege (highest available), reserved
+		0x00, 0x00, 0x00, 0x00,
+		// Remote console session ID
+		0x5a, 0x47, 0x52, 0x32,
+		// Authentication payload: RAKP-none
+		0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00,
+		// Integrity payload: none
+		0x01, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00,
+		// Confidentiality payload: none
+		0x02, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00,
+	}
+	ret := rmcpHeader()
+	// Session header: auth type, payload type, session ID, session sequence number
+	ret = append(ret, AUTH_TYPE_RMCPPLUS, PAYLOAD_TYPE_OPEN_SESSION_REQUEST, 0, 0, 0, 0, 0, 0, 0, 0)
+	ret = append(ret, byte(len(payload)), byte(len(payload)>>8))
+	return append(ret, payload...)
+}
+
+// sendRequest writes the request to the connection and reads a single
+// datagram in response.
+func sendRequest(conn net.Conn, request []byte) ([]byte, error) {
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, MAX_IPMI_DATAGRAM_LENGTH)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// checkRMCPHeader checks that b starts with an IPMI-class RMCP header, and
+// returns the remainder of the packet.
+func checkRMCPHeader(b []byte) ([]byte, error) {
+	if len(b) < RMCP_HEADER_LENGTH {
+		return nil, errIPMIPacketTooShort
+	}
+	if b[0] != RMCP_VERSION_1 || b[3]&0x1f != RMCP_CLASS_IPMI {
+		return nil, errNotIPMI
+	}
+	return b[RMCP_HEADER_LENGTH:], nil
+}
+
+// parseAuthCapabilitiesResponse decodes an IPMI 1.5 session-wrapped response
+// to a Get Channel Authentication Capabilities request, returning the
+// completion code and the response data.
+func parseAuthCapabilitiesResponse(b []byte) (completionCode byte, data []byte, err error) {
+	if b, err = checkRMCPHeader(b); err != nil {
+		return
+	}
+	// Auth type (1), session sequence number (4), session ID (4)
+	if len(b) < 9 {
+		return 0, nil, errIPMIPacketTooShort
+	}
+	authType := b[0]
+	b = b[9:]
+	if authType != AUTH_TYPE_NONE {
+		// 16-byte authentication code
+		if len(b) < 16 {
+			return 0, nil, errIPMIPacketTooShort
+		}
+		b = b[16:]
+	}
+	if len(b) < 1 {
+		return 0, nil, errIPMIPacketTooShort
+	}
+	msgLen := int(b[0])
+	b = b[1:]
+	// rqAddr, netFn/rqLUN, checksum, rsAddr, rqSeq/rsLUN, cmd, completion code, checksum
+	if msgLen < 8 || len(b) < msgLen {
+		return 0, nil, errIPMIPacketTooShort
+	}
+	msg := b[:msgLen]
+	if msg[1]>>2 != NETFN_APP_RESPONSE || msg[5] != CMD_GET_CHANNEL_AUTH_CAPABILITIES {
+		return 0, nil, errUnexpectedResponse
+	}
+	return msg[6], msg[7 : msgLen-1], nil
+}
+
+// parseOpenSessionResponse decodes an RMCP+ Open Session Response, returning
+// its status code.
+func parseOpenSessionResponse(b []byte) (statusCode byte, err error) {
+	if b, err = checkRMCPHeader(b); err != nil {
+		return
+	}
+	// Auth type (1), payload type (1), session ID (4), session sequence number (4), length (2)
+	if len(b) < 12 {
+		return 0, errIPMIPacketTooShort
+	}
+	if b[0] != AUTH_TYPE_RMCPPLUS || b[1]&0x3f != PAYLOAD_TYPE_OPEN_SESSION_RESPONSE {
+		return 0, errUnexpectedResponse
+	}
+	payloadLen := int(binary.LittleEndian.Uint16(b[10:12]))
+	b = b[12:]
+	// Message tag, status code
+	if payloadLen < 2 || len(b) < 2 {
+		return 0, errIPMIPacketTooShort
+	}
+	return b[1], nil
+}
@@ -0,0 +1,66 @@
+package ipmi
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestGetChannelAuthCapabilitiesRequest(t *testing.T) {
+	expected := "0600ff07000000000000000000092018c88100388e04b5"
+	if actual := hex.EncodeToString(getChannelAuthCapabilitiesRequest(true)); actual != expected {
+		t.Errorf("request mismatch: got %s, expected %s", actual, expected)
+	}
+}
+
+func TestParseAuthCapabilitiesResponse(t *testing.T) {
+	// Auth types none/md2/md5/password + IPMI 2.0 extended data; non-null
+	// usernames enabled, null usernames and anonymous login disabled,
+	// per-message auth disabled; supports 1.5 and 2.0.
+	response, _ := hex.DecodeString("0600ff0700000000000000000010811c632000380001971403000000000a")
+	completionCode, data, err := parseAuthCapabilitiesResponse(response)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if completionCode != COMPLETION_CODE_OK {
+		t.Errorf("unexpected completion code %02x", completionCode)
+	}
+	expectedData := []byte{0x01, 0x97, 0x14, 0x03, 0x00, 0x00, 0x00, 0x00}
+	if !bytes.Equal(data, expectedData) {
+		t.Fatalf("data mismatch: got %x, expected %x", data, expectedData)
+	}
+	caps, err := parseAuthCapabilities(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(caps.AuthTypes) != 4 || caps.AuthTypes[0] != "none" {
+		t.Errorf("unexpected auth types %v", caps.AuthTypes)
+	}
+	if !caps.ExtendedCapabilities || !caps.SupportsIPMIv15 || !caps.SupportsIPMIv20 {
+		t.Errorf("expected IPMI 1.5 and 2.0 support: %+v", caps)
+	}
+	if !caps.NonNullUsernamesEnabled || caps.NullUsernamesEnabled || caps.AnonymousLoginEnabled {
+		t.Errorf("unexpected login flags: %+v", caps)
+	}
+	if !caps.PerMessageAuthDisabled {
+		t.Errorf("expected per-message auth to be disabled: %+v", caps)
+	}
+
+	for _, bad := range []string{"", "0600ff06", "0600ff0700000000000000000003811c63"} {
+		b, _ := hex.DecodeString(bad)
+		if _, _, err := parseAuthCapabilitiesResponse(b); err == nil {
+			t.Errorf("expected error parsing %s", bad)
+		}
+	}
+}
+
+func TestParseOpenSessionResponse(t *testing.T) {
+	response, _ := hex.DecodeString("0600ff070611000000000000000024000000040000005a4752320102030400000008000000000100000800000000020000080000000000")
+	status, err := parseOpenSessionResponse(response)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status != 0 {
+		t.Errorf("unexpected status %02x", status)
+	}
+}
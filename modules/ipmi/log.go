@@ -0,0 +1,133 @@
+package ipmi
+
+// AuthCapabilities is the decoded response to a Get Channel Authentication
+// Capabilities request.
+type AuthCapabilities struct {
+	// ChannelNumber is the channel the capabilities apply to.
+	ChannelNumber uint8 `json:"channel_number"`
+
+	// AuthTypes lists the IPMI 1.5 authentication types the channel supports
+	// ("none", "md2", "md5", "password", "oem").
+	AuthTypes []string `json:"auth_types,omitempty"`
+
+	// ExtendedCapabilities is set if the response includes the IPMI 2.0
+	// extended capabilities.
+	ExtendedCapabilities bool `json:"extended_capabilities"`
+
+	// KGEnabled is set if the BMC key (K_g) is set to a non-default value.
+	KGEnabled bool `json:"kg_enabled"`
+
+	// PerMessageAuthDisabled is set if per-message authentication is disabled.
+	PerMessageAuthDisabled bool `json:"per_message_auth_disabled"`
+
+	// UserLevelAuthDisabled is set if user-level authentication is disabled.
+	UserLevelAuthDisabled bool `json:"user_level_auth_disabled"`
+
+	// NonNullUsernamesEnabled is set if users with non-null usernames are
+	// enabled.
+	NonNullUsernamesEnabled bool `json:"non_null_usernames_enabled"`
+
+	// NullUsernamesEnabled is set if users with null usernames (and non-null
+	// passwords) are enabled.
+	NullUsernamesEnabled bool `json:"null_usernames_enabled"`
+
+	// AnonymousLoginEnabled is set if the null user with a null password is
+	// enabled.
+	AnonymousLoginEnabled bool `json:"anonymous_login_enabled"`
+
+	// SupportsIPMIv15 is set if the channel supports IPMI 1.5 connections.
+	SupportsIPMIv15 bool `json:"supports_ipmi_v1_5"`
+
+	// SupportsIPMIv20 is set if the channel supports IPMI 2.0 (RMCP+)
+	// connections.
+	SupportsIPMIv20 bool `json:"supports_ipmi_v2_0"`
+
+	// OEMID is the IANA enterprise number of the OEM, if any.
+	OEMID uint32 `json:"oem_id"`
+
+	// OEMAuxData is the OEM auxiliary data byte.
+	OEMAuxData uint8 `json:"oem_aux_data"`
+
+	// Raw is the raw response data the above fields were parsed from.
+	Raw []byte `json:"raw,omitempty"`
+}
+
+// Log is the struct returned to the caller.
+type Log struct {
+	// IsIPMI is set if the server responded with an IPMI-class RMCP message.
+	IsIPMI bool `json:"is_ipmi"`
+
+	// CompletionCode is the completion code of the Get Channel Authentication
+	// Capabilities response.
+	CompletionCode uint8 `json:"completion_code"`
+
+	// IPMIVersion is the highest IPMI version supported by the channel,
+	// either "1.5" or "2.0".
+	IPMIVersion string `json:"ipmi_version,omitempty"`
+
+	// Capabilities is the parsed Get Channel Authentication Capabilities
+	// response.
+	Capabilities *AuthCapabilities `json:"capabilities,omitempty"`
+
+	// NullAuth is set if the channel supports the "none" authentication
+	// type.
+	NullAuth bool `json:"null_auth"`
+
+	// AnonymousLogin is set if the channel permits logging in as the null
+	// user, with or without a password.
+	AnonymousLogin bool `json:"anonymous_login"`
+
+	// CipherZero is set if the BMC accepted an RMCP+ Open Session Request
+	// for cipher suite 0 (no authentication, integrity or confidentiality).
+	// It is only present if --cipher-zero was given and the channel supports
+	// IPMI 2.0.
+	CipherZero *bool `json:"cipher_zero,omitempty"`
+
+	// OpenSessionStatus is the status code of the cipher suite 0 Open
+	// Session Response.
+	OpenSessionStatus *uint8 `json:"open_session_status,omitempty"`
+}
+
+// parseAuthCapabilities decodes the data of a Get Channel Authentication
+// Capabilities response.
+func parseAuthCapabilities(data []byte) (*AuthCapabilities, error) {
+	if len(data) < authCapabilitiesDataLength {
+		return nil, errIPMIPacketTooShort
+	}
+	caps := &AuthCapabilities{
+		ChannelNumber:           data[0],
+		ExtendedCapabilities:    data[1]&CAPS_IPMI2_EXTENDED != 0,
+		KGEnabled:               data[2]&CAPS_KG_ENABLED != 0,
+		PerMessageAuthDisabled:  data[2]&CAPS_PER_MESSAGE_AUTH_DISABLED != 0,
+		UserLevelAuthDisabled:   data[2]&CAPS_USER_LEVEL_AUTH_DISABLED != 0,
+		NonNullUsernamesEnabled: data[2]&CAPS_NON_NULL_USERNAMES_ENABLED != 0,
+		NullUsernamesEnabled:    data[2]&CAPS_NULL_USERNAMES_ENABLED != 0,
+		AnonymousLoginEnabled:   data[2]&CAPS_ANONYMOUS_LOGIN_ENABLED != 0,
+		OEMID:                   uint32(data[4]) | uint32(data[5])<<8 | uint32(data[6])<<16,
+		OEMAuxData:              data[7],
+		Raw:                     data,
+	}
+	authTypes := []struct {
+		bit  byte
+		name string
+	}{
+		{CAPS_AUTH_TYPE_NONE, "none"},
+		{CAPS_AUTH_TYPE_MD2, "md2"},
+		{CAPS_AUTH_TYPE_MD5, "md5"},
+		{CAPS_AUTH_TYPE_PASSWORD, "password"},
+		{CAPS_AUTH_TYPE_OEM, "oem"},
+	}
+	for _, authType := range authTypes {
+		if data[1]&authType.bit != 0 {
+			caps.AuthTypes = append(caps.AuthTypes, authType.name)
+		}
+	}
+	if caps.ExtendedCapabilities {
+		caps.SupportsIPMIv15 = data[3]&CAPS_SUPPORTS_IPMI15 != 0
+		caps.SupportsIPMIv20 = data[3]&CAPS_SUPPORTS_IPMI20 != 0
+	} else {
+		// Without the extended capabilities, only IPMI 1.5 is known to work.
+		caps.SupportsIPMIv15 = true
+	}
+	return caps, nil
+}
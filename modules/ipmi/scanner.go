@@ -0,0 +1,186 @@
+// Package ipmi provides a zgrab2 module that scans for IPMI BMCs.
+// Default Port: 623 (UDP)
+//
+// The scan sends an RMCP Get Channel Authentication Capabilities request,
+// which does not require a session, and records the supported authentication
+// types, whether anonymous / null-user login is permitted and the supported
+// IPMI versions.
+//
+// If --cipher-zero is set and the BMC supports IPMI 2.0, it then sends an
+// RMCP+ Open Session Request for cipher suite 0 and records whether the BMC
+// accepted it.
+package ipmi
+
+import (
+	"context"
+	"net"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmap/zgrab2"
+)
+
+// Scan results are in log.go
+
+// Flags holds the command-line configuration for the ipmi scan module.
+// Populated by the framework.
+type Flags struct {
+	zgrab2.BaseFlags
+	zgrab2.UDPFlags
+
+	CipherZero bool `long:"cipher-zero" description:"If the BMC supports IPMI 2.0, check whether it accepts an RMCP+ session using cipher suite 0"`
+	Verbose    bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
+}
+
+// Module implements the zgrab2.Module interface.
+type Module struct {
+}
+
+// Scanner implements the zgrab2.Scanner interface.
+type Scanner struct {
+	config *Flags
+}
+
+// RegisterModule registers the zgrab2 module.
+func RegisterModule() {
+	var module Module
+	_, err := zgrab2.AddCommand("ipmi", "ipmi", module.Description(), 623, &module)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewFlags returns a default Flags object.
+func (module *Module) NewFlags() interface{} {
+	return new(Flags)
+}
+
+// NewScanner returns a new Scanner instance.
+func (module *Module) NewScanner() zgrab2.Scanner {
+	return new(Scanner)
+}
+
+// Description returns an overview of this module.
+func (module *Module) Description() string {
+	return "Probe for IPMI BMCs and their authentication capabilities"
+}
+
+// Validate checks that the flags are valid.
+// On success, returns nil.
+// On failure, returns an error instance describing the error.
+func (flags *Flags) Validate(args []string) error {
+	return nil
+}
+
+// Help returns the module's help string.
+func (flags *Flags) Help() string {
+	return ""
+}
+
+// Init initializes the Scanner.
+func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
+	f, _ := flags.(*Flags)
+	scanner.config = f
+	return nil
+}
+
+// InitPerSender initializes the scanner for a given sender.
+func (scanner *Scanner) InitPerSender(senderID int) error {
+	return nil
+}
+
+// GetName returns the Scanner name defined in the Flags.
+func (scanner *Scanner) GetName() string {
+	return scanner.config.Name
+}
+
+// GetTrigger returns the Trigger defined in the Flags.
+func (scanner *Scanner) GetTrigger() string {
+	return scanner.config.Trigger
+}
+
+// Protocol returns the protocol identifier of the scan.
+func (scanner *Scanner) Protocol() string {
+	return "ipmi"
+}
+
+// getAuthCapabilities sends a Get Channel Authentication Capabilities request
+// and parses the response into result. BMCs that only speak IPMI 1.5 may
+// reject the request for the IPMI 2.0 extended data, in which case the
+// request is retried without it.
+func getAuthCapabilities(result *Log, conn net.Conn, ipmi2 bool) error {
+	response, err := sendRequest(conn, getChannelAuthCapabilitiesRequest(ipmi2))
+	if err != nil {
+		return err
+	}
+	completionCode, data, err := parseAuthCapabilitiesResponse(response)
+	if err != nil {
+		if err != errIPMIPacketTooShort && err != errNotIPMI {
+			result.IsIPMI = true
+		}
+		return zgrab2.NewScanError(zgrab2.SCAN_PROTOCOL_ERROR, err)
+	}
+	result.IsIPMI = true
+	result.CompletionCode = completionCode
+	if completionCode == COMPLETION_CODE_INVALID_REQUEST && ipmi2 {
+		return getAuthCapabilities(result, conn, false)
+	}
+	if completionCode != COMPLETION_CODE_OK {
+		return zgrab2.NewScanError(zgrab2.SCAN_APPLICATION_ERROR, errUnexpectedResponse)
+	}
+	caps, err := parseAuthCapabilities(data)
+	if err != nil {
+		return zgrab2.NewScanError(zgrab2.SCAN_PROTOCOL_ERROR, err)
+	}
+	result.Capabilities = caps
+	return nil
+}
+
+// Scan probes for an IPMI BMC.
+//  1. Connects to the configured port over UDP (default 623).
+//  2. Sends a Get Channel Authentication Capabilities request asking for the
+//     IPMI 2.0 extended data, falling back to a plain IPMI 1.5 request if the
+//     BMC rejects it.
+//  3. If the response is an IPMI message, the service is considered detected.
+//  4. Parses the capabilities, and sets NullAuth / AnonymousLogin from them.
+//  5. If --cipher-zero is set and IPMI 2.0 is supported, sends an RMCP+ Open
+//     Session Request for cipher suite 0 and sets CipherZero.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	conn, err := target.OpenUDP(&scanner.config.BaseFlags, &scanner.config.UDPFlags)
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	defer conn.Close()
+	result := new(Log)
+	if err := getAuthCapabilities(result, conn, true); err != nil {
+		if !result.IsIPMI {
+			return zgrab2.TryGetScanStatus(err), nil, err
+		}
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+	caps := result.Capabilities
+	result.IPMIVersion = "1.5"
+	if caps.SupportsIPMIv20 {
+		result.IPMIVersion = "2.0"
+	}
+	for _, authType := range caps.AuthTypes {
+		if authType == "none" {
+			result.NullAuth = true
+		}
+	}
+	result.AnonymousLogin = caps.AnonymousLoginEnabled || caps.NullUsernamesEnabled
+
+	if scanner.config.CipherZero && caps.SupportsIPMIv20 {
+		response, err := sendRequest(conn, openSessionRequest())
+		if err != nil {
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+		status, err := parseOpenSessionResponse(response)
+		if err != nil {
+			return zgrab2.SCAN_PROTOCOL_ERROR, result, err
+		}
+		accepted := status == 0
+		result.OpenSessionStatus = &status
+		result.CipherZero = &accepted
+	}
+	return zgrab2.SCAN_SUCCESS, result, nil
+}
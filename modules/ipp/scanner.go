@@ -4,6 +4,7 @@ package ipp
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/binary"
 	"errors"
@@ -16,6 +17,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/zmap/zgrab2"
@@ -46,6 +48,7 @@ var (
 )
 
 type scan struct {
+	target      *zgrab2.ScanTarget
 	connections []net.Conn
 	transport   *http.Transport
 	client      *http.Client
@@ -624,22 +627,45 @@ func (scan *scan) getCheckRedirect(scanner *Scanner) func(*http.Request, *http.R
 // Taken from zgrab2 http library, slightly modified to use slightly leaner scan object
 func (scan *scan) getTLSDialer(scanner *Scanner) func(net, addr string) (net.Conn, error) {
 	return func(net, addr string) (net.Conn, error) {
-		outer, err := zgrab2.DialTimeoutConnection(net, addr, scanner.config.BaseFlags.Timeout, 0)
+		start := time.Now()
+		outer, err := zgrab2.DialTimeoutConnectionContext(scan.target.Context(), net, addr, scanner.config.BaseFlags.Timeout, 0)
 		if err != nil {
 			return nil, err
 		}
+		scan.target.RecordConnect(start)
+		scan.target.RecordRemoteAddr(outer.RemoteAddr())
 		scan.connections = append(scan.connections, outer)
 		tlsConn, err := scanner.config.TLSFlags.GetTLSConnection(outer)
 		if err != nil {
 			return nil, err
 		}
 		// lib/http/transport.go fills in the TLSLog in the http.Request instance(s)
+		start = time.Now()
 		err = tlsConn.Handshake()
 		scan.results.TLSLog = tlsConn.GetLog()
+		if err == nil {
+			scan.target.RecordHandshake(start)
+		}
 		return tlsConn, err
 	}
 }
 
+// getDialContext returns a DialContext function for plaintext connections
+// that records their timing and remote address.
+func (scan *scan) getDialContext(scanner *Scanner) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := zgrab2.GetTimeoutConnectionDialer(scanner.config.Timeout)
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		start := time.Now()
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		scan.target.RecordConnect(start)
+		scan.target.RecordRemoteAddr(conn.RemoteAddr())
+		return conn, nil
+	}
+}
+
 // This doesn't use ipp(s) scheme, because http doesn't recognize them, so we need http scheme
 // We convert as needed later in convertURIToIPP
 func getHTTPURL(https bool, host string, port uint16, endpoint string) string {
@@ -655,6 +681,7 @@ func getHTTPURL(https bool, host string, port uint16, endpoint string) string {
 // Adapted from newHTTPScan in zgrab2 http module
 func (scanner *Scanner) newIPPScan(target *zgrab2.ScanTarget, tls bool) *scan {
 	newScan := scan{
+		target: target,
 		client: http.MakeNewClient(),
 	}
 	newScan.results = ScanResults{}
@@ -665,7 +692,7 @@ func (scanner *Scanner) newIPPScan(target *zgrab2.ScanTarget, tls bool) *scan {
 		MaxIdleConnsPerHost: scanner.config.MaxRedirects,
 	}
 	transport.DialTLS = newScan.getTLSDialer(scanner)
-	transport.DialContext = zgrab2.GetTimeoutConnectionDialer(scanner.config.Timeout).DialContext
+	transport.DialContext = newScan.getDialContext(scanner)
 	newScan.client.CheckRedirect = newScan.getCheckRedirect(scanner)
 	newScan.client.UserAgent = scanner.config.UserAgent
 	newScan.client.Transport = transport
@@ -729,7 +756,7 @@ func (scan *scan) shouldReportResult(scanner *Scanner) bool {
 // Scan TODO: describe how scan operates in appropriate detail
 //1. Send a request (currently get-printer-attributes)
 //2. Take in that response & read out version numbers
-func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
 	// Try all known IPP versions from newest to oldest until we reach a supported version
 	scan, err := scanner.tryGrabForVersions(&target, Versions, scanner.config.TLSRetry || scanner.config.IPPSecure)
 	if err != nil {
@@ -0,0 +1,141 @@
+// IRC client for the irc module: message parsing and just enough of
+// registration (RFC 2812, with IRCv3 capability negotiation) to read the
+// server's welcome numerics.
+// https://modern.ircdocs.horse/
+
+package irc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// maxLineLength bounds the length of a single message, including IRCv3 tags.
+const maxLineLength = 8191 + 512
+
+var (
+	// ErrInvalidMessage is returned when a line from the server cannot be
+	// parsed as an IRC message.
+	ErrInvalidMessage = errors.New("invalid IRC message")
+
+	// ErrLineTooLong is returned when a line from the server exceeds
+	// maxLineLength.
+	ErrLineTooLong = errors.New("IRC line too long")
+)
+
+// Message is a parsed IRC message.
+type Message struct {
+	// Prefix is the message source, without the leading ':'.
+	Prefix string
+
+	// Command is the command or three-digit numeric.
+	Command string
+
+	// Params holds the parameters, including any trailing parameter.
+	Params []string
+}
+
+// Trailing returns the last parameter, or "" if there are none.
+func (m *Message) Trailing() string {
+	if len(m.Params) == 0 {
+		return ""
+	}
+	return m.Params[len(m.Params)-1]
+}
+
+// ParseMessage parses a single IRC line (without its line terminator). IRCv3
+// message tags are ignored.
+func ParseMessage(line string) (*Message, error) {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, "@") {
+		i := strings.IndexByte(line, ' ')
+		if i < 0 {
+			return nil, ErrInvalidMessage
+		}
+		line = strings.TrimLeft(line[i+1:], " ")
+	}
+	msg := new(Message)
+	if strings.HasPrefix(line, ":") {
+		i := strings.IndexByte(line, ' ')
+		if i < 0 {
+			return nil, ErrInvalidMessage
+		}
+		msg.Prefix = line[1:i]
+		line = strings.TrimLeft(line[i+1:], " ")
+	}
+	for line != "" {
+		if strings.HasPrefix(line, ":") && msg.Command != "" {
+			msg.Params = append(msg.Params, line[1:])
+			break
+		}
+		var field string
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			field, line = line[:i], strings.TrimLeft(line[i+1:], " ")
+		} else {
+			field, line = line, ""
+		}
+		if msg.Command == "" {
+			msg.Command = strings.ToUpper(field)
+		} else {
+			msg.Params = append(msg.Params, field)
+		}
+	}
+	if msg.Command == "" || !isCommand(msg.Command) {
+		return nil, ErrInvalidMessage
+	}
+	return msg, nil
+}
+
+// isCommand returns true if s is a valid command: letters, or a three-digit
+// numeric.
+func isCommand(s string) bool {
+	if len(s) == 3 && s[0] >= '0' && s[0] <= '9' && s[1] >= '0' && s[1] <= '9' && s[2] >= '0' && s[2] <= '9' {
+		return true
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'A' || s[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Connection wraps the state of an IRC connection.
+type Connection struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewConnection returns a Connection over the given net.Conn.
+func NewConnection(conn net.Conn) *Connection {
+	return &Connection{conn: conn, reader: bufio.NewReaderSize(conn, maxLineLength)}
+}
+
+// Send writes a single command line, appending the line terminator.
+func (c *Connection) Send(format string, args ...interface{}) error {
+	_, err := c.conn.Write([]byte(fmt.Sprintf(format, args...) + "\r\n"))
+	return err
+}
+
+// ReadMessage reads and parses the next message. Blank lines are skipped.
+func (c *Connection) ReadMessage() (*Message, error) {
+	for {
+		line, err := c.reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			return nil, ErrLineTooLong
+		}
+		if err != nil && len(line) == 0 {
+			return nil, err
+		}
+		if strings.TrimSpace(string(line)) == "" {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return ParseMessage(string(line))
+	}
+}
@@ -0,0 +1,116 @@
+package irc
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMessage(t *testing.T) {
+	tests := map[string]Message{
+		":irc.example.net 001 zgrab :Welcome to ExampleNet zgrab\r\n": {
+			Prefix: "irc.example.net", Command: "001", Params: []string{"zgrab", "Welcome to ExampleNet zgrab"},
+		},
+		"PING :12345": {Command: "PING", Params: []string{"12345"}},
+		"@time=2021-01-01T00:00:00Z :srv CAP * LS * :sasl multi-prefix": {
+			Prefix: "srv", Command: "CAP", Params: []string{"*", "LS", "*", "sasl multi-prefix"},
+		},
+		"notice AUTH :*** Looking up your hostname": {Command: "NOTICE", Params: []string{"AUTH", "*** Looking up your hostname"}},
+	}
+	for line, expected := range tests {
+		msg, err := ParseMessage(line)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", line, err)
+			continue
+		}
+		if !reflect.DeepEqual(*msg, expected) {
+			t.Errorf("%q: expected %+v, got %+v", line, expected, *msg)
+		}
+	}
+	for _, line := range []string{"SSH-2.0-OpenSSH_8.2", "HTTP/1.1 400 Bad Request", ":prefixonly"} {
+		if _, err := ParseMessage(line); err != ErrInvalidMessage {
+			t.Errorf("%q: expected ErrInvalidMessage, got %v", line, err)
+		}
+	}
+}
+
+// serveIRC answers the client's registration on conn and returns the lines
+// the client sent.
+func serveIRC(conn net.Conn, sent chan<- []string) {
+	reader := bufio.NewReader(conn)
+	var lines []string
+	// Writes are queued so that they cannot block on the client's writes
+	// over the synchronous pipe.
+	out := make(chan string, 16)
+	defer close(out)
+	go func() {
+		for s := range out {
+			conn.Write([]byte(s))
+		}
+	}()
+	write := func(s string) { out <- s }
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+		switch {
+		case line == "CAP LS 302":
+			write(":srv NOTICE * :*** Looking up your hostname...\r\n")
+			write(":srv CAP * LS * :multi-prefix sasl\r\n:srv CAP * LS :server-time\r\n")
+		case strings.HasPrefix(line, "USER "):
+			write("PING :cookie\r\n")
+		case line == "PONG :cookie":
+			write(":srv 001 zgrab :Welcome to the ExampleNet IRC Network\r\n" +
+				":srv 002 zgrab :Your host is srv, running version solanum-1.0\r\n" +
+				":srv 003 zgrab :This server was created today\r\n" +
+				":srv 004 zgrab srv solanum-1.0 DGIOQRSZaghilopsuwz CFILMPQRSTbcefgijklmnopqrstuvz bkloveqjfI\r\n" +
+				":srv 005 zgrab CHANTYPES=# NETWORK=ExampleNet NICKLEN=16 :are supported by this server\r\n" +
+				":srv 422 zgrab :MOTD File is missing\r\n")
+		case line == "QUIT":
+			sent <- lines
+			return
+		}
+	}
+	sent <- lines
+}
+
+func TestRegister(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	sent := make(chan []string, 1)
+	go serveIRC(server, sent)
+	scanner := &Scanner{config: &Flags{Nick: "zgrab", User: "zgrab2", RealName: "zgrab2", MaxMessages: 100}}
+	conn := NewConnection(client)
+	result := &ScanResults{}
+	isIRC, err := scanner.register(conn, result)
+	if !isIRC || err != nil {
+		t.Fatalf("unexpected result: %v, %v", isIRC, err)
+	}
+	conn.Send("QUIT")
+	expected := &ScanResults{
+		Notices:      []string{"*** Looking up your hostname..."},
+		Capabilities: []string{"multi-prefix", "sasl", "server-time"},
+		Welcome:      "Welcome to the ExampleNet IRC Network",
+		YourHost:     "Your host is srv, running version solanum-1.0",
+		Created:      "This server was created today",
+		ServerName:   "srv",
+		Version:      "solanum-1.0",
+		UserModes:    "DGIOQRSZaghilopsuwz",
+		ChannelModes: "CFILMPQRSTbcefgijklmnopqrstuvz",
+		ISupport:     []string{"CHANTYPES=#", "NETWORK=ExampleNet", "NICKLEN=16"},
+		Network:      "ExampleNet",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %+v, got %+v", expected, result)
+	}
+	lines := <-sent
+	expectedLines := []string{"CAP LS 302", "NICK zgrab", "USER zgrab2 0 * :zgrab2", "CAP END", "PONG :cookie", "QUIT"}
+	if !reflect.DeepEqual(lines, expectedLines) {
+		t.Errorf("expected client to send %q, got %q", expectedLines, lines)
+	}
+}
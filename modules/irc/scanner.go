@@ -0,0 +1,334 @@
+// Package irc provides a zgrab2 module that scans for IRC servers.
+// Default Port: 6667 (TCP)
+//
+// The scanner sends CAP LS (unless --no-cap is set), then registers with
+// NICK and USER, answering any PING it receives while doing so. It records
+// the advertised IRCv3 capabilities and the welcome numerics: 001-003, the
+// server name and version from 004 (which identify the ircd software), and
+// the ISUPPORT tokens from 005 (including NETWORK, which names the network).
+// Reading stops at the end of the MOTD (376 / 422), at the first error, or
+// after --max-messages messages, and the scanner then sends QUIT.
+//
+// The --ircs flag tells the scanner to perform a TLS handshake immediately
+// after connecting, using the standard TLS flags. It is implied when
+// scanning port 6697, the standard IRC-over-TLS port.
+package irc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmap/zgrab2"
+)
+
+// ircsPort is the standard port for IRC over implicit TLS.
+const ircsPort = 6697
+
+// ScanResults instances are returned by the module's Scan function.
+type ScanResults struct {
+	// Notices holds the NOTICE messages sent before registration completed,
+	// e.g. "*** Looking up your hostname...".
+	Notices []string `json:"notices,omitempty"`
+
+	// Capabilities lists the IRCv3 capabilities from the CAP LS reply.
+	Capabilities []string `json:"capabilities,omitempty"`
+
+	// Welcome is the text of the 001 (RPL_WELCOME) reply.
+	Welcome string `json:"welcome,omitempty"`
+
+	// YourHost is the text of the 002 (RPL_YOURHOST) reply.
+	YourHost string `json:"your_host,omitempty"`
+
+	// Created is the text of the 003 (RPL_CREATED) reply.
+	Created string `json:"created,omitempty"`
+
+	// ServerName is the server name from the 004 (RPL_MYINFO) reply.
+	ServerName string `json:"server_name,omitempty"`
+
+	// Version is the ircd version from the 004 (RPL_MYINFO) reply.
+	Version string `json:"version,omitempty"`
+
+	// UserModes lists the available user modes, from the 004 reply.
+	UserModes string `json:"user_modes,omitempty"`
+
+	// ChannelModes lists the available channel modes, from the 004 reply.
+	ChannelModes string `json:"channel_modes,omitempty"`
+
+	// ISupport lists the tokens from the 005 (RPL_ISUPPORT) replies, e.g.
+	// "NETWORK=Libera.Chat".
+	ISupport []string `json:"isupport,omitempty"`
+
+	// Network is the value of the NETWORK ISUPPORT token.
+	Network string `json:"network,omitempty"`
+
+	// Error is the text of the ERROR message, if the server closed the
+	// connection with one.
+	Error string `json:"error,omitempty"`
+
+	// TLSLog is the standard TLS log, if --ircs is enabled.
+	TLSLog *zgrab2.TLSLog `json:"tls,omitempty"`
+}
+
+// Flags holds the command-line configuration for the IRC scan module.
+// Populated by the framework.
+type Flags struct {
+	zgrab2.BaseFlags
+	zgrab2.TLSFlags
+
+	// IRCSecure indicates that the client should do a TLS handshake immediately after connecting.
+	IRCSecure bool `long:"ircs" description:"Immediately negotiate a TLS connection (implied on port 6697)"`
+
+	// Nick is the nickname to register with.
+	Nick string `long:"nick" description:"Nickname to register with (default: a random zgrab nickname)"`
+
+	// User is the username sent in the USER command.
+	User string `long:"user" default:"zgrab2" description:"Username to send in the USER command"`
+
+	// RealName is the real name sent in the USER command.
+	RealName string `long:"realname" default:"zgrab2" description:"Real name to send in the USER command"`
+
+	// NoCAP indicates that CAP LS should not be sent.
+	NoCAP bool `long:"no-cap" description:"Do not send CAP LS before registering"`
+
+	// MaxMessages bounds the number of messages read.
+	MaxMessages int `long:"max-messages" default:"200" description:"Maximum number of messages to read before giving up"`
+
+	// Verbose indicates that there should be more verbose logging.
+	Verbose bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
+}
+
+// Module implements the zgrab2.Module interface.
+type Module struct {
+}
+
+// Scanner implements the zgrab2.Scanner interface.
+type Scanner struct {
+	config *Flags
+}
+
+// RegisterModule registers the zgrab2 module.
+func RegisterModule() {
+	var module Module
+	_, err := zgrab2.AddCommand("irc", "irc", module.Description(), 6667, &module)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewFlags returns a default Flags object.
+func (module *Module) NewFlags() interface{} {
+	return new(Flags)
+}
+
+// NewScanner returns a new Scanner instance.
+func (module *Module) NewScanner() zgrab2.Scanner {
+	return new(Scanner)
+}
+
+// Description returns an overview of this module.
+func (module *Module) Description() string {
+	return "Register with an IRC server and record its welcome numerics and capabilities"
+}
+
+// Validate checks that the flags are valid.
+// On success, returns nil.
+// On failure, returns an error instance describing the error.
+func (flags *Flags) Validate(args []string) error {
+	if flags.MaxMessages <= 0 {
+		log.Error("--max-messages must be positive")
+		return zgrab2.ErrInvalidArguments
+	}
+	if strings.ContainsAny(flags.Nick+flags.User, " \r\n") || strings.ContainsAny(flags.RealName, "\r\n") {
+		log.Error("--nick, --user and --realname must not contain line breaks, and --nick and --user must not contain spaces")
+		return zgrab2.ErrInvalidArguments
+	}
+	return nil
+}
+
+// Help returns the module's help string.
+func (flags *Flags) Help() string {
+	return ""
+}
+
+// Init initializes the Scanner.
+func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
+	f, _ := flags.(*Flags)
+	scanner.config = f
+	return nil
+}
+
+// InitPerSender initializes the scanner for a given sender.
+func (scanner *Scanner) InitPerSender(senderID int) error {
+	return nil
+}
+
+// GetName returns the Scanner name defined in the Flags.
+func (scanner *Scanner) GetName() string {
+	return scanner.config.Name
+}
+
+// GetTrigger returns the Trigger defined in the Flags.
+func (scanner *Scanner) GetTrigger() string {
+	return scanner.config.Trigger
+}
+
+// Protocol returns the protocol identifier of the scan.
+func (scanner *Scanner) Protocol() string {
+	return "irc"
+}
+
+// getNick returns the nickname to register with.
+func (scanner *Scanner) getNick() string {
+	if scanner.config.Nick != "" {
+		return scanner.config.Nick
+	}
+	return fmt.Sprintf("zgrab%04d", rand.Intn(10000))
+}
+
+// register performs registration over conn and reads messages until the end
+// of the MOTD, recording them in result. It returns true if any valid IRC
+// message was received.
+func (scanner *Scanner) register(conn *Connection, result *ScanResults) (bool, error) {
+	nick := scanner.getNick()
+	if !scanner.config.NoCAP {
+		if err := conn.Send("CAP LS 302"); err != nil {
+			return false, err
+		}
+	}
+	if err := conn.Send("NICK %s", nick); err != nil {
+		return false, err
+	}
+	if err := conn.Send("USER %s 0 * :%s", scanner.config.User, scanner.config.RealName); err != nil {
+		return false, err
+	}
+	isIRC := false
+	for i := 0; i < scanner.config.MaxMessages; i++ {
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			return isIRC, err
+		}
+		isIRC = true
+		switch msg.Command {
+		case "PING":
+			if err := conn.Send("PONG :%s", msg.Trailing()); err != nil {
+				return isIRC, err
+			}
+		case "NOTICE":
+			if result.Welcome == "" {
+				result.Notices = append(result.Notices, msg.Trailing())
+			}
+		case "CAP":
+			// CAP <target> LS [*] :<caps>; the * marks a continuation.
+			if len(msg.Params) < 3 || strings.ToUpper(msg.Params[1]) != "LS" {
+				continue
+			}
+			result.Capabilities = append(result.Capabilities, strings.Fields(msg.Trailing())...)
+			if len(msg.Params) == 3 {
+				if err := conn.Send("CAP END"); err != nil {
+					return isIRC, err
+				}
+			}
+		case "001":
+			result.Welcome = msg.Trailing()
+		case "002":
+			result.YourHost = msg.Trailing()
+		case "003":
+			result.Created = msg.Trailing()
+		case "004":
+			// 004 <nick> <servername> <version> <usermodes> <chanmodes> ...
+			if len(msg.Params) > 1 {
+				result.ServerName = msg.Params[1]
+			}
+			if len(msg.Params) > 2 {
+				result.Version = msg.Params[2]
+			}
+			if len(msg.Params) > 3 {
+				result.UserModes = msg.Params[3]
+			}
+			if len(msg.Params) > 4 {
+				result.ChannelModes = msg.Params[4]
+			}
+		case "005":
+			// 005 <nick> <token>... :are supported by this server
+			if len(msg.Params) < 3 {
+				continue
+			}
+			for _, token := range msg.Params[1 : len(msg.Params)-1] {
+				result.ISupport = append(result.ISupport, token)
+				if strings.HasPrefix(token, "NETWORK=") {
+					result.Network = strings.TrimPrefix(token, "NETWORK=")
+				}
+			}
+		case "433":
+			// ERR_NICKNAMEINUSE, before registration completes
+			if result.Welcome == "" {
+				nick += "_"
+				if err := conn.Send("NICK %s", nick); err != nil {
+					return isIRC, err
+				}
+			}
+		case "376", "422":
+			// RPL_ENDOFMOTD / ERR_NOMOTD
+			return isIRC, nil
+		case "ERROR":
+			result.Error = msg.Trailing()
+			return isIRC, errors.New("server sent ERROR: " + result.Error)
+		}
+	}
+	return isIRC, nil
+}
+
+// Scan performs the IRC scan.
+//  1. Open a TCP connection to the target port (default 6667).
+//  2. If --ircs is set, or the port is 6697, perform a TLS handshake using
+//     the command-line flags.
+//  3. Send CAP LS (unless --no-cap is set), NICK and USER.
+//  4. Read messages, answering PINGs and ending capability negotiation,
+//     until the end of the MOTD. If nothing that parses as IRC is received,
+//     fail with a protocol error.
+//  5. Send QUIT and close the connection.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	c, err := target.Open(&scanner.config.BaseFlags)
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	zgrab2.EnableTLSDetection(c)
+	defer c.Close()
+	result := &ScanResults{}
+	port := scanner.config.Port
+	if target.Port != nil {
+		port = *target.Port
+	}
+	if scanner.config.IRCSecure || port == ircsPort {
+		tlsConn, err := scanner.config.TLSFlags.GetTLSConnection(c)
+		if err != nil {
+			return zgrab2.TryGetScanStatus(err), nil, err
+		}
+		result.TLSLog = tlsConn.GetLog()
+		if err := tlsConn.Handshake(); err != nil {
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+		c = tlsConn
+	}
+	conn := NewConnection(c)
+	isIRC, err := scanner.register(conn, result)
+	if !isIRC {
+		if err == ErrInvalidMessage || err == ErrLineTooLong {
+			return zgrab2.SCAN_PROTOCOL_ERROR, nil, err
+		}
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	if err != nil {
+		if result.Error != "" {
+			return zgrab2.SCAN_APPLICATION_ERROR, result, err
+		}
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+	// Best effort; the results are complete either way.
+	conn.Send("QUIT")
+	return zgrab2.SCAN_SUCCESS, result, nil
+}
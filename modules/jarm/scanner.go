@@ -3,11 +3,11 @@
 package jarm
 
 import (
+	"context"
 	_ "fmt"
 	jarm "github.com/RumbleDiscovery/jarm-go"
 	"github.com/zmap/zgrab2"
 	"log"
-	"net"
 	"strings"
 	"time"
 )
@@ -98,43 +98,61 @@ func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
 	return nil
 }
 
-func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+// emptyResponse is the raw hash of a probe that got no ServerHello (e.g. the
+// connection was reset); it contributes zeroed components to the fingerprint.
+const emptyResponse = "|||"
+
+// Fingerprint sends the ten JARM probes to the target, each on a new
+// connection, and returns the 62-character JARM fingerprint. A probe whose
+// connection is refused or reset, or that gets no ServerHello, contributes
+// zeroed components; only a failure to connect for the first probe is
+// returned as an error.
+func Fingerprint(target *zgrab2.ScanTarget, flags *zgrab2.BaseFlags) (string, error) {
+	port := flags.Port
+	if target.Port != nil {
+		port = *target.Port
+	}
 	// Stores raw hashes returned from parsing each protocols Hello message
 	rawhashes := []string{}
 
 	// Loop through each Probe type
-	for _, probe := range jarm.GetProbes(target.Host(), int(scanner.GetPort())) {
-		var (
-			conn net.Conn
-			err  error
-			ret  []byte
-		)
-		conn, err = target.Open(&scanner.config.BaseFlags)
+	for i, probe := range jarm.GetProbes(target.Host(), int(port)) {
+		conn, err := target.Open(flags)
 		if err != nil {
-			return zgrab2.TryGetScanStatus(err), nil, err
+			if i == 0 {
+				return "", err
+			}
+			rawhashes = append(rawhashes, emptyResponse)
+			continue
 		}
 
-		_, err = conn.Write(jarm.BuildProbe(probe))
-		if err != nil {
-			rawhashes = append(rawhashes, "")
+		if _, err = conn.Write(jarm.BuildProbe(probe)); err != nil {
+			rawhashes = append(rawhashes, emptyResponse)
 			conn.Close()
 			continue
 		}
 
-		ret, _ = zgrab2.ReadAvailableWithOptions(conn, 1484, 500*time.Millisecond, 0, 1484)
+		ret, _ := zgrab2.ReadAvailableWithOptions(conn, 1484, 500*time.Millisecond, 0, 1484)
 
 		ans, err := jarm.ParseServerHello(ret, probe)
 		if err != nil {
-			rawhashes = append(rawhashes, "")
-			conn.Close()
-			continue
+			ans = emptyResponse
 		}
 
 		rawhashes = append(rawhashes, ans)
 		conn.Close()
 	}
 
+	return jarm.RawHashToFuzzyHash(strings.Join(rawhashes, ",")), nil
+}
+
+// Scan computes the target's JARM fingerprint; see Fingerprint.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	fingerprint, err := Fingerprint(&target, &scanner.config.BaseFlags)
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
 	return zgrab2.SCAN_SUCCESS, &Results{
-		Fingerprint: jarm.RawHashToFuzzyHash(strings.Join(rawhashes, ",")),
+		Fingerprint: fingerprint,
 	}, nil
 }
@@ -0,0 +1,81 @@
+package jarm
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jarm "github.com/RumbleDiscovery/jarm-go"
+	"github.com/zmap/zgrab2"
+)
+
+// serveTLS completes a TLS handshake on the first handshakes connections,
+// and resets the rest.
+func serveTLS(listener net.Listener, config *tls.Config, handshakes int) {
+	for i := 0; ; i++ {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		if i >= handshakes {
+			conn.(*net.TCPConn).SetLinger(0)
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+			tls.Server(conn, config).Handshake()
+		}()
+	}
+}
+
+func TestFingerprintPartialReset(t *testing.T) {
+	// Borrow the test certificate of an httptest TLS server.
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	config := server.TLS.Clone()
+	server.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveTLS(listener, config, 5)
+
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	target := zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port}
+	flags := &zgrab2.BaseFlags{Timeout: 5 * time.Second}
+	fingerprint, err := Fingerprint(&target, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fingerprint) != 62 || fingerprint == jarm.ZeroHash {
+		t.Fatalf("unexpected fingerprint %q", fingerprint)
+	}
+	// Each probe contributes three characters (cipher and version) to the
+	// first 30; the reset probes must contribute zeroes.
+	if fingerprint[15:30] != strings.Repeat("0", 15) {
+		t.Errorf("expected zeroed components for the reset probes, got %q", fingerprint)
+	}
+	if fingerprint[:15] == strings.Repeat("0", 15) {
+		t.Errorf("expected components for the answered probes, got %q", fingerprint)
+	}
+}
+
+func TestFingerprintClosedPort(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	listener.Close()
+	target := zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port}
+	if _, err := Fingerprint(&target, &zgrab2.BaseFlags{Timeout: time.Second}); err == nil {
+		t.Error("expected an error for a closed port")
+	}
+}
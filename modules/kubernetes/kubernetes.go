@@ -0,0 +1,98 @@
+package kubernetes
+
+import (
+	"encoding/json"
+
+	"github.com/zmap/zgrab2/lib/http"
+)
+
+// VersionInfo holds the build information from the API server's /version
+// endpoint.
+type VersionInfo struct {
+	Major        string `json:"major,omitempty"`
+	Minor        string `json:"minor,omitempty"`
+	GitVersion   string `json:"git_version,omitempty"`
+	GitCommit    string `json:"git_commit,omitempty"`
+	GitTreeState string `json:"git_tree_state,omitempty"`
+	BuildDate    string `json:"build_date,omitempty"`
+	GoVersion    string `json:"go_version,omitempty"`
+	Compiler     string `json:"compiler,omitempty"`
+	Platform     string `json:"platform,omitempty"`
+}
+
+// rawVersion is used to decode the /version response, which uses the API's
+// field names.
+type rawVersion struct {
+	Major        string `json:"major"`
+	Minor        string `json:"minor"`
+	GitVersion   string `json:"gitVersion"`
+	GitCommit    string `json:"gitCommit"`
+	GitTreeState string `json:"gitTreeState"`
+	BuildDate    string `json:"buildDate"`
+	GoVersion    string `json:"goVersion"`
+	Compiler     string `json:"compiler"`
+	Platform     string `json:"platform"`
+}
+
+// EndpointResult records whether an endpoint answered without credentials.
+type EndpointResult struct {
+	// StatusCode is the HTTP status of the response.
+	StatusCode int `json:"status_code"`
+
+	// Kind is the kind of the returned object, e.g. "APIVersions",
+	// "PodList" or "Status".
+	Kind string `json:"kind,omitempty"`
+
+	// Anonymous is true if the endpoint returned its content without
+	// credentials.
+	Anonymous bool `json:"anonymous"`
+
+	// ItemCount is the number of items in the returned list, if any.
+	ItemCount int `json:"item_count,omitempty"`
+
+	// Versions lists the API versions returned by /api.
+	Versions []string `json:"versions,omitempty"`
+}
+
+// object is used to decode the fields common to the API objects of
+// interest.
+type object struct {
+	Kind     string            `json:"kind"`
+	Items    []json.RawMessage `json:"items"`
+	Versions []string          `json:"versions"`
+}
+
+// parseVersion parses the body of a /version response. It returns nil if the
+// body is not a Kubernetes version object.
+func parseVersion(body string) *VersionInfo {
+	var raw rawVersion
+	if err := json.Unmarshal([]byte(body), &raw); err != nil || raw.GitVersion == "" {
+		return nil
+	}
+	version := VersionInfo(raw)
+	return &version
+}
+
+// parseEndpoint returns the EndpointResult for a response, which counts as
+// anonymous access if it returned a 200 with an object of the expected kind.
+func parseEndpoint(resp *http.Response, expectedKind string) *EndpointResult {
+	result := &EndpointResult{StatusCode: resp.StatusCode}
+	var obj object
+	if err := json.Unmarshal([]byte(resp.BodyText), &obj); err != nil {
+		return result
+	}
+	result.Kind = obj.Kind
+	if resp.StatusCode == http.StatusOK && obj.Kind == expectedKind {
+		result.Anonymous = true
+		result.ItemCount = len(obj.Items)
+		result.Versions = obj.Versions
+	}
+	return result
+}
+
+// isKubernetes returns true if the endpoint's response looks like it came
+// from an API server or kubelet: an object of the expected kind, or a
+// Kubernetes Status object (e.g. a 403 for system:anonymous).
+func (result *EndpointResult) isKubernetes() bool {
+	return result.Anonymous || result.Kind == "Status"
+}
@@ -0,0 +1,93 @@
+package kubernetes
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/httpapi/httpapitest"
+)
+
+const testVersion = `{"major":"1","minor":"21","gitVersion":"v1.21.2","gitCommit":"092fbfbf53427de67cac1e9fa54aaa09a28371d7","gitTreeState":"clean","buildDate":"2021-06-16T12:53:14Z","goVersion":"go1.16.5","compiler":"gc","platform":"linux/amd64"}`
+
+const testForbidden = `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure","message":"forbidden: User \"system:anonymous\" cannot get path \"/api\"","reason":"Forbidden","details":{},"code":403}`
+
+// scanTestServer runs the scanner against a local plaintext server using
+// handler, probing kubelet endpoints if kubelet is set.
+func scanTestServer(t *testing.T, kubelet bool, handler http.HandlerFunc) (status zgrab2.ScanStatus, results *ScanResults, err error) {
+	scanner := &Scanner{config: &Flags{Plaintext: true, Kubelet: kubelet, MaxSize: 256, UserAgent: "zgrab2"}}
+	scanner.config.Timeout = 5 * time.Second
+	httpapitest.ServeTarget(t, handler, func(target *zgrab2.ScanTarget) {
+		var result interface{}
+		status, result, err = scanner.Scan(context.Background(), *target)
+		results, _ = result.(*ScanResults)
+	})
+	return status, results, err
+}
+
+func TestScanAPIServer(t *testing.T) {
+	status, result, err := scanTestServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/version":
+			w.Write([]byte(testVersion))
+		case "/healthz":
+			w.Write([]byte("ok"))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(testForbidden))
+		}
+	})
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	if result.Version == nil || result.Version.GitVersion != "v1.21.2" || result.Version.Platform != "linux/amd64" {
+		t.Errorf("unexpected version: %+v", result.Version)
+	}
+	if result.Healthz != "ok" || result.AnonymousAccess || result.API.Kind != "Status" || result.API.StatusCode != 403 {
+		t.Errorf("unexpected result: %+v, %+v", result, result.API)
+	}
+}
+
+func TestScanAnonymousAPI(t *testing.T) {
+	_, result, _ := scanTestServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api":
+			w.Write([]byte(`{"kind":"APIVersions","versions":["v1"],"serverAddressByClientCIDRs":[{"clientCIDR":"0.0.0.0/0","serverAddress":"10.0.0.1:6443"}]}`))
+		default:
+			w.Write([]byte(testVersion))
+		}
+	})
+	if !result.AnonymousAccess || !reflect.DeepEqual(result.API.Versions, []string{"v1"}) {
+		t.Errorf("expected anonymous /api access, got %+v", result.API)
+	}
+}
+
+func TestScanKubelet(t *testing.T) {
+	status, result, err := scanTestServer(t, true, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pods":
+			w.Write([]byte(`{"kind":"PodList","apiVersion":"v1","metadata":{},"items":[{"metadata":{"name":"a"}},{"metadata":{"name":"b"}}]}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Unauthorized"))
+		}
+	})
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	if !result.AnonymousAccess || !result.Pods.Anonymous || result.Pods.ItemCount != 2 || result.RunningPods.Anonymous {
+		t.Errorf("unexpected kubelet result: %+v, %+v", result.Pods, result.RunningPods)
+	}
+}
+
+func TestScanNotKubernetes(t *testing.T) {
+	status, _, err := scanTestServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>hello</html>"))
+	})
+	if status != zgrab2.SCAN_PROTOCOL_ERROR || err != ErrNotKubernetes {
+		t.Errorf("expected protocol error, got %s: %v", status, err)
+	}
+}
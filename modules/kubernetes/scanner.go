@@ -0,0 +1,257 @@
+// Package kubernetes provides a zgrab2 module that fingerprints Kubernetes
+// API servers and kubelets.
+// Default Port: 6443 (TCP)
+//
+// The scanner connects over TLS, using the standard TLS flags; the API
+// server's certificate usually reveals the cluster's DNS names in its SANs.
+// It sends GET /version and GET /healthz, recording the Kubernetes version
+// and build information, and GET /api, recording whether the API can be
+// reached anonymously (a misconfiguration).
+//
+// The --kubelet flag (implied when scanning port 10250, the kubelet's port)
+// tells the scanner to also send GET /pods and GET /runningpods, recording
+// whether the kubelet lists its pods without credentials.
+//
+// The --plaintext flag tells the scanner to connect without TLS, e.g. to
+// the legacy insecure port 8080.
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/http"
+	"github.com/zmap/zgrab2/lib/httpapi"
+)
+
+// kubeletPort is the kubelet's default port.
+const kubeletPort = 10250
+
+// ErrNotKubernetes is returned when none of the responses look like they
+// came from a Kubernetes API server or kubelet.
+var ErrNotKubernetes = errors.New("server is not a Kubernetes API server or kubelet")
+
+// ScanResults instances are returned by the module's Scan function.
+type ScanResults struct {
+	// VersionResponse is the HTTP response to GET /version.
+	VersionResponse *http.Response `json:"version_response,omitempty" zgrab:"debug"`
+
+	// Version holds the parsed /version response.
+	Version *VersionInfo `json:"version,omitempty"`
+
+	// HealthzStatus is the HTTP status of the response to GET /healthz.
+	HealthzStatus int `json:"healthz_status,omitempty"`
+
+	// Healthz is the body of the response to GET /healthz, e.g. "ok".
+	Healthz string `json:"healthz,omitempty"`
+
+	// API records whether GET /api answered without credentials.
+	API *EndpointResult `json:"api,omitempty"`
+
+	// Pods records whether the kubelet's GET /pods answered without
+	// credentials, if --kubelet is set.
+	Pods *EndpointResult `json:"pods,omitempty"`
+
+	// RunningPods records whether the kubelet's GET /runningpods answered
+	// without credentials, if --kubelet is set.
+	RunningPods *EndpointResult `json:"running_pods,omitempty"`
+
+	// AnonymousAccess is true if any of /api, /pods or /runningpods
+	// answered without credentials.
+	AnonymousAccess bool `json:"anonymous_access"`
+
+	// TLSLog is the standard TLS log, unless --plaintext is set.
+	TLSLog *zgrab2.TLSLog `json:"tls,omitempty"`
+}
+
+// Flags holds the command-line configuration for the kubernetes scan module.
+// Populated by the framework.
+type Flags struct {
+	zgrab2.BaseFlags
+	zgrab2.TLSFlags
+
+	// Plaintext indicates that the client should connect without TLS.
+	Plaintext bool `long:"plaintext" description:"Connect without TLS (e.g. to the legacy insecure port 8080)"`
+
+	// Kubelet indicates that the kubelet endpoints should be probed.
+	Kubelet bool `long:"kubelet" description:"Also probe the kubelet's /pods and /runningpods (implied on port 10250)"`
+
+	// MaxSize bounds the size of each response body that is read.
+	MaxSize int `long:"max-size" default:"256" description:"Max kilobytes to read in response to each request"`
+
+	// UserAgent is sent in the User-Agent header of each request.
+	UserAgent string `long:"user-agent" default:"Mozilla/5.0 zgrab/0.x" description:"Set a custom user agent"`
+
+	// Verbose indicates that there should be more verbose logging.
+	Verbose bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
+}
+
+// Module implements the zgrab2.Module interface.
+type Module struct {
+}
+
+// Scanner implements the zgrab2.Scanner interface.
+type Scanner struct {
+	config *Flags
+}
+
+// scan holds the state of a single scan.
+type scan struct {
+	scanner *Scanner
+	client  *httpapi.Client
+	results ScanResults
+}
+
+// RegisterModule registers the zgrab2 module.
+func RegisterModule() {
+	var module Module
+	_, err := zgrab2.AddCommand("kubernetes", "kubernetes", module.Description(), 6443, &module)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewFlags returns a default Flags object.
+func (module *Module) NewFlags() interface{} {
+	return new(Flags)
+}
+
+// NewScanner returns a new Scanner instance.
+func (module *Module) NewScanner() zgrab2.Scanner {
+	return new(Scanner)
+}
+
+// Description returns an overview of this module.
+func (module *Module) Description() string {
+	return "Fingerprint Kubernetes API servers and kubelets, and check for anonymous access"
+}
+
+// Validate checks that the flags are valid.
+// On success, returns nil.
+// On failure, returns an error instance describing the error.
+func (flags *Flags) Validate(args []string) error {
+	return nil
+}
+
+// Help returns the module's help string.
+func (flags *Flags) Help() string {
+	return ""
+}
+
+// Init initializes the Scanner.
+func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
+	f, _ := flags.(*Flags)
+	scanner.config = f
+	return nil
+}
+
+// InitPerSender initializes the scanner for a given sender.
+func (scanner *Scanner) InitPerSender(senderID int) error {
+	return nil
+}
+
+// GetName returns the Scanner name defined in the Flags.
+func (scanner *Scanner) GetName() string {
+	return scanner.config.Name
+}
+
+// GetTrigger returns the Trigger defined in the Flags.
+func (scanner *Scanner) GetTrigger() string {
+	return scanner.config.Trigger
+}
+
+// Protocol returns the protocol identifier of the scan.
+func (scanner *Scanner) Protocol() string {
+	return "kubernetes"
+}
+
+// newKubernetesScan returns a scan of the target on the given port.
+func (scanner *Scanner) newKubernetesScan(target *zgrab2.ScanTarget, port uint) *scan {
+	ret := &scan{scanner: scanner}
+	ret.client = httpapi.NewClient(target, port, &httpapi.Config{
+		BaseFlags: &scanner.config.BaseFlags,
+		TLSFlags:  &scanner.config.TLSFlags,
+		UseTLS:    !scanner.config.Plaintext,
+		UserAgent: scanner.config.UserAgent,
+		MaxSize:   scanner.config.MaxSize,
+		TLSLog:    &ret.results.TLSLog,
+	})
+	return ret
+}
+
+// Cleanup closes any connections that have been opened during the scan.
+func (scan *scan) Cleanup() {
+	scan.client.Close()
+}
+
+// Scan performs the Kubernetes scan.
+//  1. Connect over TLS (unless --plaintext is set), recording the
+//     certificate.
+//  2. Send GET /version, GET /healthz and GET /api.
+//  3. If --kubelet is set or the port is 10250, send GET /pods and GET
+//     /runningpods.
+//  4. If none of the responses look like they came from Kubernetes, fail
+//     with a protocol error.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	port := scanner.config.Port
+	if target.Port != nil {
+		port = *target.Port
+	}
+	scan := scanner.newKubernetesScan(&target, port)
+	defer scan.Cleanup()
+	result := &scan.results
+	isKubernetes := false
+
+	resp, err := scan.client.Get("/version")
+	if err != nil {
+		if result.TLSLog != nil {
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	result.VersionResponse = resp
+	if resp.StatusCode == http.StatusOK {
+		result.Version = parseVersion(resp.BodyText)
+	}
+	isKubernetes = result.Version != nil || parseEndpoint(resp, "").isKubernetes()
+
+	resp, err = scan.client.Get("/healthz")
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+	result.HealthzStatus = resp.StatusCode
+	result.Healthz = strings.TrimSpace(resp.BodyText)
+
+	resp, err = scan.client.Get("/api")
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+	result.API = parseEndpoint(resp, "APIVersions")
+	isKubernetes = isKubernetes || result.API.isKubernetes()
+	result.AnonymousAccess = result.API.Anonymous
+
+	if scanner.config.Kubelet || port == kubeletPort {
+		for _, endpoint := range []struct {
+			path   string
+			result **EndpointResult
+		}{{"/pods", &result.Pods}, {"/runningpods/", &result.RunningPods}} {
+			resp, err = scan.client.Get(endpoint.path)
+			if err != nil {
+				return zgrab2.TryGetScanStatus(err), result, err
+			}
+			*endpoint.result = parseEndpoint(resp, "PodList")
+			// The kubelet answers unauthenticated requests with a bare
+			// "Unauthorized" rather than a Status object.
+			isKubernetes = isKubernetes || (*endpoint.result).isKubernetes() ||
+				(resp.StatusCode == http.StatusUnauthorized && strings.TrimSpace(resp.BodyText) == "Unauthorized")
+			result.AnonymousAccess = result.AnonymousAccess || (*endpoint.result).Anonymous
+		}
+	}
+	if !isKubernetes {
+		return zgrab2.SCAN_PROTOCOL_ERROR, result, ErrNotKubernetes
+	}
+	return zgrab2.SCAN_SUCCESS, result, nil
+}
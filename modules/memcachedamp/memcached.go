@@ -0,0 +1,153 @@
+// Memcached UDP protocol client for the memcached-amp module.
+
+package memcachedamp
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+)
+
+const (
+	// frameHeaderSize is the size of the frame header that starts each UDP
+	// datagram: the request ID, the sequence number, the total number of
+	// datagrams in the message and a reserved field, each 16 bits.
+	frameHeaderSize = 8
+
+	// statsCommand is the request sent: a single stats command.
+	statsCommand = "stats\r\n"
+
+	// maxDatagramSize bounds the size of a response datagram.
+	maxDatagramSize = 65535
+)
+
+// ErrInvalidResponse is returned when the first datagram received is not a
+// response to the request.
+var ErrInvalidResponse = errors.New("invalid memcached UDP response")
+
+// frameHeader is the frame header of a UDP datagram.
+type frameHeader struct {
+	requestID uint16
+	sequence  uint16
+	total     uint16
+}
+
+// encodeRequest returns the datagram of a single-datagram request.
+func encodeRequest(requestID uint16, command string) []byte {
+	ret := make([]byte, frameHeaderSize, frameHeaderSize+len(command))
+	binary.BigEndian.PutUint16(ret[0:2], requestID)
+	binary.BigEndian.PutUint16(ret[4:6], 1)
+	return append(ret, command...)
+}
+
+// parseFrame splits a datagram into its frame header and payload.
+func parseFrame(datagram []byte) (*frameHeader, []byte, error) {
+	if len(datagram) < frameHeaderSize {
+		return nil, nil, ErrInvalidResponse
+	}
+	header := &frameHeader{
+		requestID: binary.BigEndian.Uint16(datagram[0:2]),
+		sequence:  binary.BigEndian.Uint16(datagram[2:4]),
+		total:     binary.BigEndian.Uint16(datagram[4:6]),
+	}
+	if header.total == 0 || header.sequence >= header.total {
+		return nil, nil, ErrInvalidResponse
+	}
+	return header, datagram[frameHeaderSize:], nil
+}
+
+// Response is the outcome of a stats request.
+type Response struct {
+	// RequestSize is the size of the request datagram's UDP payload.
+	RequestSize int
+
+	// ResponseSize is the total size of the UDP payloads of the response
+	// datagrams received, including their frame headers.
+	ResponseSize int
+
+	// Datagrams is the number of response datagrams received.
+	Datagrams int
+
+	// Complete is true if every datagram of the response was received.
+	Complete bool
+
+	// Truncated is true if the reading stopped after maxSize bytes.
+	Truncated bool
+
+	// Payload is the response, reassembled in sequence order from the
+	// datagrams received.
+	Payload string
+}
+
+// Stats sends a stats request with the given request ID over a UDP
+// connection, and reads the response datagrams until all of them have been
+// received, at least maxSize bytes have been read, or a read fails (e.g.
+// times out with the connection's deadline). It only fails if no response
+// datagram was received; datagrams that are not part of the response are
+// ignored after the first one.
+func Stats(conn net.Conn, requestID uint16, maxSize int) (*Response, error) {
+	request := encodeRequest(requestID, statsCommand)
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+	ret := &Response{RequestSize: len(request)}
+	var total uint16
+	payloads := make(map[uint16][]byte)
+	buf := make([]byte, maxDatagramSize)
+	for total == 0 || len(payloads) < int(total) {
+		n, err := conn.Read(buf)
+		if err != nil {
+			if ret.Datagrams > 0 {
+				break
+			}
+			return nil, err
+		}
+		header, payload, err := parseFrame(buf[:n])
+		if err == nil && header.requestID != requestID {
+			err = ErrInvalidResponse
+		}
+		if err != nil {
+			if ret.Datagrams > 0 {
+				continue
+			}
+			return nil, err
+		}
+		if total == 0 {
+			total = header.total
+		}
+		ret.Datagrams++
+		ret.ResponseSize += n
+		if _, ok := payloads[header.sequence]; !ok {
+			payloads[header.sequence] = append([]byte(nil), payload...)
+		}
+		if ret.ResponseSize >= maxSize {
+			ret.Truncated = len(payloads) < int(total)
+			break
+		}
+	}
+	ret.Complete = len(payloads) == int(total)
+	var payload strings.Builder
+	for i := uint16(0); i < total; i++ {
+		payload.Write(payloads[i])
+	}
+	ret.Payload = payload.String()
+	return ret, nil
+}
+
+// parseStats returns the statistics listed in a stats response, one
+// "STAT <name> <value>" line each.
+func parseStats(payload string) map[string]string {
+	var ret map[string]string
+	for _, line := range strings.Split(payload, "\r\n") {
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 || fields[0] != "STAT" {
+			continue
+		}
+		if ret == nil {
+			ret = make(map[string]string)
+		}
+		ret[fields[1]] = fields[2]
+	}
+	return ret
+}
@@ -0,0 +1,127 @@
+package memcachedamp
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+// encodeResponse returns the response datagram with the given frame header
+// fields and payload.
+func encodeResponse(requestID, sequence, total uint16, payload string) []byte {
+	ret := make([]byte, frameHeaderSize)
+	binary.BigEndian.PutUint16(ret[0:2], requestID)
+	binary.BigEndian.PutUint16(ret[2:4], sequence)
+	binary.BigEndian.PutUint16(ret[4:6], total)
+	return append(ret, payload...)
+}
+
+// serve answers the first request received by server with the datagrams
+// returned by respond for its request ID.
+func serve(t *testing.T, server net.PacketConn, respond func(requestID uint16) [][]byte) {
+	buf := make([]byte, 64)
+	n, addr, err := server.ReadFrom(buf)
+	if err != nil || n != frameHeaderSize+len(statsCommand) || string(buf[frameHeaderSize:n]) != statsCommand {
+		t.Errorf("unexpected request %x: %v", buf[:n], err)
+		return
+	}
+	for _, datagram := range respond(binary.BigEndian.Uint16(buf[0:2])) {
+		server.WriteTo(datagram, addr)
+	}
+}
+
+// scan runs the scanner against a server answering with respond.
+func scan(t *testing.T, timeout time.Duration, maxSize int, respond func(requestID uint16) [][]byte) (zgrab2.ScanStatus, *ScanResults, error) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	go serve(t, server, respond)
+	port := uint(server.LocalAddr().(*net.UDPAddr).Port)
+	scanner := &Scanner{config: &Flags{MaxResponseSize: maxSize}}
+	scanner.config.Timeout = timeout
+	status, res, err := scanner.Scan(context.Background(), zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port})
+	result, _ := res.(*ScanResults)
+	return status, result, err
+}
+
+const (
+	statsPart1 = "STAT pid 1\r\nSTAT version 1.5.22\r\n"
+	statsPart2 = "STAT curr_connections 2\r\nEND\r\n"
+)
+
+func TestParseStats(t *testing.T) {
+	expected := map[string]string{"pid": "1", "version": "1.5.22", "curr_connections": "2"}
+	if stats := parseStats(statsPart1 + statsPart2); !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected %v, got %v", expected, stats)
+	}
+	if stats := parseStats("ERROR\r\n"); stats != nil {
+		t.Errorf("expected no stats, got %v", stats)
+	}
+}
+
+func TestScan(t *testing.T) {
+	// The datagrams are sent out of order.
+	status, result, err := scan(t, 5*time.Second, 65536, func(requestID uint16) [][]byte {
+		return [][]byte{
+			encodeResponse(requestID, 1, 2, statsPart2),
+			encodeResponse(requestID, 0, 2, statsPart1),
+		}
+	})
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	size := 2*frameHeaderSize + len(statsPart1) + len(statsPart2)
+	if result.RequestSize != 15 || result.ResponseSize != size || result.Datagrams != 2 || !result.Complete || result.Truncated {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if result.Amplification != float64(size)/15 || !result.Amplifier || result.Version != "1.5.22" {
+		t.Errorf("unexpected amplification or version in %+v", result)
+	}
+}
+
+func TestScanPartial(t *testing.T) {
+	// Only the first of three datagrams arrives.
+	status, result, err := scan(t, 200*time.Millisecond, 65536, func(requestID uint16) [][]byte {
+		return [][]byte{encodeResponse(requestID, 0, 3, statsPart1)}
+	})
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	if result.Datagrams != 1 || result.Complete || result.Truncated || result.Version != "1.5.22" {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	// The reading stops after --max-response-size bytes.
+	status, result, err = scan(t, 5*time.Second, 10, func(requestID uint16) [][]byte {
+		return [][]byte{encodeResponse(requestID, 0, 2, statsPart1), encodeResponse(requestID, 1, 2, statsPart2)}
+	})
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	if result.Datagrams != 1 || result.Complete || !result.Truncated {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestScanInvalid(t *testing.T) {
+	status, _, err := scan(t, 5*time.Second, 65536, func(requestID uint16) [][]byte {
+		return [][]byte{encodeResponse(requestID+1, 0, 1, "END\r\n")}
+	})
+	if status != zgrab2.SCAN_PROTOCOL_ERROR || err != ErrInvalidResponse {
+		t.Errorf("unexpected status %s: %v", status, err)
+	}
+
+	status, _, err = scan(t, 200*time.Millisecond, 65536, func(requestID uint16) [][]byte {
+		return nil
+	})
+	if status == zgrab2.SCAN_SUCCESS || err == nil {
+		t.Errorf("expected the scan to time out, got %s", status)
+	}
+}
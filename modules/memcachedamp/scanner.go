@@ -0,0 +1,175 @@
+// Package memcachedamp provides a zgrab2 module that checks whether a
+// memcached server answers over UDP, and so can be used for DDoS reflection
+// and amplification.
+// Default Port: 11211 (UDP)
+//
+// The scanner sends a single stats request (15 bytes of UDP payload), reads
+// the response datagrams, up to --max-response-size bytes, and records the
+// request and response sizes and their ratio, the amplification factor.
+package memcachedamp
+
+import (
+	"context"
+	"math/rand"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmap/zgrab2"
+)
+
+// ScanResults instances are returned by the module's Scan function.
+type ScanResults struct {
+	// RequestSize is the size of the request's UDP payload, in bytes.
+	RequestSize int `json:"request_size"`
+
+	// ResponseSize is the total size of the UDP payloads of the response
+	// datagrams received, in bytes.
+	ResponseSize int `json:"response_size"`
+
+	// Datagrams is the number of response datagrams received.
+	Datagrams int `json:"datagrams"`
+
+	// Amplification is ResponseSize divided by RequestSize.
+	Amplification float64 `json:"amplification"`
+
+	// Amplifier is true if the response is larger than the request, i.e.
+	// the server can be used to amplify reflected traffic.
+	Amplifier bool `json:"amplifier"`
+
+	// Complete is true if every datagram of the response was received.
+	Complete bool `json:"complete"`
+
+	// Truncated is true if the reading stopped after --max-response-size
+	// bytes, before the response was complete.
+	Truncated bool `json:"truncated,omitempty"`
+
+	// Version is the server's version, from the stats response.
+	Version string `json:"version,omitempty"`
+
+	// Stats are the statistics listed in the response.
+	Stats map[string]string `json:"stats,omitempty" zgrab:"debug"`
+}
+
+// Flags holds the command-line configuration for the memcached-amp scan
+// module. Populated by the framework.
+type Flags struct {
+	zgrab2.BaseFlags
+	zgrab2.UDPFlags
+
+	// MaxResponseSize bounds the number of response bytes read.
+	MaxResponseSize int `long:"max-response-size" default:"65536" description:"Stop reading the response after this many bytes of UDP payload"`
+
+	// Verbose indicates that there should be more verbose logging.
+	Verbose bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
+}
+
+// Module implements the zgrab2.Module interface.
+type Module struct {
+}
+
+// Scanner implements the zgrab2.Scanner interface.
+type Scanner struct {
+	config *Flags
+}
+
+// RegisterModule registers the zgrab2 module.
+func RegisterModule() {
+	var module Module
+	_, err := zgrab2.AddCommand("memcached-amp", "memcached-amp", module.Description(), 11211, &module)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewFlags returns a default Flags object.
+func (module *Module) NewFlags() interface{} {
+	return new(Flags)
+}
+
+// NewScanner returns a new Scanner instance.
+func (module *Module) NewScanner() zgrab2.Scanner {
+	return new(Scanner)
+}
+
+// Description returns an overview of this module.
+func (module *Module) Description() string {
+	return "Measure the amplification factor of a memcached server's UDP stats response"
+}
+
+// Validate checks that the flags are valid.
+// On success, returns nil.
+// On failure, returns an error instance describing the error.
+func (flags *Flags) Validate(args []string) error {
+	if flags.MaxResponseSize <= 0 {
+		log.Errorf("--max-response-size must be positive")
+		return zgrab2.ErrInvalidArguments
+	}
+	return nil
+}
+
+// Help returns the module's help string.
+func (flags *Flags) Help() string {
+	return ""
+}
+
+// Init initializes the Scanner.
+func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
+	f, _ := flags.(*Flags)
+	scanner.config = f
+	return nil
+}
+
+// InitPerSender initializes the scanner for a given sender.
+func (scanner *Scanner) InitPerSender(senderID int) error {
+	return nil
+}
+
+// GetName returns the Scanner name defined in the Flags.
+func (scanner *Scanner) GetName() string {
+	return scanner.config.Name
+}
+
+// GetTrigger returns the Trigger defined in the Flags.
+func (scanner *Scanner) GetTrigger() string {
+	return scanner.config.Trigger
+}
+
+// Protocol returns the protocol identifier of the scan.
+func (scanner *Scanner) Protocol() string {
+	return "memcached-amp"
+}
+
+// Scan performs the memcached-amp scan.
+//  1. Open a UDP connection to the target port (default 11211).
+//  2. Send a stats request with a random request ID; if the host does not
+//     answer within the timeout, fail with the timeout, and if the first
+//     datagram is not a response to the request, fail with a protocol error.
+//  3. Read the response datagrams until they have all been received, the
+//     timeout expires or --max-response-size bytes have been read, and
+//     record the sizes and the amplification factor.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	conn, err := target.OpenUDP(&scanner.config.BaseFlags, &scanner.config.UDPFlags)
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	defer conn.Close()
+
+	response, err := Stats(conn, uint16(rand.Intn(1<<16)), scanner.config.MaxResponseSize)
+	if err != nil {
+		if err == ErrInvalidResponse {
+			return zgrab2.SCAN_PROTOCOL_ERROR, nil, err
+		}
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	result := &ScanResults{
+		RequestSize:   response.RequestSize,
+		ResponseSize:  response.ResponseSize,
+		Datagrams:     response.Datagrams,
+		Amplification: float64(response.ResponseSize) / float64(response.RequestSize),
+		Amplifier:     response.ResponseSize > response.RequestSize,
+		Complete:      response.Complete,
+		Truncated:     response.Truncated,
+		Stats:         parseStats(response.Payload),
+	}
+	result.Version = result.Stats["version"]
+	return zgrab2.SCAN_SUCCESS, result, nil
+}
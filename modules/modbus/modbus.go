@@ -156,6 +156,28 @@ type ModbusEvent struct {
 
 	// Raw is the full raw response from the server, including the header.
 	Raw []byte `json:"raw,omitempty"`
+
+	// RequestUnitID is the unit ID that was sent in the request.
+	RequestUnitID int `json:"request_unit_id"`
+
+	// HoldingRegisters is the result of the Read Holding Registers probe; it is only present if
+	// --read-holding-registers was set and the server sent a response.
+	HoldingRegisters *HoldingRegistersResponse `json:"holding_registers,omitempty"`
+}
+
+// HoldingRegistersResponse is the parsed response to a Read Holding Registers (0x03) request.
+type HoldingRegistersResponse struct {
+	// StartAddress is the address of the first register that was requested.
+	StartAddress uint16 `json:"start_address"`
+
+	// Values are the register values returned by the server; it is omitted if there was an exception.
+	Values []uint16 `json:"values,omitempty"`
+
+	// ExceptionResponse is the parsed exception, if the server returned one.
+	ExceptionResponse *ExceptionResponse `json:"exception_response,omitempty"`
+
+	// Raw is the full raw response from the server, including the header.
+	Raw []byte `json:"raw,omitempty"`
 }
 
 // IsException returns true if this response indicates an exception has occurred.
@@ -249,6 +271,37 @@ func (m *ModbusResponse) getMEIResponse(strict bool) (*MEIResponse, error) {
 	}, nil
 }
 
+// getHoldingRegistersResponse parses the response to a Read Holding Registers request.
+func (m *ModbusResponse) getHoldingRegistersResponse(startAddress uint16, strict bool) (*HoldingRegistersResponse, error) {
+	ret := &HoldingRegistersResponse{
+		StartAddress: startAddress,
+		Raw:          m.Raw,
+	}
+	if m.Function&0x7F != FunctionCodeReadHoldingRegisters {
+		return nil, fmt.Errorf("Invalid function code 0x%02x", m.Function)
+	}
+	if m.IsException() {
+		ex, err := m.getExceptionResponse(strict)
+		if err != nil {
+			return nil, err
+		}
+		ret.ExceptionResponse = ex
+		return ret, nil
+	}
+	if len(m.Data) < 1 {
+		return nil, fmt.Errorf("Response too short (%d bytes)", len(m.Data))
+	}
+	byteCount := int(m.Data[0])
+	if byteCount%2 != 0 || len(m.Data) < 1+byteCount {
+		return nil, fmt.Errorf("Invalid register byte count %d (%d bytes available)", byteCount, len(m.Data)-1)
+	}
+	ret.Values = make([]uint16, byteCount/2)
+	for i := range ret.Values {
+		ret.Values[i] = binary.BigEndian.Uint16(m.Data[1+2*i:])
+	}
+	return ret, nil
+}
+
 func parseMEIObject(objectBytes []byte) (int, *MEIObject) {
 	length := len(objectBytes)
 	if length < 2 {
@@ -410,4 +463,7 @@ var ModbusFunctionEncapsulatedInterface = FunctionCode(0x2B)
 const (
 	// FunctionCodeMEI identifies the MEI read function.
 	FunctionCodeMEI = FunctionCode(0x2B)
+
+	// FunctionCodeReadHoldingRegisters identifies the Read Holding Registers function.
+	FunctionCodeReadHoldingRegisters = FunctionCode(0x03)
 )
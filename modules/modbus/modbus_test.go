@@ -0,0 +1,43 @@
+package modbus
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetHoldingRegistersResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		function FunctionCode
+		data     []byte
+		strict   bool
+		values   []uint16
+		ex       *ExceptionResponse
+		valid    bool
+	}{
+		{"two registers", FunctionCodeReadHoldingRegisters, []byte{4, 0x12, 0x34, 0x00, 0x01}, false, []uint16{0x1234, 1}, nil, true},
+		{"trailing data", FunctionCodeReadHoldingRegisters, []byte{2, 0x00, 0x07, 0xff}, false, []uint16{7}, nil, true},
+		{"no registers", FunctionCodeReadHoldingRegisters, []byte{0}, false, []uint16{}, nil, true},
+		{"exception", FunctionCodeReadHoldingRegisters | 0x80, []byte{0x02}, false, nil, &ExceptionResponse{ExceptionFunction: FunctionCodeReadHoldingRegisters, ExceptionType: 0x02}, true},
+		{"empty exception", FunctionCodeReadHoldingRegisters | 0x80, nil, false, nil, &ExceptionResponse{ExceptionFunction: FunctionCodeReadHoldingRegisters}, true},
+		{"empty exception, strict", FunctionCodeReadHoldingRegisters | 0x80, nil, true, nil, nil, false},
+		{"empty", FunctionCodeReadHoldingRegisters, nil, false, nil, nil, false},
+		{"odd byte count", FunctionCodeReadHoldingRegisters, []byte{3, 0x00, 0x01, 0x02}, false, nil, nil, false},
+		{"truncated", FunctionCodeReadHoldingRegisters, []byte{4, 0x12, 0x34, 0x00}, false, nil, nil, false},
+		{"wrong function", FunctionCodeMEI, []byte{2, 0x00, 0x01}, false, nil, nil, false},
+	}
+	for _, test := range tests {
+		response := &ModbusResponse{Function: test.function, Data: test.data}
+		ret, err := response.getHoldingRegistersResponse(100, test.strict)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: expected valid=%v, got %v", test.name, test.valid, err)
+			continue
+		}
+		if !test.valid {
+			continue
+		}
+		if ret.StartAddress != 100 || !reflect.DeepEqual(ret.Values, test.values) || !reflect.DeepEqual(ret.ExceptionResponse, test.ex) {
+			t.Errorf("%s: unexpected response %+v", test.name, ret)
+		}
+	}
+}
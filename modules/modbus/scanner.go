@@ -14,12 +14,23 @@
 // The --strict flag allows turning on new validity checks beyond those
 // done in the original zgrab, to help rule out false matches.
 //
+// The --read-holding-registers flag causes the scanner to follow up the
+// device identification with a Read Holding Registers (0x03) request for
+// --register-count registers starting at --register-address, to confirm that
+// the device is a live PLC.
+//
 // The output is the same as the original ZGrab: a "modbus event" object,
 // with either the parsed MEI response or the parsed exception info.
-// The only addition is a "raw" field containing the raw response data.
+// The additions are a "raw" field containing the raw response data, the
+// "request_unit_id" that was sent, and the optional "holding_registers".
+//
+// Since only a Modbus server would send a well-formed exception response
+// (e.g. ILLEGAL FUNCTION), exceptions are treated as a successful detection.
 package modbus
 
 import (
+	"context"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"net"
@@ -37,6 +48,10 @@ type Flags struct {
 	ObjectID  uint8  `long:"object-id" description:"The ObjectID of the object to be read." default:"0x00"`
 	Strict    bool   `long:"strict" description:"If set, perform stricter checks on the response data to get fewer false positives"`
 	RequestID uint16 `long:"request-id" description:"Override the default request ID." default:"0x5A47"`
+
+	ReadHoldingRegisters bool   `long:"read-holding-registers" description:"If set, send a Read Holding Registers request after the device identification request"`
+	RegisterAddress      uint16 `long:"register-address" description:"The address of the first holding register to read" default:"0"`
+	RegisterCount        uint16 `long:"register-count" description:"The number of holding registers to read (1-125)" default:"1"`
 	Verbose   bool   `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
 }
 
@@ -77,6 +92,9 @@ func (module *Module) Description() string {
 // On success, returns nil.
 // On failure, returns an error instance describing the error.
 func (flags *Flags) Validate(args []string) error {
+	if flags.ReadHoldingRegisters && (flags.RegisterCount < 1 || flags.RegisterCount > 125) {
+		return fmt.Errorf("register-count must be between 1 and 125 (got %d)", flags.RegisterCount)
+	}
 	if flags.Verbose {
 		// If --verbose is set, do some extra checking but don't fail.
 		if flags.ObjectID >= 0x07 && flags.ObjectID < 0x80 {
@@ -129,6 +147,44 @@ func (c *Conn) getUnderlyingConn() net.Conn {
 	return c.Conn
 }
 
+// sendRequest marshals the request and writes it to the server.
+func (c *Conn) sendRequest(req *ModbusRequest) error {
+	data, err := c.MarshalRequest(req)
+	if err != nil {
+		log.Fatalf("Unexpected error marshaling modbus packet: %v", err)
+	}
+	w := 0
+	for w < len(data) {
+		written, err := c.getUnderlyingConn().Write(data[w:])
+		w += written
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readHoldingRegisters sends a Read Holding Registers request for the configured registers, and parses the
+// response.
+func (c *Conn) readHoldingRegisters() (*HoldingRegistersResponse, error) {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint16(data[0:2], c.scanner.config.RegisterAddress)
+	binary.BigEndian.PutUint16(data[2:4], c.scanner.config.RegisterCount)
+	req := ModbusRequest{
+		UnitID:   int(c.scanner.config.UnitID),
+		Function: FunctionCodeReadHoldingRegisters,
+		Data:     data,
+	}
+	if err := c.sendRequest(&req); err != nil {
+		return nil, err
+	}
+	res, err := c.GetModbusResponse()
+	if res == nil {
+		return nil, err
+	}
+	return res.getHoldingRegistersResponse(c.scanner.config.RegisterAddress, c.scanner.config.Strict)
+}
+
 // Scan probes for a modbus service.
 // It connects to the configured TCP port (default 502) and sends a packet with:
 //	 UnitID = <flags.UnitID, default 0>
@@ -137,8 +193,9 @@ func (c *Conn) getUnderlyingConn() net.Conn {
 //   Category = 0x01: Basic
 //	 ObjectID = <flags.ObjectID, default 0: VendorName>
 // If the response is not a valid modbus response to this packet, then fail with a SCAN_PROTOCOL_ERROR.
-// Otherwise, return the parsed response and status (SCAN_SUCCESS or SCAN_APPLICATION_ERROR)
-func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+// Otherwise, if --read-holding-registers is set, send a Read Holding Registers request and include the parsed
+// response, then return the parsed response with SCAN_SUCCESS (even if the server returned an exception).
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
 	conn, err := target.Open(&scanner.config.BaseFlags)
 	if err != nil {
 		return zgrab2.TryGetScanStatus(err), nil, err
@@ -156,17 +213,8 @@ func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, inter
 		},
 	}
 
-	data, err := c.MarshalRequest(&req)
-	if err != nil {
-		log.Fatalf("Unexpected error marshaling modbus packet: %v", err)
-	}
-	w := 0
-	for w < len(data) {
-		written, err := c.getUnderlyingConn().Write(data[w:])
-		w += written
-		if err != nil {
-			return zgrab2.TryGetScanStatus(err), nil, err
-		}
+	if err := c.sendRequest(&req); err != nil {
+		return zgrab2.TryGetScanStatus(err), nil, err
 	}
 
 	res, err := c.GetModbusResponse()
@@ -202,10 +250,18 @@ func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, inter
 		return zgrab2.SCAN_PROTOCOL_ERROR, nil, err
 	}
 
-	status := zgrab2.SCAN_SUCCESS
-	if res.IsException() {
-		// Note the exception, but note that the modbus protocol was detected
-		status = zgrab2.SCAN_APPLICATION_ERROR
+	ret.RequestUnitID = int(scanner.config.UnitID)
+
+	if scanner.config.ReadHoldingRegisters {
+		// Modbus has already been detected at this point, so failures here are not fatal.
+		registers, err := c.readHoldingRegisters()
+		if err != nil {
+			log.Debugf("Error reading holding registers: %v", err)
+		}
+		ret.HoldingRegisters = registers
 	}
-	return status, ret, nil
+
+	// An exception response (e.g. ILLEGAL FUNCTION) still positively identifies a modbus server; the exception details
+	// are included in the result.
+	return zgrab2.SCAN_SUCCESS, ret, nil
 }
@@ -1,6 +1,7 @@
 package mongodb
 
 import (
+	"context"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
@@ -17,6 +18,7 @@ type Module struct {
 // Flags contains mongodb-specific command-line flags.
 type Flags struct {
 	zgrab2.BaseFlags
+	MaxDatabases uint `long:"max-databases" default:"100" description:"Record at most this many of the databases listed by the unauthenticated listDatabases command"`
 }
 
 // Scanner implements the zgrab2.Scanner interface
@@ -25,6 +27,8 @@ type Scanner struct {
 	isMasterMsg         []byte
 	buildInfoCommandMsg []byte
 	buildInfoOpMsg      []byte
+	listDatabasesQuery  []byte
+	listDatabasesOpMsg  []byte
 }
 
 // scan holds the state for the scan of an individual target
@@ -117,6 +121,33 @@ func getBuildInfoOpMsg() []byte {
 	return op_msg
 }
 
+// getListDatabasesQuery returns a mongodb message containing the listDatabases command.
+// https://docs.mongodb.com/manual/reference/command/listDatabases/
+func getListDatabasesQuery() []byte {
+	query, err := bson.Marshal(bson.M{"listDatabases": 1})
+	if err != nil {
+		// programmer error
+		log.Fatalf("Invalid BSON: %v", err)
+	}
+	return getOpQuery("admin.$cmd", query)
+}
+
+// getListDatabasesOpMsg returns a mongodb "OP" message containing the listDatabases command.
+func getListDatabasesOpMsg() []byte {
+	// The command name must come first, so the document is ordered.
+	section_payload, err := bson.Marshal(bson.D{
+		bson.DocElem{Name: "listDatabases", Value: 1},
+		bson.DocElem{Name: "$db", Value: "admin"},
+	})
+	if err != nil {
+		// programmer error
+		log.Fatalf("Invalid BSON: %v", err)
+	}
+	section := make([]byte, len(section_payload)+1)
+	copy(section[1:], section_payload)
+	return getOpMsg(section)
+}
+
 // BuildEnvironment_t holds build environment information returned by scan.
 type BuildEnvironment_t struct {
 	Distmod    string `bson:"distmod,omitempty" json:"dist_mod,omitempty"`
@@ -149,10 +180,58 @@ type IsMaster_t struct {
 	ReadOnly                     bool  `bson:"readOnly" json:"read_only"`
 }
 
+// Database_t holds a database listed by the listDatabases command
+type Database_t struct {
+	Name       string `bson:"name" json:"name"`
+	SizeOnDisk int64  `bson:"sizeOnDisk" json:"size_on_disk"`
+	Empty      bool   `bson:"empty" json:"empty"`
+}
+
+// listDatabasesReply holds the reply to the listDatabases command
+type listDatabasesReply struct {
+	OK        float64      `bson:"ok"`
+	ErrMsg    string       `bson:"errmsg"`
+	Code      int32        `bson:"code"`
+	CodeName  string       `bson:"codeName"`
+	Databases []Database_t `bson:"databases"`
+	TotalSize int64        `bson:"totalSize"`
+}
+
+// ListDatabases_t holds the outcome of the listDatabases command, sent
+// without credentials
+type ListDatabases_t struct {
+	// Databases holds the first --max-databases databases listed.
+	Databases []Database_t `json:"databases,omitempty"`
+
+	// TotalDatabases is the number of databases listed, including any left
+	// out of Databases.
+	TotalDatabases int `json:"total_databases"`
+
+	// DatabasesTruncated is true if some databases were left out.
+	DatabasesTruncated bool `json:"databases_truncated,omitempty"`
+
+	// TotalSize is the total size on disk of the databases, in bytes.
+	TotalSize int64 `json:"total_size,omitempty"`
+
+	// Error, ErrorCode and ErrorCodeName describe why the command was
+	// rejected, if it was; Error is also set if the reply could not be read.
+	Error         string `json:"error,omitempty"`
+	ErrorCode     int32  `json:"error_code,omitempty"`
+	ErrorCodeName string `json:"error_code_name,omitempty"`
+}
+
 // Result holds the data returned by a scan
 type Result struct {
 	IsMaster  *IsMaster_t  `json:"is_master,omitempty"`
 	BuildInfo *BuildInfo_t `json:"build_info,omitempty"`
+
+	// AuthRequired is true if the server rejected listDatabases without
+	// credentials as unauthorized, and false if it listed its databases
+	// (i.e. it is readable by anyone). It is omitted if the command failed
+	// for another reason.
+	AuthRequired *bool `json:"auth_required,omitempty"`
+
+	ListDatabases *ListDatabases_t `json:"list_databases,omitempty"`
 }
 
 // Init initializes the scanner
@@ -162,6 +241,8 @@ func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
 	scanner.isMasterMsg = getIsMasterMsg()
 	scanner.buildInfoCommandMsg = getBuildInfoQuery()
 	scanner.buildInfoOpMsg = getBuildInfoOpMsg()
+	scanner.listDatabasesQuery = getListDatabasesQuery()
+	scanner.listDatabasesOpMsg = getListDatabasesOpMsg()
 	return nil
 }
 
@@ -264,8 +345,101 @@ func getIsMaster(conn *Connection) (*IsMaster_t, error) {
 	return document, nil
 }
 
-// Scan connects to a host and performs a scan.
-func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+// getReplyDoc returns the BSON document in msg, the reply to a command sent
+// in an OP_QUERY (i.e. an OP_REPLY) or an OP_MSG.
+func getReplyDoc(msg []byte) ([]byte, error) {
+	if len(msg) < MSGHEADER_LEN {
+		return nil, fmt.Errorf("Server truncated message - no header (%d bytes: %s)", len(msg), hex.EncodeToString(msg))
+	}
+	var doc_offset int
+	switch opcode := binary.LittleEndian.Uint32(msg[12:16]); opcode {
+	case OP_REPLY:
+		// responseFlags, cursorID, startingFrom, numberReturned
+		doc_offset = MSGHEADER_LEN + 20
+	case OP_MSG:
+		// flagBits, then the kind byte of the body section
+		doc_offset = MSGHEADER_LEN + 5
+		if len(msg) >= doc_offset && msg[doc_offset-1] != 0 {
+			return nil, fmt.Errorf("Server sent unexpected OP_MSG section kind %d", msg[doc_offset-1])
+		}
+	default:
+		return nil, fmt.Errorf("Server sent unexpected opcode %d", opcode)
+	}
+	if len(msg) < doc_offset+4 {
+		return nil, fmt.Errorf("Server truncated message - no reply doc (%d bytes: %s)", len(msg), hex.EncodeToString(msg))
+	}
+	doclen := int(binary.LittleEndian.Uint32(msg[doc_offset : doc_offset+4]))
+	if doclen < 5 || len(msg[doc_offset:]) < doclen {
+		return nil, fmt.Errorf("Server truncated BSON reply doc (%d bytes: %s)",
+			len(msg[doc_offset:]), hex.EncodeToString(msg))
+	}
+	return msg[doc_offset : doc_offset+doclen], nil
+}
+
+// errorCodeUnauthorized is the code of the error returned for commands that
+// require authentication.
+const errorCodeUnauthorized = 13
+
+// getListDatabases returns whether reply shows that the server requires
+// authentication, and what it listed, keeping at most maxDatabases
+// databases. The former is nil if the command failed for another reason.
+func getListDatabases(reply *listDatabasesReply, maxDatabases uint) (*bool, *ListDatabases_t) {
+	if reply.OK == 0 {
+		var authRequired *bool
+		if reply.Code == errorCodeUnauthorized || reply.CodeName == "Unauthorized" {
+			authRequired = new(bool)
+			*authRequired = true
+		}
+		return authRequired, &ListDatabases_t{
+			Error:         reply.ErrMsg,
+			ErrorCode:     reply.Code,
+			ErrorCodeName: reply.CodeName,
+		}
+	}
+	ret := &ListDatabases_t{
+		Databases:      reply.Databases,
+		TotalDatabases: len(reply.Databases),
+		TotalSize:      reply.TotalSize,
+	}
+	if uint(len(ret.Databases)) > maxDatabases {
+		ret.Databases = ret.Databases[:maxDatabases]
+		ret.DatabasesTruncated = true
+	}
+	return new(bool), ret
+}
+
+// listDatabases sends the listDatabases command without credentials, as an
+// OP_MSG if opMsg is set or an OP_QUERY otherwise, and records whether the
+// server rejected it, and the databases listed if not.
+func (scan *scan) listDatabases(opMsg bool) error {
+	query := scan.scanner.listDatabasesQuery
+	if opMsg {
+		query = scan.scanner.listDatabasesOpMsg
+	}
+	if err := scan.conn.Write(query); err != nil {
+		return err
+	}
+	msg, err := scan.conn.readMsg(maxListDatabasesMsgLen)
+	if err != nil {
+		return err
+	}
+	doc, err := getReplyDoc(msg)
+	if err != nil {
+		return zgrab2.NewScanError(zgrab2.SCAN_PROTOCOL_ERROR, err)
+	}
+	reply := listDatabasesReply{}
+	if err := bson.Unmarshal(doc, &reply); err != nil {
+		err = fmt.Errorf("Server sent invalid BSON reply doc (%d bytes: %s)", len(doc), hex.EncodeToString(doc))
+		return zgrab2.NewScanError(zgrab2.SCAN_PROTOCOL_ERROR, err)
+	}
+	scan.result.AuthRequired, scan.result.ListDatabases = getListDatabases(&reply, scan.scanner.config.MaxDatabases)
+	return nil
+}
+
+// Scan connects to a host and performs a scan: isMaster, then buildinfo,
+// then listDatabases, which tells whether the server can be read without
+// credentials.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
 	scan, err := scanner.StartScan(&target)
 	if err != nil {
 		return zgrab2.TryGetScanStatus(err), nil, err
@@ -313,7 +487,13 @@ func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, inter
 	}
 	bson.Unmarshal(msg[MSGHEADER_LEN+resp_offset:], &result.BuildInfo)
 
-	return zgrab2.SCAN_SUCCESS, &result, err
+	// The server has been identified by now, so a failure here is only
+	// recorded.
+	if err := scan.listDatabases(result.IsMaster.MaxWireVersion >= 6); err != nil {
+		result.ListDatabases = &ListDatabases_t{Error: err.Error()}
+	}
+
+	return zgrab2.SCAN_SUCCESS, &result, nil
 }
 
 // RegisterModule registers the zgrab2 module.
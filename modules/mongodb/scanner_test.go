@@ -0,0 +1,198 @@
+package mongodb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// fakeConn is a Reader/Writer that replies with a canned message.
+type fakeConn struct {
+	reply   bytes.Buffer
+	written bytes.Buffer
+}
+
+func (conn *fakeConn) Read(buf []byte) (int, error) {
+	return conn.reply.Read(buf)
+}
+
+func (conn *fakeConn) Write(buf []byte) (int, error) {
+	return conn.written.Write(buf)
+}
+
+// getOpMsgReply returns an OP_MSG carrying doc.
+func getOpMsgReply(t *testing.T, doc interface{}) []byte {
+	payload, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return getOpMsg(append([]byte{0}, payload...))
+}
+
+// getOpReply returns an OP_REPLY carrying doc.
+func getOpReply(t *testing.T, doc interface{}) []byte {
+	payload, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := make([]byte, MSGHEADER_LEN+20+len(payload))
+	binary.LittleEndian.PutUint32(msg[0:], uint32(len(msg)))
+	binary.LittleEndian.PutUint32(msg[12:], OP_REPLY)
+	binary.LittleEndian.PutUint32(msg[MSGHEADER_LEN+16:], 1)
+	copy(msg[MSGHEADER_LEN+20:], payload)
+	return msg
+}
+
+func TestGetReplyDoc(t *testing.T) {
+	for _, msg := range [][]byte{getOpMsgReply(t, bson.M{"ok": 1}), getOpReply(t, bson.M{"ok": 1})} {
+		doc, err := getReplyDoc(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded listDatabasesReply
+		if err := bson.Unmarshal(doc, &decoded); err != nil || decoded.OK != 1 {
+			t.Errorf("unexpected reply doc %x (%v)", doc, err)
+		}
+		if _, err := getReplyDoc(msg[:len(msg)-1]); err == nil {
+			t.Errorf("expected an error for a truncated reply")
+		}
+	}
+	msg := getOpMsgReply(t, bson.M{"ok": 1})
+	binary.LittleEndian.PutUint32(msg[12:], OP_QUERY)
+	if _, err := getReplyDoc(msg); err == nil {
+		t.Errorf("expected an error for an unexpected opcode")
+	}
+}
+
+func TestGetListDatabases(t *testing.T) {
+	databases := []Database_t{
+		{Name: "admin", SizeOnDisk: 40960},
+		{Name: "customers", SizeOnDisk: 1 << 30},
+		{Name: "local", SizeOnDisk: 73728},
+	}
+	authRequired, listed := getListDatabases(&listDatabasesReply{OK: 1, Databases: databases, TotalSize: 1<<30 + 114688}, 2)
+	expected := &ListDatabases_t{
+		Databases:          databases[:2],
+		TotalDatabases:     3,
+		DatabasesTruncated: true,
+		TotalSize:          1<<30 + 114688,
+	}
+	if authRequired == nil || *authRequired || !reflect.DeepEqual(listed, expected) {
+		t.Errorf("expected %+v, got %v, %+v", expected, authRequired, listed)
+	}
+
+	authRequired, listed = getListDatabases(&listDatabasesReply{
+		ErrMsg:   "command listDatabases requires authentication",
+		Code:     13,
+		CodeName: "Unauthorized",
+	}, 2)
+	expected = &ListDatabases_t{
+		Error:         "command listDatabases requires authentication",
+		ErrorCode:     13,
+		ErrorCodeName: "Unauthorized",
+	}
+	if authRequired == nil || !*authRequired || !reflect.DeepEqual(listed, expected) {
+		t.Errorf("expected %+v, got %v, %+v", expected, authRequired, listed)
+	}
+
+	// Other errors do not tell whether authentication is required.
+	authRequired, listed = getListDatabases(&listDatabasesReply{
+		ErrMsg:   "not master and slaveOk=false",
+		Code:     13435,
+		CodeName: "NotMasterNoSlaveOk",
+	}, 2)
+	if authRequired != nil || listed.ErrorCode != 13435 {
+		t.Errorf("expected no auth_required, got %v, %+v", authRequired, listed)
+	}
+}
+
+func TestListDatabases(t *testing.T) {
+	scanner := &Scanner{config: &Flags{MaxDatabases: 10}}
+	if err := scanner.Init(scanner.config); err != nil {
+		t.Fatal(err)
+	}
+	conn := &fakeConn{}
+	// Servers may send sizes as doubles.
+	conn.reply.Write(getOpMsgReply(t, bson.M{
+		"databases": []bson.M{
+			{"name": "admin", "sizeOnDisk": 40960.0, "empty": false},
+			{"name": "test", "sizeOnDisk": 0.0, "empty": true},
+		},
+		"totalSize": 40960.0,
+		"ok":        1.0,
+	}))
+	scan := &scan{scanner: scanner, result: &Result{}, conn: &Connection{scanner: scanner, conn: conn}}
+	if err := scan.listDatabases(true); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(conn.written.Bytes(), scanner.listDatabasesOpMsg) {
+		t.Errorf("expected listDatabases to be sent as an OP_MSG, sent %x", conn.written.Bytes())
+	}
+	expected := &ListDatabases_t{
+		Databases:      []Database_t{{Name: "admin", SizeOnDisk: 40960}, {Name: "test", Empty: true}},
+		TotalDatabases: 2,
+		TotalSize:      40960,
+	}
+	if scan.result.AuthRequired == nil || *scan.result.AuthRequired || !reflect.DeepEqual(scan.result.ListDatabases, expected) {
+		t.Errorf("expected %+v, got %+v", expected, scan.result.ListDatabases)
+	}
+}
+
+func TestScanListDatabasesFailure(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	// The server answers isMaster and buildinfo, then closes the connection
+	// instead of answering listDatabases.
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		replies := [][]byte{
+			getOpReply(t, bson.M{"ismaster": true, "maxWireVersion": 5}),
+			getOpReply(t, bson.M{"version": "3.4.0"}),
+		}
+		for _, reply := range replies {
+			header := make([]byte, MSGHEADER_LEN)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				return
+			}
+			body := make([]byte, binary.LittleEndian.Uint32(header)-MSGHEADER_LEN)
+			if _, err := io.ReadFull(conn, body); err != nil {
+				return
+			}
+			conn.Write(reply)
+		}
+	}()
+
+	scanner := &Scanner{}
+	flags := &Flags{MaxDatabases: 10}
+	flags.Timeout = 5 * time.Second
+	if err := scanner.Init(flags); err != nil {
+		t.Fatal(err)
+	}
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	status, ret, err := scanner.Scan(context.Background(), zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port})
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	result := *ret.(**Result)
+	if result.IsMaster == nil || result.BuildInfo == nil || result.BuildInfo.Version != "3.4.0" {
+		t.Errorf("expected isMaster and buildinfo, got %+v", result)
+	}
+	if result.AuthRequired != nil || result.ListDatabases == nil || result.ListDatabases.Error == "" {
+		t.Errorf("expected the listDatabases failure to be recorded, got %v, %+v", result.AuthRequired, result.ListDatabases)
+	}
+}
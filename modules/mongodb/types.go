@@ -37,6 +37,15 @@ const (
 	MSGHEADER_LEN	= 16
 )
 
+const (
+	// maxMsgLen bounds the messages read by ReadMsg.
+	maxMsgLen = 5125
+
+	// maxListDatabasesMsgLen bounds the reply to listDatabases, which can
+	// list many databases.
+	maxListDatabasesMsgLen = 1024 * 1024
+)
+
 // Connection holds the state for a single connection within a scan.
 type Connection struct {
 	scanner *Scanner
@@ -48,13 +57,19 @@ type Connection struct {
 
 // ReadMsg reads a full MongoDB message from the connection.
 func (conn *Connection) ReadMsg() ([]byte, error) {
+	return conn.readMsg(maxMsgLen)
+}
+
+// readMsg reads a full MongoDB message of at most maxLen bytes from the
+// connection.
+func (conn *Connection) readMsg(maxLen uint32) ([]byte, error) {
 	var msglen_buf [4]byte
 	_, err := io.ReadFull(conn.conn, msglen_buf[:])
 	if err != nil {
 		return nil, err
 	}
 	msglen := binary.LittleEndian.Uint32(msglen_buf[:])
-	if msglen < 4 || msglen > 5125 {
+	if msglen < 4 || msglen > maxLen {
 	        // msglen is length of message which includes msglen itself; Less than
 		// four is invalid. More than a few K probably mean this isn't actually
 		// a mongodb server.
@@ -0,0 +1,372 @@
+package mssql
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+var (
+	// ErrLoginFailed is returned when the server rejects the LOGIN7 request.
+	ErrLoginFailed = errors.New("login failed")
+
+	// ErrNoLoginAck is returned when the server's response to the LOGIN7
+	// request has neither a LOGINACK nor an ERROR token.
+	ErrNoLoginAck = errors.New("no LOGINACK in login response")
+)
+
+// https://msdn.microsoft.com/en-us/library/dd304019.aspx
+const (
+	// login7FixedSize is the size of the fixed-length part of the LOGIN7
+	// packet body, up to and including cbSSPILong (TDS 7.2 and later).
+	login7FixedSize = 94
+
+	// login7TDSVersion is the TDS version requested in the LOGIN7 packet:
+	// TDS 7.4, i.e. SQL Server 2012 and later.
+	login7TDSVersion = 0x74000004
+
+	// login7PacketSize is the packet size requested in the LOGIN7 packet.
+	login7PacketSize = 4096
+
+	// login7OptionFlags1 sets fUseDB, fDatabase (INIT_DB_FATAL) and fSetLang.
+	login7OptionFlags1 = 0xE0
+
+	// login7OptionFlags2 sets fLanguage (INIT_LANG_FATAL) and fODBC.
+	login7OptionFlags2 = 0x03
+
+	// login7LCID is the client locale (en-US).
+	login7LCID = 0x0409
+
+	// login7ClientName is the host, application and library name sent in the
+	// LOGIN7 packet.
+	login7ClientName = "zgrab2"
+)
+
+// TDSToken identifies a token in a tabular result token stream.
+// Values are defined at https://msdn.microsoft.com/en-us/library/dd357296.aspx.
+type TDSToken uint8
+
+const (
+	// TDSTokenError is an ERROR token.
+	TDSTokenError TDSToken = 0xAA
+
+	// TDSTokenInfo is an INFO token.
+	TDSTokenInfo = 0xAB
+
+	// TDSTokenLoginAck is a LOGINACK token.
+	TDSTokenLoginAck = 0xAD
+
+	// TDSTokenFeatureExtAck is a FEATUREEXTACK token.
+	TDSTokenFeatureExtAck = 0xAE
+
+	// TDSTokenEnvChange is an ENVCHANGE token.
+	TDSTokenEnvChange = 0xE3
+
+	// TDSTokenSessionState is a SESSIONSTATE token.
+	TDSTokenSessionState = 0xE4
+
+	// TDSTokenFedAuthInfo is a FEDAUTHINFO token.
+	TDSTokenFedAuthInfo = 0xEE
+
+	// TDSTokenDone is a DONE token.
+	TDSTokenDone = 0xFD
+
+	// TDSTokenDoneProc is a DONEPROC token.
+	TDSTokenDoneProc = 0xFE
+
+	// TDSTokenDoneInProc is a DONEINPROC token.
+	TDSTokenDoneInProc = 0xFF
+)
+
+// envChangeDatabase is the ENVCHANGE type for a change of database.
+const envChangeDatabase = 1
+
+// LoginResult is the outcome of the LOGIN7 request.
+type LoginResult struct {
+	// Success is true if the server acknowledged the login.
+	Success bool `json:"success"`
+
+	// TDSVersion is the TDS version the server agreed to, e.g. "7.4".
+	TDSVersion string `json:"tds_version,omitempty"`
+
+	// ProgramName is the name of the server program in the LOGINACK, e.g.
+	// "Microsoft SQL Server".
+	ProgramName string `json:"program_name,omitempty"`
+
+	// ProgramVersion is the version of the server program in the LOGINACK,
+	// as "MAJOR.MINOR.BUILD_NUMBER".
+	ProgramVersion string `json:"program_version,omitempty"`
+
+	// Database is the database the session was switched to.
+	Database string `json:"database,omitempty"`
+
+	// ErrorNumber is the number of the ERROR token, if the login failed,
+	// e.g. 18456 for bad credentials.
+	ErrorNumber int32 `json:"error_number,omitempty"`
+
+	// ErrorMessage is the message of the ERROR token, if the login failed.
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+// encodeUCS2 returns the UTF-16LE encoding of s.
+func encodeUCS2(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	ret := make([]byte, 2*len(units))
+	for i, unit := range units {
+		binary.LittleEndian.PutUint16(ret[2*i:], unit)
+	}
+	return ret
+}
+
+// decodeUCS2 decodes the UTF-16LE string in buf.
+func decodeUCS2(buf []byte) string {
+	units := make([]uint16, len(buf)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(buf[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// obfuscatePassword scrambles the UTF-16LE encoded password as described in
+// the LOGIN7 docs: each byte has its nibbles swapped, and is XORed with 0xA5.
+// This is not encryption.
+func obfuscatePassword(password []byte) []byte {
+	ret := make([]byte, len(password))
+	for i, b := range password {
+		ret[i] = (b<<4 | b>>4) ^ 0xA5
+	}
+	return ret
+}
+
+// encodeLogin7 returns the body of a LOGIN7 packet for SQL Server
+// authentication with the given credentials.
+func encodeLogin7(user, password, serverName, database string) ([]byte, error) {
+	// The variable-length fields, in the order of their OffsetLength entries.
+	// Each is a UTF-16LE string, whose length is given in characters.
+	fields := [][]byte{
+		encodeUCS2(login7ClientName), // HostName
+		encodeUCS2(user),
+		obfuscatePassword(encodeUCS2(password)),
+		encodeUCS2(login7ClientName), // AppName
+		encodeUCS2(serverName),
+		nil,                          // Extension (unused)
+		encodeUCS2(login7ClientName), // CltIntName
+		nil,                          // Language
+		encodeUCS2(database),
+	}
+	size := login7FixedSize
+	for _, field := range fields {
+		size += len(field)
+	}
+	if size > 0xffff-8 {
+		return nil, ErrTooLarge
+	}
+	ret := make([]byte, login7FixedSize, size)
+	binary.LittleEndian.PutUint32(ret[0:4], uint32(size))
+	binary.LittleEndian.PutUint32(ret[4:8], login7TDSVersion)
+	binary.LittleEndian.PutUint32(ret[8:12], login7PacketSize)
+	// ClientProgVer, ClientPID and ConnectionID are left zero.
+	ret[24] = login7OptionFlags1
+	ret[25] = login7OptionFlags2
+	// TypeFlags, OptionFlags3 and ClientTimeZone are left zero.
+	binary.LittleEndian.PutUint32(ret[32:36], login7LCID)
+	cursor := ret[36:]
+	for _, field := range fields {
+		binary.LittleEndian.PutUint16(cursor[0:2], uint16(len(ret)))
+		binary.LittleEndian.PutUint16(cursor[2:4], uint16(len(field)/2))
+		ret = append(ret, field...)
+		cursor = cursor[4:]
+	}
+	// ClientID (6 bytes) is left zero; SSPI, AtchDBFile and ChangePassword
+	// are empty, pointing to the end of the data; cbSSPILong is zero.
+	cursor = cursor[6:]
+	for i := 0; i < 3; i++ {
+		binary.LittleEndian.PutUint16(cursor[0:2], uint16(len(ret)))
+		cursor = cursor[4:]
+	}
+	return ret, nil
+}
+
+// tokenReader reads values from a token stream, recording the first error.
+type tokenReader struct {
+	data []byte
+	err  error
+}
+
+// bytes reads n bytes, or returns nil if there are not enough left.
+func (r *tokenReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.data) < n {
+		r.err = ErrInvalidData
+		return nil
+	}
+	ret := r.data[:n]
+	r.data = r.data[n:]
+	return ret
+}
+
+// byte reads a single byte.
+func (r *tokenReader) byte() byte {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+// uint16 reads a little-endian USHORT.
+func (r *tokenReader) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+// uint32 reads a little-endian DWORD.
+func (r *tokenReader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+// skipLong skips a field with a DWORD length. The length is checked against
+// the data left before it is converted, as it may not fit in an int.
+func (r *tokenReader) skipLong() {
+	n := r.uint32()
+	if r.err == nil && uint64(n) > uint64(len(r.data)) {
+		r.err = ErrInvalidData
+		return
+	}
+	r.bytes(int(n))
+}
+
+// bVarchar reads a B_VARCHAR: a string with a one-byte length in characters.
+func (r *tokenReader) bVarchar() string {
+	return decodeUCS2(r.bytes(2 * int(r.byte())))
+}
+
+// usVarchar reads a US_VARCHAR: a string with a two-byte length in
+// characters.
+func (r *tokenReader) usVarchar() string {
+	return decodeUCS2(r.bytes(2 * int(r.uint16())))
+}
+
+// parseLoginResponse parses the token stream sent in response to a LOGIN7
+// request. It returns ErrLoginFailed if the server sent an ERROR token
+// instead of a LOGINACK.
+func parseLoginResponse(body []byte) (*LoginResult, error) {
+	result := &LoginResult{}
+	stream := &tokenReader{data: body}
+	for len(stream.data) > 0 && stream.err == nil {
+		token := TDSToken(stream.byte())
+		switch token {
+		case TDSTokenLoginAck, TDSTokenError, TDSTokenInfo, TDSTokenEnvChange:
+			r := &tokenReader{data: stream.bytes(int(stream.uint16()))}
+			if stream.err != nil {
+				break
+			}
+			parseLoginToken(result, token, r)
+		case TDSTokenSessionState, TDSTokenFedAuthInfo:
+			stream.skipLong()
+		case TDSTokenFeatureExtAck:
+			for stream.err == nil && stream.byte() != 0xFF {
+				stream.skipLong()
+			}
+		case TDSTokenDone, TDSTokenDoneProc, TDSTokenDoneInProc:
+			stream.bytes(12)
+		default:
+			return result, ErrInvalidData
+		}
+	}
+	if stream.err != nil {
+		return result, stream.err
+	}
+	if result.Success {
+		return result, nil
+	}
+	if result.ErrorNumber != 0 {
+		return result, ErrLoginFailed
+	}
+	return result, ErrNoLoginAck
+}
+
+// parseLoginToken records the LOGINACK, ERROR or database ENVCHANGE token
+// read by r in result. Other tokens are ignored, as are malformed ones.
+func parseLoginToken(result *LoginResult, token TDSToken, r *tokenReader) {
+	switch token {
+	case TDSTokenLoginAck:
+		r.byte() // Interface
+		version := r.bytes(4)
+		name := r.bVarchar()
+		progVersion := r.bytes(4)
+		if r.err != nil {
+			return
+		}
+		result.Success = true
+		result.TDSVersion = fmt.Sprintf("%d.%d", version[0]>>4, version[0]&0x0f)
+		result.ProgramName = name
+		result.ProgramVersion = fmt.Sprintf("%d.%d.%d", progVersion[0], progVersion[1], binary.BigEndian.Uint16(progVersion[2:4]))
+	case TDSTokenError:
+		number := r.uint32()
+		r.bytes(2) // State, Class
+		message := r.usVarchar()
+		if r.err != nil || result.ErrorNumber != 0 {
+			return
+		}
+		result.ErrorNumber = int32(number)
+		result.ErrorMessage = message
+	case TDSTokenEnvChange:
+		if r.byte() == envChangeDatabase {
+			if database := r.bVarchar(); r.err == nil {
+				result.Database = database
+			}
+		}
+	}
+}
+
+// readMessage reads packets until the end of the message, and returns their
+// concatenated bodies.
+func (connection *Connection) readMessage() ([]byte, error) {
+	var ret []byte
+	for {
+		packet, err := connection.tdsConn.ReadPacket()
+		if err != nil {
+			return ret, err
+		}
+		if packet.Type != TDSPacketTypeTabularResult {
+			return ret, ErrInvalidData
+		}
+		ret = append(ret, packet.Body...)
+		if packet.Status&TDSStatusEOM != 0 {
+			return ret, nil
+		}
+	}
+}
+
+// Login sends a LOGIN7 request with the given SQL Server credentials and
+// reads the response. Called after Handshake(). If the server only encrypts
+// the login (EncryptModeOff), the TLS layer is dropped once the request has
+// been sent, and the response is read in the clear.
+func (connection *Connection) Login(user, password, serverName, database string) (*LoginResult, error) {
+	body, err := encodeLogin7(user, password, serverName, database)
+	if err != nil {
+		return nil, err
+	}
+	if err := connection.SendTDSPacket(TDSPacketTypeTDS7Login, body); err != nil {
+		return nil, err
+	}
+	if connection.tlsConn != nil && connection.getEncryptMode() == EncryptModeOff {
+		// Client was only using encryption for login, so switch back to rawConn
+		connection.tdsConn = &tdsConnection{conn: connection.rawConn, enabled: true, session: connection}
+		// tdsConnection.Write(rawData) -> net.Conn.Write(header + rawData)
+		// conn.Read() -> header + rawData -> tdsConnection.Read() -> rawData
+	}
+	response, err := connection.readMessage()
+	if err != nil {
+		return nil, err
+	}
+	return parseLoginResponse(response)
+}
@@ -0,0 +1,180 @@
+package mssql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// encodeToken returns a token with a two-byte length.
+func encodeToken(token TDSToken, body ...[]byte) []byte {
+	data := bytes.Join(body, nil)
+	ret := []byte{byte(token), 0, 0}
+	binary.LittleEndian.PutUint16(ret[1:], uint16(len(data)))
+	return append(ret, data...)
+}
+
+// encodeBVarchar returns s as a B_VARCHAR.
+func encodeBVarchar(s string) []byte {
+	data := encodeUCS2(s)
+	return append([]byte{byte(len(data) / 2)}, data...)
+}
+
+// encodeUSVarchar returns s as a US_VARCHAR.
+func encodeUSVarchar(s string) []byte {
+	data := encodeUCS2(s)
+	ret := []byte{0, 0}
+	binary.LittleEndian.PutUint16(ret, uint16(len(data)/2))
+	return append(ret, data...)
+}
+
+var (
+	doneToken = append([]byte{TDSTokenDone}, make([]byte, 12)...)
+
+	loginAckToken = encodeToken(TDSTokenLoginAck,
+		[]byte{1},                      // Interface
+		[]byte{0x74, 0x00, 0x00, 0x04}, // TDSVersion
+		encodeBVarchar("Microsoft SQL Server"),
+		[]byte{14, 0, 0x0c, 0x1c}, // ProgVersion
+	)
+
+	loginErrorToken = encodeToken(TDSTokenError,
+		[]byte{0x18, 0x48, 0, 0}, // Number: 18456
+		[]byte{1, 14},            // State, Class
+		encodeUSVarchar("Login failed for user 'sa'."),
+		encodeBVarchar("SQL01"),
+		encodeBVarchar(""),
+		[]byte{1, 0, 0, 0}, // LineNumber
+	)
+
+	databaseToken = encodeToken(TDSTokenEnvChange,
+		[]byte{envChangeDatabase}, encodeBVarchar("master"), encodeBVarchar("master"))
+
+	infoToken = encodeToken(TDSTokenInfo,
+		[]byte{0x45, 0x16, 0, 0}, []byte{2, 0},
+		encodeUSVarchar("Changed database context to 'master'."),
+		encodeBVarchar("SQL01"), encodeBVarchar(""), []byte{1, 0, 0, 0})
+)
+
+func TestEncodeLogin7(t *testing.T) {
+	body, err := encodeLogin7("sa", "ab", "db.example.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := binary.LittleEndian.Uint32(body); int(size) != len(body) {
+		t.Errorf("length %d does not match the body size %d", size, len(body))
+	}
+	if version := binary.LittleEndian.Uint32(body[4:]); version != login7TDSVersion {
+		t.Errorf("unexpected TDS version 0x%08x", version)
+	}
+	// field returns the variable-length field with the given index.
+	field := func(i int) []byte {
+		offset := binary.LittleEndian.Uint16(body[36+4*i:])
+		length := binary.LittleEndian.Uint16(body[38+4*i:])
+		return body[offset : int(offset)+2*int(length)]
+	}
+	if user := decodeUCS2(field(1)); user != "sa" {
+		t.Errorf("unexpected user %q", user)
+	}
+	if password := field(2); !bytes.Equal(password, []byte{0xb3, 0xa5, 0x83, 0xa5}) {
+		t.Errorf("unexpected obfuscated password %x", password)
+	}
+	if server := decodeUCS2(field(4)); server != "db.example.com" {
+		t.Errorf("unexpected server name %q", server)
+	}
+	if first := binary.LittleEndian.Uint16(body[36:]); first != login7FixedSize {
+		t.Errorf("expected the data to start at %d, got %d", login7FixedSize, first)
+	}
+}
+
+func TestParseLoginResponse(t *testing.T) {
+	result, err := parseLoginResponse(bytes.Join([][]byte{databaseToken, infoToken, loginAckToken, doneToken}, nil))
+	expected := &LoginResult{Success: true, TDSVersion: "7.4", ProgramName: "Microsoft SQL Server", ProgramVersion: "14.0.3100", Database: "master"}
+	if err != nil || !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %+v, got %+v: %v", expected, result, err)
+	}
+
+	result, err = parseLoginResponse(append(loginErrorToken, doneToken...))
+	expected = &LoginResult{ErrorNumber: 18456, ErrorMessage: "Login failed for user 'sa'."}
+	if err != ErrLoginFailed || !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %+v, got %+v: %v", expected, result, err)
+	}
+
+	if _, err := parseLoginResponse(doneToken); err != ErrNoLoginAck {
+		t.Errorf("expected ErrNoLoginAck, got %v", err)
+	}
+	if _, err := parseLoginResponse(loginAckToken[:10]); err != ErrInvalidData {
+		t.Errorf("expected a truncated token to be invalid, got %v", err)
+	}
+	// DWORD lengths that do not fit in the data (or, on 32-bit platforms, in
+	// an int) are invalid.
+	for _, length := range [][]byte{{0x10, 0, 0, 0}, {0xff, 0xff, 0xff, 0xff}} {
+		sessionState := append([]byte{byte(TDSTokenSessionState)}, length...)
+		if _, err := parseLoginResponse(append(sessionState, doneToken...)); err != ErrInvalidData {
+			t.Errorf("expected the length %x to be invalid, got %v", length, err)
+		}
+	}
+}
+
+// serveLogin answers a PRELOGIN on conn with ENCRYPT_NOT_SUP, then reads a
+// LOGIN7 and answers it with response, split into two packets.
+func serveLogin(t *testing.T, conn net.Conn, response []byte) {
+	defer conn.Close()
+	readPacket := func(packetType TDSPacketType) []byte {
+		header, err := readTDSHeader(conn)
+		if err != nil || header.Type != uint8(packetType) {
+			t.Errorf("expected a packet of type 0x%02x, got %+v: %v", packetType, header, err)
+			return nil
+		}
+		body := make([]byte, header.Length-8)
+		if _, err := io.ReadFull(conn, body); err != nil {
+			t.Error(err)
+		}
+		return body
+	}
+	writePacket := func(status uint8, body []byte) {
+		packet := &TDSPacket{TDSHeader: TDSHeader{Type: TDSPacketTypeTabularResult, Status: status}, Body: body}
+		buf, _ := packet.Encode()
+		conn.Write(buf)
+	}
+
+	readPacket(TDSPacketTypePrelogin)
+	options, _ := PreloginOptions{
+		PreloginVersion:    {14, 0, 0x0c, 0x1c, 0, 0},
+		PreloginEncryption: {byte(EncryptModeNotSupported)},
+	}.Encode()
+	writePacket(TDSStatusEOM, options)
+	if readPacket(TDSPacketTypeTDS7Login) == nil {
+		return
+	}
+	writePacket(TDSStatusNormal, response[:5])
+	writePacket(TDSStatusEOM, response[5:])
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		response []byte
+		success  bool
+		err      error
+	}{
+		{bytes.Join([][]byte{databaseToken, loginAckToken, doneToken}, nil), true, nil},
+		{append(loginErrorToken, doneToken...), false, ErrLoginFailed},
+	}
+	for _, test := range tests {
+		client, server := net.Pipe()
+		go serveLogin(t, server, test.response)
+		sql := NewConnection(client)
+		mode, err := sql.Handshake(&Flags{EncryptMode: "ENCRYPT_NOT_SUP"})
+		if err != nil || mode.Encryption() != "none" {
+			t.Fatalf("unexpected handshake result %s: %v", mode, err)
+		}
+		result, err := sql.Login("sa", "secret", "127.0.0.1", "")
+		if err != test.err || result == nil || result.Success != test.success {
+			t.Errorf("expected success=%v, got %+v: %v", test.success, result, err)
+		}
+		sql.Close()
+	}
+}
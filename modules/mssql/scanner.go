@@ -5,13 +5,16 @@
 // (the default is ENCRYPT_ON). Note: only ENCRYPT_NOT_SUP will skip the TLS
 // handshake, since even ENCRYPT_OFF uses TLS for the login step.
 //
-// The scan performs a PRELOGIN and if possible does a TLS handshake.
+// The scan performs a PRELOGIN and if possible does a TLS handshake. With
+// --login, it then sends a LOGIN7 with the --user and --password to check
+// whether they are accepted.
 //
-// The output is the the server version and instance name, and if applicable the
-// TLS output.
+// The output is the the server version and instance name, how much of the
+// session is encrypted, and if applicable the TLS output and login result.
 package mssql
 
 import (
+	"context"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -37,6 +40,14 @@ type ScanResults struct {
 	// EncryptMode is the mode negotiated with the server.
 	EncryptMode *EncryptMode `json:"encrypt_mode,omitempty"`
 
+	// Encryption is how much of the session the negotiated EncryptMode
+	// encrypts: "full", "login_only" (the credentials, but none of the
+	// queries or results), or "none" (not even the credentials).
+	Encryption string `json:"encryption,omitempty"`
+
+	// Login is the result of the LOGIN7 request, if --login is set.
+	Login *LoginResult `json:"login,omitempty"`
+
 	// TLSLog is the shared TLS handshake/scan log.
 	TLSLog *zgrab2.TLSLog `json:"tls,omitempty"`
 }
@@ -46,6 +57,10 @@ type Flags struct {
 	zgrab2.BaseFlags
 	zgrab2.TLSFlags
 	EncryptMode string `long:"encrypt-mode" description:"The type of encryption to request in the pre-login step. One of ENCRYPT_ON, ENCRYPT_OFF, ENCRYPT_NOT_SUP." default:"ENCRYPT_ON"`
+	Login       bool   `long:"login" description:"After the handshake, send a LOGIN7 with --user and --password to check whether the server accepts them"`
+	User        string `long:"user" description:"SQL Server login name to send with --login"`
+	Password    string `long:"password" description:"Password to send with --login. WARNING: This is sent in the clear if the server does not support encryption."`
+	Database    string `long:"database" description:"Initial database to request with --login; by default, the login's default database"`
 	Verbose     bool   `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
 }
 
@@ -74,8 +89,12 @@ func (module *Module) Description() string {
 	return "Perform a handshake for MSSQL databases"
 }
 
-// Validate does nothing in this module.
+// Validate checks that --login is given a --user.
 func (flags *Flags) Validate(args []string) error {
+	if flags.Login && flags.User == "" {
+		log.Errorf("--login requires --user")
+		return zgrab2.ErrInvalidArguments
+	}
 	return nil
 }
 
@@ -121,7 +140,8 @@ func (scanner *Scanner) GetTrigger() string {
 // 4. If the server encrypt mode is EncryptModeNotSupported, break.
 // 5. Perform a TLS handshake, with the packets wrapped in TDS headers.
 // 6. Decode the Version and InstanceName from the PRELOGIN response
-func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+// 7. With --login, send a LOGIN7; if it is rejected, fail with an app error.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
 	conn, err := target.Open(&scanner.config.BaseFlags)
 	if err != nil {
 		return zgrab2.TryGetScanStatus(err), nil, err
@@ -133,6 +153,7 @@ func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, inter
 	encryptMode, handshakeErr := sql.Handshake(scanner.config)
 
 	result.EncryptMode = &encryptMode
+	result.Encryption = encryptMode.Encryption()
 
 	if sql.tlsConn != nil {
 		result.TLSLog = sql.tlsConn.GetLog()
@@ -172,6 +193,24 @@ func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, inter
 			return zgrab2.TryGetScanStatus(handshakeErr), result, handshakeErr
 		}
 	}
+
+	if scanner.config.Login {
+		serverName := target.Domain
+		if serverName == "" {
+			serverName = target.Host()
+		}
+		login, err := sql.Login(scanner.config.User, scanner.config.Password, serverName, scanner.config.Database)
+		result.Login = login
+		switch err {
+		case nil:
+		case ErrLoginFailed:
+			return zgrab2.SCAN_APPLICATION_ERROR, result, err
+		case ErrNoLoginAck, ErrInvalidData:
+			return zgrab2.SCAN_PROTOCOL_ERROR, result, err
+		default:
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+	}
 	return zgrab2.SCAN_SUCCESS, result, nil
 }
 
@@ -0,0 +1,137 @@
+// Package mssqlbrowser provides a zgrab2 module that queries the SQL Server
+// Browser service for the SQL Server instances on a host.
+// Default Port: 1434 (UDP)
+//
+// The scanner sends a CLNT_BCAST_EX request (a single 0x02 byte) as
+// described in [MS-SQLR], and records each instance listed in the response,
+// with its name, version and the TCP port it listens on, which is how
+// instances on dynamic ports are found.
+package mssqlbrowser
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmap/zgrab2"
+)
+
+// ScanResults instances are returned by the module's Scan function.
+type ScanResults struct {
+	// Instances lists the instances in the response.
+	Instances []Instance `json:"instances,omitempty"`
+
+	// Response is the raw RESP_DATA of the response.
+	Response string `json:"response,omitempty" zgrab:"debug"`
+}
+
+// Flags holds the command-line configuration for the mssql-browser scan
+// module. Populated by the framework.
+type Flags struct {
+	zgrab2.BaseFlags
+	zgrab2.UDPFlags
+
+	// Verbose indicates that there should be more verbose logging.
+	Verbose bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
+}
+
+// Module implements the zgrab2.Module interface.
+type Module struct {
+}
+
+// Scanner implements the zgrab2.Scanner interface.
+type Scanner struct {
+	config *Flags
+}
+
+// RegisterModule registers the zgrab2 module.
+func RegisterModule() {
+	var module Module
+	_, err := zgrab2.AddCommand("mssql-browser", "mssql-browser", module.Description(), 1434, &module)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewFlags returns a default Flags object.
+func (module *Module) NewFlags() interface{} {
+	return new(Flags)
+}
+
+// NewScanner returns a new Scanner instance.
+func (module *Module) NewScanner() zgrab2.Scanner {
+	return new(Scanner)
+}
+
+// Description returns an overview of this module.
+func (module *Module) Description() string {
+	return "List the SQL Server instances, their versions and TCP ports, from the SQL Server Browser service"
+}
+
+// Validate checks that the flags are valid.
+// On success, returns nil.
+// On failure, returns an error instance describing the error.
+func (flags *Flags) Validate(args []string) error {
+	return nil
+}
+
+// Help returns the module's help string.
+func (flags *Flags) Help() string {
+	return ""
+}
+
+// Init initializes the Scanner.
+func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
+	f, _ := flags.(*Flags)
+	scanner.config = f
+	return nil
+}
+
+// InitPerSender initializes the scanner for a given sender.
+func (scanner *Scanner) InitPerSender(senderID int) error {
+	return nil
+}
+
+// GetName returns the Scanner name defined in the Flags.
+func (scanner *Scanner) GetName() string {
+	return scanner.config.Name
+}
+
+// GetTrigger returns the Trigger defined in the Flags.
+func (scanner *Scanner) GetTrigger() string {
+	return scanner.config.Trigger
+}
+
+// Protocol returns the protocol identifier of the scan.
+func (scanner *Scanner) Protocol() string {
+	return "mssql-browser"
+}
+
+// Scan performs the mssql-browser scan.
+//  1. Open a UDP connection to the target port (default 1434).
+//  2. Send a CLNT_BCAST_EX request, and read the response; if the host does
+//     not answer within the timeout, fail with the timeout.
+//  3. If the response is not an SVR_RESP, fail with a protocol error;
+//     otherwise record the instances listed, even if it is truncated.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	conn, err := target.OpenUDP(&scanner.config.BaseFlags, &scanner.config.UDPFlags)
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	defer conn.Close()
+
+	data, err := Query(conn)
+	if err != nil && err != ErrTruncated {
+		if err == ErrInvalidResponse {
+			return zgrab2.SCAN_PROTOCOL_ERROR, nil, err
+		}
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	result := &ScanResults{
+		Instances: parseInstances(data),
+		Response:  data,
+	}
+	if err != nil {
+		return zgrab2.SCAN_PROTOCOL_ERROR, result, err
+	}
+	return zgrab2.SCAN_SUCCESS, result, nil
+}
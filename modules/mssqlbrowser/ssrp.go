@@ -0,0 +1,138 @@
+// SQL Server Resolution Protocol ([MS-SQLR]) client for the mssql-browser
+// module.
+
+package mssqlbrowser
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// clntBcastEx is the CLNT_BCAST_EX request, asking for the list of all
+	// instances.
+	clntBcastEx = 0x02
+
+	// svrResp is the type of the SVR_RESP response.
+	svrResp = 0x05
+
+	// maxResponseSize bounds the size of a response datagram.
+	maxResponseSize = 65535
+)
+
+var (
+	// ErrInvalidResponse is returned when the response is not an SVR_RESP.
+	ErrInvalidResponse = errors.New("invalid SQL Server Browser response")
+
+	// ErrTruncated is returned when the response is shorter than its
+	// RESP_SIZE.
+	ErrTruncated = errors.New("truncated SQL Server Browser response")
+)
+
+// Instance describes a SQL Server instance listed in the response.
+type Instance struct {
+	// ServerName is the name of the server hosting the instance.
+	ServerName string `json:"server_name,omitempty"`
+
+	// InstanceName is the name of the instance, e.g. "MSSQLSERVER" for the
+	// default instance.
+	InstanceName string `json:"instance_name,omitempty"`
+
+	// IsClustered is true if the instance is part of a failover cluster.
+	IsClustered bool `json:"is_clustered"`
+
+	// Version is the instance's version, e.g. "15.0.2000.5".
+	Version string `json:"version,omitempty"`
+
+	// TCPPort is the TCP port the instance listens on, if it accepts TCP
+	// connections.
+	TCPPort uint16 `json:"tcp_port,omitempty"`
+
+	// NamedPipe is the named pipe the instance listens on, if any, e.g.
+	// \\HOST\pipe\sql\query.
+	NamedPipe string `json:"named_pipe,omitempty"`
+
+	// Other holds the other keys of the instance's record, such as the
+	// parameters of the "via", "rpc", "spx", "adsp" and "bv" protocols.
+	Other map[string]string `json:"other,omitempty"`
+}
+
+// parseInstances parses the RESP_DATA of an SVR_RESP: a record for each
+// instance, terminated by ";;", each a list of "key;value" pairs separated by
+// semicolons.
+func parseInstances(data string) []Instance {
+	var ret []Instance
+	for _, record := range strings.Split(data, ";;") {
+		if record == "" {
+			continue
+		}
+		var instance Instance
+		fields := strings.Split(record, ";")
+		for i := 0; i+1 < len(fields); i += 2 {
+			key, value := fields[i], fields[i+1]
+			switch strings.ToLower(key) {
+			case "servername":
+				instance.ServerName = value
+			case "instancename":
+				instance.InstanceName = value
+			case "isclustered":
+				instance.IsClustered = strings.EqualFold(value, "yes")
+			case "version":
+				instance.Version = value
+			case "tcp":
+				if port, err := strconv.ParseUint(value, 10, 16); err == nil {
+					instance.TCPPort = uint16(port)
+				} else {
+					instance.setOther(key, value)
+				}
+			case "np":
+				instance.NamedPipe = value
+			default:
+				instance.setOther(key, value)
+			}
+		}
+		ret = append(ret, instance)
+	}
+	return ret
+}
+
+// setOther records a key that has no field of its own.
+func (instance *Instance) setOther(key, value string) {
+	if instance.Other == nil {
+		instance.Other = make(map[string]string)
+	}
+	instance.Other[key] = value
+}
+
+// parseResponse returns the RESP_DATA of an SVR_RESP datagram. If the
+// datagram is shorter than its RESP_SIZE, it returns the data present with
+// ErrTruncated.
+func parseResponse(datagram []byte) (string, error) {
+	if len(datagram) < 3 || datagram[0] != svrResp {
+		return "", ErrInvalidResponse
+	}
+	size := int(binary.LittleEndian.Uint16(datagram[1:3]))
+	data := datagram[3:]
+	if len(data) < size {
+		return string(data), ErrTruncated
+	}
+	return string(data[:size]), nil
+}
+
+// Query sends a CLNT_BCAST_EX request over a UDP connection, and returns the
+// RESP_DATA of the response. The read times out with the connection's
+// deadline if the server does not answer.
+func Query(conn net.Conn) (string, error) {
+	if _, err := conn.Write([]byte{clntBcastEx}); err != nil {
+		return "", err
+	}
+	buf := make([]byte, maxResponseSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return parseResponse(buf[:n])
+}
@@ -0,0 +1,73 @@
+package mssqlbrowser
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+const testResponse = `ServerName;SQL01;InstanceName;MSSQLSERVER;IsClustered;No;Version;15.0.2000.5;tcp;1433;np;\\SQL01\pipe\sql\query;;` +
+	`ServerName;SQL01;InstanceName;SQLEXPRESS;IsClustered;Yes;Version;11.0.2100.60;tcp;49721;via;SQL01,1433:1433;;`
+
+var expectedInstances = []Instance{
+	{ServerName: "SQL01", InstanceName: "MSSQLSERVER", Version: "15.0.2000.5", TCPPort: 1433, NamedPipe: `\\SQL01\pipe\sql\query`},
+	{ServerName: "SQL01", InstanceName: "SQLEXPRESS", IsClustered: true, Version: "11.0.2100.60", TCPPort: 49721, Other: map[string]string{"via": "SQL01,1433:1433"}},
+}
+
+// encodeResponse returns an SVR_RESP datagram with the given RESP_SIZE and
+// data.
+func encodeResponse(size int, data string) []byte {
+	ret := []byte{svrResp, 0, 0}
+	binary.LittleEndian.PutUint16(ret[1:], uint16(size))
+	return append(ret, data...)
+}
+
+func TestParseInstances(t *testing.T) {
+	if instances := parseInstances(testResponse); !reflect.DeepEqual(instances, expectedInstances) {
+		t.Errorf("expected %+v, got %+v", expectedInstances, instances)
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	if data, err := parseResponse(encodeResponse(5, "abcdefg")); data != "abcde" || err != nil {
+		t.Errorf("expected the data up to RESP_SIZE, got %q: %v", data, err)
+	}
+	if data, err := parseResponse(encodeResponse(10, "abcde")); data != "abcde" || err != ErrTruncated {
+		t.Errorf("expected a truncated response, got %q: %v", data, err)
+	}
+	if _, err := parseResponse([]byte("HTTP/1.1 400")); err != ErrInvalidResponse {
+		t.Errorf("expected an invalid response, got %v", err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	go func() {
+		buf := make([]byte, 16)
+		n, addr, err := server.ReadFrom(buf)
+		if err != nil || n != 1 || buf[0] != clntBcastEx {
+			t.Errorf("unexpected request %x: %v", buf[:n], err)
+			return
+		}
+		server.WriteTo(encodeResponse(len(testResponse), testResponse), addr)
+	}()
+	port := uint(server.LocalAddr().(*net.UDPAddr).Port)
+	scanner := &Scanner{config: &Flags{}}
+	scanner.config.Timeout = 5 * time.Second
+	status, res, err := scanner.Scan(context.Background(), zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port})
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	if instances := res.(*ScanResults).Instances; !reflect.DeepEqual(instances, expectedInstances) {
+		t.Errorf("expected %+v, got %+v", expectedInstances, instances)
+	}
+}
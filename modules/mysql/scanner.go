@@ -5,6 +5,7 @@
 package mysql
 
 import (
+	"context"
 	"reflect"
 
 	log "github.com/sirupsen/logrus"
@@ -215,7 +216,7 @@ func (scanner *Scanner) GetTrigger() string {
 // 2. If the server supports SSL, send an SSLRequest packet, then
 //    perform the standard TLS actions.
 // 3. Process and return the results.
-func (s *Scanner) Scan(t zgrab2.ScanTarget) (status zgrab2.ScanStatus, result interface{}, thrown error) {
+func (s *Scanner) Scan(ctx context.Context, t zgrab2.ScanTarget) (status zgrab2.ScanStatus, result interface{}, thrown error) {
 	var tlsConn *zgrab2.TLSConnection
 	sql := mysql.NewConnection(&mysql.Config{})
 	defer func() {
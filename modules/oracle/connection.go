@@ -1,6 +1,9 @@
 package oracle
 
 import (
+	"bytes"
+	"encoding/hex"
+	"io"
 	"net"
 	"strconv"
 
@@ -68,6 +71,59 @@ type HandshakeLog struct {
 	// NSNServiceVersions is a map from the Native Service Negotiation service
 	// name to the ReleaseVersion in that service packet.
 	NSNServiceVersions map[string]string `json:"nsn_service_versions,omitempty"`
+
+	// NSNServices lists the services in the server's Native Service
+	// Negotiation response, giving the supervisor, authentication, encryption
+	// and data integrity options that it returned.
+	NSNServices []NSNServiceLog `json:"nsn_services,omitempty"`
+
+	// ChecksumMismatches lists the non-zero checksums in the server's packets
+	// that did not match the computed values (only checked with
+	// --verify-checksums).
+	ChecksumMismatches []ChecksumMismatch `json:"checksum_mismatches,omitempty"`
+}
+
+// NSNServiceLog is a service in the server's Native Service Negotiation
+// response.
+type NSNServiceLog struct {
+	// Type is the name of the service type, e.g. "Supervisor" or
+	// "Unknown(0x5)".
+	Type string `json:"type"`
+
+	// Marker is the service's marker, which is usually 0.
+	Marker uint32 `json:"marker,omitempty"`
+
+	// Values are the service's values (including its ReleaseVersion), as
+	// type / value pairs.
+	Values []NSNValue `json:"values,omitempty"`
+}
+
+// getNSNServiceLogs returns the logs of the services in the NSN packet.
+func getNSNServiceLogs(nsn *TNSDataNSN) []NSNServiceLog {
+	ret := make([]NSNServiceLog, len(nsn.Services))
+	for i, svc := range nsn.Services {
+		ret[i] = NSNServiceLog{
+			Type:   svc.Type.String(),
+			Marker: svc.Marker,
+			Values: svc.Values,
+		}
+	}
+	return ret
+}
+
+// PingLog gives the result of a --ping probe.
+type PingLog struct {
+	// ResponseType is the type of the packet that the listener sent in
+	// response to the ping (e.g. "REFUSE", "ACCEPT", "RESEND" or "REDIRECT").
+	ResponseType string `json:"response_type"`
+
+	// RefuseErrorRaw is the Data from the Refuse packet returned by the
+	// listener, if it returned one.
+	RefuseErrorRaw string `json:"refuse_error_raw,omitempty"`
+
+	// RefuseVersion is the parsed DESCRIPTION.VSNNUM field from the
+	// RefuseErrorRaw, in dotted-decimal format.
+	RefuseVersion string `json:"refuse_version,omitempty"`
 }
 
 // Connection holds the state for a scan connection to the Oracle server.
@@ -78,8 +134,18 @@ type Connection struct {
 	resent    bool
 	redirect  string
 	tnsDriver *TNSDriver
+
+	checksumMismatches []ChecksumMismatch
+
+	rawPackets          []string
+	rawPacketBytes      int
+	rawPacketsTruncated bool
 }
 
+// maxRawPacketBytes bounds the total size of the packets recorded with
+// --raw-packets.
+const maxRawPacketBytes = 16384
+
 // send ensures everything gets written
 func (conn *Connection) send(data []byte) error {
 	rest := data
@@ -96,7 +162,48 @@ func (conn *Connection) send(data []byte) error {
 
 // readPacket tries to read/parse a packet from the connection.
 func (conn *Connection) readPacket() (*TNSPacket, error) {
-	return conn.tnsDriver.ReadTNSPacket(conn.conn)
+	var reader io.Reader = conn.conn
+	var raw bytes.Buffer
+	if conn.scanner.config.RawPackets {
+		reader = io.TeeReader(conn.conn, &raw)
+	}
+	packet, err := conn.tnsDriver.ReadTNSPacket(reader)
+	if raw.Len() > 0 {
+		// Record the bytes even if the packet could not be parsed.
+		conn.recordRawPacket(raw.Bytes())
+	}
+	if packet != nil {
+		conn.checksumMismatches = append(conn.checksumMismatches, packet.ChecksumMismatches...)
+	}
+	return packet, err
+}
+
+// recordRawPacket records the hex encoding of a packet read from the server,
+// cut short once the recorded packets reach maxRawPacketBytes.
+func (conn *Connection) recordRawPacket(raw []byte) {
+	if left := maxRawPacketBytes - conn.rawPacketBytes; len(raw) > left {
+		raw = raw[:left]
+		conn.rawPacketsTruncated = true
+	}
+	if len(raw) == 0 {
+		return
+	}
+	conn.rawPacketBytes += len(raw)
+	conn.rawPackets = append(conn.rawPackets, hex.EncodeToString(raw))
+}
+
+// logRawPackets records the packets read from the server with --raw-packets
+// in results, which are created if needed.
+func (conn *Connection) logRawPackets(results *ScanResults) *ScanResults {
+	if len(conn.rawPackets) == 0 && !conn.rawPacketsTruncated {
+		return results
+	}
+	if results == nil {
+		results = new(ScanResults)
+	}
+	results.RawPackets = conn.rawPackets
+	results.RawPacketsTruncated = conn.rawPacketsTruncated
+	return results
 }
 
 // SendPacket sends the given packet body to the server (prefixing the
@@ -142,17 +249,22 @@ func u16Flag(v string) uint16 {
 	return uint16(ret)
 }
 
-// Connect to the server and do a handshake with the given config.
-func (conn *Connection) Connect(connectDescriptor string) (*HandshakeLog, error) {
-	result := HandshakeLog{}
+// newConnectPacket returns the Connect packet for connectDescriptor with the
+// given config.
+func (conn *Connection) newConnectPacket(connectDescriptor string) (*TNSConnect, error) {
+	version := conn.scanner.config.Version
 	extraData := []byte{}
+	if conn.scanner.config.TNSVersion != "" {
+		version = u16Flag(conn.scanner.config.TNSVersion)
+		extraData = ConnectUnknown3A(version)
+	}
 	if len(connectDescriptor)+len(extraData)+0x3A > 0x7fff {
 		return nil, ErrInvalidInput
 	}
 
 	// TODO: Variable fields in the connect descriptor (e.g. host?)
 	connectPacket := &TNSConnect{
-		Version:              conn.scanner.config.Version,
+		Version:              version,
 		MinVersion:           conn.scanner.config.MinVersion,
 		GlobalServiceOptions: ServiceOptions(u16Flag(conn.scanner.config.GlobalServiceOptions)),
 		SDU:                  u16Flag(conn.scanner.config.SDU),
@@ -160,8 +272,6 @@ func (conn *Connection) Connect(connectDescriptor string) (*HandshakeLog, error)
 		ProtocolCharacteristics: NTProtocolCharacteristics(u16Flag(conn.scanner.config.ProtocolCharacterisics)),
 		MaxBeforeAck:            0,
 		ByteOrder:               defaultByteOrder,
-		DataLength:              uint16(len(connectDescriptor)),
-		DataOffset:              uint16(0x003A + len(extraData)),
 		MaxResponseSize:         0x00000800,
 		ConnectFlags0:           ConnectFlags(u16Flag(conn.scanner.config.ConnectFlags) & 0xff),
 		ConnectFlags1:           ConnectFlags(u16Flag(conn.scanner.config.ConnectFlags) >> 8),
@@ -170,7 +280,76 @@ func (conn *Connection) Connect(connectDescriptor string) (*HandshakeLog, error)
 		ConnectionID0:           [8]byte{0, 0, 0, 0, 0, 0, 0, 0},
 		ConnectionID1:           [8]byte{0, 0, 0, 0, 0, 0, 0, 0},
 		Unknown3A:               extraData,
-		ConnectDescriptor:       connectDescriptor,
+	}
+	if err := connectPacket.SetConnectDescriptor(connectDescriptor); err != nil {
+		return nil, err
+	}
+	return connectPacket, nil
+}
+
+// decodeRefuseVersion returns the DESCRIPTION.VSNNUM field from the descriptor
+// in a Refuse packet, in dotted-decimal format, or "" if there is none.
+func decodeRefuseVersion(desc Descriptor) string {
+	if versions := desc.GetValues("DESCRIPTION.VSNNUM"); len(versions) > 0 {
+		// If there are multiple VSNNUMs, we only care about the first.
+		decVersion := versions[0]
+		if intVersion, err := strconv.ParseUint(decVersion, 10, 32); err == nil {
+			return DecodeReleaseVersion(uint32(intVersion))
+		}
+	}
+	return ""
+}
+
+// pingDescriptor is the connect descriptor sent by Ping: the listener's ping
+// command, as sent by tnsping, which needs no SID or service name.
+const pingDescriptor = "(CONNECT_DATA=(COMMAND=ping))"
+
+// Ping sends a Connect packet with the listener's ping command, and reads a
+// single packet in response, without resending or replying to it. Any TNS
+// packet shows that a listener is present; its type is recorded.
+func (conn *Connection) Ping() (*PingLog, error) {
+	connectPacket, err := conn.newConnectPacket(pingDescriptor)
+	if err != nil {
+		return nil, err
+	}
+	toSend, err := conn.tnsDriver.EncodePacket(&TNSPacket{Body: connectPacket})
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.send(toSend); err != nil {
+		return nil, err
+	}
+	response, err := conn.readPacket()
+	if response == nil {
+		return nil, err
+	}
+	// A packet whose body is not decoded (e.g. a Redirect) still shows that a
+	// listener is present, if its header is valid.
+	if _, ok := packetTypeNames[response.Header.Type]; !ok {
+		return nil, ErrInvalidData
+	}
+	result := &PingLog{ResponseType: response.Header.Type.String()}
+	if refuse, ok := response.Body.(*TNSRefuse); ok {
+		result.RefuseErrorRaw = string(refuse.Data)
+		if desc, err := DecodeDescriptor(result.RefuseErrorRaw); err == nil {
+			result.RefuseVersion = decodeRefuseVersion(desc)
+		}
+	}
+	return result, nil
+}
+
+// Connect to the server and do a handshake with the given config.
+func (conn *Connection) Connect(connectDescriptor string) (*HandshakeLog, error) {
+	result := HandshakeLog{}
+	defer func() {
+		// Ensure that checksum mismatches are recorded on all return paths.
+		if len(conn.checksumMismatches) > 0 {
+			result.ChecksumMismatches = conn.checksumMismatches
+		}
+	}()
+	connectPacket, err := conn.newConnectPacket(connectDescriptor)
+	if err != nil {
+		return nil, err
 	}
 	response, err := conn.SendPacket(connectPacket)
 
@@ -197,13 +376,7 @@ func (conn *Connection) Connect(connectDescriptor string) (*HandshakeLog, error)
 		result.RefuseReasonSys = resp.SysReason.String()
 		if desc, err := DecodeDescriptor(result.RefuseErrorRaw); err == nil {
 			result.RefuseError = desc
-			if versions := desc.GetValues("DESCRIPTION.VSNNUM"); len(versions) > 0 {
-				// If there are multiple VSNNUMs, we only care about the first.
-				decVersion := versions[0]
-				if intVersion, err := strconv.ParseUint(decVersion, 10, 32); err == nil {
-					result.RefuseVersion = ReleaseVersion(intVersion).String()
-				}
-			}
+			result.RefuseVersion = decodeRefuseVersion(desc)
 		}
 		return &result, nil
 	default:
@@ -315,6 +488,7 @@ func (conn *Connection) Connect(connectDescriptor string) (*HandshakeLog, error)
 	if err != nil {
 		return &result, err
 	}
+	result.NSNVersion = nsnResponse.Version.String()
 	result.NSNServiceVersions = make(map[string]string)
 	for _, svc := range nsnResponse.Services {
 		if !svc.Type.IsUnknown() {
@@ -326,6 +500,7 @@ func (conn *Connection) Connect(connectDescriptor string) (*HandshakeLog, error)
 			}
 		}
 	}
+	result.NSNServices = getNSNServiceLogs(nsnResponse)
 
 	return &result, nil
 }
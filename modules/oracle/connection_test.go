@@ -0,0 +1,114 @@
+package oracle
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+)
+
+// servePing reads the Connect packet from server, checks that it carries the
+// ping command, and replies with response.
+func servePing(t *testing.T, server net.Conn, driver *TNSDriver, response *TNSPacket) {
+	defer server.Close()
+	packet, err := driver.ReadTNSPacket(server)
+	if err != nil {
+		t.Errorf("ReadTNSPacket: %v", err)
+		return
+	}
+	connect, ok := packet.Body.(*TNSConnect)
+	if !ok {
+		t.Errorf("expected a Connect packet, got %T", packet.Body)
+		return
+	}
+	if descriptor := string(connect.ConnectDescriptor); !strings.Contains(descriptor, "(COMMAND=ping)") {
+		t.Errorf("expected the ping command, got %s", descriptor)
+	}
+	toSend, err := driver.EncodePacket(response)
+	if err != nil {
+		t.Errorf("EncodePacket: %v", err)
+		return
+	}
+	server.Write(toSend)
+}
+
+func TestPing(t *testing.T) {
+	flags := &Flags{
+		Version:                312,
+		MinVersion:             300,
+		GlobalServiceOptions:   "0x0C41",
+		SDU:                    "0x2000",
+		TDU:                    "0xFFFF",
+		ProtocolCharacterisics: "0x7F08",
+		ConnectFlags:           "0x4141",
+	}
+	scanner := &Scanner{config: flags}
+	refuseData := "(DESCRIPTION=(TMP=)(VSNNUM=186647040)(ERR=0)(ALIAS=LISTENER))"
+	client, server := net.Pipe()
+	defer client.Close()
+	go servePing(t, server, scanner.getTNSDriver(), &TNSPacket{
+		// TNSRefuse.GetType() does not give the Refuse type, so set the header.
+		Header: &TNSHeader{mode: TNSModeOld, Type: PacketTypeRefuse},
+		Body: &TNSRefuse{
+			AppReason:  0x22,
+			SysReason:  0,
+			DataLength: uint16(len(refuseData)),
+			Data:       []byte(refuseData),
+		},
+	})
+	conn := &Connection{conn: client, scanner: scanner, tnsDriver: scanner.getTNSDriver()}
+	result, err := conn.Ping()
+	if err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if result.ResponseType != "REFUSE" {
+		t.Errorf("expected a REFUSE response, got %s", result.ResponseType)
+	}
+	if result.RefuseErrorRaw != refuseData {
+		t.Errorf("expected refuse data %s, got %s", refuseData, result.RefuseErrorRaw)
+	}
+	if result.RefuseVersion != "11.2.0.2.0" {
+		t.Errorf("expected refuse version 11.2.0.2.0, got %s", result.RefuseVersion)
+	}
+}
+
+func TestRawPackets(t *testing.T) {
+	scanner := &Scanner{config: &Flags{
+		Version:                312,
+		MinVersion:             300,
+		GlobalServiceOptions:   "0x0C41",
+		SDU:                    "0x2000",
+		TDU:                    "0xFFFF",
+		ProtocolCharacterisics: "0x7F08",
+		ConnectFlags:           "0x4141",
+		RawPackets:             true,
+	}}
+	response := &TNSPacket{
+		Header: &TNSHeader{mode: TNSModeOld, Type: PacketTypeResend},
+		Body:   &TNSResend{},
+	}
+	encoded, err := scanner.getTNSDriver().EncodePacket(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, server := net.Pipe()
+	defer client.Close()
+	go servePing(t, server, scanner.getTNSDriver(), response)
+	conn := &Connection{conn: client, scanner: scanner, tnsDriver: scanner.getTNSDriver()}
+	if _, err := conn.Ping(); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	results := conn.logRawPackets(nil)
+	if len(results.RawPackets) != 1 || results.RawPackets[0] != hex.EncodeToString(encoded) || results.RawPacketsTruncated {
+		t.Errorf("expected raw packet %x, got %v", encoded, results.RawPackets)
+	}
+
+	// The recorded packets are cut short at maxRawPacketBytes.
+	conn.recordRawPacket(bytes.Repeat([]byte{0xff}, maxRawPacketBytes))
+	conn.recordRawPacket(encoded)
+	results = conn.logRawPackets(nil)
+	if len(results.RawPackets) != 2 || len(results.RawPackets[1]) != 2*(maxRawPacketBytes-len(encoded)) || !results.RawPacketsTruncated {
+		t.Errorf("expected the packets to be cut short, got %d packets", len(results.RawPackets))
+	}
+}
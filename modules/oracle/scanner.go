@@ -16,11 +16,17 @@
 // Sending an intentionally invalid --connect-descriptor can force a Refuse
 // response, which should include a version number.
 //
+// For fast discovery, --ping sends only a Connect packet with the listener's
+// ping command (as tnsping does), which needs no valid SID or service name,
+// and records the type of the packet returned: any valid TNS response shows
+// that a listener is present.
+//
 // The output includes the server's protocol version and any component release
 // versions that are returned.
 package oracle
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 
@@ -33,9 +39,21 @@ type ScanResults struct {
 	// Handshake is the log of the TNS handshake between client and server.
 	Handshake *HandshakeLog `json:"handshake,omitempty"`
 
+	// Ping is the result of the --ping probe, if it was sent in place of the
+	// handshake.
+	Ping *PingLog `json:"ping,omitempty"`
+
 	// TLSLog contains the log of the TLS handshake (and any additional
 	// configured TLS scan operations).
 	TLSLog *zgrab2.TLSLog `json:"tls,omitempty"`
+
+	// RawPackets are the raw bytes (in hex) of each packet read from the
+	// server, with --raw-packets.
+	RawPackets []string `json:"raw_packets,omitempty" zgrab:"debug"`
+
+	// RawPacketsTruncated is true if the packets read from the server
+	// exceeded maxRawPacketBytes, so that RawPackets were cut short.
+	RawPacketsTruncated bool `json:"raw_packets_truncated,omitempty" zgrab:"debug"`
 }
 
 // Flags holds the command-line configuration for the HTTP scan module.
@@ -48,6 +66,11 @@ type Flags struct {
 	// packet. TODO: Find version number mappings.
 	Version uint16 `long:"client-version" description:"The client version number to send." default:"312"`
 
+	// TNSVersion, if set, is the client version number sent in the Connect
+	// packet in place of Version, which is then laid out as a client of that
+	// version would (see ConnectUnknown3A).
+	TNSVersion string `long:"tns-version" description:"Send a connect packet laid out as by a client of this version (0x138 to 0x13b), advertising it in place of --client-version"`
+
 	// MinVersion is the minimum protocol version that the client claims support
 	// for in the Connect packet. Same format as Version above.
 	MinVersion uint16 `long:"min-server-version" description:"The minimum supported client version to send in the connect packet." default:"300"`
@@ -89,6 +112,26 @@ type Flags struct {
 	// lengths.
 	NewTNS bool `long:"new-tns" description:"If set, use new-style TNS headers"`
 
+	// ComputeChecksums causes the client to populate the packet and header
+	// checksums in the packets it sends, rather than leaving them 0.
+	ComputeChecksums bool `long:"compute-checksums" description:"If set, compute the TNS packet / header checksums on packets sent to the server"`
+
+	// VerifyChecksums causes the client to check any non-zero checksums in
+	// the server's packets, and record mismatches in the results.
+	VerifyChecksums bool `long:"verify-checksums" description:"If set, verify any TNS packet / header checksums sent by the server and record mismatches"`
+
+	// MaxPacketLength is the largest packet Length accepted from the server;
+	// larger packets are rejected without reading their body.
+	MaxPacketLength uint32 `long:"max-packet-length" default:"2097152" description:"Reject packets from the server whose header gives a length larger than this"`
+
+	// Ping causes the client to only check for a listener, by sending the
+	// listener's ping command and recording the type of the response.
+	Ping bool `long:"ping" description:"Only detect a TNS listener: send its ping command in a single connect packet and record the response type. Pair with a short --timeout for discovery."`
+
+	// RawPackets causes the raw bytes of each packet read from the server to
+	// be recorded in the results, up to maxRawPacketBytes in total.
+	RawPackets bool `long:"raw-packets" description:"Record the raw bytes (in hex) of each packet read from the server, up to 16KiB in total. Only output with --debug."`
+
 	// Verbose causes more verbose logging, and includes debug fields inthe scan
 	// results.
 	Verbose bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
@@ -147,9 +190,21 @@ func (flags *Flags) Validate(args []string) error {
 			return fmt.Errorf("%s: %s is larger than 16 bits", name, value)
 		}
 	}
+	if flags.TNSVersion != "" {
+		v, err := strconv.ParseUint(flags.TNSVersion, 0, 16)
+		if err != nil {
+			return fmt.Errorf("tns-version: %s is not a valid 16-bit integer: %v", flags.TNSVersion, err)
+		}
+		if uint16(v) < MinConnectLayoutVersion || uint16(v) > MaxConnectLayoutVersion {
+			return fmt.Errorf("tns-version: the connect packet layout of version 0x%x is unknown (must be 0x%x to 0x%x)", v, MinConnectLayoutVersion, MaxConnectLayoutVersion)
+		}
+	}
 	if _, err := EncodeReleaseVersion(flags.ReleaseVersion); err != nil {
 		return fmt.Errorf("release-version: %s is not a valid five-component dotted-decimal number", flags.ReleaseVersion)
 	}
+	if flags.MaxPacketLength != 0 && flags.MaxPacketLength < 8 {
+		return fmt.Errorf("max-packet-length: %d is shorter than a packet header", flags.MaxPacketLength)
+	}
 	return nil
 }
 
@@ -193,7 +248,12 @@ func (scanner *Scanner) getTNSDriver() *TNSDriver {
 	if scanner.config.NewTNS {
 		mode = TNSMode12c
 	}
-	return &TNSDriver{Mode: mode}
+	return &TNSDriver{
+		Mode:             mode,
+		ComputeChecksums: scanner.config.ComputeChecksums,
+		VerifyChecksums:  scanner.config.VerifyChecksums,
+		MaxPacketLength:  scanner.config.MaxPacketLength,
+	}
 }
 
 // Scan does the following:
@@ -217,7 +277,7 @@ func (scanner *Scanner) getTNSDriver() *TNSDriver {
 //     into the results, then send a Native Security Negotiation Data packet.
 //  8. If the response is not a Data packet, exit with SCAN_APPLICATION_ERROR.
 //  9. Pull the versions out of the response and exit with SCAN_SUCCESS.
-func (scanner *Scanner) Scan(t zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+func (scanner *Scanner) Scan(ctx context.Context, t zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
 	var results *ScanResults
 
 	sock, err := t.Open(&scanner.config.BaseFlags)
@@ -245,12 +305,28 @@ func (scanner *Scanner) Scan(t zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{
 		target:    &t,
 		tnsDriver: scanner.getTNSDriver(),
 	}
+	if scanner.config.Ping {
+		pingLog, err := conn.Ping()
+		results = conn.logRawPackets(results)
+		if err != nil {
+			if err == ErrInvalidData {
+				return zgrab2.SCAN_PROTOCOL_ERROR, results, err
+			}
+			return zgrab2.TryGetScanStatus(err), results, err
+		}
+		if results == nil {
+			results = new(ScanResults)
+		}
+		results.Ping = pingLog
+		return zgrab2.SCAN_SUCCESS, results, nil
+	}
 	connectDescriptor := scanner.config.ConnectDescriptor
 	if connectDescriptor == "" {
 		// In local testing, omitting the SERVICE_NAME allowed the server to
 		// choose an appropriate default. CID.PROGRAM added strictly for logging
 		// purposes.
-		connectDescriptor = "(DESCRIPTION=(CONNECT_DATA=(CID=(PROGRAM=zgrab2))))"
+		defaultDescriptor := ConnectDescriptorBuilder{Program: "zgrab2"}
+		connectDescriptor, _ = defaultDescriptor.Build()
 	}
 	handshakeLog, err := conn.Connect(connectDescriptor)
 	if handshakeLog != nil {
@@ -262,6 +338,7 @@ func (scanner *Scanner) Scan(t zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{
 		}
 		results.Handshake = handshakeLog
 	}
+	results = conn.logRawPackets(results)
 
 	if err != nil {
 		switch err {
@@ -28,8 +28,21 @@ var (
 	// ErrBufferTooSmall is returned when the caller provides a buffer that is
 	// too small for the required data.
 	ErrBufferTooSmall = errors.New("buffer too small")
+
+	// ErrTruncatedPacket is returned when the stream ends before the end of a
+	// packet, as given by the Length in its header.
+	ErrTruncatedPacket = errors.New("server sent a truncated packet")
+
+	// ErrPacketTooLarge is returned when the Length in a packet's header is
+	// larger than the TNSDriver's MaxPacketLength.
+	ErrPacketTooLarge = errors.New("server sent a packet larger than the maximum packet length")
 )
 
+// DefaultMaxPacketLength is the largest packet Length accepted by
+// ReadTNSPacket, unless the TNSDriver gives another: the largest SDU that a
+// server may negotiate.
+const DefaultMaxPacketLength = 0x200000
+
 // References:
 // https://wiki.wireshark.org/Oracle
 // https://blog.pythian.com/repost-oracle-protocol/
@@ -119,7 +132,7 @@ func getSliceReader(data []byte) *sliceReader {
 }
 
 func (reader *sliceReader) Read(output []byte) (int, error) {
-	if reader.Data == nil {
+	if len(reader.Data) == 0 {
 		return 0, io.EOF
 	}
 	n := len(output)
@@ -148,6 +161,64 @@ type TNSDriver struct {
 	// Mode determines what type of packets will be sent -- TNSModeOld or
 	// TNSMode12c.
 	Mode TNSMode
+
+	// ComputeChecksums causes EncodePacket to populate the PacketChecksum
+	// and HeaderChecksum fields of outgoing packets, rather than sending 0.
+	ComputeChecksums bool
+
+	// VerifyChecksums causes ReadTNSPacket to check any non-zero checksums on
+	// incoming packets, recording mismatches in the returned TNSPacket.
+	VerifyChecksums bool
+
+	// MaxPacketLength is the largest packet Length that ReadTNSPacket
+	// accepts; larger packets are rejected before their body is read, so
+	// that a bogus Length can't cause a huge allocation. If 0,
+	// DefaultMaxPacketLength is used.
+	MaxPacketLength uint32
+}
+
+// maxPacketLength returns the largest packet Length accepted by the driver.
+func (driver *TNSDriver) maxPacketLength() uint32 {
+	if driver.MaxPacketLength == 0 {
+		return DefaultMaxPacketLength
+	}
+	return driver.MaxPacketLength
+}
+
+// tnsChecksum computes the 16-bit ones'-complement checksum of data (the same
+// algorithm as the IP header checksum, RFC 1071). An odd trailing byte is
+// padded with a zero.
+func tnsChecksum(data []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[i : i+2]))
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
+
+// computeChecksums returns the PacketChecksum and HeaderChecksum for the
+// packet with the given encoded header and body. The PacketChecksum covers the
+// entire packet with both checksum fields set to zero; the HeaderChecksum
+// covers the header (including the PacketChecksum) with the HeaderChecksum
+// field set to zero. In TNSMode12c, there is no PacketChecksum, so it is
+// always returned as 0.
+func (driver *TNSDriver) computeChecksums(header []byte, body []byte) (uint16, uint16) {
+	temp := make([]byte, len(header))
+	copy(temp, header)
+	var packetChecksum uint16
+	temp[6], temp[7] = 0, 0
+	if driver.Mode == TNSModeOld {
+		temp[2], temp[3] = 0, 0
+		packetChecksum = tnsChecksum(append(temp, body...))
+		binary.BigEndian.PutUint16(temp[2:4], packetChecksum)
+	}
+	return packetChecksum, tnsChecksum(temp[0:8])
 }
 
 // EncodePacket encodes the packet (header + body). If header is nil, create one
@@ -184,6 +255,12 @@ func (driver *TNSDriver) EncodePacket(packet *TNSPacket) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if driver.ComputeChecksums {
+		packet.Header.PacketChecksum, packet.Header.HeaderChecksum = driver.computeChecksums(header, body)
+		if header, err = packet.Header.Encode(); err != nil {
+			return nil, err
+		}
+	}
 	return append(header, body...), nil
 }
 
@@ -241,9 +318,18 @@ func (header *TNSHeader) Encode() ([]byte, error) {
 
 // ReadTNSHeader reads/decodes a TNSHeader from the first 8 bytes of the stream.
 func (driver *TNSDriver) ReadTNSHeader(reader io.Reader) (*TNSHeader, error) {
+	// Both header formats are 8 bytes long; read them in full, since they
+	// may arrive in more than one piece.
+	raw := make([]byte, 8)
+	if _, err := io.ReadFull(reader, raw); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, ErrTruncatedPacket
+		}
+		return nil, err
+	}
 	ret := TNSHeader{}
 	ret.mode = driver.Mode
-	next := startReading(reader)
+	next := startReading(getSliceReader(raw))
 	switch driver.Mode {
 	case TNSModeOld:
 		var length uint16
@@ -909,12 +995,57 @@ func ReadTNSRedirect(reader io.Reader, header *TNSHeader) (*TNSRedirect, error)
 // https://docs.oracle.com/cd/B28359_01/server.111/b28310/dba004.htm:
 // major.maintenance.appserver.component.platform. The number of bits allocated
 // to each are respectively 8.4.4.8.8, so 0x01230405 would denote "1.2.3.4.5".
+//
+// Starting with 18c, the components are instead
+// year.update.revision.increment.extension (see
+// https://docs.oracle.com/en/database/oracle/oracle-database/18/upgrd/about-oracle-database-release-numbers.html),
+// and the bits allocated to each are respectively 8.8.4.8.4, so 0x13030000
+// denotes "19.3.0.0.0". The layout is chosen based on the first component.
 type ReleaseVersion uint32
 
+// releaseVersionLayout gives the number of bits allocated to each of the five
+// components of a ReleaseVersion.
+type releaseVersionLayout [5]uint
+
+var (
+	// releaseVersionLayoutOld is the layout used prior to 18c.
+	releaseVersionLayoutOld = releaseVersionLayout{8, 4, 4, 8, 8}
+
+	// releaseVersionLayout18c is the layout used by 18c and later.
+	releaseVersionLayout18c = releaseVersionLayout{8, 8, 4, 8, 4}
+)
+
+// releaseVersionNewFormatMajor is the first major version using the
+// releaseVersionLayout18c layout.
+const releaseVersionNewFormatMajor = 18
+
+// getReleaseVersionLayout returns the layout used for the given major version.
+func getReleaseVersionLayout(major uint32) releaseVersionLayout {
+	if major >= releaseVersionNewFormatMajor {
+		return releaseVersionLayout18c
+	}
+	return releaseVersionLayoutOld
+}
+
+// Components returns the five components of the release version, unpacked
+// using the layout appropriate for its major version.
+func (v ReleaseVersion) Components() [5]uint32 {
+	var ret [5]uint32
+	layout := getReleaseVersionLayout(uint32(v >> 24))
+	shift := uint(32)
+	for i, bits := range layout {
+		shift -= bits
+		ret[i] = (uint32(v) >> shift) & (1<<bits - 1)
+	}
+	return ret
+}
+
 // String returns the dotted-decimal representation of the release version:
-// major.maintenance.appserver.component.platform.
+// major.maintenance.appserver.component.platform (or, for 18c and later,
+// year.update.revision.increment.extension).
 func (v ReleaseVersion) String() string {
-	return fmt.Sprintf("%d.%d.%d.%d.%d", v>>24, v>>20&0x0F, v>>16&0x0F, v>>8&0xFF, v&0xFF)
+	c := v.Components()
+	return fmt.Sprintf("%d.%d.%d.%d.%d", c[0], c[1], c[2], c[3], c[4])
 }
 
 // Bytes returns the big-endian binary encoding of the release version.
@@ -926,31 +1057,41 @@ func (v ReleaseVersion) Bytes() []byte {
 
 // EncodeReleaseVersion gets a ReleaseVersion instance from its dotted-decimal
 // representation, e.g.:
-// EncodeReleaseVersion("64.3.2.1.0") = ReleaseVersion(0x40320100).
+// EncodeReleaseVersion("10.2.0.3.0") = ReleaseVersion(0x0a200300).
+// EncodeReleaseVersion("19.3.0.0.0") = ReleaseVersion(0x13030000).
 func EncodeReleaseVersion(value string) (ReleaseVersion, error) {
 	parts := strings.Split(value, ".")
 	if len(parts) != 5 {
 		return 0, ErrInvalidInput
 	}
 	numbers := make([]uint32, 5)
-	maxValue := []int{
-		255,
-		15,
-		15,
-		255,
-		255,
-	}
 	for i, v := range parts {
 		n, err := strconv.ParseUint(v, 10, 16)
 		if err != nil {
 			return 0, ErrInvalidInput
 		}
-		if int(n) > maxValue[i] {
+		numbers[i] = uint32(n)
+	}
+	if numbers[0] > 0xff {
+		return 0, ErrInvalidInput
+	}
+	layout := getReleaseVersionLayout(numbers[0])
+	ret := uint32(0)
+	for i, bits := range layout {
+		if numbers[i] >= 1<<bits {
 			return 0, ErrInvalidInput
 		}
-		numbers[i] = uint32(n)
+		ret = (ret << bits) | numbers[i]
 	}
-	return ReleaseVersion((numbers[0] << 24) | (numbers[1] << 20) | (numbers[2] << 16) | (numbers[3] << 8) | numbers[4]), nil
+	return ReleaseVersion(ret), nil
+}
+
+// DecodeReleaseVersion gets the dotted-decimal representation of a packed
+// release version number (for example, the VSNNUM in a Refuse packet's
+// descriptor, or the version in a NSN packet), e.g.:
+// DecodeReleaseVersion(0x0a200300) = "10.2.0.3.0".
+func DecodeReleaseVersion(value uint32) string {
+	return ReleaseVersion(value).String()
 }
 
 func encodeReleaseVersion(value string) ReleaseVersion {
@@ -1058,6 +1199,9 @@ func (packet *TNSData) GetType() PacketType {
 // ReadTNSData reads a TNSData packet from the stream, which should point to the
 // first byte after the TNSHeader.
 func ReadTNSData(reader io.Reader, header *TNSHeader) (*TNSData, error) {
+	if header.Length < 8+2 {
+		return nil, ErrInvalidData
+	}
 	ret := new(TNSData)
 	next := startReading(reader)
 	next.read(&ret.DataFlags)
@@ -1225,11 +1369,31 @@ type NSNValue struct {
 	Value []byte
 }
 
+// nsnValueSizes gives the encoded size of the fixed-width value types.
+var nsnValueSizes = map[NSNValueType]int{
+	NSNValueTypeUB1:     1,
+	NSNValueTypeUB2:     2,
+	NSNValueTypeUB4:     4,
+	NSNValueTypeVersion: 4,
+	NSNValueTypeStatus:  2,
+}
+
+// getFriendlyType returns the value's type, or NSNValueTypeBytes if the value
+// is the wrong size for its type (which servers may send), so that it can be
+// safely decoded.
+func (value *NSNValue) getFriendlyType() NSNValueType {
+	if size, ok := nsnValueSizes[value.Type]; ok && len(value.Value) != size {
+		return NSNValueTypeBytes
+	}
+	return value.Type
+}
+
 // String gives the friendly encoding of the sub-packet value; integers are
 // given in decimal, versions in dotted decimal format, binary data as base64,
-// strings as strings.
+// strings as strings. Values that are the wrong size for their type are given
+// as base64.
 func (value *NSNValue) String() string {
-	switch value.Type {
+	switch value.getFriendlyType() {
 	case NSNValueTypeString:
 		return string(value.Value)
 	case NSNValueTypeBytes:
@@ -1258,7 +1422,7 @@ func (value *NSNValue) MarshalJSON() ([]byte, error) {
 	ret := Aux{
 		Type: value.Type,
 	}
-	switch value.Type {
+	switch value.getFriendlyType() {
 	case NSNValueTypeString:
 		ret.Value = string(value.Value)
 	case NSNValueTypeBytes:
@@ -1501,10 +1665,60 @@ type TNSPacketBody interface {
 	Encode() ([]byte, error)
 }
 
+// ChecksumMismatch records a checksum in a received packet that did not match
+// the value computed by the client.
+type ChecksumMismatch struct {
+	// PacketType is the type of the packet containing the bad checksum.
+	PacketType string `json:"packet_type"`
+
+	// Field is the checksum that failed to match ("packet" or "header").
+	Field string `json:"field"`
+
+	// Received is the checksum value sent by the server.
+	Received uint16 `json:"received"`
+
+	// Computed is the checksum value computed by the client.
+	Computed uint16 `json:"computed"`
+}
+
 // TNSPacket is a TNSHeader + a body.
 type TNSPacket struct {
 	Header *TNSHeader
 	Body   TNSPacketBody
+
+	// ChecksumMismatches lists any non-zero checksums in the received packet
+	// that did not match the computed values. Only populated when the
+	// driver's VerifyChecksums is set.
+	ChecksumMismatches []ChecksumMismatch
+}
+
+// verifyChecksums checks any non-zero checksums in the header against the
+// values computed from the raw packet data, and returns a list of the ones that
+// do not match.
+func (driver *TNSDriver) verifyChecksums(header *TNSHeader, body []byte) ([]ChecksumMismatch, error) {
+	encoded, err := header.Encode()
+	if err != nil {
+		return nil, err
+	}
+	packetChecksum, headerChecksum := driver.computeChecksums(encoded, body)
+	ret := []ChecksumMismatch{}
+	if driver.Mode == TNSModeOld && header.PacketChecksum != 0 && header.PacketChecksum != packetChecksum {
+		ret = append(ret, ChecksumMismatch{
+			PacketType: header.Type.String(),
+			Field:      "packet",
+			Received:   header.PacketChecksum,
+			Computed:   packetChecksum,
+		})
+	}
+	if header.HeaderChecksum != 0 && header.HeaderChecksum != headerChecksum {
+		ret = append(ret, ChecksumMismatch{
+			PacketType: header.Type.String(),
+			Field:      "header",
+			Received:   header.HeaderChecksum,
+			Computed:   headerChecksum,
+		})
+	}
+	return ret, nil
 }
 
 // ReadTNSPacket reads a TNSPacket from the stream, or returns nil + an error
@@ -1512,10 +1726,34 @@ type TNSPacket struct {
 func (driver *TNSDriver) ReadTNSPacket(reader io.Reader) (*TNSPacket, error) {
 	var body TNSPacketBody
 	var err error
+	var mismatches []ChecksumMismatch
 	header, err := driver.ReadTNSHeader(reader)
 	if err != nil {
 		return nil, err
 	}
+	if header.Length < 8 {
+		return nil, ErrInvalidData
+	}
+	if header.Length > driver.maxPacketLength() {
+		return nil, ErrPacketTooLarge
+	}
+	// Read the entire body up front, since it may arrive in more than one
+	// piece, and decode it from the buffer, so that the body is never read
+	// past the end of the packet.
+	raw := make([]byte, header.Length-8)
+	if _, err := io.ReadFull(reader, raw); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrTruncatedPacket
+		}
+		return nil, err
+	}
+	if driver.VerifyChecksums {
+		// The checksums cover the raw packet.
+		if mismatches, err = driver.verifyChecksums(header, raw); err != nil {
+			return nil, err
+		}
+	}
+	reader = getSliceReader(raw)
 	switch header.Type {
 	case PacketTypeConnect:
 		body, err = ReadTNSConnect(reader, header)
@@ -1530,9 +1768,14 @@ func (driver *TNSDriver) ReadTNSPacket(reader io.Reader) (*TNSPacket, error) {
 	default:
 		err = ErrInvalidData
 	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// The body's fields run past the end of the packet.
+		err = ErrInvalidData
+	}
 	return &TNSPacket{
-		Header: header,
-		Body:   body,
+		Header:             header,
+		Body:               body,
+		ChecksumMismatches: mismatches,
 	}, err
 }
 
@@ -1639,3 +1882,103 @@ func DecodeDescriptor(descriptor string) (Descriptor, error) {
 	}
 	return ret, nil
 }
+
+// ConnectDescriptorBuilder holds the structured fields used to build a
+// connect descriptor string for the TNSConnect packet.
+type ConnectDescriptorBuilder struct {
+	// Host is the ADDRESS.HOST of the listener. If empty, the ADDRESS is
+	// omitted.
+	Host string
+
+	// Port is the ADDRESS.PORT of the listener.
+	Port uint
+
+	// SID is the CONNECT_DATA.SID of the database instance. At most one of SID
+	// and ServiceName may be set.
+	SID string
+
+	// ServiceName is the CONNECT_DATA.SERVICE_NAME of the database service.
+	// At most one of SID and ServiceName may be set.
+	ServiceName string
+
+	// Program is the optional CONNECT_DATA.CID.PROGRAM value.
+	Program string
+
+	// CIDHost is the optional CONNECT_DATA.CID.HOST value (the client host).
+	CIDHost string
+
+	// User is the optional CONNECT_DATA.CID.USER value.
+	User string
+}
+
+// Build returns the canonical connect descriptor for the builder's fields, e.g.
+// (DESCRIPTION=(ADDRESS=(PROTOCOL=TCP)(HOST=h)(PORT=1521))(CONNECT_DATA=(SERVICE_NAME=s)(CID=(PROGRAM=p)(HOST=c)(USER=u)))).
+// Returns ErrInvalidInput if both SID and ServiceName are set, or if any value
+// contains characters that are special in the descriptor syntax.
+func (builder *ConnectDescriptorBuilder) Build() (string, error) {
+	if builder.SID != "" && builder.ServiceName != "" {
+		return "", ErrInvalidInput
+	}
+	values := []string{builder.Host, builder.SID, builder.ServiceName, builder.Program, builder.CIDHost, builder.User}
+	for _, v := range values {
+		if strings.ContainsAny(v, "()=") {
+			return "", ErrInvalidInput
+		}
+	}
+	ret := "(DESCRIPTION="
+	if builder.Host != "" {
+		ret += fmt.Sprintf("(ADDRESS=(PROTOCOL=TCP)(HOST=%s)(PORT=%d))", builder.Host, builder.Port)
+	}
+	ret += "(CONNECT_DATA="
+	if builder.SID != "" {
+		ret += "(SID=" + builder.SID + ")"
+	}
+	if builder.ServiceName != "" {
+		ret += "(SERVICE_NAME=" + builder.ServiceName + ")"
+	}
+	if builder.Program != "" || builder.CIDHost != "" || builder.User != "" {
+		ret += "(CID="
+		if builder.Program != "" {
+			ret += "(PROGRAM=" + builder.Program + ")"
+		}
+		if builder.CIDHost != "" {
+			ret += "(HOST=" + builder.CIDHost + ")"
+		}
+		if builder.User != "" {
+			ret += "(USER=" + builder.User + ")"
+		}
+		ret += ")"
+	}
+	ret += "))"
+	return ret, nil
+}
+
+const (
+	// MinConnectLayoutVersion and MaxConnectLayoutVersion bound the client
+	// versions whose Connect packet layout is known (see ConnectUnknown3A).
+	MinConnectLayoutVersion uint16 = 0x0138
+	MaxConnectLayoutVersion uint16 = 0x013b
+)
+
+// ConnectUnknown3A returns the Unknown3A that a client of the given version
+// sends in its Connect packet. Clients from version 0x013b send 12 bytes, so
+// that the DataOffset is 0x46; older clients send none, and the connect
+// descriptor starts at 0x3A.
+func ConnectUnknown3A(version uint16) []byte {
+	if version >= 0x013b {
+		return []byte{0x00, 0x00, 0x20, 0x00, 0x00, 0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	}
+	return []byte{}
+}
+
+// SetConnectDescriptor sets the packet's ConnectDescriptor, and recomputes the
+// DataLength and DataOffset fields to match it (and the current Unknown3A).
+func (packet *TNSConnect) SetConnectDescriptor(descriptor string) error {
+	if len(descriptor) > 0xffff || 0x3A+len(packet.Unknown3A) > 0xffff {
+		return ErrInvalidInput
+	}
+	packet.ConnectDescriptor = descriptor
+	packet.DataLength = uint16(len(descriptor))
+	packet.DataOffset = uint16(0x3A + len(packet.Unknown3A))
+	return nil
+}
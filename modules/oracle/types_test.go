@@ -5,6 +5,8 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"testing"
@@ -212,6 +214,62 @@ var validTNSData = map[string]TestCase{
 			},
 		},
 	},
+	"02.NSN.Response": TestCase{
+		Encoding: "00 6d 00 00 06 00 00 00  00 00 de ad be ef 00 63 " + /* .m.............c */
+			"0b 20 02 00 00 04 00 00  04 00 02 00 00 00 00 00 " + /* . .............. */
+			"04 00 05 0b 20 02 00 00  02 00 06 00 00 00 01 00 " + /* .... ........... */
+			"02 00 00 00 00 00 04 00  05 0b 20 02 00 00 02 00 " + /* .......... ..... */
+			"06 fb ff 00 02 00 02 00  00 00 00 00 04 00 05 0b " + /* ................ */
+			"20 02 00 00 01 00 02 00  00 03 00 02 00 00 00 00 " + /*  ............... */
+			"00 04 00 05 0b 20 02 00  00 01 00 02 00 ", /* ..... ....... */
+		Value: &TNSPacket{
+			Header: &TNSHeader{
+				Length:         0x006d,
+				PacketChecksum: 0,
+				Flags:          0,
+				Type:           PacketTypeData,
+				HeaderChecksum: 0,
+			},
+			Body: &TNSData{
+				DataFlags: 0,
+				Data: orPanic((&TNSDataNSN{
+					ID:      DataIDNSN,
+					Version: encodeReleaseVersion("11.2.0.2.0"),
+					Options: NSNOptions(0),
+					Services: []NSNService{
+						NSNService{
+							Type: NSNServiceSupervisor,
+							Values: []NSNValue{
+								*NSNValueVersion("11.2.0.2.0"),
+								*NSNValueStatus(0),
+							},
+						},
+						NSNService{
+							Type: NSNServiceAuthentication,
+							Values: []NSNValue{
+								*NSNValueVersion("11.2.0.2.0"),
+								*NSNValueStatus(0xfbff),
+							},
+						},
+						NSNService{
+							Type: NSNServiceEncryption,
+							Values: []NSNValue{
+								*NSNValueVersion("11.2.0.2.0"),
+								*NSNValueUB1(0),
+							},
+						},
+						NSNService{
+							Type: NSNServiceDataIntegrity,
+							Values: []NSNValue{
+								*NSNValueVersion("11.2.0.2.0"),
+								*NSNValueUB1(0),
+							},
+						},
+					},
+				}).Encode()),
+			},
+		},
+	},
 }
 
 var validTNSConnect = map[string]TestCase{
@@ -585,6 +643,50 @@ func TestTNSData(t *testing.T) {
 	}
 }
 
+func TestTNSDataNSN(t *testing.T) {
+	for tag, info := range validTNSData {
+		data := info.Value.Body.(*TNSData)
+		if data.GetID() != DataIDNSN {
+			continue
+		}
+		nsn, err := DecodeTNSDataNSN(data.Data)
+		if err != nil {
+			t.Fatalf("%s: Error decoding TNSDataNSN: %v", tag, err)
+		}
+		encoded, err := nsn.Encode()
+		if err != nil {
+			t.Fatalf("%s: Error encoding TNSDataNSN: %v", tag, err)
+		}
+		if !bytes.Equal(data.Data, encoded) {
+			t.Errorf("%s: TNSDataNSN mismatch:[\n%s\n]", tag, interleave(data.Data, encoded))
+		}
+	}
+	nsn, err := DecodeTNSDataNSN(validTNSData["02.NSN.Response"].Value.Body.(*TNSData).Data)
+	if err != nil {
+		t.Fatalf("Error decoding NSN response: %v", err)
+	}
+	expected := `[{"type":"Supervisor","values":[{"type":5,"value":"11.2.0.2.0"},{"type":6,"value":0}]},` +
+		`{"type":"Authentication","values":[{"type":5,"value":"11.2.0.2.0"},{"type":6,"value":64511}]},` +
+		`{"type":"Encryption","values":[{"type":5,"value":"11.2.0.2.0"},{"type":2,"value":0}]},` +
+		`{"type":"DataIntegrity","values":[{"type":5,"value":"11.2.0.2.0"},{"type":2,"value":0}]}]`
+	if actual := string(serialize(getNSNServiceLogs(nsn))); actual != expected {
+		t.Errorf("NSN services mismatch: expected %s, got %s", expected, actual)
+	}
+}
+
+func TestNSNValueWrongSize(t *testing.T) {
+	// Values that are too short for their type are treated as bytes.
+	for _, typ := range []NSNValueType{NSNValueTypeUB1, NSNValueTypeUB2, NSNValueTypeUB4, NSNValueTypeVersion, NSNValueTypeStatus} {
+		value := NSNValue{Type: typ, Value: []byte{}}
+		if str := value.String(); str != "" {
+			t.Errorf("%d: expected empty string, got %s", typ, str)
+		}
+		if actual := string(serialize(&value)); actual != fmt.Sprintf(`{"type":%d,"value":""}`, typ) {
+			t.Errorf("%d: unexpected JSON %s", typ, actual)
+		}
+	}
+}
+
 var descriptorValues = map[string]Descriptor{
 	//"()": Descriptor{},
 	"(DESCRIPTION=(ERR=1153)(VSNNUM=186647040)(ERROR_STACK=(ERROR=(CODE=1153)(EMFI=4)(ARGS='()'))(ERROR=(CODE=303)(EMFI=1))))": Descriptor{
@@ -777,7 +879,15 @@ func TestDecodeDescriptor(t *testing.T) {
 var releaseVersions = map[string]ReleaseVersion{
 	"1.2.3.4.5":         ReleaseVersion(0x01230405),
 	"0.0.0.0.0":         ReleaseVersion(0),
-	"255.15.15.255.255": ReleaseVersion(0xFFFFFFFF),
+	"10.2.0.3.0":        ReleaseVersion(0x0a200300),
+	"11.2.0.4.0":        ReleaseVersion(0x0b200400),
+	"12.1.0.2.0":        ReleaseVersion(0x0c100200),
+	"17.15.15.255.255":  ReleaseVersion(0x11FFFFFF),
+	"18.3.0.0.0":        ReleaseVersion(0x12030000),
+	"19.3.0.0.0":        ReleaseVersion(0x13030000),
+	"19.18.0.0.0":       ReleaseVersion(0x13120000),
+	"21.255.15.255.15":  ReleaseVersion(0x15FFFFFF),
+	"255.255.15.255.15": ReleaseVersion(0xFFFFFFFF),
 }
 
 var badReleaseVersions = []string{
@@ -791,6 +901,11 @@ var badReleaseVersions = []string{
 	"0.0.16.0.0",
 	"0.0.0.256.0",
 	"0.0.0.0.256",
+	"17.16.0.0.0",
+	"19.256.0.0.0",
+	"19.0.16.0.0",
+	"19.0.0.256.0",
+	"19.0.0.0.16",
 	"a.b.c.d.e",
 	"A.B.C.D.E",
 	"p.q.r.s.t",
@@ -815,6 +930,9 @@ func TestReleaseVersion(t *testing.T) {
 		if encoded != version {
 			t.Errorf("EncodeReleaseVersion(%s) failed: got 0x%08x, expected 0x%08x", stringValue, uint32(encoded), uint32(version))
 		}
+		if decoded := DecodeReleaseVersion(uint32(encoded)); decoded != stringValue {
+			t.Errorf("DecodeReleaseVersion(0x%08x) failed: got %s, expected %s", uint32(encoded), decoded, stringValue)
+		}
 	}
 	for _, bad := range badReleaseVersions {
 		if ret, err := EncodeReleaseVersion(bad); err == nil {
@@ -822,3 +940,274 @@ func TestReleaseVersion(t *testing.T) {
 		}
 	}
 }
+
+func TestTNSChecksums(t *testing.T) {
+	for _, mode := range []TNSMode{TNSModeOld, TNSMode12c} {
+		driver := &TNSDriver{Mode: mode, ComputeChecksums: true, VerifyChecksums: true}
+		packet := &TNSPacket{Body: &TNSData{DataFlags: DFEOF, Data: []byte("checksummed payload")}}
+		encoded, err := driver.EncodePacket(packet)
+		if err != nil {
+			t.Fatalf("mode %d: Error encoding packet: %v", mode, err)
+		}
+		if packet.Header.HeaderChecksum == 0 {
+			t.Errorf("mode %d: HeaderChecksum not computed", mode)
+		}
+		if mode == TNSModeOld && packet.Header.PacketChecksum == 0 {
+			t.Errorf("mode %d: PacketChecksum not computed", mode)
+		}
+		decoded, err := driver.ReadTNSPacket(getSliceReader(encoded))
+		if err != nil {
+			t.Fatalf("mode %d: Error reading packet: %v", mode, err)
+		}
+		if len(decoded.ChecksumMismatches) != 0 {
+			t.Errorf("mode %d: Unexpected checksum mismatches: %v", mode, decoded.ChecksumMismatches)
+		}
+		if data, ok := decoded.Body.(*TNSData); !ok || string(data.Data) != "checksummed payload" {
+			t.Errorf("mode %d: Read wrong packet: %v", mode, decoded.Body)
+		}
+
+		// Corrupt the payload; in old mode this invalidates the packet checksum.
+		encoded[len(encoded)-1] ^= 0xff
+		// Corrupt the header checksum.
+		encoded[7] ^= 0xff
+		decoded, err = driver.ReadTNSPacket(getSliceReader(encoded))
+		if err != nil {
+			t.Fatalf("mode %d: Error reading corrupted packet: %v", mode, err)
+		}
+		expected := 1
+		if mode == TNSModeOld {
+			expected = 2
+		}
+		if len(decoded.ChecksumMismatches) != expected {
+			t.Errorf("mode %d: Expected %d checksum mismatches, got %v", mode, expected, decoded.ChecksumMismatches)
+		}
+	}
+
+	// Zero checksums are treated as unset, and never reported.
+	driver := &TNSDriver{Mode: TNSModeOld, VerifyChecksums: true}
+	decoded, err := driver.ReadTNSPacket(getSliceReader(fromHex(validTNSData["00.trivial"].Encoding)))
+	if err != nil {
+		t.Fatalf("Error reading packet: %v", err)
+	}
+	if len(decoded.ChecksumMismatches) != 0 {
+		t.Errorf("Unexpected checksum mismatches for unset checksums: %v", decoded.ChecksumMismatches)
+	}
+}
+
+var connectDescriptorBuilders = map[string]ConnectDescriptorBuilder{
+	"(DESCRIPTION=(CONNECT_DATA=(CID=(PROGRAM=zgrab2))))": ConnectDescriptorBuilder{
+		Program: "zgrab2",
+	},
+	"(DESCRIPTION=(ADDRESS=(PROTOCOL=TCP)(HOST=10.0.72.113)(PORT=1521))(CONNECT_DATA=(SID=orcl11g)(CID=(PROGRAM=sqlplus@kali)(HOST=kali)(USER=root))))": ConnectDescriptorBuilder{
+		Host:    "10.0.72.113",
+		Port:    1521,
+		SID:     "orcl11g",
+		Program: "sqlplus@kali",
+		CIDHost: "kali",
+		User:    "root",
+	},
+	"(DESCRIPTION=(ADDRESS=(PROTOCOL=TCP)(HOST=db.example.com)(PORT=1522))(CONNECT_DATA=(SERVICE_NAME=ckdb)))": ConnectDescriptorBuilder{
+		Host:        "db.example.com",
+		Port:        1522,
+		ServiceName: "ckdb",
+	},
+	// Long enough that the descriptor is sent after the packet body.
+	"(DESCRIPTION=(ADDRESS=(PROTOCOL=TCP)(HOST=a-very-long-host-name.with.several.subdomains.example.com)(PORT=1521))(CONNECT_DATA=(SERVICE_NAME=a_very_long_service_name.example.com)(CID=(PROGRAM=C:\\oracle\\product\\12.1.0\\bin\\sqlplus.exe)(HOST=WORKSTATION-0001)(USER=Administrator))))": ConnectDescriptorBuilder{
+		Host:        "a-very-long-host-name.with.several.subdomains.example.com",
+		Port:        1521,
+		ServiceName: "a_very_long_service_name.example.com",
+		Program:     "C:\\oracle\\product\\12.1.0\\bin\\sqlplus.exe",
+		CIDHost:     "WORKSTATION-0001",
+		User:        "Administrator",
+	},
+}
+
+var badConnectDescriptorBuilders = []ConnectDescriptorBuilder{
+	ConnectDescriptorBuilder{SID: "sid", ServiceName: "service"},
+	ConnectDescriptorBuilder{Host: "bad(host)", Port: 1521},
+	ConnectDescriptorBuilder{User: "user=root"},
+}
+
+func TestConnectDescriptorBuilder(t *testing.T) {
+	driver := getTNSDriver()
+	for expected, builder := range connectDescriptorBuilders {
+		descriptor, err := builder.Build()
+		if err != nil {
+			t.Fatalf("Build(%v) failed: %v", builder, err)
+		}
+		if descriptor != expected {
+			t.Errorf("Build mismatch: expected %s, got %s", expected, descriptor)
+		}
+		parsed, err := DecodeDescriptor(descriptor)
+		if err != nil {
+			t.Fatalf("Failed to parse built descriptor [[%s]]: %v", descriptor, err)
+		}
+		if builder.ServiceName != "" {
+			if v, _ := parsed.GetValue("DESCRIPTION.CONNECT_DATA.SERVICE_NAME"); v != builder.ServiceName {
+				t.Errorf("%s: SERVICE_NAME mismatch: expected %s, got %s", descriptor, builder.ServiceName, v)
+			}
+		}
+		if builder.SID != "" {
+			if v, _ := parsed.GetValue("DESCRIPTION.CONNECT_DATA.SID"); v != builder.SID {
+				t.Errorf("%s: SID mismatch: expected %s, got %s", descriptor, builder.SID, v)
+			}
+		}
+
+		connect := &TNSConnect{
+			Version:    0x013a,
+			MinVersion: 0x012c,
+			ByteOrder:  defaultByteOrder,
+			Unknown3A:  []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		}
+		if err := connect.SetConnectDescriptor(descriptor); err != nil {
+			t.Fatalf("SetConnectDescriptor(%s) failed: %v", descriptor, err)
+		}
+		if connect.DataLength != uint16(len(descriptor)) || connect.DataOffset != 0x3A+12 {
+			t.Errorf("%s: bad DataLength / DataOffset: 0x%04x / 0x%04x", descriptor, connect.DataLength, connect.DataOffset)
+		}
+		encoded, err := driver.EncodePacket(&TNSPacket{Body: connect})
+		if err != nil {
+			t.Fatalf("%s: Error encoding packet: %v", descriptor, err)
+		}
+		reader := getSliceReader(encoded)
+		response, err := driver.ReadTNSPacket(reader)
+		if err != nil {
+			t.Fatalf("%s: Error reading packet: %v", descriptor, err)
+		}
+		decoded, ok := response.Body.(*TNSConnect)
+		if !ok {
+			t.Fatalf("%s: Read wrong packet: %v", descriptor, response.Body)
+		}
+		jsonPacket := serialize(connect)
+		jsonDecoded := serialize(decoded)
+		if !bytes.Equal(jsonPacket, jsonDecoded) {
+			t.Errorf("%s: TNSConnect round trip mismatch:[\n%s\n]", descriptor, interleave(jsonPacket, jsonDecoded))
+		}
+		if len(reader.Data) > 0 {
+			t.Errorf("%s: %d bytes left over", descriptor, len(reader.Data))
+		}
+	}
+	for _, bad := range badConnectDescriptorBuilders {
+		if ret, err := bad.Build(); err == nil {
+			t.Errorf("Successfully built bad descriptor %v: %s", bad, ret)
+		}
+	}
+}
+
+func TestConnectUnknown3A(t *testing.T) {
+	for tag, info := range validTNSConnect {
+		expected := info.Value.Body.(*TNSConnect)
+		connect := &TNSConnect{
+			Version:   expected.Version,
+			Unknown3A: ConnectUnknown3A(expected.Version),
+		}
+		if err := connect.SetConnectDescriptor(expected.ConnectDescriptor); err != nil {
+			t.Fatalf("%s: SetConnectDescriptor failed: %v", tag, err)
+		}
+		if connect.DataOffset != expected.DataOffset || connect.DataLength != expected.DataLength {
+			t.Errorf("%s: version 0x%04x: expected DataOffset / DataLength 0x%04x / 0x%04x, got 0x%04x / 0x%04x", tag, expected.Version, expected.DataOffset, expected.DataLength, connect.DataOffset, connect.DataLength)
+		}
+		// The "unknown3a" vector has arbitrary bytes in place of the padding.
+		if tag != "unknown3a" && !bytes.Equal(connect.Unknown3A, expected.Unknown3A) {
+			t.Errorf("%s: version 0x%04x: expected Unknown3A %x, got %x", tag, expected.Version, expected.Unknown3A, connect.Unknown3A)
+		}
+	}
+	// Only the versions with a known layout are accepted by --tns-version.
+	flags := Flags{
+		ReleaseVersion:         "11.2.0.4.0",
+		GlobalServiceOptions:   "0x0C41",
+		ProtocolCharacterisics: "0x7F08",
+		ConnectFlags:           "0x4141",
+		SDU:                    "0x2000",
+		TDU:                    "0xFFFF",
+	}
+	for version := MinConnectLayoutVersion; version <= MaxConnectLayoutVersion; version++ {
+		flags.TNSVersion = fmt.Sprintf("0x%x", version)
+		if err := flags.Validate(nil); err != nil {
+			t.Errorf("--tns-version %s rejected: %v", flags.TNSVersion, err)
+		}
+	}
+	for _, version := range []string{"0x137", "0x13c", "banana"} {
+		flags.TNSVersion = version
+		if flags.Validate(nil) == nil {
+			t.Errorf("--tns-version %s accepted", version)
+		}
+	}
+}
+
+// oneByteReader returns the data from a slice one byte at a time, as a slow
+// server might.
+type oneByteReader struct {
+	data []byte
+}
+
+func (reader *oneByteReader) Read(output []byte) (int, error) {
+	if len(reader.data) == 0 {
+		return 0, io.EOF
+	}
+	if len(output) == 0 {
+		return 0, nil
+	}
+	output[0] = reader.data[0]
+	reader.data = reader.data[1:]
+	return 1, nil
+}
+
+func TestReadTNSPacketPartialReads(t *testing.T) {
+	driver := getTNSDriver()
+	for tag, info := range validTNSData {
+		bin := fromHex(info.Encoding)
+		reader := &oneByteReader{data: bin}
+		response, err := driver.ReadTNSPacket(reader)
+		if err != nil {
+			t.Fatalf("%s: Error reading packet one byte at a time: %v", tag, err)
+		}
+		jsonPacket := serialize(info.Value.Body)
+		jsonDecoded := serialize(response.Body)
+		if !bytes.Equal(jsonPacket, jsonDecoded) {
+			t.Errorf("%s: partial read mismatch:[\n%s\n]", tag, interleave(jsonPacket, jsonDecoded))
+		}
+		if len(reader.data) > 0 {
+			t.Errorf("%s: %d bytes left over", tag, len(reader.data))
+		}
+
+		// A packet cut short in its header or its body is an error.
+		for _, n := range []int{4, len(bin) - 1} {
+			_, err := driver.ReadTNSPacket(&oneByteReader{data: bin[:n]})
+			if err != ErrTruncatedPacket {
+				t.Errorf("%s: expected ErrTruncatedPacket reading %d of %d bytes, got %v", tag, n, len(bin), err)
+			}
+		}
+	}
+
+	// A Refuse packet whose DataLength runs past the end of the packet is
+	// invalid.
+	refuse := fromHex("00 0c 00 00 04 00 00 00 22 00 00 10")
+	if _, err := driver.ReadTNSPacket(&oneByteReader{data: append(refuse, make([]byte, 16)...)}); err != ErrInvalidData {
+		t.Errorf("expected ErrInvalidData for an overlong Refuse, got %v", err)
+	}
+}
+
+func TestReadTNSPacketTooLarge(t *testing.T) {
+	// A 12c header claiming a (nearly) 4GB packet is rejected before any of
+	// the body is read.
+	driver := &TNSDriver{Mode: TNSMode12c}
+	reader := &oneByteReader{data: fromHex("ff ff ff ff 06 00 00 00 00 00")}
+	if _, err := driver.ReadTNSPacket(reader); err != ErrPacketTooLarge {
+		t.Errorf("expected ErrPacketTooLarge, got %v", err)
+	}
+	if len(reader.data) != 2 {
+		t.Errorf("expected only the header to be read, %d bytes left over", len(reader.data))
+	}
+
+	// The maximum is configurable.
+	driver = &TNSDriver{Mode: TNSModeOld, MaxPacketLength: 16}
+	packet := fromHex("00 14 00 00 06 00 00 00 00 00 01 02 03 04 05 06 07 08 09 0a")
+	if _, err := driver.ReadTNSPacket(getSliceReader(packet)); err != ErrPacketTooLarge {
+		t.Errorf("expected ErrPacketTooLarge for a 20-byte packet, got %v", err)
+	}
+	driver.MaxPacketLength = 20
+	if _, err := driver.ReadTNSPacket(getSliceReader(packet)); err != nil {
+		t.Errorf("expected a 20-byte packet to be read, got %v", err)
+	}
+}
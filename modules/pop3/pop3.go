@@ -7,6 +7,7 @@ import (
 	"net"
 	"regexp"
 	"io"
+	"strings"
 
 	"github.com/zmap/zgrab2"
 )
@@ -40,3 +41,25 @@ func (conn *Connection) SendCommand(cmd string) (string, error) {
 	}
 	return conn.ReadResponse()
 }
+
+// SendMultiLineCommand sends a command whose successful response is
+// multi-line (e.g. CAPA), then reads up to the terminating ".", which may
+// arrive after the first line has been read.
+func (conn *Connection) SendMultiLineCommand(cmd string) (string, error) {
+	ret, err := conn.SendCommand(cmd)
+	if err != nil {
+		return "", err
+	}
+	for strings.HasPrefix(ret, "+") && !strings.HasSuffix(ret, "\r\n.\r\n") {
+		more, err := conn.ReadResponse()
+		if err != nil {
+			return ret, err
+		}
+		if more == "" {
+			// Timed out or closed; return what there is.
+			break
+		}
+		ret += more
+	}
+	return ret, nil
+}
@@ -5,6 +5,9 @@
 // The --send-help and --send-noop flags tell the scanner to send a
 // HELP or NOOP command and read the response.
 //
+// The --send-capa flag tells the scanner to send a CAPA command (after
+// STLS, if it is sent), and read the list of capabilities.
+//
 // The --pop3s flag tells the scanner to perform a TLS handshake
 // immediately after connecting, before even attempting to read
 // the banner.
@@ -26,6 +29,7 @@
 package pop3
 
 import (
+	"context"
 	"fmt"
 	"errors"
 	"strings"
@@ -45,6 +49,13 @@ type ScanResults struct {
 	// HELP is the server's response to the HELP command, if it is sent.
 	HELP string `json:"help,omitempty"`
 
+	// CAPA is the server's response to the CAPA command, if it is sent.
+	CAPA string `json:"capa,omitempty"`
+
+	// AuthMechanisms are the SASL mechanisms advertised in the server's
+	// response to the CAPA command.
+	AuthMechanisms []string `json:"auth_mechanisms,omitempty"`
+
 	// StartTLS is the server's response to the STARTTLS command, if it is sent.
 	StartTLS string `json:"starttls,omitempty"`
 
@@ -67,8 +78,11 @@ type Flags struct {
 	// SendNOOP indicates that the NOOP command should be sent.
 	SendNOOP bool `long:"send-noop" description:"Send the NOOP command before closing."`
 
+	// SendCAPA indicates that the CAPA command should be sent.
+	SendCAPA bool `long:"send-capa" description:"Send the CAPA command"`
+
 	// SendQUIT indicates that the QUIT command should be sent.
-	SendQUIT bool `long:"send-quit" description:"Send the QUIT command before closing."`
+	SendQUIT bool `long:"send-quit" description:"Send the QUIT command before closing (always done with --close-graceful)."`
 
 	// POP3Secure indicates that the client should do a TLS handshake immediately after connecting.
 	POP3Secure bool `long:"pop3s" description:"Immediately negotiate a TLS connection"`
@@ -163,6 +177,19 @@ func getPOP3Error(response string) error {
 	return fmt.Errorf("POP3 error: %s", response[1:])
 }
 
+// getAuthMechanisms returns the SASL mechanisms listed in the SASL
+// capability of a CAPA response.
+func getAuthMechanisms(capa string) []string {
+	var names []string
+	for _, line := range strings.Split(capa, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && strings.EqualFold(fields[0], "SASL") {
+			names = append(names, fields[1:]...)
+		}
+	}
+	return zgrab2.SASLMechanisms(names)
+}
+
 // Check the contents of the POP3 header and return a relevant ScanStatus
 func VerifyPOP3Contents(banner string) zgrab2.ScanStatus {
 	lowerBanner := strings.ToLower(banner)
@@ -193,13 +220,15 @@ func VerifyPOP3Contents(banner string) zgrab2.ScanStatus {
 // 5. If --send-noop is sent, send NOOP, read the result.
 // 6. If --starttls is sent, send STLS, read the result, negotiate a
 //    TLS connection using the command-line flags.
-// 7. If --send-quit is sent, send QUIT and read the result.
-// 8. Close the connection.
-func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+// 7. If --send-capa is sent, send CAPA, read the result.
+// 8. If --send-quit is sent, send QUIT and read the result.
+// 9. Close the connection.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
 	c, err := target.Open(&scanner.config.BaseFlags)
 	if err != nil {
 		return zgrab2.TryGetScanStatus(err), nil, err
 	}
+	zgrab2.EnableTLSDetection(c)
 	defer c.Close()
 	result := &ScanResults{}
 	if scanner.config.POP3Secure {
@@ -258,7 +287,15 @@ func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, inter
 		}
 		conn.Conn = tlsConn
 	}
-	if scanner.config.SendQUIT {
+	if scanner.config.SendCAPA {
+		ret, err := conn.SendMultiLineCommand("CAPA")
+		if err != nil {
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+		result.CAPA = ret
+		result.AuthMechanisms = getAuthMechanisms(ret)
+	}
+	if scanner.config.SendQUIT || zgrab2.CloseGracefully() {
 		ret, err := conn.SendCommand("QUIT")
 		if err != nil {
 			if err != nil {
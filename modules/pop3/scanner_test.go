@@ -0,0 +1,46 @@
+package pop3
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetAuthMechanisms(t *testing.T) {
+	testTable := map[string]struct {
+		CAPA     string
+		Expected []string
+	}{
+		"dovecot": {
+			CAPA:     "+OK\r\nCAPA\r\nTOP\r\nUIDL\r\nRESP-CODES\r\nPIPELINING\r\nAUTH-RESP-CODE\r\nSTLS\r\nUSER\r\nSASL PLAIN LOGIN\r\n.\r\n",
+			Expected: []string{"PLAIN", "LOGIN"},
+		},
+		"gmail": {
+			CAPA:     "+OK Capability list follows\r\nUSER\r\nRESP-CODES\r\nEXPIRE 0\r\nLOGIN-DELAY 300\r\nTOP\r\nUIDL\r\nX-GOOGLE-RICO\r\nSASL PLAIN XOAUTH2 OAUTHBEARER\r\n.\r\n",
+			Expected: []string{"PLAIN", "XOAUTH2", "OAUTHBEARER"},
+		},
+		"lower case": {
+			CAPA:     "+OK\r\nsasl cram-md5 digest-md5\r\n.\r\n",
+			Expected: []string{"CRAM-MD5", "DIGEST-MD5"},
+		},
+		"duplicates and invalid names": {
+			CAPA:     "+OK\r\nSASL PLAIN plain BAD/NAME\r\nSASL LOGIN\r\n.\r\n",
+			Expected: []string{"PLAIN", "LOGIN"},
+		},
+		"empty sasl": {
+			CAPA:     "+OK\r\nUSER\r\nSASL\r\n.\r\n",
+			Expected: nil,
+		},
+		"no sasl": {
+			CAPA:     "+OK\r\nTOP\r\nUSER\r\nUIDL\r\n.\r\n",
+			Expected: nil,
+		},
+	}
+
+	for name, test := range testTable {
+		t.Run(name, func(t *testing.T) {
+			if got := getAuthMechanisms(test.CAPA); !reflect.DeepEqual(got, test.Expected) {
+				t.Errorf("received unexpected mechanisms: %v, wanted: %v", got, test.Expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	// ProductPostgres identifies a PostgreSQL server.
+	ProductPostgres = "PostgreSQL"
+
+	// ProductCockroachDB identifies a CockroachDB server, which speaks the
+	// Postgres wire protocol.
+	ProductCockroachDB = "CockroachDB"
+)
+
+// postgresVersionRegex matches the leading version number in a PostgreSQL
+// server_version, e.g. "12.3" in "12.3 (Debian 12.3-1.pgdg100+1)" or "9.6.18".
+var postgresVersionRegex = regexp.MustCompile(`^\d+(\.\d+)*`)
+
+// crdbVersionRegex matches the version in a CockroachDB version string, e.g.
+// "v20.2.7" in "CockroachDB CCL v20.2.7 (x86_64-unknown-linux-gnu, ...)".
+var crdbVersionRegex = regexp.MustCompile(`\bv(\d+\.\d+(\.\d+)?(-[0-9A-Za-z.-]+)?)`)
+
+// parseCockroachVersion returns the version in s and true if s is a
+// CockroachDB version string; the version is "" if it cannot be parsed.
+func parseCockroachVersion(s string) (string, bool) {
+	if !strings.Contains(s, "CockroachDB") {
+		return "", false
+	}
+	if match := crdbVersionRegex.FindStringSubmatch(s); match != nil {
+		return match[1], true
+	}
+	return "", true
+}
+
+// isCockroachError returns true if err looks like it was sent by CockroachDB
+// rather than PostgreSQL. PostgreSQL reports the C source file of the error
+// (e.g. "postmaster.c"), while CockroachDB reports a Go source file.
+func isCockroachError(err *PostgresError) bool {
+	return err != nil && strings.HasSuffix((*err)["file"], ".go")
+}
+
+// identifyProduct fills in the ServerVersion, Product and ProductVersion
+// from the server_version (and crdb_version) ParameterStatus values. If the
+// server never sent a ParameterStatus -- e.g. it sent an ErrorResponse
+// instead -- the product is inferred from the errors, without a version.
+func (results *Results) identifyProduct() {
+	if results.ServerParameters != nil {
+		params := *results.ServerParameters
+		results.ServerVersion = params["server_version"]
+		// Older CockroachDB releases put their own version in server_version;
+		// newer ones report a Postgres version there and use crdb_version.
+		for _, key := range []string{"server_version", "crdb_version"} {
+			if version, ok := parseCockroachVersion(params[key]); ok {
+				results.Product = ProductCockroachDB
+				results.ProductVersion = version
+				return
+			}
+		}
+		if results.ServerVersion != "" {
+			results.Product = ProductPostgres
+			results.ProductVersion = postgresVersionRegex.FindString(results.ServerVersion)
+			return
+		}
+	}
+	errors := []*PostgresError{results.UserStartupError, results.StartupError, results.ProtocolError}
+	for _, err := range errors {
+		if isCockroachError(err) {
+			results.Product = ProductCockroachDB
+			return
+		}
+	}
+	for _, err := range errors {
+		if err != nil && (*err)["file"] != "" {
+			results.Product = ProductPostgres
+			return
+		}
+	}
+}
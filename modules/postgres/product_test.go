@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"testing"
+)
+
+func TestIdentifyProduct(t *testing.T) {
+	tests := []struct {
+		name           string
+		results        Results
+		product        string
+		productVersion string
+	}{
+		{
+			name:           "postgres",
+			results:        Results{ServerParameters: &ServerParameters{"server_version": "12.3 (Debian 12.3-1.pgdg100+1)"}},
+			product:        ProductPostgres,
+			productVersion: "12.3",
+		},
+		{
+			name: "old cockroach",
+			results: Results{ServerParameters: &ServerParameters{
+				"server_version": "CockroachDB CCL v1.1.9 (linux amd64, built 2018/05/15 13:35:13, go1.8.3)",
+			}},
+			product:        ProductCockroachDB,
+			productVersion: "1.1.9",
+		},
+		{
+			name: "new cockroach",
+			results: Results{ServerParameters: &ServerParameters{
+				"server_version": "13.0.0",
+				"crdb_version":   "CockroachDB CCL v21.1.0-beta.3 (x86_64-unknown-linux-gnu, built 2021/04/12 17:53:13, go1.15.5)",
+			}},
+			product:        ProductCockroachDB,
+			productVersion: "21.1.0-beta.3",
+		},
+		{
+			name: "error before parameters",
+			results: Results{
+				StartupError:     &PostgresError{"code": "08P01", "message": "no username specified", "file": "postmaster.c", "line": "2106"},
+				UserStartupError: &PostgresError{"code": "28P01", "message": "password authentication failed"},
+			},
+			product: ProductPostgres,
+		},
+		{
+			name: "cockroach error",
+			results: Results{
+				StartupError: &PostgresError{"code": "28000", "message": "no username specified", "file": "server.go", "line": "543"},
+			},
+			product: ProductCockroachDB,
+		},
+		{
+			name:    "unknown",
+			results: Results{SupportedVersions: "FATAL: unsupported frontend protocol"},
+		},
+	}
+	for _, test := range tests {
+		test.results.identifyProduct()
+		if test.results.Product != test.product || test.results.ProductVersion != test.productVersion {
+			t.Errorf("%s: expected %q %q, got %q %q", test.name, test.product, test.productVersion,
+				test.results.Product, test.results.ProductVersion)
+		}
+	}
+}
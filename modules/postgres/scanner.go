@@ -10,6 +10,7 @@
 package postgres
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"net"
@@ -77,6 +78,17 @@ type Results struct {
 	// TransactionStatus is the value of the 'Z'-type packet returned by
 	// the server after the final StartupMessage.
 	TransactionStatus string `json:"transaction_status,omitempty"`
+
+	// ServerVersion is the raw server_version ParameterStatus value.
+	ServerVersion string `json:"server_version,omitempty"`
+
+	// Product is the server implementation, "PostgreSQL" or "CockroachDB",
+	// inferred from the server parameters or, failing that, the errors.
+	Product string `json:"product,omitempty"`
+
+	// ProductVersion is the version of the Product, e.g. "12.3" or
+	// "20.2.7", if known.
+	ProductVersion string `json:"product_version,omitempty"`
 }
 
 // PostgresError is parsed the payload of an 'E'-type packet, mapping
@@ -392,8 +404,10 @@ func (s *Scanner) getDefaultKVPs() map[string]string {
 //
 // * NOTE: TLS is only used for the first connection, and then only if
 //   both client and server support it.
-func (s *Scanner) Scan(t zgrab2.ScanTarget) (status zgrab2.ScanStatus, result interface{}, thrown error) {
+func (s *Scanner) Scan(ctx context.Context, t zgrab2.ScanTarget) (status zgrab2.ScanStatus, result interface{}, thrown error) {
 	var results Results
+	// Identify the product from whatever was collected, however the scan ends.
+	defer results.identifyProduct()
 
 	mgr := newConnectionManager()
 	defer mgr.cleanUp()
@@ -0,0 +1,170 @@
+package prometheus
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+)
+
+// BuildInfo holds the labels of a *_build_info series, which Prometheus,
+// node_exporter and most other exporters use to report their version.
+type BuildInfo struct {
+	// Metric is the name of the series, e.g. "prometheus_build_info".
+	Metric string `json:"metric"`
+
+	// Labels are the series' labels, e.g. version, revision and goversion.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Metrics holds the well-known series extracted from a /metrics response.
+type Metrics struct {
+	// Samples is the number of samples in the response.
+	Samples int `json:"samples"`
+
+	// ProcessStartTime is the value of process_start_time_seconds, as a
+	// Unix timestamp.
+	ProcessStartTime float64 `json:"process_start_time_seconds,omitempty"`
+
+	// GoVersion is the version label of go_info, e.g. "go1.21.4".
+	GoVersion string `json:"go_version,omitempty"`
+
+	// BuildInfo lists the *_build_info series.
+	BuildInfo []BuildInfo `json:"build_info,omitempty"`
+}
+
+// sample is a single line of the text exposition format.
+type sample struct {
+	name   string
+	labels map[string]string
+	value  string
+}
+
+// parseSample parses a sample line of the form
+// name{label="value",...} value [timestamp]. It returns false if the line
+// is not a sample.
+func parseSample(line string) (*sample, bool) {
+	i := strings.IndexAny(line, "{ \t")
+	if i <= 0 {
+		return nil, false
+	}
+	ret := &sample{name: line[:i]}
+	if !isMetricName(ret.name) {
+		return nil, false
+	}
+	rest := line[i:]
+	if rest[0] == '{' {
+		labels, n, ok := parseLabels(rest)
+		if !ok {
+			return nil, false
+		}
+		ret.labels = labels
+		rest = rest[n:]
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, false
+	}
+	// Values are floats, including NaN and +Inf.
+	if _, err := strconv.ParseFloat(fields[0], 64); err != nil {
+		return nil, false
+	}
+	ret.value = fields[0]
+	return ret, true
+}
+
+// parseLabels parses the {label="value",...} at the start of s, returning
+// the labels and the length of the label set.
+func parseLabels(s string) (map[string]string, int, bool) {
+	labels := make(map[string]string)
+	i := 1
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == ',') {
+			i++
+		}
+		if i >= len(s) {
+			return nil, 0, false
+		}
+		if s[i] == '}' {
+			return labels, i + 1, true
+		}
+		eq := strings.IndexByte(s[i:], '=')
+		if eq <= 0 || i+eq+1 >= len(s) || s[i+eq+1] != '"' {
+			return nil, 0, false
+		}
+		name := strings.TrimSpace(s[i : i+eq])
+		i += eq + 2
+		var value strings.Builder
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+				continue
+			}
+			value.WriteByte(s[i])
+		}
+		if i >= len(s) {
+			return nil, 0, false
+		}
+		labels[name] = value.String()
+		i++
+	}
+}
+
+// isMetricName checks that name matches [a-zA-Z_:][a-zA-Z0-9_:]*.
+func isMetricName(name string) bool {
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == ':':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
+
+// parseMetrics parses a /metrics response in the text exposition format,
+// extracting the well-known series. It returns nil if the body is not in
+// that format.
+func parseMetrics(body string) *Metrics {
+	ret := &Metrics{}
+	comments := 0
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	scanner.Buffer(nil, len(body)+1)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "# HELP ") || strings.HasPrefix(line, "# TYPE ") {
+			comments++
+			continue
+		}
+		if line[0] == '#' {
+			continue
+		}
+		s, ok := parseSample(line)
+		if !ok {
+			// The body may have been truncated mid-line.
+			continue
+		}
+		ret.Samples++
+		switch {
+		case s.name == "process_start_time_seconds":
+			ret.ProcessStartTime, _ = strconv.ParseFloat(s.value, 64)
+		case s.name == "go_info":
+			ret.GoVersion = s.labels["version"]
+		case strings.HasSuffix(s.name, "_build_info"):
+			ret.BuildInfo = append(ret.BuildInfo, BuildInfo{Metric: s.name, Labels: s.labels})
+		}
+	}
+	if comments == 0 && ret.Samples == 0 {
+		return nil
+	}
+	return ret
+}
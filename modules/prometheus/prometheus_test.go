@@ -0,0 +1,119 @@
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/httpapi/httpapitest"
+)
+
+const nodeExporterMetrics = `# HELP go_info Information about the Go environment.
+# TYPE go_info gauge
+go_info{version="go1.21.4"} 1
+# HELP node_exporter_build_info A metric with a constant '1' value labeled by version, revision, branch, goversion from which node_exporter was built, and the goos and goarch for the build.
+# TYPE node_exporter_build_info gauge
+node_exporter_build_info{branch="HEAD",goarch="amd64",goos="linux",goversion="go1.21.4",revision="7333465abf9efba81876303bb57e6fadb946041b",tags="netgo osusergo static_build",version="1.7.0"} 1
+# HELP node_load1 1m load average.
+# TYPE node_load1 gauge
+node_load1 0.21
+# HELP process_start_time_seconds Start time of the process since unix epoch in seconds.
+# TYPE process_start_time_seconds gauge
+process_start_time_seconds 1.70121934512e+09
+`
+
+// scanTestServer runs the scanner against a local server using handler.
+func scanTestServer(t *testing.T, handler http.HandlerFunc) (status zgrab2.ScanStatus, results *ScanResults, err error) {
+	scanner := &Scanner{config: &Flags{MaxSize: 256, UserAgent: "zgrab2"}}
+	scanner.config.Timeout = 5 * time.Second
+	httpapitest.ServeTarget(t, handler, func(target *zgrab2.ScanTarget) {
+		var result interface{}
+		status, result, err = scanner.Scan(context.Background(), *target)
+		results, _ = result.(*ScanResults)
+	})
+	return status, results, err
+}
+
+func TestScanNodeExporter(t *testing.T) {
+	status, result, err := scanTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metrics":
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+			w.Write([]byte(nodeExporterMetrics))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	if !result.MetricsExposed || result.AuthRequired || result.Healthy || result.MetricsSize != int64(len(nodeExporterMetrics)) || result.MetricsTruncated {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	expected := &Metrics{
+		Samples:          4,
+		ProcessStartTime: 1701219345.12,
+		GoVersion:        "go1.21.4",
+		BuildInfo: []BuildInfo{{
+			Metric: "node_exporter_build_info",
+			Labels: map[string]string{
+				"branch":    "HEAD",
+				"goarch":    "amd64",
+				"goos":      "linux",
+				"goversion": "go1.21.4",
+				"revision":  "7333465abf9efba81876303bb57e6fadb946041b",
+				"tags":      "netgo osusergo static_build",
+				"version":   "1.7.0",
+			},
+		}},
+	}
+	if !reflect.DeepEqual(result.Metrics, expected) {
+		t.Errorf("expected metrics %+v, got %+v", expected, result.Metrics)
+	}
+}
+
+func TestScanPrometheusAuthRequired(t *testing.T) {
+	status, result, err := scanTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metrics":
+			w.Header().Set("WWW-Authenticate", `Basic realm="Prometheus"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/-/healthy":
+			w.Write([]byte("Prometheus Server is Healthy.\n"))
+		}
+	})
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	if !result.AuthRequired || result.MetricsExposed || !result.Healthy || result.Metrics != nil {
+		t.Errorf("expected auth required, got %+v", result)
+	}
+}
+
+func TestScanNotPrometheus(t *testing.T) {
+	status, _, err := scanTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html></html>"))
+	})
+	if status != zgrab2.SCAN_PROTOCOL_ERROR || err != ErrNotPrometheus {
+		t.Errorf("expected protocol error, got %s: %v", status, err)
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	s, ok := parseSample(`http_requests_total{handler="/api/v1/query",msg="a \"quoted\"\nvalue"} 1027 1395066363000`)
+	if !ok {
+		t.Fatal("expected a sample")
+	}
+	expected := map[string]string{"handler": "/api/v1/query", "msg": "a \"quoted\"\nvalue"}
+	if s.name != "http_requests_total" || s.value != "1027" || !reflect.DeepEqual(s.labels, expected) {
+		t.Errorf("unexpected sample %+v", s)
+	}
+	for _, line := range []string{`<html>`, `foo{bar="baz"`, `foo bar`, `9foo 1`} {
+		if _, ok := parseSample(line); ok {
+			t.Errorf("expected %q not to be a sample", line)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Package prometheus provides a zgrab2 module that probes for Prometheus
+// servers and exporters (e.g. node_exporter) exposing their metrics.
+// Default Port: 9090 (TCP); node_exporter listens on 9100.
+//
+// The scanner sends GET /metrics, recording whether the metrics are exposed
+// without credentials, the size of the payload, and a few well-known series:
+// process_start_time_seconds, the go_info version and the *_build_info
+// labels. It then sends GET /-/healthy, Prometheus' health check.
+//
+// The --use-tls flag tells the scanner to connect over TLS, using the
+// standard TLS flags.
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/http"
+	"github.com/zmap/zgrab2/lib/httpapi"
+)
+
+// ErrNotPrometheus is returned when neither /metrics nor /-/healthy look like
+// they came from Prometheus or an exporter.
+var ErrNotPrometheus = errors.New("server does not expose Prometheus metrics")
+
+// acceptMetrics asks for the text exposition format, rather than protobuf or
+// OpenMetrics.
+const acceptMetrics = "text/plain;version=0.0.4;q=1,*/*;q=0.1"
+
+// ScanResults instances are returned by the module's Scan function.
+type ScanResults struct {
+	// MetricsResponse is the HTTP response to GET /metrics.
+	MetricsResponse *http.Response `json:"metrics_response,omitempty" zgrab:"debug"`
+
+	// HealthyResponse is the HTTP response to GET /-/healthy.
+	HealthyResponse *http.Response `json:"healthy_response,omitempty" zgrab:"debug"`
+
+	// MetricsExposed is true if /metrics returned metrics without
+	// credentials.
+	MetricsExposed bool `json:"metrics_exposed"`
+
+	// AuthRequired is true if /metrics was refused for lack of credentials.
+	AuthRequired bool `json:"auth_required"`
+
+	// MetricsSize is the size of the /metrics payload in bytes: its
+	// Content-Length, if given, or else the number of bytes read.
+	MetricsSize int64 `json:"metrics_size,omitempty"`
+
+	// MetricsTruncated is true if only the first --max-size KB of the
+	// payload were read and parsed.
+	MetricsTruncated bool `json:"metrics_truncated,omitempty"`
+
+	// Metrics holds the well-known series extracted from /metrics.
+	Metrics *Metrics `json:"metrics,omitempty"`
+
+	// Healthy is true if /-/healthy reported that Prometheus is healthy.
+	Healthy bool `json:"healthy"`
+
+	// TLSLog is the standard TLS log, if TLS was used.
+	TLSLog *zgrab2.TLSLog `json:"tls,omitempty"`
+}
+
+// Flags holds the command-line configuration for the prometheus scan module.
+// Populated by the framework.
+type Flags struct {
+	zgrab2.BaseFlags
+	zgrab2.TLSFlags
+
+	// UseTLS indicates that the client should connect over TLS.
+	UseTLS bool `long:"use-tls" description:"Connect over TLS"`
+
+	// MaxSize bounds the size of each response body that is read.
+	MaxSize int `long:"max-size" default:"1024" description:"Max kilobytes to read in response to each request"`
+
+	// UserAgent is sent in the User-Agent header of each request.
+	UserAgent string `long:"user-agent" default:"Mozilla/5.0 zgrab/0.x" description:"Set a custom user agent"`
+
+	// Verbose indicates that there should be more verbose logging.
+	Verbose bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
+}
+
+// Module implements the zgrab2.Module interface.
+type Module struct {
+}
+
+// Scanner implements the zgrab2.Scanner interface.
+type Scanner struct {
+	config *Flags
+}
+
+// scan holds the state of a single scan.
+type scan struct {
+	scanner *Scanner
+	client  *httpapi.Client
+	results ScanResults
+}
+
+// RegisterModule registers the zgrab2 module.
+func RegisterModule() {
+	var module Module
+	_, err := zgrab2.AddCommand("prometheus", "prometheus", module.Description(), 9090, &module)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewFlags returns a default Flags object.
+func (module *Module) NewFlags() interface{} {
+	return new(Flags)
+}
+
+// NewScanner returns a new Scanner instance.
+func (module *Module) NewScanner() zgrab2.Scanner {
+	return new(Scanner)
+}
+
+// Description returns an overview of this module.
+func (module *Module) Description() string {
+	return "Probe for Prometheus servers and exporters with /metrics and /-/healthy"
+}
+
+// Validate checks that the flags are valid.
+// On success, returns nil.
+// On failure, returns an error instance describing the error.
+func (flags *Flags) Validate(args []string) error {
+	return nil
+}
+
+// Help returns the module's help string.
+func (flags *Flags) Help() string {
+	return ""
+}
+
+// Init initializes the Scanner.
+func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
+	f, _ := flags.(*Flags)
+	scanner.config = f
+	return nil
+}
+
+// InitPerSender initializes the scanner for a given sender.
+func (scanner *Scanner) InitPerSender(senderID int) error {
+	return nil
+}
+
+// GetName returns the Scanner name defined in the Flags.
+func (scanner *Scanner) GetName() string {
+	return scanner.config.Name
+}
+
+// GetTrigger returns the Trigger defined in the Flags.
+func (scanner *Scanner) GetTrigger() string {
+	return scanner.config.Trigger
+}
+
+// Protocol returns the protocol identifier of the scan.
+func (scanner *Scanner) Protocol() string {
+	return "prometheus"
+}
+
+// newPrometheusScan returns a scan of the target on the given port.
+func (scanner *Scanner) newPrometheusScan(target *zgrab2.ScanTarget, port uint) *scan {
+	ret := &scan{scanner: scanner}
+	ret.client = httpapi.NewClient(target, port, &httpapi.Config{
+		BaseFlags: &scanner.config.BaseFlags,
+		TLSFlags:  &scanner.config.TLSFlags,
+		UseTLS:    scanner.config.UseTLS,
+		UserAgent: scanner.config.UserAgent,
+		MaxSize:   scanner.config.MaxSize,
+		TLSLog:    &ret.results.TLSLog,
+	})
+	return ret
+}
+
+// Cleanup closes any connections that have been opened during the scan.
+func (scan *scan) Cleanup() {
+	scan.client.Close()
+}
+
+// Scan performs the Prometheus scan.
+//  1. Connect over TLS if --use-tls is set; otherwise over plain HTTP.
+//  2. Send GET /metrics. If it returns metrics in the text exposition
+//     format, flag metrics_exposed and record the payload size and the
+//     well-known series; if it returns 401 / 403, record auth_required.
+//  3. Send GET /-/healthy and record whether Prometheus reported itself
+//     healthy. If neither response looks like it came from Prometheus or an
+//     exporter (including when /metrics required credentials), fail with a
+//     protocol error.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	port := scanner.config.Port
+	if target.Port != nil {
+		port = *target.Port
+	}
+	scan := scanner.newPrometheusScan(&target, port)
+	defer scan.Cleanup()
+	result := &scan.results
+
+	resp, n, err := scan.client.Do("GET", "/metrics", acceptMetrics, "")
+	if err != nil {
+		if result.TLSLog != nil {
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	result.MetricsResponse = resp
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if result.Metrics = parseMetrics(resp.BodyText); result.Metrics != nil {
+			result.MetricsExposed = true
+			result.MetricsSize = n
+			if resp.ContentLength > n {
+				result.MetricsSize = resp.ContentLength
+				result.MetricsTruncated = true
+			} else if resp.ContentLength < 0 && n == int64(scanner.config.MaxSize)*1024 {
+				result.MetricsTruncated = true
+			}
+		}
+	case http.StatusUnauthorized, http.StatusForbidden:
+		result.AuthRequired = true
+	}
+
+	resp, _, err = scan.client.Do("GET", "/-/healthy", "*/*", "")
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+	result.HealthyResponse = resp
+	result.Healthy = resp.StatusCode == http.StatusOK && strings.Contains(resp.BodyText, "Healthy")
+	if !result.MetricsExposed && !result.Healthy {
+		return zgrab2.SCAN_PROTOCOL_ERROR, result, ErrNotPrometheus
+	}
+	return zgrab2.SCAN_SUCCESS, result, nil
+}
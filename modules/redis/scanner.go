@@ -10,19 +10,28 @@
 // distinct. The probe sends a sequence of commands and checks that the response
 // is well-formed redis data, which should be possible whatever the
 // configuration.
+// With --use-tls, the probe is sent over TLS (as with rediss:// URLs), using
+// the standard TLS flags; --tls-sni and --tls-client-cert/--tls-client-key
+// are aliases for --server-name and --certificates/--certificate-key, for
+// servers behind load balancers or requiring mutual TLS.
 package redis
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/zmap/zcrypto/tls"
 	"github.com/zmap/zgrab2"
 	"gopkg.in/yaml.v2"
 )
@@ -30,15 +39,60 @@ import (
 // Flags contains redis-specific command-line flags.
 type Flags struct {
 	zgrab2.BaseFlags
+	zgrab2.TLSFlags
 
 	CustomCommands   string `long:"custom-commands" description:"Pathname for JSON/YAML file that contains extra commands to execute. WARNING: This is sent in the clear."`
 	Mappings         string `long:"mappings" description:"Pathname for JSON/YAML file that contains mappings for command names."`
 	MaxInputFileSize int64  `long:"max-input-file-size" default:"102400" description:"Maximum size for either input file."`
 	Password         string `long:"password" description:"Set a password to use to authenticate to the server. WARNING: This is sent in the clear."`
-	DoInline         bool   `long:"inline" description:"Send commands using the inline syntax"`
+	DoInline         bool   `long:"inline" description:"Send commands using the inline syntax; PING is also sent as a multibulk array, and its response recorded if it differs"`
+	Eval             bool   `long:"eval" description:"Check whether Lua scripting is enabled, by sending the harmless EVAL \"return 1\" 0"`
+	ScanKeys         uint   `long:"scan-keys" description:"Sample up to this many key names (but not their values) with SCAN, and look up their types with TYPE"`
+	UseTLS           bool   `long:"use-tls" description:"Connect over TLS"`
+	TLSSNI           string `long:"tls-sni" description:"Alias for --server-name: with --use-tls, send this server name in the TLS handshake instead of the target's domain (e.g. for a load balancer)"`
+	TLSClientCert    string `long:"tls-client-cert" description:"Alias for --certificates: with --use-tls, PEM file of a client certificate to present, for servers requiring mutual TLS"`
+	TLSClientKey     string `long:"tls-client-key" description:"Alias for --certificate-key: PEM file of the private key for --tls-client-cert"`
+	UnixSocket       string `long:"unix-socket" description:"Connect to the Unix domain socket at this path instead of each target's address and port (e.g. to scan from a sidecar container)"`
 	Verbose          bool   `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
+	Admin            bool   `long:"admin" description:"Probe for admin access with LATENCY LATEST, SLOWLOG GET 10 and CLIENT LIST, recording their parsed replies"`
+	Redact           bool   `long:"redact" description:"With --admin, replace the client addresses and names in the SLOWLOG GET and CLIENT LIST replies, and the arguments of the commands in the SLOWLOG GET reply, (and the raw replies, with --debug) with <redacted>"`
+	DetectOnly       bool   `long:"detect-only" description:"Only send PING and a nonexistent command, and classify the server from the framing of the replies, skipping AUTH, INFO, QUIT (unless --close-graceful is given) and all other probes"`
 }
 
+const (
+	// maxScanKeys is the most keys that --scan-keys may sample.
+	maxScanKeys = 1000
+
+	// maxScanIterations is the most SCAN commands sent for --scan-keys, so
+	// that a sparse keyspace is never iterated in full.
+	maxScanIterations = 10
+
+	// slowlogEntries is the number of entries requested with SLOWLOG GET,
+	// for --admin.
+	slowlogEntries = "10"
+
+	// maxClients is the most CLIENT LIST clients recorded, for --admin.
+	maxClients = 100
+
+	// redactedValue replaces the values hidden by --redact.
+	redactedValue = "<redacted>"
+)
+
+// The values of Result.Classification, with --detect-only.
+const (
+	// ClassificationRedis means that PING was answered with PONG or an
+	// error, and the nonexistent command with an error, as redis does.
+	ClassificationRedis = "redis"
+
+	// ClassificationPossibleRedis means that the replies were well-formed
+	// redis data, but not the expected replies (e.g. because PING was
+	// renamed, or the connection was closed after PING).
+	ClassificationPossibleRedis = "possible_redis"
+
+	// ClassificationNotRedis means that a reply was not redis data.
+	ClassificationNotRedis = "not_redis"
+)
+
 // Module implements the zgrab2.Module interface
 type Module struct {
 }
@@ -71,12 +125,30 @@ type Result struct {
 	// the index in RawCommandOutput matches the index in Commands.
 	RawCommandOutput [][]byte `json:"raw_command_output,omitempty" zgrab:"debug"`
 
+	// TLSLog is the standard TLS log, if --use-tls is set.
+	TLSLog *zgrab2.TLSLog `json:"tls,omitempty"`
+
+	// TLSVersion and TLSCipherSuite are the TLS version and cipher suite
+	// negotiated, if --use-tls is set.
+	TLSVersion     string `json:"tls_version,omitempty"`
+	TLSCipherSuite string `json:"tls_cipher_suite,omitempty"`
+
+	// UnixSocket is the path of the Unix domain socket the probe was sent
+	// over, if --unix-socket is set; the target's address and port were
+	// then not used.
+	UnixSocket string `json:"unix_socket,omitempty"`
+
 	// PingResponse is the response from the server, should be the simple string
 	// "PONG".
 	// NOTE: This is invoked *before* calling AUTH, so this may return an auth
 	// required error even if --password is provided.
 	PingResponse string `json:"ping_response,omitempty"`
 
+	// MultibulkPingResponse is the response to PING sent as a multibulk
+	// array, if --inline is set and it differs from PingResponse (the
+	// response to the inline PING).
+	MultibulkPingResponse string `json:"multibulk_ping_response,omitempty"`
+
 	// AuthResponse is only included if --password is set.
 	AuthResponse string `json:"auth_response,omitempty"`
 
@@ -142,6 +214,10 @@ type Result struct {
 	// if present. It specifies the total number of commands processed by the server.
 	CommandsProcessed uint32 `json:"total_commands_processed,omitempty"`
 
+	// NonexistentCommand is the non-existent command that was sent: a random
+	// token, unless the NONEXISTENT command is given in the --mappings file.
+	NonexistentCommand string `json:"nonexistent_command,omitempty"`
+
 	// NonexistentResponse is the response to the non-existent command; even if
 	// auth is required, this may give a different error than existing commands.
 	NonexistentResponse string `json:"nonexistent_response,omitempty"`
@@ -150,10 +226,63 @@ type Result struct {
 	// responses from user-inputted commands.
 	CustomResponses []CustomResponse `json:"custom_responses,omitempty"`
 
+	// EvalEnabled is true if the server ran the harmless script sent with
+	// EVAL, if --eval is set; it is false if EVAL is disabled, renamed or
+	// not permitted (e.g. because authentication is required).
+	EvalEnabled *bool `json:"eval_enabled,omitempty"`
+
+	// EvalResponse is the response to the EVAL command, if --eval is set.
+	EvalResponse string `json:"eval_response,omitempty"`
+
+	// SampledKeys are the keys sampled with SCAN, if --scan-keys is set.
+	SampledKeys []SampledKey `json:"sampled_keys,omitempty"`
+
+	// ScanKeysError is the error returned by the server for SCAN, if any
+	// (e.g. because authentication is required).
+	ScanKeysError string `json:"scan_keys_error,omitempty"`
+
+	// SentinelMasters are the masters monitored by the server, if it is a
+	// Sentinel (its INFO gives redis_mode:sentinel).
+	SentinelMasters []SentinelMaster `json:"sentinel_masters,omitempty"`
+
+	// SentinelError is the error returned by a Sentinel for SENTINEL
+	// masters, if any (e.g. because authentication is required).
+	SentinelError string `json:"sentinel_error,omitempty"`
+
+	// LatencyEvents are the events with latency spikes, from LATENCY
+	// LATEST, if --admin is set.
+	LatencyEvents []LatencyEvent `json:"latency_events,omitempty"`
+
+	// LatencyError is the error returned by the server for LATENCY LATEST,
+	// if any (e.g. because authentication is required).
+	LatencyError string `json:"latency_error,omitempty"`
+
+	// SlowlogEntries are the latest entries of the slow log, from SLOWLOG
+	// GET, if --admin is set.
+	SlowlogEntries []SlowlogEntry `json:"slowlog_entries,omitempty"`
+
+	// SlowlogError is the error returned by the server for SLOWLOG GET, if
+	// any.
+	SlowlogError string `json:"slowlog_error,omitempty"`
+
+	// Clients are the first maxClients client connections, from CLIENT
+	// LIST, if --admin is set; ClientCount is the total number listed.
+	Clients     []Client `json:"clients,omitempty"`
+	ClientCount int      `json:"client_count,omitempty"`
+
+	// ClientListError is the error returned by the server for CLIENT LIST,
+	// if any.
+	ClientListError string `json:"client_list_error,omitempty"`
+
 	// QuitResponse is the response from the QUIT command -- should be the
 	// simple string "OK" even when authentication is required, unless the
 	// QUIT command was renamed.
 	QuitResponse string `json:"quit_response,omitempty"`
+
+	// Classification is whether the server is redis, judged from the
+	// replies to PING and the nonexistent command, if --detect-only is set:
+	// one of "redis", "possible_redis" or "not_redis".
+	Classification string `json:"classification,omitempty"`
 }
 
 // RegisterModule registers the zgrab2 module
@@ -182,6 +311,39 @@ func (module *Module) Description() string {
 
 // Validate checks that the flags are valid
 func (flags *Flags) Validate(args []string) error {
+	if (flags.TLSSNI != "" || flags.TLSClientCert != "" || flags.TLSClientKey != "") && !flags.UseTLS {
+		log.Errorf("--tls-sni, --tls-client-cert and --tls-client-key require --use-tls")
+		return zgrab2.ErrInvalidArguments
+	}
+	if (flags.TLSClientCert == "") != (flags.TLSClientKey == "") {
+		log.Errorf("--tls-client-cert and --tls-client-key must be given together")
+		return zgrab2.ErrInvalidArguments
+	}
+	for _, alias := range []struct {
+		name, target, value string
+		flag                *string
+	}{
+		{"--tls-sni", "--server-name", flags.TLSSNI, &flags.ServerName},
+		{"--tls-client-cert", "--certificates", flags.TLSClientCert, &flags.Certificates},
+		{"--tls-client-key", "--certificate-key", flags.TLSClientKey, &flags.CertificateKey},
+	} {
+		if alias.value == "" {
+			continue
+		}
+		if *alias.flag != "" && *alias.flag != alias.value {
+			log.Errorf("%s is an alias for %s, and cannot be given a different value", alias.name, alias.target)
+			return zgrab2.ErrInvalidArguments
+		}
+		*alias.flag = alias.value
+	}
+	if flags.ScanKeys > maxScanKeys {
+		log.Errorf("--scan-keys must be at most %d", maxScanKeys)
+		return zgrab2.ErrInvalidArguments
+	}
+	if flags.DetectOnly && (flags.Password != "" || flags.CustomCommands != "" || flags.Eval || flags.ScanKeys > 0 || flags.Admin) {
+		log.Errorf("--detect-only cannot be used with --password, --custom-commands, --eval, --scan-keys or --admin")
+		return zgrab2.ErrInvalidArguments
+	}
 	return nil
 }
 
@@ -260,14 +422,23 @@ func (scanner *Scanner) getFileContents(file string, output interface{}) error {
 	return nil
 }
 
-// Initializes the command mappings
+// Initializes the command mappings. NONEXISTENT has no default mapping, so
+// that a random command is sent in its place, unless the --mappings file
+// gives one.
 func (scanner *Scanner) initCommands() error {
 	scanner.commandMappings = map[string]string{
-		"PING":        "PING",
-		"AUTH":        "AUTH",
-		"INFO":        "INFO",
-		"NONEXISTENT": "NONEXISTENT",
-		"QUIT":        "QUIT",
+		"PING": "PING",
+		"AUTH": "AUTH",
+		"INFO": "INFO",
+		"QUIT": "QUIT",
+		"EVAL": "EVAL",
+		"SCAN": "SCAN",
+		"TYPE": "TYPE",
+
+		"SENTINEL": "SENTINEL",
+		"LATENCY":  "LATENCY",
+		"SLOWLOG":  "SLOWLOG",
+		"CLIENT":   "CLIENT",
 	}
 
 	if scanner.config.CustomCommands != "" {
@@ -312,14 +483,30 @@ func (scan *scan) SendCommand(cmd string, args ...string) (RedisValue, error) {
 
 // StartScan opens a connection to the target and sets up a scan instance for it
 func (scanner *Scanner) StartScan(target *zgrab2.ScanTarget) (*scan, error) {
-	conn, err := target.Open(&scanner.config.BaseFlags)
+	var conn net.Conn
+	var err error
+	if scanner.config.UnixSocket != "" {
+		conn, err = target.OpenUnix(scanner.config.UnixSocket, &scanner.config.BaseFlags)
+	} else {
+		conn, err = target.Open(&scanner.config.BaseFlags)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if scanner.config.UseTLS {
+		tlsConn, err := scanner.config.TLSFlags.GetTLSConnectionForTarget(conn, target)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	} else {
+		zgrab2.EnableTLSDetection(conn)
+	}
 	return &scan{
 		target:  target,
 		scanner: scanner,
-		result:  &Result{},
+		result:  &Result{UnixSocket: scanner.config.UnixSocket},
 		conn: &Connection{
 			scanner: scanner,
 			conn:    conn,
@@ -328,6 +515,23 @@ func (scanner *Scanner) StartScan(target *zgrab2.ScanTarget) (*scan, error) {
 	}, nil
 }
 
+// handshake performs the TLS handshake, if --use-tls is set, and records
+// the TLS log and the negotiated version and cipher suite.
+func (scan *scan) handshake() error {
+	tlsConn, ok := scan.conn.conn.(*zgrab2.TLSConnection)
+	if !ok {
+		return nil
+	}
+	scan.result.TLSLog = tlsConn.GetLog()
+	if err := tlsConn.Handshake(); err != nil {
+		return err
+	}
+	state := tlsConn.ConnectionState()
+	scan.result.TLSVersion = tls.TLSVersion(state.Version).String()
+	scan.result.TLSCipherSuite = tls.CipherSuite(state.CipherSuite).String()
+	return nil
+}
+
 // Force the response into a string. Used when you expect a human-readable
 // string.
 func forceToString(val RedisValue) string {
@@ -363,22 +567,474 @@ func convToUint32(s string) uint32 {
 	return uint32(s64)
 }
 
-// Scan executes the following commands:
-// 1. PING
+// probeMultibulkPing sends PING as a multibulk array, for --inline, and
+// records the response if it differs from inlineResponse, the response to
+// the inline PING: the replies are decoded the same way, but some servers
+// treat the two syntaxes differently.
+func (scan *scan) probeMultibulkPing(inlineResponse RedisValue) error {
+	cmd := scan.scanner.commandMappings["PING"]
+	scan.result.Commands = append(scan.result.Commands, getInlineCommand(cmd))
+	resp, err := scan.conn.SendCommand(cmd)
+	if err != nil {
+		return err
+	}
+	encoded := resp.Encode()
+	scan.result.RawCommandOutput = append(scan.result.RawCommandOutput, encoded)
+	if !bytes.Equal(encoded, inlineResponse.Encode()) {
+		scan.result.MultibulkPingResponse = forceToString(resp)
+	}
+	return nil
+}
+
+// nonexistentCommandLength is the length of the random command sent in place
+// of NONEXISTENT.
+const nonexistentCommandLength = 12
+
+// randomCommand returns a random command name of nonexistentCommandLength
+// letters, which (unlike a fixed name) no server will have, and which can't
+// be used to fingerprint the scan.
+func randomCommand() string {
+	b := make([]byte, nonexistentCommandLength)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	for i := range b {
+		b[i] = 'A' + b[i]%26
+	}
+	return string(b)
+}
+
+// probeNonexistent sends a command that the server does not have, and
+// records and returns the response.
+func (scan *scan) probeNonexistent() (RedisValue, error) {
+	cmd, ok := scan.scanner.commandMappings["NONEXISTENT"]
+	if !ok {
+		cmd = randomCommand()
+	}
+	scan.result.NonexistentCommand = cmd
+	resp, err := scan.SendCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	scan.result.NonexistentResponse = forceToString(resp)
+	return resp, nil
+}
+
+// evalScript is the script sent for --eval; it neither reads nor writes any
+// data.
+const evalScript = "return 1"
+
+// probeEval sends EVAL with a harmless script, and records whether the server
+// ran it: only then is the reply the integer 1.
+func (scan *scan) probeEval() error {
+	resp, err := scan.SendCommand(scan.scanner.commandMappings["EVAL"], evalScript, "0")
+	if err != nil {
+		return err
+	}
+	value, ok := resp.(Integer)
+	enabled := ok && value == 1
+	scan.result.EvalEnabled = &enabled
+	scan.result.EvalResponse = forceToString(resp)
+	return nil
+}
+
+// scanKeys samples up to --scan-keys key names with SCAN, following the
+// cursor until it returns to 0, enough keys have been seen, or
+// maxScanIterations commands have been sent, then gets each key's TYPE.
+func (scan *scan) scanKeys() error {
+	limit := int(scan.scanner.config.ScanKeys)
+	count := strconv.Itoa(limit)
+	seen := make(map[string]bool)
+	cursor := "0"
+	for i := 0; i < maxScanIterations && len(scan.result.SampledKeys) < limit; i++ {
+		resp, err := scan.SendCommand(scan.scanner.commandMappings["SCAN"], cursor, "COUNT", count)
+		if err != nil {
+			return err
+		}
+		if errMessage, ok := resp.(ErrorMessage); ok {
+			scan.result.ScanKeysError = forceToString(errMessage)
+			return nil
+		}
+		next, keys, ok := parseScanResponse(resp)
+		if !ok {
+			scan.result.ScanKeysError = "(Unexpected SCAN response)"
+			return nil
+		}
+		// SCAN may return a key more than once.
+		for _, key := range keys {
+			if len(scan.result.SampledKeys) < limit && !seen[key] {
+				seen[key] = true
+				scan.result.SampledKeys = append(scan.result.SampledKeys, SampledKey{Name: key})
+			}
+		}
+		if cursor = next; cursor == "0" {
+			break
+		}
+	}
+	for i := range scan.result.SampledKeys {
+		key := &scan.result.SampledKeys[i]
+		resp, err := scan.SendCommand(scan.scanner.commandMappings["TYPE"], key.Name)
+		if err != nil {
+			return err
+		}
+		key.Type = forceToString(resp)
+	}
+	return nil
+}
+
+// parseScanResponse gets the next cursor and the keys from a SCAN response,
+// which is an array of the cursor and an array of keys.
+func parseScanResponse(resp RedisValue) (string, []string, bool) {
+	array, ok := resp.(RedisArray)
+	if !ok || len(array) != 2 {
+		return "", nil, false
+	}
+	cursor, ok := array[0].(BulkString)
+	if !ok {
+		return "", nil, false
+	}
+	keyArray, ok := array[1].(RedisArray)
+	if !ok {
+		return "", nil, false
+	}
+	keys := make([]string, 0, len(keyArray))
+	for _, v := range keyArray {
+		key, ok := v.(BulkString)
+		if !ok {
+			return "", nil, false
+		}
+		keys = append(keys, string(key))
+	}
+	return string(cursor), keys, true
+}
+
+// probeSentinel sends SENTINEL masters to a Sentinel, and records the
+// masters it monitors.
+func (scan *scan) probeSentinel() error {
+	resp, err := scan.SendCommand(scan.scanner.commandMappings["SENTINEL"], "masters")
+	if err != nil {
+		return err
+	}
+	if errMessage, ok := resp.(ErrorMessage); ok {
+		scan.result.SentinelError = forceToString(errMessage)
+		return nil
+	}
+	masters, ok := parseSentinelMasters(resp)
+	if !ok {
+		scan.result.SentinelError = "(Unexpected SENTINEL masters response)"
+		return nil
+	}
+	scan.result.SentinelMasters = masters
+	return nil
+}
+
+// parseSentinelMasters parses a SENTINEL masters response: an array with,
+// for each master, an array of alternating field names and values.
+func parseSentinelMasters(resp RedisValue) ([]SentinelMaster, bool) {
+	array, ok := resp.(RedisArray)
+	if !ok {
+		return nil, false
+	}
+	masters := make([]SentinelMaster, 0, len(array))
+	for _, v := range array {
+		fields, ok := v.(RedisArray)
+		if !ok || len(fields)%2 != 0 {
+			return nil, false
+		}
+		var master SentinelMaster
+		for i := 0; i < len(fields); i += 2 {
+			name, ok := fields[i].(BulkString)
+			if !ok {
+				return nil, false
+			}
+			value := forceToString(fields[i+1])
+			switch string(name) {
+			case "name":
+				master.Name = value
+			case "ip":
+				master.IP = value
+			case "port":
+				master.Port = convToUint32(value)
+			case "flags":
+				master.Flags = value
+			case "quorum":
+				master.Quorum = convToUint32(value)
+			case "num-slaves":
+				master.NumSlaves = convToUint32(value)
+			case "num-other-sentinels":
+				master.NumOtherSentinels = convToUint32(value)
+			}
+		}
+		masters = append(masters, master)
+	}
+	return masters, true
+}
+
+// sendAdminCommand sends an --admin command, replacing its raw reply with
+// redactedValue if --redact is set, as it may hold client addresses.
+func (scan *scan) sendAdminCommand(cmd string, args ...string) (RedisValue, error) {
+	resp, err := scan.SendCommand(scan.scanner.commandMappings[cmd], args...)
+	if err == nil && scan.scanner.config.Redact {
+		scan.result.RawCommandOutput[len(scan.result.RawCommandOutput)-1] = []byte(redactedValue)
+	}
+	return resp, err
+}
+
+// probeAdmin sends LATENCY LATEST, SLOWLOG GET 10 and CLIENT LIST, for
+// --admin, and records their parsed replies. Servers that answer them grant
+// admin-level access.
+func (scan *scan) probeAdmin() error {
+	redact := scan.scanner.config.Redact
+	resp, err := scan.sendAdminCommand("LATENCY", "LATEST")
+	if err != nil {
+		return err
+	}
+	if errMessage, ok := resp.(ErrorMessage); ok {
+		scan.result.LatencyError = forceToString(errMessage)
+	} else if events, ok := parseLatencyLatest(resp); ok {
+		scan.result.LatencyEvents = events
+	} else {
+		scan.result.LatencyError = "(Unexpected LATENCY LATEST response)"
+	}
+
+	resp, err = scan.sendAdminCommand("SLOWLOG", "GET", slowlogEntries)
+	if err != nil {
+		return err
+	}
+	if errMessage, ok := resp.(ErrorMessage); ok {
+		scan.result.SlowlogError = forceToString(errMessage)
+	} else if entries, ok := parseSlowlog(resp, redact); ok {
+		scan.result.SlowlogEntries = entries
+	} else {
+		scan.result.SlowlogError = "(Unexpected SLOWLOG GET response)"
+	}
+
+	resp, err = scan.sendAdminCommand("CLIENT", "LIST")
+	if err != nil {
+		return err
+	}
+	if errMessage, ok := resp.(ErrorMessage); ok {
+		scan.result.ClientListError = forceToString(errMessage)
+	} else if list, ok := resp.(BulkString); ok {
+		clients := parseClientList(string(list), redact)
+		scan.result.ClientCount = len(clients)
+		if len(clients) > maxClients {
+			clients = clients[:maxClients]
+		}
+		scan.result.Clients = clients
+	} else {
+		scan.result.ClientListError = "(Unexpected CLIENT LIST response)"
+	}
+	return nil
+}
+
+// parseLatencyLatest parses a LATENCY LATEST response: an array with, for
+// each event, an array of its name, the time of its latest spike, and the
+// latest and maximum latencies.
+func parseLatencyLatest(resp RedisValue) ([]LatencyEvent, bool) {
+	array, ok := resp.(RedisArray)
+	if !ok {
+		return nil, false
+	}
+	events := make([]LatencyEvent, 0, len(array))
+	for _, v := range array {
+		fields, ok := v.(RedisArray)
+		if !ok || len(fields) < 4 {
+			return nil, false
+		}
+		name, ok := fields[0].(BulkString)
+		timestamp, ok1 := fields[1].(Integer)
+		latest, ok2 := fields[2].(Integer)
+		max, ok3 := fields[3].(Integer)
+		if !ok || !ok1 || !ok2 || !ok3 {
+			return nil, false
+		}
+		events = append(events, LatencyEvent{
+			Event:     string(name),
+			Timestamp: int64(timestamp),
+			LatestMS:  int64(latest),
+			MaxMS:     int64(max),
+		})
+	}
+	return events, true
+}
+
+// parseSlowlog parses a SLOWLOG GET response: an array with, for each
+// entry, an array of its ID, timestamp, duration, an array of the command
+// and its arguments, and (since redis 4.0) the client's address and name.
+// If redact is set, the client's address and name and the command's
+// arguments (which may hold keys, values or passwords) are redacted; only
+// the command name is kept.
+func parseSlowlog(resp RedisValue, redact bool) ([]SlowlogEntry, bool) {
+	array, ok := resp.(RedisArray)
+	if !ok {
+		return nil, false
+	}
+	entries := make([]SlowlogEntry, 0, len(array))
+	for _, v := range array {
+		fields, ok := v.(RedisArray)
+		if !ok || len(fields) < 4 {
+			return nil, false
+		}
+		id, ok := fields[0].(Integer)
+		timestamp, ok1 := fields[1].(Integer)
+		duration, ok2 := fields[2].(Integer)
+		args, ok3 := fields[3].(RedisArray)
+		if !ok || !ok1 || !ok2 || !ok3 {
+			return nil, false
+		}
+		entry := SlowlogEntry{
+			ID:             int64(id),
+			Timestamp:      int64(timestamp),
+			DurationMicros: int64(duration),
+		}
+		for i, arg := range args {
+			entry.Command = append(entry.Command, redactValue(forceToString(arg), redact && i > 0))
+		}
+		if len(fields) >= 6 {
+			entry.ClientAddr = redactValue(forceToString(fields[4]), redact)
+			entry.ClientName = redactValue(forceToString(fields[5]), redact)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, true
+}
+
+// parseClientList parses a CLIENT LIST response: a line for each client,
+// of space-separated field=value pairs. The client's addresses and name are
+// redacted if redact is set.
+func parseClientList(list string, redact bool) []Client {
+	var clients []Client
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var client Client
+		for _, field := range strings.Fields(line) {
+			nameValue := strings.SplitN(field, "=", 2)
+			if len(nameValue) != 2 {
+				continue
+			}
+			value := nameValue[1]
+			switch nameValue[0] {
+			case "id":
+				client.ID, _ = strconv.ParseUint(value, 10, 64)
+			case "addr":
+				client.Addr = redactValue(value, redact)
+			case "laddr":
+				client.LocalAddr = redactValue(value, redact)
+			case "name":
+				client.Name = redactValue(value, redact)
+			case "user":
+				client.User = value
+			case "age":
+				client.Age = convToUint32(value)
+			case "idle":
+				client.Idle = convToUint32(value)
+			case "flags":
+				client.Flags = value
+			case "db":
+				client.DB = convToUint32(value)
+			case "cmd":
+				client.Cmd = value
+			}
+		}
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// redactValue returns redactedValue in place of value if redact is set and
+// value is not empty.
+func redactValue(value string, redact bool) string {
+	if redact && value != "" {
+		return redactedValue
+	}
+	return value
+}
+
+// classify returns the Classification of a server from its (well-formed)
+// replies to PING and the nonexistent command, which is nil if the
+// connection failed before the latter was answered.
+func classify(ping, nonexistent RedisValue) string {
+	_, pingError := ping.(ErrorMessage)
+	pong := forceToString(ping) == "PONG"
+	_, nonexistentError := nonexistent.(ErrorMessage)
+	switch {
+	case (pong || pingError) && nonexistentError:
+		return ClassificationRedis
+	default:
+		return ClassificationPossibleRedis
+	}
+}
+
+// isInvalidData returns true if err means that the server's reply was not
+// redis data.
+func isInvalidData(err error) bool {
+	return err == ErrInvalidData || err == ErrBadLength || err == ErrWrongType
+}
+
+// detect sends only PING and the nonexistent command, and classifies the
+// server from the replies, for --detect-only.
+func (scan *scan) detect() (zgrab2.ScanStatus, interface{}, error) {
+	result := scan.result
+	pingResponse, err := scan.SendCommand(scan.scanner.commandMappings["PING"])
+	if isInvalidData(err) {
+		result.Classification = ClassificationNotRedis
+		return zgrab2.SCAN_PROTOCOL_ERROR, result, err
+	} else if err != nil {
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	result.PingResponse = forceToString(pingResponse)
+	result.Classification = classify(pingResponse, nil)
+	nonexistentResponse, err := scan.probeNonexistent()
+	if isInvalidData(err) {
+		result.Classification = ClassificationNotRedis
+		return zgrab2.SCAN_PROTOCOL_ERROR, result, err
+	} else if err != nil {
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+	result.Classification = classify(pingResponse, nonexistentResponse)
+	if zgrab2.CloseGracefully() {
+		// QUIT is not needed for the classification, so its errors are ignored.
+		if quitResponse, err := scan.SendCommand(scan.scanner.commandMappings["QUIT"]); err == nil {
+			result.QuitResponse = forceToString(quitResponse)
+		}
+	}
+	return zgrab2.SCAN_SUCCESS, result, nil
+}
+
+// Scan performs the TLS handshake, if --use-tls is set, then executes the
+// following commands:
+// 1. PING (with --inline, also sent as a multibulk array)
 // 2. (only if --password is provided) AUTH <password>
 // 3. INFO
-// 4. NONEXISTENT
+// 4. NONEXISTENT (a random command, unless --mappings gives one)
 // 5. (only if --custom-commands is provided) CustomCommands <args>
-// 6. QUIT
+// 6. (only if --eval is provided) EVAL "return 1" 0
+// 7. (only if --scan-keys is provided) SCAN <cursor> COUNT <n>, TYPE <key>
+// 8. (only if --admin is provided) LATENCY LATEST, SLOWLOG GET 10, CLIENT LIST
+// 9. QUIT
+// If INFO shows that the server is a Sentinel, which supports neither EVAL
+// nor SCAN, steps 6 and 7 are replaced by SENTINEL masters.
+// With --detect-only, only steps 1 and 4 (and 9, with --close-graceful) are
+// sent, and the server is classified from their replies.
 // The responses for each of these is logged, and if INFO succeeds, the version
 // is scraped from it.
-func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
 	// ping, info, quit
 	scan, err := scanner.StartScan(&target)
 	if err != nil {
 		return zgrab2.TryGetScanStatus(err), nil, err
 	}
 	defer scan.Close()
+	if err := scan.handshake(); err != nil {
+		return zgrab2.TryGetScanStatus(err), scan.result, err
+	}
+	if scanner.config.DetectOnly {
+		return scan.detect()
+	}
 	result := scan.result
 	pingResponse, err := scan.SendCommand(scanner.commandMappings["PING"])
 	if err != nil {
@@ -389,6 +1045,11 @@ func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, inter
 	// From this point forward, we always return a non-nil result, implying that
 	// we have positively identified that a redis service is present.
 	result.PingResponse = forceToString(pingResponse)
+	if scanner.config.DoInline {
+		if err := scan.probeMultibulkPing(pingResponse); err != nil {
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+	}
 	if scanner.config.Password != "" {
 		authResponse, err := scan.SendCommand(scanner.commandMappings["AUTH"], scanner.config.Password)
 		if err != nil {
@@ -450,11 +1111,9 @@ func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, inter
 			}
 		}
 	}
-	bogusResponse, err := scan.SendCommand(scanner.commandMappings["NONEXISTENT"])
-	if err != nil {
+	if _, err := scan.probeNonexistent(); err != nil {
 		return zgrab2.TryGetScanStatus(err), result, err
 	}
-	result.NonexistentResponse = forceToString(bogusResponse)
 	for i := range scanner.customCommands {
 		fullCmd := strings.Fields(scanner.customCommands[i])
 		resp, err := scan.SendCommand(fullCmd[0], fullCmd[1:]...)
@@ -468,6 +1127,27 @@ func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, inter
 		}
 		result.CustomResponses = append(result.CustomResponses, customResponse)
 	}
+	if result.Mode == "sentinel" {
+		if err := scan.probeSentinel(); err != nil {
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+	} else {
+		if scanner.config.Eval {
+			if err := scan.probeEval(); err != nil {
+				return zgrab2.TryGetScanStatus(err), result, err
+			}
+		}
+		if scanner.config.ScanKeys > 0 {
+			if err := scan.scanKeys(); err != nil {
+				return zgrab2.TryGetScanStatus(err), result, err
+			}
+		}
+	}
+	if scanner.config.Admin {
+		if err := scan.probeAdmin(); err != nil {
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+	}
 	quitResponse, err := scan.SendCommand(scanner.commandMappings["QUIT"])
 	if err != nil && err != io.EOF {
 		return zgrab2.TryGetScanStatus(err), result, err
@@ -0,0 +1,719 @@
+package redis
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+// scriptedIO is a fake Reader/Writer that decodes each command written to it
+// (as a multibulk array or inline) and queues the reply returned by respond.
+type scriptedIO struct {
+	respond func(cmd []string) RedisValue
+	output  bytes.Buffer
+
+	// written holds each command written, and inline whether it was sent
+	// with the inline syntax.
+	written []string
+	inline  []bool
+}
+
+// Write decodes the command and queues the reply.
+func (scripted *scriptedIO) Write(buf []byte) (int, error) {
+	scripted.written = append(scripted.written, string(buf))
+	var cmd []string
+	if len(buf) > 0 && buf[0] != '*' {
+		args, ok := splitInline(strings.TrimSuffix(string(buf), "\r\n"))
+		if !ok {
+			return 0, ErrInvalidData
+		}
+		cmd = args
+		scripted.inline = append(scripted.inline, true)
+	} else {
+		decoder := Connection{conn: bytes.NewBuffer(buf)}
+		value, err := decoder.ReadRedisValue()
+		if err != nil {
+			return 0, err
+		}
+		for _, v := range value.(RedisArray) {
+			cmd = append(cmd, string(v.(BulkString)))
+		}
+		scripted.inline = append(scripted.inline, false)
+	}
+	scripted.output.Write(scripted.respond(cmd).Encode())
+	return len(buf), nil
+}
+
+// splitInline splits an inline command into its arguments as the server
+// does, handling double-quoted arguments with backslash escapes.
+func splitInline(line string) ([]string, bool) {
+	var args []string
+	for i := 0; i < len(line); {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+		var arg []byte
+		if line[i] != '"' {
+			for i < len(line) && line[i] != ' ' {
+				arg = append(arg, line[i])
+				i++
+			}
+			args = append(args, string(arg))
+			continue
+		}
+		for i++; ; i++ {
+			if i >= len(line) {
+				return nil, false
+			}
+			c := line[i]
+			if c == '"' {
+				i++
+				break
+			}
+			if c == '\\' && i+1 < len(line) {
+				i++
+				switch c = line[i]; c {
+				case 'n':
+					c = '\n'
+				case 'r':
+					c = '\r'
+				case 't':
+					c = '\t'
+				case 'x':
+					v, err := strconv.ParseUint(line[i+1:i+3], 16, 8)
+					if err != nil {
+						return nil, false
+					}
+					c = byte(v)
+					i += 2
+				}
+			}
+			arg = append(arg, c)
+		}
+		if i < len(line) && line[i] != ' ' {
+			return nil, false
+		}
+		args = append(args, string(arg))
+	}
+	return args, true
+}
+
+// Read reads the queued replies.
+func (scripted *scriptedIO) Read(buf []byte) (int, error) {
+	return scripted.output.Read(buf)
+}
+
+// getScriptedScan returns a scan with --scan-keys=scanKeys backed by a
+// scriptedIO.
+func getScriptedScan(t *testing.T, scanKeys uint, respond func(cmd []string) RedisValue) *scan {
+	scanner := &Scanner{config: &Flags{ScanKeys: scanKeys}}
+	if err := scanner.initCommands(); err != nil {
+		t.Fatal(err)
+	}
+	return &scan{
+		scanner: scanner,
+		result:  &Result{},
+		conn:    &Connection{scanner: scanner, conn: &scriptedIO{respond: respond}},
+	}
+}
+
+// scanReply builds a SCAN reply.
+func scanReply(cursor string, keys ...string) RedisValue {
+	array := make(RedisArray, len(keys))
+	for i, key := range keys {
+		array[i] = BulkString(key)
+	}
+	return RedisArray{BulkString(cursor), array}
+}
+
+func TestScanKeys(t *testing.T) {
+	var scans [][]string
+	scan := getScriptedScan(t, 3, func(cmd []string) RedisValue {
+		switch cmd[0] {
+		case "SCAN":
+			scans = append(scans, cmd[1:])
+			if cmd[1] == "0" {
+				return scanReply("17", "user:1", "session:abc")
+			}
+			// SCAN may return a key more than once.
+			return scanReply("0", "session:abc", "queue", "counter")
+		case "TYPE":
+			if cmd[1] == "queue" {
+				return SimpleString("list")
+			}
+			return SimpleString("string")
+		}
+		return ErrorMessage("ERR unknown command")
+	})
+	if err := scan.scanKeys(); err != nil {
+		t.Fatal(err)
+	}
+	expectedScans := [][]string{{"0", "COUNT", "3"}, {"17", "COUNT", "3"}}
+	if !reflect.DeepEqual(scans, expectedScans) {
+		t.Errorf("expected SCANs %v, got %v", expectedScans, scans)
+	}
+	expectedKeys := []SampledKey{
+		{Name: "user:1", Type: "string"},
+		{Name: "session:abc", Type: "string"},
+		{Name: "queue", Type: "list"},
+	}
+	if !reflect.DeepEqual(scan.result.SampledKeys, expectedKeys) {
+		t.Errorf("expected keys %v, got %v", expectedKeys, scan.result.SampledKeys)
+	}
+}
+
+func TestScanKeysIterationCap(t *testing.T) {
+	scans := 0
+	scan := getScriptedScan(t, 10, func(cmd []string) RedisValue {
+		if cmd[0] == "SCAN" {
+			// A sparse keyspace: the cursor never returns to 0.
+			scans++
+			return scanReply("42")
+		}
+		return SimpleString("none")
+	})
+	if err := scan.scanKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if scans != maxScanIterations || len(scan.result.SampledKeys) != 0 {
+		t.Errorf("expected %d SCANs and no keys, got %d and %v", maxScanIterations, scans, scan.result.SampledKeys)
+	}
+}
+
+func TestScanKeysNoAuth(t *testing.T) {
+	scan := getScriptedScan(t, 10, func(cmd []string) RedisValue {
+		return ErrorMessage("NOAUTH Authentication required.")
+	})
+	if err := scan.scanKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if scan.result.ScanKeysError != "(Error: NOAUTH Authentication required.)" || scan.result.SampledKeys != nil {
+		t.Errorf("unexpected result %+v", scan.result)
+	}
+}
+
+func TestProbeEval(t *testing.T) {
+	tests := map[string]struct {
+		reply    RedisValue
+		enabled  bool
+		response string
+	}{
+		"enabled": {Integer(1), true, "1"},
+		"renamed": {ErrorMessage("ERR unknown command 'EVAL'"), false, "(Error: ERR unknown command 'EVAL')"},
+		"no auth": {ErrorMessage("NOAUTH Authentication required."), false, "(Error: NOAUTH Authentication required.)"},
+		"acl":     {ErrorMessage("NOPERM this user has no permissions to run the 'eval' command"), false, "(Error: NOPERM this user has no permissions to run the 'eval' command)"},
+		"string":  {BulkString("1"), false, "1"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var sent []string
+			scan := getScriptedScan(t, 0, func(cmd []string) RedisValue {
+				sent = cmd
+				return test.reply
+			})
+			if err := scan.probeEval(); err != nil {
+				t.Fatal(err)
+			}
+			if expected := []string{"EVAL", evalScript, "0"}; !reflect.DeepEqual(sent, expected) {
+				t.Errorf("expected command %v, got %v", expected, sent)
+			}
+			if enabled := scan.result.EvalEnabled; enabled == nil || *enabled != test.enabled {
+				t.Errorf("expected eval_enabled=%v, got %v", test.enabled, enabled)
+			}
+			if scan.result.EvalResponse != test.response {
+				t.Errorf("expected response %q, got %q", test.response, scan.result.EvalResponse)
+			}
+		})
+	}
+}
+
+func TestProbeNonexistent(t *testing.T) {
+	var sent []string
+	respond := func(cmd []string) RedisValue {
+		sent = append(sent, cmd[0])
+		return ErrorMessage("ERR unknown command '" + cmd[0] + "'")
+	}
+	first := getScriptedScan(t, 0, respond)
+	second := getScriptedScan(t, 0, respond)
+	for _, scan := range []*scan{first, second} {
+		if _, err := scan.probeNonexistent(); err != nil {
+			t.Fatal(err)
+		}
+		cmd := scan.result.NonexistentCommand
+		if len(cmd) != nonexistentCommandLength || strings.Trim(cmd, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+			t.Errorf("expected a random command, got %q", cmd)
+		}
+		if expected := "(Error: ERR unknown command '" + cmd + "')"; scan.result.NonexistentResponse != expected {
+			t.Errorf("expected response %q, got %q", expected, scan.result.NonexistentResponse)
+		}
+	}
+	if first.result.NonexistentCommand == second.result.NonexistentCommand {
+		t.Errorf("expected a different command for each scan, got %q twice", first.result.NonexistentCommand)
+	}
+	if !reflect.DeepEqual(sent, []string{first.result.NonexistentCommand, second.result.NonexistentCommand}) {
+		t.Errorf("unexpected commands sent: %v", sent)
+	}
+
+	// A mapping gives a fixed command.
+	scan := getScriptedScan(t, 0, respond)
+	scan.scanner.commandMappings["NONEXISTENT"] = "NOSUCHCOMMAND"
+	if _, err := scan.probeNonexistent(); err != nil {
+		t.Fatal(err)
+	}
+	if scan.result.NonexistentCommand != "NOSUCHCOMMAND" || sent[len(sent)-1] != "NOSUCHCOMMAND" {
+		t.Errorf("expected the mapped command, got %q", scan.result.NonexistentCommand)
+	}
+}
+
+// sentinelMaster builds an entry of a SENTINEL masters reply.
+func sentinelMaster(fields ...string) RedisValue {
+	array := make(RedisArray, len(fields))
+	for i, field := range fields {
+		array[i] = BulkString(field)
+	}
+	return array
+}
+
+func TestProbeSentinel(t *testing.T) {
+	var sent []string
+	scan := getScriptedScan(t, 0, func(cmd []string) RedisValue {
+		sent = cmd
+		return RedisArray{
+			sentinelMaster("name", "mymaster", "ip", "10.0.0.5", "port", "6379", "runid", "c1a5d2c6", "flags", "master", "num-slaves", "2", "num-other-sentinels", "2", "quorum", "2"),
+			sentinelMaster("name", "cache", "ip", "10.0.0.9", "port", "6380", "flags", "master,s_down", "num-slaves", "0", "num-other-sentinels", "0", "quorum", "1"),
+		}
+	})
+	if err := scan.probeSentinel(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"SENTINEL", "masters"}; !reflect.DeepEqual(sent, expected) {
+		t.Errorf("expected command %v, got %v", expected, sent)
+	}
+	expected := []SentinelMaster{
+		{Name: "mymaster", IP: "10.0.0.5", Port: 6379, Flags: "master", Quorum: 2, NumSlaves: 2, NumOtherSentinels: 2},
+		{Name: "cache", IP: "10.0.0.9", Port: 6380, Flags: "master,s_down", Quorum: 1},
+	}
+	if !reflect.DeepEqual(scan.result.SentinelMasters, expected) {
+		t.Errorf("expected masters %+v, got %+v", expected, scan.result.SentinelMasters)
+	}
+
+	for reply, expectedError := range map[RedisValue]string{
+		ErrorMessage("NOAUTH Authentication required."): "(Error: NOAUTH Authentication required.)",
+		SimpleString("OK"): "(Unexpected SENTINEL masters response)",
+	} {
+		reply := reply
+		scan := getScriptedScan(t, 0, func(cmd []string) RedisValue { return reply })
+		if err := scan.probeSentinel(); err != nil {
+			t.Fatal(err)
+		}
+		if scan.result.SentinelError != expectedError || scan.result.SentinelMasters != nil {
+			t.Errorf("expected error %q, got %+v", expectedError, scan.result)
+		}
+	}
+}
+
+// rawReply is a reply that is not redis data.
+type rawReply string
+
+func (rawReply) Type() RedisType {
+	return "raw"
+}
+
+func (reply rawReply) Encode() []byte {
+	return []byte(reply)
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name           string
+		ping           RedisValue
+		nonexistent    RedisValue
+		status         zgrab2.ScanStatus
+		classification string
+	}{
+		{"pong", SimpleString("PONG"), ErrorMessage("ERR unknown command"), zgrab2.SCAN_SUCCESS, ClassificationRedis},
+		{"noauth", ErrorMessage("NOAUTH Authentication required."), ErrorMessage("NOAUTH Authentication required."), zgrab2.SCAN_SUCCESS, ClassificationRedis},
+		{"unexpected", BulkString("hello"), Integer(1), zgrab2.SCAN_SUCCESS, ClassificationPossibleRedis},
+		// The connection is closed after PING.
+		{"closed", SimpleString("PONG"), rawReply(""), zgrab2.SCAN_IO_TIMEOUT, ClassificationPossibleRedis},
+		{"http", rawReply("HTTP/1.1 400 Bad Request\r\n\r\n"), nil, zgrab2.SCAN_PROTOCOL_ERROR, ClassificationNotRedis},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sent []string
+			scan := getScriptedScan(t, 0, func(cmd []string) RedisValue {
+				sent = append(sent, cmd[0])
+				if cmd[0] == "PING" {
+					return test.ping
+				}
+				return test.nonexistent
+			})
+			status, _, _ := scan.detect()
+			if status != test.status || scan.result.Classification != test.classification {
+				t.Errorf("expected %s/%s, got %s/%s", test.status, test.classification, status, scan.result.Classification)
+			}
+			if test.nonexistent != nil && !reflect.DeepEqual(sent, []string{"PING", scan.result.NonexistentCommand}) {
+				t.Errorf("unexpected commands sent: %v", sent)
+			}
+		})
+	}
+}
+
+func TestGetInlineCommand(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"PING"}, "PING"},
+		{[]string{"SET", "a b", ""}, `SET "a b" ""`},
+		{[]string{"SET", "k", "say \"hi\"\r\n"}, `SET k "say \"hi\"\r\n"`},
+		{[]string{"SET", "k", "it's\\\x00\xff"}, `SET k "it's\\\x00\xff"`},
+	}
+	for _, test := range tests {
+		encoded := getInlineCommand(test.args[0], test.args[1:]...)
+		if encoded != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, encoded)
+		}
+		if decoded, ok := splitInline(encoded); !ok || !reflect.DeepEqual(decoded, test.args) {
+			t.Errorf("%s decoded to %q", encoded, decoded)
+		}
+	}
+}
+
+func TestInlineCommands(t *testing.T) {
+	info := BulkString("# Server\r\nredis_version:6.2.6\r\n")
+	respond := func(cmd []string) RedisValue {
+		switch cmd[0] {
+		case "PING":
+			return SimpleString("PONG")
+		case "INFO":
+			return info
+		}
+		return ErrorMessage("ERR unknown command '" + cmd[0] + "'")
+	}
+	var results []*Result
+	for _, inline := range []bool{false, true} {
+		scan := getScriptedScan(t, 0, respond)
+		scan.scanner.config.DoInline = inline
+		for _, cmd := range [][]string{{"PING"}, {"INFO"}, {"ECHO", "a b"}} {
+			if _, err := scan.SendCommand(cmd[0], cmd[1:]...); err != nil {
+				t.Fatal(err)
+			}
+		}
+		scripted := scan.conn.conn.(*scriptedIO)
+		for i, command := range scan.result.Commands {
+			if scripted.inline[i] != inline {
+				t.Errorf("inline=%v: %s was sent with the wrong syntax", inline, command)
+			}
+			if inline && scripted.written[i] != command+"\r\n" {
+				t.Errorf("recorded %q, but sent %q", command, scripted.written[i])
+			}
+		}
+		results = append(results, scan.result)
+	}
+	// The replies are decoded the same way, whichever syntax was used.
+	if !reflect.DeepEqual(results[0], results[1]) {
+		t.Errorf("inline results differ: %+v, %+v", results[0], results[1])
+	}
+}
+
+func TestProbeMultibulkPing(t *testing.T) {
+	var scripted *scriptedIO
+	scan := getScriptedScan(t, 0, func(cmd []string) RedisValue {
+		// This server rejects inline commands.
+		if scripted.inline[len(scripted.inline)-1] {
+			return ErrorMessage("ERR inline commands are disabled")
+		}
+		return SimpleString("PONG")
+	})
+	scan.scanner.config.DoInline = true
+	scripted = scan.conn.conn.(*scriptedIO)
+	ping, err := scan.SendCommand("PING")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := scan.probeMultibulkPing(ping); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(scripted.inline, []bool{true, false}) {
+		t.Errorf("expected an inline then a multibulk PING, got %v", scripted.inline)
+	}
+	if scan.result.MultibulkPingResponse != "PONG" || len(scan.result.RawCommandOutput) != 2 {
+		t.Errorf("expected the multibulk response to be recorded, got %+v", scan.result)
+	}
+
+	// Matching responses are not recorded.
+	scan = getScriptedScan(t, 0, func(cmd []string) RedisValue { return SimpleString("PONG") })
+	if err := scan.probeMultibulkPing(SimpleString("PONG")); err != nil {
+		t.Fatal(err)
+	}
+	if scan.result.MultibulkPingResponse != "" {
+		t.Errorf("unexpected multibulk response %q", scan.result.MultibulkPingResponse)
+	}
+}
+
+func TestProbeAdmin(t *testing.T) {
+	respond := func(cmd []string) RedisValue {
+		switch strings.Join(cmd, " ") {
+		case "LATENCY LATEST":
+			return RedisArray{RedisArray{BulkString("command"), Integer(1700000000), Integer(250), Integer(1200)}}
+		case "SLOWLOG GET 10":
+			return RedisArray{
+				RedisArray{Integer(14), Integer(1700000100), Integer(15000), RedisArray{BulkString("KEYS"), BulkString("*")}, BulkString("10.0.0.7:52814"), BulkString("worker")},
+				// redis before 4.0 does not give the client.
+				RedisArray{Integer(13), Integer(1700000000), Integer(12000), RedisArray{BulkString("FLUSHALL")}},
+			}
+		case "CLIENT LIST":
+			return BulkString("id=3 addr=10.0.0.7:52814 laddr=10.0.0.1:6379 fd=8 name=worker age=120 idle=0 flags=N db=0 cmd=client|list user=default\n" +
+				"id=5 addr=10.0.0.8:40112 fd=9 name= age=3 idle=3 flags=S db=0 cmd=replconf\n")
+		}
+		return ErrorMessage("ERR unknown command")
+	}
+	for _, redact := range []bool{false, true} {
+		scan := getScriptedScan(t, 0, respond)
+		scan.scanner.config.Redact = redact
+		if err := scan.probeAdmin(); err != nil {
+			t.Fatal(err)
+		}
+		expectedCommands := []string{"LATENCY LATEST", "SLOWLOG GET 10", "CLIENT LIST"}
+		if !reflect.DeepEqual(scan.result.Commands, expectedCommands) {
+			t.Errorf("expected commands %v, got %v", expectedCommands, scan.result.Commands)
+		}
+		expectedEvents := []LatencyEvent{{Event: "command", Timestamp: 1700000000, LatestMS: 250, MaxMS: 1200}}
+		if !reflect.DeepEqual(scan.result.LatencyEvents, expectedEvents) {
+			t.Errorf("expected events %+v, got %+v", expectedEvents, scan.result.LatencyEvents)
+		}
+		addr, name, localAddr, arg := "10.0.0.7:52814", "worker", "10.0.0.1:6379", "*"
+		if redact {
+			addr, name, localAddr, arg = redactedValue, redactedValue, redactedValue, redactedValue
+		}
+		expectedEntries := []SlowlogEntry{
+			{ID: 14, Timestamp: 1700000100, DurationMicros: 15000, Command: []string{"KEYS", arg}, ClientAddr: addr, ClientName: name},
+			{ID: 13, Timestamp: 1700000000, DurationMicros: 12000, Command: []string{"FLUSHALL"}},
+		}
+		if !reflect.DeepEqual(scan.result.SlowlogEntries, expectedEntries) {
+			t.Errorf("expected slowlog %+v, got %+v", expectedEntries, scan.result.SlowlogEntries)
+		}
+		otherAddr := "10.0.0.8:40112"
+		if redact {
+			otherAddr = redactedValue
+		}
+		expectedClients := []Client{
+			{ID: 3, Addr: addr, LocalAddr: localAddr, Name: name, User: "default", Age: 120, Flags: "N", Cmd: "client|list"},
+			{ID: 5, Addr: otherAddr, Age: 3, Idle: 3, Flags: "S", Cmd: "replconf"},
+		}
+		if !reflect.DeepEqual(scan.result.Clients, expectedClients) || scan.result.ClientCount != 2 {
+			t.Errorf("expected clients %+v, got %+v", expectedClients, scan.result.Clients)
+		}
+		for _, raw := range scan.result.RawCommandOutput {
+			if redacted := string(raw) == redactedValue; redacted != redact {
+				t.Errorf("redact=%v, but recorded raw reply %q", redact, raw)
+			}
+		}
+	}
+
+	scan := getScriptedScan(t, 0, func(cmd []string) RedisValue {
+		return ErrorMessage("NOPERM this user has no permissions to run the '" + strings.ToLower(cmd[0]) + "' command")
+	})
+	if err := scan.probeAdmin(); err != nil {
+		t.Fatal(err)
+	}
+	if scan.result.LatencyError == "" || scan.result.SlowlogError == "" || scan.result.ClientListError == "" || scan.result.Clients != nil {
+		t.Errorf("expected the errors to be recorded, got %+v", scan.result)
+	}
+}
+
+func TestValidateTLS(t *testing.T) {
+	tests := []struct {
+		flags Flags
+		valid bool
+	}{
+		{Flags{UseTLS: true, TLSSNI: "cache.example.com"}, true},
+		{Flags{UseTLS: true, TLSClientCert: "client.pem", TLSClientKey: "client.key"}, true},
+		{Flags{TLSSNI: "cache.example.com"}, false},
+		{Flags{UseTLS: true, TLSClientCert: "client.pem"}, false},
+		{Flags{UseTLS: true, TLSSNI: "cache.example.com", TLSFlags: zgrab2.TLSFlags{ServerName: "cache.example.com"}}, true},
+		{Flags{UseTLS: true, TLSSNI: "cache.example.com", TLSFlags: zgrab2.TLSFlags{ServerName: "other.example.com"}}, false},
+	}
+	for _, test := range tests {
+		if err := test.flags.Validate(nil); (err == nil) != test.valid {
+			t.Errorf("%+v: expected valid=%v, got %v", test.flags, test.valid, err)
+		}
+	}
+
+	flags := Flags{UseTLS: true, TLSSNI: "cache.example.com", TLSClientCert: "client.pem", TLSClientKey: "client.key"}
+	if err := flags.Validate(nil); err != nil {
+		t.Fatal(err)
+	}
+	if flags.ServerName != "cache.example.com" || flags.Certificates != "client.pem" || flags.CertificateKey != "client.key" {
+		t.Errorf("expected the aliases to set the TLS flags, got %+v", flags.TLSFlags)
+	}
+}
+
+// writeTestCertificate writes a new self-signed certificate for name, and
+// its key, to PEM files in dir, and returns their paths.
+func writeTestCertificate(t *testing.T, dir string, name string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawKey, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+".key")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: rawKey}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestScanTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zgrab2-redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	serverCert, serverKey := writeTestCertificate(t, dir, "server.example.com")
+	clientCert, clientKey := writeTestCertificate(t, dir, "client.example.com")
+
+	cert, err := tls.LoadX509KeyPair(serverCert, serverKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The server requires a client certificate, and records the server name
+	// it was sent.
+	serverNames := make(chan string, 1)
+	done := make(chan struct{})
+	defer close(done)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAnyClientCert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tlsConn := conn.(*tls.Conn)
+		if err := tlsConn.Handshake(); err != nil {
+			serverNames <- "handshake failed: " + err.Error()
+			return
+		}
+		serverNames <- tlsConn.ConnectionState().ServerName
+		buf := make([]byte, 1024)
+		if _, err := conn.Read(buf); err == nil {
+			conn.Write([]byte("+PONG\r\n"))
+		}
+		<-done
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	port := uint(addr.Port)
+	flags := &Flags{
+		BaseFlags:     zgrab2.BaseFlags{Timeout: 5 * time.Second},
+		UseTLS:        true,
+		TLSSNI:        "cache.example.com",
+		TLSClientCert: clientCert,
+		TLSClientKey:  clientKey,
+	}
+	if err := flags.Validate(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := zgrab2.ValidateTLSFlags(flags); err != nil {
+		t.Fatal(err)
+	}
+	scanner := new(Scanner)
+	if err := scanner.Init(flags); err != nil {
+		t.Fatal(err)
+	}
+	scan, err := scanner.StartScan(&zgrab2.ScanTarget{IP: addr.IP, Port: &port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer scan.Close()
+	if err := scan.handshake(); err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	if serverName := <-serverNames; serverName != "cache.example.com" {
+		t.Errorf("expected the --tls-sni server name, got %q", serverName)
+	}
+	if scan.result.TLSLog == nil || scan.result.TLSVersion == "" || scan.result.TLSCipherSuite == "" {
+		t.Errorf("expected the TLS log, version and cipher suite, got %+v", scan.result)
+	}
+	if reply, err := scan.SendCommand("PING"); err != nil || forceToString(reply) != "PONG" {
+		t.Errorf("expected PONG over TLS, got %v (%v)", reply, err)
+	}
+}
+
+func TestScanServerSpeaksTLS(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 1024)
+		if _, err := conn.Read(buf); err == nil {
+			// A TLS "unexpected message" alert, as sent by a TLS server
+			// that receives a plaintext command.
+			conn.Write([]byte{0x15, 0x03, 0x01, 0x00, 0x02, 0x02, 0x0a})
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	port := uint(addr.Port)
+	scanner := new(Scanner)
+	if err := scanner.Init(&Flags{BaseFlags: zgrab2.BaseFlags{Timeout: 5 * time.Second}}); err != nil {
+		t.Fatal(err)
+	}
+	status, _, err := scanner.Scan(context.Background(), zgrab2.ScanTarget{IP: addr.IP, Port: &port})
+	if status != zgrab2.SCAN_PROTOCOL_ERROR || err != zgrab2.ErrServerSpeaksTLS {
+		t.Errorf("expected ErrServerSpeaksTLS, got %s (%v)", status, err)
+	}
+}
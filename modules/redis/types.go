@@ -349,17 +349,46 @@ func (conn *Connection) write(data []byte) error {
 	return nil
 }
 
-// inlineQuote quotes arg if it contains any spaces, for use in inline commands.
+// inlineQuote quotes arg for use in inline commands, if it is empty or
+// contains spaces, quotes or other special characters, escaping them as the
+// server's parser expects, e.g. "a \"b\"\r\n".
 func inlineQuote(arg string) string {
-	if strings.ContainsAny(arg, " ") {
-		return "\"" + arg + "\""
+	needsQuotes := arg == ""
+	for i := 0; i < len(arg) && !needsQuotes; i++ {
+		c := arg[i]
+		needsQuotes = c <= ' ' || c >= 0x7f || c == '"' || c == '\'' || c == '\\'
 	}
-	return arg
+	if !needsQuotes {
+		return arg
+	}
+	var ret strings.Builder
+	ret.WriteByte('"')
+	for i := 0; i < len(arg); i++ {
+		switch c := arg[i]; c {
+		case '"', '\\':
+			ret.WriteByte('\\')
+			ret.WriteByte(c)
+		case '\n':
+			ret.WriteString(`\n`)
+		case '\r':
+			ret.WriteString(`\r`)
+		case '\t':
+			ret.WriteString(`\t`)
+		default:
+			if c < ' ' || c >= 0x7f {
+				fmt.Fprintf(&ret, `\x%02x`, c)
+			} else {
+				ret.WriteByte(c)
+			}
+		}
+	}
+	ret.WriteByte('"')
+	return ret.String()
 }
 
 // getInlineCommand gets the inline version of the given command+args: any
-// elements containing spaces are quoted, and the elements are joined together
-// with spaces.
+// elements that are empty or contain spaces or special characters are quoted,
+// and the elements are joined together with spaces.
 func getInlineCommand(cmd string, args ...string) string {
 	ret := make([]string, len(args)+1)
 	ret[0] = inlineQuote(cmd)
@@ -430,3 +459,93 @@ type CustomResponse struct {
 	Arguments string `json:"arguments,omitempty"`
 	Response  string `json:"response,omitempty"`
 }
+
+// SentinelMaster is a master monitored by a Sentinel, as described by
+// SENTINEL masters.
+type SentinelMaster struct {
+	// Name is the name the master is monitored under.
+	Name string `json:"name"`
+
+	// IP and Port are the master's address.
+	IP   string `json:"ip,omitempty"`
+	Port uint32 `json:"port,omitempty"`
+
+	// Flags is the Sentinel's view of the master, e.g. "master" or
+	// "master,s_down".
+	Flags string `json:"flags,omitempty"`
+
+	// Quorum is the number of Sentinels that need to agree that the master
+	// is down before it is failed over.
+	Quorum uint32 `json:"quorum"`
+
+	// NumSlaves and NumOtherSentinels are the numbers of replicas and of
+	// other Sentinels that this Sentinel knows of for the master.
+	NumSlaves         uint32 `json:"num_slaves"`
+	NumOtherSentinels uint32 `json:"num_other_sentinels"`
+}
+
+// SampledKey is a key sampled with SCAN: its name and its TYPE, but not its
+// value.
+type SampledKey struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+}
+
+// LatencyEvent is an event with a latency spike, as described by LATENCY
+// LATEST.
+type LatencyEvent struct {
+	// Event is the name of the event, e.g. "command" or "fork".
+	Event string `json:"event"`
+
+	// Timestamp is the Unix time of the latest spike.
+	Timestamp int64 `json:"timestamp"`
+
+	// LatestMS and MaxMS are the latest and the all-time maximum latencies,
+	// in milliseconds.
+	LatestMS int64 `json:"latest_ms"`
+	MaxMS    int64 `json:"max_ms"`
+}
+
+// SlowlogEntry is a command logged by the slow log, as described by
+// SLOWLOG GET.
+type SlowlogEntry struct {
+	ID        int64 `json:"id"`
+	Timestamp int64 `json:"timestamp"`
+
+	// DurationMicros is the time the command took to run, in microseconds.
+	DurationMicros int64 `json:"duration_us"`
+
+	// Command is the command and its arguments (which the server may have
+	// shortened); the arguments are redacted with --redact.
+	Command []string `json:"command,omitempty"`
+
+	// ClientAddr and ClientName identify the client that sent the command
+	// (only given by redis 4.0 and later).
+	ClientAddr string `json:"client_addr,omitempty"`
+	ClientName string `json:"client_name,omitempty"`
+}
+
+// Client is a client connection, as described by CLIENT LIST.
+type Client struct {
+	ID uint64 `json:"id"`
+
+	// Addr and LocalAddr are the addresses of the client and the server ends
+	// of the connection (the latter only given by redis 6.2 and later).
+	Addr      string `json:"addr,omitempty"`
+	LocalAddr string `json:"laddr,omitempty"`
+
+	Name string `json:"name,omitempty"`
+	User string `json:"user,omitempty"`
+
+	// Age and Idle are the connection's age and idle time in seconds.
+	Age  uint32 `json:"age"`
+	Idle uint32 `json:"idle"`
+
+	// Flags are the client flags, e.g. "N" for a normal client or "S" for a
+	// replica.
+	Flags string `json:"flags,omitempty"`
+	DB    uint32 `json:"db"`
+
+	// Cmd is the last command the client ran.
+	Cmd string `json:"cmd,omitempty"`
+}
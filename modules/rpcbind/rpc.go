@@ -0,0 +1,330 @@
+// ONC RPC (RFC 5531) client for the rpcbind module, implementing just the
+// portmapper's PMAPPROC_DUMP call (RFC 1833).
+
+package rpcbind
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+const (
+	// rpcVersion is the version of the RPC protocol.
+	rpcVersion = 2
+
+	// programPortmap is the program number of the portmapper / rpcbind.
+	programPortmap = 100000
+
+	// portmapVersion is version 2 of the portmapper protocol, which every
+	// rpcbind also implements.
+	portmapVersion = 2
+
+	// procDump is PMAPPROC_DUMP, which lists the registered mappings.
+	procDump = 4
+
+	// msgCall and msgReply are the message types.
+	msgCall  = 0
+	msgReply = 1
+
+	// replyAccepted and replyDenied are the reply statuses.
+	replyAccepted = 0
+	replyDenied   = 1
+
+	// acceptSuccess is the accept status of a successful call.
+	acceptSuccess = 0
+
+	// lastFragment is set in the record marking header of the last fragment
+	// of a record sent over TCP.
+	lastFragment = 0x80000000
+
+	// maxRecordSize bounds the size of a reply sent over TCP.
+	maxRecordSize = 1 << 20
+
+	// maxDatagramSize bounds the size of a reply sent over UDP.
+	maxDatagramSize = 65535
+
+	// maxMappings bounds the number of mappings parsed from a reply.
+	maxMappings = 4096
+
+	// maxUnmatchedReplies bounds the number of UDP replies with the wrong
+	// XID that are skipped.
+	maxUnmatchedReplies = 8
+)
+
+var (
+	// ErrInvalidReply is returned when the reply is not a valid RPC reply
+	// to the call.
+	ErrInvalidReply = errors.New("invalid RPC reply")
+
+	// ErrTooLarge is returned when the reply is larger than the limit.
+	ErrTooLarge = errors.New("RPC reply too large")
+
+	// ErrTooManyReplies is returned when the server sends too many UDP
+	// replies that do not match the call.
+	ErrTooManyReplies = errors.New("too many unmatched RPC replies")
+)
+
+// acceptStatusNames are the names of the accept statuses.
+var acceptStatusNames = map[uint32]string{
+	0: "SUCCESS",
+	1: "PROG_UNAVAIL",
+	2: "PROG_MISMATCH",
+	3: "PROC_UNAVAIL",
+	4: "GARBAGE_ARGS",
+	5: "SYSTEM_ERR",
+}
+
+// rejectStatusNames are the names of the reject statuses.
+var rejectStatusNames = map[uint32]string{
+	0: "RPC_MISMATCH",
+	1: "AUTH_ERROR",
+}
+
+// statusName returns the name of status in names, or its number.
+func statusName(names map[uint32]string, status uint32) string {
+	if name, ok := names[status]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", status)
+}
+
+// RPCError is returned when the server accepts the call but fails it, or
+// denies it.
+type RPCError struct {
+	// Status is the name of the accept or reject status, e.g.
+	// "PROG_UNAVAIL" or "AUTH_ERROR".
+	Status string
+}
+
+func (err *RPCError) Error() string {
+	return "RPC call failed: " + err.Status
+}
+
+// protocolNames are the names of the IP protocols used in mappings.
+var protocolNames = map[uint32]string{
+	6:  "tcp",
+	17: "udp",
+}
+
+// programNames are the names of well-known RPC programs.
+var programNames = map[uint32]string{
+	100000: "portmapper",
+	100001: "rstatd",
+	100002: "rusersd",
+	100003: "nfs",
+	100004: "ypserv",
+	100005: "mountd",
+	100007: "ypbind",
+	100008: "walld",
+	100009: "yppasswdd",
+	100011: "rquotad",
+	100012: "sprayd",
+	100021: "nlockmgr",
+	100024: "status",
+	100068: "cmsd",
+	100069: "ypxfrd",
+	100083: "ttdbserverd",
+	100227: "nfs_acl",
+	150001: "pcnfsd",
+	300019: "amd",
+	391002: "sgi_fam",
+}
+
+// Mapping is an entry in the portmapper's list of registered programs.
+type Mapping struct {
+	// Program is the RPC program number, e.g. 100003 for NFS.
+	Program uint32 `json:"program"`
+
+	// ProgramName is the name of well-known programs, e.g. "nfs".
+	ProgramName string `json:"program_name,omitempty"`
+
+	// Version is the program's version.
+	Version uint32 `json:"version"`
+
+	// Protocol is the transport the program listens on: "tcp", "udp", or
+	// the IP protocol number.
+	Protocol string `json:"protocol"`
+
+	// Port is the port the program listens on.
+	Port uint32 `json:"port"`
+}
+
+// newXID returns a random transaction ID.
+func newXID() uint32 {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return binary.BigEndian.Uint32(b)
+}
+
+// encodeDumpCall encodes a PMAPPROC_DUMP call with AUTH_NONE credentials.
+func encodeDumpCall(xid uint32) []byte {
+	fields := []uint32{
+		xid, msgCall, rpcVersion, programPortmap, portmapVersion, procDump,
+		0, 0, // credentials: AUTH_NONE, no body
+		0, 0, // verifier: AUTH_NONE, no body
+	}
+	ret := make([]byte, 4*len(fields))
+	for i, v := range fields {
+		binary.BigEndian.PutUint32(ret[4*i:], v)
+	}
+	return ret
+}
+
+// xdrReader reads XDR values from a buffer, recording the first error.
+type xdrReader struct {
+	data []byte
+	err  error
+}
+
+// uint32 reads an unsigned integer, or returns 0 if none is left.
+func (r *xdrReader) uint32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.data) < 4 {
+		r.err = ErrInvalidReply
+		return 0
+	}
+	ret := binary.BigEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return ret
+}
+
+// skipOpaque skips a variable-length opaque value, with its padding.
+func (r *xdrReader) skipOpaque() {
+	n := r.uint32()
+	if r.err != nil {
+		return
+	}
+	padded := (uint64(n) + 3) &^ 3
+	if uint64(len(r.data)) < padded {
+		r.err = ErrInvalidReply
+		return
+	}
+	r.data = r.data[padded:]
+}
+
+// parseDumpReply parses the reply to the PMAPPROC_DUMP call with the given
+// XID. If the call was denied or failed, it returns an *RPCError.
+func parseDumpReply(reply []byte, xid uint32) ([]Mapping, error) {
+	r := &xdrReader{data: reply}
+	if r.uint32() != xid || r.uint32() != msgReply {
+		if r.err != nil {
+			return nil, r.err
+		}
+		return nil, ErrInvalidReply
+	}
+	switch r.uint32() {
+	case replyAccepted:
+		r.uint32() // verifier flavor
+		r.skipOpaque()
+		status := r.uint32()
+		if r.err != nil {
+			return nil, r.err
+		}
+		if status != acceptSuccess {
+			return nil, &RPCError{Status: statusName(acceptStatusNames, status)}
+		}
+	case replyDenied:
+		status := r.uint32()
+		if r.err != nil {
+			return nil, r.err
+		}
+		return nil, &RPCError{Status: statusName(rejectStatusNames, status)}
+	default:
+		if r.err != nil {
+			return nil, r.err
+		}
+		return nil, ErrInvalidReply
+	}
+	var ret []Mapping
+	for r.uint32() == 1 {
+		if len(ret) >= maxMappings {
+			return ret, ErrTooLarge
+		}
+		mapping := Mapping{
+			Program: r.uint32(),
+			Version: r.uint32(),
+		}
+		protocol := r.uint32()
+		mapping.Port = r.uint32()
+		if r.err != nil {
+			break
+		}
+		mapping.ProgramName = programNames[mapping.Program]
+		if name, ok := protocolNames[protocol]; ok {
+			mapping.Protocol = name
+		} else {
+			mapping.Protocol = fmt.Sprintf("%d", protocol)
+		}
+		ret = append(ret, mapping)
+	}
+	return ret, r.err
+}
+
+// readRecord reads a record sent over TCP, reassembling its fragments.
+func readRecord(conn io.Reader) ([]byte, error) {
+	var ret []byte
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return ret, err
+		}
+		marker := binary.BigEndian.Uint32(header)
+		size := marker &^ lastFragment
+		if uint64(len(ret))+uint64(size) > maxRecordSize {
+			return ret, ErrTooLarge
+		}
+		fragment := make([]byte, size)
+		if _, err := io.ReadFull(conn, fragment); err != nil {
+			return ret, err
+		}
+		ret = append(ret, fragment...)
+		if marker&lastFragment != 0 {
+			return ret, nil
+		}
+	}
+}
+
+// DumpTCP sends a PMAPPROC_DUMP call over a TCP connection, and returns the
+// mappings in the reply.
+func DumpTCP(conn net.Conn) ([]Mapping, error) {
+	xid := newXID()
+	call := encodeDumpCall(xid)
+	record := make([]byte, 4, 4+len(call))
+	binary.BigEndian.PutUint32(record, lastFragment|uint32(len(call)))
+	if _, err := conn.Write(append(record, call...)); err != nil {
+		return nil, err
+	}
+	reply, err := readRecord(conn)
+	if err != nil {
+		return nil, err
+	}
+	return parseDumpReply(reply, xid)
+}
+
+// DumpUDP sends a PMAPPROC_DUMP call over a UDP connection, and returns the
+// mappings in the reply. Datagrams that are not replies to the call are
+// skipped.
+func DumpUDP(conn net.Conn) ([]Mapping, error) {
+	xid := newXID()
+	if _, err := conn.Write(encodeDumpCall(xid)); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, maxDatagramSize)
+	for i := 0; i < maxUnmatchedReplies; i++ {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		if n < 4 || binary.BigEndian.Uint32(buf) != xid {
+			continue
+		}
+		return parseDumpReply(buf[:n], xid)
+	}
+	return nil, ErrTooManyReplies
+}
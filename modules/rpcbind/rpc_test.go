@@ -0,0 +1,144 @@
+package rpcbind
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+// encodeWords encodes XDR unsigned integers.
+func encodeWords(words ...uint32) []byte {
+	ret := make([]byte, 4*len(words))
+	for i, v := range words {
+		binary.BigEndian.PutUint32(ret[4*i:], v)
+	}
+	return ret
+}
+
+// dumpReply returns a successful reply to the call with the given XID,
+// listing the portmapper, NFS and mountd.
+func dumpReply(xid uint32) []byte {
+	return encodeWords(
+		xid, msgReply, replyAccepted,
+		0, 0, // verifier: AUTH_NONE, no body
+		acceptSuccess,
+		1, 100000, 2, 6, 111,
+		1, 100003, 3, 6, 2049,
+		1, 100005, 3, 17, 20048,
+		1, 100005, 3, 6, 20048,
+		0,
+	)
+}
+
+var expectedMappings = []Mapping{
+	{Program: 100000, ProgramName: "portmapper", Version: 2, Protocol: "tcp", Port: 111},
+	{Program: 100003, ProgramName: "nfs", Version: 3, Protocol: "tcp", Port: 2049},
+	{Program: 100005, ProgramName: "mountd", Version: 3, Protocol: "udp", Port: 20048},
+	{Program: 100005, ProgramName: "mountd", Version: 3, Protocol: "tcp", Port: 20048},
+}
+
+// serveTCP accepts connections on listener, answering each DUMP call with
+// dumpReply split into two fragments.
+func serveTCP(t *testing.T, listener net.Listener) {
+	for {
+		c, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(c net.Conn) {
+			defer c.Close()
+			call := make([]byte, 44)
+			if _, err := io.ReadFull(c, call); err != nil {
+				t.Errorf("reading call: %v", err)
+				return
+			}
+			if marker := binary.BigEndian.Uint32(call); marker != lastFragment|40 {
+				t.Errorf("unexpected record marker 0x%08x", marker)
+			}
+			if !reflect.DeepEqual(call[12:28], encodeWords(rpcVersion, programPortmap, portmapVersion, procDump)) {
+				t.Errorf("unexpected call %x", call)
+			}
+			reply := dumpReply(binary.BigEndian.Uint32(call[4:]))
+			c.Write(append(encodeWords(24), reply[:24]...))
+			c.Write(append(encodeWords(lastFragment|uint32(len(reply)-24)), reply[24:]...))
+		}(c)
+	}
+}
+
+func TestScanTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveTCP(t, listener)
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	scanner := &Scanner{config: &Flags{}}
+	scanner.config.Timeout = 5 * time.Second
+	status, res, err := scanner.Scan(context.Background(), zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port})
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	result := res.(*ScanResults)
+	if result.Transport != "tcp" || !reflect.DeepEqual(result.Mappings, expectedMappings) {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if !reflect.DeepEqual(result.Programs, []string{"portmapper", "nfs", "mountd"}) {
+		t.Errorf("unexpected programs %v", result.Programs)
+	}
+}
+
+func TestDumpUDP(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	go func() {
+		call := make([]byte, 100)
+		n, addr, err := server.ReadFrom(call)
+		if err != nil || n != 40 {
+			t.Errorf("unexpected call %x: %v", call[:n], err)
+			return
+		}
+		xid := binary.BigEndian.Uint32(call)
+		// A stale reply to another call is skipped.
+		server.WriteTo(dumpReply(xid+1), addr)
+		server.WriteTo(dumpReply(xid), addr)
+	}()
+	conn, err := net.Dial("udp", server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	mappings, err := DumpUDP(conn)
+	if err != nil || !reflect.DeepEqual(mappings, expectedMappings) {
+		t.Errorf("unexpected mappings %+v: %v", mappings, err)
+	}
+}
+
+func TestParseDumpReply(t *testing.T) {
+	denied := encodeWords(7, msgReply, replyDenied, 1, 1)
+	if _, err := parseDumpReply(denied, 7); !reflect.DeepEqual(err, &RPCError{Status: "AUTH_ERROR"}) {
+		t.Errorf("expected AUTH_ERROR, got %v", err)
+	}
+	unavailable := encodeWords(7, msgReply, replyAccepted, 0, 4, 0xdeadbeef, 3)
+	if _, err := parseDumpReply(unavailable, 7); !reflect.DeepEqual(err, &RPCError{Status: "PROC_UNAVAIL"}) {
+		t.Errorf("expected PROC_UNAVAIL, got %v", err)
+	}
+	if _, err := parseDumpReply(dumpReply(8), 7); err != ErrInvalidReply {
+		t.Errorf("expected a mismatched XID to be invalid, got %v", err)
+	}
+	reply := dumpReply(7)
+	mappings, err := parseDumpReply(reply[:len(reply)-12], 7)
+	if err != ErrInvalidReply || !reflect.DeepEqual(mappings, expectedMappings[:3]) {
+		t.Errorf("expected the mappings before the truncation, got %+v: %v", mappings, err)
+	}
+}
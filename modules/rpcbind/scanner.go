@@ -0,0 +1,181 @@
+// Package rpcbind provides a zgrab2 module that lists the RPC programs
+// registered with a portmapper / rpcbind.
+// Default Port: 111 (TCP, or UDP with --udp)
+//
+// The scanner sends a PMAPPROC_DUMP call (version 2 of the portmapper
+// protocol, with no authentication) and records the registered programs
+// with their versions, transports and ports, which reveal services such as
+// NFS, mountd and NIS.
+package rpcbind
+
+import (
+	"context"
+	"net"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmap/zgrab2"
+)
+
+// ScanResults instances are returned by the module's Scan function.
+type ScanResults struct {
+	// Transport is the transport the call was sent over: "tcp" or "udp".
+	Transport string `json:"transport"`
+
+	// Mappings lists the registered programs.
+	Mappings []Mapping `json:"mappings,omitempty"`
+
+	// Programs lists the names of the distinct well-known programs
+	// registered, e.g. "nfs" and "mountd".
+	Programs []string `json:"programs,omitempty"`
+
+	// RPCError is the accept or reject status, if the call failed, e.g.
+	// "PROC_UNAVAIL" or "AUTH_ERROR".
+	RPCError string `json:"rpc_error,omitempty"`
+}
+
+// Flags holds the command-line configuration for the rpcbind scan module.
+// Populated by the framework.
+type Flags struct {
+	zgrab2.BaseFlags
+	zgrab2.UDPFlags
+
+	// UDP indicates that the call should be sent over UDP instead of TCP.
+	UDP bool `long:"udp" description:"Send the call over UDP instead of TCP"`
+
+	// Verbose indicates that there should be more verbose logging.
+	Verbose bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
+}
+
+// Module implements the zgrab2.Module interface.
+type Module struct {
+}
+
+// Scanner implements the zgrab2.Scanner interface.
+type Scanner struct {
+	config *Flags
+}
+
+// RegisterModule registers the zgrab2 module.
+func RegisterModule() {
+	var module Module
+	_, err := zgrab2.AddCommand("rpcbind", "rpcbind", module.Description(), 111, &module)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewFlags returns a default Flags object.
+func (module *Module) NewFlags() interface{} {
+	return new(Flags)
+}
+
+// NewScanner returns a new Scanner instance.
+func (module *Module) NewScanner() zgrab2.Scanner {
+	return new(Scanner)
+}
+
+// Description returns an overview of this module.
+func (module *Module) Description() string {
+	return "List the RPC programs registered with a portmapper / rpcbind"
+}
+
+// Validate checks that the flags are valid.
+// On success, returns nil.
+// On failure, returns an error instance describing the error.
+func (flags *Flags) Validate(args []string) error {
+	return nil
+}
+
+// Help returns the module's help string.
+func (flags *Flags) Help() string {
+	return ""
+}
+
+// Init initializes the Scanner.
+func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
+	f, _ := flags.(*Flags)
+	scanner.config = f
+	return nil
+}
+
+// InitPerSender initializes the scanner for a given sender.
+func (scanner *Scanner) InitPerSender(senderID int) error {
+	return nil
+}
+
+// GetName returns the Scanner name defined in the Flags.
+func (scanner *Scanner) GetName() string {
+	return scanner.config.Name
+}
+
+// GetTrigger returns the Trigger defined in the Flags.
+func (scanner *Scanner) GetTrigger() string {
+	return scanner.config.Trigger
+}
+
+// Protocol returns the protocol identifier of the scan.
+func (scanner *Scanner) Protocol() string {
+	return "rpcbind"
+}
+
+// Scan performs the rpcbind scan.
+//  1. Open a TCP connection to the target port (default 111), or a UDP
+//     one with --udp.
+//  2. Send a PMAPPROC_DUMP call, framed with a record marking header over
+//     TCP, and read the matching reply. If it is not a valid reply, fail
+//     with a protocol error.
+//  3. If the call was denied or failed, record the status and fail with an
+//     application error; otherwise record the mappings.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	var conn net.Conn
+	var err error
+	result := &ScanResults{Transport: "tcp"}
+	if scanner.config.UDP {
+		result.Transport = "udp"
+		conn, err = target.OpenUDP(&scanner.config.BaseFlags, &scanner.config.UDPFlags)
+	} else {
+		conn, err = target.Open(&scanner.config.BaseFlags)
+	}
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	defer conn.Close()
+
+	var mappings []Mapping
+	if scanner.config.UDP {
+		mappings, err = DumpUDP(conn)
+	} else {
+		mappings, err = DumpTCP(conn)
+	}
+	if rpcErr, ok := err.(*RPCError); ok {
+		result.RPCError = rpcErr.Status
+		return zgrab2.SCAN_APPLICATION_ERROR, result, err
+	}
+	status := zgrab2.SCAN_SUCCESS
+	if err == ErrInvalidReply || err == ErrTooLarge || err == ErrTooManyReplies {
+		status = zgrab2.SCAN_PROTOCOL_ERROR
+	} else if err != nil {
+		status = zgrab2.TryGetScanStatus(err)
+	}
+	if len(mappings) == 0 && err != nil {
+		return status, nil, err
+	}
+	// A truncated reply still gives the mappings before the error.
+	result.Mappings = mappings
+	result.Programs = programs(mappings)
+	return status, result, err
+}
+
+// programs returns the names of the distinct well-known programs in
+// mappings, in the order they first appear.
+func programs(mappings []Mapping) []string {
+	var ret []string
+	seen := make(map[string]bool)
+	for _, mapping := range mappings {
+		if mapping.ProgramName != "" && !seen[mapping.ProgramName] {
+			seen[mapping.ProgramName] = true
+			ret = append(ret, mapping.ProgramName)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,169 @@
+// rsync daemon protocol client for the rsync module.
+// The daemon's side of the handshake is implemented in rsync's
+// clientserver.c.
+
+package rsync
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+)
+
+// maxLineLength bounds the length of a single line sent by the server.
+const maxLineLength = 4096
+
+const (
+	// greetingPrefix begins the server's greeting and its control lines.
+	greetingPrefix = "@RSYNCD: "
+
+	// errorPrefix begins the server's error lines.
+	errorPrefix = "@ERROR"
+
+	// responseOK is sent when an anonymous module is selected.
+	responseOK = "@RSYNCD: OK"
+
+	// responseExit terminates the module list.
+	responseExit = "@RSYNCD: EXIT"
+
+	// responseAuthRequired, followed by a challenge, is sent when the
+	// selected module requires authentication.
+	responseAuthRequired = "@RSYNCD: AUTHREQD"
+)
+
+var (
+	// ErrInvalidGreeting is returned when the server's first line is not an
+	// @RSYNCD greeting.
+	ErrInvalidGreeting = errors.New("invalid rsync greeting")
+
+	// ErrLineTooLong is returned when the server sends a line longer than
+	// maxLineLength.
+	ErrLineTooLong = errors.New("line too long")
+)
+
+// Greeting is the server's @RSYNCD greeting.
+type Greeting struct {
+	// Raw is the full greeting line.
+	Raw string `json:"raw"`
+
+	// Version is the server's protocol version, e.g. "31.0".
+	Version string `json:"version"`
+
+	// Digests lists the checksum digests advertised after the version by
+	// rsync 3.2 and later, e.g. "sha512", "md5".
+	Digests []string `json:"digests,omitempty"`
+}
+
+// ParseGreeting parses an @RSYNCD greeting line.
+func ParseGreeting(line string) (*Greeting, error) {
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, greetingPrefix) {
+		return nil, ErrInvalidGreeting
+	}
+	fields := strings.Fields(line[len(greetingPrefix):])
+	if len(fields) == 0 {
+		return nil, ErrInvalidGreeting
+	}
+	ret := &Greeting{Raw: line, Version: fields[0]}
+	if len(fields) > 1 {
+		ret.Digests = fields[1:]
+	}
+	return ret, nil
+}
+
+// Connection wraps the state of a connection to an rsync daemon.
+type Connection struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewConnection returns a Connection over the given net.Conn.
+func NewConnection(conn net.Conn) *Connection {
+	return &Connection{conn: conn, reader: bufio.NewReaderSize(conn, maxLineLength)}
+}
+
+// Send writes a single line, appending the line terminator.
+func (c *Connection) Send(line string) error {
+	_, err := c.conn.Write([]byte(line + "\n"))
+	return err
+}
+
+// ReadLine reads the next line, without its terminator.
+func (c *Connection) ReadLine() (string, error) {
+	line, err := c.reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return "", ErrLineTooLong
+	}
+	if err != nil && len(line) == 0 {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// Handshake reads the server's greeting and echoes its version back.
+func (c *Connection) Handshake() (*Greeting, error) {
+	line, err := c.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	greeting, err := ParseGreeting(line)
+	if err != nil {
+		return nil, err
+	}
+	return greeting, c.Send(greetingPrefix + greeting.Version)
+}
+
+// ReadUntil reads lines until one is a control line ending the exchange
+// (done returns true for it) or an @ERROR line. It returns the lines read
+// before it, and the terminating line.
+func (c *Connection) ReadUntil(done func(string) bool) ([]string, string, error) {
+	var lines []string
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return lines, "", err
+		}
+		if done(line) || strings.HasPrefix(line, errorPrefix) {
+			return lines, line, nil
+		}
+		lines = append(lines, line)
+	}
+}
+
+// DaemonModule is an entry in the daemon's module list.
+type DaemonModule struct {
+	// Name is the module's name.
+	Name string `json:"name"`
+
+	// Comment is the module's description.
+	Comment string `json:"comment,omitempty"`
+
+	// AuthRequired is true if selecting the module returned AUTHREQD, and
+	// false if it returned OK. It is absent if the module was not checked.
+	AuthRequired *bool `json:"auth_required,omitempty"`
+
+	// Response is the server's final response to selecting the module,
+	// e.g. "@RSYNCD: OK" or "@ERROR: access denied to backup from ...".
+	Response string `json:"response,omitempty"`
+}
+
+// parseListing splits the lines sent in response to an empty module
+// request into the MOTD and the module list. The daemon formats each module
+// as "%-15s\t%s"; other lines are taken to be the MOTD.
+func parseListing(lines []string) (string, []*DaemonModule) {
+	var motd []string
+	var modules []*DaemonModule
+	for _, line := range lines {
+		i := strings.IndexByte(line, '\t')
+		if i < 0 || strings.TrimSpace(line[:i]) == "" {
+			motd = append(motd, line)
+			continue
+		}
+		modules = append(modules, &DaemonModule{
+			Name:    strings.TrimSpace(line[:i]),
+			Comment: strings.TrimSpace(line[i+1:]),
+		})
+	}
+	return strings.TrimRight(strings.Join(motd, "\n"), "\n"), modules
+}
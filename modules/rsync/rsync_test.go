@@ -0,0 +1,90 @@
+package rsync
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+// serveDaemon accepts connections on listener, answering as an rsync daemon
+// with a MOTD, a public module and a private one.
+func serveDaemon(t *testing.T, listener net.Listener) {
+	for {
+		c, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(c net.Conn) {
+			defer c.Close()
+			reader := bufio.NewReader(c)
+			c.Write([]byte("@RSYNCD: 31.0 sha512 sha256 sha1 md5 md4\n"))
+			if line, _ := reader.ReadString('\n'); line != "@RSYNCD: 31.0\n" {
+				t.Errorf("unexpected client greeting %q", line)
+				return
+			}
+			line, _ := reader.ReadString('\n')
+			c.Write([]byte("Welcome to the mirror\n\n"))
+			switch line {
+			case "\n":
+				c.Write([]byte("pub            \tPublic files\nbackup         \t\n@RSYNCD: EXIT\n"))
+			case "pub\n":
+				c.Write([]byte("@RSYNCD: OK\n"))
+			case "backup\n":
+				c.Write([]byte("@RSYNCD: AUTHREQD 7Ja6vKbZ5jFqL0hM8AMSnA\n"))
+			default:
+				c.Write([]byte("@ERROR: Unknown module '" + strings.TrimSpace(line) + "'\n"))
+			}
+		}(c)
+	}
+}
+
+func TestScan(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveDaemon(t, listener)
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	scanner := &Scanner{config: &Flags{CheckAuth: true, MaxModules: 32}}
+	scanner.config.Timeout = 5 * time.Second
+	status, res, err := scanner.Scan(context.Background(), zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port})
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	result := res.(*ScanResults)
+	if result.Greeting.Version != "31.0" || !reflect.DeepEqual(result.Greeting.Digests, []string{"sha512", "sha256", "sha1", "md5", "md4"}) {
+		t.Errorf("unexpected greeting: %+v", result.Greeting)
+	}
+	if result.MOTD != "Welcome to the mirror" {
+		t.Errorf("unexpected MOTD %q", result.MOTD)
+	}
+	if len(result.Modules) != 2 || result.Modules[0].Name != "pub" || result.Modules[0].Comment != "Public files" || result.Modules[1].Name != "backup" {
+		t.Fatalf("unexpected modules: %+v", result.Modules)
+	}
+	if auth := result.Modules[0].AuthRequired; auth == nil || *auth {
+		t.Errorf("expected pub to be anonymous, got %+v", result.Modules[0])
+	}
+	if auth := result.Modules[1].AuthRequired; auth == nil || !*auth {
+		t.Errorf("expected backup to require auth, got %+v", result.Modules[1])
+	}
+	if result.AnonymousModules != 1 {
+		t.Errorf("expected 1 anonymous module, got %d", result.AnonymousModules)
+	}
+}
+
+func TestParseGreeting(t *testing.T) {
+	if _, err := ParseGreeting("SSH-2.0-OpenSSH_8.2\r\n"); err != ErrInvalidGreeting {
+		t.Errorf("expected invalid greeting, got %v", err)
+	}
+	greeting, err := ParseGreeting("@RSYNCD: 30.0\n")
+	if err != nil || greeting.Version != "30.0" || greeting.Digests != nil {
+		t.Errorf("unexpected greeting %+v: %v", greeting, err)
+	}
+}
@@ -0,0 +1,219 @@
+// Package rsync provides a zgrab2 module that scans for rsync daemons.
+// Default Port: 873 (TCP)
+//
+// The scanner reads the server's @RSYNCD greeting, echoes its protocol
+// version back, and sends an empty module request, recording the MOTD and
+// the modules the daemon lists.
+//
+// If --check-auth is set, it then reconnects once for each listed module (up
+// to --max-modules) and selects it, recording whether the daemon requires
+// authentication (@RSYNCD: AUTHREQD) or grants anonymous access
+// (@RSYNCD: OK).
+package rsync
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmap/zgrab2"
+)
+
+// ScanResults instances are returned by the module's Scan function.
+type ScanResults struct {
+	// Greeting is the server's @RSYNCD greeting.
+	Greeting *Greeting `json:"greeting,omitempty"`
+
+	// MOTD is the message of the day sent before the module list.
+	MOTD string `json:"motd,omitempty"`
+
+	// Modules lists the modules returned by the empty module request.
+	Modules []*DaemonModule `json:"modules,omitempty"`
+
+	// AnonymousModules is the number of checked modules that were
+	// accessible without authentication.
+	AnonymousModules int `json:"anonymous_modules,omitempty"`
+
+	// Error is the @ERROR line sent in response to the module request, if
+	// any.
+	Error string `json:"error,omitempty"`
+}
+
+// Flags holds the command-line configuration for the rsync scan module.
+// Populated by the framework.
+type Flags struct {
+	zgrab2.BaseFlags
+
+	// CheckAuth indicates that each listed module should be selected to
+	// check whether it requires authentication.
+	CheckAuth bool `long:"check-auth" description:"Select each listed module to check whether it requires authentication"`
+
+	// MaxModules bounds the number of modules checked with --check-auth.
+	MaxModules int `long:"max-modules" default:"32" description:"Maximum number of modules to check with --check-auth"`
+
+	// Verbose indicates that there should be more verbose logging.
+	Verbose bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
+}
+
+// Module implements the zgrab2.Module interface.
+type Module struct {
+}
+
+// Scanner implements the zgrab2.Scanner interface.
+type Scanner struct {
+	config *Flags
+}
+
+// RegisterModule registers the zgrab2 module.
+func RegisterModule() {
+	var module Module
+	_, err := zgrab2.AddCommand("rsync", "rsync", module.Description(), 873, &module)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewFlags returns a default Flags object.
+func (module *Module) NewFlags() interface{} {
+	return new(Flags)
+}
+
+// NewScanner returns a new Scanner instance.
+func (module *Module) NewScanner() zgrab2.Scanner {
+	return new(Scanner)
+}
+
+// Description returns an overview of this module.
+func (module *Module) Description() string {
+	return "Read an rsync daemon's greeting and list its modules"
+}
+
+// Validate checks that the flags are valid.
+// On success, returns nil.
+// On failure, returns an error instance describing the error.
+func (flags *Flags) Validate(args []string) error {
+	return nil
+}
+
+// Help returns the module's help string.
+func (flags *Flags) Help() string {
+	return ""
+}
+
+// Init initializes the Scanner.
+func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
+	f, _ := flags.(*Flags)
+	scanner.config = f
+	return nil
+}
+
+// InitPerSender initializes the scanner for a given sender.
+func (scanner *Scanner) InitPerSender(senderID int) error {
+	return nil
+}
+
+// GetName returns the Scanner name defined in the Flags.
+func (scanner *Scanner) GetName() string {
+	return scanner.config.Name
+}
+
+// GetTrigger returns the Trigger defined in the Flags.
+func (scanner *Scanner) GetTrigger() string {
+	return scanner.config.Trigger
+}
+
+// Protocol returns the protocol identifier of the scan.
+func (scanner *Scanner) Protocol() string {
+	return "rsync"
+}
+
+// checkModule opens a new connection and selects module, recording the
+// server's response.
+func (scanner *Scanner) checkModule(target *zgrab2.ScanTarget, module *DaemonModule) error {
+	c, err := target.Open(&scanner.config.BaseFlags)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	conn := NewConnection(c)
+	if _, err := conn.Handshake(); err != nil {
+		return err
+	}
+	if err := conn.Send(module.Name); err != nil {
+		return err
+	}
+	_, response, err := conn.ReadUntil(func(line string) bool {
+		return line == responseOK || strings.HasPrefix(line, responseAuthRequired)
+	})
+	if err != nil {
+		return err
+	}
+	module.Response = response
+	if !strings.HasPrefix(response, errorPrefix) {
+		authRequired := strings.HasPrefix(response, responseAuthRequired)
+		module.AuthRequired = &authRequired
+	}
+	return nil
+}
+
+// Scan performs the rsync scan.
+//  1. Open a TCP connection to the target port (default 873).
+//  2. Read the @RSYNCD greeting and echo its version. If the greeting is
+//     invalid, fail with a protocol error.
+//  3. Send an empty module request and read the MOTD and module list up to
+//     @RSYNCD: EXIT. If the server sends @ERROR instead, record it and fail
+//     with an application error.
+//  4. If --check-auth is set, select each listed module on a new
+//     connection and record whether it requires authentication.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	c, err := target.Open(&scanner.config.BaseFlags)
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	defer c.Close()
+	conn := NewConnection(c)
+
+	greeting, err := conn.Handshake()
+	if greeting == nil {
+		if err == ErrInvalidGreeting {
+			return zgrab2.SCAN_PROTOCOL_ERROR, nil, err
+		}
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	result := &ScanResults{Greeting: greeting}
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+
+	if err := conn.Send(""); err != nil {
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+	lines, terminator, err := conn.ReadUntil(func(line string) bool {
+		return line == responseExit
+	})
+	result.MOTD, result.Modules = parseListing(lines)
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+	if strings.HasPrefix(terminator, errorPrefix) {
+		result.Error = terminator
+		return zgrab2.SCAN_APPLICATION_ERROR, result, errors.New(terminator)
+	}
+	c.Close()
+
+	if scanner.config.CheckAuth {
+		for i, module := range result.Modules {
+			if i >= scanner.config.MaxModules {
+				break
+			}
+			if err := scanner.checkModule(&target, module); err != nil {
+				return zgrab2.TryGetScanStatus(err), result, err
+			}
+			if module.AuthRequired != nil && !*module.AuthRequired {
+				result.AnonymousModules++
+			}
+		}
+	}
+	return zgrab2.SCAN_SUCCESS, result, nil
+}
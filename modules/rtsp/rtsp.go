@@ -0,0 +1,150 @@
+// RTSP 1.0 client for the rtsp module.
+// https://tools.ietf.org/html/rfc2326
+
+package rtsp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxBodySize is the maximum number of bytes of a response body (e.g. the SDP
+// returned by DESCRIBE) that is read and recorded.
+const maxBodySize = 0x4000
+
+var (
+	// ErrInvalidStatusLine is returned when the server's response does not
+	// begin with an RTSP status line.
+	ErrInvalidStatusLine = errors.New("invalid RTSP status line")
+
+	// ErrCSeqMismatch is returned when the CSeq of a response does not match
+	// that of the request.
+	ErrCSeqMismatch = errors.New("response CSeq does not match request")
+)
+
+// Response is a parsed RTSP response.
+type Response struct {
+	// StatusLine is the full first line of the response.
+	StatusLine string `json:"status_line"`
+
+	// Version is the protocol version from the status line, e.g. "RTSP/1.0".
+	Version string `json:"version"`
+
+	// StatusCode is the numeric status code, e.g. 200.
+	StatusCode int `json:"status_code"`
+
+	// StatusText is the reason phrase, e.g. "OK".
+	StatusText string `json:"status_text,omitempty"`
+
+	// Headers holds the response headers, keyed by their canonical names.
+	Headers map[string][]string `json:"headers,omitempty"`
+
+	// Body is the response body, up to maxBodySize bytes.
+	Body string `json:"body,omitempty"`
+}
+
+// Connection wraps the state of an RTSP connection.
+type Connection struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	cseq   int
+}
+
+// NewConnection returns a Connection over the given net.Conn.
+func NewConnection(conn net.Conn) *Connection {
+	return &Connection{conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// SendRequest sends a request for the given method and URL, with the next
+// CSeq and any extra headers, and reads the server's response. If the
+// response carries a CSeq, it must match that of the request.
+func (c *Connection) SendRequest(method string, url string, headers map[string]string) (*Response, error) {
+	c.cseq++
+	req := fmt.Sprintf("%s %s RTSP/1.0\r\nCSeq: %d\r\n", method, url, c.cseq)
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		req += fmt.Sprintf("%s: %s\r\n", k, headers[k])
+	}
+	req += "\r\n"
+	if _, err := c.conn.Write([]byte(req)); err != nil {
+		return nil, err
+	}
+	resp, err := c.readResponse()
+	if err != nil {
+		return resp, err
+	}
+	if cseq, ok := resp.Headers["Cseq"]; ok && len(cseq) > 0 {
+		if n, err := strconv.Atoi(strings.TrimSpace(cseq[0])); err != nil || n != c.cseq {
+			return resp, ErrCSeqMismatch
+		}
+	}
+	return resp, nil
+}
+
+// readResponse reads and parses a single RTSP response.
+func (c *Connection) readResponse() (*Response, error) {
+	tp := textproto.NewReader(c.reader)
+	line, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := parseStatusLine(line)
+	if err != nil {
+		return nil, err
+	}
+	headers, err := tp.ReadMIMEHeader()
+	if len(headers) > 0 {
+		resp.Headers = headers
+	}
+	if err != nil {
+		return resp, err
+	}
+	if lengths, ok := headers["Content-Length"]; ok && len(lengths) > 0 {
+		length, err := strconv.Atoi(strings.TrimSpace(lengths[0]))
+		if err != nil || length < 0 {
+			return resp, fmt.Errorf("invalid Content-Length %q", lengths[0])
+		}
+		if length > maxBodySize {
+			length = maxBodySize
+		}
+		body := make([]byte, length)
+		n, err := io.ReadFull(c.reader, body)
+		resp.Body = string(body[:n])
+		if err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+// parseStatusLine parses an RTSP status line, e.g. "RTSP/1.0 200 OK".
+func parseStatusLine(line string) (*Response, error) {
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) < 2 || !strings.HasPrefix(parts[0], "RTSP/") {
+		return nil, ErrInvalidStatusLine
+	}
+	code, err := strconv.Atoi(parts[1])
+	if err != nil || len(parts[1]) != 3 {
+		return nil, ErrInvalidStatusLine
+	}
+	resp := &Response{
+		StatusLine: line,
+		Version:    parts[0],
+		StatusCode: code,
+	}
+	if len(parts) == 3 {
+		resp.StatusText = parts[2]
+	}
+	return resp, nil
+}
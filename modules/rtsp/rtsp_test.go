@@ -0,0 +1,79 @@
+package rtsp
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/textproto"
+	"testing"
+)
+
+// serveRTSP reads requests from conn and answers each with the response
+// produced by respond, echoing the request's CSeq unless cseqOffset is set.
+func serveRTSP(conn net.Conn, cseqOffset int, respond func(method string) string) {
+	tp := textproto.NewReader(bufio.NewReader(conn))
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		headers, err := tp.ReadMIMEHeader()
+		if err != nil {
+			return
+		}
+		var method string
+		fmt.Sscanf(line, "%s", &method)
+		var cseq int
+		fmt.Sscanf(headers.Get("CSeq"), "%d", &cseq)
+		fmt.Fprintf(conn, respond(method), cseq+cseqOffset)
+	}
+}
+
+func TestSendRequest(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go serveRTSP(server, 0, func(method string) string {
+		if method == "OPTIONS" {
+			return "RTSP/1.0 200 OK\r\nCSeq: %d\r\nPublic: OPTIONS, DESCRIBE, SETUP, PLAY\r\nServer: TestCam/1.0\r\n\r\n"
+		}
+		return "RTSP/1.0 401 Unauthorized\r\nCSeq: %d\r\nWWW-Authenticate: Basic realm=\"cam\"\r\nContent-Length: 5\r\n\r\nhello"
+	})
+	conn := NewConnection(client)
+	options, err := conn.SendRequest("OPTIONS", "rtsp://127.0.0.1:554/", nil)
+	if err != nil {
+		t.Fatalf("OPTIONS failed: %v", err)
+	}
+	if options.StatusCode != 200 || options.Version != "RTSP/1.0" || options.Headers["Server"][0] != "TestCam/1.0" {
+		t.Errorf("unexpected OPTIONS response: %+v", options)
+	}
+	describe, err := conn.SendRequest("DESCRIBE", "rtsp://127.0.0.1:554/stream", nil)
+	if err != nil {
+		t.Fatalf("DESCRIBE failed: %v", err)
+	}
+	if describe.StatusCode != 401 || describe.Body != "hello" || getDescribeStatus(describe.StatusCode) != "auth_required" {
+		t.Errorf("unexpected DESCRIBE response: %+v", describe)
+	}
+	if conn.cseq != 2 {
+		t.Errorf("expected CSeq 2, got %d", conn.cseq)
+	}
+}
+
+func TestSendRequestCSeqMismatch(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go serveRTSP(server, 1, func(method string) string {
+		return "RTSP/1.0 200 OK\r\nCSeq: %d\r\n\r\n"
+	})
+	conn := NewConnection(client)
+	if _, err := conn.SendRequest("OPTIONS", "rtsp://127.0.0.1:554/", nil); err != ErrCSeqMismatch {
+		t.Errorf("expected ErrCSeqMismatch, got %v", err)
+	}
+}
+
+func TestParseStatusLine(t *testing.T) {
+	for _, line := range []string{"HTTP/1.1 200 OK", "RTSP/1.0", "RTSP/1.0 2000 OK", "SSH-2.0-OpenSSH"} {
+		if _, err := parseStatusLine(line); err != ErrInvalidStatusLine {
+			t.Errorf("expected ErrInvalidStatusLine for %q, got %v", line, err)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Package rtsp provides a zgrab2 module that scans for RTSP servers, such as
+// IP cameras.
+// Default Port: 554 (TCP)
+//
+// The scanner sends an OPTIONS request and records the methods listed in the
+// Public header along with the Server header.
+//
+// If --describe is set, it then sends a DESCRIBE request for --path and
+// records whether the stream is open (200), requires authentication (401) or
+// does not exist (404).
+package rtsp
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmap/zgrab2"
+)
+
+// ScanResults instances are returned by the module's Scan function.
+type ScanResults struct {
+	// Options is the response to the OPTIONS request.
+	Options *Response `json:"options,omitempty"`
+
+	// PublicMethods lists the methods in the OPTIONS response's Public
+	// header.
+	PublicMethods []string `json:"public_methods,omitempty"`
+
+	// Server is the OPTIONS response's Server header.
+	Server string `json:"server,omitempty"`
+
+	// Describe is the response to the DESCRIBE request, if one was sent.
+	Describe *Response `json:"describe,omitempty"`
+
+	// DescribeStatus summarizes the DESCRIBE response: "open" (200),
+	// "auth_required" (401), "not_found" (404) or "other".
+	DescribeStatus string `json:"describe_status,omitempty"`
+}
+
+// Flags holds the command-line configuration for the RTSP scan module.
+// Populated by the framework.
+type Flags struct {
+	zgrab2.BaseFlags
+
+	// Describe indicates that a DESCRIBE request should be sent after the
+	// OPTIONS request.
+	Describe bool `long:"describe" description:"Send a DESCRIBE request for --path after the OPTIONS request"`
+
+	// Path is the stream path used in the DESCRIBE request.
+	Path string `long:"path" default:"/" description:"Stream path to DESCRIBE"`
+
+	// UserAgent is sent in the User-Agent header of each request.
+	UserAgent string `long:"user-agent" default:"zgrab2" description:"User-Agent to send with each request"`
+
+	// Verbose indicates that there should be more verbose logging.
+	Verbose bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
+}
+
+// Module implements the zgrab2.Module interface.
+type Module struct {
+}
+
+// Scanner implements the zgrab2.Scanner interface.
+type Scanner struct {
+	config *Flags
+}
+
+// RegisterModule registers the zgrab2 module.
+func RegisterModule() {
+	var module Module
+	_, err := zgrab2.AddCommand("rtsp", "rtsp", module.Description(), 554, &module)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewFlags returns a default Flags object.
+func (module *Module) NewFlags() interface{} {
+	return new(Flags)
+}
+
+// NewScanner returns a new Scanner instance.
+func (module *Module) NewScanner() zgrab2.Scanner {
+	return new(Scanner)
+}
+
+// Description returns an overview of this module.
+func (module *Module) Description() string {
+	return "Probe for RTSP servers (e.g. IP cameras) with OPTIONS and DESCRIBE"
+}
+
+// Validate checks that the flags are valid.
+// On success, returns nil.
+// On failure, returns an error instance describing the error.
+func (flags *Flags) Validate(args []string) error {
+	if !strings.HasPrefix(flags.Path, "/") {
+		log.Error("--path must begin with /")
+		return zgrab2.ErrInvalidArguments
+	}
+	return nil
+}
+
+// Help returns the module's help string.
+func (flags *Flags) Help() string {
+	return ""
+}
+
+// Init initializes the Scanner.
+func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
+	f, _ := flags.(*Flags)
+	scanner.config = f
+	return nil
+}
+
+// InitPerSender initializes the scanner for a given sender.
+func (scanner *Scanner) InitPerSender(senderID int) error {
+	return nil
+}
+
+// GetName returns the Scanner name defined in the Flags.
+func (scanner *Scanner) GetName() string {
+	return scanner.config.Name
+}
+
+// GetTrigger returns the Trigger defined in the Flags.
+func (scanner *Scanner) GetTrigger() string {
+	return scanner.config.Trigger
+}
+
+// Protocol returns the protocol identifier of the scan.
+func (scanner *Scanner) Protocol() string {
+	return "rtsp"
+}
+
+// getURL returns the rtsp:// URL for the target and path.
+func (scanner *Scanner) getURL(target *zgrab2.ScanTarget, path string) string {
+	host := target.Domain
+	if host == "" {
+		host = target.IP.String()
+	}
+	port := scanner.config.Port
+	if target.Port != nil {
+		port = *target.Port
+	}
+	return "rtsp://" + net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10)) + path
+}
+
+// getDescribeStatus summarizes a DESCRIBE response's status code.
+func getDescribeStatus(code int) string {
+	switch code {
+	case 200:
+		return "open"
+	case 401:
+		return "auth_required"
+	case 404:
+		return "not_found"
+	default:
+		return "other"
+	}
+}
+
+// Scan performs the RTSP scan.
+//  1. Open a TCP connection to the target port (default 554).
+//  2. Send OPTIONS (CSeq 1). If the response is not an RTSP response, fail
+//     with a protocol error.
+//  3. Record the Public methods and Server header.
+//  4. If --describe is set, send DESCRIBE for --path (CSeq 2) and record the
+//     response and its summarized status.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	c, err := target.Open(&scanner.config.BaseFlags)
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	zgrab2.EnableTLSDetection(c)
+	defer c.Close()
+	conn := NewConnection(c)
+	headers := map[string]string{"User-Agent": scanner.config.UserAgent}
+
+	options, err := conn.SendRequest("OPTIONS", scanner.getURL(&target, "/"), headers)
+	if options == nil {
+		if err == ErrInvalidStatusLine {
+			return zgrab2.SCAN_PROTOCOL_ERROR, nil, err
+		}
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	result := &ScanResults{Options: options}
+	for _, public := range options.Headers["Public"] {
+		for _, method := range strings.Split(public, ",") {
+			if method = strings.TrimSpace(method); method != "" {
+				result.PublicMethods = append(result.PublicMethods, method)
+			}
+		}
+	}
+	if server, ok := options.Headers["Server"]; ok && len(server) > 0 {
+		result.Server = server[0]
+	}
+	if err != nil {
+		if err == ErrCSeqMismatch {
+			return zgrab2.SCAN_PROTOCOL_ERROR, result, err
+		}
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+
+	if scanner.config.Describe {
+		headers["Accept"] = "application/sdp"
+		describe, err := conn.SendRequest("DESCRIBE", scanner.getURL(&target, scanner.config.Path), headers)
+		if describe != nil {
+			result.Describe = describe
+			result.DescribeStatus = getDescribeStatus(describe.StatusCode)
+		}
+		if err != nil {
+			if err == ErrInvalidStatusLine || err == ErrCSeqMismatch {
+				return zgrab2.SCAN_PROTOCOL_ERROR, result, err
+			}
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+	}
+	return zgrab2.SCAN_SUCCESS, result, nil
+}
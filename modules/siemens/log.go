@@ -46,4 +46,10 @@ type S7Log struct {
 
 	// Fiirmware is the third field returned in the module identification response.
 	Firmware string `json:"firmware,omitempty"`
+
+	// FirmwareVersion is the version number from the firmware record of the module identification response.
+	FirmwareVersion string `json:"firmware_version,omitempty"`
+
+	// ModuleIdentificationRecords are the raw SZL records from the module identification response.
+	ModuleIdentificationRecords []SZLRecord `json:"module_identification_records,omitempty"`
 }
@@ -3,9 +3,9 @@ package siemens
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
+	"io"
 	"net"
-
-	"github.com/zmap/zgrab2"
 )
 
 // ReconnectFunction is used to re-connect to the target to re-try the scan with a different TSAP destination.
@@ -60,6 +60,10 @@ func GetS7Banner(logStruct *S7Log, connection net.Conn, reconnect ReconnectFunct
 		return err
 	}
 	parseModuleIdentificatioNRequest(logStruct, &moduleIdentificationResponse)
+	if szl, err := parseSZLResponse(&moduleIdentificationResponse); err == nil {
+		logStruct.ModuleIdentificationRecords = szl.Records
+		logStruct.FirmwareVersion = szl.getFirmwareVersion()
+	}
 
 	// Make Component Identification request
 	componentIdentificationResponse, err := readRequest(connection, S7_SZL_COMPONENT_IDENTIFICATION)
@@ -123,13 +127,32 @@ func makeRequestPacketBytes(pduType byte, parameters []byte, data []byte) ([]byt
 
 // Send a generic packet request and return the response
 func sendRequestReadResponse(connection net.Conn, requestBytes []byte) ([]byte, error) {
-	connection.Write(requestBytes)
-	responseBytes, err := zgrab2.ReadAvailable(connection)
-	if err != nil {
+	if _, err := connection.Write(requestBytes); err != nil {
 		return nil, err
 	}
+	return readTPKTPacket(connection)
+}
 
-	return responseBytes, nil
+// readTPKTPacket reads a single TPKT packet (including the 4-byte header) from
+// the connection, using the length in the header to find the end of the packet.
+func readTPKTPacket(connection net.Conn) ([]byte, error) {
+	header := make([]byte, tpktLength)
+	if _, err := io.ReadFull(connection, header); err != nil {
+		return nil, err
+	}
+	if header[0] != 3 {
+		return nil, errNotS7
+	}
+	length := int(binary.BigEndian.Uint16(header[2:4]))
+	if length < tpktLength {
+		return nil, errInvalidPacket
+	}
+	ret := make([]byte, length)
+	copy(ret, header)
+	if _, err := io.ReadFull(connection, ret[tpktLength:]); err != nil {
+		return nil, err
+	}
+	return ret, nil
 }
 
 func unmarshalCOTPConnectionResponse(responseBytes []byte) (cotpConnPacket COTPConnectionPacket, err error) {
@@ -296,3 +319,77 @@ func readRequest(connection net.Conn, slzId uint16) (packet S7Packet, err error)
 
 	return packet, nil
 }
+
+// SZLRecord is a single data record from a System Status List (SZL) read
+// response.
+type SZLRecord struct {
+	// Index identifies the record within the list (e.g. for module
+	// identification, 0x0001 is the module, 0x0006 the hardware and 0x0007 the
+	// firmware).
+	Index uint16 `json:"index"`
+
+	// Raw is the full raw record, including the index.
+	Raw []byte `json:"raw"`
+}
+
+// szlResponse is the parsed data of a SZL read response.
+type szlResponse struct {
+	// ID is the SZL-ID of the list that was read.
+	ID uint16
+
+	// Index is the SZL index that was read.
+	Index uint16
+
+	// Records are the individual data records.
+	Records []SZLRecord
+}
+
+// szlModuleIdentificationFirmware is the index of the module identification
+// record describing the firmware.
+const szlModuleIdentificationFirmware = uint16(0x0007)
+
+// parseSZLResponse decodes the data of a SZL read response into its header and
+// data records. The data starts with a 4-byte data header (return code,
+// transport size, length), followed by the SZL header (SZL-ID, index, record
+// length, record count) and the records themselves.
+func parseSZLResponse(s7Packet *S7Packet) (*szlResponse, error) {
+	data := s7Packet.Data
+	if len(data) < 12 {
+		return nil, errS7PacketTooShort
+	}
+	if data[0] != 0xff {
+		// Return code is not "success"
+		return nil, errInvalidPacket
+	}
+	ret := szlResponse{
+		ID:    binary.BigEndian.Uint16(data[4:6]),
+		Index: binary.BigEndian.Uint16(data[6:8]),
+	}
+	recordLength := int(binary.BigEndian.Uint16(data[8:10]))
+	recordCount := int(binary.BigEndian.Uint16(data[10:12]))
+	if recordLength < 2 {
+		return nil, errInvalidPacket
+	}
+	rest := data[12:]
+	for i := 0; i < recordCount && len(rest) >= recordLength; i++ {
+		ret.Records = append(ret.Records, SZLRecord{
+			Index: binary.BigEndian.Uint16(rest[0:2]),
+			Raw:   rest[0:recordLength],
+		})
+		rest = rest[recordLength:]
+	}
+	return &ret, nil
+}
+
+// getFirmwareVersion returns the firmware version (e.g. "V2.6.9") from the
+// module identification firmware record, whose last three bytes give the
+// version number. Returns the empty string if there is no such record.
+func (szl *szlResponse) getFirmwareVersion() string {
+	for _, record := range szl.Records {
+		if record.Index == szlModuleIdentificationFirmware && len(record.Raw) >= 5 {
+			v := record.Raw[len(record.Raw)-3:]
+			return fmt.Sprintf("V%d.%d.%d", v[0], v[1], v[2])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package siemens
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestReadTPKTPacket(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []byte
+		expected []byte
+		err      error
+	}{
+		{"packet", []byte{3, 0, 0, 6, 0xaa, 0xbb}, []byte{3, 0, 0, 6, 0xaa, 0xbb}, nil},
+		{"trailing data", []byte{3, 0, 0, 5, 0xaa, 0xbb}, []byte{3, 0, 0, 5, 0xaa}, nil},
+		{"header only", []byte{3, 0, 0, 4}, []byte{3, 0, 0, 4}, nil},
+		{"empty", nil, nil, io.EOF},
+		{"truncated header", []byte{3, 0}, nil, io.ErrUnexpectedEOF},
+		{"truncated data", []byte{3, 0, 0, 8, 0xaa}, nil, io.ErrUnexpectedEOF},
+		{"bad version", []byte{4, 0, 0, 6, 0xaa, 0xbb}, nil, errNotS7},
+		{"bad length", []byte{3, 0, 0, 3, 0xaa}, nil, errInvalidPacket},
+	}
+	for _, test := range tests {
+		client, server := net.Pipe()
+		go func(input []byte) {
+			server.Write(input)
+			server.Close()
+		}(test.input)
+		packet, err := readTPKTPacket(client)
+		client.Close()
+		if err != test.err || !bytes.Equal(packet, test.expected) {
+			t.Errorf("%s: expected %x (%v), got %x (%v)", test.name, test.expected, test.err, packet, err)
+		}
+	}
+}
+
+func TestParseSZLResponse(t *testing.T) {
+	header := []byte{0xff, 0x09, 0x00, 0x20, 0x00, 0x11, 0x00, 0x00}
+	firmware := []byte{0x00, 0x07, 'a', 'b', 0x00, 0x00, 0x02, 0x06, 0x09}
+	module := []byte{0x00, 0x01, 'c', 'd', 0x00, 0x00, 0x00, 0x00, 0x00}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	tests := []struct {
+		name     string
+		data     []byte
+		records  int
+		firmware string
+		err      error
+	}{
+		{"records", join(header, []byte{0x00, 0x09, 0x00, 0x02}, module, firmware), 2, "V2.6.9", nil},
+		{"no firmware", join(header, []byte{0x00, 0x09, 0x00, 0x01}, module), 1, "", nil},
+		{"truncated record", join(header, []byte{0x00, 0x09, 0x00, 0x02}, module, firmware[:5]), 1, "", nil},
+		{"short record", join(header, []byte{0x00, 0x04, 0x00, 0x01}, firmware[:4]), 1, "", nil},
+		{"extra records", join(header, []byte{0x00, 0x09, 0x00, 0x01}, module, firmware), 1, "", nil},
+		{"no records", join(header, []byte{0x00, 0x09, 0x00, 0x00}), 0, "", nil},
+		{"bad record length", join(header, []byte{0x00, 0x01, 0x00, 0x01}, firmware), 0, "", errInvalidPacket},
+		{"error return code", join([]byte{0x0a}, header[1:], []byte{0x00, 0x09, 0x00, 0x01}, firmware), 0, "", errInvalidPacket},
+		{"truncated header", header, 0, "", errS7PacketTooShort},
+		{"empty", nil, 0, "", errS7PacketTooShort},
+	}
+	for _, test := range tests {
+		szl, err := parseSZLResponse(&S7Packet{Data: test.data})
+		if err != test.err {
+			t.Errorf("%s: expected error %v, got %v", test.name, test.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if szl.ID != 0x0011 || len(szl.Records) != test.records {
+			t.Errorf("%s: expected %d records of SZL 0x0011, got %+v", test.name, test.records, szl)
+		}
+		if version := szl.getFirmwareVersion(); version != test.firmware {
+			t.Errorf("%s: expected firmware %q, got %q", test.name, test.firmware, version)
+		}
+	}
+}
@@ -1,9 +1,16 @@
 // Package siemens provides a zgrab2 module that scans for Siemens S7.
 // Default port: TCP 102
-// Ported from the original zgrab. Input and output are identical.
+// Ported from the original zgrab. Input and output are identical, except that
+// the output also includes the raw SZL records from the module identification
+// response (module_identification_records), and the firmware version parsed
+// from them (firmware_version).
+//
+// Responses are read using the length in the TPKT header, so each read
+// consumes exactly one TPKT / COTP packet.
 package siemens
 
 import (
+	"context"
 	"net"
 
 	log "github.com/sirupsen/logrus"
@@ -98,7 +105,7 @@ func (scanner *Scanner) Protocol() string {
 // 5. Request to read the module identification (and store it in the output)
 // 6. Request to read the component identification (and store it in the output)
 // 7. Return the output
-func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
 	conn, err := target.Open(&scanner.config.BaseFlags)
 	if err != nil {
 		return zgrab2.TryGetScanStatus(err), nil, err
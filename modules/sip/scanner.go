@@ -0,0 +1,208 @@
+// Package sip provides a zgrab2 module that scans for SIP services.
+// Default Port: 5060 (UDP, or TCP with --tcp)
+//
+// The scanner sends a single OPTIONS request, with a random Via branch, From
+// tag and Call-ID, and records the final response's status code along with
+// the Server, User-Agent and Allow headers. The SIP stack (Asterisk,
+// FreeSWITCH, ...) is identified from the Server / User-Agent headers where
+// possible.
+//
+// A 200 response indicates an open SIP service; 401 / 403 / 407 indicate that
+// authentication is required, and 404 that the requested user does not exist.
+package sip
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmap/zgrab2"
+)
+
+// ScanResults instances are returned by the module's Scan function.
+type ScanResults struct {
+	// Transport is the transport the request was sent over, "udp" or "tcp".
+	Transport string `json:"transport"`
+
+	// Response is the final response to the OPTIONS request.
+	Response *Response `json:"response,omitempty"`
+
+	// StatusCode is the status code of the final response.
+	StatusCode int `json:"status_code"`
+
+	// Server is the value of the Server header.
+	Server string `json:"server,omitempty"`
+
+	// UserAgent is the value of the User-Agent header.
+	UserAgent string `json:"user_agent,omitempty"`
+
+	// Allow lists the methods in the Allow header.
+	Allow []string `json:"allow,omitempty"`
+
+	// Stack is the SIP stack identified from the Server / User-Agent
+	// headers, e.g. "asterisk", if it was recognized.
+	Stack string `json:"stack,omitempty"`
+}
+
+// Flags holds the command-line configuration for the SIP scan module.
+// Populated by the framework.
+type Flags struct {
+	zgrab2.BaseFlags
+	zgrab2.UDPFlags
+
+	// TCP causes the request to be sent over TCP instead of UDP.
+	TCP bool `long:"tcp" description:"Send the request over TCP instead of UDP"`
+
+	// User is the user part of the Request-URI, if any.
+	User string `long:"user" description:"User part of the OPTIONS Request-URI (e.g. 100 for sip:100@host)"`
+
+	// UserAgent is sent in the User-Agent header.
+	UserAgent string `long:"user-agent" default:"zgrab2" description:"User-Agent to send with the request"`
+
+	// Verbose indicates that there should be more verbose logging.
+	Verbose bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
+}
+
+// Module implements the zgrab2.Module interface.
+type Module struct {
+}
+
+// Scanner implements the zgrab2.Scanner interface.
+type Scanner struct {
+	config *Flags
+}
+
+// RegisterModule registers the zgrab2 module.
+func RegisterModule() {
+	var module Module
+	_, err := zgrab2.AddCommand("sip", "sip", module.Description(), 5060, &module)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewFlags returns a default Flags object.
+func (module *Module) NewFlags() interface{} {
+	return new(Flags)
+}
+
+// NewScanner returns a new Scanner instance.
+func (module *Module) NewScanner() zgrab2.Scanner {
+	return new(Scanner)
+}
+
+// Description returns an overview of this module.
+func (module *Module) Description() string {
+	return "Probe for SIP services with an OPTIONS request"
+}
+
+// Validate checks that the flags are valid.
+// On success, returns nil.
+// On failure, returns an error instance describing the error.
+func (flags *Flags) Validate(args []string) error {
+	return nil
+}
+
+// Help returns the module's help string.
+func (flags *Flags) Help() string {
+	return ""
+}
+
+// Init initializes the Scanner.
+func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
+	f, _ := flags.(*Flags)
+	scanner.config = f
+	return nil
+}
+
+// InitPerSender initializes the scanner for a given sender.
+func (scanner *Scanner) InitPerSender(senderID int) error {
+	return nil
+}
+
+// GetName returns the Scanner name defined in the Flags.
+func (scanner *Scanner) GetName() string {
+	return scanner.config.Name
+}
+
+// GetTrigger returns the Trigger defined in the Flags.
+func (scanner *Scanner) GetTrigger() string {
+	return scanner.config.Trigger
+}
+
+// Protocol returns the protocol identifier of the scan.
+func (scanner *Scanner) Protocol() string {
+	return "sip"
+}
+
+// getRequestURI returns the sip: Request-URI for the target.
+func (scanner *Scanner) getRequestURI(target *zgrab2.ScanTarget) string {
+	host := target.Domain
+	if host == "" {
+		host = target.IP.String()
+	}
+	port := scanner.config.Port
+	if target.Port != nil {
+		port = *target.Port
+	}
+	uri := "sip:"
+	if scanner.config.User != "" {
+		uri += scanner.config.User + "@"
+	}
+	return uri + net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10))
+}
+
+// Scan performs the SIP scan.
+//  1. Open a UDP (or, with --tcp, TCP) connection to the target port (default
+//     5060).
+//  2. Send an OPTIONS request, and read responses until a final (non-1xx)
+//     response arrives.
+//  3. If the response is not a SIP response, fail with a protocol error.
+//  4. Record the status code, Server / User-Agent / Allow headers and the
+//     identified SIP stack.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	var conn net.Conn
+	var err error
+	transport := "udp"
+	if scanner.config.TCP {
+		transport = "tcp"
+		if conn, err = target.Open(&scanner.config.BaseFlags); err == nil {
+			zgrab2.EnableTLSDetection(conn)
+		}
+	} else {
+		conn, err = target.OpenUDP(&scanner.config.BaseFlags, &scanner.config.UDPFlags)
+	}
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	defer conn.Close()
+
+	resp, err := sendOptions(conn, transport, scanner.getRequestURI(&target), scanner.config.UserAgent)
+	if resp == nil {
+		if err == ErrInvalidStatusLine {
+			return zgrab2.SCAN_PROTOCOL_ERROR, nil, err
+		}
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	result := &ScanResults{
+		Transport:  transport,
+		Response:   resp,
+		StatusCode: resp.StatusCode,
+		Server:     resp.getHeader("Server"),
+		UserAgent:  resp.getHeader("User-Agent"),
+	}
+	for _, allow := range resp.Headers["Allow"] {
+		for _, method := range strings.Split(allow, ",") {
+			if method = strings.TrimSpace(method); method != "" {
+				result.Allow = append(result.Allow, method)
+			}
+		}
+	}
+	result.Stack = identifyStack(result.Server, result.UserAgent)
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+	return zgrab2.SCAN_SUCCESS, result, nil
+}
@@ -0,0 +1,240 @@
+// SIP client for the sip module.
+// https://tools.ietf.org/html/rfc3261
+
+package sip
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// maxDatagramSize is the largest UDP response that is read.
+const maxDatagramSize = 0x10000
+
+// maxProvisionalResponses bounds the number of 1xx responses that are skipped
+// while waiting for a final response.
+const maxProvisionalResponses = 4
+
+// ErrInvalidStatusLine is returned when the server's response does not begin
+// with a SIP status line.
+var ErrInvalidStatusLine = errors.New("invalid SIP status line")
+
+// compactHeaders maps the RFC 3261 compact header names to their canonical
+// long forms.
+var compactHeaders = map[string]string{
+	"I": "Call-Id",
+	"M": "Contact",
+	"E": "Content-Encoding",
+	"L": "Content-Length",
+	"C": "Content-Type",
+	"F": "From",
+	"S": "Subject",
+	"K": "Supported",
+	"T": "To",
+	"V": "Via",
+}
+
+// knownStacks maps substrings of the Server / User-Agent headers (lower case)
+// to the SIP stack they identify.
+var knownStacks = []struct {
+	substring string
+	stack     string
+}{
+	{"asterisk", "asterisk"},
+	{"freeswitch", "freeswitch"},
+	{"kamailio", "kamailio"},
+	{"opensips", "opensips"},
+	{"sip express router", "ser"},
+	{"yate", "yate"},
+	{"3cx", "3cx"},
+	{"fritz!os", "fritzos"},
+	{"avm", "fritzos"},
+	{"cisco", "cisco"},
+	{"avaya", "avaya"},
+	{"mitel", "mitel"},
+	{"polycom", "polycom"},
+	{"yealink", "yealink"},
+	{"grandstream", "grandstream"},
+	{"microsoft", "microsoft"},
+	{"sipxecs", "sipxecs"},
+}
+
+// Response is a parsed SIP response.
+type Response struct {
+	// StatusLine is the full first line of the response.
+	StatusLine string `json:"status_line"`
+
+	// Version is the protocol version from the status line, e.g. "SIP/2.0".
+	Version string `json:"version"`
+
+	// StatusCode is the numeric status code, e.g. 200.
+	StatusCode int `json:"status_code"`
+
+	// StatusText is the reason phrase, e.g. "OK".
+	StatusText string `json:"status_text,omitempty"`
+
+	// Headers holds the response headers, keyed by their canonical long
+	// names.
+	Headers map[string][]string `json:"headers,omitempty"`
+}
+
+// randomToken returns a random hex string for use in branch parameters, tags
+// and Call-IDs.
+func randomToken() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// buildOptionsRequest returns an OPTIONS request for requestURI from the
+// given local address, with a fresh branch, tag and Call-ID.
+func buildOptionsRequest(transport string, requestURI string, local net.Addr, userAgent string) string {
+	localHost, localPort, err := net.SplitHostPort(local.String())
+	if err != nil {
+		localHost, localPort = local.String(), "5060"
+	}
+	hostport := net.JoinHostPort(localHost, localPort)
+	if strings.Contains(localHost, ":") {
+		localHost = "[" + localHost + "]"
+	}
+	lines := []string{
+		fmt.Sprintf("OPTIONS %s SIP/2.0", requestURI),
+		fmt.Sprintf("Via: SIP/2.0/%s %s;branch=z9hG4bK%s;rport", strings.ToUpper(transport), hostport, randomToken()),
+		"Max-Forwards: 70",
+		fmt.Sprintf("From: <sip:zgrab2@%s>;tag=%s", localHost, randomToken()),
+		fmt.Sprintf("To: <%s>", requestURI),
+		fmt.Sprintf("Call-ID: %s@%s", randomToken(), localHost),
+		"CSeq: 1 OPTIONS",
+		fmt.Sprintf("Contact: <sip:zgrab2@%s>", hostport),
+		"Accept: application/sdp",
+		fmt.Sprintf("User-Agent: %s", userAgent),
+		"Content-Length: 0",
+	}
+	return strings.Join(lines, "\r\n") + "\r\n\r\n"
+}
+
+// readResponse reads and parses a single SIP response (status line and
+// headers). Any body is left unread.
+func readResponse(reader *bufio.Reader) (*Response, error) {
+	tp := textproto.NewReader(reader)
+	line, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := parseStatusLine(line)
+	if err != nil {
+		return nil, err
+	}
+	headers, err := tp.ReadMIMEHeader()
+	if len(headers) > 0 {
+		resp.Headers = make(map[string][]string, len(headers))
+		for k, v := range headers {
+			if long, ok := compactHeaders[k]; ok {
+				k = long
+			}
+			resp.Headers[k] = append(resp.Headers[k], v...)
+		}
+	}
+	if err != nil && err != io.EOF {
+		return resp, err
+	}
+	return resp, nil
+}
+
+// parseStatusLine parses a SIP status line, e.g. "SIP/2.0 200 OK".
+func parseStatusLine(line string) (*Response, error) {
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) < 2 || !strings.HasPrefix(parts[0], "SIP/") {
+		return nil, ErrInvalidStatusLine
+	}
+	code, err := strconv.Atoi(parts[1])
+	if err != nil || len(parts[1]) != 3 {
+		return nil, ErrInvalidStatusLine
+	}
+	resp := &Response{
+		StatusLine: line,
+		Version:    parts[0],
+		StatusCode: code,
+	}
+	if len(parts) == 3 {
+		resp.StatusText = parts[2]
+	}
+	return resp, nil
+}
+
+// getHeader returns the first value of the named header, if present.
+func (resp *Response) getHeader(name string) string {
+	if values := resp.Headers[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// identifyStack returns the name of the SIP stack identified by the given
+// Server / User-Agent values, or "" if it is not recognized.
+func identifyStack(banners ...string) string {
+	for _, banner := range banners {
+		lower := strings.ToLower(banner)
+		for _, known := range knownStacks {
+			if strings.Contains(lower, known.substring) {
+				return known.stack
+			}
+		}
+	}
+	return ""
+}
+
+// sendOptions sends an OPTIONS request over conn and returns the first final
+// (non-1xx) response. For UDP, each response is read from a single datagram.
+func sendOptions(conn net.Conn, transport string, requestURI string, userAgent string) (*Response, error) {
+	request := buildOptionsRequest(transport, requestURI, conn.LocalAddr(), userAgent)
+	if _, err := conn.Write([]byte(request)); err != nil {
+		return nil, err
+	}
+	var tcpReader *bufio.Reader
+	if transport == "tcp" {
+		tcpReader = bufio.NewReader(conn)
+	}
+	var resp *Response
+	var err error
+	for i := 0; i < maxProvisionalResponses; i++ {
+		if tcpReader != nil {
+			resp, err = readResponse(tcpReader)
+			if err == nil {
+				err = skipBody(tcpReader, resp)
+			}
+		} else {
+			buf := make([]byte, maxDatagramSize)
+			var n int
+			if n, err = conn.Read(buf); err != nil {
+				return resp, err
+			}
+			resp, err = readResponse(bufio.NewReader(strings.NewReader(string(buf[:n]))))
+		}
+		if err != nil || resp.StatusCode >= 200 {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+// skipBody discards the body of a response read from a stream transport.
+func skipBody(reader *bufio.Reader, resp *Response) error {
+	length, err := strconv.Atoi(strings.TrimSpace(resp.getHeader("Content-Length")))
+	if err != nil || length <= 0 {
+		return nil
+	}
+	_, err = io.CopyN(ioutil.Discard, reader, int64(length))
+	return err
+}
@@ -0,0 +1,95 @@
+package sip
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestParseStatusLine(t *testing.T) {
+	resp, err := parseStatusLine("SIP/2.0 401 Unauthorized")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Version != "SIP/2.0" || resp.StatusCode != 401 || resp.StatusText != "Unauthorized" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	for _, line := range []string{"HTTP/1.1 200 OK", "SIP/2.0", "SIP/2.0 2000 OK", "SIP/2.0 abc OK"} {
+		if _, err := parseStatusLine(line); err != ErrInvalidStatusLine {
+			t.Errorf("%q: expected ErrInvalidStatusLine, got %v", line, err)
+		}
+	}
+}
+
+func TestReadResponseCompactHeaders(t *testing.T) {
+	raw := "SIP/2.0 200 OK\r\nv: SIP/2.0/UDP 10.0.0.1:5060;branch=z9hG4bKabc\r\nf: <sip:zgrab2@10.0.0.1>;tag=1\r\nServer: Asterisk PBX 18.1.0\r\nAllow: INVITE, ACK, OPTIONS\r\nl: 0\r\n\r\n"
+	resp, err := readResponse(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.getHeader("Via") == "" || resp.getHeader("From") == "" || resp.getHeader("Content-Length") != "0" {
+		t.Errorf("compact headers not expanded: %v", resp.Headers)
+	}
+	if stack := identifyStack(resp.getHeader("Server")); stack != "asterisk" {
+		t.Errorf("expected asterisk, got %q", stack)
+	}
+}
+
+func TestIdentifyStack(t *testing.T) {
+	tests := map[string][]string{
+		"freeswitch": {"", "FreeSWITCH-mod_sofia/1.10.7"},
+		"kamailio":   {"kamailio (5.5.2 (x86_64/linux))"},
+		"fritzos":    {"", "AVM FRITZ!Box 7590 154.07.29"},
+		"":           {"", "SomethingElse/1.0"},
+	}
+	for expected, banners := range tests {
+		if stack := identifyStack(banners...); stack != expected {
+			t.Errorf("%v: expected %q, got %q", banners, expected, stack)
+		}
+	}
+}
+
+func TestSendOptionsTCP(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	requests := make(chan textproto.MIMEHeader, 1)
+	go func() {
+		defer server.Close()
+		tp := textproto.NewReader(bufio.NewReader(server))
+		if _, err := tp.ReadLine(); err != nil {
+			return
+		}
+		headers, err := tp.ReadMIMEHeader()
+		if err != nil {
+			return
+		}
+		requests <- headers
+		server.Write([]byte("SIP/2.0 100 Trying\r\nContent-Length: 0\r\n\r\n" +
+			"SIP/2.0 404 Not Found\r\nUser-Agent: FPBX-15.0(16.6.0)\r\nContent-Length: 4\r\n\r\nbody"))
+	}()
+	resp, err := sendOptions(client, "tcp", "sip:100@192.0.2.1:5060", "zgrab2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != 404 {
+		t.Errorf("expected final 404 response, got %d", resp.StatusCode)
+	}
+	headers := <-requests
+	if via := headers.Get("Via"); !strings.HasPrefix(via, "SIP/2.0/TCP ") || !strings.Contains(via, ";branch=z9hG4bK") {
+		t.Errorf("unexpected Via: %q", via)
+	}
+	if !strings.Contains(headers.Get("From"), ";tag=") {
+		t.Errorf("From has no tag: %q", headers.Get("From"))
+	}
+	if headers.Get("Call-Id") == "" || headers.Get("Cseq") != "1 OPTIONS" {
+		t.Errorf("missing Call-ID / CSeq: %v", headers)
+	}
+}
+
+func TestRandomTokenUnique(t *testing.T) {
+	if randomToken() == randomToken() {
+		t.Error("randomToken returned the same value twice")
+	}
+}
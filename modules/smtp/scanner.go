@@ -16,6 +16,12 @@
 // and then negotiate a TLS connection.
 // The scanner uses the standard TLS flags for the handshake.
 //
+// The --open-relay-test flag tells the scanner to test whether the server
+// relays mail: after EHLO (or HELO), it sends MAIL FROM --relay-from and
+// RCPT TO --relay-to, an external recipient, then RSET and QUIT, without
+// ever sending DATA. This is intrusive, and should only be used against
+// servers one is authorized to test.
+//
 // The --send-quit flag tells the scanner to send a QUIT command.
 //
 // So, if no flags are specified, the scanner simply reads the banner
@@ -26,6 +32,7 @@
 package smtp
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
@@ -49,12 +56,19 @@ type ScanResults struct {
 	// EHLO is the server's response to the EHLO command, if one is sent.
 	EHLO string `json:"ehlo,omitempty"`
 
+	// AuthMechanisms are the SASL mechanisms advertised in the server's
+	// response to the EHLO command.
+	AuthMechanisms []string `json:"auth_mechanisms,omitempty"`
+
 	// HELP is the server's response to the HELP command, if it is sent.
 	HELP string `json:"help,omitempty"`
 
 	// StartTLS is the server's response to the STARTTLS command, if it is sent.
 	StartTLS string `json:"starttls,omitempty"`
 
+	// OpenRelay is the result of the open relay test, with --open-relay-test.
+	OpenRelay *OpenRelayResult `json:"open_relay,omitempty"`
+
 	// QUIT is the server's response to the QUIT command, if it is sent.
 	QUIT string `json:"quit,omitempty"`
 
@@ -66,6 +80,26 @@ type ScanResults struct {
 	TLSLog *zgrab2.TLSLog `json:"tls,omitempty"`
 }
 
+// OpenRelayResult is the result of the open relay test.
+type OpenRelayResult struct {
+	// MailFrom is the server's response to the MAIL FROM command.
+	MailFrom string `json:"mail_from,omitempty"`
+
+	// RcptTo is the server's response to the RCPT TO command, if it is sent
+	// (i.e. if the server accepted MAIL FROM).
+	RcptTo string `json:"rcpt_to,omitempty"`
+
+	// RSET is the server's response to the RSET command that ends the
+	// transaction.
+	RSET string `json:"rset,omitempty"`
+
+	// Relay is true if the server accepted (2xx) the external recipient,
+	// and false if it rejected it (5xx). It is absent if the test was not
+	// conclusive, e.g. if MAIL FROM was rejected or RCPT TO failed
+	// temporarily (4xx).
+	Relay *bool `json:"relay,omitempty"`
+}
+
 // Flags holds the command-line configuration for the HTTP scan module.
 // Populated by the framework.
 type Flags struct {
@@ -82,7 +116,7 @@ type Flags struct {
 	SendHELP bool `long:"send-help" description:"Send the HELP command"`
 
 	// SendQUIT indicates that the QUIT command should be set.
-	SendQUIT bool `long:"send-quit" description:"Send the QUIT command before closing."`
+	SendQUIT bool `long:"send-quit" description:"Send the QUIT command before closing (always done with --close-graceful)."`
 
 	// HELODomain is the domain the client should send in the HELO command.
 	HELODomain string `long:"helo-domain" description:"Set the domain to use with the HELO command. Implies --send-helo."`
@@ -96,6 +130,16 @@ type Flags struct {
 	// StartTLS indicates that the client should attempt to update the connection to TLS.
 	StartTLS bool `long:"starttls" description:"Send STARTTLS before negotiating"`
 
+	// OpenRelayTest indicates that the client should test whether the server
+	// relays mail to RelayTo.
+	OpenRelayTest bool `long:"open-relay-test" description:"Intrusive: send MAIL FROM and RCPT TO an external recipient (--relay-to) to test whether the server relays mail, then RSET and QUIT; no DATA is sent. Only use on servers you are authorized to test. Implies --send-ehlo unless --send-helo is set."`
+
+	// RelayFrom is the sender address used by the open relay test.
+	RelayFrom string `long:"relay-from" description:"Set the sender address for --open-relay-test (default: the null sender <>)."`
+
+	// RelayTo is the external recipient address used by the open relay test.
+	RelayTo string `long:"relay-to" description:"Set the external recipient address for --open-relay-test, in a domain the server is not responsible for."`
+
 	// Verbose indicates that there should be more verbose logging.
 	Verbose bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
 }
@@ -151,6 +195,18 @@ func (flags *Flags) Validate(args []string) error {
 		log.Errorln("Cannot provide both EHLO and HELO")
 		return zgrab2.ErrInvalidArguments
 	}
+	if flags.OpenRelayTest {
+		if flags.RelayTo == "" {
+			log.Errorln("--open-relay-test requires --relay-to")
+			return zgrab2.ErrInvalidArguments
+		}
+		if !flags.SendHELO {
+			flags.SendEHLO = true
+		}
+	} else if flags.RelayFrom != "" || flags.RelayTo != "" {
+		log.Errorln("--relay-from and --relay-to require --open-relay-test")
+		return zgrab2.ErrInvalidArguments
+	}
 	return nil
 }
 
@@ -197,6 +253,31 @@ func getSMTPCode(response string) (int, error) {
 	return ret, nil
 }
 
+// getAuthMechanisms returns the SASL mechanisms listed in the AUTH
+// extension of an EHLO response, in either the standard "250-AUTH PLAIN
+// LOGIN" form or the obsolete "250-AUTH=PLAIN LOGIN" form.
+func getAuthMechanisms(ehlo string) []string {
+	var names []string
+	for _, line := range strings.Split(ehlo, "\n") {
+		if len(line) < 4 {
+			continue
+		}
+		fields := strings.Fields(line[4:])
+		if len(fields) == 0 {
+			continue
+		}
+		keyword := strings.ToUpper(fields[0])
+		switch {
+		case keyword == "AUTH":
+			names = append(names, fields[1:]...)
+		case strings.HasPrefix(keyword, "AUTH="):
+			names = append(names, fields[0][len("AUTH="):])
+			names = append(names, fields[1:]...)
+		}
+	}
+	return zgrab2.SASLMechanisms(names)
+}
+
 // Get a command with an optional argument (so if the argument is absent, there is no trailing space)
 func getCommand(cmd string, arg string) string {
 	if arg == "" {
@@ -205,6 +286,44 @@ func getCommand(cmd string, arg string) string {
 	return cmd + " " + arg
 }
 
+// testOpenRelay starts a mail transaction from the sender to the external
+// recipient, without sending DATA, and then ends it with RSET. The returned
+// result is non-nil if MAIL FROM was sent, even on error.
+func (conn *Connection) testOpenRelay(from string, to string) (*OpenRelayResult, error) {
+	ret, err := conn.SendCommand("MAIL FROM:<" + from + ">")
+	if err != nil {
+		return nil, err
+	}
+	result := &OpenRelayResult{MailFrom: ret}
+	code, err := getSMTPCode(ret)
+	if err != nil {
+		return result, err
+	}
+	if code >= 200 && code < 300 {
+		if result.RcptTo, err = conn.SendCommand("RCPT TO:<" + to + ">"); err != nil {
+			return result, err
+		}
+		code, err := getSMTPCode(result.RcptTo)
+		if err != nil {
+			return result, err
+		}
+		switch {
+		case code >= 200 && code < 300:
+			relay := true
+			result.Relay = &relay
+		case code >= 500 && code < 600:
+			relay := false
+			result.Relay = &relay
+		}
+	}
+	// RSET even if MAIL FROM was rejected, so that no transaction is left
+	// open whatever the server made of it.
+	if result.RSET, err = conn.SendCommand("RSET"); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
 // Verify that an SMTP code was returned, and that it is a successful one!
 // Return code on SCAN_APPLICATION_ERROR for better info
 func VerifySMTPContents(banner string) (zgrab2.ScanStatus, int) {
@@ -231,17 +350,21 @@ func VerifySMTPContents(banner string) (zgrab2.ScanStatus, int) {
 // 2. If --smtps is set, perform a TLS handshake.
 // 3. Read the banner.
 // 4. If --send-ehlo or --send-helo is sent, send the corresponding EHLO
-//    or HELO command.
+//    or HELO command, and record the AUTH mechanisms the EHLO response lists.
 // 5. If --send-help is sent, send HELP, read the result.
-// 6. If --starttls is sent, send STARTTLS, read the result, negotiate a
+// 6. If --open-relay-test is sent, send MAIL FROM, RCPT TO and RSET, and
+//    record whether the external recipient was accepted.
+// 7. If --starttls is sent, send STARTTLS, read the result, negotiate a
 //    TLS connection.
-// 7. If --send-quit is sent, send QUIT and read the result.
-// 8. Close the connection.
-func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+// 8. If --send-quit or --open-relay-test is sent, send QUIT and read the
+//    result.
+// 9. Close the connection.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
 	c, err := target.Open(&scanner.config.BaseFlags)
 	if err != nil {
 		return zgrab2.TryGetScanStatus(err), nil, err
 	}
+	zgrab2.EnableTLSDetection(c)
 	defer c.Close()
 	result := &ScanResults{}
 	if scanner.config.SMTPSecure {
@@ -284,6 +407,7 @@ func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, inter
 			return zgrab2.TryGetScanStatus(err), result, err
 		}
 		result.EHLO = ret
+		result.AuthMechanisms = getAuthMechanisms(ret)
 	}
 	if scanner.config.SendHELP {
 		ret, err := conn.SendCommand("HELP")
@@ -292,6 +416,12 @@ func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, inter
 		}
 		result.HELP = ret
 	}
+	if scanner.config.OpenRelayTest {
+		result.OpenRelay, err = conn.testOpenRelay(scanner.config.RelayFrom, scanner.config.RelayTo)
+		if err != nil {
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+	}
 	if scanner.config.StartTLS {
 		ret, err := conn.SendCommand("STARTTLS")
 		if err != nil {
@@ -315,7 +445,7 @@ func (scanner *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, inter
 		}
 		conn.Conn = tlsConn
 	}
-	if scanner.config.SendQUIT {
+	if scanner.config.SendQUIT || scanner.config.OpenRelayTest || zgrab2.CloseGracefully() {
 		ret, err := conn.SendCommand("QUIT")
 		if err != nil {
 			if err != nil {
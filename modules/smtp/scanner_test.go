@@ -1,8 +1,13 @@
 package smtp
 
 import (
-	"github.com/zmap/zgrab2"
+	"bufio"
+	"net"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/zmap/zgrab2"
 )
 
 func TestVerifySMTPContents(t *testing.T) {
@@ -51,3 +56,145 @@ func TestVerifySMTPContents(t *testing.T) {
 	}
 
 }
+
+func TestGetAuthMechanisms(t *testing.T) {
+	testTable := map[string]struct {
+		EHLO     string
+		Expected []string
+	}{
+		"postfix": {
+			EHLO:     "250-mail.example.com\r\n250-PIPELINING\r\n250-SIZE 10240000\r\n250-ETRN\r\n250-AUTH PLAIN LOGIN\r\n250-ENHANCEDSTATUSCODES\r\n250-8BITMIME\r\n250 DSN\r\n",
+			Expected: []string{"PLAIN", "LOGIN"},
+		},
+		"exchange": {
+			EHLO:     "250-EX01.example.com Hello [192.0.2.1]\r\n250-SIZE 37748736\r\n250-PIPELINING\r\n250-DSN\r\n250-ENHANCEDSTATUSCODES\r\n250-STARTTLS\r\n250-AUTH GSSAPI NTLM LOGIN\r\n250-AUTH=LOGIN\r\n250-8BITMIME\r\n250-BINARYMIME\r\n250 CHUNKING\r\n",
+			Expected: []string{"GSSAPI", "NTLM", "LOGIN"},
+		},
+		"gmail": {
+			EHLO:     "250-smtp.gmail.com at your service, [192.0.2.1]\r\n250-SIZE 35882577\r\n250-8BITMIME\r\n250-AUTH LOGIN PLAIN XOAUTH2 PLAIN-CLIENTTOKEN OAUTHBEARER XOAUTH\r\n250-ENHANCEDSTATUSCODES\r\n250-PIPELINING\r\n250-CHUNKING\r\n250 SMTPUTF8\r\n",
+			Expected: []string{"LOGIN", "PLAIN", "XOAUTH2", "PLAIN-CLIENTTOKEN", "OAUTHBEARER", "XOAUTH"},
+		},
+		"obsolete form only": {
+			EHLO:     "250-mail.example.com\r\n250 AUTH=cram-md5 login\r\n",
+			Expected: []string{"CRAM-MD5", "LOGIN"},
+		},
+		"no auth": {
+			EHLO:     "250-mail.example.com\r\n250-PIPELINING\r\n250 STARTTLS\r\n",
+			Expected: nil,
+		},
+	}
+
+	for name, test := range testTable {
+		t.Run(name, func(t *testing.T) {
+			if got := getAuthMechanisms(test.EHLO); !reflect.DeepEqual(got, test.Expected) {
+				t.Errorf("recieved unexpected mechanisms: %v, wanted: %v", got, test.Expected)
+			}
+		})
+	}
+}
+
+// fakeServer answers each command read from conn with the response for its
+// verb (the first word of the command, or "MAIL FROM"/"RCPT TO"), and
+// records the commands it received.
+func fakeServer(conn net.Conn, responses map[string]string, commands *[]string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+		*commands = append(*commands, cmd)
+		verb := strings.SplitN(cmd, ":", 2)[0]
+		if _, err := conn.Write([]byte(responses[verb])); err != nil {
+			return
+		}
+	}
+}
+
+func TestOpenRelay(t *testing.T) {
+	yes, no := true, false
+	testTable := map[string]struct {
+		MailFrom string
+		RcptTo   string
+		Relay    *bool
+		Commands []string
+	}{
+		"open relay": {
+			MailFrom: "250 2.1.0 Ok\r\n",
+			RcptTo:   "250 2.1.5 Ok\r\n",
+			Relay:    &yes,
+			Commands: []string{"MAIL FROM:<>", "RCPT TO:<victim@example.net>", "RSET"},
+		},
+		"relay denied": {
+			MailFrom: "250 2.1.0 Ok\r\n",
+			RcptTo:   "554 5.7.1 <victim@example.net>: Relay access denied\r\n",
+			Relay:    &no,
+			Commands: []string{"MAIL FROM:<>", "RCPT TO:<victim@example.net>", "RSET"},
+		},
+		"greylisted": {
+			MailFrom: "250 2.1.0 Ok\r\n",
+			RcptTo:   "450 4.2.0 <victim@example.net>: Recipient address rejected: Greylisted\r\n",
+			Relay:    nil,
+			Commands: []string{"MAIL FROM:<>", "RCPT TO:<victim@example.net>", "RSET"},
+		},
+		"sender rejected": {
+			MailFrom: "530 5.7.0 Must issue a STARTTLS command first\r\n",
+			Relay:    nil,
+			Commands: []string{"MAIL FROM:<>", "RSET"},
+		},
+	}
+
+	for name, test := range testTable {
+		t.Run(name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			var commands []string
+			done := make(chan struct{})
+			go func() {
+				fakeServer(server, map[string]string{
+					"MAIL FROM": test.MailFrom,
+					"RCPT TO":   test.RcptTo,
+					"RSET":      "250 2.0.0 Ok\r\n",
+				}, &commands)
+				close(done)
+			}()
+			conn := Connection{Conn: client}
+			result, err := conn.testOpenRelay("", "victim@example.net")
+			client.Close()
+			<-done
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result.Relay, test.Relay) {
+				t.Errorf("recieved unexpected relay: %v, wanted: %v", result.Relay, test.Relay)
+			}
+			if result.MailFrom != test.MailFrom || result.RcptTo != test.RcptTo || result.RSET == "" {
+				t.Errorf("recieved unexpected responses: %+v", result)
+			}
+			if !reflect.DeepEqual(commands, test.Commands) {
+				t.Errorf("recieved unexpected commands: %q, wanted: %q", commands, test.Commands)
+			}
+		})
+	}
+}
+
+func TestValidateOpenRelay(t *testing.T) {
+	flags := &Flags{OpenRelayTest: true}
+	if err := flags.Validate(nil); err == nil {
+		t.Errorf("expected an error without --relay-to")
+	}
+	flags = &Flags{RelayTo: "victim@example.net"}
+	if err := flags.Validate(nil); err == nil {
+		t.Errorf("expected an error without --open-relay-test")
+	}
+	flags = &Flags{OpenRelayTest: true, RelayTo: "victim@example.net"}
+	if err := flags.Validate(nil); err != nil || !flags.SendEHLO {
+		t.Errorf("expected --open-relay-test to imply --send-ehlo, got %v", err)
+	}
+	flags = &Flags{OpenRelayTest: true, RelayTo: "victim@example.net", SendHELO: true}
+	if err := flags.Validate(nil); err != nil || flags.SendEHLO {
+		t.Errorf("expected --send-helo to be kept, got %v", err)
+	}
+}
@@ -1,9 +1,12 @@
 package modules
 
 import (
+	"context"
+	"encoding/hex"
 	"net"
 	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/zmap/zgrab2"
@@ -16,19 +19,21 @@ type SSHFlags struct {
 	KexAlgorithms     string `long:"kex-algorithms" description:"Set SSH Key Exchange Algorithms"`
 	HostKeyAlgorithms string `long:"host-key-algorithms" description:"Set SSH Host Key Algorithms"`
 	Ciphers           string `long:"ciphers" description:"A comma-separated list of which ciphers to offer."`
-	CollectUserAuth   bool   `long:"userauth" description:"Use the 'none' authentication request to see what userauth methods are allowed"`
+	CollectUserAuth   bool   `long:"userauth" description:"Use the 'none' authentication request to see what userauth methods are allowed, without attempting any; the methods are recorded in auth_methods (and in the deprecated userauth)"`
 	GexMinBits        uint   `long:"gex-min-bits" description:"The minimum number of bits for the DH GEX prime." default:"1024"`
 	GexMaxBits        uint   `long:"gex-max-bits" description:"The maximum number of bits for the DH GEX prime." default:"8192"`
 	GexPreferredBits  uint   `long:"gex-preferred-bits" description:"The preferred number of bits for the DH GEX prime." default:"2048"`
 	HelloOnly         bool   `long:"hello-only" description:"Limit scan to the initial hello message"`
 	Verbose           bool   `long:"verbose" description:"Output additional information, including SSH client properties from the SSH handshake."`
+	RawClientBanner   string `long:"raw-client-banner" description:"For protocol-robustness testing, send these bytes (in hex) exactly as given in place of the client identification line, with no CR LF added; overrides --client"`
 }
 
 type SSHModule struct {
 }
 
 type SSHScanner struct {
-	config *SSHFlags
+	config          *SSHFlags
+	rawClientBanner []byte
 }
 
 func init() {
@@ -57,6 +62,10 @@ func (m *SSHModule) Description() string {
 }
 
 func (f *SSHFlags) Validate(args []string) error {
+	if _, err := hex.DecodeString(f.RawClientBanner); err != nil {
+		log.Errorf("--raw-client-banner must be hex: %v", err)
+		return zgrab2.ErrInvalidArguments
+	}
 	return nil
 }
 
@@ -67,6 +76,7 @@ func (f *SSHFlags) Help() string {
 func (s *SSHScanner) Init(flags zgrab2.ScanFlags) error {
 	f, _ := flags.(*SSHFlags)
 	s.config = f
+	s.rawClientBanner, _ = hex.DecodeString(f.RawClientBanner)
 	return nil
 }
 
@@ -82,7 +92,7 @@ func (s *SSHScanner) GetTrigger() string {
 	return s.config.Trigger
 }
 
-func (s *SSHScanner) Scan(t zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+func (s *SSHScanner) Scan(ctx context.Context, t zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
 	data := new(ssh.HandshakeLog)
 
 	var port uint
@@ -99,6 +109,7 @@ func (s *SSHScanner) Scan(t zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{},
 	sshConfig.Timeout = s.config.Timeout
 	sshConfig.ConnLog = data
 	sshConfig.ClientVersion = s.config.ClientID
+	sshConfig.RawClientBanner = s.rawClientBanner
 	sshConfig.HelloOnly = s.config.HelloOnly
 	if err := sshConfig.SetHostKeyAlgorithms(s.config.HostKeyAlgorithms); err != nil {
 		log.Fatal(err)
@@ -118,7 +129,18 @@ func (s *SSHScanner) Scan(t zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{},
 		data.Banner = strings.TrimSpace(banner)
 		return nil
 	}
-	_, err := ssh.Dial("tcp", rhost, sshConfig)
+	// Dial through zgrab2, so that the framework's connection options (and
+	// timeouts) apply, rather than with ssh.Dial.
+	conn, err := t.Open(&s.config.BaseFlags)
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	defer conn.Close()
+	start := time.Now()
+	_, _, _, err = ssh.NewClientConn(conn, rhost, sshConfig)
+	if err == nil {
+		t.RecordHandshake(start)
+	}
 	// TODO FIXME: Distinguish error types
 	status := zgrab2.TryGetScanStatus(err)
 	return status, data, err
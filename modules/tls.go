@@ -1,13 +1,38 @@
 package modules
 
 import (
+	"bytes"
+	"context"
+	"strings"
+
 	log "github.com/sirupsen/logrus"
 	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/modules/jarm"
 )
 
 type TLSFlags struct {
 	zgrab2.BaseFlags
 	zgrab2.TLSFlags
+
+	// JARM causes the JARM probes to be sent after the handshake, on new
+	// connections, and the resulting fingerprint to be added to the log.
+	JARM bool `long:"jarm" description:"Also compute the server's JARM fingerprint"`
+
+	// SNIProbes causes two more handshakes to be made after the main one,
+	// one without SNI and one with WrongSNI, recording the certificate each
+	// elicits; comparing them with the main handshake's reveals default
+	// virtual hosts and shared hosting.
+	SNIProbes bool   `long:"sni-probes" description:"Also handshake without SNI and with --wrong-sni, and record the certificates presented"`
+	WrongSNI  string `long:"wrong-sni" default:"zgrab2-sni-probe.invalid" description:"Server name sent in the wrong-SNI handshake of --sni-probes"`
+
+	// Sweep causes one more handshake per protocol version and per weak
+	// cipher group to be made after the main one; see zgrab2.SweepTLS.
+	Sweep bool `long:"sweep" description:"Also probe which of SSLv3 to TLSv1.3, and of the RC4, 3DES, EXPORT and NULL cipher suites, the server accepts"`
+
+	// ALPN is the comma-separated list of application protocols to offer
+	// in the ClientHello; the protocols offered and the one selected are
+	// added to the log.
+	ALPN string `long:"alpn" description:"Offer this comma-separated list of ALPN protocols (e.g. h2,http/1.1), and record which the server selected"`
 }
 
 type TLSModule struct {
@@ -15,6 +40,9 @@ type TLSModule struct {
 
 type TLSScanner struct {
 	config *TLSFlags
+
+	// alpn is the parsed list of --alpn protocols.
+	alpn []string
 }
 
 func init() {
@@ -39,9 +67,35 @@ func (m *TLSModule) Description() string {
 }
 
 func (f *TLSFlags) Validate(args []string) error {
+	if f.SNIProbes && f.WrongSNI == "" {
+		log.Error("--wrong-sni must not be empty")
+		return zgrab2.ErrInvalidArguments
+	}
+	if f.ALPN != "" {
+		if f.NextProtos != "" {
+			log.Error("--alpn and --next-protos are mutually exclusive")
+			return zgrab2.ErrInvalidArguments
+		}
+		for _, proto := range parseALPN(f.ALPN) {
+			// RFC 7301: protocol names are 1 to 255 bytes long.
+			if proto == "" || len(proto) > 255 {
+				log.Errorf("Invalid ALPN protocol %q in --alpn", proto)
+				return zgrab2.ErrInvalidArguments
+			}
+		}
+	}
 	return nil
 }
 
+// parseALPN splits a comma-separated list of ALPN protocols.
+func parseALPN(list string) []string {
+	protos := strings.Split(list, ",")
+	for i, proto := range protos {
+		protos[i] = strings.TrimSpace(proto)
+	}
+	return protos
+}
+
 func (f *TLSFlags) Help() string {
 	return ""
 }
@@ -52,6 +106,10 @@ func (s *TLSScanner) Init(flags zgrab2.ScanFlags) error {
 		return zgrab2.ErrMismatchedFlags
 	}
 	s.config = f
+	if f.ALPN != "" {
+		s.alpn = parseALPN(f.ALPN)
+		f.NextProtos = strings.Join(s.alpn, ",")
+	}
 	return nil
 }
 
@@ -67,11 +125,95 @@ func (s *TLSScanner) InitPerSender(senderID int) error {
 	return nil
 }
 
+// addJARM adds the target's JARM fingerprint to the log, if --jarm is set.
+// The probes use their own connections, so failures are only logged.
+func (s *TLSScanner) addJARM(t *zgrab2.ScanTarget, tlsLog *zgrab2.TLSLog) {
+	if !s.config.JARM {
+		return
+	}
+	fingerprint, err := jarm.Fingerprint(t, &s.config.BaseFlags)
+	if err != nil {
+		log.Debugf("Failed to compute JARM fingerprint of %s: %v", t.String(), err)
+		return
+	}
+	tlsLog.JARM = fingerprint
+}
+
+// sniProbe makes a handshake with the given server name (without SNI, if
+// serverName is empty), and records the certificates presented.
+func (s *TLSScanner) sniProbe(t *zgrab2.ScanTarget, serverName string, leaf []byte) *zgrab2.SNIProbe {
+	flags := s.config.TLSFlags
+	flags.ServerName = serverName
+	flags.NoSNI = serverName == ""
+	flags.Heartbleed = false
+	flags.ValidateCert = false
+	probe := &zgrab2.SNIProbe{ServerName: serverName}
+	conn, err := t.OpenTLS(&s.config.BaseFlags, &flags)
+	if conn != nil {
+		defer conn.Close()
+		if handshake := conn.GetLog().HandshakeLog; handshake != nil && handshake.ServerCertificates != nil {
+			probe.ServerCertificates = handshake.ServerCertificates
+			probe.SameCertificate = leaf != nil && bytes.Equal(handshake.ServerCertificates.Certificate.Raw, leaf)
+		}
+	}
+	if err != nil {
+		probe.Error = err.Error()
+	}
+	return probe
+}
+
+// addSweep adds the accepted versions and weak cipher suites to the log, if
+// --sweep is set. The server name is sent as in the main handshake.
+func (s *TLSScanner) addSweep(t *zgrab2.ScanTarget, tlsLog *zgrab2.TLSLog) {
+	if !s.config.Sweep {
+		return
+	}
+	serverName := s.config.ServerName
+	if serverName == "" && !s.config.NoSNI {
+		serverName = t.Domain
+	}
+	tlsLog.Sweep = zgrab2.SweepTLS(t, &s.config.BaseFlags, serverName)
+}
+
+// addALPN adds the protocols offered and the one the server selected to the
+// log, if --alpn is set.
+func (s *TLSScanner) addALPN(conn *zgrab2.TLSConnection, tlsLog *zgrab2.TLSLog) {
+	if s.alpn == nil {
+		return
+	}
+	tlsLog.ALPN = &zgrab2.ALPN{
+		Offered:  s.alpn,
+		Selected: conn.ConnectionState().NegotiatedProtocol,
+	}
+}
+
+// addProbes runs the additional probes enabled by --jarm, --sni-probes and
+// --sweep, adding their results to the log.
+func (s *TLSScanner) addProbes(t *zgrab2.ScanTarget, tlsLog *zgrab2.TLSLog) {
+	s.addJARM(t, tlsLog)
+	if s.config.SNIProbes {
+		var leaf []byte
+		if tlsLog.HandshakeLog != nil && tlsLog.HandshakeLog.ServerCertificates != nil {
+			leaf = tlsLog.HandshakeLog.ServerCertificates.Certificate.Raw
+		}
+		tlsLog.SNIProbes = []*zgrab2.SNIProbe{
+			s.sniProbe(t, "", leaf),
+			s.sniProbe(t, s.config.WrongSNI, leaf),
+		}
+	}
+	s.addSweep(t, tlsLog)
+}
+
 // Scan opens a TCP connection to the target (default port 443), then performs
 // a TLS handshake. If the handshake gets past the ServerHello stage, the
 // handshake log is returned (along with any other TLS-related logs, such as
-// heartbleed, if enabled).
-func (s *TLSScanner) Scan(t zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+// heartbleed, if enabled). With --alpn, the protocols offered and the one
+// selected are added to the log (the selection is empty if the server does
+// not support ALPN). With --jarm, the JARM fingerprint is then computed
+// and added to the log; with --sni-probes, so are the certificates presented
+// without SNI and with a wrong SNI; and with --sweep, so are the accepted
+// protocol versions and weak cipher suites.
+func (s *TLSScanner) Scan(ctx context.Context, t zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
 	conn, err := t.OpenTLS(&s.config.BaseFlags, &s.config.TLSFlags)
 	if conn != nil {
 		defer conn.Close()
@@ -82,6 +224,8 @@ func (s *TLSScanner) Scan(t zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{},
 				if log.HandshakeLog.ServerHello != nil {
 					// If we got far enough to get a valid ServerHello, then
 					// consider it to be a positive TLS detection.
+					s.addALPN(conn, log)
+					s.addProbes(&t, log)
 					return zgrab2.TryGetScanStatus(err), log, err
 				}
 				// Otherwise, detection failed.
@@ -89,7 +233,10 @@ func (s *TLSScanner) Scan(t zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{},
 		}
 		return zgrab2.TryGetScanStatus(err), nil, err
 	}
-	return zgrab2.SCAN_SUCCESS, conn.GetLog(), nil
+	tlsLog := conn.GetLog()
+	s.addALPN(conn, tlsLog)
+	s.addProbes(&t, tlsLog)
+	return zgrab2.SCAN_SUCCESS, tlsLog, nil
 }
 
 // Protocol returns the protocol identifer for the scanner.
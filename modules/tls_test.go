@@ -0,0 +1,175 @@
+package modules
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	stdtls "crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+// makeCertificate returns a self-signed certificate for name.
+func makeCertificate(t *testing.T, name string) stdtls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stdtls.Certificate{Certificate: [][]byte{raw}, PrivateKey: key}
+}
+
+func TestSNIProbes(t *testing.T) {
+	vhost := makeCertificate(t, "example.com")
+	fallback := makeCertificate(t, "default.example")
+	config := &stdtls.Config{
+		MaxVersion: stdtls.VersionTLS12,
+		GetCertificate: func(hello *stdtls.ClientHelloInfo) (*stdtls.Certificate, error) {
+			if hello.ServerName == "example.com" {
+				return &vhost, nil
+			}
+			return &fallback, nil
+		},
+	}
+	listener, err := stdtls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.(*stdtls.Conn).Handshake()
+			}()
+		}
+	}()
+
+	var module TLSModule
+	flags := module.NewFlags().(*TLSFlags)
+	flags.Port = uint(listener.Addr().(*net.TCPAddr).Port)
+	flags.Timeout = 5 * time.Second
+	flags.SNIProbes = true
+	flags.WrongSNI = "wrong.example"
+	if err := flags.Validate(nil); err != nil {
+		t.Fatal(err)
+	}
+	scanner := module.NewScanner()
+	scanner.Init(flags)
+
+	target := zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Domain: "example.com"}
+	status, result, err := scanner.Scan(context.Background(), target)
+	if status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("unexpected status %s (%v)", status, err)
+	}
+	tlsLog := result.(*zgrab2.TLSLog)
+	if leaf := tlsLog.HandshakeLog.ServerCertificates.Certificate.Parsed; leaf.Subject.CommonName != "example.com" {
+		t.Errorf("unexpected main certificate %s", leaf.Subject.CommonName)
+	}
+	if len(tlsLog.SNIProbes) != 2 {
+		t.Fatalf("expected 2 SNI probes, got %d", len(tlsLog.SNIProbes))
+	}
+	for i, serverName := range []string{"", "wrong.example"} {
+		probe := tlsLog.SNIProbes[i]
+		if probe.ServerName != serverName || probe.Error != "" || probe.SameCertificate {
+			t.Errorf("unexpected probe %+v", probe)
+		}
+		if leaf := probe.ServerCertificates.Certificate.Parsed; leaf.Subject.CommonName != "default.example" {
+			t.Errorf("probe %q: unexpected certificate %s", serverName, leaf.Subject.CommonName)
+		}
+	}
+}
+
+func TestALPN(t *testing.T) {
+	cert := makeCertificate(t, "example.com")
+	for _, test := range []struct {
+		name     string
+		server   []string
+		selected string
+	}{
+		{"h2", []string{"h2", "http/1.1"}, "h2"},
+		{"http/1.1 only", []string{"http/1.1"}, "http/1.1"},
+		{"no ALPN", nil, ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			config := &stdtls.Config{
+				MaxVersion:   stdtls.VersionTLS12,
+				Certificates: []stdtls.Certificate{cert},
+				NextProtos:   test.server,
+			}
+			listener, err := stdtls.Listen("tcp", "127.0.0.1:0", config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer listener.Close()
+			go func() {
+				for {
+					conn, err := listener.Accept()
+					if err != nil {
+						return
+					}
+					go func() {
+						defer conn.Close()
+						conn.(*stdtls.Conn).Handshake()
+					}()
+				}
+			}()
+
+			var module TLSModule
+			flags := module.NewFlags().(*TLSFlags)
+			flags.Port = uint(listener.Addr().(*net.TCPAddr).Port)
+			flags.Timeout = 5 * time.Second
+			flags.ALPN = "h2, http/1.1"
+			if err := flags.Validate(nil); err != nil {
+				t.Fatal(err)
+			}
+			scanner := module.NewScanner()
+			scanner.Init(flags)
+			status, result, err := scanner.Scan(context.Background(), zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1")})
+			if status != zgrab2.SCAN_SUCCESS {
+				t.Fatalf("unexpected status %s (%v)", status, err)
+			}
+			alpn := result.(*zgrab2.TLSLog).ALPN
+			if alpn == nil || !reflect.DeepEqual(alpn.Offered, []string{"h2", "http/1.1"}) || alpn.Selected != test.selected {
+				t.Errorf("unexpected ALPN %+v, expected %q to be selected", alpn, test.selected)
+			}
+		})
+	}
+}
+
+func TestValidateALPN(t *testing.T) {
+	for _, list := range []string{"h2,", "h2,,http/1.1", strings.Repeat("x", 256)} {
+		flags := &TLSFlags{ALPN: list}
+		if err := flags.Validate(nil); err == nil {
+			t.Errorf("expected --alpn %q to be rejected", list)
+		}
+	}
+	flags := &TLSFlags{ALPN: "h2"}
+	flags.NextProtos = "http/1.1"
+	if err := flags.Validate(nil); err == nil {
+		t.Errorf("expected --alpn and --next-protos to be rejected")
+	}
+}
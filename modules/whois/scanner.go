@@ -0,0 +1,271 @@
+// Package whois provides a zgrab2 module that queries WHOIS servers.
+// Default Port: 43 (TCP)
+//
+// The scanner sends a single query line -- the --query flag, or else the
+// target's domain or IP address -- and records the full response text, along
+// with a few common fields when present: the registrar and the creation and
+// expiration dates.
+//
+// If --follow-referral is set and the response refers the query to another
+// WHOIS server (e.g. a thin registry's "Registrar WHOIS Server"), the scanner
+// sends the same query there and records that response too; its fields take
+// precedence, since the registrar's records are usually the more detailed.
+// Only referrals to port 43 on public addresses are followed, and the
+// referral counts against the target's timeout.
+package whois
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmap/zgrab2"
+)
+
+// ScanResults instances are returned by the module's Scan function.
+type ScanResults struct {
+	// Query is the query that was sent.
+	Query string `json:"query"`
+
+	// Response is the target's response.
+	Response *Response `json:"response,omitempty"`
+
+	// Referral is the response of the server that the target referred the
+	// query to, with --follow-referral.
+	Referral *Response `json:"referral,omitempty"`
+
+	// ReferralError is the error from querying the referral server, if any.
+	ReferralError string `json:"referral_error,omitempty"`
+
+	// Fields are the common fields parsed from the responses.
+	Fields *Fields `json:"fields,omitempty"`
+}
+
+// Flags holds the command-line configuration for the whois scan module.
+// Populated by the framework.
+type Flags struct {
+	zgrab2.BaseFlags
+
+	// Query is the query to send, in place of the target's domain or IP
+	// address.
+	Query string `long:"query" description:"Query to send; defaults to the target's domain, or else its IP address"`
+
+	// FollowReferral indicates that the query should be sent to the WHOIS
+	// server that the target refers it to, if any.
+	FollowReferral bool `long:"follow-referral" description:"Send the query to the WHOIS server that the response refers to, if any, and record its response too; only referrals to port 43 on public addresses are followed"`
+
+	// MaxSize bounds the size of each response that is read.
+	MaxSize int `long:"max-size" default:"256" description:"Max kilobytes to read in response to each query"`
+
+	// Verbose indicates that there should be more verbose logging.
+	Verbose bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
+}
+
+// Module implements the zgrab2.Module interface.
+type Module struct {
+}
+
+// Scanner implements the zgrab2.Scanner interface.
+type Scanner struct {
+	config *Flags
+}
+
+// RegisterModule registers the zgrab2 module.
+func RegisterModule() {
+	var module Module
+	_, err := zgrab2.AddCommand("whois", "whois", module.Description(), 43, &module)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewFlags returns a default Flags object.
+func (module *Module) NewFlags() interface{} {
+	return new(Flags)
+}
+
+// NewScanner returns a new Scanner instance.
+func (module *Module) NewScanner() zgrab2.Scanner {
+	return new(Scanner)
+}
+
+// Description returns an overview of this module.
+func (module *Module) Description() string {
+	return "Send a WHOIS query and record the response, optionally following the referral"
+}
+
+// Validate checks that the flags are valid.
+// On success, returns nil.
+// On failure, returns an error instance describing the error.
+func (flags *Flags) Validate(args []string) error {
+	if flags.MaxSize <= 0 {
+		log.Errorf("--max-size must be positive, given %d", flags.MaxSize)
+		return zgrab2.ErrInvalidArguments
+	}
+	return nil
+}
+
+// Help returns the module's help string.
+func (flags *Flags) Help() string {
+	return ""
+}
+
+// Init initializes the Scanner.
+func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
+	f, _ := flags.(*Flags)
+	scanner.config = f
+	return nil
+}
+
+// InitPerSender initializes the scanner for a given sender.
+func (scanner *Scanner) InitPerSender(senderID int) error {
+	return nil
+}
+
+// GetName returns the Scanner name defined in the Flags.
+func (scanner *Scanner) GetName() string {
+	return scanner.config.Name
+}
+
+// GetTrigger returns the Trigger defined in the Flags.
+func (scanner *Scanner) GetTrigger() string {
+	return scanner.config.Trigger
+}
+
+// Protocol returns the protocol identifier of the scan.
+func (scanner *Scanner) Protocol() string {
+	return "whois"
+}
+
+// resolveReferral returns the address to dial for the referral to address.
+// Only referrals to the WHOIS port on public addresses are followed, so that
+// a response cannot direct the scanner at arbitrary services, or at the
+// scanner's own network. It is a variable so that tests can replace it.
+var resolveReferral = func(ctx context.Context, address string) (string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", err
+	}
+	if port != DefaultPort {
+		return "", fmt.Errorf("referral to port %s not followed", port)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return "", err
+		}
+		if len(addrs) == 0 {
+			return "", fmt.Errorf("no addresses found for %s", host)
+		}
+		ip = addrs[0].IP
+	}
+	if zgrab2.IsReservedIP(ip) {
+		return "", fmt.Errorf("referral to non-public address %s not followed", ip)
+	}
+	return net.JoinHostPort(ip.String(), port), nil
+}
+
+// queryReferral sends query to the WHOIS server at address, giving up at
+// deadline, the end of the target's timeout.
+func (scanner *Scanner) queryReferral(ctx context.Context, deadline time.Time, address string, query string) (*Response, error) {
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	dialAddress, err := resolveReferral(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	timeout := time.Until(deadline)
+	if timeout <= 0 {
+		return nil, context.DeadlineExceeded
+	}
+	conn, err := zgrab2.DialTimeoutConnectionContext(ctx, "tcp", dialAddress, timeout, scanner.config.BytesReadLimit)
+	if err != nil {
+		return nil, err
+	}
+	zgrab2.EnableTLSDetection(conn)
+	defer conn.Close()
+	response, err := Query(conn, query, scanner.config.MaxSize*1024)
+	if response != nil {
+		response.Server = address
+	}
+	return response, err
+}
+
+// Scan performs the WHOIS scan.
+//  1. Open a TCP connection to the target port (default 43).
+//  2. Send the query, and read the response until the server closes the
+//     connection. If it sends nothing, fail with a protocol error.
+//  3. If --follow-referral is set and the response refers to another
+//     server on port 43 at a public address, send it the query, within
+//     what remains of the target's timeout; failures there are recorded in
+//     referral_error, and do not fail the scan.
+//  4. Parse the common fields from the responses.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	deadline := time.Now().Add(scanner.config.Timeout)
+	result := &ScanResults{Query: scanner.config.Query}
+	if result.Query == "" {
+		result.Query = target.Domain
+	}
+	if result.Query == "" {
+		result.Query = target.IP.String()
+	}
+	conn, err := target.Open(&scanner.config.BaseFlags)
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	zgrab2.EnableTLSDetection(conn)
+	defer conn.Close()
+
+	response, err := Query(conn, result.Query, scanner.config.MaxSize*1024)
+	if response == nil {
+		if err == ErrEmptyResponse {
+			return zgrab2.SCAN_PROTOCOL_ERROR, result, err
+		}
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+	result.Response = response
+	result.Fields = ParseFields(response.Text)
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+	conn.Close()
+
+	if scanner.config.FollowReferral {
+		if address := ParseReferral(response.Text); address != "" && !isTarget(address, &target, scanner.config.Port) {
+			referral, err := scanner.queryReferral(ctx, deadline, address, result.Query)
+			if referral != nil {
+				result.Referral = referral
+				result.Fields = mergeFields(ParseFields(referral.Text), result.Fields)
+			}
+			if err != nil {
+				result.ReferralError = err.Error()
+			}
+		}
+	}
+	return zgrab2.SCAN_SUCCESS, result, nil
+}
+
+// isTarget returns true if address is the target itself, so that a server
+// referring to itself is not queried again.
+func isTarget(address string, target *zgrab2.ScanTarget, defaultPort uint) bool {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	targetPort := defaultPort
+	if target.Port != nil {
+		targetPort = *target.Port
+	}
+	if port != strconv.FormatUint(uint64(targetPort), 10) {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.Equal(target.IP)
+	}
+	return strings.EqualFold(strings.TrimSuffix(host, "."), strings.TrimSuffix(target.Domain, "."))
+}
@@ -0,0 +1,169 @@
+// WHOIS protocol client for the whois module, as described in RFC 3912: the
+// client sends a single query line, and the server sends its response and
+// closes the connection.
+
+package whois
+
+import (
+	"bufio"
+	"net"
+	"strings"
+
+	"github.com/zmap/zgrab2"
+)
+
+// DefaultPort is the WHOIS port, used for referrals that do not give one.
+const DefaultPort = "43"
+
+// ErrEmptyResponse is returned when the server closes the connection without
+// sending a response.
+var ErrEmptyResponse = zgrab2.ErrEmptyResponse
+
+// Response is a WHOIS server's response to a query.
+type Response struct {
+	// Server is the server that was queried, for referrals. It is omitted
+	// for the target itself.
+	Server string `json:"server,omitempty"`
+
+	// Text is the full response text.
+	Text string `json:"text"`
+
+	// Truncated is true if the response was longer than --max-size, so only
+	// its start is in Text.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Query sends query on conn, and reads the response until the server closes
+// the connection, up to maxSize bytes. If the read fails after part of the
+// response has been received, both are returned.
+func Query(conn net.Conn, query string, maxSize int) (*Response, error) {
+	if _, err := conn.Write([]byte(query + "\r\n")); err != nil {
+		return nil, err
+	}
+	data, truncated, err := zgrab2.ReadUntilClose(conn, maxSize)
+	if data == nil {
+		return nil, err
+	}
+	return &Response{Text: string(data), Truncated: truncated}, err
+}
+
+// Fields holds the common fields parsed from a response; the values are
+// given as the server sent them, since the date formats vary between
+// registries.
+type Fields struct {
+	// Registrar is the sponsoring registrar of the domain.
+	Registrar string `json:"registrar,omitempty"`
+
+	// CreationDate is the date the domain (or network) was registered.
+	CreationDate string `json:"creation_date,omitempty"`
+
+	// ExpirationDate is the date the registration expires.
+	ExpirationDate string `json:"expiration_date,omitempty"`
+}
+
+// The keys of the common fields, lowercased, as used by the registries and
+// registrars (the gTLDs' "Registry Expiry Date", RIPE's "created", the
+// ccTLDs' "paid-till", ...). The first key found for each field is used.
+var (
+	registrarKeys = []string{"registrar", "registrar name", "sponsoring registrar"}
+
+	creationDateKeys = []string{"creation date", "created", "created on", "registered on", "registration time", "regdate"}
+
+	expirationDateKeys = []string{"registry expiry date", "registrar registration expiration date", "expiration date", "expiry date", "expires on", "expires", "paid-till"}
+
+	// referralKeys give the server to query next: IANA's "refer", the thin
+	// gTLD registries' "Registrar WHOIS Server", and ARIN's "ReferralServer"
+	// (a whois:// URL).
+	referralKeys = []string{"refer", "registrar whois server", "whois server", "referralserver"}
+)
+
+// parseKeyValues returns the "key: value" pairs in text, with the keys
+// lowercased. Only the first value of each key is kept.
+func parseKeyValues(text string) map[string]string {
+	ret := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(nil, len(text)+1)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '%' || line[0] == '#' || line[0] == '>' {
+			continue
+		}
+		i := strings.IndexByte(line, ':')
+		if i <= 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+		if _, ok := ret[key]; !ok && value != "" {
+			ret[key] = value
+		}
+	}
+	return ret
+}
+
+// firstValue returns the value of the first of keys present in values.
+func firstValue(values map[string]string, keys []string) string {
+	for _, key := range keys {
+		if value, ok := values[key]; ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// ParseFields parses the common fields from a response, returning nil if
+// none are present.
+func ParseFields(text string) *Fields {
+	values := parseKeyValues(text)
+	ret := &Fields{
+		Registrar:      firstValue(values, registrarKeys),
+		CreationDate:   firstValue(values, creationDateKeys),
+		ExpirationDate: firstValue(values, expirationDateKeys),
+	}
+	if *ret == (Fields{}) {
+		return nil
+	}
+	return ret
+}
+
+// ParseReferral returns the address (host:port) of the WHOIS server that a
+// response refers the query to, or "" if there is none. Referrals to other
+// protocols (e.g. rwhois:// or http://) are ignored.
+func ParseReferral(text string) string {
+	referral := firstValue(parseKeyValues(text), referralKeys)
+	if i := strings.Index(referral, "://"); i >= 0 {
+		if !strings.EqualFold(referral[:i], "whois") {
+			return ""
+		}
+		referral = strings.TrimRight(referral[i+3:], "/")
+	}
+	if referral == "" || strings.ContainsAny(referral, " \t/") {
+		return ""
+	}
+	if _, _, err := net.SplitHostPort(referral); err != nil {
+		referral = net.JoinHostPort(referral, DefaultPort)
+	}
+	return referral
+}
+
+// mergeFields returns the fields of primary, filling any that are missing
+// from fallback.
+func mergeFields(primary, fallback *Fields) *Fields {
+	if primary == nil {
+		return fallback
+	}
+	if fallback == nil {
+		return primary
+	}
+	ret := *primary
+	if ret.Registrar == "" {
+		ret.Registrar = fallback.Registrar
+	}
+	if ret.CreationDate == "" {
+		ret.CreationDate = fallback.CreationDate
+	}
+	if ret.ExpirationDate == "" {
+		ret.ExpirationDate = fallback.ExpirationDate
+	}
+	return &ret
+}
@@ -0,0 +1,164 @@
+package whois
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+// serveWHOIS accepts connections on listener, answering each query with
+// response, and checking that the query is example.com.
+func serveWHOIS(t *testing.T, listener net.Listener, response string) {
+	for {
+		c, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(c net.Conn) {
+			defer c.Close()
+			if line, _ := bufio.NewReader(c).ReadString('\n'); line != "example.com\r\n" {
+				t.Errorf("unexpected query %q", line)
+				return
+			}
+			c.Write([]byte(response))
+		}(c)
+	}
+}
+
+func listen(t *testing.T, response string) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go serveWHOIS(t, listener, response)
+	return listener
+}
+
+func TestScan(t *testing.T) {
+	registrar := listen(t, "Domain Name: example.com\r\n"+
+		"Registrar: Example Registrar, Inc.\r\n"+
+		"Creation Date: 1995-08-14T04:00:00Z\r\n")
+	defer registrar.Close()
+	registry := listen(t, "   Domain Name: EXAMPLE.COM\r\n"+
+		"   Registrar WHOIS Server: "+registrar.Addr().String()+"\r\n"+
+		"   Registrar: RESERVED-Internet Assigned Numbers Authority\r\n"+
+		"   Registry Expiry Date: 2025-08-13T04:00:00Z\r\n"+
+		">>> Last update of whois database: 2024-01-01T00:00:00Z <<<\r\n")
+	defer registry.Close()
+
+	port := uint(registry.Addr().(*net.TCPAddr).Port)
+	scanner := &Scanner{config: &Flags{FollowReferral: true, MaxSize: 256}}
+	scanner.config.Timeout = 5 * time.Second
+	target := zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Domain: "example.com", Port: &port}
+
+	// The referral is to a loopback address on another port, so it is not
+	// followed unless resolveReferral is replaced.
+	status, res, err := scanner.Scan(context.Background(), target)
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	if result := res.(*ScanResults); result.Referral != nil || result.ReferralError == "" {
+		t.Fatalf("expected the referral not to be followed, got %+v", result.Referral)
+	}
+
+	defer func(saved func(context.Context, string) (string, error)) { resolveReferral = saved }(resolveReferral)
+	resolveReferral = func(ctx context.Context, address string) (string, error) {
+		return address, nil
+	}
+	status, res, err = scanner.Scan(context.Background(), target)
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	result := res.(*ScanResults)
+	if result.Query != "example.com" || result.Response == nil || result.Response.Truncated {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if result.Referral == nil || result.Referral.Server != registrar.Addr().String() || result.ReferralError != "" {
+		t.Fatalf("expected the referral to be followed, got %+v, %s", result.Referral, result.ReferralError)
+	}
+	expected := &Fields{
+		Registrar:      "Example Registrar, Inc.",
+		CreationDate:   "1995-08-14T04:00:00Z",
+		ExpirationDate: "2025-08-13T04:00:00Z",
+	}
+	if !reflect.DeepEqual(result.Fields, expected) {
+		t.Errorf("expected fields %+v, got %+v", expected, result.Fields)
+	}
+}
+
+func TestScanTruncated(t *testing.T) {
+	response := make([]byte, 1500)
+	for i := range response {
+		response[i] = 'x'
+	}
+	listener := listen(t, string(response))
+	defer listener.Close()
+
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	scanner := &Scanner{config: &Flags{Query: "example.com", MaxSize: 1}}
+	scanner.config.Timeout = 5 * time.Second
+	status, res, err := scanner.Scan(context.Background(), zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port})
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	if result := res.(*ScanResults); !result.Response.Truncated || len(result.Response.Text) != 1024 {
+		t.Errorf("expected the response to be truncated to 1024 bytes, got %d", len(result.Response.Text))
+	}
+}
+
+func TestParseReferral(t *testing.T) {
+	tests := map[string]string{
+		"refer:        whois.verisign-grs.com\n":               "whois.verisign-grs.com:43",
+		"ReferralServer:  whois://whois.ripe.net\n":            "whois.ripe.net:43",
+		"ReferralServer:  rwhois://rwhois.example.net:4321\n":  "",
+		"Registrar WHOIS Server: whois.example.com:4343\r\n":   "whois.example.com:4343",
+		"Registrar URL: http://www.example.com\r\n":            "",
+		"% This is the RIPE Database query service.\nfoo: bar": "",
+	}
+	for text, expected := range tests {
+		if actual := ParseReferral(text); actual != expected {
+			t.Errorf("%q: expected %q, got %q", text, expected, actual)
+		}
+	}
+}
+
+func TestResolveReferral(t *testing.T) {
+	tests := map[string]bool{
+		"192.0.32.59:43":     true,
+		"192.0.32.59:4343":   false,
+		"127.0.0.1:43":       false,
+		"10.1.2.3:43":        false,
+		"192.168.1.1:43":     false,
+		"169.254.169.254:43": false,
+		"[::1]:43":           false,
+		"[fe80::1]:43":       false,
+	}
+	for address, allowed := range tests {
+		dialAddress, err := resolveReferral(context.Background(), address)
+		if allowed && (err != nil || dialAddress != address) {
+			t.Errorf("%s: expected the referral to be followed, got %q, %v", address, dialAddress, err)
+		}
+		if !allowed && err == nil {
+			t.Errorf("%s: expected the referral not to be followed, got %q", address, dialAddress)
+		}
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	text := "% RIPE-style response\n" +
+		"inetnum:        192.0.2.0 - 192.0.2.255\n" +
+		"created:        2002-06-25T14:19:09Z\n" +
+		"created:        2003-01-01T00:00:00Z\n"
+	expected := &Fields{CreationDate: "2002-06-25T14:19:09Z"}
+	if fields := ParseFields(text); !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected %+v, got %+v", expected, fields)
+	}
+	if fields := ParseFields("No match for \"EXAMPLE.INVALID\".\r\n"); fields != nil {
+		t.Errorf("expected no fields, got %+v", fields)
+	}
+}
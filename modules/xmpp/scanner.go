@@ -0,0 +1,224 @@
+// Package xmpp provides a zgrab2 module that scans for XMPP servers.
+// Default Port: 5222 (TCP)
+//
+// The scanner opens a stream to the --to domain (by default, the target's
+// domain name or IP) and records the server's stream header along with the
+// advertised features: whether STARTTLS is offered or required, and which
+// SASL mechanisms are available.
+//
+// The --server-to-server flag opens a jabber:server stream instead of a
+// jabber:client one, for scanning server-to-server ports; it does not change
+// the default port, so it should usually be coupled with --port 5269.
+//
+// The --starttls flag tells the scanner to negotiate STARTTLS if it is
+// offered, record the standard TLS log, and then open a new stream and
+// record the features offered over TLS (which often include SASL mechanisms
+// that are withheld before encryption).
+package xmpp
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmap/zgrab2"
+)
+
+// ScanResults instances are returned by the module's Scan function.
+type ScanResults struct {
+	// Namespace is the default namespace of the stream that was opened,
+	// "jabber:client" or "jabber:server".
+	Namespace string `json:"namespace"`
+
+	// Stream is the server's initial stream header and features.
+	Stream *Stream `json:"stream,omitempty"`
+
+	// StartTLS is the server's reply to the <starttls/> command ("proceed"
+	// or "failure"), if it was sent.
+	StartTLS string `json:"starttls,omitempty"`
+
+	// TLSLog is the standard TLS log, if --starttls is enabled and the
+	// server agreed to it.
+	TLSLog *zgrab2.TLSLog `json:"tls,omitempty"`
+
+	// TLSStream is the server's stream header and features after the TLS
+	// upgrade.
+	TLSStream *Stream `json:"tls_stream,omitempty"`
+}
+
+// Flags holds the command-line configuration for the XMPP scan module.
+// Populated by the framework.
+type Flags struct {
+	zgrab2.BaseFlags
+	zgrab2.TLSFlags
+
+	// To is the domain sent in the stream header's to attribute.
+	To string `long:"to" description:"Domain to send in the stream header's 'to' attribute (default: the target's domain name, or IP)"`
+
+	// From is the domain sent in the stream header's from attribute.
+	From string `long:"from" description:"Domain to send in the stream header's 'from' attribute (server-to-server streams only)"`
+
+	// ServerToServer indicates that a jabber:server stream should be opened.
+	ServerToServer bool `long:"server-to-server" description:"Open a server-to-server (jabber:server) stream instead of a client one"`
+
+	// StartTLS indicates that the client should attempt to update the connection to TLS.
+	StartTLS bool `long:"starttls" description:"Negotiate STARTTLS, if offered, and record the features offered over TLS"`
+
+	// Verbose indicates that there should be more verbose logging.
+	Verbose bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
+}
+
+// Module implements the zgrab2.Module interface.
+type Module struct {
+}
+
+// Scanner implements the zgrab2.Scanner interface.
+type Scanner struct {
+	config *Flags
+}
+
+// RegisterModule registers the zgrab2 module.
+func RegisterModule() {
+	var module Module
+	_, err := zgrab2.AddCommand("xmpp", "xmpp", module.Description(), 5222, &module)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewFlags returns a default Flags object.
+func (module *Module) NewFlags() interface{} {
+	return new(Flags)
+}
+
+// NewScanner returns a new Scanner instance.
+func (module *Module) NewScanner() zgrab2.Scanner {
+	return new(Scanner)
+}
+
+// Description returns an overview of this module.
+func (module *Module) Description() string {
+	return "Fetch XMPP stream features, optionally negotiating STARTTLS"
+}
+
+// Validate checks that the flags are valid.
+// On success, returns nil.
+// On failure, returns an error instance describing the error.
+func (flags *Flags) Validate(args []string) error {
+	if flags.From != "" && !flags.ServerToServer {
+		log.Error("--from requires --server-to-server")
+		return zgrab2.ErrInvalidArguments
+	}
+	return nil
+}
+
+// Help returns the module's help string.
+func (flags *Flags) Help() string {
+	return ""
+}
+
+// Init initializes the Scanner.
+func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
+	f, _ := flags.(*Flags)
+	scanner.config = f
+	return nil
+}
+
+// InitPerSender initializes the scanner for a given sender.
+func (scanner *Scanner) InitPerSender(senderID int) error {
+	return nil
+}
+
+// GetName returns the Scanner name defined in the Flags.
+func (scanner *Scanner) GetName() string {
+	return scanner.config.Name
+}
+
+// GetTrigger returns the Trigger defined in the Flags.
+func (scanner *Scanner) GetTrigger() string {
+	return scanner.config.Trigger
+}
+
+// Protocol returns the protocol identifier of the scan.
+func (scanner *Scanner) Protocol() string {
+	return "xmpp"
+}
+
+// getTo returns the domain to send in the stream header's to attribute.
+func (scanner *Scanner) getTo(target *zgrab2.ScanTarget) string {
+	if scanner.config.To != "" {
+		return scanner.config.To
+	}
+	if target.Domain != "" {
+		return target.Domain
+	}
+	return target.IP.String()
+}
+
+// Scan performs the XMPP scan.
+//  1. Open a TCP connection to the target port (default 5222).
+//  2. Open a stream and read the server's stream header and features. If
+//     the response is not an XMPP stream, fail with a protocol error.
+//  3. If --starttls is set and the server offers STARTTLS, send
+//     <starttls/>, negotiate a TLS connection using the command-line flags,
+//     then open a new stream and read its features.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	c, err := target.Open(&scanner.config.BaseFlags)
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	zgrab2.EnableTLSDetection(c)
+	defer c.Close()
+	namespace := NamespaceClient
+	if scanner.config.ServerToServer {
+		namespace = NamespaceServer
+	}
+	conn := NewConnection(c, namespace, scanner.getTo(&target), scanner.config.From)
+	result := &ScanResults{Namespace: namespace}
+
+	stream, err := conn.OpenStream()
+	if stream == nil {
+		if err == ErrNotXMPP {
+			return zgrab2.SCAN_PROTOCOL_ERROR, nil, err
+		}
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	result.Stream = stream
+	if err != nil {
+		if err == ErrStreamError {
+			return zgrab2.SCAN_APPLICATION_ERROR, result, err
+		}
+		return zgrab2.TryGetScanStatus(err), result, err
+	}
+
+	if scanner.config.StartTLS && stream.Features != nil && stream.Features.StartTLS {
+		ret, err := conn.StartTLS()
+		result.StartTLS = ret
+		if err != nil {
+			if err == ErrStartTLSFailed {
+				return zgrab2.SCAN_APPLICATION_ERROR, result, err
+			}
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+		tlsConn, err := scanner.config.TLSFlags.GetTLSConnection(conn.Conn())
+		if err != nil {
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+		result.TLSLog = tlsConn.GetLog()
+		if err := tlsConn.Handshake(); err != nil {
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+		conn.Upgrade(tlsConn)
+		stream, err := conn.OpenStream()
+		result.TLSStream = stream
+		if err != nil {
+			if err == ErrNotXMPP {
+				return zgrab2.SCAN_PROTOCOL_ERROR, result, err
+			}
+			if err == ErrStreamError {
+				return zgrab2.SCAN_APPLICATION_ERROR, result, err
+			}
+			return zgrab2.TryGetScanStatus(err), result, err
+		}
+	}
+	return zgrab2.SCAN_SUCCESS, result, nil
+}
@@ -0,0 +1,269 @@
+// Minimal XMPP stream client for the xmpp module: just enough of RFC 6120 to
+// open a stream, read its features and negotiate STARTTLS.
+// https://tools.ietf.org/html/rfc6120
+
+package xmpp
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+const (
+	// NamespaceClient is the default namespace of client-to-server streams.
+	NamespaceClient = "jabber:client"
+
+	// NamespaceServer is the default namespace of server-to-server streams.
+	NamespaceServer = "jabber:server"
+
+	nsStreams  = "http://etherx.jabber.org/streams"
+	nsTLS      = "urn:ietf:params:xml:ns:xmpp-tls"
+	nsSASL     = "urn:ietf:params:xml:ns:xmpp-sasl"
+	nsStreamsE = "urn:ietf:params:xml:ns:xmpp-streams"
+)
+
+// maxStreamSize bounds the number of bytes read from each stream (i.e. before
+// and after the TLS upgrade), so that a misbehaving server cannot make the
+// scanner read forever.
+const maxStreamSize = 0x10000
+
+var (
+	// ErrNotXMPP is returned when the server's response does not begin with
+	// an XMPP stream header.
+	ErrNotXMPP = errors.New("response is not an XMPP stream")
+
+	// ErrStreamError is returned when the server closes the stream with a
+	// <stream:error/>.
+	ErrStreamError = errors.New("server sent a stream error")
+
+	// ErrStartTLSFailed is returned when the server answers <starttls/> with
+	// <failure/>.
+	ErrStartTLSFailed = errors.New("server refused STARTTLS")
+)
+
+// Features is the content of the server's <stream:features/>.
+type Features struct {
+	// StartTLS is true if the server offers STARTTLS.
+	StartTLS bool `json:"starttls"`
+
+	// StartTLSRequired is true if the STARTTLS feature is marked
+	// <required/>.
+	StartTLSRequired bool `json:"starttls_required"`
+
+	// SASLMechanisms lists the advertised SASL mechanisms.
+	SASLMechanisms []string `json:"sasl_mechanisms,omitempty"`
+
+	// Other lists the remaining features, as "namespace local-name".
+	Other []string `json:"other,omitempty"`
+}
+
+// Stream holds the attributes of the server's stream header and the features
+// it advertised.
+type Stream struct {
+	// ID is the stream's id attribute.
+	ID string `json:"id,omitempty"`
+
+	// From is the stream's from attribute, i.e. the server's domain.
+	From string `json:"from,omitempty"`
+
+	// Version is the stream's version attribute, e.g. "1.0".
+	Version string `json:"version,omitempty"`
+
+	// Lang is the stream's xml:lang attribute.
+	Lang string `json:"lang,omitempty"`
+
+	// Features holds the stream features, if the server sent them.
+	Features *Features `json:"features,omitempty"`
+
+	// Error is the condition of the <stream:error/>, if the server sent one
+	// instead of its features, e.g. "host-unknown".
+	Error string `json:"error,omitempty"`
+}
+
+// rawFeatures is used to decode <stream:features/>.
+type rawFeatures struct {
+	StartTLS *struct {
+		Required *struct{} `xml:"required"`
+	} `xml:"urn:ietf:params:xml:ns:xmpp-tls starttls"`
+	Mechanisms []string `xml:"urn:ietf:params:xml:ns:xmpp-sasl mechanisms>mechanism"`
+	Other      []struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+// rawStreamError is used to decode <stream:error/>.
+type rawStreamError struct {
+	Conditions []struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+// Connection wraps the state of an XMPP connection.
+type Connection struct {
+	conn      net.Conn
+	decoder   *xml.Decoder
+	namespace string
+	to        string
+	from      string
+}
+
+// NewConnection returns a Connection over the given net.Conn, whose streams
+// use the given default namespace and to / from domains (from may be empty).
+func NewConnection(conn net.Conn, namespace string, to string, from string) *Connection {
+	c := &Connection{namespace: namespace, to: to, from: from}
+	c.setConn(conn)
+	return c
+}
+
+// setConn switches the connection to conn (e.g. after the TLS upgrade),
+// starting a new XML decoder.
+func (c *Connection) setConn(conn net.Conn) {
+	c.conn = conn
+	c.decoder = xml.NewDecoder(io.LimitReader(conn, maxStreamSize))
+}
+
+// nextStart returns the next start element from the stream, skipping the XML
+// declaration, character data and comments.
+func (c *Connection) nextStart() (*xml.StartElement, error) {
+	for {
+		token, err := c.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			return &t, nil
+		case xml.EndElement:
+			return nil, io.EOF
+		}
+	}
+}
+
+// OpenStream sends a stream header and reads the server's stream header and
+// features.
+func (c *Connection) OpenStream() (*Stream, error) {
+	header := fmt.Sprintf("<?xml version='1.0'?><stream:stream to='%s' ", escapeAttr(c.to))
+	if c.from != "" {
+		header += fmt.Sprintf("from='%s' ", escapeAttr(c.from))
+	}
+	header += fmt.Sprintf("version='1.0' xml:lang='en' xmlns='%s' xmlns:stream='%s'>", c.namespace, nsStreams)
+	if _, err := c.conn.Write([]byte(header)); err != nil {
+		return nil, err
+	}
+	start, err := c.nextStart()
+	if err != nil {
+		if _, ok := err.(*xml.SyntaxError); ok {
+			return nil, ErrNotXMPP
+		}
+		return nil, err
+	}
+	if start.Name.Space != nsStreams || start.Name.Local != "stream" {
+		return nil, ErrNotXMPP
+	}
+	stream := new(Stream)
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "id":
+			stream.ID = attr.Value
+		case "from":
+			stream.From = attr.Value
+		case "version":
+			stream.Version = attr.Value
+		case "lang":
+			stream.Lang = attr.Value
+		}
+	}
+
+	start, err = c.nextStart()
+	if err != nil {
+		return stream, err
+	}
+	if start.Name.Space == nsStreams && start.Name.Local == "error" {
+		var raw rawStreamError
+		if err := c.decoder.DecodeElement(&raw, start); err != nil {
+			return stream, err
+		}
+		for _, condition := range raw.Conditions {
+			if condition.XMLName.Space == nsStreamsE && condition.XMLName.Local != "text" {
+				stream.Error = condition.XMLName.Local
+				break
+			}
+		}
+		return stream, ErrStreamError
+	}
+	if start.Name.Space != nsStreams || start.Name.Local != "features" {
+		return stream, fmt.Errorf("expected stream features, got <%s>", start.Name.Local)
+	}
+	var raw rawFeatures
+	if err := c.decoder.DecodeElement(&raw, start); err != nil {
+		return stream, err
+	}
+	features := &Features{
+		StartTLS:       raw.StartTLS != nil,
+		SASLMechanisms: raw.Mechanisms,
+	}
+	if raw.StartTLS != nil && raw.StartTLS.Required != nil {
+		features.StartTLSRequired = true
+	}
+	for _, other := range raw.Other {
+		features.Other = append(features.Other, other.XMLName.Space+" "+other.XMLName.Local)
+	}
+	stream.Features = features
+	return stream, nil
+}
+
+// StartTLS sends <starttls/> and reads the server's reply, returning its
+// local name ("proceed" or "failure"). On "proceed", the caller must
+// negotiate TLS over the underlying connection and call Upgrade.
+func (c *Connection) StartTLS() (string, error) {
+	if _, err := c.conn.Write([]byte("<starttls xmlns='" + nsTLS + "'/>")); err != nil {
+		return "", err
+	}
+	start, err := c.nextStart()
+	if err != nil {
+		return "", err
+	}
+	if err := c.decoder.Skip(); err != nil {
+		return start.Name.Local, err
+	}
+	switch {
+	case start.Name.Space == nsTLS && start.Name.Local == "proceed":
+		return start.Name.Local, nil
+	case start.Name.Space == nsTLS && start.Name.Local == "failure":
+		return start.Name.Local, ErrStartTLSFailed
+	default:
+		return start.Name.Local, fmt.Errorf("unexpected reply to starttls: <%s>", start.Name.Local)
+	}
+}
+
+// Conn returns the underlying connection.
+func (c *Connection) Conn() net.Conn {
+	return c.conn
+}
+
+// Upgrade switches the connection to conn, which wraps the original
+// connection in TLS. A new stream must then be opened with OpenStream.
+func (c *Connection) Upgrade(conn net.Conn) {
+	c.setConn(conn)
+}
+
+// escapeAttr escapes s for use in a single-quoted XML attribute.
+func escapeAttr(s string) string {
+	var ret []byte
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '&':
+			ret = append(ret, "&amp;"...)
+		case '<':
+			ret = append(ret, "&lt;"...)
+		case '\'':
+			ret = append(ret, "&apos;"...)
+		default:
+			ret = append(ret, s[i])
+		}
+	}
+	return string(ret)
+}
@@ -0,0 +1,84 @@
+package xmpp
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// serveXMPP reads the client's stream header from conn, checks its default
+// namespace, then writes response.
+func serveXMPP(t *testing.T, conn net.Conn, namespace string, response string) {
+	reader := bufio.NewReader(conn)
+	header, err := reader.ReadString('>') // <?xml ...?>
+	if err == nil {
+		header, err = reader.ReadString('>') // <stream:stream ...>
+	}
+	if err != nil {
+		t.Errorf("failed reading stream header: %v", err)
+		return
+	}
+	if !strings.Contains(header, "xmlns='"+namespace+"'") || !strings.Contains(header, "to='example.com'") {
+		t.Errorf("unexpected stream header: %s", header)
+	}
+	conn.Write([]byte(response))
+}
+
+func TestOpenStreamFeatures(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go serveXMPP(t, server, NamespaceClient, "<?xml version='1.0'?>"+
+		"<stream:stream xmlns='jabber:client' xmlns:stream='http://etherx.jabber.org/streams' id='abc123' from='example.com' version='1.0' xml:lang='en'>"+
+		"<stream:features>"+
+		"<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'><required/></starttls>"+
+		"<mechanisms xmlns='urn:ietf:params:xml:ns:xmpp-sasl'><mechanism>SCRAM-SHA-1</mechanism><mechanism>PLAIN</mechanism></mechanisms>"+
+		"<c xmlns='http://jabber.org/protocol/caps' hash='sha-1' node='http://prosody.im' ver='x'/>"+
+		"</stream:features>")
+	stream, err := NewConnection(client, NamespaceClient, "example.com", "").OpenStream()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stream.ID != "abc123" || stream.From != "example.com" || stream.Version != "1.0" || stream.Lang != "en" {
+		t.Errorf("unexpected stream header: %+v", stream)
+	}
+	expected := &Features{
+		StartTLS:         true,
+		StartTLSRequired: true,
+		SASLMechanisms:   []string{"SCRAM-SHA-1", "PLAIN"},
+		Other:            []string{"http://jabber.org/protocol/caps c"},
+	}
+	if !reflect.DeepEqual(stream.Features, expected) {
+		t.Errorf("expected features %+v, got %+v", expected, stream.Features)
+	}
+}
+
+func TestOpenStreamServerError(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go serveXMPP(t, server, NamespaceServer, "<stream:stream xmlns='jabber:server' xmlns:stream='http://etherx.jabber.org/streams' version='1.0'>"+
+		"<stream:error><host-unknown xmlns='urn:ietf:params:xml:ns:xmpp-streams'/></stream:error></stream:stream>")
+	stream, err := NewConnection(client, NamespaceServer, "example.com", "").OpenStream()
+	if err != ErrStreamError {
+		t.Fatalf("expected ErrStreamError, got %v", err)
+	}
+	if stream.Error != "host-unknown" {
+		t.Errorf("expected host-unknown, got %q", stream.Error)
+	}
+}
+
+func TestOpenStreamNotXMPP(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go serveXMPP(t, server, NamespaceClient, "<html><body>hello</body></html>")
+	if _, err := NewConnection(client, NamespaceClient, "example.com", "").OpenStream(); err != ErrNotXMPP {
+		t.Errorf("expected ErrNotXMPP, got %v", err)
+	}
+}
+
+func TestEscapeAttr(t *testing.T) {
+	if s := escapeAttr("a'b<c&d"); s != "a&apos;b&lt;c&amp;d" {
+		t.Errorf("unexpected escaping: %s", s)
+	}
+}
@@ -0,0 +1,177 @@
+// Package zookeeper provides a zgrab2 module that probes ZooKeeper servers
+// with four-letter word commands.
+// Default Port: 2181 (TCP)
+//
+// The scanner sends each of the --commands (srvr, stat and mntr by default)
+// on its own connection, as the server closes the connection after each
+// response. It records which commands the server executed and which it
+// refused (ZooKeeper 3.5.3 and later only execute the commands in their
+// 4lw.commands.whitelist), the raw responses, and the version, mode and
+// connection and node counts parsed from them.
+package zookeeper
+
+import (
+	"context"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmap/zgrab2"
+)
+
+// ScanResults instances are returned by the module's Scan function.
+type ScanResults struct {
+	// Commands lists the outcome of each command, in the order sent.
+	Commands []*CommandResult `json:"commands,omitempty"`
+
+	// Stats holds the fields parsed from the permitted commands' responses.
+	Stats *Stats `json:"stats,omitempty"`
+}
+
+// Flags holds the command-line configuration for the zookeeper scan module.
+// Populated by the framework.
+type Flags struct {
+	zgrab2.BaseFlags
+
+	// Commands is the comma-separated list of four-letter words to send.
+	Commands string `long:"commands" default:"srvr,stat,mntr" description:"Comma-separated list of four-letter word commands to send, each on its own connection"`
+
+	// Verbose indicates that there should be more verbose logging.
+	Verbose bool `long:"verbose" description:"More verbose logging, include debug fields in the scan results"`
+}
+
+// Module implements the zgrab2.Module interface.
+type Module struct {
+}
+
+// Scanner implements the zgrab2.Scanner interface.
+type Scanner struct {
+	config   *Flags
+	commands []string
+}
+
+// RegisterModule registers the zgrab2 module.
+func RegisterModule() {
+	var module Module
+	_, err := zgrab2.AddCommand("zookeeper", "zookeeper", module.Description(), 2181, &module)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewFlags returns a default Flags object.
+func (module *Module) NewFlags() interface{} {
+	return new(Flags)
+}
+
+// NewScanner returns a new Scanner instance.
+func (module *Module) NewScanner() zgrab2.Scanner {
+	return new(Scanner)
+}
+
+// Description returns an overview of this module.
+func (module *Module) Description() string {
+	return "Send ZooKeeper four-letter word commands (srvr, stat, mntr) and record the server's version, mode and counts"
+}
+
+// parseCommands splits a comma-separated list of four-letter words.
+func parseCommands(commands string) []string {
+	var ret []string
+	for _, command := range strings.Split(commands, ",") {
+		if command = strings.TrimSpace(command); command != "" {
+			ret = append(ret, command)
+		}
+	}
+	return ret
+}
+
+// Validate checks that the flags are valid.
+// On success, returns nil.
+// On failure, returns an error instance describing the error.
+func (flags *Flags) Validate(args []string) error {
+	commands := parseCommands(flags.Commands)
+	if len(commands) == 0 {
+		log.Errorf("--commands must list at least one command")
+		return zgrab2.ErrInvalidArguments
+	}
+	for _, command := range commands {
+		if len(command) != 4 {
+			log.Errorf("invalid command %q in --commands: must be four letters", command)
+			return zgrab2.ErrInvalidArguments
+		}
+	}
+	return nil
+}
+
+// Help returns the module's help string.
+func (flags *Flags) Help() string {
+	return ""
+}
+
+// Init initializes the Scanner.
+func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
+	f, _ := flags.(*Flags)
+	scanner.config = f
+	scanner.commands = parseCommands(f.Commands)
+	return nil
+}
+
+// InitPerSender initializes the scanner for a given sender.
+func (scanner *Scanner) InitPerSender(senderID int) error {
+	return nil
+}
+
+// GetName returns the Scanner name defined in the Flags.
+func (scanner *Scanner) GetName() string {
+	return scanner.config.Name
+}
+
+// GetTrigger returns the Trigger defined in the Flags.
+func (scanner *Scanner) GetTrigger() string {
+	return scanner.config.Trigger
+}
+
+// Protocol returns the protocol identifier of the scan.
+func (scanner *Scanner) Protocol() string {
+	return "zookeeper"
+}
+
+// Scan performs the zookeeper scan.
+//  1. For each command, open a TCP connection to the target port (default
+//     2181), send the command and read the response until the server closes
+//     the connection. If a connection fails, stop.
+//  2. Record whether each command was executed or refused, and parse the
+//     fields of the executed ones.
+//  3. If the server answered none of the commands, fail with a protocol
+//     error.
+func (scanner *Scanner) Scan(ctx context.Context, target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	result := new(ScanResults)
+	answered := false
+	var scanErr error
+	for _, command := range scanner.commands {
+		conn, err := target.Open(&scanner.config.BaseFlags)
+		if err != nil {
+			scanErr = err
+			break
+		}
+		commandResult, err := SendCommand(conn, command)
+		conn.Close()
+		result.Commands = append(result.Commands, commandResult)
+		if commandResult.Response != "" {
+			answered = true
+		} else if err != nil {
+			scanErr = err
+			break
+		}
+	}
+	if !answered {
+		if scanErr != nil {
+			return zgrab2.TryGetScanStatus(scanErr), nil, scanErr
+		}
+		return zgrab2.SCAN_PROTOCOL_ERROR, nil, ErrNoResponse
+	}
+	result.Stats = ParseStats(result.Commands)
+	if scanErr != nil {
+		return zgrab2.TryGetScanStatus(scanErr), result, scanErr
+	}
+	return zgrab2.SCAN_SUCCESS, result, nil
+}
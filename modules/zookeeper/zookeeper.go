@@ -0,0 +1,200 @@
+// ZooKeeper four-letter word commands for the zookeeper module.
+
+package zookeeper
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// maxResponseSize bounds the size of a command's response.
+const maxResponseSize = 64 * 1024
+
+// notInWhitelist is part of the response of servers that refuse a command
+// missing from their 4lw.commands.whitelist (ZooKeeper 3.5.3 and later).
+const notInWhitelist = "is not executed because it is not in the whitelist"
+
+// ErrNoResponse is returned when the server answered none of the commands.
+var ErrNoResponse = errors.New("no response to any four-letter word command")
+
+// CommandResult is the outcome of a single four-letter word command.
+type CommandResult struct {
+	// Command is the four-letter word sent, e.g. "srvr".
+	Command string `json:"command"`
+
+	// Permitted is true if the server executed the command; it is false if
+	// the server refused it as missing from its whitelist, or closed the
+	// connection without answering.
+	Permitted bool `json:"permitted"`
+
+	// Response is the raw response.
+	Response string `json:"response,omitempty"`
+
+	// Truncated is true if the response was longer than the limit, so only
+	// its start is in Response.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// SendCommand sends a four-letter word command on conn, and reads the
+// response until the server closes the connection.
+func SendCommand(conn net.Conn, command string) (*CommandResult, error) {
+	result := &CommandResult{Command: command}
+	if _, err := conn.Write([]byte(command)); err != nil {
+		return result, err
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(conn, maxResponseSize+1))
+	if len(data) > maxResponseSize {
+		data = data[:maxResponseSize]
+		result.Truncated = true
+	}
+	result.Response = string(data)
+	result.Permitted = len(data) > 0 && !strings.Contains(result.Response, notInWhitelist)
+	return result, err
+}
+
+// Stats holds the fields parsed from the srvr, stat and mntr responses.
+type Stats struct {
+	// Version is the server version, e.g. "3.4.14-4c25d48..., built on
+	// 03/06/2019 16:18 GMT".
+	Version string `json:"version,omitempty"`
+
+	// Mode is the server's role: "leader", "follower", "observer" or
+	// "standalone".
+	Mode string `json:"mode,omitempty"`
+
+	// Connections is the number of client connections.
+	Connections *int64 `json:"connections,omitempty"`
+
+	// Outstanding is the number of queued requests.
+	Outstanding *int64 `json:"outstanding,omitempty"`
+
+	// NodeCount is the number of znodes.
+	NodeCount *int64 `json:"node_count,omitempty"`
+
+	// Zxid is the last transaction ID, e.g. "0x100000002".
+	Zxid string `json:"zxid,omitempty"`
+
+	// Clients lists the client connections in the stat response, e.g.
+	// "/10.0.0.1:53124[1](queued=0,recved=10,sent=10)".
+	Clients []string `json:"clients,omitempty"`
+
+	// Monitor holds the key-value pairs of the mntr response, e.g.
+	// zk_followers or zk_watch_count.
+	Monitor map[string]string `json:"monitor,omitempty"`
+}
+
+// parseInt parses value as a decimal integer, returning nil if it is not
+// one.
+func parseInt(value string) *int64 {
+	ret, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &ret
+}
+
+// setIfUnset sets *field to value if it is not already set.
+func setIfUnset(field **int64, value *int64) {
+	if *field == nil {
+		*field = value
+	}
+}
+
+// parseServerStats parses the "Key: value" lines of a srvr or stat response
+// into stats, along with the client list of a stat response.
+func parseServerStats(stats *Stats, response string) {
+	scanner := bufio.NewScanner(strings.NewReader(response))
+	inClients := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inClients {
+			if client := strings.TrimSpace(line); client != "" {
+				stats.Clients = append(stats.Clients, client)
+				continue
+			}
+			inClients = false
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		key, value := line[:i], strings.TrimSpace(line[i+1:])
+		switch key {
+		case "Zookeeper version":
+			if stats.Version == "" {
+				stats.Version = value
+			}
+		case "Mode":
+			if stats.Mode == "" {
+				stats.Mode = value
+			}
+		case "Connections":
+			setIfUnset(&stats.Connections, parseInt(value))
+		case "Outstanding":
+			setIfUnset(&stats.Outstanding, parseInt(value))
+		case "Node count":
+			setIfUnset(&stats.NodeCount, parseInt(value))
+		case "Zxid":
+			if stats.Zxid == "" {
+				stats.Zxid = value
+			}
+		case "Clients":
+			inClients = true
+		}
+	}
+}
+
+// parseMonitor parses the tab-separated key-value lines of a mntr response
+// into stats.
+func parseMonitor(stats *Stats, response string) {
+	for _, line := range strings.Split(response, "\n") {
+		fields := strings.SplitN(strings.TrimRight(line, "\r"), "\t", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		key, value := fields[0], fields[1]
+		if stats.Monitor == nil {
+			stats.Monitor = make(map[string]string)
+		}
+		stats.Monitor[key] = value
+		switch key {
+		case "zk_version":
+			if stats.Version == "" {
+				stats.Version = value
+			}
+		case "zk_server_state":
+			if stats.Mode == "" {
+				stats.Mode = value
+			}
+		case "zk_num_alive_connections":
+			setIfUnset(&stats.Connections, parseInt(value))
+		case "zk_outstanding_requests":
+			setIfUnset(&stats.Outstanding, parseInt(value))
+		case "zk_znode_count":
+			setIfUnset(&stats.NodeCount, parseInt(value))
+		}
+	}
+}
+
+// ParseStats parses the responses of the permitted srvr, stat and mntr
+// commands in results.
+func ParseStats(results []*CommandResult) *Stats {
+	stats := new(Stats)
+	for _, result := range results {
+		if !result.Permitted {
+			continue
+		}
+		switch result.Command {
+		case "srvr", "stat":
+			parseServerStats(stats, result.Response)
+		case "mntr":
+			parseMonitor(stats, result.Response)
+		}
+	}
+	return stats
+}
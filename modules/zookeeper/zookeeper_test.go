@@ -0,0 +1,85 @@
+package zookeeper
+
+import (
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+const (
+	srvrResponse = "Zookeeper version: 3.5.9-83df9301aa5c2a5d284a9940177808c01bc35cef, built on 01/06/2021 20:03 GMT\n" +
+		"Latency min/avg/max: 0/0.5/3\nReceived: 20\nSent: 19\nConnections: 2\nOutstanding: 0\n" +
+		"Zxid: 0x100000002\nMode: follower\nNode count: 5\n"
+
+	statResponse = "stat is not executed because it is not in the whitelist.\n"
+
+	mntrResponse = "zk_version\t3.5.9-83df9301aa5c2a5d284a9940177808c01bc35cef, built on 01/06/2021 20:03 GMT\n" +
+		"zk_server_state\tfollower\nzk_num_alive_connections\t2\nzk_znode_count\t5\nzk_watch_count\t1\n"
+)
+
+// serveZooKeeper accepts connections on listener, answering each command
+// with the given response and closing the connection.
+func serveZooKeeper(t *testing.T, listener net.Listener, responses map[string]string) {
+	for {
+		c, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(c net.Conn) {
+			defer c.Close()
+			command := make([]byte, 4)
+			if _, err := io.ReadFull(c, command); err != nil {
+				t.Errorf("reading command: %v", err)
+				return
+			}
+			c.Write([]byte(responses[string(command)]))
+		}(c)
+	}
+}
+
+func TestParseStats(t *testing.T) {
+	stat := "Zookeeper version: 3.4.14\nClients:\n /10.0.0.1:53124[1](queued=0,recved=10,sent=10)\n /10.0.0.2:41000[0](queued=0,recved=1,sent=0)\n\n" +
+		"Latency min/avg/max: 0/0/0\nConnections: 2\nMode: standalone\nNode count: 4\n"
+	stats := ParseStats([]*CommandResult{{Command: "stat", Permitted: true, Response: stat}})
+	if stats.Version != "3.4.14" || stats.Mode != "standalone" || *stats.Connections != 2 || *stats.NodeCount != 4 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	expected := []string{"/10.0.0.1:53124[1](queued=0,recved=10,sent=10)", "/10.0.0.2:41000[0](queued=0,recved=1,sent=0)"}
+	if !reflect.DeepEqual(stats.Clients, expected) {
+		t.Errorf("expected clients %v, got %v", expected, stats.Clients)
+	}
+}
+
+func TestScan(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveZooKeeper(t, listener, map[string]string{"srvr": srvrResponse, "stat": statResponse, "mntr": mntrResponse})
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	scanner := &Scanner{config: &Flags{}, commands: []string{"srvr", "stat", "mntr", "ruok"}}
+	scanner.config.Timeout = 5 * time.Second
+
+	status, res, err := scanner.Scan(context.Background(), zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port})
+	if status != zgrab2.SCAN_SUCCESS || err != nil {
+		t.Fatalf("unexpected status %s: %v", status, err)
+	}
+	result := res.(*ScanResults)
+	var permitted []bool
+	for _, command := range result.Commands {
+		permitted = append(permitted, command.Permitted)
+	}
+	if !reflect.DeepEqual(permitted, []bool{true, false, true, false}) || result.Commands[1].Response != statResponse {
+		t.Errorf("unexpected commands %+v", result.Commands)
+	}
+	stats := result.Stats
+	if stats.Mode != "follower" || *stats.Connections != 2 || *stats.NodeCount != 5 || stats.Zxid != "0x100000002" || stats.Monitor["zk_watch_count"] != "1" {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
@@ -1,6 +1,10 @@
 package zgrab2
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 // Monitor is a collection of states per scans and a channel to communicate
 // those scans to the monitor
@@ -9,6 +13,33 @@ type Monitor struct {
 	statusesChan chan moduleStatus
 	// Callback is invoked after each scan.
 	Callback func(string)
+
+	// targetsScanned, targetsSkipped and targetsDuplicate count the input
+	// targets that were sent to the workers, dropped by --sample-rate and
+	// dropped by --dedup; targetsThrottled counts those whose scan waited
+	// for --max-per-host. maxTargets is set to 1 if the input was cut short
+	// by --max-targets.
+	targetsScanned   uint64
+	targetsSkipped   uint64
+	targetsDuplicate uint64
+	targetsThrottled uint64
+	maxTargets       uint32
+}
+
+// TargetCounts holds the number of input targets that were scanned,
+// skipped, and skipped as duplicates.
+type TargetCounts struct {
+	Scanned    uint64 `json:"scanned"`
+	Skipped    uint64 `json:"skipped,omitempty"`
+	Duplicates uint64 `json:"duplicates,omitempty"`
+
+	// Throttled is the number of targets whose scan was delayed by
+	// --max-per-host, because as many scans of the same host were running.
+	Throttled uint64 `json:"throttled,omitempty"`
+
+	// MaxTargetsReached is true if the scan stopped reading the input after
+	// --max-targets targets.
+	MaxTargetsReached bool `json:"max_targets_reached,omitempty"`
 }
 
 // State contains the respective number of successes and failures
@@ -19,8 +50,9 @@ type State struct {
 }
 
 type moduleStatus struct {
-	name string
-	st   status
+	name     string
+	st       status
+	duration time.Duration
 }
 
 type status uint
@@ -36,6 +68,45 @@ func (m *Monitor) GetStatuses() map[string]*State {
 	return m.states
 }
 
+// GetTargetCounts returns the number of input targets that were scanned,
+// skipped, and skipped as duplicates so far.
+func (m *Monitor) GetTargetCounts() *TargetCounts {
+	return &TargetCounts{
+		Scanned:           atomic.LoadUint64(&m.targetsScanned),
+		Skipped:           atomic.LoadUint64(&m.targetsSkipped),
+		Duplicates:        atomic.LoadUint64(&m.targetsDuplicate),
+		Throttled:         atomic.LoadUint64(&m.targetsThrottled),
+		MaxTargetsReached: atomic.LoadUint32(&m.maxTargets) != 0,
+	}
+}
+
+func (m *Monitor) targetScanned() {
+	atomic.AddUint64(&m.targetsScanned, 1)
+}
+
+func (m *Monitor) targetSkipped() {
+	atomic.AddUint64(&m.targetsSkipped, 1)
+}
+
+func (m *Monitor) targetDuplicate() {
+	atomic.AddUint64(&m.targetsDuplicate, 1)
+}
+
+func (m *Monitor) targetThrottled() {
+	atomic.AddUint64(&m.targetsThrottled, 1)
+}
+
+func (m *Monitor) maxTargetsReached() {
+	atomic.StoreUint32(&m.maxTargets, 1)
+}
+
+// scanStarted records the start of a scan by the named scanner in the
+// attempted / in-flight metrics.
+func (m *Monitor) scanStarted(name string) {
+	scansAttempted.WithLabelValues(name).Inc()
+	scansInFlight.WithLabelValues(name).Inc()
+}
+
 // Stop indicates the monitor is done and the internal channel should be closed.
 // This function does not block, but will allow a call to Wait() on the
 // WaitGroup passed to MakeMonitor to return.
@@ -59,11 +130,18 @@ func MakeMonitor(statusChanSize int, wg *sync.WaitGroup) *Monitor {
 			if m.Callback != nil {
 				m.Callback(s.name)
 			}
+			scansInFlight.WithLabelValues(s.name).Dec()
+			scanDuration.WithLabelValues(s.name).Observe(s.duration.Seconds())
+			if statsd != nil {
+				statsd.observe(s)
+			}
 			switch s.st {
 			case statusSuccess:
 				m.states[s.name].Successes++
+				scansSucceeded.WithLabelValues(s.name).Inc()
 			case statusFailure:
 				m.states[s.name].Failures++
+				scansFailed.WithLabelValues(s.name).Inc()
 			default:
 				continue
 			}
@@ -0,0 +1,85 @@
+package zgrab2
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// orderedResult holds the results of a target (one per connection, with
+// --connections-per-host) for --ordered-output.
+type orderedResult struct {
+	// seq is the target's position in the scan, starting from 0.
+	seq uint64
+
+	results [][]byte
+}
+
+// reorderResults sends the results from in to out in the order of their
+// seq, starting from 0, until in is closed.
+// At most window targets' results are held back waiting for an earlier
+// target; when one more arrives, the earliest of them is sent out of order
+// (with a warning), and the target being waited for is sent whenever it
+// arrives.
+func reorderResults(in <-chan orderedResult, out chan<- []byte, window int) {
+	var next uint64
+	pending := make(map[uint64][][]byte)
+	// sent holds the targets after next that were sent out of order.
+	sent := make(map[uint64]bool)
+	send := func(results [][]byte) {
+		for _, result := range results {
+			out <- result
+		}
+	}
+	// advance sends the pending results from next on, until a target that
+	// has not arrived yet.
+	advance := func() {
+		for {
+			if sent[next] {
+				delete(sent, next)
+			} else if results, ok := pending[next]; ok {
+				send(results)
+				delete(pending, next)
+			} else {
+				return
+			}
+			next++
+		}
+	}
+	warned := false
+	for result := range in {
+		pending[result.seq] = result.results
+		waiting := next
+		advance()
+		if next != waiting {
+			warned = false
+		}
+		if len(pending) <= window {
+			continue
+		}
+		if !warned {
+			log.Warnf("--ordered-output: target %d is still being scanned after %d later targets; writing results out of order", next, window)
+			warned = true
+		}
+		earliest := earliestSeq(pending)
+		send(pending[earliest])
+		delete(pending, earliest)
+		sent[earliest] = true
+	}
+	// Send what is left in order, skipping any targets that never arrived.
+	for len(pending) > 0 {
+		next = earliestSeq(pending)
+		advance()
+	}
+}
+
+// earliestSeq returns the lowest seq in pending, which must not be empty.
+func earliestSeq(pending map[uint64][][]byte) uint64 {
+	first := true
+	var earliest uint64
+	for seq := range pending {
+		if first || seq < earliest {
+			earliest = seq
+			first = false
+		}
+	}
+	return earliest
+}
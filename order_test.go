@@ -0,0 +1,55 @@
+package zgrab2
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// reorder runs reorderResults on results for the given seqs (the result of
+// each seq being the seq itself), and returns the order of the output.
+func reorder(seqs []uint64, window int) []string {
+	in := make(chan orderedResult, len(seqs))
+	out := make(chan []byte, len(seqs))
+	for _, seq := range seqs {
+		in <- orderedResult{seq: seq, results: [][]byte{[]byte(strconv.FormatUint(seq, 10))}}
+	}
+	close(in)
+	reorderResults(in, out, window)
+	close(out)
+	var ret []string
+	for result := range out {
+		ret = append(ret, string(result))
+	}
+	return ret
+}
+
+func TestReorderResults(t *testing.T) {
+	ordered := []string{"0", "1", "2", "3", "4", "5"}
+	if got := reorder([]uint64{3, 1, 0, 2, 5, 4}, 10); !reflect.DeepEqual(got, ordered) {
+		t.Errorf("expected %v, got %v", ordered, got)
+	}
+
+	// Target 0 is slow; once more than 2 later targets are held back, they
+	// are written out of order, and target 0 is written when it arrives.
+	expected := []string{"1", "2", "0", "3", "4", "5"}
+	if got := reorder([]uint64{1, 2, 3, 4, 0, 5}, 2); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	// All the results of a target are written together.
+	in := make(chan orderedResult, 2)
+	out := make(chan []byte, 3)
+	in <- orderedResult{seq: 1, results: [][]byte{[]byte("1a"), []byte("1b")}}
+	in <- orderedResult{seq: 0, results: [][]byte{[]byte("0")}}
+	close(in)
+	reorderResults(in, out, 10)
+	close(out)
+	var got []string
+	for result := range out {
+		got = append(got, string(result))
+	}
+	if expected := []string{"0", "1a", "1b"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
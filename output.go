@@ -134,6 +134,58 @@ func WidenMapKeys(input map[int]string) map[uint64]string {
 // results or error.
 type OutputResultsFunc func(results <-chan []byte) error
 
+// OutputSink is a destination for serialized scan results. Write is called
+// once for each result, and Close once there are no more results.
+type OutputSink interface {
+	Write(result []byte) error
+	Close() error
+}
+
+// OutputResultsSinkFunc returns an OutputResultsFunc that writes each result
+// to sink, and closes the sink once the results channel is closed.
+func OutputResultsSinkFunc(sink OutputSink) OutputResultsFunc {
+	return func(results <-chan []byte) error {
+		for result := range results {
+			if err := sink.Write(result); err != nil {
+				sink.Close()
+				return err
+			}
+		}
+		return sink.Close()
+	}
+}
+
+// WriterOutputSink is an OutputSink that writes newline-delimited results to
+// an io.Writer through a buffered writer. It is the default sink, used for
+// --output-file.
+type WriterOutputSink struct {
+	buf *bufio.Writer
+}
+
+// NewWriterOutputSink returns a WriterOutputSink that writes to w.
+func NewWriterOutputSink(w io.Writer) *WriterOutputSink {
+	return &WriterOutputSink{buf: bufio.NewWriter(w)}
+}
+
+// Write writes the result followed by a newline, flushing if --flush is set.
+func (sink *WriterOutputSink) Write(result []byte) error {
+	if _, err := sink.buf.Write(result); err != nil {
+		return err
+	}
+	if err := sink.buf.WriteByte('\n'); err != nil {
+		return err
+	}
+	if config.Flush {
+		return sink.buf.Flush()
+	}
+	return nil
+}
+
+// Close flushes any buffered output. The underlying io.Writer is not closed.
+func (sink *WriterOutputSink) Close() error {
+	return sink.buf.Flush()
+}
+
 // OutputResultsWriterFunc returns an OutputResultsFunc that wraps an io.Writer
 // in a buffered writer, and uses OutputResults.
 func OutputResultsWriterFunc(w io.Writer) OutputResultsFunc {
@@ -146,16 +198,11 @@ func OutputResultsWriterFunc(w io.Writer) OutputResultsFunc {
 
 // OutputResults writes results to a buffered Writer from a channel.
 func OutputResults(w *bufio.Writer, results <-chan []byte) error {
+	sink := &WriterOutputSink{buf: w}
 	for result := range results {
-		if _, err := w.Write(result); err != nil {
+		if err := sink.Write(result); err != nil {
 			return err
 		}
-		if err := w.WriteByte('\n'); err != nil {
-			return err
-		}
-		if config.Flush {
-			w.Flush()
-		}
 	}
 	return nil
 }
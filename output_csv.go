@@ -0,0 +1,130 @@
+package zgrab2
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// CSVOutputSink is an OutputSink that flattens each JSON result into a CSV
+// row containing only the configured fields, and writes the rows to another
+// OutputSink. A header row naming the fields is written when the sink is
+// created, so the output has a header even if there are no results.
+//
+// Each field is a dotted path into the result (e.g.
+// "data.http.result.response.status_code"); path components that are
+// integers index into lists. Fields missing from a result give empty cells,
+// and fields that are objects or lists are written as JSON.
+type CSVOutputSink struct {
+	inner  OutputSink
+	fields []string
+	paths  [][]string
+}
+
+// NewCSVOutputSink returns a CSVOutputSink that writes the given fields to
+// inner, after writing the header row.
+func NewCSVOutputSink(inner OutputSink, fields []string) (*CSVOutputSink, error) {
+	paths := make([][]string, len(fields))
+	for i, field := range fields {
+		paths[i] = strings.Split(field, ".")
+	}
+	ret := &CSVOutputSink{inner: inner, fields: fields, paths: paths}
+	if err := ret.writeRow(fields); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ParseCSVFields splits a comma-separated list of dotted field paths.
+func ParseCSVFields(fields string) []string {
+	var ret []string
+	for _, field := range strings.Split(fields, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			ret = append(ret, field)
+		}
+	}
+	return ret
+}
+
+// lookupPath returns the value at the given path in a decoded JSON value, and
+// false if it is not present.
+func lookupPath(value interface{}, path []string) (interface{}, bool) {
+	for _, key := range path {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// formatCSVCell returns the cell text for a decoded JSON value.
+func formatCSVCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+}
+
+// writeRow writes a single CSV row to the inner sink.
+func (sink *CSVOutputSink) writeRow(cells []string) error {
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	if err := w.Write(cells); err != nil {
+		return err
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	// The inner sink is responsible for the line terminator.
+	return sink.inner.Write(bytes.TrimRight(buf.Bytes(), "\n"))
+}
+
+// Write flattens the JSON result into a row.
+func (sink *CSVOutputSink) Write(result []byte) error {
+	var decoded interface{}
+	decoder := json.NewDecoder(bytes.NewReader(result))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return err
+	}
+	cells := make([]string, len(sink.paths))
+	for i, path := range sink.paths {
+		if value, ok := lookupPath(decoded, path); ok {
+			cells[i] = formatCSVCell(value)
+		}
+	}
+	return sink.writeRow(cells)
+}
+
+// Close closes the inner sink.
+func (sink *CSVOutputSink) Close() error {
+	return sink.inner.Close()
+}
@@ -0,0 +1,268 @@
+package zgrab2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxElasticsearchErrors is the number of distinct indexing errors kept in
+// ElasticsearchStats.
+const maxElasticsearchErrors = 10
+
+// elasticsearchRetryBackoff is the delay before the first retry of a failed
+// bulk request; it doubles with each retry.
+var elasticsearchRetryBackoff = time.Second
+
+// ElasticsearchStats counts the results indexed by an
+// ElasticsearchOutputSink. It is included in the summary, so that indexing
+// errors are reported without aborting the scan.
+type ElasticsearchStats struct {
+	Indexed uint64 `json:"indexed"`
+	Failed  uint64 `json:"failed,omitempty"`
+	Retried uint64 `json:"retried,omitempty"`
+
+	// Errors are the first few distinct errors that made results fail.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// ElasticsearchOutputSink is an OutputSink that indexes each result, as is,
+// as a document in an Elasticsearch index, using the _bulk API. The target's
+// ip (and port, if the input gives one) are top-level fields of the result.
+//
+// Results are sent in batches of the configured size, or once the flush
+// interval has passed (or one at a time, if --flush is set). Write blocks
+// while a batch is being sent, so a slow cluster slows the scan down rather
+// than queueing results without bound. Requests that fail, and documents the
+// cluster rejects with 429 (Too Many Requests), are retried with exponential
+// backoff; results that still cannot be indexed are counted as failed in the
+// sink's ElasticsearchStats, and do not stop the scan.
+type ElasticsearchOutputSink struct {
+	client    *http.Client
+	bulkURL   string
+	action    []byte
+	batchSize int
+	retries   int
+
+	mu      sync.Mutex
+	pending [][]byte
+	stats   ElasticsearchStats
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// ParseElasticsearchDestination parses a destination of the form
+// "http://host:9200/index" into the URL of the _bulk API and the index.
+func ParseElasticsearchDestination(destination string) (bulkURL string, index string, err error) {
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid elasticsearch destination %s: %v", destination, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", "", fmt.Errorf("elasticsearch destination %s must be of the form http://host:port/index", destination)
+	}
+	path := strings.TrimRight(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	index = path[i+1:]
+	if index == "" {
+		return "", "", fmt.Errorf("elasticsearch destination %s must include an index", destination)
+	}
+	u.Path = path[:i] + "/_bulk"
+	return u.String(), index, nil
+}
+
+// NewElasticsearchOutputSink returns an ElasticsearchOutputSink that indexes
+// into index through the _bulk API at bulkURL, sending batches of batchSize
+// results at least every flushInterval, and retrying failed results up to
+// retries times.
+func NewElasticsearchOutputSink(bulkURL string, index string, batchSize int, flushInterval time.Duration, retries int) *ElasticsearchOutputSink {
+	action, _ := json.Marshal(map[string]interface{}{"index": map[string]string{"_index": index}})
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	sink := &ElasticsearchOutputSink{
+		client:    &http.Client{Timeout: time.Minute},
+		bulkURL:   bulkURL,
+		action:    action,
+		batchSize: batchSize,
+		retries:   retries,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go sink.flushPeriodically(flushInterval)
+	return sink
+}
+
+// flushPeriodically sends the queued results every interval, until the sink
+// is closed.
+func (sink *ElasticsearchOutputSink) flushPeriodically(interval time.Duration) {
+	defer close(sink.done)
+	if interval <= 0 {
+		<-sink.stop
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			sink.mu.Lock()
+			sink.flush()
+			sink.mu.Unlock()
+		case <-sink.stop:
+			return
+		}
+	}
+}
+
+// Write queues the result, sending the queued results once a full batch is
+// available. Indexing errors are recorded in the sink's stats rather than
+// returned.
+func (sink *ElasticsearchOutputSink) Write(result []byte) error {
+	// The result buffer is not guaranteed to outlive the call, so copy it.
+	doc := make([]byte, len(result))
+	copy(doc, result)
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	sink.pending = append(sink.pending, doc)
+	if len(sink.pending) >= sink.batchSize || config.Flush {
+		sink.flush()
+	}
+	return nil
+}
+
+// flush sends the queued results, retrying those that fail. The caller must
+// hold sink.mu.
+func (sink *ElasticsearchOutputSink) flush() {
+	docs := sink.pending
+	sink.pending = nil
+	backoff := elasticsearchRetryBackoff
+	for attempt := 0; len(docs) > 0; attempt++ {
+		if attempt > 0 {
+			sink.stats.Retried += uint64(len(docs))
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		retry, err := sink.send(docs)
+		if err != nil {
+			retry = docs
+		}
+		if len(retry) > 0 && attempt == sink.retries {
+			if err == nil {
+				err = fmt.Errorf("documents rejected after %d retries", sink.retries)
+			}
+			sink.failed(uint64(len(retry)), err)
+			return
+		}
+		docs = retry
+	}
+}
+
+// send sends one bulk request for docs, and returns the docs that should be
+// retried, or an error if the whole request should be retried.
+func (sink *ElasticsearchOutputSink) send(docs [][]byte) ([][]byte, error) {
+	var body bytes.Buffer
+	for _, doc := range docs {
+		body.Write(sink.action)
+		body.WriteByte('\n')
+		body.Write(doc)
+		body.WriteByte('\n')
+	}
+	resp, err := sink.client.Post(sink.bulkURL, "application/x-ndjson", &body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		err := fmt.Errorf("elasticsearch bulk request failed: %s", resp.Status)
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			return nil, err
+		}
+		// Retrying will not help, e.g. for a bad index name.
+		sink.failed(uint64(len(docs)), err)
+		return nil, nil
+	}
+	var bulk struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  *struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&bulk); err != nil {
+		return nil, fmt.Errorf("invalid elasticsearch bulk response: %v", err)
+	}
+	if !bulk.Errors {
+		sink.stats.Indexed += uint64(len(docs))
+		return nil, nil
+	}
+	if len(bulk.Items) != len(docs) {
+		return nil, fmt.Errorf("elasticsearch bulk response has %d items for %d documents", len(bulk.Items), len(docs))
+	}
+	var retry [][]byte
+	for i, item := range bulk.Items {
+		for _, result := range item {
+			switch {
+			case result.Status < 300:
+				sink.stats.Indexed++
+			case result.Status == http.StatusTooManyRequests:
+				retry = append(retry, docs[i])
+			case result.Error != nil:
+				sink.failed(1, fmt.Errorf("%s: %s", result.Error.Type, result.Error.Reason))
+			default:
+				sink.failed(1, fmt.Errorf("elasticsearch returned status %d", result.Status))
+			}
+		}
+	}
+	return retry, nil
+}
+
+// failed counts n results as failed with err, and logs err the first time
+// it is seen.
+func (sink *ElasticsearchOutputSink) failed(n uint64, err error) {
+	sink.stats.Failed += n
+	msg := err.Error()
+	for _, seen := range sink.stats.Errors {
+		if seen == msg {
+			return
+		}
+	}
+	log.Warnf("could not index %d results in elasticsearch: %s", n, msg)
+	if len(sink.stats.Errors) < maxElasticsearchErrors {
+		sink.stats.Errors = append(sink.stats.Errors, msg)
+	}
+}
+
+// Stats returns the sink's counts so far.
+func (sink *ElasticsearchOutputSink) Stats() *ElasticsearchStats {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	stats := sink.stats
+	stats.Errors = append([]string(nil), sink.stats.Errors...)
+	return &stats
+}
+
+// Close sends any queued results. Indexing errors are recorded in the sink's
+// stats rather than returned.
+func (sink *ElasticsearchOutputSink) Close() error {
+	close(sink.stop)
+	<-sink.done
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	sink.flush()
+	return nil
+}
@@ -0,0 +1,156 @@
+package zgrab2
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeBulkServer is an Elasticsearch _bulk endpoint that records the
+// documents it receives, and replies to each with the status returned by
+// status (or 201).
+type fakeBulkServer struct {
+	mu       sync.Mutex
+	requests int
+	docs     []string
+	status   func(doc string, attempt int) int
+	attempts map[string]int
+}
+
+func (server *fakeBulkServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.requests++
+	var items []string
+	errors := false
+	scanner := bufio.NewScanner(req.Body)
+	for scanner.Scan() {
+		if action := scanner.Text(); action != `{"index":{"_index":"zgrab2"}}` {
+			http.Error(w, "bad action "+action, http.StatusBadRequest)
+			return
+		}
+		scanner.Scan()
+		doc := scanner.Text()
+		status := 201
+		if server.status != nil {
+			status = server.status(doc, server.attempts[doc])
+		}
+		server.attempts[doc]++
+		if status == 201 {
+			server.docs = append(server.docs, doc)
+			items = append(items, `{"index":{"status":201}}`)
+		} else {
+			errors = true
+			items = append(items, fmt.Sprintf(`{"index":{"status":%d,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}}`, status))
+		}
+	}
+	fmt.Fprintf(w, `{"took":1,"errors":%v,"items":[%s]}`, errors, strings.Join(items, ","))
+}
+
+func newFakeBulkServer(status func(doc string, attempt int) int) (*fakeBulkServer, *httptest.Server) {
+	fake := &fakeBulkServer{status: status, attempts: make(map[string]int)}
+	server := httptest.NewServer(fake)
+	return fake, server
+}
+
+func TestElasticsearchOutputSink(t *testing.T) {
+	saved := elasticsearchRetryBackoff
+	defer func() { elasticsearchRetryBackoff = saved }()
+	elasticsearchRetryBackoff = time.Millisecond
+
+	fake, server := newFakeBulkServer(func(doc string, attempt int) int {
+		switch {
+		case strings.Contains(doc, "1.1.1.2") && attempt == 0:
+			// Rejected once, then indexed on the retry.
+			return 429
+		case strings.Contains(doc, "1.1.1.3"):
+			return 400
+		}
+		return 201
+	})
+	defer server.Close()
+
+	sink := NewElasticsearchOutputSink(server.URL+"/_bulk", "zgrab2", 2, time.Hour, 3)
+	results := make(chan []byte, 5)
+	for i := 1; i <= 5; i++ {
+		results <- []byte(fmt.Sprintf(`{"ip":"1.1.1.%d","port":443,"data":{}}`, i))
+	}
+	close(results)
+	if err := OutputResultsSinkFunc(sink)(results); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	// Two full batches, the retry and the final partial batch.
+	if fake.requests != 4 {
+		t.Errorf("expected 4 bulk requests, got %d", fake.requests)
+	}
+	for _, doc := range fake.docs {
+		var grab Grab
+		if err := json.Unmarshal([]byte(doc), &grab); err != nil || grab.Port != 443 {
+			t.Errorf("unexpected document %s", doc)
+		}
+	}
+	expected := &ElasticsearchStats{
+		Indexed: 4,
+		Failed:  1,
+		Retried: 1,
+		Errors:  []string{"mapper_parsing_exception: failed to parse"},
+	}
+	if stats := sink.Stats(); !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected stats %+v, got %+v", expected, stats)
+	}
+}
+
+func TestElasticsearchOutputSinkGivesUp(t *testing.T) {
+	saved := elasticsearchRetryBackoff
+	defer func() { elasticsearchRetryBackoff = saved }()
+	elasticsearchRetryBackoff = time.Millisecond
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	sink := NewElasticsearchOutputSink(server.URL+"/_bulk", "zgrab2", 10, time.Hour, 2)
+	sink.Write([]byte(`{"ip":"1.1.1.1"}`))
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if requests := atomic.LoadInt32(&requests); requests != 3 {
+		t.Errorf("expected 3 bulk requests, got %d", requests)
+	}
+	if stats := sink.Stats(); stats.Indexed != 0 || stats.Failed != 1 || len(stats.Errors) != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestElasticsearchOutputSinkFlushInterval(t *testing.T) {
+	fake, server := newFakeBulkServer(nil)
+	defer server.Close()
+
+	sink := NewElasticsearchOutputSink(server.URL+"/_bulk", "zgrab2", 100, 10*time.Millisecond, 0)
+	defer sink.Close()
+	sink.Write([]byte(`{"ip":"1.1.1.1"}`))
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if sink.Stats().Indexed == 1 {
+			break
+		}
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.docs) != 1 {
+		t.Errorf("expected the queued result to be sent, got %v", fake.docs)
+	}
+}
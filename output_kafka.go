@@ -0,0 +1,91 @@
+package zgrab2
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaBatchSize is the number of results KafkaOutputSink buffers before
+// producing them to the broker.
+const kafkaBatchSize = 100
+
+// KafkaOutputSink is an OutputSink that produces each result as a message to
+// a Kafka topic. Results are produced in batches of kafkaBatchSize (or one at
+// a time, if --flush is set).
+type KafkaOutputSink struct {
+	writer  *kafka.Writer
+	pending []kafka.Message
+}
+
+// ParseKafkaDestination parses a destination of the form
+// "broker1:port,broker2:port/topic" into its list of brokers and the topic.
+func ParseKafkaDestination(destination string) (brokers []string, topic string, err error) {
+	i := strings.LastIndex(destination, "/")
+	if i < 0 {
+		return nil, "", fmt.Errorf("kafka destination %s must be of the form brokers/topic", destination)
+	}
+	topic = destination[i+1:]
+	for _, broker := range strings.Split(destination[:i], ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			brokers = append(brokers, broker)
+		}
+	}
+	if len(brokers) == 0 || topic == "" {
+		return nil, "", fmt.Errorf("kafka destination %s must include at least one broker and a topic", destination)
+	}
+	return brokers, topic, nil
+}
+
+// NewKafkaOutputSink returns a KafkaOutputSink that produces to the given
+// topic on the given brokers.
+func NewKafkaOutputSink(brokers []string, topic string) *KafkaOutputSink {
+	return &KafkaOutputSink{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.LeastBytes{},
+			BatchSize:    kafkaBatchSize,
+			BatchTimeout: 10 * time.Millisecond,
+		},
+	}
+}
+
+// Write queues the result, producing the queued results once a full batch is
+// available.
+func (sink *KafkaOutputSink) Write(result []byte) error {
+	// The result buffer is not guaranteed to outlive the call, so copy it.
+	value := make([]byte, len(result))
+	copy(value, result)
+	sink.pending = append(sink.pending, kafka.Message{Value: value})
+	if len(sink.pending) >= kafkaBatchSize || config.Flush {
+		return sink.flush()
+	}
+	return nil
+}
+
+func (sink *KafkaOutputSink) flush() error {
+	if len(sink.pending) == 0 {
+		return nil
+	}
+	err := sink.writer.WriteMessages(context.Background(), sink.pending...)
+	sink.pending = sink.pending[:0]
+	if err != nil {
+		return fmt.Errorf("error producing results to kafka: %v", err)
+	}
+	return nil
+}
+
+// Close produces any queued results and closes the connection to the
+// brokers.
+func (sink *KafkaOutputSink) Close() error {
+	flushErr := sink.flush()
+	closeErr := sink.writer.Close()
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
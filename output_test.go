@@ -1,7 +1,9 @@
 package zgrab2
 
 import (
+	"bytes"
 	"fmt"
+	"time"
 )
 
 func ExampleMapFlagsToSet_success() {
@@ -75,3 +77,117 @@ func ExampleListFlagsToSet() {
 	// bit0: true
 	// Unknown: 0x4
 }
+
+func ExampleOutputResultsSinkFunc() {
+	buf := new(bytes.Buffer)
+	results := make(chan []byte, 2)
+	results <- []byte(`{"ip":"1.2.3.4"}`)
+	results <- []byte(`{"ip":"5.6.7.8"}`)
+	close(results)
+	if err := OutputResultsSinkFunc(NewWriterOutputSink(buf))(results); err != nil {
+		fmt.Println(err)
+	}
+	fmt.Print(buf.String())
+	// Output:
+	// {"ip":"1.2.3.4"}
+	// {"ip":"5.6.7.8"}
+}
+
+func ExampleParseKafkaDestination() {
+	for _, destination := range []string{"localhost:9092/zgrab2", "k1:9092, k2:9092/results", "localhost:9092", "/topic", "localhost:9092/"} {
+		brokers, topic, err := ParseKafkaDestination(destination)
+		if err != nil {
+			fmt.Println("error")
+			continue
+		}
+		fmt.Println(brokers, topic)
+	}
+	// Output:
+	// [localhost:9092] zgrab2
+	// [k1:9092 k2:9092] results
+	// error
+	// error
+	// error
+}
+
+func ExampleParseElasticsearchDestination() {
+	for _, destination := range []string{"http://localhost:9200/zgrab2", "https://es.example.com/proxy/results/", "http://localhost:9200", "localhost:9200/zgrab2", "ftp://localhost/zgrab2"} {
+		bulkURL, index, err := ParseElasticsearchDestination(destination)
+		if err != nil {
+			fmt.Println("error")
+			continue
+		}
+		fmt.Println(bulkURL, index)
+	}
+	// Output:
+	// http://localhost:9200/_bulk zgrab2
+	// https://es.example.com/proxy/_bulk results
+	// error
+	// error
+	// error
+}
+
+func ExampleSyslogOutputSink_formatMessage() {
+	sink := &SyslogOutputSink{
+		facility:       16,
+		hostname:       "scanner",
+		appName:        "zgrab2",
+		procID:         "42",
+		maxMessageSize: 120,
+	}
+	now := time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC)
+	fmt.Println(string(sink.formatMessage([]byte(`{"ip":"1.2.3.4"}`), now)))
+	fmt.Println(string(sink.formatMessage([]byte(`{"ip":"1.2.3.4","data":{"banner":{"banner":"a long banner"}}}`), now)))
+	// Output:
+	// <134>1 2021-04-01T12:00:00.000000Z scanner zgrab2 42 result - {"ip":"1.2.3.4"}
+	// <134>1 2021-04-01T12:00:00.000000Z scanner zgrab2 42 result [zgrab2@32473 truncated="true" length="61"] {"ip":"1.2.3.4",
+}
+
+func ExampleParseSyslogFacility() {
+	for _, facility := range []string{"local0", "daemon", "7", "24", "bogus"} {
+		code, err := ParseSyslogFacility(facility)
+		fmt.Println(code, err)
+	}
+	// Output:
+	// 16 <nil>
+	// 3 <nil>
+	// 7 <nil>
+	// 0 invalid syslog facility 24
+	// 0 invalid syslog facility bogus
+}
+
+func ExampleCSVOutputSink() {
+	buf := new(bytes.Buffer)
+	sink, err := NewCSVOutputSink(NewWriterOutputSink(buf), ParseCSVFields("ip, data.http.status, data.http.result.response.status_code,data.http.result.tags.1,data.http.result.tags,missing"))
+	if err != nil {
+		fmt.Println(err)
+	}
+	results := make(chan []byte, 2)
+	results <- []byte(`{"ip":"1.2.3.4","data":{"http":{"status":"success","result":{"response":{"status_code":200},"tags":["a","b,c"]}}}}`)
+	results <- []byte(`{"ip":"5.6.7.8","data":{"http":{"status":"connection-timeout"}}}`)
+	close(results)
+	if err := OutputResultsSinkFunc(sink)(results); err != nil {
+		fmt.Println(err)
+	}
+	fmt.Print(buf.String())
+	// Output:
+	// ip,data.http.status,data.http.result.response.status_code,data.http.result.tags.1,data.http.result.tags,missing
+	// 1.2.3.4,success,200,"b,c","[""a"",""b,c""]",
+	// 5.6.7.8,connection-timeout,,,,
+}
+
+func ExampleCSVOutputSink_noResults() {
+	buf := new(bytes.Buffer)
+	sink, err := NewCSVOutputSink(NewWriterOutputSink(buf), ParseCSVFields("ip,data.http.status"))
+	if err != nil {
+		fmt.Println(err)
+	}
+	results := make(chan []byte)
+	close(results)
+	if err := OutputResultsSinkFunc(sink)(results); err != nil {
+		fmt.Println(err)
+	}
+	fmt.Print(buf.String())
+	// Output:
+	// ip,data.http.status
+}
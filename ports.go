@@ -0,0 +1,64 @@
+package zgrab2
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParsePorts parses a comma-separated list of ports and port ranges, e.g.
+// "80,443,8000-8010", in the order given, dropping repeated ports.
+func ParsePorts(ports string) ([]uint, error) {
+	var ret []uint
+	seen := make(map[uint64]bool)
+	for _, field := range strings.Split(ports, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		first, last := field, field
+		if i := strings.Index(field, "-"); i >= 0 {
+			first, last = field[:i], field[i+1:]
+		}
+		start, err := strconv.ParseUint(strings.TrimSpace(first), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", field)
+		}
+		end, err := strconv.ParseUint(strings.TrimSpace(last), 10, 16)
+		if err != nil || end < start {
+			return nil, fmt.Errorf("invalid port range %q", field)
+		}
+		for port := start; port <= end; port++ {
+			if !seen[port] {
+				seen[port] = true
+				ret = append(ret, uint(port))
+			}
+		}
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("no ports in %q", ports)
+	}
+	return ret, nil
+}
+
+// expandPorts returns a copy of target for each of ports, or just target if
+// ports is empty or the input gave the target's port. Each copy has its own
+// Tags, so that they can be changed independently.
+func expandPorts(target ScanTarget, ports []uint) []ScanTarget {
+	if len(ports) == 0 || target.Port != nil {
+		return []ScanTarget{target}
+	}
+	ret := make([]ScanTarget, len(ports))
+	for i, port := range ports {
+		port := port
+		ret[i] = target
+		ret[i].Port = &port
+		if target.Tags != nil {
+			ret[i].Tags = make(map[string]string, len(target.Tags))
+			for k, v := range target.Tags {
+				ret[i].Tags[k] = v
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,81 @@
+package zgrab2
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		ports    string
+		expected []uint
+	}{
+		{"80", []uint{80}},
+		{"80,443, 8080,8443", []uint{80, 443, 8080, 8443}},
+		{"8000-8003,80,8001", []uint{8000, 8001, 8002, 8003, 80}},
+		{"0-1,65535", []uint{0, 1, 65535}},
+	}
+	for _, test := range tests {
+		ports, err := ParsePorts(test.ports)
+		if err != nil || !reflect.DeepEqual(ports, test.expected) {
+			t.Errorf("%q: expected %v, got %v: %v", test.ports, test.expected, ports, err)
+		}
+	}
+	for _, invalid := range []string{"", ",", "http", "65536", "-1", "90-80", "80-"} {
+		if ports, err := ParsePorts(invalid); err == nil {
+			t.Errorf("%q: expected an error, got %v", invalid, ports)
+		}
+	}
+}
+
+func TestFeedTargetsPorts(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config.ports = []uint{80, 443, 8080}
+	fixed := uint(22)
+	config.inputTargets = func(ch chan<- ScanTarget) error {
+		ch <- ScanTarget{IP: net.IPv4(192, 0, 2, 1)}
+		ch <- ScanTarget{IP: net.IPv4(192, 0, 2, 2), Port: &fixed}
+		return nil
+	}
+	var wg sync.WaitGroup
+	mon := MakeMonitor(1, &wg)
+	defer wg.Wait()
+	defer mon.Stop()
+	processQueue := make(chan ScanTarget, 10)
+	feedTargets(context.Background(), processQueue, mon)
+	close(processQueue)
+
+	fed := make(map[string][]uint)
+	for target := range processQueue {
+		fed[target.IP.String()] = append(fed[target.IP.String()], *target.Port)
+		if grab := BuildGrabFromInputResponse(&target, nil); grab.Port != *target.Port {
+			t.Errorf("expected the result of %s to record its port", target.String())
+		}
+	}
+	expected := map[string][]uint{"192.0.2.1": {80, 443, 8080}, "192.0.2.2": {22}}
+	if !reflect.DeepEqual(fed, expected) {
+		t.Errorf("expected targets %v, got %v", expected, fed)
+	}
+	if counts := mon.GetTargetCounts(); counts.Scanned != 4 {
+		t.Errorf("expected 4 targets scanned, got %+v", counts)
+	}
+}
+
+func TestExpandPorts(t *testing.T) {
+	target := ScanTarget{IP: net.IPv4(192, 0, 2, 1), Tags: map[string]string{"segment": "a"}}
+	expanded := expandPorts(target, []uint{80, 443})
+	if len(expanded) != 2 || *expanded[0].Port != 80 || *expanded[1].Port != 443 {
+		t.Fatalf("unexpected targets %+v", expanded)
+	}
+	expanded[0].Tags["segment"] = "b"
+	if expanded[1].Tags["segment"] != "a" || target.Tags["segment"] != "a" {
+		t.Errorf("expected each target to have its own tags")
+	}
+	if expanded := expandPorts(target, nil); len(expanded) != 1 || expanded[0].Port != nil {
+		t.Errorf("expected the target unchanged without ports, got %+v", expanded)
+	}
+}
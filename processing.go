@@ -1,10 +1,13 @@
 package zgrab2
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"net"
 	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/zmap/zgrab2/lib/output"
@@ -12,8 +15,13 @@ import (
 
 // Grab contains all scan responses for a single host
 type Grab struct {
+	Meta   *ResultMeta             `json:"_meta,omitempty"`
 	IP     string                  `json:"ip,omitempty"`
 	Domain string                  `json:"domain,omitempty"`
+	Port   uint                    `json:"port,omitempty"`
+	Tags   map[string]string       `json:"tags,omitempty"`
+	Input  string                  `json:"raw_input,omitempty"`
+	GeoIP  *GeoIP                  `json:"geoip,omitempty"`
 	Data   map[string]ScanResponse `json:"data,omitempty"`
 }
 
@@ -23,6 +31,36 @@ type ScanTarget struct {
 	Domain string
 	Tag    string
 	Port   *uint
+
+	// Tags holds arbitrary labels from the input (e.g. the customer or
+	// segment the target belongs to), which are copied unchanged into the
+	// target's Grab.
+	Tags map[string]string
+
+	// RawInput is the input record the target was read from, if
+	// --echo-input is set; it is copied unchanged into the target's Grab.
+	RawInput string
+
+	// handoff holds the connections handed off between the target's
+	// scanners; see HandOff.
+	handoff *handoffPool
+
+	// ctx is the context passed to the current scanner's Scan; the
+	// connections opened for the target end when it is done.
+	ctx context.Context
+
+	// timing is the current scan's Timing; see RecordConnect.
+	timing *Timing
+
+	// remoteAddr is the current scan's ConnectedAddr; see RecordRemoteAddr.
+	remoteAddr *remoteAddr
+
+	// wire records the current scan's traffic, if --capture-wire is set.
+	wire *wireRecorder
+
+	// seq is the target's position among the targets sent to the workers,
+	// used by --ordered-output.
+	seq uint64
 }
 
 func (target ScanTarget) String() string {
@@ -55,7 +93,19 @@ func (target *ScanTarget) Host() string {
 	panic("unreachable")
 }
 
+// Context returns the context of the scan in progress, or the background
+// context outside of a scan.
+func (target *ScanTarget) Context() context.Context {
+	if target.ctx == nil {
+		return context.Background()
+	}
+	return target.ctx
+}
+
 // Open connects to the ScanTarget using the configured flags, and returns a net.Conn that uses the configured timeouts for Read/Write operations.
+// The connection is closed when the scan's context is done. The time taken to connect is recorded in the scan's Timing.
+// With --replay-file, it returns a ReplayConn playing back the file instead.
+// With --capture-wire, the bytes sent and received are recorded in the scan's WireCapture.
 func (target *ScanTarget) Open(flags *BaseFlags) (net.Conn, error) {
 	var port uint
 	// If the port is supplied in ScanTarget, let that override the cmdline option
@@ -65,19 +115,78 @@ func (target *ScanTarget) Open(flags *BaseFlags) (net.Conn, error) {
 		port = flags.Port
 	}
 
+	start := time.Now()
+	if config.ReplayFile != "" {
+		conn := target.openReplay(port)
+		target.RecordConnect(start)
+		return target.captureWire(conn), nil
+	}
 	address := net.JoinHostPort(target.Host(), fmt.Sprintf("%d", port))
-	return DialTimeoutConnection("tcp", address, flags.Timeout, flags.BytesReadLimit)
+	conn, err := DialTimeoutConnectionContext(target.Context(), "tcp", address, flags.Timeout, flags.BytesReadLimit)
+	if err != nil {
+		return nil, err
+	}
+	target.RecordConnect(start)
+	target.RecordRemoteAddr(conn.RemoteAddr())
+	return target.captureWire(conn), nil
+}
+
+// remoteAddr records the remote address of a scan's first connection.
+type remoteAddr struct {
+	mu   sync.Mutex
+	addr string
+}
+
+// get returns the recorded address, or "" if there is none.
+func (r *remoteAddr) get() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.addr
+}
+
+// RecordRemoteAddr records addr, the remote address of a connection to the
+// target, as the current scan's ConnectedAddr. Only the first connection of
+// a scan is recorded. Modules that connect without ScanTarget.Open should
+// call it after connecting.
+func (target *ScanTarget) RecordRemoteAddr(addr net.Addr) {
+	if target.remoteAddr != nil && addr != nil {
+		target.remoteAddr.mu.Lock()
+		defer target.remoteAddr.mu.Unlock()
+		if target.remoteAddr.addr == "" {
+			target.remoteAddr.addr = addr.String()
+		}
+	}
+}
+
+// OpenUnix connects to the Unix domain socket at path in place of the
+// ScanTarget's address, for modules that can scan a local service; the
+// target then only identifies the scan in the output. The connection uses
+// the configured timeouts, as with Open.
+func (target *ScanTarget) OpenUnix(path string, flags *BaseFlags) (net.Conn, error) {
+	start := time.Now()
+	conn, err := DialTimeoutConnectionContext(target.Context(), "unix", path, flags.Timeout, flags.BytesReadLimit)
+	if err != nil {
+		return nil, err
+	}
+	target.RecordConnect(start)
+	return target.captureWire(conn), nil
 }
 
 // OpenTLS connects to the ScanTarget using the configured flags, then performs
-// the TLS handshake. On success error is nil, but the connection can be non-nil
+// the TLS handshake, recording its duration. On success error is nil, but the connection can be non-nil
 // even if there is an error (this allows fetching the handshake log).
 func (target *ScanTarget) OpenTLS(baseFlags *BaseFlags, tlsFlags *TLSFlags) (*TLSConnection, error) {
 	conn, err := tlsFlags.Connect(target, baseFlags)
 	if err != nil {
 		return conn, err
 	}
-	err = conn.Handshake()
+	start := time.Now()
+	if err = conn.Handshake(); err == nil {
+		target.RecordHandshake(start)
+	}
 	return conn, err
 }
 
@@ -92,38 +201,48 @@ func (target *ScanTarget) OpenUDP(flags *BaseFlags, udp *UDPFlags) (net.Conn, er
 		port = flags.Port
 	}
 	address := net.JoinHostPort(target.Host(), fmt.Sprintf("%d", port))
-	var local *net.UDPAddr
+	// Bind to the --interface, if set
+	dialer := net.Dialer{Control: config.dialControl}
 	if udp != nil && (udp.LocalAddress != "" || udp.LocalPort != 0) {
-		local = &net.UDPAddr{}
+		local := &net.UDPAddr{}
 		if udp.LocalAddress != "" && udp.LocalAddress != "*" {
 			local.IP = net.ParseIP(udp.LocalAddress)
 		}
 		if udp.LocalPort != 0 {
 			local.Port = int(udp.LocalPort)
 		}
+		dialer.LocalAddr = local
 	}
 	remote, err := net.ResolveUDPAddr("udp", address)
 	if err != nil {
 		return nil, err
 	}
-	conn, err := net.DialUDP("udp", local, remote)
+	conn, err := dialer.Dial("udp", remote.String())
 	if err != nil {
 		return nil, err
 	}
-	return NewTimeoutConnection(nil, conn, flags.Timeout, 0, 0, flags.BytesReadLimit), nil
+	target.RecordRemoteAddr(conn.RemoteAddr())
+	return NewTimeoutConnection(target.Context(), conn, flags.Timeout, 0, 0, flags.BytesReadLimit), nil
 }
 
 // BuildGrabFromInputResponse constructs a Grab object for a target, given the
 // scan responses.
 func BuildGrabFromInputResponse(t *ScanTarget, responses map[string]ScanResponse) *Grab {
 	var ipstr string
+	var port uint
 
 	if t.IP != nil {
 		ipstr = t.IP.String()
 	}
+	if t.Port != nil {
+		port = *t.Port
+	}
 	return &Grab{
 		IP:     ipstr,
 		Domain: t.Domain,
+		Port:   port,
+		Tags:   t.Tags,
+		Input:  t.RawInput,
 		Data:   responses,
 	}
 }
@@ -148,12 +267,30 @@ func EncodeGrab(raw *Grab, includeDebug bool) ([]byte, error) {
 	return json.Marshal(outputData)
 }
 
-// grabTarget calls handler for each action
-func grabTarget(input ScanTarget, m *Monitor) []byte {
+// serviceResponded returns true if any of the responses has a status other
+// than a connection failure (see IsConnectionFailure), so that the target
+// sent some application-level response.
+func serviceResponded(responses map[string]ScanResponse) bool {
+	for _, response := range responses {
+		if !IsConnectionFailure(response.Status) {
+			return true
+		}
+	}
+	return false
+}
+
+// grabTarget calls handler for each action, stopping early if ctx is done.
+// It returns nil if --success-only is set and no module got past connecting.
+func grabTarget(ctx context.Context, input ScanTarget, m *Monitor) []byte {
 	moduleResult := make(map[string]ScanResponse)
+	input.handoff = newHandoffPool()
+	defer input.handoff.closeAll()
 
-	for _, scannerName := range orderedScanners {
-		scanner := scanners[scannerName]
+	for _, registered := range registeredScanners() {
+		if ctx.Err() != nil {
+			break
+		}
+		scannerName, scanner := registered.name, registered.scanner
 		trigger := (*scanner).GetTrigger()
 		if input.Tag != trigger {
 			continue
@@ -165,7 +302,7 @@ func grabTarget(input ScanTarget, m *Monitor) []byte {
 				panic(e)
 			}
 		}(scannerName)
-		name, res := RunScanner(*scanner, m, input)
+		name, res := RunScanner(ctx, *scanner, m, input)
 		moduleResult[name] = res
 		if res.Error != nil && !config.Multiple.ContinueOnError {
 			break
@@ -175,7 +312,15 @@ func grabTarget(input ScanTarget, m *Monitor) []byte {
 		}
 	}
 
+	if config.SuccessOnly && !serviceResponded(moduleResult) {
+		return nil
+	}
+
 	raw := BuildGrabFromInputResponse(&input, moduleResult)
+	if config.ResultMeta {
+		raw.Meta = newResultMeta(moduleResult)
+	}
+	applyResultProcessors(&input, raw)
 	result, err := EncodeGrab(raw, includeDebugOutput())
 	if err != nil {
 		log.Fatalf("unable to marshal data: %s", err)
@@ -184,8 +329,101 @@ func grabTarget(input ScanTarget, m *Monitor) []byte {
 	return result
 }
 
+// finishResult applies the --signatures to an encoded result and then, as
+// the last change before it is written, shrinks it to --max-record-bytes.
+func finishResult(result []byte, input *ScanTarget) []byte {
+	if len(config.signatures) > 0 {
+		if signed, err := ApplySignatures(result, config.signatures); err != nil {
+			log.Errorf("unable to apply signatures: %v", err)
+		} else {
+			result = signed
+		}
+	}
+	if config.MaxRecordBytes > 0 && len(result) > config.MaxRecordBytes {
+		log.Debugf("truncating the %d-byte result for %s", len(result), input.String())
+		result = truncateRecord(result, config.MaxRecordBytes)
+	}
+	return result
+}
+
+// feedTargets reads the input targets and sends them to processQueue,
+// dropping those already seen if --dedup is set, then all but a random one
+// in --sample-rate of them, expands each into one target per port in --ports,
+// and stops after --max-targets have been sent.
+// The numbers of targets sent and dropped are recorded in mon. Once ctx is
+// done, no more targets are sent.
+func feedTargets(ctx context.Context, processQueue chan<- ScanTarget, mon *Monitor) {
+	inputQueue := make(chan ScanTarget)
+	inputDone := make(chan error, 1)
+	go func() {
+		inputDone <- config.inputTargets(inputQueue)
+		close(inputQueue)
+	}()
+	sampler := rand.New(rand.NewSource(time.Now().UnixNano()))
+	seen := newTargetSet()
+	var sent uint
+	for target := range inputQueue {
+		if seen != nil && !seen.add(dedupKey(&target)) {
+			mon.targetDuplicate()
+			continue
+		}
+		if config.SampleRate > 1 && sampler.Intn(int(config.SampleRate)) != 0 {
+			mon.targetSkipped()
+			continue
+		}
+		for _, target := range expandPorts(target, config.ports) {
+			target.seq = uint64(sent)
+			select {
+			case processQueue <- target:
+			case <-ctx.Done():
+				log.Infof("stopping the scan: %v", ctx.Err())
+				go discardTargets(inputQueue)
+				return
+			}
+			mon.targetScanned()
+			if sent++; config.MaxTargets > 0 && sent >= config.MaxTargets {
+				log.Infof("stopping after --max-targets=%d targets", config.MaxTargets)
+				mon.maxTargetsReached()
+				go discardTargets(inputQueue)
+				return
+			}
+		}
+	}
+	if err := <-inputDone; err != nil {
+		log.Fatal(err)
+	}
+}
+
+// discardTargets receives and drops the rest of the targets from
+// inputQueue once feedTargets has stopped early. An InputTargetsFunc cannot
+// be interrupted, so this lets the input goroutine run to completion rather
+// than block forever on its next send.
+func discardTargets(inputQueue <-chan ScanTarget) {
+	for range inputQueue {
+	}
+}
+
 // Process sets up an output encoder, input reader, and starts grab workers.
 func Process(mon *Monitor) {
+	ProcessContext(context.Background(), mon)
+}
+
+// ProcessContext is Process, except that once ctx is done, no more targets
+// are read, and the scans in progress are cancelled: their connections are
+// closed, and their results (with errors) are output as usual.
+func ProcessContext(ctx context.Context, mon *Monitor) {
+	if config.MetricsAddr != "" {
+		server := startMetricsServer(config.MetricsAddr)
+		defer stopMetricsServer(server)
+	}
+	if config.Statsd != "" {
+		emitter, err := startStatsd(config.Statsd, config.StatsdPrefix)
+		if err != nil {
+			log.Fatalf("could not connect to statsd server %s: %v", config.Statsd, err)
+		}
+		statsd = emitter
+		defer emitter.close()
+	}
 	workers := config.Senders
 	processQueue := make(chan ScanTarget, workers*4)
 	outputQueue := make(chan []byte, workers*4)
@@ -195,6 +433,7 @@ func Process(mon *Monitor) {
 	var outputDone sync.WaitGroup
 	workerDone.Add(int(workers))
 	outputDone.Add(1)
+	limiter := newHostLimiter()
 
 	// Start the output encoder
 	go func() {
@@ -203,28 +442,63 @@ func Process(mon *Monitor) {
 			log.Fatal(err)
 		}
 	}()
+	// With --ordered-output, the workers' results are put back in order
+	// before they are output.
+	var orderedQueue chan orderedResult
+	var reorderDone sync.WaitGroup
+	if config.OrderedOutput {
+		orderedQueue = make(chan orderedResult, workers*4)
+		reorderDone.Add(1)
+		go func() {
+			defer reorderDone.Done()
+			reorderResults(orderedQueue, outputQueue, config.OrderedWindow)
+		}()
+	}
 	//Start all the workers
 	for i := 0; i < workers; i++ {
 		go func(i int) {
-			for _, scannerName := range orderedScanners {
-				scanner := *scanners[scannerName]
+			for _, registered := range registeredScanners() {
+				scanner := *registered.scanner
 				scanner.InitPerSender(i)
 			}
 			for obj := range processQueue {
+				if limiter != nil {
+					if limiter.acquire(obj.Host()) {
+						mon.targetThrottled()
+					}
+				}
+				var results [][]byte
 				for run := uint(0); run < uint(config.ConnectionsPerHost); run++ {
-					result := grabTarget(obj, mon)
-					outputQueue <- result
+					result := grabTarget(ctx, obj, mon)
+					if result == nil {
+						// Left out by --success-only.
+						continue
+					}
+					result = finishResult(result, &obj)
+					if orderedQueue != nil {
+						results = append(results, result)
+					} else {
+						outputQueue <- result
+					}
+				}
+				if orderedQueue != nil {
+					orderedQueue <- orderedResult{seq: obj.seq, results: results}
+				}
+				if limiter != nil {
+					limiter.release(obj.Host())
 				}
 			}
 			workerDone.Done()
 		}(i)
 	}
 
-	if err := config.inputTargets(processQueue); err != nil {
-		log.Fatal(err)
-	}
+	feedTargets(ctx, processQueue, mon)
 	close(processQueue)
 	workerDone.Wait()
+	if orderedQueue != nil {
+		close(orderedQueue)
+		reorderDone.Wait()
+	}
 	close(outputQueue)
 	outputDone.Wait()
 }
@@ -0,0 +1,163 @@
+package zgrab2
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// feedTestTargets runs feedTargets over n input targets with the given
+// limits, returning the number of targets fed and the monitor's counts.
+func feedTestTargets(n int, maxTargets uint, sampleRate uint) (int, *TargetCounts) {
+	saved := config
+	defer func() { config = saved }()
+	config.MaxTargets = maxTargets
+	config.SampleRate = sampleRate
+	config.inputTargets = func(ch chan<- ScanTarget) error {
+		for i := 0; i < n; i++ {
+			ch <- ScanTarget{IP: net.IPv4(192, 0, 2, byte(i))}
+		}
+		return nil
+	}
+	var wg sync.WaitGroup
+	mon := MakeMonitor(1, &wg)
+	defer wg.Wait()
+	defer mon.Stop()
+	processQueue := make(chan ScanTarget, n)
+	feedTargets(context.Background(), processQueue, mon)
+	close(processQueue)
+	fed := 0
+	for range processQueue {
+		fed++
+	}
+	return fed, mon.GetTargetCounts()
+}
+
+func TestFeedTargets(t *testing.T) {
+	fed, counts := feedTestTargets(100, 0, 0)
+	if fed != 100 || counts.Scanned != 100 || counts.Skipped != 0 || counts.MaxTargetsReached {
+		t.Errorf("without limits: fed %d, counts %+v", fed, counts)
+	}
+
+	fed, counts = feedTestTargets(100, 10, 0)
+	if fed != 10 || counts.Scanned != 10 || !counts.MaxTargetsReached {
+		t.Errorf("--max-targets=10: fed %d, counts %+v", fed, counts)
+	}
+
+	fed, counts = feedTestTargets(1000, 0, 10)
+	if fed != int(counts.Scanned) || counts.Scanned+counts.Skipped != 1000 || fed < 50 || fed > 200 {
+		t.Errorf("--sample-rate=10: fed %d, counts %+v", fed, counts)
+	}
+}
+
+func TestFeedTargetsMaxTargetsFinishesInput(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config.MaxTargets = 5
+	finished := make(chan struct{})
+	config.inputTargets = func(ch chan<- ScanTarget) error {
+		defer close(finished)
+		for i := 0; i < 100; i++ {
+			ch <- ScanTarget{IP: net.IPv4(192, 0, 2, byte(i))}
+		}
+		return nil
+	}
+	var wg sync.WaitGroup
+	mon := MakeMonitor(1, &wg)
+	defer wg.Wait()
+	defer mon.Stop()
+	feedTargets(context.Background(), make(chan ScanTarget, 5), mon)
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Error("the input goroutine is still blocked after --max-targets")
+	}
+}
+
+func TestFeedTargetsCancelled(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config.inputTargets = func(ch chan<- ScanTarget) error {
+		for i := 0; i < 10; i++ {
+			ch <- ScanTarget{IP: net.IPv4(192, 0, 2, byte(i))}
+		}
+		return nil
+	}
+	var wg sync.WaitGroup
+	mon := MakeMonitor(1, &wg)
+	defer wg.Wait()
+	defer mon.Stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// An unbuffered queue with no workers, so only the cancellation can
+	// stop feedTargets.
+	feedTargets(ctx, make(chan ScanTarget), mon)
+	if counts := mon.GetTargetCounts(); counts.Scanned != 0 {
+		t.Errorf("counts %+v after cancellation", counts)
+	}
+}
+
+func TestOpenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zgrab2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "redis.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write([]byte("+PONG\r\n"))
+		conn.Close()
+	}()
+
+	// The target's address is not used.
+	target := ScanTarget{IP: net.ParseIP("192.0.2.1"), timing: new(Timing)}
+	conn, err := target.OpenUnix(path, &BaseFlags{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	buf, err := ioutil.ReadAll(conn)
+	if err != nil || string(buf) != "+PONG\r\n" {
+		t.Errorf("unexpected response %q (%v)", buf, err)
+	}
+	if target.timing.Connect <= 0 {
+		t.Errorf("connect time not recorded: %+v", target.timing)
+	}
+}
+
+func TestServiceResponded(t *testing.T) {
+	tests := []struct {
+		statuses []ScanStatus
+		expected bool
+	}{
+		{[]ScanStatus{SCAN_CONNECTION_REFUSED}, false},
+		{[]ScanStatus{SCAN_CONNECTION_TIMEOUT, SCAN_IO_TIMEOUT, SCAN_CONNECTION_CLOSED}, false},
+		{[]ScanStatus{SCAN_CONNECTION_TIMEOUT, SCAN_APPLICATION_ERROR}, true},
+		{[]ScanStatus{SCAN_PROTOCOL_ERROR}, true},
+		{[]ScanStatus{SCAN_SUCCESS}, true},
+		{nil, false},
+	}
+	for _, test := range tests {
+		responses := make(map[string]ScanResponse)
+		for i, status := range test.statuses {
+			responses[string(rune('a'+i))] = ScanResponse{Status: status}
+		}
+		if got := serviceResponded(responses); got != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.statuses, test.expected, got)
+		}
+	}
+}
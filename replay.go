@@ -0,0 +1,114 @@
+package zgrab2
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+)
+
+// replayAddr is the net.Addr of a ReplayConn.
+type replayAddr string
+
+// Network returns "replay".
+func (addr replayAddr) Network() string {
+	return "replay"
+}
+
+// String returns the name of the replayed stream.
+func (addr replayAddr) String() string {
+	return string(addr)
+}
+
+// ReplayConn is a net.Conn that plays back a recorded server-side byte stream,
+// for developing and testing modules without a live target. Reads return the
+// recorded bytes and then io.EOF, as if the server had closed the connection;
+// the bytes that the client writes go to a sink, which may record them.
+type ReplayConn struct {
+	server io.Reader
+	client io.Writer
+	remote net.Addr
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewReplayConn returns a ReplayConn that reads the server's bytes from server
+// and writes the client's bytes to client. If client is nil, they are
+// discarded.
+func NewReplayConn(server io.Reader, client io.Writer) *ReplayConn {
+	if client == nil {
+		client = ioutil.Discard
+	}
+	return &ReplayConn{
+		server: server,
+		client: client,
+		remote: replayAddr("replay"),
+	}
+}
+
+// isClosed returns true if the connection has been closed.
+func (conn *ReplayConn) isClosed() bool {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return conn.closed
+}
+
+// Read reads the next recorded server bytes.
+func (conn *ReplayConn) Read(b []byte) (int, error) {
+	if conn.isClosed() {
+		return 0, io.ErrClosedPipe
+	}
+	return conn.server.Read(b)
+}
+
+// Write passes the client's bytes to the sink.
+func (conn *ReplayConn) Write(b []byte) (int, error) {
+	if conn.isClosed() {
+		return 0, io.ErrClosedPipe
+	}
+	return conn.client.Write(b)
+}
+
+// Close closes the connection; later reads and writes fail.
+func (conn *ReplayConn) Close() error {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	conn.closed = true
+	return nil
+}
+
+// LocalAddr returns a placeholder address.
+func (conn *ReplayConn) LocalAddr() net.Addr {
+	return replayAddr("local")
+}
+
+// RemoteAddr returns the address of the target being replayed, if known.
+func (conn *ReplayConn) RemoteAddr() net.Addr {
+	return conn.remote
+}
+
+// SetDeadline does nothing: reads and writes never block.
+func (conn *ReplayConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+// SetReadDeadline does nothing: reads never block.
+func (conn *ReplayConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+// SetWriteDeadline does nothing: writes never block.
+func (conn *ReplayConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// openReplay returns a ReplayConn playing back the --replay-file to the
+// target, discarding the client's bytes.
+func (target *ScanTarget) openReplay(port uint) net.Conn {
+	conn := NewReplayConn(bytes.NewReader(config.replayData), nil)
+	conn.remote = &net.TCPAddr{IP: target.IP, Port: int(port)}
+	return conn
+}
@@ -0,0 +1,63 @@
+package zgrab2
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestReplayConn(t *testing.T) {
+	client := new(bytes.Buffer)
+	conn := NewReplayConn(strings.NewReader("+PONG\r\n"), client)
+	if _, err := conn.Write([]byte("PING\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if client.String() != "PING\r\n" {
+		t.Errorf("expected the client's bytes to be recorded, got %q", client.String())
+	}
+	response, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(response) != "+PONG\r\n" {
+		t.Errorf("expected the server's bytes, got %q", response)
+	}
+	if n, err := conn.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Errorf("expected EOF after the recording, got %d, %v", n, err)
+	}
+	conn.Close()
+	if _, err := conn.Write([]byte("QUIT\r\n")); err != io.ErrClosedPipe {
+		t.Errorf("expected a write after Close to fail, got %v", err)
+	}
+}
+
+func TestOpenReplay(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config.ReplayFile = "server.bin"
+	config.replayData = []byte("SSH-2.0-OpenSSH_7.4\r\n")
+
+	port := uint(22)
+	target := ScanTarget{IP: net.ParseIP("192.0.2.1"), Port: &port}
+	for i := 0; i < 2; i++ {
+		// Each connection replays the recording from the start.
+		conn, err := target.Open(&BaseFlags{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Write([]byte("SSH-2.0-zgrab2\r\n")); err != nil {
+			t.Fatal(err)
+		}
+		banner, err := ioutil.ReadAll(conn)
+		if err != nil || string(banner) != "SSH-2.0-OpenSSH_7.4\r\n" {
+			t.Errorf("unexpected replay %q, %v", banner, err)
+		}
+		if addr := conn.RemoteAddr().String(); addr != "192.0.2.1:22" {
+			t.Errorf("expected the target's address, got %s", addr)
+		}
+		conn.Close()
+	}
+}
@@ -0,0 +1,51 @@
+package zgrab2
+
+import (
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ResultProcessor is a hook that enriches each target's Grab before it is
+// encoded and written, e.g. adding GeoIP or ASN data to its Tags, or an extra
+// entry to its Data. If it returns an error, the error is logged and the Grab
+// is passed on to the remaining processors as-is.
+//
+// Processors run on the worker goroutines, so a processor is called
+// concurrently for different targets and must be safe for concurrent use.
+type ResultProcessor func(grab *Grab) error
+
+// resultProcessors holds the registered processors, in the order they were
+// registered, guarded by resultProcessorsMutex.
+var (
+	resultProcessorsMutex sync.RWMutex
+	resultProcessors      []ResultProcessor
+)
+
+// RegisterResultProcessor adds processor to the processors run on each Grab.
+// Processors run in the order they were registered, each seeing the changes
+// made by the ones before it. It should be called before Process, typically
+// from an init function.
+func RegisterResultProcessor(processor ResultProcessor) {
+	resultProcessorsMutex.Lock()
+	defer resultProcessorsMutex.Unlock()
+	resultProcessors = append(resultProcessors, processor)
+}
+
+// registeredResultProcessors returns the processors registered so far, in
+// the order they were registered.
+func registeredResultProcessors() []ResultProcessor {
+	resultProcessorsMutex.RLock()
+	defer resultProcessorsMutex.RUnlock()
+	return append([]ResultProcessor(nil), resultProcessors...)
+}
+
+// applyResultProcessors runs the registered processors, in order, on the
+// grab of target.
+func applyResultProcessors(target *ScanTarget, grab *Grab) {
+	for i, processor := range registeredResultProcessors() {
+		if err := processor(grab); err != nil {
+			log.Errorf("result processor %d failed on %s: %v", i, target.String(), err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package zgrab2
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestApplyResultProcessors(t *testing.T) {
+	resultProcessorsMutex.Lock()
+	saved := resultProcessors
+	resultProcessors = nil
+	resultProcessorsMutex.Unlock()
+	defer func() {
+		resultProcessorsMutex.Lock()
+		resultProcessors = saved
+		resultProcessorsMutex.Unlock()
+	}()
+
+	var order []string
+	RegisterResultProcessor(func(grab *Grab) error {
+		order = append(order, "asn")
+		if grab.Tags == nil {
+			grab.Tags = make(map[string]string)
+		}
+		grab.Tags["asn"] = "AS64496"
+		return nil
+	})
+	RegisterResultProcessor(func(grab *Grab) error {
+		order = append(order, "failing")
+		return errors.New("lookup failed")
+	})
+	RegisterResultProcessor(func(grab *Grab) error {
+		order = append(order, "org")
+		grab.Tags["org"] = "example-" + grab.Tags["asn"]
+		return nil
+	})
+
+	target := &ScanTarget{IP: net.ParseIP("192.0.2.1")}
+	grab := BuildGrabFromInputResponse(target, nil)
+	applyResultProcessors(target, grab)
+	if !reflect.DeepEqual(order, []string{"asn", "failing", "org"}) {
+		t.Errorf("processors ran in the wrong order: %v", order)
+	}
+	expected := map[string]string{"asn": "AS64496", "org": "example-AS64496"}
+	if !reflect.DeepEqual(grab.Tags, expected) {
+		t.Errorf("expected tags %v, got %v", expected, grab.Tags)
+	}
+}
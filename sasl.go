@@ -0,0 +1,41 @@
+package zgrab2
+
+import "strings"
+
+// maxSASLMechanismLength is the longest mechanism name allowed by RFC 4422.
+const maxSASLMechanismLength = 20
+
+// SASLMechanisms normalizes a list of SASL mechanism names (e.g. PLAIN,
+// LOGIN, CRAM-MD5, XOAUTH2), as advertised by a server's capabilities, so
+// that every module reports them the same way: names are upper-cased,
+// anything that is not a valid mechanism name is dropped, and duplicates are
+// removed, keeping the server's order. Returns nil if no names are left.
+func SASLMechanisms(names []string) []string {
+	var ret []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if !isSASLMechanism(name) || seen[name] {
+			continue
+		}
+		seen[name] = true
+		ret = append(ret, name)
+	}
+	return ret
+}
+
+// isSASLMechanism checks that name is a valid, upper-cased SASL mechanism
+// name: 1 to 20 of the characters A-Z, 0-9, '-' and '_'.
+func isSASLMechanism(name string) bool {
+	if len(name) == 0 || len(name) > maxSASLMechanismLength {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package zgrab2
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSASLMechanisms(t *testing.T) {
+	tests := map[string]struct {
+		names    []string
+		expected []string
+	}{
+		// Postfix/Dovecot: 250-AUTH PLAIN LOGIN
+		"postfix": {
+			names:    strings.Fields("PLAIN LOGIN"),
+			expected: []string{"PLAIN", "LOGIN"},
+		},
+		// Microsoft Exchange: 250-AUTH GSSAPI NTLM LOGIN, repeated in the
+		// obsolete 250-AUTH=LOGIN form.
+		"exchange": {
+			names:    strings.Fields("GSSAPI NTLM LOGIN LOGIN"),
+			expected: []string{"GSSAPI", "NTLM", "LOGIN"},
+		},
+		// Gmail: 250-AUTH LOGIN PLAIN XOAUTH2 PLAIN-CLIENTTOKEN OAUTHBEARER XOAUTH
+		"gmail": {
+			names:    strings.Fields("LOGIN PLAIN XOAUTH2 PLAIN-CLIENTTOKEN OAUTHBEARER XOAUTH"),
+			expected: []string{"LOGIN", "PLAIN", "XOAUTH2", "PLAIN-CLIENTTOKEN", "OAUTHBEARER", "XOAUTH"},
+		},
+		// Lower-cased names, as sent by some embedded servers.
+		"lower case": {
+			names:    []string{"cram-md5", "Digest-MD5", "plain"},
+			expected: []string{"CRAM-MD5", "DIGEST-MD5", "PLAIN"},
+		},
+		"invalid names": {
+			names:    []string{"", "SCRAM-SHA-256-PLUS", "PLAIN]", "THIS-NAME-IS-FAR-TOO-LONG", "EXTERNAL"},
+			expected: []string{"SCRAM-SHA-256-PLUS", "EXTERNAL"},
+		},
+		"empty": {
+			names:    nil,
+			expected: nil,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := SASLMechanisms(test.names); !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
@@ -1,16 +1,33 @@
 package zgrab2
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 )
 
-var scanners map[string]*Scanner
-var orderedScanners []string
+// scanners and orderedScanners hold the registered scanners, by name and in
+// the order they were registered. They are initialized with the package, so
+// that RegisterScan works from other packages' init functions, and are
+// guarded by scannersMutex, so that it works from any goroutine.
+var (
+	scannersMutex   sync.RWMutex
+	scanners        = make(map[string]*Scanner)
+	orderedScanners []string
+)
+
+// namedScanner is a registered scanner with the name it was registered under.
+type namedScanner struct {
+	name    string
+	scanner *Scanner
+}
 
 // RegisterScan registers each individual scanner to be ran by the framework
 func RegisterScan(name string, s Scanner) {
+	scannersMutex.Lock()
+	defer scannersMutex.Unlock()
 	//add to list and map
 	if scanners[name] != nil {
 		log.Fatalf("name: %s already used", name)
@@ -19,30 +36,73 @@ func RegisterScan(name string, s Scanner) {
 	scanners[name] = &s
 }
 
+// registeredScanners returns the scanners registered so far, in the order
+// they were registered.
+func registeredScanners() []namedScanner {
+	scannersMutex.RLock()
+	defer scannersMutex.RUnlock()
+	ret := make([]namedScanner, len(orderedScanners))
+	for i, name := range orderedScanners {
+		ret[i] = namedScanner{name: name, scanner: scanners[name]}
+	}
+	return ret
+}
+
 // PrintScanners prints all registered scanners
 func PrintScanners() {
-	for k, v := range scanners {
-		fmt.Println(k, v)
+	for _, s := range registeredScanners() {
+		fmt.Println(s.name, s.scanner)
 	}
 }
 
-// RunScanner runs a single scan on a target and returns the resulting data
-func RunScanner(s Scanner, mon *Monitor, target ScanTarget) (string, ScanResponse) {
+// RunScanner runs a single scan on a target and returns the resulting data,
+// including its Timing.
+// The connections the scanner opens with target are closed when ctx is done.
+// If the connection is refused and --fallback-ports is set, the scan is
+// retried on each fallback port in turn, and the result is that of the first
+// one that did not refuse the connection, with its Port.
+func RunScanner(ctx context.Context, s Scanner, mon *Monitor, target ScanTarget) (string, ScanResponse) {
 	t := time.Now()
-	status, res, e := s.Scan(target)
-	var err *string
-	if e == nil {
-		mon.statusesChan <- moduleStatus{name: s.GetName(), st: statusSuccess}
-		err = nil
+	mon.scanStarted(s.GetName())
+	resp := runScan(ctx, s, target)
+	if resp.Status == SCAN_CONNECTION_REFUSED {
+		for _, port := range config.fallbackPorts {
+			if ctx.Err() != nil {
+				break
+			}
+			port := port
+			fallbackTarget := target
+			fallbackTarget.Port = &port
+			if fallback := runScan(ctx, s, fallbackTarget); fallback.Status != SCAN_CONNECTION_REFUSED {
+				fallback.Port = port
+				resp = fallback
+				break
+			}
+		}
+	}
+	duration := time.Since(t)
+	resp.Timing.Total = microseconds(duration)
+	if resp.Error == nil {
+		mon.statusesChan <- moduleStatus{name: s.GetName(), st: statusSuccess, duration: duration}
 	} else {
-		mon.statusesChan <- moduleStatus{name: s.GetName(), st: statusFailure}
-		errString := e.Error()
-		err = &errString
+		mon.statusesChan <- moduleStatus{name: s.GetName(), st: statusFailure, duration: duration}
 	}
-	resp := ScanResponse{Result: res, Protocol: s.Protocol(), Error: err, Timestamp: t.Format(time.RFC3339), Status: status}
 	return s.GetName(), resp
 }
 
-func init() {
-	scanners = make(map[string]*Scanner)
+// runScan runs s on target once, and returns the resulting data.
+func runScan(ctx context.Context, s Scanner, target ScanTarget) ScanResponse {
+	t := time.Now()
+	timing := new(Timing)
+	target.ctx = ctx
+	target.timing = timing
+	target.remoteAddr = new(remoteAddr)
+	target.wire = newWireRecorder(config.CaptureWireSize)
+	status, res, e := s.Scan(ctx, target)
+	var err *string
+	if e != nil {
+		errString := e.Error()
+		err = &errString
+	}
+	return ScanResponse{Result: res, Protocol: s.Protocol(), Error: err, Timestamp: t.Format(time.RFC3339), Status: status, Timing: timing, Wire: target.wire.capture(), ConnectedAddr: target.remoteAddr.get()}
 }
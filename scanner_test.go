@@ -0,0 +1,51 @@
+package zgrab2
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestRegisterScanConcurrent registers scanners from several goroutines
+// while others read them; run with -race.
+func TestRegisterScanConcurrent(t *testing.T) {
+	scannersMutex.Lock()
+	savedScanners, savedOrder := scanners, orderedScanners
+	scanners, orderedScanners = make(map[string]*Scanner), nil
+	scannersMutex.Unlock()
+	defer func() {
+		scannersMutex.Lock()
+		scanners, orderedScanners = savedScanners, savedOrder
+		scannersMutex.Unlock()
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			RegisterScan(fmt.Sprintf("scanner%d", i), &timingTestScanner{})
+		}(i)
+		go func() {
+			defer wg.Done()
+			for _, s := range registeredScanners() {
+				if s.scanner == nil {
+					t.Errorf("scanner %s registered without a Scanner", s.name)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	registered := registeredScanners()
+	if len(registered) != 50 {
+		t.Fatalf("expected 50 scanners, got %d", len(registered))
+	}
+	seen := make(map[string]bool)
+	for _, s := range registered {
+		if seen[s.name] || scanners[s.name] != s.scanner {
+			t.Errorf("unexpected scanner %s", s.name)
+		}
+		seen[s.name] = true
+	}
+}
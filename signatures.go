@@ -0,0 +1,106 @@
+package zgrab2
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// Signature is a named regular expression used to classify scan results.
+type Signature struct {
+	Name  string `json:"name"`
+	Regex string `json:"regex"`
+
+	regexp *regexp.Regexp
+}
+
+// LoadSignatures reads a JSON array of {"name": ..., "regex": ...} rules from
+// source and compiles them. It returns the signatures that compiled, along
+// with an error for each rule that is unnamed or failed to compile.
+func LoadSignatures(source io.Reader) ([]*Signature, []error) {
+	var signatures []*Signature
+	if err := json.NewDecoder(source).Decode(&signatures); err != nil {
+		return nil, []error{err}
+	}
+	var ret []*Signature
+	var errs []error
+	for i, signature := range signatures {
+		if signature.Name == "" {
+			errs = append(errs, fmt.Errorf("signature %d has no name", i))
+			continue
+		}
+		var err error
+		if signature.regexp, err = regexp.Compile(signature.Regex); err != nil {
+			errs = append(errs, fmt.Errorf("signature %s: %v", signature.Name, err))
+			continue
+		}
+		ret = append(ret, signature)
+	}
+	return ret, errs
+}
+
+// collectStrings appends every string in the decoded JSON value v to ret.
+// Strings that are valid base64 (i.e. encoded []byte fields) are appended in
+// decoded form as well.
+func collectStrings(v interface{}, ret [][]byte) [][]byte {
+	switch value := v.(type) {
+	case string:
+		ret = append(ret, []byte(value))
+		if len(value) >= 4 && len(value)%4 == 0 {
+			if decoded, err := base64.StdEncoding.DecodeString(value); err == nil {
+				ret = append(ret, decoded)
+			}
+		}
+	case map[string]interface{}:
+		for _, child := range value {
+			ret = collectStrings(child, ret)
+		}
+	case []interface{}:
+		for _, child := range value {
+			ret = collectStrings(child, ret)
+		}
+	}
+	return ret
+}
+
+// ApplySignatures matches signatures against the text and byte fields of
+// each module's result in the serialized Grab, and records the names of the
+// signatures that matched in that module's "signatures" list. The result is
+// returned unchanged if no signature matched.
+func ApplySignatures(result []byte, signatures []*Signature) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(result))
+	decoder.UseNumber()
+	var grab map[string]interface{}
+	if err := decoder.Decode(&grab); err != nil {
+		return result, err
+	}
+	data, _ := grab["data"].(map[string]interface{})
+	matched := false
+	for _, v := range data {
+		response, ok := v.(map[string]interface{})
+		if !ok || response["result"] == nil {
+			continue
+		}
+		values := collectStrings(response["result"], nil)
+		var names []string
+		for _, signature := range signatures {
+			for _, value := range values {
+				if signature.regexp.Match(value) {
+					names = append(names, signature.Name)
+					break
+				}
+			}
+		}
+		if len(names) > 0 {
+			response["signatures"] = names
+			matched = true
+		}
+	}
+	if !matched {
+		return result, nil
+	}
+	return json.Marshal(grab)
+}
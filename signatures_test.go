@@ -0,0 +1,72 @@
+package zgrab2
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadSignatures(t *testing.T) {
+	signatures, errs := LoadSignatures(strings.NewReader(`[
+		{"name": "openssh", "regex": "^SSH-2\\.0-OpenSSH"},
+		{"name": "broken", "regex": "(unclosed"},
+		{"regex": "unnamed"}
+	]`))
+	if len(signatures) != 1 || signatures[0].Name != "openssh" {
+		t.Errorf("expected only openssh to load, got %v", signatures)
+	}
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), "broken") {
+		t.Errorf("expected two errors, got %v", errs)
+	}
+	if _, errs := LoadSignatures(strings.NewReader(`{"name": "x"}`)); len(errs) != 1 {
+		t.Errorf("expected a decode error, got %v", errs)
+	}
+}
+
+func TestApplySignatures(t *testing.T) {
+	signatures, errs := LoadSignatures(strings.NewReader(`[
+		{"name": "openssh", "regex": "^SSH-2\\.0-OpenSSH"},
+		{"name": "redis", "regex": "^-ERR unknown command"},
+		{"name": "binary", "regex": "^\\x00\\x01\\x02"}
+	]`))
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	// "AAEC/w==" is the base64 encoding of 00 01 02 ff.
+	input := `{"ip":"10.0.0.1","data":{` +
+		`"ssh":{"status":"success","protocol":"ssh","result":{"banner":"SSH-2.0-OpenSSH_8.2","port":22}},` +
+		`"raw":{"status":"success","protocol":"banner","result":{"bytes":["AAEC/w=="]}},` +
+		`"http":{"status":"connection-timeout","protocol":"http","error":"timeout"}}}`
+	output, err := ApplySignatures([]byte(input), signatures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var grab struct {
+		Data map[string]struct {
+			Signatures []string               `json:"signatures"`
+			Result     map[string]interface{} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(output, &grab); err != nil {
+		t.Fatal(err)
+	}
+	if got := grab.Data["ssh"].Signatures; !reflect.DeepEqual(got, []string{"openssh"}) {
+		t.Errorf("ssh: expected [openssh], got %v", got)
+	}
+	if got := grab.Data["raw"].Signatures; !reflect.DeepEqual(got, []string{"binary"}) {
+		t.Errorf("raw: expected [binary], got %v", got)
+	}
+	if got := grab.Data["http"].Signatures; got != nil {
+		t.Errorf("http: expected no signatures, got %v", got)
+	}
+	if port := grab.Data["ssh"].Result["port"]; port != float64(22) {
+		t.Errorf("result was altered: port = %v", port)
+	}
+
+	unmatched := `{"ip":"10.0.0.1","data":{"ssh":{"status":"success","result":{"banner":"SSH-2.0-dropbear"}}}}`
+	output, err = ApplySignatures([]byte(unmatched), signatures)
+	if err != nil || string(output) != unmatched {
+		t.Errorf("expected unmatched result to be unchanged, got %s: %v", output, err)
+	}
+}
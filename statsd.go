@@ -0,0 +1,168 @@
+package zgrab2
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statsdInterval is how often the statsd emitter pushes its metrics.
+const statsdInterval = time.Second
+
+// statsdMaxPacketSize keeps each datagram within a typical MTU.
+const statsdMaxPacketSize = 1432
+
+// statsd is the emitter started by ProcessContext if --statsd is set. The
+// Monitor passes it every scan's status.
+var statsd *statsdEmitter
+
+// statsdModuleStats are the counts for one module since the last push.
+type statsdModuleStats struct {
+	scans     uint64
+	successes uint64
+	failures  uint64
+	duration  time.Duration
+}
+
+// statsdEmitter pushes the scan metrics to a StatsD server over UDP. The
+// statuses are aggregated in memory and pushed once per statsdInterval, so
+// the scan never waits on the network; send errors are ignored.
+//
+// For each module that ran, it sends the counters <prefix>.<module>.scans,
+// .successes and .failures, and the gauge <prefix>.<module>.duration_ms, the
+// mean scan time; it also sends the gauge <prefix>.scans_per_second.
+type statsdEmitter struct {
+	conn   net.Conn
+	prefix string
+
+	mu      sync.Mutex
+	modules map[string]*statsdModuleStats
+	last    time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startStatsd starts a statsdEmitter pushing to the StatsD server at
+// address, with metric names starting with prefix.
+func startStatsd(address string, prefix string) (*statsdEmitter, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	emitter := &statsdEmitter{
+		conn:    conn,
+		prefix:  prefix,
+		modules: make(map[string]*statsdModuleStats),
+		last:    time.Now(),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(emitter.done)
+		ticker := time.NewTicker(statsdInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				emitter.push()
+			case <-emitter.stop:
+				return
+			}
+		}
+	}()
+	return emitter, nil
+}
+
+// observe records a scan's status.
+func (emitter *statsdEmitter) observe(s moduleStatus) {
+	emitter.mu.Lock()
+	defer emitter.mu.Unlock()
+	stats := emitter.modules[s.name]
+	if stats == nil {
+		stats = new(statsdModuleStats)
+		emitter.modules[s.name] = stats
+	}
+	stats.scans++
+	stats.duration += s.duration
+	switch s.st {
+	case statusSuccess:
+		stats.successes++
+	case statusFailure:
+		stats.failures++
+	}
+}
+
+// push sends the metrics observed since the last push, and resets them.
+func (emitter *statsdEmitter) push() {
+	emitter.mu.Lock()
+	modules := emitter.modules
+	emitter.modules = make(map[string]*statsdModuleStats)
+	now := time.Now()
+	elapsed := now.Sub(emitter.last)
+	emitter.last = now
+	emitter.mu.Unlock()
+
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var lines []string
+	var scans uint64
+	for _, name := range names {
+		stats := modules[name]
+		metric := emitter.prefix + "." + statsdName(name)
+		scans += stats.scans
+		lines = append(lines,
+			fmt.Sprintf("%s.scans:%d|c", metric, stats.scans),
+			fmt.Sprintf("%s.successes:%d|c", metric, stats.successes),
+			fmt.Sprintf("%s.failures:%d|c", metric, stats.failures),
+			fmt.Sprintf("%s.duration_ms:%.3f|g", metric, stats.duration.Seconds()*1000/float64(stats.scans)))
+	}
+	if elapsed > 0 {
+		lines = append(lines, fmt.Sprintf("%s.scans_per_second:%.3f|g", emitter.prefix, float64(scans)/elapsed.Seconds()))
+	}
+	emitter.send(lines)
+}
+
+// send sends lines in as few packets as possible.
+func (emitter *statsdEmitter) send(lines []string) {
+	var packet []byte
+	for _, line := range lines {
+		if len(packet) > 0 && len(packet)+1+len(line) > statsdMaxPacketSize {
+			emitter.conn.Write(packet)
+			packet = packet[:0]
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	if len(packet) > 0 {
+		emitter.conn.Write(packet)
+	}
+}
+
+// close pushes the remaining metrics and closes the connection.
+func (emitter *statsdEmitter) close() {
+	close(emitter.stop)
+	<-emitter.done
+	emitter.push()
+	emitter.conn.Close()
+}
+
+// statsdName replaces the characters that StatsD gives a meaning to (and
+// whitespace) in a module name.
+func statsdName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', '.', ' ', '\t', '\n':
+			return '_'
+		}
+		return r
+	}, name)
+}
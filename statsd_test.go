@@ -0,0 +1,79 @@
+package zgrab2
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsdEmitter(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	emitter, err := startStatsd(server.LocalAddr().String(), "zgrab2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	emitter.observe(moduleStatus{name: "http", st: statusSuccess, duration: 10 * time.Millisecond})
+	emitter.observe(moduleStatus{name: "http", st: statusFailure, duration: 30 * time.Millisecond})
+	emitter.observe(moduleStatus{name: "tls:443", st: statusSuccess, duration: time.Millisecond})
+	emitter.close()
+
+	buf := make([]byte, statsdMaxPacketSize)
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := server.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(buf[:n]), "\n")
+	expected := []string{
+		"zgrab2.http.scans:2|c",
+		"zgrab2.http.successes:1|c",
+		"zgrab2.http.failures:1|c",
+		"zgrab2.http.duration_ms:20.000|g",
+		"zgrab2.tls_443.scans:1|c",
+		"zgrab2.tls_443.successes:1|c",
+		"zgrab2.tls_443.failures:0|c",
+		"zgrab2.tls_443.duration_ms:1.000|g",
+	}
+	if len(lines) != len(expected)+1 || !strings.HasPrefix(lines[len(expected)], "zgrab2.scans_per_second:") {
+		t.Fatalf("unexpected packet %q", buf[:n])
+	}
+	for i, line := range expected {
+		if lines[i] != line {
+			t.Errorf("expected line %d to be %q, got %q", i, line, lines[i])
+		}
+	}
+}
+
+func TestStatsdEmitterSplitsPackets(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	conn, err := net.Dial("udp", server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	emitter := &statsdEmitter{conn: conn}
+	line := strings.Repeat("x", 600) + ":1|c"
+	emitter.send([]string{line, line, line})
+	buf := make([]byte, 2*statsdMaxPacketSize)
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for _, lines := range []int{2, 1} {
+		n, _, err := server.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > statsdMaxPacketSize || strings.Count(string(buf[:n]), "\n") != lines-1 {
+			t.Errorf("expected a packet of %d lines, got %d bytes: %q", lines, n, buf[:n])
+		}
+	}
+}
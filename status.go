@@ -3,7 +3,9 @@ package zgrab2
 import (
 	"io"
 	"net"
+	"os"
 	"runtime/debug"
+	"syscall"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -26,6 +28,17 @@ const (
 	SCAN_UNKNOWN_ERROR                 = ScanStatus("unknown-error")       // Catch-all for unrecognized errors
 )
 
+// IsConnectionFailure returns true if status means that the scan failed
+// before the target sent any application-level response: the connection was
+// refused, timed out, or was closed or went silent.
+func IsConnectionFailure(status ScanStatus) bool {
+	switch status {
+	case SCAN_CONNECTION_REFUSED, SCAN_CONNECTION_TIMEOUT, SCAN_CONNECTION_CLOSED, SCAN_IO_TIMEOUT:
+		return true
+	}
+	return false
+}
+
 // ScanError an error that also includes a ScanStatus.
 type ScanError struct {
 	Status ScanStatus
@@ -71,16 +84,17 @@ func TryGetScanStatus(err error) ScanStatus {
 	case *net.OpError:
 		switch e.Op {
 		case "dial":
-			// TODO: Distinguish connection timeout / connection refused
 			// Windows examples:
 			//	"dial tcp 192.168.30.3:22: connectex: A connection attempt failed because the connected party did not properly respond after a period of time, or established connection failed because connected host has failed to respond."
 			//	"dial tcp 127.0.0.1:22: connectex: No connection could be made because the target machine actively refused it."
+			if errno, ok := GetErrno(e); ok && errno == syscall.ECONNREFUSED {
+				return SCAN_CONNECTION_REFUSED
+			}
 			return SCAN_CONNECTION_TIMEOUT
-		case "read":
-			// TODO: Distinguish connection reset vs timeout
-			return SCAN_IO_TIMEOUT
-		case "write":
-			// TODO: Distinguish connection reset vs timeout
+		case "read", "write":
+			if errno, ok := GetErrno(e); ok && (errno == syscall.ECONNRESET || errno == syscall.EPIPE) {
+				return SCAN_CONNECTION_CLOSED
+			}
 			return SCAN_IO_TIMEOUT
 		default:
 			// TODO: Do we need a generic network error?
@@ -93,3 +107,13 @@ func TryGetScanStatus(err error) ScanStatus {
 		return SCAN_UNKNOWN_ERROR
 	}
 }
+
+// GetErrno returns the system error number underlying err, if there is one.
+func GetErrno(err *net.OpError) (syscall.Errno, bool) {
+	inner := err.Err
+	if sysErr, ok := inner.(*os.SyscallError); ok {
+		inner = sysErr.Err
+	}
+	errno, ok := inner.(syscall.Errno)
+	return errno, ok
+}
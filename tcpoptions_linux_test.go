@@ -0,0 +1,83 @@
+//go:build linux
+// +build linux
+
+package zgrab2
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestTCPOptions(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	defer func(keepAlive time.Duration, nagle bool) {
+		config.TCPKeepAlive, config.TCPNagle = keepAlive, nagle
+	}(config.TCPKeepAlive, config.TCPNagle)
+
+	config.TCPKeepAlive = 42 * time.Second
+	config.TCPNagle = true
+	conn, err := DialTimeoutConnection("tcp", listener.Addr().String(), time.Second, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	raw, err := conn.(*TimeoutConnection).Conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keepAlive, idle, noDelay int
+	raw.Control(func(fd uintptr) {
+		keepAlive, _ = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE)
+		idle, _ = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE)
+		noDelay, _ = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_NODELAY)
+	})
+	if keepAlive == 0 || idle != 42 || noDelay != 0 {
+		t.Errorf("unexpected socket options: SO_KEEPALIVE %d, TCP_KEEPIDLE %d, TCP_NODELAY %d", keepAlive, idle, noDelay)
+	}
+}
+
+func TestSourceIP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	remote := make(chan net.Addr, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		remote <- conn.RemoteAddr()
+		conn.Close()
+	}()
+	defer func(localAddr *net.TCPAddr) {
+		config.localAddr = localAddr
+	}(config.localAddr)
+
+	// Linux accepts any 127.0.0.0/8 address as a loopback source.
+	config.localAddr = &net.TCPAddr{IP: net.ParseIP("127.0.0.2")}
+	conn, err := DialTimeoutConnection("tcp", listener.Addr().String(), time.Second, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if addr := (<-remote).(*net.TCPAddr); !addr.IP.Equal(config.localAddr.IP) {
+		t.Errorf("expected the connection to come from %s, got %s", config.localAddr.IP, addr.IP)
+	}
+}
@@ -0,0 +1,50 @@
+package zgrab2
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Timing breaks down the time taken by a single scan, in microseconds. It is
+// included in every ScanResponse, so the fields mean the same for every
+// module.
+type Timing struct {
+	// Connect is the time taken to establish the scan's first TCP
+	// connection, if it opened one with ScanTarget.Open (or recorded one
+	// with ScanTarget.RecordConnect).
+	Connect int64 `json:"connect_us,omitempty"`
+
+	// Handshake is the time taken by the scan's first protocol handshake
+	// (e.g. TLS), if it made one with ScanTarget.OpenTLS (or recorded one
+	// with ScanTarget.RecordHandshake).
+	Handshake int64 `json:"handshake_us,omitempty"`
+
+	// Total is the time taken by the whole scan.
+	Total int64 `json:"total_us"`
+}
+
+// microseconds returns d in whole microseconds, rounded up so that
+// recorded durations are never zero.
+func microseconds(d time.Duration) int64 {
+	return int64((d + time.Microsecond - 1) / time.Microsecond)
+}
+
+// RecordConnect records the time taken to establish a TCP connection to the
+// target, which started at start, in the current scan's Timing. Only the
+// first connection of a scan is recorded. Modules that connect without
+// ScanTarget.Open should call it after connecting.
+func (target *ScanTarget) RecordConnect(start time.Time) {
+	if target.timing != nil {
+		atomic.CompareAndSwapInt64(&target.timing.Connect, 0, microseconds(time.Since(start)))
+	}
+}
+
+// RecordHandshake records the time taken by a protocol handshake with the
+// target, which started at start, in the current scan's Timing. Only the
+// first handshake of a scan is recorded. Modules should call it once their
+// protocol's handshake (e.g. an SSH key exchange) is complete.
+func (target *ScanTarget) RecordHandshake(start time.Time) {
+	if target.timing != nil {
+		atomic.CompareAndSwapInt64(&target.timing.Handshake, 0, microseconds(time.Since(start)))
+	}
+}
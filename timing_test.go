@@ -0,0 +1,92 @@
+package zgrab2
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// timingTestScanner opens a connection to the target and holds it briefly.
+type timingTestScanner struct {
+	flags *BaseFlags
+}
+
+func (s *timingTestScanner) Init(flags ScanFlags) error       { return nil }
+func (s *timingTestScanner) InitPerSender(senderID int) error { return nil }
+func (s *timingTestScanner) GetName() string                  { return "timing" }
+func (s *timingTestScanner) GetTrigger() string               { return "" }
+func (s *timingTestScanner) Protocol() string                 { return "timing" }
+
+func (s *timingTestScanner) Scan(ctx context.Context, t ScanTarget) (ScanStatus, interface{}, error) {
+	conn, err := t.Open(s.flags)
+	if err != nil {
+		return TryGetScanStatus(err), nil, err
+	}
+	defer conn.Close()
+	time.Sleep(10 * time.Millisecond)
+	return SCAN_SUCCESS, nil, nil
+}
+
+func TestRunScannerTiming(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	var wg sync.WaitGroup
+	mon := MakeMonitor(1, &wg)
+	defer wg.Wait()
+	defer mon.Stop()
+	scanner := &timingTestScanner{flags: &BaseFlags{
+		Port:    uint(listener.Addr().(*net.TCPAddr).Port),
+		Timeout: 5 * time.Second,
+	}}
+	_, resp := RunScanner(context.Background(), scanner, mon, ScanTarget{IP: net.ParseIP("127.0.0.1")})
+	if resp.Status != SCAN_SUCCESS {
+		t.Fatalf("unexpected status %s (%v)", resp.Status, resp.Error)
+	}
+	timing := resp.Timing
+	if timing == nil || timing.Connect <= 0 || timing.Handshake != 0 {
+		t.Fatalf("unexpected timing %+v", timing)
+	}
+	if timing.Total < timing.Connect || timing.Total < 10000 {
+		t.Errorf("unexpected total in timing %+v", timing)
+	}
+	if resp.ConnectedAddr != listener.Addr().String() {
+		t.Errorf("expected connected_addr %s, got %q", listener.Addr(), resp.ConnectedAddr)
+	}
+}
+
+func TestRecordConnectFirstOnly(t *testing.T) {
+	target := ScanTarget{timing: new(Timing)}
+	target.RecordConnect(time.Now().Add(-time.Millisecond))
+	target.RecordConnect(time.Now().Add(-time.Second))
+	if connect := target.timing.Connect; connect < 1000 || connect >= 1000000 {
+		t.Errorf("expected the first connection to be recorded, got %dus", connect)
+	}
+
+	// Without a scan in progress, recording is a no-op.
+	(&ScanTarget{}).RecordHandshake(time.Now())
+	(&ScanTarget{}).RecordRemoteAddr(&net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 80})
+}
+
+func TestRecordRemoteAddrFirstOnly(t *testing.T) {
+	target := ScanTarget{remoteAddr: new(remoteAddr)}
+	target.RecordRemoteAddr(&net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 80})
+	target.RecordRemoteAddr(&net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 443})
+	if addr := target.remoteAddr.get(); addr != "192.0.2.1:80" {
+		t.Errorf("expected the first connection to be recorded, got %q", addr)
+	}
+}
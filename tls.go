@@ -3,6 +3,7 @@ package zgrab2
 import (
 	"encoding/base64"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -28,7 +29,9 @@ import (
 // Common flags for TLS configuration -- include this in your module's ScanFlags implementation to use the common TLS code
 // Adapted from modules/ssh.go
 type TLSFlags struct {
-	Heartbleed bool `long:"heartbleed" description:"Check if server is vulnerable to Heartbleed"`
+	// Heartbleed (CVE-2014-0160) is intrusive, so it is opt-in; only the
+	// length of the heartbeat response is kept, not its contents.
+	Heartbleed bool `long:"heartbleed" description:"Check if server is vulnerable to Heartbleed (intrusive: sends a malformed heartbeat request after the handshake)"`
 
 	SessionTicket        bool `long:"session-ticket" description:"Send support for TLS Session Tickets and output ticket if presented" json:"session"`
 	ExtendedMasterSecret bool `long:"extended-master-secret" description:"Offer RFC 7627 Extended Master Secret extension" json:"extended"`
@@ -40,8 +43,11 @@ type TLSFlags struct {
 	KeepClientLogs bool `long:"keep-client-logs" description:"Include the client-side logs in the TLS handshake"`
 
 	Time string `long:"time" description:"Explicit request time to use, instead of clock. YYYYMMDDhhmmss format."`
-	// TODO: directory? glob? How to map server name -> certificate?
-	Certificates string `long:"certificates" description:"Set of certificates to present to the server"`
+	// Certificates and CertificateKey give the client certificate, for
+	// servers requiring mutual TLS; they are loaded when the flags are
+	// validated.
+	Certificates   string `long:"certificates" description:"PEM file of a client certificate (followed by any intermediates) to present to the server, for servers requiring mutual TLS"`
+	CertificateKey string `long:"certificate-key" description:"PEM file of the private key for --certificates (default: read from the --certificates file)"`
 	// TODO: re-evaluate this, or at least specify the file format
 	CertificateMap string `long:"certificate-map" description:"A file mapping server names to certificates"`
 	// TODO: directory? glob?
@@ -50,6 +56,10 @@ type TLSFlags struct {
 	NextProtos              string `long:"next-protos" description:"A list of supported application-level protocols"`
 	ServerName              string `long:"server-name" description:"Server name used for certificate verification and (optionally) SNI"`
 	VerifyServerCertificate bool   `long:"verify-server-certificate" description:"If set, the scan will fail if the server certificate does not match the server-name, or does not chain to a trusted root."`
+	// ValidateCert records the validity of the server certificate without
+	// failing the scan; see CertValidation.
+	ValidateCert bool   `long:"validate-cert" description:"Validate the server certificate, and record whether it is trusted, self-signed, expired or not yet valid, matches the server name, and the days until it expires"`
+	CAFile       string `long:"ca-file" description:"PEM file of trusted roots for --validate-cert (default: the system roots)"`
 	// TODO: format? mapping? zgrab1 had flags like ChromeOnly, FirefoxOnly, etc...
 	CipherSuite      string `long:"cipher-suite" description:"A comma-delimited list of hex cipher suites to advertise."`
 	MinVersion       int    `long:"min-version" description:"The minimum SSL/TLS version that is acceptable. 0 means that SSLv3 is the minimum."`
@@ -64,6 +74,60 @@ type TLSFlags struct {
 	ClientRandom string `long:"client-random" description:"Set an explicit Client Random (base64 encoded)"`
 	// TODO: format?
 	ClientHello string `long:"client-hello" description:"Set an explicit ClientHello (base64 encoded)"`
+
+	// clientCertificate is the certificate loaded from Certificates.
+	clientCertificate *tls.Certificate
+}
+
+// tlsFlagsValidator is implemented by the flags of every module that embeds
+// TLSFlags.
+type tlsFlagsValidator interface {
+	validate() error
+}
+
+// validate loads the files named by the flags, so that a bad file is
+// reported when the command line is parsed rather than during the scan.
+func (t *TLSFlags) validate() error {
+	if t.CAFile != "" {
+		if _, err := getValidationRoots(t.CAFile); err != nil {
+			return err
+		}
+	}
+	if t.CertificateKey != "" && t.Certificates == "" {
+		return errors.New("--certificate-key requires --certificates")
+	}
+	if t.Certificates != "" {
+		cert, err := t.loadClientCertificate()
+		if err != nil {
+			return err
+		}
+		t.clientCertificate = cert
+	}
+	return nil
+}
+
+// loadClientCertificate loads the client certificate and its key from the
+// --certificates and --certificate-key files.
+func (t *TLSFlags) loadClientCertificate() (*tls.Certificate, error) {
+	keyFile := t.CertificateKey
+	if keyFile == "" {
+		keyFile = t.Certificates
+	}
+	cert, err := tls.LoadX509KeyPair(t.Certificates, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not load --certificates %s: %s", t.Certificates, err)
+	}
+	return &cert, nil
+}
+
+// ValidateTLSFlags validates the TLSFlags embedded in a module's flags, if
+// there are any. ParseCommandLine calls it for the module given on the
+// command line; it must also be called for each module of a multiple scan.
+func ValidateTLSFlags(flags ScanFlags) error {
+	if t, ok := flags.(tlsFlagsValidator); ok {
+		return t.validate()
+	}
+	return nil
 }
 
 func getCSV(arg string) []string {
@@ -120,8 +184,15 @@ func (t *TLSFlags) GetTLSConfigForTarget(target *ScanTarget) (*tls.Config, error
 		}
 	}
 	if t.Certificates != "" {
-		// TODO FIXME: Implement
-		log.Fatalf("--certificates not implemented")
+		cert := t.clientCertificate
+		if cert == nil {
+			// The flags were not validated (e.g. they were set by a caller
+			// of the package rather than on the command line).
+			if cert, err = t.loadClientCertificate(); err != nil {
+				return nil, err
+			}
+		}
+		ret.Certificates = []tls.Certificate{*cert}
 	}
 	if t.CertificateMap != "" {
 		// TODO FIXME: Implement
@@ -258,10 +329,17 @@ func (t *TLSFlags) GetTLSConfigForTarget(target *ScanTarget) (*tls.Config, error
 	return &ret, nil
 }
 
+// heartbleedReadLimit bounds the number of bytes of the heartbeat response
+// read by the heartbleed check; a leak is confirmed by the response length
+// alone.
+const heartbleedReadLimit = 256
+
 type TLSConnection struct {
 	tls.Conn
-	flags *TLSFlags
-	log   *TLSLog
+	flags    *TLSFlags
+	config   *tls.Config
+	log      *TLSLog
+	recorder *serverHelloRecorder
 }
 
 type TLSLog struct {
@@ -269,6 +347,55 @@ type TLSLog struct {
 	HandshakeLog *tls.ServerHandshake `json:"handshake_log"`
 	// This will be nil if heartbleed is not checked because of client configuration flags
 	HeartbleedLog *tls.Heartbleed `json:"heartbleed_log,omitempty"`
+	// HeartbeatResponseLength is the number of bytes of the heartbeat
+	// response read by the heartbleed check (at most heartbleedReadLimit);
+	// the bytes themselves are discarded
+	HeartbeatResponseLength int `json:"heartbeat_response_length,omitempty"`
+	// JARM is the server's JARM fingerprint, if requested by the module
+	JARM string `json:"jarm,omitempty"`
+	// JA3S is the fingerprint of the ServerHello, if one was received
+	JA3S *JA3S `json:"ja3s,omitempty"`
+	// CertValidation is the validity of the server certificate, if
+	// --validate-cert is set
+	CertValidation *CertValidation `json:"cert_validation,omitempty"`
+	// SNIProbes are the additional handshakes made with other server names,
+	// if requested by the module
+	SNIProbes []*SNIProbe `json:"sni_probes,omitempty"`
+	// Sweep records the protocol versions and weak cipher suites accepted,
+	// if requested by the module
+	Sweep *TLSSweep `json:"sweep,omitempty"`
+	// ALPN records the application protocols offered and the one the
+	// server selected, if requested by the module
+	ALPN *ALPN `json:"alpn,omitempty"`
+}
+
+// ALPN records an Application-Layer Protocol Negotiation (RFC 7301).
+type ALPN struct {
+	// Offered are the protocols offered in the ClientHello, in order of
+	// preference.
+	Offered []string `json:"offered"`
+
+	// Selected is the protocol the server selected, or empty if it did not
+	// select one (e.g. if it does not support ALPN).
+	Selected string `json:"selected"`
+}
+
+// SNIProbe records the certificate presented in a handshake made with a
+// given server name (or none), for comparison with that of the main
+// handshake.
+type SNIProbe struct {
+	// ServerName is the SNI sent, or empty if none was sent.
+	ServerName string `json:"server_name,omitempty"`
+
+	// ServerCertificates are the certificates presented by the server.
+	ServerCertificates *tls.Certificates `json:"server_certificates,omitempty"`
+
+	// SameCertificate is true if the server presented the same leaf
+	// certificate as in the main handshake.
+	SameCertificate bool `json:"same_certificate"`
+
+	// Error is set if the handshake failed.
+	Error string `json:"error,omitempty"`
 }
 
 func (z *TLSConnection) GetLog() *TLSLog {
@@ -282,13 +409,17 @@ func (z *TLSConnection) GetLog() *TLSLog {
 func (z *TLSConnection) Handshake() error {
 	log := z.GetLog()
 	if z.flags.Heartbleed {
-		buf := make([]byte, 256)
+		buf := make([]byte, heartbleedReadLimit)
+		var n int
 		defer func() {
 			log.HandshakeLog = z.Conn.GetHandshakeLog()
 			log.HeartbleedLog = z.Conn.GetHeartbleedLog()
+			log.HeartbeatResponseLength = n
+			log.JA3S = z.getJA3S()
+			log.CertValidation = z.validateServerCertificate(log.HandshakeLog)
 		}()
 		// TODO - CheckHeartbleed does not bubble errors from Handshake
-		_, err := z.CheckHeartbleed(buf)
+		n, err := z.CheckHeartbleed(buf)
 		if err == tls.HeartbleedError {
 			err = nil
 		}
@@ -297,11 +428,22 @@ func (z *TLSConnection) Handshake() error {
 		defer func() {
 			log.HandshakeLog = z.Conn.GetHandshakeLog()
 			log.HeartbleedLog = nil
+			log.JA3S = z.getJA3S()
+			log.CertValidation = z.validateServerCertificate(log.HandshakeLog)
 		}()
 		return z.Conn.Handshake()
 	}
 }
 
+// getJA3S stops recording the bytes read from the server, and returns the
+// JA3S fingerprint of the ServerHello, if one was received.
+func (z *TLSConnection) getJA3S() *JA3S {
+	if z.recorder == nil {
+		return nil
+	}
+	return z.recorder.stop()
+}
+
 // Close the underlying connection.
 func (conn *TLSConnection) Close() error {
 	return conn.Conn.Close()
@@ -331,10 +473,13 @@ func (t *TLSFlags) GetTLSConnectionForTarget(conn net.Conn, target *ScanTarget)
 }
 
 func (t *TLSFlags) GetWrappedConnection(conn net.Conn, cfg *tls.Config) *TLSConnection {
-	tlsClient := tls.Client(conn, cfg)
+	recorder := newServerHelloRecorder(conn)
+	tlsClient := tls.Client(recorder, cfg)
 	wrappedClient := TLSConnection{
-		Conn:  *tlsClient,
-		flags: t,
+		Conn:     *tlsClient,
+		flags:    t,
+		config:   cfg,
+		recorder: recorder,
 	}
 	return &wrappedClient
 }
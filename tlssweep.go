@@ -0,0 +1,359 @@
+package zgrab2
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+// The protocol versions probed by SweepTLS.
+const (
+	versionSSL30 uint16 = 0x0300
+	versionTLS10 uint16 = 0x0301
+	versionTLS11 uint16 = 0x0302
+	versionTLS12 uint16 = 0x0303
+	versionTLS13 uint16 = 0x0304
+)
+
+// sweepVersion is a protocol version probed by SweepTLS, with the cipher
+// suites offered in its ClientHello.
+type sweepVersion struct {
+	name    string
+	version uint16
+	ciphers []uint16
+}
+
+// sweepCipherGroup is a group of weak cipher suites probed by SweepTLS.
+type sweepCipherGroup struct {
+	name    string
+	ciphers []uint16
+}
+
+var (
+	rc4Ciphers = []uint16{
+		0xc011, // TLS_ECDHE_RSA_WITH_RC4_128_SHA
+		0xc007, // TLS_ECDHE_ECDSA_WITH_RC4_128_SHA
+		0x0005, // TLS_RSA_WITH_RC4_128_SHA
+		0x0004, // TLS_RSA_WITH_RC4_128_MD5
+	}
+	tripleDESCiphers = []uint16{
+		0xc012, // TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA
+		0xc008, // TLS_ECDHE_ECDSA_WITH_3DES_EDE_CBC_SHA
+		0x0016, // TLS_DHE_RSA_WITH_3DES_EDE_CBC_SHA
+		0x000a, // TLS_RSA_WITH_3DES_EDE_CBC_SHA
+	}
+	exportCiphers = []uint16{
+		0x0003, // TLS_RSA_EXPORT_WITH_RC4_40_MD5
+		0x0006, // TLS_RSA_EXPORT_WITH_RC2_CBC_40_MD5
+		0x0008, // TLS_RSA_EXPORT_WITH_DES40_CBC_SHA
+		0x000e, // TLS_DH_RSA_EXPORT_WITH_DES40_CBC_SHA
+		0x0011, // TLS_DHE_DSS_EXPORT_WITH_DES40_CBC_SHA
+		0x0014, // TLS_DHE_RSA_EXPORT_WITH_DES40_CBC_SHA
+	}
+	nullCiphers = []uint16{
+		0xc010, // TLS_ECDHE_RSA_WITH_NULL_SHA
+		0xc006, // TLS_ECDHE_ECDSA_WITH_NULL_SHA
+		0x003b, // TLS_RSA_WITH_NULL_SHA256
+		0x0002, // TLS_RSA_WITH_NULL_SHA
+		0x0001, // TLS_RSA_WITH_NULL_MD5
+	}
+
+	// modernCiphers are the current TLS 1.0-1.2 cipher suites, offered
+	// (along with the weak ones) when probing a version.
+	modernCiphers = []uint16{
+		0xc02b, // TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256
+		0xc02f, // TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+		0xc02c, // TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384
+		0xc030, // TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384
+		0xcca9, // TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256
+		0xcca8, // TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256
+		0xc023, // TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256
+		0xc027, // TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256
+		0xc009, // TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA
+		0xc013, // TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA
+		0xc00a, // TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA
+		0xc014, // TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA
+		0x009e, // TLS_DHE_RSA_WITH_AES_128_GCM_SHA256
+		0x009f, // TLS_DHE_RSA_WITH_AES_256_GCM_SHA384
+		0x0033, // TLS_DHE_RSA_WITH_AES_128_CBC_SHA
+		0x0039, // TLS_DHE_RSA_WITH_AES_256_CBC_SHA
+		0x009c, // TLS_RSA_WITH_AES_128_GCM_SHA256
+		0x009d, // TLS_RSA_WITH_AES_256_GCM_SHA384
+		0x003c, // TLS_RSA_WITH_AES_128_CBC_SHA256
+		0x002f, // TLS_RSA_WITH_AES_128_CBC_SHA
+		0x0035, // TLS_RSA_WITH_AES_256_CBC_SHA
+	}
+
+	// tls13Ciphers are the TLS 1.3 cipher suites; a ServerHello selecting
+	// one of them has negotiated TLS 1.3.
+	tls13Ciphers = []uint16{
+		0x1301, // TLS_AES_128_GCM_SHA256
+		0x1302, // TLS_AES_256_GCM_SHA384
+		0x1303, // TLS_CHACHA20_POLY1305_SHA256
+	}
+)
+
+// legacyCiphers are offered when probing SSL 3.0 to TLS 1.2.
+var legacyCiphers = concatCiphers(modernCiphers, tripleDESCiphers, rc4Ciphers)
+
+var sweepVersions = []sweepVersion{
+	{"SSLv3", versionSSL30, legacyCiphers},
+	{"TLSv1.0", versionTLS10, legacyCiphers},
+	{"TLSv1.1", versionTLS11, legacyCiphers},
+	{"TLSv1.2", versionTLS12, legacyCiphers},
+	{"TLSv1.3", versionTLS13, tls13Ciphers},
+}
+
+var weakCipherGroups = []sweepCipherGroup{
+	{"RC4", rc4Ciphers},
+	{"3DES", tripleDESCiphers},
+	{"EXPORT", exportCiphers},
+	{"NULL", nullCiphers},
+}
+
+// maxSweepRead bounds the number of bytes read while waiting for a
+// ServerHello.
+const maxSweepRead = 16384 + 5
+
+// errSweepRejected is recorded when the server answers a sweep probe with
+// an alert, or with a ServerHello for a different version or cipher suite.
+var errSweepRejected = errors.New("rejected by the server")
+
+// TLSSweep is the result of SweepTLS: which protocol versions and weak
+// cipher suites a server accepts.
+type TLSSweep struct {
+	// AcceptedVersions are the names of the accepted versions, e.g.
+	// "TLSv1.2".
+	AcceptedVersions []string `json:"accepted_versions"`
+
+	// Versions are the per-version probes, from SSLv3 to TLSv1.3.
+	Versions []*TLSSweepProbe `json:"versions"`
+
+	// WeakCiphers are the per-group probes of the RC4, 3DES, EXPORT and
+	// NULL cipher suites, made with the highest accepted version up to
+	// TLSv1.2. They are absent if no such version was accepted.
+	WeakCiphers []*TLSSweepProbe `json:"weak_ciphers,omitempty"`
+
+	// Deprecated is true if the server accepts SSLv3, TLSv1.0 or TLSv1.1,
+	// or any of the weak cipher groups.
+	Deprecated bool `json:"deprecated"`
+}
+
+// TLSSweepProbe is the result of one sweep handshake.
+type TLSSweepProbe struct {
+	// Name is the version (e.g. "TLSv1.0") or cipher group (e.g. "RC4")
+	// probed.
+	Name string `json:"name"`
+
+	// Accepted is true if the server answered with a ServerHello selecting
+	// the probed version (and, for a cipher group, one of its suites).
+	Accepted bool `json:"accepted"`
+
+	// CipherSuite is the cipher suite selected by the server, if accepted.
+	CipherSuite uint16 `json:"cipher_suite,omitempty"`
+
+	// Error is set if the probe was not accepted.
+	Error string `json:"error,omitempty"`
+}
+
+// SweepTLS makes one handshake per protocol version from SSLv3 to TLSv1.3,
+// then one per weak cipher group (so at most nine in all) with the highest accepted version up to
+// TLSv1.2, each on a new connection, and records which were accepted. The
+// handshakes go no further than the ServerHello, so versions and suites
+// the TLS library does not implement can still be probed. serverName is
+// sent as SNI, unless empty.
+func SweepTLS(target *ScanTarget, flags *BaseFlags, serverName string) *TLSSweep {
+	sweep := &TLSSweep{AcceptedVersions: []string{}}
+	var cipherVersion uint16
+	for _, v := range sweepVersions {
+		probe := sweepProbe(target, flags, v.name, v.version, v.ciphers, serverName)
+		sweep.Versions = append(sweep.Versions, probe)
+		if !probe.Accepted {
+			continue
+		}
+		sweep.AcceptedVersions = append(sweep.AcceptedVersions, v.name)
+		if v.version <= versionTLS11 {
+			sweep.Deprecated = true
+		}
+		if v.version <= versionTLS12 {
+			cipherVersion = v.version
+		}
+	}
+	if cipherVersion == 0 {
+		return sweep
+	}
+	for _, group := range weakCipherGroups {
+		probe := sweepProbe(target, flags, group.name, cipherVersion, group.ciphers, serverName)
+		sweep.WeakCiphers = append(sweep.WeakCiphers, probe)
+		if probe.Accepted {
+			sweep.Deprecated = true
+		}
+	}
+	return sweep
+}
+
+// sweepProbe sends a ClientHello for version offering ciphers on a new
+// connection, and checks whether the ServerHello accepts them.
+func sweepProbe(target *ScanTarget, flags *BaseFlags, name string, version uint16, ciphers []uint16, serverName string) *TLSSweepProbe {
+	probe := &TLSSweepProbe{Name: name}
+	conn, err := target.Open(flags)
+	if err != nil {
+		probe.Error = err.Error()
+		return probe
+	}
+	defer conn.Close()
+	hello, err := buildSweepClientHello(version, ciphers, serverName)
+	if err != nil {
+		probe.Error = err.Error()
+		return probe
+	}
+	if _, err := conn.Write(hello); err != nil {
+		probe.Error = err.Error()
+		return probe
+	}
+
+	var data []byte
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		data = append(data, buf[:n]...)
+		if len(data) > 0 && data[0] == 21 {
+			// An alert record
+			probe.Error = errSweepRejected.Error()
+			return probe
+		}
+		serverVersion, cipher, _, parseErr := parseServerHello(data)
+		if parseErr == nil {
+			if sweepAccepted(version, ciphers, serverVersion, cipher) {
+				probe.Accepted = true
+				probe.CipherSuite = cipher
+			} else {
+				probe.Error = errSweepRejected.Error()
+			}
+			return probe
+		}
+		if err != nil {
+			probe.Error = err.Error()
+			return probe
+		}
+		if len(data) >= maxSweepRead {
+			probe.Error = errNoServerHello.Error()
+			return probe
+		}
+	}
+}
+
+// sweepAccepted returns true if a ServerHello with the given version and
+// cipher suite accepts a ClientHello for version offering ciphers. TLS 1.3
+// ServerHellos carry the TLS 1.2 version, so TLS 1.3 is recognized by its
+// cipher suites instead.
+func sweepAccepted(version uint16, ciphers []uint16, serverVersion, cipher uint16) bool {
+	if version == versionTLS13 {
+		serverVersion = versionTLS12
+		if containsCipher(tls13Ciphers, cipher) {
+			serverVersion = versionTLS13
+		}
+	}
+	return serverVersion == version && containsCipher(ciphers, cipher)
+}
+
+func containsCipher(ciphers []uint16, cipher uint16) bool {
+	for _, c := range ciphers {
+		if c == cipher {
+			return true
+		}
+	}
+	return false
+}
+
+func concatCiphers(lists ...[]uint16) []uint16 {
+	var ret []uint16
+	for _, list := range lists {
+		ret = append(ret, list...)
+	}
+	return ret
+}
+
+// appendUint16 appends v to b in network byte order.
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+// appendExtension appends a TLS extension of the given type and body to b.
+func appendExtension(b []byte, extensionType uint16, body []byte) []byte {
+	b = appendUint16(b, extensionType)
+	b = appendUint16(b, uint16(len(body)))
+	return append(b, body...)
+}
+
+// buildSweepClientHello returns a ClientHello record for version offering
+// ciphers. The SSLv3 hello has no extensions; the TLS 1.3 hello offers only
+// TLS 1.3 in supported_versions, with a random X25519 key share.
+func buildSweepClientHello(version uint16, ciphers []uint16, serverName string) ([]byte, error) {
+	random := make([]byte, 32+32+32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	helloVersion := version
+	if version == versionTLS13 {
+		helloVersion = versionTLS12
+	}
+
+	var hello []byte
+	hello = appendUint16(hello, helloVersion)
+	hello = append(hello, random[:32]...)
+	// A session ID, for the TLS 1.3 middlebox compatibility mode.
+	hello = append(hello, 32)
+	hello = append(hello, random[32:64]...)
+	hello = appendUint16(hello, uint16(2*len(ciphers)))
+	for _, cipher := range ciphers {
+		hello = appendUint16(hello, cipher)
+	}
+	// Only the null compression method
+	hello = append(hello, 1, 0)
+
+	if version != versionSSL30 {
+		var extensions []byte
+		if serverName != "" {
+			// server_name: a single host_name entry
+			entry := []byte{0}
+			entry = appendUint16(entry, uint16(len(serverName)))
+			entry = append(entry, serverName...)
+			extensions = appendExtension(extensions, 0x0000, append(appendUint16(nil, uint16(len(entry))), entry...))
+		}
+		// supported_groups: x25519, secp256r1, secp384r1, secp521r1
+		extensions = appendExtension(extensions, 0x000a, []byte{0, 8, 0x00, 0x1d, 0x00, 0x17, 0x00, 0x18, 0x00, 0x19})
+		// ec_point_formats: uncompressed
+		extensions = appendExtension(extensions, 0x000b, []byte{1, 0})
+		// signature_algorithms: ECDSA, RSA-PSS and RSA PKCS#1 with
+		// SHA-256/384/512, and RSA PKCS#1 and ECDSA with SHA-1
+		extensions = appendExtension(extensions, 0x000d, []byte{
+			0, 22,
+			0x04, 0x03, 0x05, 0x03, 0x06, 0x03,
+			0x08, 0x04, 0x08, 0x05, 0x08, 0x06,
+			0x04, 0x01, 0x05, 0x01, 0x06, 0x01,
+			0x02, 0x01, 0x02, 0x03,
+		})
+		// renegotiation_info, empty
+		extensions = appendExtension(extensions, 0xff01, []byte{0})
+		if version == versionTLS13 {
+			// supported_versions: TLS 1.3 only
+			extensions = appendExtension(extensions, 0x002b, []byte{2, 0x03, 0x04})
+			// key_share: X25519
+			share := []byte{0, 36, 0x00, 0x1d, 0, 32}
+			extensions = appendExtension(extensions, 0x0033, append(share, random[64:]...))
+		}
+		hello = appendUint16(hello, uint16(len(extensions)))
+		hello = append(hello, extensions...)
+	}
+
+	handshake := []byte{1, byte(len(hello) >> 16), byte(len(hello) >> 8), byte(len(hello))}
+	handshake = append(handshake, hello...)
+	recordVersion := version
+	if version > versionTLS10 {
+		recordVersion = versionTLS10
+	}
+	record := []byte{22}
+	record = appendUint16(record, recordVersion)
+	record = appendUint16(record, uint16(len(handshake)))
+	return append(record, handshake...), nil
+}
@@ -0,0 +1,86 @@
+package zgrab2
+
+import (
+	stdtls "crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSweepTLS(t *testing.T) {
+	// Borrow the test certificate of an httptest TLS server.
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	config := server.TLS.Clone()
+	config.MinVersion = stdtls.VersionTLS12
+	config.MaxVersion = stdtls.VersionTLS13
+	server.Close()
+
+	listener, err := stdtls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.(*stdtls.Conn).Handshake()
+			}()
+		}
+	}()
+
+	flags := &BaseFlags{
+		Port:    uint(listener.Addr().(*net.TCPAddr).Port),
+		Timeout: 5 * time.Second,
+	}
+	target := &ScanTarget{IP: net.ParseIP("127.0.0.1")}
+	sweep := SweepTLS(target, flags, "example.com")
+
+	if expected := []string{"TLSv1.2", "TLSv1.3"}; !reflect.DeepEqual(sweep.AcceptedVersions, expected) {
+		t.Errorf("expected accepted versions %v, got %v", expected, sweep.AcceptedVersions)
+	}
+	if len(sweep.Versions) != len(sweepVersions) {
+		t.Fatalf("expected %d version probes, got %d", len(sweepVersions), len(sweep.Versions))
+	}
+	for _, probe := range sweep.Versions {
+		if probe.Accepted != (probe.CipherSuite != 0) || probe.Accepted == (probe.Error != "") {
+			t.Errorf("inconsistent probe %+v", probe)
+		}
+	}
+	if tls13 := sweep.Versions[4]; !containsCipher(tls13Ciphers, tls13.CipherSuite) {
+		t.Errorf("unexpected TLSv1.3 probe %+v", tls13)
+	}
+	if len(sweep.WeakCiphers) != len(weakCipherGroups) {
+		t.Fatalf("expected %d cipher group probes, got %d", len(weakCipherGroups), len(sweep.WeakCiphers))
+	}
+	for _, probe := range sweep.WeakCiphers {
+		if probe.Accepted {
+			t.Errorf("unexpected accepted cipher group %+v", probe)
+		}
+	}
+	if sweep.Deprecated {
+		t.Error("sweep unexpectedly deprecated")
+	}
+}
+
+func TestBuildSweepClientHello(t *testing.T) {
+	for _, v := range sweepVersions {
+		hello, err := buildSweepClientHello(v.version, v.ciphers, "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hello[0] != 22 || int(hello[3])<<8|int(hello[4]) != len(hello)-5 {
+			t.Errorf("%s: bad record header % x", v.name, hello[:5])
+		}
+		if length := int(hello[6])<<16 | int(hello[7])<<8 | int(hello[8]); hello[5] != 1 || length != len(hello)-9 {
+			t.Errorf("%s: bad handshake header % x", v.name, hello[5:9])
+		}
+	}
+}
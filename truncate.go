@@ -0,0 +1,156 @@
+package zgrab2
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"unicode/utf8"
+)
+
+// maxTruncateSteps bounds the number of fields truncateRecord clips or drops
+// one at a time, before it drops the whole data object.
+const maxTruncateSteps = 1000
+
+// recordField is a scalar field (or array element) of a decoded record.
+type recordField struct {
+	// path is the field's dotted path in the record, e.g.
+	// "data.http.result.response.body"; array elements are numbered.
+	path string
+
+	// value is the field's value, and size the length of its encoding.
+	value interface{}
+	size  int
+
+	// removed is the number of bytes besides size by which removing the
+	// field shortens the record: its key, colon and comma, or -4 for an
+	// array element, which is replaced with null.
+	removed int
+
+	// set replaces the field's value; remove drops it.
+	set    func(interface{})
+	remove func()
+}
+
+// encodedSize returns the length of the JSON encoding of value.
+func encodedSize(value interface{}) int {
+	encoded, _ := json.Marshal(value)
+	return len(encoded)
+}
+
+// recordFields appends the scalar fields in value to fields.
+func recordFields(fields []*recordField, value interface{}, path string, removed int, set func(interface{}), remove func()) []*recordField {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			key := key
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			fields = recordFields(fields, child, childPath, encodedSize(key)+2, func(x interface{}) { v[key] = x }, func() { delete(v, key) })
+		}
+	case []interface{}:
+		for i, child := range v {
+			i := i
+			fields = recordFields(fields, child, path+"."+strconv.Itoa(i), -4, func(x interface{}) { v[i] = x }, func() { v[i] = nil })
+		}
+	case nil:
+	default:
+		fields = append(fields, &recordField{path: path, value: v, size: encodedSize(v), removed: removed, set: set, remove: remove})
+	}
+	return fields
+}
+
+// largestField returns the index of the field with the longest encoding.
+func largestField(fields []*recordField) int {
+	largest := 0
+	for i, field := range fields {
+		if field.size > fields[largest].size {
+			largest = i
+		}
+	}
+	return largest
+}
+
+// clipString returns s without (at least) its last n bytes, cut at a rune
+// boundary.
+func clipString(s string, n int) string {
+	end := len(s) - n
+	if end < 0 {
+		end = 0
+	}
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end]
+}
+
+// truncateRecord shrinks the JSON-encoded result record until it is at most
+// maxBytes long, by repeatedly clipping the end of its longest string field,
+// or dropping its largest other field if that is not enough. The returned
+// record has "truncated": true, and the paths of the clipped or dropped
+// fields in "truncated_fields". If the record cannot be decoded, it is
+// returned unchanged.
+func truncateRecord(record []byte, maxBytes int) []byte {
+	var root map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(record))
+	decoder.UseNumber()
+	if err := decoder.Decode(&root); err != nil {
+		return record
+	}
+	var truncated []string
+	encode := func() []byte {
+		root["truncated"] = true
+		root["truncated_fields"] = truncated
+		encoded, _ := json.Marshal(root)
+		return encoded
+	}
+	addField := func(path string) bool {
+		for _, field := range truncated {
+			if field == path {
+				return false
+			}
+		}
+		truncated = append(truncated, path)
+		return true
+	}
+
+	// The fields and their sizes are found once; the length of the record
+	// is then tracked as they are clipped or dropped, and only checked by
+	// encoding the record again once it should fit.
+	fields := recordFields(nil, root, "", 0, nil, nil)
+	encoded := encode()
+	for step := 0; len(encoded) > maxBytes && step < maxTruncateSteps && len(fields) > 0; {
+		size := len(encoded)
+		for ; size > maxBytes && step < maxTruncateSteps && len(fields) > 0; step++ {
+			i := largestField(fields)
+			field := fields[i]
+			// The clipped string is followed by a few bytes of slack, so
+			// that escaped characters rarely need a second pass.
+			excess := size - maxBytes
+			if s, ok := field.value.(string); ok && field.size > excess+16 {
+				field.value = clipString(s, excess+16)
+				field.set(field.value)
+				clippedSize := encodedSize(field.value)
+				size -= field.size - clippedSize
+				field.size = clippedSize
+			} else {
+				field.remove()
+				size -= field.size + field.removed
+				fields = append(fields[:i], fields[i+1:]...)
+			}
+			if addField(field.path) {
+				size += encodedSize(field.path) + 1
+			}
+		}
+		encoded = encode()
+	}
+	if len(encoded) > maxBytes {
+		if _, ok := root["data"]; ok {
+			delete(root, "data")
+			addField("data")
+			encoded = encode()
+		}
+	}
+	return encoded
+}
@@ -0,0 +1,118 @@
+package zgrab2
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// decodeRecord decodes an encoded record, keeping numbers as json.Number.
+func decodeRecord(t *testing.T, record []byte) map[string]interface{} {
+	var ret map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(record))
+	decoder.UseNumber()
+	if err := decoder.Decode(&ret); err != nil {
+		t.Fatalf("invalid record %s: %v", record, err)
+	}
+	return ret
+}
+
+func TestTruncateRecord(t *testing.T) {
+	banner := strings.Repeat("é", 5000)
+	record, err := json.Marshal(&Grab{
+		IP: "192.0.2.1",
+		Data: map[string]ScanResponse{
+			"banner": {Status: SCAN_SUCCESS, Protocol: "banner", Result: map[string]interface{}{
+				"banner": banner,
+				"length": uint64(18446744073709551615),
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	truncated := truncateRecord(record, 2048)
+	if len(truncated) > 2048 {
+		t.Errorf("expected at most 2048 bytes, got %d", len(truncated))
+	}
+	decoded := decodeRecord(t, truncated)
+	if decoded["ip"] != "192.0.2.1" || decoded["truncated"] != true {
+		t.Errorf("unexpected truncated record %s", truncated)
+	}
+	if fields := decoded["truncated_fields"]; !reflect.DeepEqual(fields, []interface{}{"data.banner.result.banner"}) {
+		t.Errorf("unexpected truncated_fields %v", fields)
+	}
+	result := decoded["data"].(map[string]interface{})["banner"].(map[string]interface{})["result"].(map[string]interface{})
+	if clipped := result["banner"].(string); clipped == "" || !strings.HasPrefix(banner, clipped) {
+		t.Errorf("expected a clipped banner, got %d bytes", len(clipped))
+	}
+	if result["length"] != json.Number("18446744073709551615") {
+		t.Errorf("expected numbers to be kept exactly, got %v", result["length"])
+	}
+
+	// Records that cannot be shrunk enough lose their data.
+	decoded = decodeRecord(t, truncateRecord(record, 10))
+	if _, ok := decoded["data"]; ok || decoded["truncated"] != true {
+		t.Errorf("expected the data to be dropped, got %v", decoded)
+	}
+}
+
+func TestTruncateRecordManyFields(t *testing.T) {
+	result := make(map[string]interface{})
+	for i := 0; i < 50; i++ {
+		result["field"+strconv.Itoa(i)] = []interface{}{strings.Repeat("v", 200+i), i}
+	}
+	record, err := json.Marshal(&Grab{
+		IP:   "192.0.2.1",
+		Data: map[string]ScanResponse{"banner": {Status: SCAN_SUCCESS, Protocol: "banner", Result: result}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	truncated := truncateRecord(record, 4096)
+	if len(truncated) > 4096 {
+		t.Errorf("expected at most 4096 bytes, got %d", len(truncated))
+	}
+	decoded := decodeRecord(t, truncated)
+	if _, ok := decoded["data"]; !ok {
+		t.Errorf("expected the data to be kept, got %s", truncated)
+	}
+	if fields, _ := decoded["truncated_fields"].([]interface{}); len(fields) == 0 {
+		t.Errorf("expected truncated_fields, got %s", truncated)
+	}
+}
+
+func TestFinishResultTruncatesAfterSignatures(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	signatures, errs := LoadSignatures(strings.NewReader(`[{"name": "long-banner", "regex": "^x{1000}"}]`))
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	config.signatures = signatures
+	config.MaxRecordBytes = 512
+	record, err := json.Marshal(&Grab{
+		IP:   "192.0.2.1",
+		Data: map[string]ScanResponse{"banner": {Status: SCAN_SUCCESS, Protocol: "banner", Result: map[string]interface{}{"banner": strings.Repeat("x", 1000)}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := finishResult(record, &ScanTarget{IP: net.ParseIP("192.0.2.1")})
+	if len(result) > 512 {
+		t.Errorf("expected at most 512 bytes, got %d", len(result))
+	}
+	decoded := decodeRecord(t, result)
+	data, _ := decoded["data"].(map[string]interface{})
+	banner, _ := data["banner"].(map[string]interface{})
+	if signatures, _ := banner["signatures"].([]interface{}); len(signatures) != 1 || signatures[0] != "long-banner" {
+		t.Errorf("expected the signature to match the full banner, got %s", result)
+	}
+}
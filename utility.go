@@ -2,6 +2,8 @@ package zgrab2
 
 import (
 	"errors"
+	"io"
+	"io/ioutil"
 	"net"
 	"regexp"
 	"strconv"
@@ -54,6 +56,9 @@ func ParseCommandLine(flags []string) ([]string, string, ScanFlags, error) {
 		validateFrameworkConfiguration()
 	}
 	sf, _ := f.(ScanFlags)
+	if err == nil && sf != nil {
+		err = ValidateTLSFlags(sf)
+	}
 	return posArgs, moduleType, sf, err
 }
 
@@ -109,8 +114,7 @@ func ReadAvailableWithOptions(conn net.Conn, bufferSize int, readTimeout time.Du
 		// Would be nice if this could be taken from the SetReadDeadline(), but that's not possible in general
 		const defaultTotalTimeout = 1 * time.Second
 		totalTimeout = defaultTotalTimeout
-		timeoutConn, isTimeoutConn := conn.(*TimeoutConnection)
-		if isTimeoutConn {
+		if timeoutConn := GetTimeoutConnection(conn); timeoutConn != nil {
 			totalTimeout = timeoutConn.Timeout
 		}
 	}
@@ -153,6 +157,26 @@ func ReadAvailableWithOptions(conn net.Conn, bufferSize int, readTimeout time.Du
 	return ret, ErrTotalTimeout
 }
 
+// ReadUntilClose reads the response on conn until the server closes the
+// connection, as finger and WHOIS servers do, keeping at most maxSize bytes;
+// truncated is set if the server sent more. If the read fails after part of
+// the response has been received, both are returned; if nothing was
+// received, the error is the read's, or ErrEmptyResponse.
+func ReadUntilClose(conn net.Conn, maxSize int) (data []byte, truncated bool, err error) {
+	data, err = ioutil.ReadAll(io.LimitReader(conn, int64(maxSize)+1))
+	if len(data) == 0 {
+		if err == nil {
+			err = ErrEmptyResponse
+		}
+		return nil, false, err
+	}
+	if len(data) > maxSize {
+		data = data[:maxSize]
+		truncated = true
+	}
+	return data, truncated, err
+}
+
 var InsufficientBufferError = errors.New("not enough buffer space")
 
 // ReadUntilRegex calls connection.Read() until it returns an error, or the cumulatively-read data matches the given regexp
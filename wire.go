@@ -0,0 +1,144 @@
+package zgrab2
+
+import (
+	"encoding/hex"
+	"net"
+	"sync"
+)
+
+// WireCapture holds the raw bytes sent and received on the connections that a
+// single scan opened with ScanTarget.Open, if --capture-wire is set. Each
+// direction is hex-encoded, and holds at most --capture-wire-size bytes
+// across all of the scan's connections.
+type WireCapture struct {
+	// Sent is the bytes sent to the target, hex-encoded.
+	Sent string `json:"sent,omitempty"`
+
+	// Received is the bytes received from the target, hex-encoded.
+	Received string `json:"received,omitempty"`
+
+	// SentTruncated is true if more than --capture-wire-size bytes were
+	// sent, so only the first ones are in Sent.
+	SentTruncated bool `json:"sent_truncated,omitempty"`
+
+	// ReceivedTruncated is true if more than --capture-wire-size bytes were
+	// received, so only the first ones are in Received.
+	ReceivedTruncated bool `json:"received_truncated,omitempty"`
+}
+
+// wireRecorder accumulates a scan's WireCapture.
+type wireRecorder struct {
+	limit int
+
+	mu                sync.Mutex
+	sent              []byte
+	received          []byte
+	sentTruncated     bool
+	receivedTruncated bool
+}
+
+// newWireRecorder returns a wireRecorder keeping up to limit bytes in each
+// direction, or nil if --capture-wire is not set.
+func newWireRecorder(limit int) *wireRecorder {
+	if !config.CaptureWire {
+		return nil
+	}
+	return &wireRecorder{limit: limit}
+}
+
+// record appends as much of b to buf as the limit allows, and returns true if
+// any of b had to be dropped.
+func (recorder *wireRecorder) record(buf *[]byte, b []byte) bool {
+	room := recorder.limit - len(*buf)
+	if room <= 0 {
+		return len(b) > 0
+	}
+	if len(b) > room {
+		*buf = append(*buf, b[:room]...)
+		return true
+	}
+	*buf = append(*buf, b...)
+	return false
+}
+
+// recordSent records bytes sent to the target.
+func (recorder *wireRecorder) recordSent(b []byte) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if recorder.record(&recorder.sent, b) {
+		recorder.sentTruncated = true
+	}
+}
+
+// recordReceived records bytes received from the target.
+func (recorder *wireRecorder) recordReceived(b []byte) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if recorder.record(&recorder.received, b) {
+		recorder.receivedTruncated = true
+	}
+}
+
+// capture returns the WireCapture so far, or nil if nothing was sent or
+// received.
+func (recorder *wireRecorder) capture() *WireCapture {
+	if recorder == nil {
+		return nil
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.sent) == 0 && len(recorder.received) == 0 && !recorder.sentTruncated && !recorder.receivedTruncated {
+		return nil
+	}
+	return &WireCapture{
+		Sent:              hex.EncodeToString(recorder.sent),
+		Received:          hex.EncodeToString(recorder.received),
+		SentTruncated:     recorder.sentTruncated,
+		ReceivedTruncated: recorder.receivedTruncated,
+	}
+}
+
+// wireConn is a net.Conn that passes the bytes sent and received to a
+// wireRecorder.
+type wireConn struct {
+	net.Conn
+	recorder *wireRecorder
+}
+
+// Read reads from the underlying connection, recording the bytes read.
+func (conn *wireConn) Read(b []byte) (int, error) {
+	n, err := conn.Conn.Read(b)
+	if n > 0 {
+		conn.recorder.recordReceived(b[:n])
+	}
+	return n, err
+}
+
+// Write writes to the underlying connection, recording the bytes written.
+func (conn *wireConn) Write(b []byte) (int, error) {
+	n, err := conn.Conn.Write(b)
+	if n > 0 {
+		conn.recorder.recordSent(b[:n])
+	}
+	return n, err
+}
+
+// Unwrap returns the underlying connection; see GetTimeoutConnection.
+func (conn *wireConn) Unwrap() net.Conn {
+	return conn.Conn
+}
+
+// CloseWrite shuts down the writing side of the underlying connection, if it
+// can be half-closed.
+func (conn *wireConn) CloseWrite() error {
+	return closeWrite(conn.Conn)
+}
+
+// captureWire wraps conn so that its traffic is recorded in the current scan's
+// WireCapture, if --capture-wire is set.
+func (target *ScanTarget) captureWire(conn net.Conn) net.Conn {
+	if target.wire == nil {
+		return conn
+	}
+	return &wireConn{Conn: conn, recorder: target.wire}
+}
@@ -0,0 +1,94 @@
+package zgrab2
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWireCapture(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config.CaptureWire = true
+
+	target := ScanTarget{wire: newWireRecorder(8)}
+	client := new(bytes.Buffer)
+	conn := target.captureWire(NewReplayConn(strings.NewReader("+PONG\r\n"), client))
+	conn.Write([]byte("PING\r\n"))
+	response, err := ioutil.ReadAll(conn)
+	if err != nil || string(response) != "+PONG\r\n" {
+		t.Fatalf("unexpected response %q, %v", response, err)
+	}
+	// A second connection shares the scan's capture, up to the limit.
+	conn = target.captureWire(NewReplayConn(strings.NewReader("+OK\r\n"), client))
+	conn.Write([]byte("QUIT\r\n"))
+	ioutil.ReadAll(conn)
+	if client.String() != "PING\r\nQUIT\r\n" {
+		t.Errorf("expected the bytes to be passed through, got %q", client.String())
+	}
+
+	expected := &WireCapture{
+		Sent:              "50494e470d0a5155", // PING\r\nQU
+		Received:          "2b504f4e470d0a2b", // +PONG\r\n+
+		SentTruncated:     true,
+		ReceivedTruncated: true,
+	}
+	if capture := target.wire.capture(); !reflect.DeepEqual(capture, expected) {
+		t.Errorf("expected %+v, got %+v", expected, capture)
+	}
+}
+
+func TestWireCaptureDisabled(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config.CaptureWire = false
+
+	target := ScanTarget{wire: newWireRecorder(8)}
+	conn := NewReplayConn(strings.NewReader(""), nil)
+	if wrapped := target.captureWire(conn); wrapped != conn {
+		t.Errorf("expected the connection to be returned unwrapped, got %T", wrapped)
+	}
+	if capture := target.wire.capture(); capture != nil {
+		t.Errorf("expected no capture, got %+v", capture)
+	}
+}
+
+func TestWireCaptureUnwrap(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	// The server echoes what it receives until the client half-closes.
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := ioutil.ReadAll(conn)
+		conn.Write(data)
+	}()
+	inner, err := DialTimeoutConnectionContext(context.Background(), "tcp", listener.Addr().String(), time.Second, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := ScanTarget{wire: newWireRecorder(64)}
+	conn := target.captureWire(inner)
+	defer conn.Close()
+	if timeoutConn := GetTimeoutConnection(conn); timeoutConn != inner {
+		t.Fatalf("expected the wrapped *TimeoutConnection, got %v", timeoutConn)
+	}
+	conn.Write([]byte("hello"))
+	if err := conn.(interface{ CloseWrite() error }).CloseWrite(); err != nil {
+		t.Fatalf("CloseWrite failed: %v", err)
+	}
+	if data, err := ioutil.ReadAll(conn); err != nil || string(data) != "hello" {
+		t.Errorf("expected the echo after the half-close, got %q, %v", data, err)
+	}
+}
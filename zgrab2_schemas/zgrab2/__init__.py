@@ -21,3 +21,22 @@ from . import ssh
 from . import telnet
 from . import ipp
 from . import banner
+from . import ipmi
+from . import rtsp
+from . import sip
+from . import xmpp
+from . import irc
+from . import docker
+from . import etcd
+from . import kubernetes
+from . import cassandra
+from . import influxdb
+from . import rsync
+from . import coap
+from . import prometheus
+from . import whois
+from . import finger
+from . import rpcbind
+from . import mssql_browser
+from . import zookeeper
+from . import memcached_amp
@@ -19,6 +19,7 @@ bacnet_scan_response = SubRecord({
         "model_name": String(),
         "description": String(),
         "location": String(),
+        "segmented": Boolean(),
     })
 }, extends=zgrab2.base_scan_response)
 
@@ -11,7 +11,25 @@ from . import zgrab2
 banner_scan_response = SubRecord({
     "result": SubRecord({
         "banner": String(),
-        "length": Unsigned32BitInteger()
+        "length": Unsigned32BitInteger(),
+        "raw": Binary(),
+        "hex": String(),
+        "printable": String(),
+        "matched_probe": String(),
+        "probes": ListOf(SubRecord({
+            "name": String(),
+            "length": Unsigned32BitInteger(),
+            "raw": Binary(),
+            "hex": String(),
+            "printable": String(),
+            "matched": Boolean(),
+            "error": String(),
+        })),
+        "connect": SubRecord({
+            "outcome": Enum(values=["success", "refused", "timeout", "unreachable", "reset", "error"]),
+            "latency_us": Unsigned32BitInteger(doc="The time taken to connect or fail, in microseconds."),
+            "error": String(),
+        }, doc="The outcome of the connection, with --connect-only; otherwise, absent."),
     })
 }, extends=zgrab2.base_scan_response)
 
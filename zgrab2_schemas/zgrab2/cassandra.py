@@ -0,0 +1,35 @@
+# zschema sub-schema for zgrab2's cassandra module
+# Registers zgrab2-cassandra globally, and cassandra with the main zgrab2 schema.
+from zschema.leaves import *
+from zschema.compounds import *
+import zschema.registry
+
+import zcrypto_schemas.zcrypto as zcrypto
+from . import zgrab2
+
+# modules/cassandra/cassandra.go: Error
+cassandra_error = SubRecord({
+    'code': Signed32BitInteger(),
+    'message': String(),
+})
+
+cassandra_scan_response = SubRecord({
+    'result': SubRecord({
+        'protocol_version': Unsigned8BitInteger(),
+        'protocol_errors': ListOf(String()),
+        # map[string][]string, keyed by option name
+        'supported': SubRecord({}),  # TODO FIXME: unconstrained dict
+        'cql_versions': ListOf(String()),
+        'compression': ListOf(String()),
+        'protocol_versions': ListOf(String()),
+        'startup_response': Enum(values=['ready', 'authenticate', 'error']),
+        'auth_required': Boolean(),
+        'authenticator': String(),
+        'startup_error': cassandra_error,
+        'product': Enum(values=['cassandra', 'scylladb']),
+    })
+}, extends=zgrab2.base_scan_response)
+
+zschema.registry.register_schema('zgrab2-cassandra', cassandra_scan_response)
+
+zgrab2.register_scan_response_type('cassandra', cassandra_scan_response)
@@ -0,0 +1,35 @@
+# zschema sub-schema for zgrab2's coap module
+# Registers zgrab2-coap globally, and coap with the main zgrab2 schema.
+from zschema.leaves import *
+from zschema.compounds import *
+import zschema.registry
+
+import zcrypto_schemas.zcrypto as zcrypto
+from . import zgrab2
+
+# modules/coap/coap.go: Link
+coap_link = SubRecord({
+    'path': String(),
+    'resource_types': ListOf(String()),
+    'interfaces': ListOf(String()),
+    'content_format': String(),
+    'title': String(),
+    # map[string]string, keyed by attribute name
+    'attributes': SubRecord({}),  # TODO FIXME: unconstrained dict
+})
+
+coap_scan_response = SubRecord({
+    'result': SubRecord({
+        'message_type': Enum(values=['CON', 'NON', 'ACK', 'RST']),
+        'code': String(),
+        'code_name': String(),
+        'content_format': Unsigned32BitInteger(),
+        'link_format': String(),
+        'resources': ListOf(coap_link),
+        'resource_types': ListOf(String()),
+    })
+}, extends=zgrab2.base_scan_response)
+
+zschema.registry.register_schema('zgrab2-coap', coap_scan_response)
+
+zgrab2.register_scan_response_type('coap', coap_scan_response)
@@ -0,0 +1,55 @@
+# zschema sub-schema for zgrab2's docker module
+# Registers zgrab2-docker globally, and docker with the main zgrab2 schema.
+from zschema.leaves import *
+from zschema.compounds import *
+import zschema.registry
+
+import zcrypto_schemas.zcrypto as zcrypto
+from . import zgrab2
+from . import http
+
+# modules/docker/docker.go: VersionInfo
+docker_version = SubRecord({
+    'version': String(),
+    'api_version': String(),
+    'min_api_version': String(),
+    'git_commit': String(),
+    'go_version': String(),
+    'os': String(),
+    'arch': String(),
+    'kernel_version': String(),
+    'build_time': String(),
+})
+
+# modules/docker/docker.go: Info
+docker_info = SubRecord({
+    'id': String(),
+    'name': String(),
+    'server_version': String(),
+    'operating_system': String(),
+    'os_type': String(),
+    'architecture': String(),
+    'kernel_version': String(),
+    'containers': Unsigned32BitInteger(),
+    'containers_running': Unsigned32BitInteger(),
+    'images': Unsigned32BitInteger(),
+    'ncpu': Unsigned32BitInteger(),
+    'mem_total': Signed64BitInteger(),
+    'docker_root_dir': String(),
+})
+
+docker_scan_response = SubRecord({
+    'result': SubRecord({
+        'version_response': http.http_response_full,
+        'info_response': http.http_response_full,
+        'version': docker_version,
+        'info': docker_info,
+        'auth_required': Boolean(),
+        'unauthenticated_access': Boolean(doc='True if /info returned container and image counts without credentials.'),
+        'tls': zgrab2.tls_log,
+    })
+}, extends=zgrab2.base_scan_response)
+
+zschema.registry.register_schema('zgrab2-docker', docker_scan_response)
+
+zgrab2.register_scan_response_type('docker', docker_scan_response)
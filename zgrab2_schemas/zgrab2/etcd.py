@@ -0,0 +1,38 @@
+# zschema sub-schema for zgrab2's etcd module
+# Registers zgrab2-etcd globally, and etcd with the main zgrab2 schema.
+from zschema.leaves import *
+from zschema.compounds import *
+import zschema.registry
+
+import zcrypto_schemas.zcrypto as zcrypto
+from . import zgrab2
+from . import http
+
+# modules/etcd/etcd.go: APIResult
+etcd_api_result = SubRecord({
+    'endpoint': String(),
+    'status_code': Unsigned16BitInteger(),
+    'auth_required': Boolean(),
+    'readable': Boolean(),
+    'key_count': Signed64BitInteger(),
+    'keys': ListOf(String()),
+    'error': String(),
+})
+
+etcd_scan_response = SubRecord({
+    'result': SubRecord({
+        'version_response': http.http_response_full,
+        'server_version': String(),
+        'cluster_version': String(),
+        'v3': etcd_api_result,
+        'v2': etcd_api_result,
+        'api_versions': ListOf(Enum(values=['v3', 'v2'])),
+        'auth_enabled': Boolean(),
+        'readable_without_auth': Boolean(doc='True if keys could be listed without credentials.'),
+        'tls': zgrab2.tls_log,
+    })
+}, extends=zgrab2.base_scan_response)
+
+zschema.registry.register_schema('zgrab2-etcd', etcd_scan_response)
+
+zgrab2.register_scan_response_type('etcd', etcd_scan_response)
@@ -0,0 +1,20 @@
+# zschema sub-schema for zgrab2's finger module
+# Registers zgrab2-finger globally, and finger with the main zgrab2 schema.
+from zschema.leaves import *
+from zschema.compounds import *
+import zschema.registry
+
+import zcrypto_schemas.zcrypto as zcrypto
+from . import zgrab2
+
+finger_scan_response = SubRecord({
+    'result': SubRecord({
+        'query': String(),
+        'response': String(),
+        'truncated': Boolean(),
+    })
+}, extends=zgrab2.base_scan_response)
+
+zschema.registry.register_schema('zgrab2-finger', finger_scan_response)
+
+zgrab2.register_scan_response_type('finger', finger_scan_response)
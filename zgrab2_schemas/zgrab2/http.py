@@ -159,12 +159,38 @@ http_response_full = SubRecord({
     "headers": http_headers,
     "body": String(),
     "body_sha256": Binary(),
+    "body_compression": Enum(values=["gzip", "deflate"]),
+    "body_compressed": Binary(),
     "content_length": Signed64BitInteger(),
     "transfer_encoding": ListOf(String()),
     "trailers": http_headers,
     "request": http_request_full
 })
 
+# modules/http/websocket.go: WebSocketResults
+http_websocket = SubRecord({
+    "url": String(),
+    "status_code": Signed32BitInteger(),
+    "upgraded": Boolean(),
+    "accept": String(),
+    "accept_valid": Boolean(),
+    "subprotocol": String(),
+    "extensions": ListOf(String()),
+    "response": http_response_full,
+    "error": String(),
+})
+
+# modules/http/auth.go: AuthResults
+http_auth = SubRecord({
+    "scheme": String(),
+    "challenge": String(),
+    "unauthenticated_status_code": Signed32BitInteger(),
+    "status_code": Signed32BitInteger(),
+    "status_changed": Boolean(),
+    "response": http_response_full,
+    "error": String(),
+})
+
 # modules/http.go: HTTPResults
 http_scan_response = SubRecord({
     "result": SubRecord({
@@ -172,6 +198,10 @@ http_scan_response = SubRecord({
         "connect_response": http_response,
         "response": http_response_full,
         "redirect_response_chain": ListOf(http_response_full),
+        "websocket": http_websocket,
+        "auth": http_auth,
+        "invalid_response": Binary(),
+        "http09": Boolean(),
     })
 }, extends=zgrab2.base_scan_response)
 
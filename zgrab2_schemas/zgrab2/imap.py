@@ -11,6 +11,8 @@ imap_scan_response = SubRecord({
     "result": SubRecord({
         "banner": String(doc="The IMAP banner."),
         "starttls": String(doc="The server's response to the STARTTLS command."),
+        "capability": String(doc="The server's response to the CAPABILITY command."),
+        "auth_mechanisms": ListOf(String(), doc="The SASL mechanisms advertised in the server's capabilities."),
         "close": String(doc="The server's response to the CLOSE command."),
         "tls": zgrab2.tls_log,
     })
@@ -0,0 +1,37 @@
+# zschema sub-schema for zgrab2's influxdb module
+# Registers zgrab2-influxdb globally, and influxdb with the main zgrab2 schema.
+from zschema.leaves import *
+from zschema.compounds import *
+import zschema.registry
+
+import zcrypto_schemas.zcrypto as zcrypto
+from . import zgrab2
+from . import http
+
+# modules/influxdb/influxdb.go: Health
+influxdb_health = SubRecord({
+    'name': String(),
+    'message': String(),
+    'status': String(),
+    'version': String(),
+    'commit': String(),
+})
+
+influxdb_scan_response = SubRecord({
+    'result': SubRecord({
+        'ping_response': http.http_response_full,
+        'health_response': http.http_response_full,
+        'query_response': http.http_response_full,
+        'version': String(),
+        'build': String(),
+        'health': influxdb_health,
+        'auth_required': Boolean(),
+        'unauthenticated_access': Boolean(doc='True if SHOW DATABASES succeeded without credentials.'),
+        'databases': ListOf(String()),
+        'tls': zgrab2.tls_log,
+    })
+}, extends=zgrab2.base_scan_response)
+
+zschema.registry.register_schema('zgrab2-influxdb', influxdb_scan_response)
+
+zgrab2.register_scan_response_type('influxdb', influxdb_scan_response)
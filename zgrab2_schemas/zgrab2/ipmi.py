@@ -0,0 +1,42 @@
+# zschema sub-schema for zgrab2's ipmi module
+# Registers zgrab2-ipmi globally, and ipmi with the main zgrab2 schema.
+from zschema.leaves import *
+from zschema.compounds import *
+import zschema.registry
+
+import zcrypto_schemas.zcrypto as zcrypto
+from . import zgrab2
+
+auth_capabilities = SubRecord({
+    'channel_number': Unsigned8BitInteger(),
+    'auth_types': ListOf(Enum(values=['none', 'md2', 'md5', 'password', 'oem'])),
+    'extended_capabilities': Boolean(),
+    'kg_enabled': Boolean(),
+    'per_message_auth_disabled': Boolean(),
+    'user_level_auth_disabled': Boolean(),
+    'non_null_usernames_enabled': Boolean(),
+    'null_usernames_enabled': Boolean(),
+    'anonymous_login_enabled': Boolean(),
+    'supports_ipmi_v1_5': Boolean(),
+    'supports_ipmi_v2_0': Boolean(),
+    'oem_id': Unsigned32BitInteger(),
+    'oem_aux_data': Unsigned8BitInteger(),
+    'raw': Binary(),
+})
+
+ipmi_scan_response = SubRecord({
+    'result': SubRecord({
+        'is_ipmi': Boolean(),
+        'completion_code': Unsigned8BitInteger(),
+        'ipmi_version': Enum(values=['1.5', '2.0']),
+        'capabilities': auth_capabilities,
+        'null_auth': Boolean(),
+        'anonymous_login': Boolean(),
+        'cipher_zero': Boolean(),
+        'open_session_status': Unsigned8BitInteger(),
+    })
+}, extends=zgrab2.base_scan_response)
+
+zschema.registry.register_schema('zgrab2-ipmi', ipmi_scan_response)
+
+zgrab2.register_scan_response_type('ipmi', ipmi_scan_response)
@@ -0,0 +1,46 @@
+# zschema sub-schema for zgrab2's kubernetes module
+# Registers zgrab2-kubernetes globally, and kubernetes with the main zgrab2 schema.
+from zschema.leaves import *
+from zschema.compounds import *
+import zschema.registry
+
+import zcrypto_schemas.zcrypto as zcrypto
+from . import zgrab2
+from . import http
+
+# modules/kubernetes/kubernetes.go: EndpointResult
+kubernetes_endpoint_result = SubRecord({
+    'status_code': Unsigned16BitInteger(),
+    'kind': String(),
+    'anonymous': Boolean(),
+    'item_count': Unsigned32BitInteger(),
+    'versions': ListOf(String()),
+})
+
+kubernetes_scan_response = SubRecord({
+    'result': SubRecord({
+        'version_response': http.http_response_full,
+        'version': SubRecord({
+            'major': String(),
+            'minor': String(),
+            'git_version': String(),
+            'git_commit': String(),
+            'git_tree_state': String(),
+            'build_date': String(),
+            'go_version': String(),
+            'compiler': String(),
+            'platform': String(),
+        }),
+        'healthz_status': Unsigned16BitInteger(),
+        'healthz': String(),
+        'api': kubernetes_endpoint_result,
+        'pods': kubernetes_endpoint_result,
+        'running_pods': kubernetes_endpoint_result,
+        'anonymous_access': Boolean(doc='True if /api, /pods or /runningpods answered without credentials.'),
+        'tls': zgrab2.tls_log,
+    })
+}, extends=zgrab2.base_scan_response)
+
+zschema.registry.register_schema('zgrab2-kubernetes', kubernetes_scan_response)
+
+zgrab2.register_scan_response_type('kubernetes', kubernetes_scan_response)
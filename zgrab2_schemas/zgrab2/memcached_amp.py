@@ -0,0 +1,26 @@
+# zschema sub-schema for zgrab2's memcached-amp module
+# Registers zgrab2-memcached-amp globally, and memcached-amp with the main zgrab2 schema.
+from zschema.leaves import *
+from zschema.compounds import *
+import zschema.registry
+
+import zcrypto_schemas.zcrypto as zcrypto
+from . import zgrab2
+
+memcached_amp_scan_response = SubRecord({
+    'result': SubRecord({
+        'request_size': Unsigned32BitInteger(doc='The size of the stats request\'s UDP payload, in bytes.'),
+        'response_size': Unsigned32BitInteger(doc='The total size of the UDP payloads of the response datagrams received, in bytes.'),
+        'datagrams': Unsigned32BitInteger(doc='The number of response datagrams received.'),
+        'amplification': Float(doc='response_size divided by request_size.'),
+        'amplifier': Boolean(doc='True if the response is larger than the request.'),
+        'complete': Boolean(doc='True if every datagram of the response was received.'),
+        'truncated': Boolean(doc='True if the reading stopped after --max-response-size bytes.'),
+        'version': String(),
+        'stats': SubRecord({}),  # TODO FIXME: unconstrained dict
+    })
+}, extends=zgrab2.base_scan_response)
+
+zschema.registry.register_schema('zgrab2-memcached-amp', memcached_amp_scan_response)
+
+zgrab2.register_scan_response_type('memcached-amp', memcached_amp_scan_response)
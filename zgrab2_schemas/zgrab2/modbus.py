@@ -37,6 +37,13 @@ exception_response = SubRecord({
     'exception_type': Unsigned8BitInteger(),
 })
 
+holding_registers = SubRecord({
+    'start_address': Unsigned16BitInteger(),
+    'values': ListOf(Unsigned16BitInteger()),
+    'exception_response': exception_response,
+    'raw': Binary(),
+})
+
 modbus_scan_response = SubRecord({
     'result': SubRecord({
         'length': Unsigned16BitInteger(),
@@ -46,6 +53,8 @@ modbus_scan_response = SubRecord({
         'mei_response': mei_response,
         'exception_response': exception_response,
         'raw': Binary(),
+        'request_unit_id': Unsigned8BitInteger(),
+        'holding_registers': holding_registers,
     })
 }, extends=zgrab2.base_scan_response)
 
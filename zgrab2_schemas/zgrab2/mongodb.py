@@ -31,7 +31,21 @@ mongodb_scan_response = SubRecord({
             "max_write_batch_size": Signed32BitInteger(),
             "logical_session_timeout_minutes": Signed32BitInteger(),
             "max_message_size_bytes": Signed32BitInteger(),
-            "read_only": Boolean()})})
+            "read_only": Boolean()}),
+        "auth_required": Boolean(doc="True if the server rejected the listDatabases command sent without credentials as unauthorized, false if it listed its databases; absent if the command failed otherwise."),
+        "list_databases": SubRecord({
+            "databases": ListOf(SubRecord({
+                "name": String(),
+                "size_on_disk": Signed64BitInteger(),
+                "empty": Boolean(),
+            }), doc="The first --max-databases databases listed."),
+            "total_databases": Unsigned32BitInteger(doc="The number of databases listed, including any left out of databases."),
+            "databases_truncated": Boolean(doc="True if some databases were left out."),
+            "total_size": Signed64BitInteger(doc="The total size on disk of the databases, in bytes."),
+            "error": String(doc="The error message, if the command was rejected."),
+            "error_code": Signed32BitInteger(),
+            "error_code_name": String(),
+        })})
 }, extends=zgrab2.base_scan_response)
 
 zschema.registry.register_schema("zgrab2-mongodb", mongodb_scan_response)
@@ -42,7 +42,17 @@ mssql_scan_response = SubRecord({
         "instance_name": WhitespaceAnalyzedString(),
         "prelogin_options": prelogin_options,
         "encrypt_mode": Enum(values=ENCRYPT_MODES, doc="The negotiated ENCRYPT_MODE with the server."),
+        "encryption": Enum(values=["full", "login_only", "none"], doc="How much of the session the negotiated ENCRYPT_MODE encrypts: everything, only the login, or nothing."),
         "tls": zgrab2.tls_log,
+        "login": SubRecord({
+            "success": Boolean(doc="True if the server acknowledged the login."),
+            "tds_version": String(doc="The TDS version the server agreed to, e.g. 7.4."),
+            "program_name": String(doc="The server program name in the LOGINACK."),
+            "program_version": String(doc="The server program version in the LOGINACK, as MAJOR.MINOR.BUILD_NUMBER."),
+            "database": String(doc="The database the session was switched to."),
+            "error_number": Signed32BitInteger(doc="The number of the ERROR token, if the login failed, e.g. 18456."),
+            "error_message": String(doc="The message of the ERROR token, if the login failed."),
+        }, doc="The result of the LOGIN7 request, if --login was set."),
     })
 }, extends=zgrab2.base_scan_response)
 
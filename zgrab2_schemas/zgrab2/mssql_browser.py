@@ -0,0 +1,30 @@
+# zschema sub-schema for zgrab2's mssql-browser module
+# Registers zgrab2-mssql-browser globally, and mssql-browser with the main zgrab2 schema.
+from zschema.leaves import *
+from zschema.compounds import *
+import zschema.registry
+
+import zcrypto_schemas.zcrypto as zcrypto
+from . import zgrab2
+
+# modules/mssqlbrowser/ssrp.go: Instance
+mssql_browser_instance = SubRecord({
+    'server_name': String(),
+    'instance_name': String(),
+    'is_clustered': Boolean(),
+    'version': String(),
+    'tcp_port': Unsigned16BitInteger(),
+    'named_pipe': String(),
+    'other': SubRecord({}),  # TODO FIXME: unconstrained dict
+})
+
+mssql_browser_scan_response = SubRecord({
+    'result': SubRecord({
+        'instances': ListOf(mssql_browser_instance),
+        'response': String(),
+    })
+}, extends=zgrab2.base_scan_response)
+
+zschema.registry.register_schema('zgrab2-mssql-browser', mssql_browser_scan_response)
+
+zgrab2.register_scan_response_type('mssql-browser', mssql_browser_scan_response)
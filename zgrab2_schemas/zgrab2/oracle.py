@@ -73,8 +73,31 @@ oracle_scan_response = SubRecord({
             "nsn_service_versions": SubRecord({
                 service: WhitespaceAnalyzedString() for service in nsn_services
             }, doc="A map from the native Service Negotation service names to the ReleaseVersion (in dotted-decimal format) in that service packet."),
+            "nsn_services": ListOf(SubRecord({
+                "type": WhitespaceAnalyzedString(doc="The name of the service type.", examples=nsn_services + ["Unknown(0x5)"]),
+                "marker": Unsigned32BitInteger(doc="The service's marker; omitted if zero."),
+                "values": ListOf(SubRecord({
+                    "type": Unsigned16BitInteger(doc="The type of the value: 0 = string, 1 = bytes, 2 = UB1, 3 = UB2, 4 = UB4, 5 = version, 6 = status."),
+                    "value": SubRecord({}),  # TODO FIXME: the value's JSON type depends on the type
+                }), doc="The service's values, as type / value pairs."),
+            }), doc="The services in the server's Native Service Negotiation response, with the options that it returned for each."),
+            "checksum_mismatches": ListOf(SubRecord({
+                "packet_type": WhitespaceAnalyzedString(doc="The type of the packet containing the mismatched checksum.", examples=["ACCEPT", "DATA"]),
+                "field": Enum(values=["packet", "header"], doc="The checksum that did not match."),
+                "received": Unsigned16BitInteger(doc="The checksum value sent by the server."),
+                "computed": Unsigned16BitInteger(doc="The checksum value computed by the client."),
+            }), doc="The non-zero checksums in the server's packets that did not match the computed values. Only present with --verify-checksums."),
         }, doc="The log of the Oracle / TDS handshake process."),
+        "ping": SubRecord({
+            "response_type": WhitespaceAnalyzedString(doc="The type of the packet the listener sent in response to the ping Connect packet.", examples=["REFUSE", "ACCEPT"]),
+            "refuse_error_raw": WhitespaceAnalyzedString(doc="The data from the Refuse packet returned by the listener, if it sent one.", examples=[
+                "(DESCRIPTION=(TMP=)(VSNNUM=186647040)(ERR=0)(ALIAS=LISTENER))"
+            ]),
+            "refuse_version": WhitespaceAnalyzedString(doc="The parsed DESCRIPTION.VSNNUM field from the Refuse packet, in dotted-decimal format.", examples=["11.2.0.2.0"]),
+        }, doc="The log of the TNS ping, with --ping. Omitted otherwise."),
         "tls": zgrab2.tls_log,
+        "raw_packets": ListOf(String(), doc="The raw bytes (in hex) of each packet read from the server, with --raw-packets and --debug."),
+        "raw_packets_truncated": Boolean(doc="True if the packets read from the server exceeded 16KiB, so that raw_packets were cut short."),
     })
 }, extends=zgrab2.base_scan_response)
 
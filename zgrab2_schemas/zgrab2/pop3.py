@@ -12,6 +12,8 @@ pop3_scan_response = SubRecord({
         "banner": String(doc="The POP3 banner."),
         "noop": String(doc="The server's response to the NOOP command."),
         "help": String(doc="The server's response to the HELP command."),
+        "capa": String(doc="The server's response to the CAPA command."),
+        "auth_mechanisms": ListOf(String(), doc="The SASL mechanisms advertised in the server's response to the CAPA command."),
         "starttls": String(doc="The server's response to the STARTTLS command."),
         "quit": String(doc="The server's response to the QUIT command."),
         "tls": zgrab2.tls_log,
@@ -65,6 +65,9 @@ postgres_scan_response = SubRecord({
         "server_parameters": WhitespaceAnalyzedString(),
         "backend_key_data": postgres_key_data,
         "transaction_status": WhitespaceAnalyzedString(),
+        "server_version": WhitespaceAnalyzedString(),
+        "product": Enum(values=["PostgreSQL", "CockroachDB"]),
+        "product_version": String(),
     })
 }, extends=zgrab2.base_scan_response)
 
@@ -0,0 +1,42 @@
+# zschema sub-schema for zgrab2's prometheus module
+# Registers zgrab2-prometheus globally, and prometheus with the main zgrab2 schema.
+from zschema.leaves import *
+from zschema.compounds import *
+import zschema.registry
+
+import zcrypto_schemas.zcrypto as zcrypto
+from . import zgrab2
+from . import http
+
+# modules/prometheus/prometheus.go: BuildInfo
+prometheus_build_info = SubRecord({
+    'metric': String(),
+    # map[string]string, keyed by label name
+    'labels': SubRecord({}),  # TODO FIXME: unconstrained dict
+})
+
+# modules/prometheus/prometheus.go: Metrics
+prometheus_metrics = SubRecord({
+    'samples': Unsigned32BitInteger(),
+    'process_start_time_seconds': Double(),
+    'go_version': String(),
+    'build_info': ListOf(prometheus_build_info),
+})
+
+prometheus_scan_response = SubRecord({
+    'result': SubRecord({
+        'metrics_response': http.http_response_full,
+        'healthy_response': http.http_response_full,
+        'metrics_exposed': Boolean(doc='True if /metrics returned metrics without credentials.'),
+        'auth_required': Boolean(),
+        'metrics_size': Unsigned32BitInteger(),
+        'metrics_truncated': Boolean(),
+        'metrics': prometheus_metrics,
+        'healthy': Boolean(),
+        'tls': zgrab2.tls_log,
+    })
+}, extends=zgrab2.base_scan_response)
+
+zschema.registry.register_schema('zgrab2-prometheus', prometheus_scan_response)
+
+zgrab2.register_scan_response_type('prometheus', prometheus_scan_response)
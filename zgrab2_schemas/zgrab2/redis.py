@@ -11,6 +11,10 @@ redis_scan_response = SubRecord({
     "result": SubRecord({
         "commands": ListOf(String(), doc="The list of commands actually sent to the server, serialized in inline format, like 'PING' or 'AUTH somePassword'."),
         "raw_command_output": ListOf(Binary(), doc="The raw output returned by the server for each command sent; the indices match those of commands."),
+        "tls": zgrab2.tls_log,
+        "tls_version": String(doc="The TLS version negotiated, if --use-tls is set."),
+        "tls_cipher_suite": String(doc="The TLS cipher suite negotiated, if --use-tls is set."),
+        "unix_socket": String(doc="The path of the Unix domain socket the probe was sent over, if --unix-socket was set."),
         "ping_response": String(doc="The response from the PING command; should either be \"PONG\" or an authentication error.", examples=[
             "PONG",
             "(Error: NOAUTH Authentication required.)",
@@ -19,11 +23,16 @@ redis_scan_response = SubRecord({
             "# Server\r\nredis_version:4.0.7\r\nkey2:value2\r\n",
             "(Error: NOAUTH Authentication required.)",
         ]),
+        "multibulk_ping_response": String(doc="With --inline, the response to PING sent as a multibulk array, if it differs from the response to the inline PING."),
         "auth_response": String(doc="The response from the AUTH command, if sent."),
-        "nonexistent_response": String(doc="The response from the NONEXISTENT command.", examples=[
-            "(Error: ERR unknown command 'NONEXISTENT')",
+        "nonexistent_command": String(doc="The non-existent command that was sent: a random token, unless a mapping for NONEXISTENT was given.", examples=[
+            "QZKWBNRTAXJE",
+        ]),
+        "nonexistent_response": String(doc="The response from the non-existent command.", examples=[
+            "(Error: ERR unknown command 'QZKWBNRTAXJE')",
         ]),
         "quit_response": String(doc="The response to the QUIT command.", examples=["OK"]),
+        "classification": Enum(values=["redis", "possible_redis", "not_redis"], doc="Whether the server is redis, judged from the replies to PING and the non-existent command, if --detect-only is set."),
         "version": String(doc="The version string, read from the the info_response (if available)."),
         "major": Unsigned32BitInteger(doc="Major is the version's major number."),
         "minor": Unsigned32BitInteger(doc="Minor is the version's minor number."),
@@ -44,6 +53,53 @@ redis_scan_response = SubRecord({
             "arguments": String(doc="The arguments portion of the command sent."),
             "response": String(doc="The response from the sent command and arguments."),
         }), doc="The responses from the user-passed custom commands."),
+        "eval_enabled": Boolean(doc="True if the server ran the harmless script sent with EVAL, if --eval is set."),
+        "eval_response": String(doc="The response to the EVAL command, if --eval is set."),
+        "sampled_keys": ListOf(SubRecord({
+            "name": String(doc="The key's name."),
+            "type": String(doc="The key's type, from the TYPE command."),
+        }), doc="The keys sampled with SCAN, if --scan-keys is set."),
+        "scan_keys_error": String(doc="The error returned by the server for SCAN, if any."),
+        "sentinel_masters": ListOf(SubRecord({
+            "name": String(doc="The name the master is monitored under."),
+            "ip": String(doc="The master's IP address."),
+            "port": Unsigned32BitInteger(doc="The master's port."),
+            "flags": String(doc="The Sentinel's view of the master, e.g. master,s_down."),
+            "quorum": Unsigned32BitInteger(doc="The number of Sentinels that must agree that the master is down."),
+            "num_slaves": Unsigned32BitInteger(doc="The number of replicas known for the master."),
+            "num_other_sentinels": Unsigned32BitInteger(doc="The number of other Sentinels known for the master."),
+        }), doc="The masters monitored by the server, from SENTINEL masters, if it is a Sentinel."),
+        "sentinel_error": String(doc="The error returned by a Sentinel for SENTINEL masters, if any."),
+        "latency_events": ListOf(SubRecord({
+            "event": String(doc="The name of the event, e.g. command or fork."),
+            "timestamp": Signed64BitInteger(doc="The Unix time of the latest latency spike."),
+            "latest_ms": Signed64BitInteger(doc="The latest latency, in milliseconds."),
+            "max_ms": Signed64BitInteger(doc="The all-time maximum latency, in milliseconds."),
+        }), doc="The events with latency spikes, from LATENCY LATEST, if --admin is set."),
+        "latency_error": String(doc="The error returned by the server for LATENCY LATEST, if any."),
+        "slowlog_entries": ListOf(SubRecord({
+            "id": Signed64BitInteger(),
+            "timestamp": Signed64BitInteger(doc="The Unix time the command was run."),
+            "duration_us": Signed64BitInteger(doc="The time the command took to run, in microseconds."),
+            "command": ListOf(String(), doc="The command and its arguments (redacted with --redact)."),
+            "client_addr": String(doc="The address of the client that sent the command (redacted with --redact)."),
+            "client_name": String(doc="The name of the client that sent the command (redacted with --redact)."),
+        }), doc="The latest entries of the slow log, from SLOWLOG GET 10, if --admin is set."),
+        "slowlog_error": String(doc="The error returned by the server for SLOWLOG GET, if any."),
+        "clients": ListOf(SubRecord({
+            "id": Unsigned64BitInteger(),
+            "addr": String(doc="The client's address (redacted with --redact)."),
+            "laddr": String(doc="The server's address for the connection (redacted with --redact)."),
+            "name": String(doc="The client's name (redacted with --redact)."),
+            "user": String(doc="The ACL user the client is authenticated as."),
+            "age": Unsigned32BitInteger(doc="The connection's age, in seconds."),
+            "idle": Unsigned32BitInteger(doc="The connection's idle time, in seconds."),
+            "flags": String(),
+            "db": Unsigned32BitInteger(),
+            "cmd": String(doc="The last command the client ran."),
+        }), doc="The first 100 client connections, from CLIENT LIST, if --admin is set."),
+        "client_count": Unsigned32BitInteger(doc="The number of client connections listed by CLIENT LIST."),
+        "client_list_error": String(doc="The error returned by the server for CLIENT LIST, if any."),
     })
 }, extends=zgrab2.base_scan_response)
 
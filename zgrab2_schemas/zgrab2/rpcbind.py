@@ -0,0 +1,30 @@
+# zschema sub-schema for zgrab2's rpcbind module
+# Registers zgrab2-rpcbind globally, and rpcbind with the main zgrab2 schema.
+from zschema.leaves import *
+from zschema.compounds import *
+import zschema.registry
+
+import zcrypto_schemas.zcrypto as zcrypto
+from . import zgrab2
+
+# modules/rpcbind/rpc.go: Mapping
+rpcbind_mapping = SubRecord({
+    'program': Unsigned32BitInteger(),
+    'program_name': String(),
+    'version': Unsigned32BitInteger(),
+    'protocol': String(),
+    'port': Unsigned32BitInteger(),
+})
+
+rpcbind_scan_response = SubRecord({
+    'result': SubRecord({
+        'transport': Enum(values=['tcp', 'udp']),
+        'mappings': ListOf(rpcbind_mapping),
+        'programs': ListOf(String()),
+        'rpc_error': String(),
+    })
+}, extends=zgrab2.base_scan_response)
+
+zschema.registry.register_schema('zgrab2-rpcbind', rpcbind_scan_response)
+
+zgrab2.register_scan_response_type('rpcbind', rpcbind_scan_response)
@@ -0,0 +1,37 @@
+# zschema sub-schema for zgrab2's rsync module
+# Registers zgrab2-rsync globally, and rsync with the main zgrab2 schema.
+from zschema.leaves import *
+from zschema.compounds import *
+import zschema.registry
+
+import zcrypto_schemas.zcrypto as zcrypto
+from . import zgrab2
+
+# modules/rsync/rsync.go: Greeting
+rsync_greeting = SubRecord({
+    'raw': String(),
+    'version': String(),
+    'digests': ListOf(String()),
+})
+
+# modules/rsync/rsync.go: DaemonModule
+rsync_module = SubRecord({
+    'name': String(),
+    'comment': String(),
+    'auth_required': Boolean(),
+    'response': String(),
+})
+
+rsync_scan_response = SubRecord({
+    'result': SubRecord({
+        'greeting': rsync_greeting,
+        'motd': String(),
+        'modules': ListOf(rsync_module),
+        'anonymous_modules': Unsigned32BitInteger(),
+        'error': String(),
+    })
+}, extends=zgrab2.base_scan_response)
+
+zschema.registry.register_schema('zgrab2-rsync', rsync_scan_response)
+
+zgrab2.register_scan_response_type('rsync', rsync_scan_response)
@@ -0,0 +1,33 @@
+# zschema sub-schema for zgrab2's rtsp module
+# Registers zgrab2-rtsp globally, and rtsp with the main zgrab2 schema.
+from zschema.leaves import *
+from zschema.compounds import *
+import zschema.registry
+
+import zcrypto_schemas.zcrypto as zcrypto
+from . import zgrab2
+
+# modules/rtsp/rtsp.go: Response
+rtsp_response = SubRecord({
+    'status_line': String(),
+    'version': String(),
+    'status_code': Unsigned16BitInteger(),
+    'status_text': String(),
+    # map[string][]string, keyed by canonical header name
+    'headers': SubRecord({}),  # TODO FIXME: unconstrained dict
+    'body': String(),
+})
+
+rtsp_scan_response = SubRecord({
+    'result': SubRecord({
+        'options': rtsp_response,
+        'public_methods': ListOf(String()),
+        'server': String(),
+        'describe': rtsp_response,
+        'describe_status': Enum(values=['open', 'auth_required', 'not_found', 'other']),
+    })
+}, extends=zgrab2.base_scan_response)
+
+zschema.registry.register_schema('zgrab2-rtsp', rtsp_scan_response)
+
+zgrab2.register_scan_response_type('rtsp', rtsp_scan_response)
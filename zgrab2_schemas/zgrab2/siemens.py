@@ -24,6 +24,11 @@ siemens_scan_response = SubRecord({
         'module_id': String(),
         'hardware': String(),
         'firmware': String(),
+        'firmware_version': String(),
+        'module_identification_records': ListOf(SubRecord({
+            'index': Unsigned16BitInteger(),
+            'raw': Binary(),
+        })),
     })
 }, extends=zgrab2.base_scan_response)
 
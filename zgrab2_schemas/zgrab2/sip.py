@@ -0,0 +1,34 @@
+# zschema sub-schema for zgrab2's sip module
+# Registers zgrab2-sip globally, and sip with the main zgrab2 schema.
+from zschema.leaves import *
+from zschema.compounds import *
+import zschema.registry
+
+import zcrypto_schemas.zcrypto as zcrypto
+from . import zgrab2
+
+# modules/sip/sip.go: Response
+sip_response = SubRecord({
+    'status_line': String(),
+    'version': String(),
+    'status_code': Unsigned16BitInteger(),
+    'status_text': String(),
+    # map[string][]string, keyed by canonical (long-form) header name
+    'headers': SubRecord({}),  # TODO FIXME: unconstrained dict
+})
+
+sip_scan_response = SubRecord({
+    'result': SubRecord({
+        'transport': Enum(values=['udp', 'tcp']),
+        'response': sip_response,
+        'status_code': Unsigned16BitInteger(),
+        'server': String(),
+        'user_agent': String(),
+        'allow': ListOf(String()),
+        'stack': String(),
+    })
+}, extends=zgrab2.base_scan_response)
+
+zschema.registry.register_schema('zgrab2-sip', sip_scan_response)
+
+zgrab2.register_scan_response_type('sip', sip_scan_response)
@@ -11,9 +11,16 @@ smtp_scan_response = SubRecord({
     "result": SubRecord({
         "banner": String(),
         "ehlo": String(),
+        "auth_mechanisms": ListOf(String(), doc="The SASL mechanisms advertised in the server's response to the EHLO command."),
         "helo": String(),
         "help": String(),
         "starttls": String(),
+        "open_relay": SubRecord({
+            "mail_from": String(doc="The server's response to MAIL FROM."),
+            "rcpt_to": String(doc="The server's response to RCPT TO the external recipient, if MAIL FROM was accepted."),
+            "rset": String(doc="The server's response to the RSET that ended the transaction."),
+            "relay": Boolean(doc="True if the server accepted the external recipient, false if it rejected it; absent if the test was inconclusive."),
+        }, doc="The result of the intrusive open relay test, with --open-relay-test."),
         "quit": String(),
         "tls": zgrab2.tls_log,
     })
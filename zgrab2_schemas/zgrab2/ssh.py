@@ -233,6 +233,12 @@ AlgorithmSelection = SubRecordType({
     "server_to_client_alg_group": DirectionAlgorithms(),
 })
 
+# zgrab2/lib/ssh/hassh.go: HASSH
+HASSH = SubRecordType({
+    "string": String(doc="The key exchange, cipher, MAC and compression algorithm lists, joined with ';'."),
+    "hash": String(doc="The hex-encoded MD5 hash of string."),
+})
+
 # zgrab2/lib/ssh/log.go: HandshakeLog
 # TODO: Can ssh re-use any of the generic TLS model?
 ssh_scan_response = SubRecord({
@@ -242,9 +248,27 @@ ssh_scan_response = SubRecord({
         "client_id": EndpointID(),
         "server_key_exchange": KexInitMessage(),
         "client_key_exchange": KexInitMessage(),
+        "hassh": HASSH(doc="The HASSH fingerprint of the client's key exchange offer."),
+        "hassh_server": HASSH(doc="The HASSHServer fingerprint of the server's key exchange offer."),
         "algorithm_selection": AlgorithmSelection(),
         "key_exchange": KeyExchange(),
-        "userauth": ListOf(String()),
+        "dh_group": SubRecord({
+            "name": String(doc="The fixed group (group1 or group14), or absent for a group exchange."),
+            "group_exchange": Boolean(doc="True if the server chose the group in a diffie-hellman-group-exchange."),
+            "prime_bits": Unsigned32BitInteger(doc="The size of the group's prime, in bits; recorded even if a group exchange prime was out of range."),
+            "min_bits": Unsigned32BitInteger(doc="The --gex-min-bits requested in a group exchange."),
+            "preferred_bits": Unsigned32BitInteger(doc="The --gex-preferred-bits requested in a group exchange."),
+            "max_bits": Unsigned32BitInteger(doc="The --gex-max-bits requested in a group exchange."),
+        }, doc="The finite field Diffie-Hellman group of the key exchange, if one was used."),
+        "auth_methods": SubRecord({
+            "service_accepted": Boolean(doc="True if the server accepted the ssh-userauth service request."),
+            "methods": ListOf(String(), doc="The authentication methods the server allows, in the order sent, from its response to the none request."),
+            "partial_success": Boolean(),
+            "none_accepted": Boolean(doc="True if the server accepted the none request, i.e. allows logging in without authenticating."),
+            "error": String(doc="How the exchange failed, e.g. if the server closed the connection on the none request."),
+        }, doc="The server's answer to the none authentication request, with --userauth."),
+        "userauth": ListOf(String(), doc="Deprecated: the same methods as auth_methods.methods, kept for earlier consumers."),
+        "raw_client_banner": String(doc="The hex of the bytes sent in place of the client identification line, with --raw-client-banner."),
         "crypto": KexResult(),
     })
 }, extends=zgrab2.base_scan_response)
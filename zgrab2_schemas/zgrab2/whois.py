@@ -0,0 +1,36 @@
+# zschema sub-schema for zgrab2's whois module
+# Registers zgrab2-whois globally, and whois with the main zgrab2 schema.
+from zschema.leaves import *
+from zschema.compounds import *
+import zschema.registry
+
+import zcrypto_schemas.zcrypto as zcrypto
+from . import zgrab2
+
+# modules/whois/whois.go: Response
+whois_response = SubRecord({
+    'server': String(),
+    'text': String(),
+    'truncated': Boolean(),
+})
+
+# modules/whois/whois.go: Fields
+whois_fields = SubRecord({
+    'registrar': String(),
+    'creation_date': String(),
+    'expiration_date': String(),
+})
+
+whois_scan_response = SubRecord({
+    'result': SubRecord({
+        'query': String(),
+        'response': whois_response,
+        'referral': whois_response,
+        'referral_error': String(),
+        'fields': whois_fields,
+    })
+}, extends=zgrab2.base_scan_response)
+
+zschema.registry.register_schema('zgrab2-whois', whois_scan_response)
+
+zgrab2.register_scan_response_type('whois', whois_scan_response)
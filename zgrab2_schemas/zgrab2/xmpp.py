@@ -0,0 +1,37 @@
+# zschema sub-schema for zgrab2's xmpp module
+# Registers zgrab2-xmpp globally, and xmpp with the main zgrab2 schema.
+from zschema.leaves import *
+from zschema.compounds import *
+import zschema.registry
+
+import zcrypto_schemas.zcrypto as zcrypto
+from . import zgrab2
+
+# modules/xmpp/xmpp.go: Stream
+xmpp_stream = SubRecord({
+    'id': String(),
+    'from': String(),
+    'version': String(),
+    'lang': String(),
+    'features': SubRecord({
+        'starttls': Boolean(),
+        'starttls_required': Boolean(),
+        'sasl_mechanisms': ListOf(String()),
+        'other': ListOf(String()),
+    }),
+    'error': String(),
+})
+
+xmpp_scan_response = SubRecord({
+    'result': SubRecord({
+        'namespace': Enum(values=['jabber:client', 'jabber:server']),
+        'stream': xmpp_stream,
+        'starttls': String(),
+        'tls': zgrab2.tls_log,
+        'tls_stream': xmpp_stream,
+    })
+}, extends=zgrab2.base_scan_response)
+
+zschema.registry.register_schema('zgrab2-xmpp', xmpp_scan_response)
+
+zgrab2.register_scan_response_type('xmpp', xmpp_scan_response)
@@ -29,9 +29,26 @@ def FlagsSet(keys, **kwargs):
 
 # zgrab2/processing.go: Grab
 grab_result = Record({
+    "_meta": SubRecord({
+        "schema_version": Unsigned32BitInteger(doc="The version of the output format."),
+        "zgrab2_version": String(doc="The version of zgrab2 that produced the result."),
+        "modules": ListOf(String(), doc="The modules that produced the result's data."),
+    }, required=False, doc="How the result was produced, if --result-meta was set."),
     # TODO: ip may be required; see https://github.com/zmap/zgrab2/issues/104
     "ip": IPv4Address(required=False, doc="The IP address of the target."),
     "domain": String(required=False, doc="The domain name of the target, if available."),
+    "port": Unsigned16BitInteger(required=False, doc="The port of the target, if given in the input."),
+    "tags": SubRecord({}, required=False, doc="Arbitrary labels carried through from the JSON input."),  # TODO FIXME: unconstrained dict
+    "raw_input": String(required=False, doc="The input record the target was read from, if --echo-input was set."),
+    "truncated": Boolean(required=False, doc="True if the result was longer than --max-record-bytes and was shrunk."),
+    "truncated_fields": ListOf(String(), required=False, doc="The dotted paths of the fields that were clipped or dropped to shrink the result, e.g. data.http.result.response.body."),
+    "geoip": SubRecord({
+        "country": String(doc="The ISO 3166-1 code of the country, e.g. US."),
+        "country_name": String(doc="The English name of the country."),
+        "city": String(doc="The English name of the city."),
+        "asn": Unsigned32BitInteger(doc="The number of the autonomous system announcing the IP."),
+        "organization": String(doc="The name of the organization owning the autonomous system."),
+    }, required=False, doc="The location and network of the target's IP, looked up in the --geoip databases; absent for private and reserved IPs."),
     "data": SubRecord(scan_response_types, doc="The scan data for this host."),
 })
 
@@ -53,14 +70,73 @@ base_scan_response = SubRecord({
     "protocol": String(doc="The identifier of the protocol being scanned."),
     "timestamp": DateTime(doc="The time the scan was started."),
     "result": SubRecord({}, required=False),  # This is overridden by the protocols' implementations
-    "error": String(required=False, doc="If the status was not success, error may contain information about the failure.")
+    "error": String(required=False, doc="If the status was not success, error may contain information about the failure."),
+    "signatures": ListOf(String(), required=False, doc="The names of the --signatures-file rules that matched the result."),
+    "timing": SubRecord({
+        "connect_us": Unsigned32BitInteger(doc="The time taken to establish the scan's first TCP connection, in microseconds."),
+        "handshake_us": Unsigned32BitInteger(doc="The time taken by the scan's first protocol (e.g. TLS) handshake, in microseconds."),
+        "total_us": Unsigned32BitInteger(doc="The time taken by the whole scan, in microseconds."),
+    }, required=False),
+    "wire": SubRecord({
+        "sent": String(doc="The bytes sent to the target, hex-encoded."),
+        "received": String(doc="The bytes received from the target, hex-encoded."),
+        "sent_truncated": Boolean(doc="True if more than --capture-wire-size bytes were sent."),
+        "received_truncated": Boolean(doc="True if more than --capture-wire-size bytes were received."),
+    }, required=False, doc="The raw traffic of the scan's connections, with --capture-wire."),
+    "connected_addr": String(required=False, doc="The remote address (IP:port) of the scan's first connection."),
+    "port": Unsigned16BitInteger(required=False, doc="The --fallback-ports port that answered, if the module's port refused the connection."),
     # TODO: error_component? domain?
 })
 
+# zgrab2/tlssweep.go: TLSSweepProbe
+tls_sweep_probe = SubRecord({
+        "name": String(),
+        "accepted": Boolean(),
+        "cipher_suite": Unsigned16BitInteger(doc="The cipher suite selected by the server, if accepted."),
+        "error": String(),
+    })
+
 # zgrab2/tls.go: TLSLog
 tls_log = SubRecord({
     "handshake_log": zcrypto.TLSHandshake(doc="The TLS handshake log."),
-    "heartbleed_log": zcrypto.HeartbleedLog(doc="The heartbleed scan log, if heartbleed scanning was enabled; otherwise, absent.")
+    "heartbleed_log": zcrypto.HeartbleedLog(doc="The heartbleed scan log, if heartbleed scanning was enabled; otherwise, absent."),
+    "heartbeat_response_length": Unsigned32BitInteger(doc="The number of bytes of the heartbeat response read by the heartbleed check (at most 256), if any."),
+    "jarm": String(doc="The JARM fingerprint, if requested; otherwise, absent."),
+    "ja3s": SubRecord({
+        "string": String(doc="The JA3S string: the ServerHello's version, cipher suite and extension types."),
+        "hash": String(doc="The MD5 hash of the JA3S string."),
+    }, doc="The JA3S fingerprint of the ServerHello, if one was received."),
+    "cert_validation": SubRecord({
+        "valid": Boolean(doc="True if the certificate chains to a trusted root, is within its validity period and matches the hostname."),
+        "chain_valid": Boolean(doc="True if the certificate chains to a trusted root."),
+        "self_signed": Boolean(),
+        "expired": Boolean(),
+        "not_yet_valid": Boolean(),
+        "hostname": String(doc="The name the certificate was checked against."),
+        "hostname_mismatch": Boolean(),
+        "days_until_expiry": Signed32BitInteger(),
+        "error": String(),
+    }, doc="The validity of the server certificate, if --validate-cert was set; otherwise, absent."),
+    "sni_probes": ListOf(SubRecord({
+        "server_name": String(doc="The SNI sent, or absent if none was sent."),
+        "server_certificates": SubRecord({
+            "certificate": zcrypto.SimpleCertificate(),
+            "chain": ListOf(zcrypto.SimpleCertificate()),
+            "validation": zcrypto.TLSCertificateValidation(),
+        }),
+        "same_certificate": Boolean(doc="True if the leaf certificate is the same as in the main handshake."),
+        "error": String(),
+    }), doc="The additional handshakes made without SNI and with a wrong SNI, if --sni-probes was set; otherwise, absent."),
+    "sweep": SubRecord({
+        "accepted_versions": ListOf(String(), doc="The accepted protocol versions, e.g. TLSv1.2."),
+        "versions": ListOf(tls_sweep_probe, doc="The per-version probes, from SSLv3 to TLSv1.3."),
+        "weak_ciphers": ListOf(tls_sweep_probe, doc="The RC4, 3DES, EXPORT and NULL cipher group probes, made with the highest accepted version up to TLSv1.2."),
+        "deprecated": Boolean(doc="True if SSLv3, TLSv1.0, TLSv1.1 or a weak cipher group is accepted."),
+    }, doc="The protocol versions and weak cipher suites accepted, if --sweep was set; otherwise, absent."),
+    "alpn": SubRecord({
+        "offered": ListOf(String(), doc="The ALPN protocols offered in the ClientHello, in order of preference."),
+        "selected": String(doc="The protocol selected by the server, or empty if it selected none (e.g. it does not support ALPN)."),
+    }, doc="The ALPN negotiation, if --alpn was set; otherwise, absent."),
 })
 
 
@@ -0,0 +1,39 @@
+# zschema sub-schema for zgrab2's zookeeper module
+# Registers zgrab2-zookeeper globally, and zookeeper with the main zgrab2 schema.
+from zschema.leaves import *
+from zschema.compounds import *
+import zschema.registry
+
+import zcrypto_schemas.zcrypto as zcrypto
+from . import zgrab2
+
+# modules/zookeeper/zookeeper.go: CommandResult
+zookeeper_command = SubRecord({
+    'command': String(),
+    'permitted': Boolean(),
+    'response': String(),
+    'truncated': Boolean(),
+})
+
+# modules/zookeeper/zookeeper.go: Stats
+zookeeper_stats = SubRecord({
+    'version': String(),
+    'mode': String(),
+    'connections': Signed64BitInteger(),
+    'outstanding': Signed64BitInteger(),
+    'node_count': Signed64BitInteger(),
+    'zxid': String(),
+    'clients': ListOf(String()),
+    'monitor': SubRecord({}),  # TODO FIXME: unconstrained dict
+})
+
+zookeeper_scan_response = SubRecord({
+    'result': SubRecord({
+        'commands': ListOf(zookeeper_command),
+        'stats': zookeeper_stats,
+    })
+}, extends=zgrab2.base_scan_response)
+
+zschema.registry.register_schema('zgrab2-zookeeper', zookeeper_scan_response)
+
+zgrab2.register_scan_response_type('zookeeper', zookeeper_scan_response)